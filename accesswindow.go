@@ -0,0 +1,310 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// TimeWindow is a recurring range of time during which access is allowed.
+type TimeWindow struct {
+	// Days the window applies to. Empty means every day.
+	Days []time.Weekday
+
+	// Start is the opening time in "HH:MM" format.
+	Start string
+
+	// End is the closing time in "HH:MM" format. If End is before Start,
+	// the window spans midnight.
+	End string
+}
+
+// CountryResolver maps a client IP to an ISO 3166-1 alpha-2 country code.
+type CountryResolver interface {
+	Country(ip string) (string, error)
+}
+
+// AccessWindowConfig defines the configuration for access window middleware.
+type AccessWindowConfig struct {
+	// Windows are the allowed weekday/hour ranges.
+	Windows []TimeWindow
+
+	// Schedule is a cron-like expression ("minute hour day-of-month month
+	// day-of-week") describing the minutes during which access is allowed,
+	// e.g. "* 9-17 * * 1-5" for office hours. Fields support *, lists,
+	// ranges and steps.
+	Schedule string
+
+	// Location is the timezone windows and schedules are evaluated in.
+	// Default: time.UTC
+	Location *time.Location
+
+	// CountryResolver resolves client IPs to countries. It is required
+	// when AllowedCountries or DeniedCountries is set, and used when the
+	// GeoIP middleware has not already stored the client location. Use
+	// GeoCountryResolver to share the GeoIP resolver.
+	CountryResolver CountryResolver
+
+	// AllowedCountries restricts access to these country codes.
+	// Clients whose country cannot be resolved are denied.
+	AllowedCountries []string
+
+	// DeniedCountries blocks these country codes.
+	DeniedCountries []string
+
+	// TrustedProxies is a list of trusted proxy IP addresses used when
+	// resolving the client IP.
+	TrustedProxies []string
+
+	// StatusCode is returned when access is denied.
+	// Default: 403 Forbidden
+	StatusCode int
+
+	// ScheduleMessage is returned outside the allowed windows.
+	// Default: "Access is not allowed at this time"
+	ScheduleMessage string
+
+	// RegionMessage is returned for clients outside the allowed regions.
+	// Default: "Access is not allowed from your region"
+	RegionMessage string
+
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+
+	// SkipFunc allows skipping the checks for certain requests.
+	SkipFunc Skipper
+}
+
+// AccessWindow returns middleware that only allows requests within the given windows.
+func AccessWindow(windows ...TimeWindow) ginji.Middleware {
+	return AccessWindowWithConfig(AccessWindowConfig{Windows: windows})
+}
+
+// AccessWindowWithConfig returns access window middleware with custom configuration.
+// It panics if a window or the schedule cannot be parsed, or if countries
+// are restricted without a CountryResolver.
+func AccessWindowWithConfig(config AccessWindowConfig) ginji.Middleware {
+	if (len(config.AllowedCountries) > 0 || len(config.DeniedCountries) > 0) && config.CountryResolver == nil {
+		panic("AccessWindow: CountryResolver is required to restrict countries")
+	}
+	if config.Location == nil {
+		config.Location = time.UTC
+	}
+	if config.StatusCode == 0 {
+		config.StatusCode = http.StatusForbidden
+	}
+	if config.ScheduleMessage == "" {
+		config.ScheduleMessage = "Access is not allowed at this time"
+	}
+	if config.RegionMessage == "" {
+		config.RegionMessage = "Access is not allowed from your region"
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	windows := make([]parsedWindow, 0, len(config.Windows))
+	for _, w := range config.Windows {
+		pw, err := parseTimeWindow(w)
+		if err != nil {
+			panic("AccessWindow: " + err.Error())
+		}
+		windows = append(windows, pw)
+	}
+
+	var schedule *cronSchedule
+	if config.Schedule != "" {
+		s, err := parseCronSchedule(config.Schedule)
+		if err != nil {
+			panic("AccessWindow: " + err.Error())
+		}
+		schedule = s
+	}
+
+	allowed := upperSet(config.AllowedCountries)
+	denied := upperSet(config.DeniedCountries)
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		if len(windows) > 0 || schedule != nil {
+			now := config.Now().In(config.Location)
+			open := schedule != nil && schedule.matches(now)
+			for _, w := range windows {
+				if open {
+					break
+				}
+				open = w.contains(now)
+			}
+			if !open {
+				c.AbortWithStatusJSON(config.StatusCode, ginji.H{
+					"error": config.ScheduleMessage,
+				})
+				return nil
+			}
+		}
+
+		if len(allowed) > 0 || len(denied) > 0 {
+			country := strings.ToUpper(accessCountry(c, config))
+			if denied[country] || (len(allowed) > 0 && !allowed[country]) {
+				c.AbortWithStatusJSON(config.StatusCode, ginji.H{
+					"error": config.RegionMessage,
+				})
+				return nil
+			}
+		}
+
+		return c.Next()
+	}
+}
+
+// accessCountry returns the client country, preferring the location stored
+// by the GeoIP middleware. It returns "" if the country cannot be resolved.
+func accessCountry(c *ginji.Context, config AccessWindowConfig) string {
+	if info, ok := GetGeoInfo(c); ok && info.Country != "" {
+		return info.Country
+	}
+	country, err := config.CountryResolver.Country(clientIP(c.Req, config.TrustedProxies))
+	if err != nil {
+		return ""
+	}
+	return country
+}
+
+// upperSet builds a lookup set of upper-cased values.
+func upperSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[strings.ToUpper(v)] = true
+	}
+	return set
+}
+
+// parsedWindow is a TimeWindow with times converted to minutes of the day.
+type parsedWindow struct {
+	days       map[time.Weekday]bool
+	start, end int
+}
+
+// parseTimeWindow validates and converts a TimeWindow.
+func parseTimeWindow(w TimeWindow) (parsedWindow, error) {
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return parsedWindow{}, err
+	}
+	end, err := parseClock(w.End)
+	if err != nil {
+		return parsedWindow{}, err
+	}
+	pw := parsedWindow{start: start, end: end}
+	if len(w.Days) > 0 {
+		pw.days = make(map[time.Weekday]bool, len(w.Days))
+		for _, d := range w.Days {
+			pw.days[d] = true
+		}
+	}
+	return pw, nil
+}
+
+// contains reports whether t falls within the window.
+func (w parsedWindow) contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	day := t.Weekday()
+
+	if w.start <= w.end {
+		return (w.days == nil || w.days[day]) && minute >= w.start && minute < w.end
+	}
+
+	// Overnight window: the part after midnight belongs to the previous day
+	if minute >= w.start {
+		return w.days == nil || w.days[day]
+	}
+	if minute < w.end {
+		return w.days == nil || w.days[(day+6)%7]
+	}
+	return false
+}
+
+// parseClock parses "HH:MM" into minutes since midnight.
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// cronSchedule is a parsed five-field cron expression.
+type cronSchedule struct {
+	minute, hour, dom, month, dow map[int]bool
+}
+
+// parseCronSchedule parses a five-field cron expression.
+func parseCronSchedule(expr string) (*cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q, expected 5 fields", expr)
+	}
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 6}}
+	sets := make([]map[int]bool, 5)
+	for i, field := range fields {
+		set, err := parseCronField(field, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %w", expr, err)
+		}
+		sets[i] = set
+	}
+	return &cronSchedule{minute: sets[0], hour: sets[1], dom: sets[2], month: sets[3], dow: sets[4]}, nil
+}
+
+// parseCronField parses a single cron field such as "*/15", "1-5" or "1,3,5".
+func parseCronField(field string, min, max int) (map[int]bool, error) {
+	set := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if idx := strings.Index(part, "/"); idx != -1 {
+			s, err := strconv.Atoi(part[idx+1:])
+			if err != nil || s <= 0 {
+				return nil, fmt.Errorf("invalid step in %q", part)
+			}
+			step = s
+			part = part[:idx]
+		}
+
+		lo, hi := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			v, err := strconv.Atoi(bounds[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q", part)
+			}
+			lo, hi = v, v
+			if len(bounds) == 2 {
+				if hi, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, fmt.Errorf("invalid range %q", part)
+				}
+			} else if step > 1 {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return nil, fmt.Errorf("value out of range in %q", part)
+		}
+		for v := lo; v <= hi; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
+
+// matches reports whether t falls in a minute selected by the schedule.
+func (s *cronSchedule) matches(t time.Time) bool {
+	return s.minute[t.Minute()] && s.hour[t.Hour()] && s.dom[t.Day()] &&
+		s.month[int(t.Month())] && s.dow[int(t.Weekday())]
+}
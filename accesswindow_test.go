@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// staticCountryResolver resolves every IP to the same country.
+type staticCountryResolver string
+
+func (r staticCountryResolver) Country(string) (string, error) {
+	if r == "" {
+		return "", errors.New("unknown")
+	}
+	return string(r), nil
+}
+
+func fixedClock(value string) func() time.Time {
+	return func() time.Time {
+		t, _ := time.Parse(time.RFC3339, value)
+		return t
+	}
+}
+
+func TestAccessWindowWeekdayHours(t *testing.T) {
+	newApp := func(now string) *ginji.Engine {
+		app := ginji.New()
+		app.Use(AccessWindowWithConfig(AccessWindowConfig{
+			Windows: []TimeWindow{{
+				Days:  []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+				Start: "09:00",
+				End:   "17:00",
+			}},
+			Now: fixedClock(now),
+		}))
+		app.Get("/", func(c *ginji.Context) error {
+			return c.Text(ginji.StatusOK, "ok")
+		})
+		return app
+	}
+
+	// Wednesday 10:30 UTC
+	if w := ginji.PerformRequest(newApp("2024-05-15T10:30:00Z"), "GET", "/", nil); w.Code != ginji.StatusOK {
+		t.Errorf("Expected access during office hours, got %d", w.Code)
+	}
+	// Wednesday 18:00 UTC
+	if w := ginji.PerformRequest(newApp("2024-05-15T18:00:00Z"), "GET", "/", nil); w.Code != ginji.StatusForbidden {
+		t.Errorf("Expected 403 after hours, got %d", w.Code)
+	}
+	// Saturday 10:30 UTC
+	if w := ginji.PerformRequest(newApp("2024-05-18T10:30:00Z"), "GET", "/", nil); w.Code != ginji.StatusForbidden {
+		t.Errorf("Expected 403 on weekend, got %d", w.Code)
+	}
+}
+
+func TestAccessWindowOvernightAndTimezone(t *testing.T) {
+	tokyo := time.FixedZone("JST", 9*3600)
+	window := TimeWindow{Days: []time.Weekday{time.Friday}, Start: "22:00", End: "02:00"}
+	pw, err := parseTimeWindow(window)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Saturday 01:00 JST belongs to Friday's overnight window
+	if !pw.contains(time.Date(2024, 5, 18, 1, 0, 0, 0, tokyo)) {
+		t.Error("Expected Saturday 01:00 to fall in Friday's overnight window")
+	}
+	if pw.contains(time.Date(2024, 5, 19, 1, 0, 0, 0, tokyo)) {
+		t.Error("Expected Sunday 01:00 to fall outside the window")
+	}
+}
+
+func TestAccessWindowCronSchedule(t *testing.T) {
+	s, err := parseCronSchedule("*/15 9-17 * * 1-5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.matches(time.Date(2024, 5, 15, 9, 30, 0, 0, time.UTC)) {
+		t.Error("Expected 09:30 Wednesday to match")
+	}
+	if s.matches(time.Date(2024, 5, 15, 9, 31, 0, 0, time.UTC)) {
+		t.Error("Expected 09:31 not to match a 15 minute step")
+	}
+	if _, err := parseCronSchedule("* * *"); err == nil {
+		t.Error("Expected invalid schedule to be rejected")
+	}
+}
+
+func TestAccessWindowCountries(t *testing.T) {
+	newApp := func(country string) *ginji.Engine {
+		app := ginji.New()
+		app.Use(AccessWindowWithConfig(AccessWindowConfig{
+			CountryResolver:  staticCountryResolver(country),
+			AllowedCountries: []string{"de", "fr"},
+			RegionMessage:    "EU only",
+		}))
+		app.Get("/", func(c *ginji.Context) error {
+			return c.Text(ginji.StatusOK, "ok")
+		})
+		return app
+	}
+
+	if w := ginji.PerformRequest(newApp("DE"), "GET", "/", nil); w.Code != ginji.StatusOK {
+		t.Errorf("Expected allowed country to pass, got %d", w.Code)
+	}
+	w := ginji.PerformRequest(newApp("US"), "GET", "/", nil)
+	if w.Code != ginji.StatusForbidden {
+		t.Errorf("Expected 403 for other country, got %d", w.Code)
+	}
+	ginji.AssertBody(t, w, "EU only")
+	if w := ginji.PerformRequest(newApp(""), "GET", "/", nil); w.Code != ginji.StatusForbidden {
+		t.Errorf("Expected 403 for unresolved country, got %d", w.Code)
+	}
+}
+
+func TestAccessWindowCountriesRequireResolver(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic without CountryResolver")
+		}
+	}()
+	AccessWindowWithConfig(AccessWindowConfig{DeniedCountries: []string{"US"}})
+}
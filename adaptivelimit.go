@@ -0,0 +1,322 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// Adaptive concurrency limit algorithms.
+const (
+	// AdaptiveGradient grows the limit while latency stays near its long
+	// term baseline and shrinks it in proportion as latency rises or
+	// requests drop.
+	AdaptiveGradient = "gradient"
+
+	// AdaptiveAIMD grows the limit by one per successful request and cuts
+	// it by BackoffRatio on every drop.
+	AdaptiveAIMD = "aimd"
+)
+
+// AdaptiveLimitConfig defines the configuration for adaptive concurrency
+// limits.
+type AdaptiveLimitConfig struct {
+	// Algorithm is AdaptiveGradient or AdaptiveAIMD.
+	// Default: AdaptiveGradient
+	Algorithm string
+
+	// InitialLimit is the in-flight limit at start.
+	// Default: 20
+	InitialLimit int
+
+	// MinLimit is the lowest the limit can go.
+	// Default: 1
+	MinLimit int
+
+	// MaxLimit is the highest the limit can go.
+	// Default: 1000
+	MaxLimit int
+
+	// BackoffRatio multiplies the limit on a drop with AdaptiveAIMD.
+	// Default: 0.9
+	BackoffRatio float64
+
+	// Tolerance is how much latency may rise above its baseline before
+	// AdaptiveGradient shrinks the limit, e.g. 1.5 for 50%.
+	// Default: 1.5
+	Tolerance float64
+
+	// Smoothing weighs each new AdaptiveGradient limit against the old one,
+	// between 0 and 1.
+	// Default: 0.2
+	Smoothing float64
+
+	// IsDrop reports whether a request signals overload.
+	// Default: a 5xx status, a handler error or a timed out request context
+	IsDrop func(c *ginji.Context, err error, latency time.Duration) bool
+
+	// Metrics exports the adaptive_limit and adaptive_in_flight gauges and
+	// the adaptive_rejected_total counter. Optional.
+	Metrics *Metrics
+
+	// StatusCode is sent when a request is over the limit.
+	// Default: 503
+	StatusCode int
+
+	// SkipFunc allows skipping the limit for certain requests.
+	SkipFunc Skipper
+}
+
+// DefaultAdaptiveLimitConfig returns default adaptive limit configuration.
+func DefaultAdaptiveLimitConfig() AdaptiveLimitConfig {
+	return AdaptiveLimitConfig{
+		Algorithm:    AdaptiveGradient,
+		InitialLimit: 20,
+		MinLimit:     1,
+		MaxLimit:     1000,
+		BackoffRatio: 0.9,
+		Tolerance:    1.5,
+		Smoothing:    0.2,
+		IsDrop:       defaultAdaptiveIsDrop,
+		StatusCode:   ginji.StatusServiceUnavailable,
+	}
+}
+
+// AdaptiveLimitStats is the state of an AdaptiveLimit.
+type AdaptiveLimitStats struct {
+	// Limit is the current in-flight limit.
+	Limit int `json:"limit"`
+
+	// InFlight is the number of requests being served.
+	InFlight int `json:"inFlight"`
+
+	// Rejected is the number of requests rejected since start.
+	Rejected int64 `json:"rejected"`
+
+	// BaselineLatency is the long term latency of AdaptiveGradient.
+	BaselineLatency string `json:"baselineLatency,omitempty"`
+}
+
+// adaptiveBaselineSamples is the number of samples the long term latency
+// of AdaptiveGradient averages over.
+const adaptiveBaselineSamples = 600
+
+// AdaptiveLimit caps the number of requests in flight at a limit it finds
+// by itself from observed latency, for services whose capacity is unknown
+// or changes with their dependencies:
+//
+//	limit := middleware.NewAdaptiveLimit(middleware.DefaultAdaptiveLimitConfig())
+//	app.Use(limit.Middleware())
+//
+// Requests over the limit are rejected at once, so the queue of a
+// saturated service stays short and its latency stays low.
+//
+// AdaptiveLimit implements StatsProvider.
+type AdaptiveLimit struct {
+	config AdaptiveLimitConfig
+
+	mu       sync.Mutex
+	limit    float64
+	inFlight int
+	rejected int64
+	baseline float64 // long term latency in seconds
+}
+
+// NewAdaptiveLimit creates an adaptive limit with the given configuration.
+func NewAdaptiveLimit(config AdaptiveLimitConfig) *AdaptiveLimit {
+	defaults := DefaultAdaptiveLimitConfig()
+	if config.Algorithm == "" {
+		config.Algorithm = defaults.Algorithm
+	}
+	if config.Algorithm != AdaptiveGradient && config.Algorithm != AdaptiveAIMD {
+		panic("AdaptiveLimit: unknown algorithm " + config.Algorithm)
+	}
+	if config.MinLimit <= 0 {
+		config.MinLimit = defaults.MinLimit
+	}
+	if config.MaxLimit <= 0 {
+		config.MaxLimit = defaults.MaxLimit
+	}
+	if config.MaxLimit < config.MinLimit {
+		config.MaxLimit = config.MinLimit
+	}
+	if config.InitialLimit <= 0 {
+		config.InitialLimit = defaults.InitialLimit
+	}
+	if config.BackoffRatio <= 0 || config.BackoffRatio >= 1 {
+		config.BackoffRatio = defaults.BackoffRatio
+	}
+	if config.Tolerance < 1 {
+		config.Tolerance = defaults.Tolerance
+	}
+	if config.Smoothing <= 0 || config.Smoothing > 1 {
+		config.Smoothing = defaults.Smoothing
+	}
+	if config.IsDrop == nil {
+		config.IsDrop = defaults.IsDrop
+	}
+	if config.StatusCode == 0 {
+		config.StatusCode = defaults.StatusCode
+	}
+
+	l := &AdaptiveLimit{config: config}
+	l.limit = l.clamp(float64(config.InitialLimit))
+	if m := config.Metrics; m != nil {
+		m.Describe("adaptive_limit", MetricGauge, "Current adaptive concurrency limit.")
+		m.Describe("adaptive_in_flight", MetricGauge, "Number of requests in flight under the adaptive limit.")
+		m.Describe("adaptive_rejected_total", MetricCounter, "Number of requests rejected by the adaptive limit.")
+		m.Set("adaptive_limit", math.Floor(l.limit))
+		m.Set("adaptive_in_flight", 0)
+	}
+	return l
+}
+
+// Middleware returns middleware that rejects requests over the limit and
+// adjusts the limit from the latency of the others.
+func (l *AdaptiveLimit) Middleware() ginji.Middleware {
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if l.config.SkipFunc != nil && l.config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		inFlight, ok := l.acquire()
+		if !ok {
+			if m := l.config.Metrics; m != nil {
+				m.Inc("adaptive_rejected_total")
+			}
+			c.SetHeader("Retry-After", "1")
+			c.AbortWithStatusJSON(l.config.StatusCode, ginji.H{
+				"error": "Too many concurrent requests",
+			})
+			return nil
+		}
+
+		start := time.Now()
+		completed := false
+		defer func() {
+			// A panic is a drop
+			if !completed {
+				l.release(inFlight, time.Since(start), true)
+			}
+		}()
+
+		err := c.Next()
+		completed = true
+		latency := time.Since(start)
+		l.release(inFlight, latency, l.config.IsDrop(c, err, latency))
+		return err
+	}
+}
+
+// Limit returns the current in-flight limit.
+func (l *AdaptiveLimit) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
+
+// Stats implements StatsProvider.
+func (l *AdaptiveLimit) Stats() any {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stats := AdaptiveLimitStats{Limit: int(l.limit), InFlight: l.inFlight, Rejected: l.rejected}
+	if l.baseline > 0 {
+		stats.BaselineLatency = time.Duration(l.baseline * float64(time.Second)).String()
+	}
+	return stats
+}
+
+// acquire admits a request if the limit allows, returning the number of
+// requests in flight including it.
+func (l *AdaptiveLimit) acquire() (int, bool) {
+	l.mu.Lock()
+	if l.inFlight >= int(l.limit) {
+		l.rejected++
+		l.mu.Unlock()
+		return 0, false
+	}
+	l.inFlight++
+	inFlight := l.inFlight
+	l.mu.Unlock()
+
+	if m := l.config.Metrics; m != nil {
+		m.Add("adaptive_in_flight", 1)
+	}
+	return inFlight, true
+}
+
+// release ends a request admitted with inFlight requests in flight and
+// updates the limit from its outcome.
+func (l *AdaptiveLimit) release(inFlight int, latency time.Duration, drop bool) {
+	l.mu.Lock()
+	l.inFlight--
+	if l.config.Algorithm == AdaptiveAIMD {
+		l.updateAIMDLocked(inFlight, drop)
+	} else {
+		l.updateGradientLocked(inFlight, latency, drop)
+	}
+	limit := math.Floor(l.limit)
+	l.mu.Unlock()
+
+	if m := l.config.Metrics; m != nil {
+		m.Add("adaptive_in_flight", -1)
+		m.Set("adaptive_limit", limit)
+	}
+}
+
+// updateAIMDLocked grows the limit additively and cuts it multiplicatively.
+func (l *AdaptiveLimit) updateAIMDLocked(inFlight int, drop bool) {
+	switch {
+	case drop:
+		l.limit = l.clamp(l.limit * l.config.BackoffRatio)
+	case float64(inFlight)*2 >= l.limit:
+		// Only grow a limit that is being used
+		l.limit = l.clamp(l.limit + 1)
+	}
+}
+
+// updateGradientLocked moves the limit by the gradient between the long
+// term and current latency, plus headroom to probe for more capacity.
+func (l *AdaptiveLimit) updateGradientLocked(inFlight int, latency time.Duration, drop bool) {
+	sample := latency.Seconds()
+	if sample <= 0 {
+		return
+	}
+	if l.baseline == 0 {
+		l.baseline = sample
+	} else {
+		l.baseline += (sample - l.baseline) / adaptiveBaselineSamples
+	}
+	// Let the baseline recover quickly after latency drops for good
+	if l.baseline > 2*sample {
+		l.baseline = 2 * sample
+	}
+
+	// Do not grow a limit that is not being used
+	if float64(inFlight)*2 < l.limit && !drop {
+		return
+	}
+
+	gradient := math.Max(0.5, math.Min(1, l.config.Tolerance*l.baseline/sample))
+	if drop {
+		gradient = 0.5
+	}
+	target := l.limit*gradient + math.Sqrt(l.limit)
+	l.limit = l.clamp(l.limit*(1-l.config.Smoothing) + target*l.config.Smoothing)
+}
+
+// clamp keeps limit within MinLimit and MaxLimit.
+func (l *AdaptiveLimit) clamp(limit float64) float64 {
+	return math.Max(float64(l.config.MinLimit), math.Min(float64(l.config.MaxLimit), limit))
+}
+
+// defaultAdaptiveIsDrop treats 5xx responses, handler errors and timed out
+// requests as drops.
+func defaultAdaptiveIsDrop(c *ginji.Context, err error, latency time.Duration) bool {
+	return err != nil || c.StatusCode() >= 500 || errors.Is(c.Req.Context().Err(), context.DeadlineExceeded)
+}
@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+func TestAdaptiveLimitRejectsOverLimit(t *testing.T) {
+	metrics := NewMetrics(DefaultMetricsConfig())
+	limit := NewAdaptiveLimit(AdaptiveLimitConfig{
+		Algorithm:    AdaptiveAIMD,
+		InitialLimit: 1,
+		MaxLimit:     1,
+		Metrics:      metrics,
+	})
+
+	started, release := make(chan struct{}), make(chan struct{})
+	app := ginji.New()
+	app.Use(limit.Middleware())
+	app.Get("/", func(c *ginji.Context) error {
+		close(started)
+		<-release
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	done := make(chan int)
+	go func() { done <- ginji.PerformRequest(app, "GET", "/", nil).Code }()
+	<-started
+
+	w := ginji.PerformRequest(app, "GET", "/", nil)
+	ginji.AssertStatus(t, w, ginji.StatusServiceUnavailable)
+	ginji.AssertHeader(t, w, "Retry-After", "1")
+
+	if v := metrics.Value("adaptive_in_flight"); v != 1 {
+		t.Errorf("Expected one request in flight, got %v", v)
+	}
+	if v := metrics.Value("adaptive_rejected_total"); v != 1 {
+		t.Errorf("Expected one rejection, got %v", v)
+	}
+
+	close(release)
+	if code := <-done; code != ginji.StatusOK {
+		t.Errorf("Expected first request to succeed, got %d", code)
+	}
+	stats := limit.Stats().(AdaptiveLimitStats)
+	if stats.InFlight != 0 || stats.Rejected != 1 || stats.Limit != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestAdaptiveLimitAIMD(t *testing.T) {
+	limit := NewAdaptiveLimit(AdaptiveLimitConfig{
+		Algorithm:    AdaptiveAIMD,
+		InitialLimit: 10,
+		MaxLimit:     100,
+	})
+
+	// Growth needs the limit to be in use
+	limit.release(1, time.Millisecond, false)
+	if got := limit.Limit(); got != 10 {
+		t.Errorf("Expected an idle limit to stay at 10, got %d", got)
+	}
+
+	limit.release(5, time.Millisecond, false)
+	limit.release(6, time.Millisecond, false)
+	if got := limit.Limit(); got != 12 {
+		t.Errorf("Expected limit 12 after two successes, got %d", got)
+	}
+
+	limit.release(6, time.Millisecond, true)
+	if got := limit.Limit(); got != 10 {
+		t.Errorf("Expected limit 10 after a drop, got %d", got)
+	}
+}
+
+func TestAdaptiveLimitGradient(t *testing.T) {
+	limit := NewAdaptiveLimit(AdaptiveLimitConfig{
+		InitialLimit: 20,
+		MaxLimit:     1000,
+	})
+
+	// Stable latency under load grows the limit
+	for range 50 {
+		limit.release(limit.Limit(), 10*time.Millisecond, false)
+	}
+	grown := limit.Limit()
+	if grown <= 20 {
+		t.Fatalf("Expected the limit to grow at stable latency, got %d", grown)
+	}
+
+	// Rising latency shrinks it
+	for range 20 {
+		limit.release(limit.Limit(), 50*time.Millisecond, false)
+	}
+	if got := limit.Limit(); got >= grown {
+		t.Errorf("Expected the limit to shrink as latency rises, got %d from %d", got, grown)
+	}
+
+	stats := limit.Stats().(AdaptiveLimitStats)
+	if stats.BaselineLatency == "" {
+		t.Error("Expected a baseline latency")
+	}
+}
+
+func TestAdaptiveLimitBounds(t *testing.T) {
+	limit := NewAdaptiveLimit(AdaptiveLimitConfig{
+		Algorithm:    AdaptiveAIMD,
+		InitialLimit: 3,
+		MinLimit:     2,
+		MaxLimit:     4,
+	})
+
+	for range 10 {
+		limit.release(limit.Limit(), time.Millisecond, true)
+	}
+	if got := limit.Limit(); got != 2 {
+		t.Errorf("Expected MinLimit 2, got %d", got)
+	}
+	for range 10 {
+		limit.release(limit.Limit(), time.Millisecond, false)
+	}
+	if got := limit.Limit(); got != 4 {
+		t.Errorf("Expected MaxLimit 4, got %d", got)
+	}
+}
@@ -0,0 +1,221 @@
+package middleware
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"runtime"
+	"strings"
+
+	"github.com/ginjigo/ginji"
+)
+
+// StatsProvider reports runtime statistics shown by the admin endpoint.
+// Circuit breakers, caches and session stores expose their state by
+// implementing it.
+type StatsProvider interface {
+	Stats() any
+}
+
+// StatsFunc adapts a function to the StatsProvider interface.
+type StatsFunc func() any
+
+// Stats calls f().
+func (f StatsFunc) Stats() any {
+	return f()
+}
+
+// AdminConfig defines the configuration for admin endpoint middleware.
+type AdminConfig struct {
+	// Path is the base path of the admin endpoints.
+	// Default: "/debug/middleware"
+	Path string
+
+	// Auth guards the endpoints, e.g. BasicAuth or BearerAuth. It runs
+	// after the IP allowlist check, which cannot tell clients apart
+	// behind a proxy on the same host. Required.
+	Auth ginji.Middleware
+
+	// AllowIPs lists the IP addresses or CIDR ranges allowed to reach the
+	// endpoints.
+	// Default: loopback addresses only
+	AllowIPs []string
+
+	// TrustedProxies is a list of trusted proxy IP addresses used when
+	// resolving the client IP.
+	TrustedProxies []string
+
+	// Stats are reported by name, e.g. rate limiter bucket counts from
+	// RateLimitWithStats.
+	Stats map[string]StatsProvider
+
+	// Maintenance enables the maintenance mode toggle. Pass the same
+	// Reloader to MaintenanceDynamic. Optional.
+	Maintenance *Reloader[MaintenanceConfig]
+
+	// LogLevel enables the log level toggle. Pass the same LevelVar to the
+	// slog handler options. Optional.
+	LogLevel *slog.LevelVar
+
+	// RateLimiter enables listing and lifting the bans of a rate limiter
+	// with a Penalty. Optional.
+	RateLimiter *Limiter
+
+	// SkipFunc allows skipping the admin endpoints for certain requests.
+	SkipFunc Skipper
+}
+
+// DefaultAdminConfig returns default admin configuration.
+func DefaultAdminConfig() AdminConfig {
+	return AdminConfig{
+		Path:     "/debug/middleware",
+		AllowIPs: []string{"127.0.0.0/8", "::1"},
+	}
+}
+
+// AdminEndpoints returns middleware serving runtime state and toggles:
+//
+//	GET  {Path}              stats, maintenance mode, log level and runtime info
+//	POST {Path}/maintenance  {"enabled": true} switches maintenance mode
+//	POST {Path}/log-level    {"level": "debug"} changes the log level
+//	POST {Path}/unban        {"key": "203.0.113.7"} lifts a rate limit ban
+//
+// It panics if Auth is nil.
+func AdminEndpoints(config AdminConfig) ginji.Middleware {
+	defaults := DefaultAdminConfig()
+	if config.Auth == nil {
+		panic("AdminEndpoints: Auth is required")
+	}
+	if config.Path == "" {
+		config.Path = defaults.Path
+	}
+	config.Path = strings.TrimSuffix(config.Path, "/")
+	if len(config.AllowIPs) == 0 {
+		config.AllowIPs = defaults.AllowIPs
+	}
+	allow, err := parseIPNets(config.AllowIPs)
+	if err != nil {
+		panic("AdminEndpoints: " + err.Error())
+	}
+
+	serve := func(c *ginji.Context) error {
+		defer c.Abort()
+		switch strings.TrimPrefix(c.Req.URL.Path, config.Path) {
+		case "", "/":
+			if c.Req.Method != http.MethodGet {
+				break
+			}
+			return c.JSON(http.StatusOK, adminState(config))
+		case "/maintenance":
+			if c.Req.Method != http.MethodPost || config.Maintenance == nil {
+				break
+			}
+			return adminSetMaintenance(c, config.Maintenance)
+		case "/log-level":
+			if c.Req.Method != http.MethodPost || config.LogLevel == nil {
+				break
+			}
+			return adminSetLogLevel(c, config.LogLevel)
+		case "/unban":
+			if c.Req.Method != http.MethodPost || config.RateLimiter == nil {
+				break
+			}
+			return adminUnban(c, config.RateLimiter)
+		}
+		return c.JSON(http.StatusNotFound, ginji.H{"error": "Not found"})
+	}
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		path := c.Req.URL.Path
+		if path != config.Path && !strings.HasPrefix(path, config.Path+"/") {
+			return c.Next()
+		}
+
+		ip := net.ParseIP(clientIP(c.Req, config.TrustedProxies))
+		if ip == nil || !ipInNets(ip, allow) {
+			c.AbortWithStatusJSON(http.StatusForbidden, ginji.H{
+				"error": "Access denied",
+			})
+			return nil
+		}
+
+		return ginji.Combine(config.Auth, serve)(c)
+	}
+}
+
+// adminState builds the GET response.
+func adminState(config AdminConfig) ginji.H {
+	stats := make(map[string]any, len(config.Stats))
+	for name, provider := range config.Stats {
+		stats[name] = provider.Stats()
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	state := ginji.H{
+		"stats": stats,
+		"runtime": ginji.H{
+			"goroutines": runtime.NumGoroutine(),
+			"heap_alloc": mem.HeapAlloc,
+			"num_gc":     mem.NumGC,
+		},
+	}
+	if config.Maintenance != nil {
+		state["maintenance"] = config.Maintenance.Load().Enabled
+	}
+	if config.LogLevel != nil {
+		state["log_level"] = config.LogLevel.Level().String()
+	}
+	if config.RateLimiter != nil {
+		state["bans"] = config.RateLimiter.Bans()
+	}
+	return state
+}
+
+// adminSetMaintenance switches maintenance mode.
+func adminSetMaintenance(c *ginji.Context, maintenance *Reloader[MaintenanceConfig]) error {
+	var body struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := c.BindJSON(&body); err != nil || body.Enabled == nil {
+		return c.JSON(http.StatusBadRequest, ginji.H{"error": `Expected {"enabled": true|false}`})
+	}
+	updated := maintenance.Update(func(m MaintenanceConfig) MaintenanceConfig {
+		m.Enabled = *body.Enabled
+		return m
+	})
+	return c.JSON(http.StatusOK, ginji.H{"maintenance": updated.Enabled})
+}
+
+// adminSetLogLevel changes the log level.
+func adminSetLogLevel(c *ginji.Context, level *slog.LevelVar) error {
+	var body struct {
+		Level string `json:"level"`
+	}
+	var parsed slog.Level
+	if err := c.BindJSON(&body); err != nil || parsed.UnmarshalText([]byte(body.Level)) != nil {
+		return c.JSON(http.StatusBadRequest, ginji.H{"error": "Expected a level of debug, info, warn or error"})
+	}
+	level.Set(parsed)
+	return c.JSON(http.StatusOK, ginji.H{"log_level": parsed.String()})
+}
+
+// adminUnban lifts a rate limit ban.
+func adminUnban(c *ginji.Context, limiter *Limiter) error {
+	var body struct {
+		Key string `json:"key"`
+	}
+	if err := c.BindJSON(&body); err != nil || body.Key == "" {
+		return c.JSON(http.StatusBadRequest, ginji.H{"error": `Expected {"key": "..."}`})
+	}
+	if !limiter.Unban(body.Key) {
+		return c.JSON(http.StatusNotFound, ginji.H{"error": "Key is not banned"})
+	}
+	return c.JSON(http.StatusOK, ginji.H{"unbanned": body.Key})
+}
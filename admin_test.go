@@ -0,0 +1,167 @@
+package middleware
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+func adminRequest(app *ginji.Engine, method, path string, body io.Reader) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, body)
+	req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte("admin:secret")))
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	return w
+}
+
+func TestAdminEndpointsDefaultsToLoopback(t *testing.T) {
+	app := ginji.New()
+	app.Use(AdminEndpoints(AdminConfig{Auth: BasicAuth(map[string]string{"admin": "secret"})}))
+	app.Get("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	w := adminRequest(app, "GET", "/debug/middleware", nil)
+	ginji.AssertStatus(t, w, ginji.StatusForbidden)
+
+	w = ginji.PerformRequest(app, "GET", "/", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+}
+
+func TestAdminEndpointsAuth(t *testing.T) {
+	app := ginji.New()
+	app.Use(AdminEndpoints(AdminConfig{
+		AllowIPs: []string{"192.0.2.0/24"},
+		Auth:     BasicAuth(map[string]string{"admin": "secret"}),
+	}))
+	app.Get("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/debug/middleware", nil)
+	ginji.AssertStatus(t, w, ginji.StatusUnauthorized)
+
+	w = adminRequest(app, "GET", "/debug/middleware", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+}
+
+func TestAdminEndpointsRequiresAuth(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic for AdminEndpoints without Auth")
+		}
+	}()
+	AdminEndpoints(AdminConfig{})
+}
+
+func TestAdminEndpointsState(t *testing.T) {
+	limit, stats := RateLimitWithStats(RateLimiterConfig{Max: 1, Window: time.Minute})
+	maintenance := NewReloader(DefaultMaintenanceConfig())
+	level := new(slog.LevelVar)
+
+	app := ginji.New()
+	app.Use(AdminEndpoints(AdminConfig{
+		AllowIPs:    []string{"192.0.2.0/24"},
+		Auth:        BasicAuth(map[string]string{"admin": "secret"}),
+		Stats:       map[string]StatsProvider{"ratelimit": stats},
+		Maintenance: maintenance,
+		LogLevel:    level,
+	}))
+	app.Use(MaintenanceDynamic(maintenance.Load))
+	app.Use(limit)
+	app.Get("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	ginji.PerformRequest(app, "GET", "/", nil)
+	ginji.PerformRequest(app, "GET", "/", nil)
+
+	w := adminRequest(app, "GET", "/debug/middleware", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+
+	var state struct {
+		Maintenance bool   `json:"maintenance"`
+		LogLevel    string `json:"log_level"`
+		Stats       struct {
+			RateLimit RateLimitStats `json:"ratelimit"`
+		} `json:"stats"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &state); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if state.Maintenance || state.LogLevel != "INFO" {
+		t.Errorf("Unexpected toggles: %+v", state)
+	}
+	if state.Stats.RateLimit.Buckets != 1 || state.Stats.RateLimit.Exhausted != 1 {
+		t.Errorf("Expected 1 exhausted bucket, got %+v", state.Stats.RateLimit)
+	}
+
+	w = adminRequest(app, "POST", "/debug/middleware/maintenance", strings.NewReader(`{"enabled": true}`))
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	w = ginji.PerformRequest(app, "GET", "/", nil)
+	ginji.AssertStatus(t, w, ginji.StatusServiceUnavailable)
+
+	w = adminRequest(app, "POST", "/debug/middleware/log-level", strings.NewReader(`{"level": "debug"}`))
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	if level.Level() != slog.LevelDebug {
+		t.Errorf("Expected debug level, got %v", level.Level())
+	}
+
+	w = adminRequest(app, "POST", "/debug/middleware/log-level", strings.NewReader(`{"level": "loud"}`))
+	ginji.AssertStatus(t, w, ginji.StatusBadRequest)
+}
+
+func TestAdminEndpointsUnknownPath(t *testing.T) {
+	app := ginji.New()
+	app.Use(AdminEndpoints(AdminConfig{
+		AllowIPs: []string{"192.0.2.0/24"},
+		Auth:     BasicAuth(map[string]string{"admin": "secret"}),
+	}))
+	app.Get("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	w := adminRequest(app, "POST", "/debug/middleware/maintenance", strings.NewReader(`{"enabled": true}`))
+	ginji.AssertStatus(t, w, ginji.StatusNotFound)
+}
+
+func TestAdminEndpointsUnban(t *testing.T) {
+	limiter := NewLimiter(RateLimiterConfig{
+		Max:     1,
+		Window:  time.Minute,
+		Penalty: RateLimitPenalty{Violations: 1},
+	})
+	defer limiter.Close()
+
+	app := ginji.New()
+	app.Use(AdminEndpoints(AdminConfig{
+		AllowIPs:    []string{"192.0.2.0/24"},
+		Auth:        BasicAuth(map[string]string{"admin": "secret"}),
+		RateLimiter: limiter,
+	}))
+	app.Use(limiter.Middleware())
+	app.Get("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	for range 3 {
+		ginji.PerformRequest(app, "GET", "/", nil)
+	}
+	w := adminRequest(app, "GET", "/debug/middleware", nil)
+	ginji.AssertBody(t, w, `"bans":{"192.0.2.1:1234":`)
+
+	w = adminRequest(app, "POST", "/debug/middleware/unban", strings.NewReader(`{"key": "192.0.2.1:1234"}`))
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	w = adminRequest(app, "POST", "/debug/middleware/unban", strings.NewReader(`{"key": "192.0.2.1:1234"}`))
+	ginji.AssertStatus(t, w, ginji.StatusNotFound)
+	if len(limiter.Bans()) != 0 {
+		t.Error("Expected the ban to be lifted")
+	}
+}
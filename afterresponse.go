@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/ginjigo/ginji"
+)
+
+// ErrNoAfterResponse is returned by OnCommit when no AfterResponse
+// middleware is running for the request.
+var ErrNoAfterResponse = errors.New("afterresponse: no AfterResponse middleware in chain")
+
+// AfterResponseConfig defines the configuration for after response hooks.
+type AfterResponseConfig struct {
+	// IsSuccess reports whether the request succeeded, so that its OnCommit
+	// callbacks run.
+	// Default: no error was returned, the request was not aborted and the
+	// status is below 400
+	IsSuccess func(c *ginji.Context, err error) bool
+
+	// Logger logs callbacks that panic. If nil, uses engine's logger.
+	Logger *slog.Logger
+
+	// SkipFunc allows skipping the middleware for certain requests.
+	SkipFunc Skipper
+}
+
+// DefaultAfterResponseConfig returns default after response configuration.
+func DefaultAfterResponseConfig() AfterResponseConfig {
+	return AfterResponseConfig{
+		IsSuccess: func(c *ginji.Context, err error) bool {
+			return err == nil && !c.IsAborted() && c.StatusCode() < 400
+		},
+	}
+}
+
+// AfterResponse returns after response middleware with default configuration.
+func AfterResponse() ginji.Middleware {
+	return AfterResponseWithConfig(DefaultAfterResponseConfig())
+}
+
+// AfterResponseWithConfig returns middleware that runs the callbacks
+// registered with OnCommit once the handler has returned, and only if the
+// request succeeded and its response was written without error. This makes
+// side effects such as cache invalidation, event publication or audit
+// flushing follow the outcome the client saw:
+//
+//	app.Use(middleware.AfterResponse())
+//	app.Use(middleware.WithTxn(db))
+//	app.Put("/users/:id", func(c *ginji.Context) error {
+//		...
+//		middleware.OnCommit(c, func() { cache.Delete("user:" + id) })
+//		return c.JSON(ginji.StatusOK, user)
+//	})
+//
+// Place it before WithTxn, so callbacks run after the commit. Callbacks run
+// in registration order on the request goroutine; hand long work to a
+// goroutine or queue. A panicking callback is logged and does not stop the
+// others.
+func AfterResponseWithConfig(config AfterResponseConfig) ginji.Middleware {
+	defaults := DefaultAfterResponseConfig()
+	if config.IsSuccess == nil {
+		config.IsSuccess = defaults.IsSuccess
+	}
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		failed := false
+		WrapResponseWriter(c).OnWriteError(func(err error) error {
+			failed = true
+			return err
+		})
+		hooks := &afterResponseHooks{}
+		c.Set("after_response", hooks)
+
+		err := c.Next()
+
+		callbacks := hooks.callbacks
+		hooks.callbacks = nil
+		hooks.closed = true
+		if len(callbacks) == 0 || failed || !config.IsSuccess(c, err) {
+			return err
+		}
+
+		logger := requestLogger(c, config.Logger)
+		for _, fn := range callbacks {
+			runAfterResponse(c, logger, fn)
+		}
+		return err
+	}
+}
+
+// OnCommit registers fn to run after the response has been written, if the
+// request succeeds. It returns ErrNoAfterResponse if the AfterResponse
+// middleware is not running, or has already finished, for the request.
+func OnCommit(c *ginji.Context, fn func()) error {
+	v, exists := c.Get("after_response")
+	if !exists {
+		return ErrNoAfterResponse
+	}
+	hooks, ok := v.(*afterResponseHooks)
+	if !ok || hooks.closed {
+		return ErrNoAfterResponse
+	}
+	hooks.callbacks = append(hooks.callbacks, fn)
+	return nil
+}
+
+// runAfterResponse runs fn, logging a panic instead of propagating it.
+func runAfterResponse(c *ginji.Context, logger *slog.Logger, fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.LogAttrs(c.Req.Context(), slog.LevelError, "After response callback panicked",
+				slog.String("method", c.Req.Method),
+				slog.String("path", c.Req.URL.Path),
+				slog.Any("panic", r),
+			)
+		}
+	}()
+	fn()
+}
+
+// afterResponseHooks holds a request's OnCommit callbacks.
+type afterResponseHooks struct {
+	callbacks []func()
+	closed    bool
+}
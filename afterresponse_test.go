@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ginjigo/ginji"
+)
+
+func TestAfterResponse(t *testing.T) {
+	var ran []string
+	var buf bytes.Buffer
+	app := ginji.New()
+	app.Use(func(c *ginji.Context) error {
+		_ = c.Next()
+		return nil
+	})
+	app.Use(AfterResponseWithConfig(AfterResponseConfig{
+		Logger: slog.New(slog.NewJSONHandler(&buf, nil)),
+	}))
+	register := func(c *ginji.Context, name string) {
+		if err := OnCommit(c, func() { ran = append(ran, name) }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	app.Get("/ok", func(c *ginji.Context) error {
+		register(c, "first")
+		register(c, "second")
+		return c.Text(ginji.StatusCreated, "ok")
+	})
+	app.Get("/error", func(c *ginji.Context) error {
+		register(c, "error")
+		return errors.New("boom")
+	})
+	app.Get("/notfound", func(c *ginji.Context) error {
+		register(c, "notfound")
+		return c.Text(ginji.StatusNotFound, "missing")
+	})
+	app.Get("/aborted", func(c *ginji.Context) error {
+		register(c, "aborted")
+		c.AbortWithStatusJSON(ginji.StatusOK, ginji.H{})
+		return nil
+	})
+	app.Get("/panics", func(c *ginji.Context) error {
+		_ = OnCommit(c, func() { panic("callback") })
+		register(c, "after panic")
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	for _, path := range []string{"/ok", "/error", "/notfound", "/aborted", "/panics"} {
+		ginji.PerformRequest(app, "GET", path, nil)
+	}
+
+	if strings.Join(ran, ",") != "first,second,after panic" {
+		t.Errorf("Unexpected callbacks %v", ran)
+	}
+	if !strings.Contains(buf.String(), "After response callback panicked") {
+		t.Errorf("Expected the panic to be logged, got %s", buf.String())
+	}
+}
+
+// errorWriter fails every write, as for a client that went away.
+type errorWriter struct {
+	http.ResponseWriter
+}
+
+func (w *errorWriter) Write([]byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+// recordingTx records its commit in events.
+type recordingTx struct {
+	events *[]string
+}
+
+func (tx *recordingTx) Commit() error {
+	*tx.events = append(*tx.events, "commit")
+	return nil
+}
+
+func (tx *recordingTx) Rollback() error {
+	*tx.events = append(*tx.events, "rollback")
+	return nil
+}
+
+func TestAfterResponseWriteFailure(t *testing.T) {
+	ran := false
+	app := ginji.New()
+	app.Use(func(c *ginji.Context) error {
+		c.Res = &errorWriter{ResponseWriter: c.Res}
+		return c.Next()
+	})
+	app.Use(AfterResponse())
+	app.Get("/", func(c *ginji.Context) error {
+		_ = OnCommit(c, func() { ran = true })
+		_ = c.Text(ginji.StatusOK, "ok")
+		return nil
+	})
+
+	ginji.PerformRequest(app, "GET", "/", nil)
+	if ran {
+		t.Error("Expected no callbacks after a failed write")
+	}
+}
+
+func TestAfterResponseAfterTxn(t *testing.T) {
+	var events []string
+	db := TxBeginnerFunc(func(ctx context.Context, opts *sql.TxOptions) (Tx, error) {
+		return &recordingTx{events: &events}, nil
+	})
+
+	app := ginji.New()
+	app.Use(AfterResponse())
+	app.Use(WithTxn(db))
+	app.Get("/", func(c *ginji.Context) error {
+		_ = OnCommit(c, func() { events = append(events, "callback") })
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	ginji.PerformRequest(app, "GET", "/", nil)
+	if strings.Join(events, ",") != "commit,callback" {
+		t.Errorf("Expected the callback after the commit, got %v", events)
+	}
+}
+
+func TestOnCommitWithoutMiddleware(t *testing.T) {
+	app := ginji.New()
+	app.Get("/", func(c *ginji.Context) error {
+		if err := OnCommit(c, func() {}); !errors.Is(err, ErrNoAfterResponse) {
+			t.Errorf("Expected ErrNoAfterResponse, got %v", err)
+		}
+		return nil
+	})
+	ginji.PerformRequest(app, "GET", "/", nil)
+}
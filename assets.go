@@ -0,0 +1,263 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"html/template"
+	"io"
+	"io/fs"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// AssetsConfig defines the configuration for the asset integrity helper.
+type AssetsConfig struct {
+	// FS holds the static assets, e.g. os.DirFS("public"). Required.
+	FS fs.FS
+
+	// Prefix is the URL prefix the assets are served under, matching the
+	// prefix passed to app.Static.
+	// Default: "/static"
+	Prefix string
+
+	// Algorithm is the Subresource Integrity hash: "sha256", "sha384" or
+	// "sha512".
+	// Default: "sha384"
+	Algorithm string
+
+	// ImmutableMaxAge is the Cache-Control max-age sent for fingerprinted
+	// assets, which never change under the same URL.
+	// Default: 365 days
+	ImmutableMaxAge time.Duration
+
+	// ContextKey is the key used to store the Assets in context.
+	// Default: "assets"
+	ContextKey string
+
+	// SkipFunc allows skipping the middleware for certain requests.
+	SkipFunc Skipper
+}
+
+// DefaultAssetsConfig returns default asset configuration.
+func DefaultAssetsConfig() AssetsConfig {
+	return AssetsConfig{
+		Prefix:          "/static",
+		Algorithm:       "sha384",
+		ImmutableMaxAge: 365 * 24 * time.Hour,
+		ContextKey:      "assets",
+	}
+}
+
+// Asset is a static file known to Assets.
+type Asset struct {
+	// URL is the path the asset is served at.
+	URL string `json:"url"`
+
+	// Integrity is the Subresource Integrity value, e.g. "sha384-...".
+	Integrity string `json:"integrity"`
+
+	// Version is a short content hash used to fingerprint URLs.
+	Version string `json:"version"`
+}
+
+// fingerprintPattern matches file names with a content hash, such as
+// "app.3f9a2c1d.js" or "app-3f9a2c1d.js".
+var fingerprintPattern = regexp.MustCompile(`[.-][0-9a-fA-F]{8,}\.[^./]+$`)
+
+// Assets computes Subresource Integrity hashes for static assets served with
+// app.Static and marks fingerprinted URLs as immutable. Its middleware
+// exposes it to handlers and templates through the context.
+//
+//	assets, err := middleware.NewAssets(middleware.AssetsConfig{FS: os.DirFS("public")})
+//	app.Use(assets.Middleware())
+//	app.Static("/static", "public")
+//
+// In templates, with assets.FuncMap() registered:
+//
+//	<script src="{{asset "app.js"}}" integrity="{{integrity "app.js"}}" crossorigin="anonymous"></script>
+type Assets struct {
+	config AssetsConfig
+	mu     sync.RWMutex
+	assets map[string]Asset // by name relative to FS
+}
+
+// NewAssets hashes every file in config.FS. It returns an error if the
+// files cannot be read or the algorithm is unknown.
+func NewAssets(config AssetsConfig) (*Assets, error) {
+	defaults := DefaultAssetsConfig()
+	if config.FS == nil {
+		return nil, errors.New("assets: FS is required")
+	}
+	if config.Prefix == "" {
+		config.Prefix = defaults.Prefix
+	}
+	config.Prefix = "/" + strings.Trim(config.Prefix, "/")
+	if config.Algorithm == "" {
+		config.Algorithm = defaults.Algorithm
+	}
+	if newAssetHash(config.Algorithm) == nil {
+		return nil, fmt.Errorf("assets: unsupported algorithm %q", config.Algorithm)
+	}
+	if config.ImmutableMaxAge == 0 {
+		config.ImmutableMaxAge = defaults.ImmutableMaxAge
+	}
+	if config.ContextKey == "" {
+		config.ContextKey = defaults.ContextKey
+	}
+
+	a := &Assets{config: config}
+	if err := a.Reload(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Reload rehashes the files, e.g. after a rebuild in development.
+func (a *Assets) Reload() error {
+	assets := make(map[string]Asset)
+	err := fs.WalkDir(a.config.FS, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		asset, err := a.hashFile(name)
+		if err != nil {
+			return err
+		}
+		assets[name] = asset
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("assets: %w", err)
+	}
+
+	a.mu.Lock()
+	a.assets = assets
+	a.mu.Unlock()
+	return nil
+}
+
+// Get returns the asset with the given name, relative to FS.
+func (a *Assets) Get(name string) (Asset, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	asset, ok := a.assets[strings.TrimPrefix(name, "/")]
+	return asset, ok
+}
+
+// Integrity returns the Subresource Integrity value of an asset, or "" if
+// it is unknown.
+func (a *Assets) Integrity(name string) string {
+	asset, _ := a.Get(name)
+	return asset.Integrity
+}
+
+// URL returns the fingerprinted URL of an asset. Names that already carry
+// a content hash are returned as is; others get a "?v=" version query.
+// Unknown assets get a plain URL.
+func (a *Assets) URL(name string) string {
+	asset, ok := a.Get(name)
+	if !ok {
+		return path.Join(a.config.Prefix, name)
+	}
+	if fingerprintPattern.MatchString(name) {
+		return asset.URL
+	}
+	return asset.URL + "?v=" + asset.Version
+}
+
+// FuncMap returns the "asset" and "integrity" template functions.
+func (a *Assets) FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"asset":     a.URL,
+		"integrity": a.Integrity,
+	}
+}
+
+// Middleware returns middleware that stores the Assets in the context and
+// sets immutable cache headers on fingerprinted asset requests.
+func (a *Assets) Middleware() ginji.Middleware {
+	immutable := "public, max-age=" + strconv.FormatInt(int64(a.config.ImmutableMaxAge.Seconds()), 10) + ", immutable"
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if a.config.SkipFunc != nil && a.config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		c.Set(a.config.ContextKey, a)
+		setContextValue(c, assetsContextKey, a)
+
+		if a.fingerprinted(c) {
+			c.SetHeader("Cache-Control", immutable)
+		}
+		return c.Next()
+	}
+}
+
+// fingerprinted reports whether the request is for a fingerprinted URL of
+// a known asset.
+func (a *Assets) fingerprinted(c *ginji.Context) bool {
+	name, ok := strings.CutPrefix(c.Req.URL.Path, a.config.Prefix+"/")
+	if !ok {
+		return false
+	}
+	asset, ok := a.Get(name)
+	if !ok {
+		return false
+	}
+	if fingerprintPattern.MatchString(name) {
+		return true
+	}
+	return c.Query("v") == asset.Version
+}
+
+// hashFile computes the asset entry for name.
+func (a *Assets) hashFile(name string) (Asset, error) {
+	f, err := a.config.FS.Open(name)
+	if err != nil {
+		return Asset{}, err
+	}
+	defer func() { _ = f.Close() }()
+
+	h := newAssetHash(a.config.Algorithm)
+	if _, err := io.Copy(h, f); err != nil {
+		return Asset{}, err
+	}
+	sum := h.Sum(nil)
+
+	return Asset{
+		URL:       a.config.Prefix + "/" + name,
+		Integrity: a.config.Algorithm + "-" + base64.StdEncoding.EncodeToString(sum),
+		Version:   hex.EncodeToString(sum[:4]),
+	}, nil
+}
+
+// newAssetHash returns the hash for an SRI algorithm, or nil if unsupported.
+func newAssetHash(algorithm string) hash.Hash {
+	switch algorithm {
+	case "sha256":
+		return sha256.New()
+	case "sha384":
+		return sha512.New384()
+	case "sha512":
+		return sha512.New()
+	}
+	return nil
+}
+
+// AssetsFrom returns the Assets stored by the Assets middleware.
+func AssetsFrom(c *ginji.Context) (*Assets, bool) {
+	a, ok := c.Req.Context().Value(assetsContextKey).(*Assets)
+	return a, ok
+}
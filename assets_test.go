@@ -0,0 +1,147 @@
+package middleware
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/ginjigo/ginji"
+)
+
+func newTestAssets(t *testing.T) *Assets {
+	t.Helper()
+	assets, err := NewAssets(AssetsConfig{FS: fstest.MapFS{
+		"app.js":                {Data: []byte("console.log('app')")},
+		"css/site.3f9a2c1d.css": {Data: []byte("body{}")},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return assets
+}
+
+func TestAssetsIntegrity(t *testing.T) {
+	assets := newTestAssets(t)
+
+	sum := sha512.Sum384([]byte("console.log('app')"))
+	want := "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+	if got := assets.Integrity("app.js"); got != want {
+		t.Errorf("Expected integrity %q, got %q", want, got)
+	}
+	if got := assets.Integrity("/app.js"); got != want {
+		t.Errorf("Expected leading slash to be ignored, got %q", got)
+	}
+	if got := assets.Integrity("missing.js"); got != "" {
+		t.Errorf("Expected empty integrity for unknown asset, got %q", got)
+	}
+
+	asset, _ := assets.Get("app.js")
+	if got := assets.URL("app.js"); got != "/static/app.js?v="+asset.Version {
+		t.Errorf("Expected versioned URL, got %q", got)
+	}
+	if got := assets.URL("css/site.3f9a2c1d.css"); got != "/static/css/site.3f9a2c1d.css" {
+		t.Errorf("Expected fingerprinted name unchanged, got %q", got)
+	}
+}
+
+func TestAssetsMiddleware(t *testing.T) {
+	assets := newTestAssets(t)
+	asset, _ := assets.Get("app.js")
+
+	app := ginji.New()
+	app.Use(assets.Middleware())
+	app.Get("/static/*filepath", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "asset")
+	})
+	app.Get("/page", func(c *ginji.Context) error {
+		a, ok := AssetsFrom(c)
+		if !ok {
+			return c.Text(ginji.StatusInternalServerError, "no assets")
+		}
+		return c.Text(ginji.StatusOK, a.Integrity("app.js"))
+	})
+
+	tests := []struct {
+		path      string
+		immutable bool
+	}{
+		{"/static/css/site.3f9a2c1d.css", true},
+		{"/static/app.js?v=" + asset.Version, true},
+		{"/static/app.js?v=stale", false},
+		{"/static/app.js", false},
+	}
+	for _, tt := range tests {
+		w := ginji.PerformRequest(app, "GET", tt.path, nil)
+		got := strings.Contains(w.Header().Get("Cache-Control"), "immutable")
+		if got != tt.immutable {
+			t.Errorf("%s: expected immutable=%v, got Cache-Control %q", tt.path, tt.immutable, w.Header().Get("Cache-Control"))
+		}
+	}
+
+	w := ginji.PerformRequest(app, "GET", "/page", nil)
+	ginji.AssertBody(t, w, asset.Integrity)
+}
+
+func TestAssetsFuncMap(t *testing.T) {
+	assets := newTestAssets(t)
+
+	tmpl := template.Must(template.New("page").Funcs(assets.FuncMap()).Parse(
+		`<script src="{{asset "app.js"}}" integrity="{{integrity "app.js"}}"></script>`))
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(sb.String(), `integrity="sha384-`) || !strings.Contains(sb.String(), `/static/app.js?v=`) {
+		t.Errorf("Unexpected template output: %s", sb.String())
+	}
+}
+
+func TestNewAssetsErrors(t *testing.T) {
+	if _, err := NewAssets(AssetsConfig{}); err == nil {
+		t.Error("Expected error without FS")
+	}
+	if _, err := NewAssets(AssetsConfig{FS: fstest.MapFS{}, Algorithm: "md5"}); err == nil {
+		t.Error("Expected error for unsupported algorithm")
+	}
+}
+
+func TestAssetsRangeRequests(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "video.3f9a2c1d.mp4"), []byte("0123456789"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	assets, err := NewAssets(AssetsConfig{FS: os.DirFS(dir)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := ginji.New()
+	app.Use(assets.Middleware())
+	app.Static("/static", dir)
+
+	w := ginji.NewRequest(app, "GET", "/static/video.3f9a2c1d.mp4").Header("Range", "bytes=2-5").Do()
+	ginji.AssertStatus(t, w, ginji.StatusPartialContent)
+	ginji.AssertHeader(t, w, "Content-Range", "bytes 2-5/10")
+	if w.Body.String() != "2345" {
+		t.Errorf("Expected the requested range, got %q", w.Body.String())
+	}
+	if !strings.Contains(w.Header().Get("Cache-Control"), "immutable") {
+		t.Errorf("Expected partial responses to stay cacheable, got %q", w.Header().Get("Cache-Control"))
+	}
+
+	// A stale If-Range validator gets the whole file
+	w = ginji.NewRequest(app, "GET", "/static/video.3f9a2c1d.mp4").
+		Header("Range", "bytes=2-5").
+		Header("If-Range", `"stale"`).
+		Do()
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	if w.Body.String() != "0123456789" {
+		t.Errorf("Expected the full file, got %q", w.Body.String())
+	}
+}
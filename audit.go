@@ -0,0 +1,336 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// AuditEvent records a single sensitive operation.
+type AuditEvent struct {
+	Time       time.Time `json:"time"`
+	User       string    `json:"user,omitempty"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	ResourceID string    `json:"resource_id,omitempty"`
+	IP         string    `json:"ip"`
+	RequestID  string    `json:"request_id,omitempty"`
+	Status     int       `json:"status"`
+
+	// PrevHash and Hash form a tamper-evident chain when hashing is enabled.
+	PrevHash string `json:"prev_hash,omitempty"`
+	Hash     string `json:"hash,omitempty"`
+}
+
+// AuditSink persists audit events.
+type AuditSink interface {
+	WriteAudit(ctx context.Context, event AuditEvent) error
+}
+
+// AuditSinkFunc adapts a function to the AuditSink interface, e.g. for
+// writing events to a database.
+type AuditSinkFunc func(ctx context.Context, event AuditEvent) error
+
+// WriteAudit calls f(ctx, event).
+func (f AuditSinkFunc) WriteAudit(ctx context.Context, event AuditEvent) error {
+	return f(ctx, event)
+}
+
+// slogAuditSink writes audit events as structured log records.
+type slogAuditSink struct {
+	logger *slog.Logger
+}
+
+// NewSlogAuditSink returns a sink that logs audit events to logger.
+// If logger is nil, slog.Default() is used.
+func NewSlogAuditSink(logger *slog.Logger) AuditSink {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &slogAuditSink{logger: logger}
+}
+
+// WriteAudit logs the event.
+func (s *slogAuditSink) WriteAudit(ctx context.Context, e AuditEvent) error {
+	s.logger.LogAttrs(ctx, slog.LevelInfo, "Audit",
+		slog.Time("time", e.Time),
+		slog.String("user", e.User),
+		slog.String("method", e.Method),
+		slog.String("path", e.Path),
+		slog.String("resource_id", e.ResourceID),
+		slog.String("ip", e.IP),
+		slog.String("request_id", e.RequestID),
+		slog.Int("status", e.Status),
+		slog.String("hash", e.Hash),
+	)
+	return nil
+}
+
+// writerAuditSink writes audit events as JSON lines.
+type writerAuditSink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewWriterAuditSink returns a sink that appends audit events as JSON lines
+// to w, typically an append-only file or a RotatingFile.
+func NewWriterAuditSink(w io.Writer) AuditSink {
+	return &writerAuditSink{enc: json.NewEncoder(w)}
+}
+
+// WriteAudit encodes the event as a single JSON line.
+func (s *writerAuditSink) WriteAudit(_ context.Context, e AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(e)
+}
+
+// MemoryAuditStore is an AuditSink keeping the most recent events in
+// memory, for tests and single-instance deployments that ship audit events
+// elsewhere in batches. It implements UserDataStore.
+type MemoryAuditStore struct {
+	mu       sync.Mutex
+	capacity int
+	events   []AuditEvent
+}
+
+// NewMemoryAuditStore creates a store keeping the last capacity events.
+// Default capacity: 10000
+func NewMemoryAuditStore(capacity int) *MemoryAuditStore {
+	if capacity <= 0 {
+		capacity = 10000
+	}
+	return &MemoryAuditStore{capacity: capacity}
+}
+
+// WriteAudit stores the event, evicting the oldest one when full.
+func (s *MemoryAuditStore) WriteAudit(_ context.Context, e AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.events) == s.capacity {
+		s.events = slices.Delete(s.events, 0, 1)
+	}
+	s.events = append(s.events, e)
+	return nil
+}
+
+// Events returns the stored events, oldest first.
+func (s *MemoryAuditStore) Events() []AuditEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.events)
+}
+
+// ExportUser implements UserDataStore, returning the events of userID.
+func (s *MemoryAuditStore) ExportUser(_ context.Context, userID string) ([]any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var records []any
+	for _, e := range s.events {
+		if e.User == userID {
+			records = append(records, e)
+		}
+	}
+	return records, nil
+}
+
+// PurgeUser implements UserDataStore, deleting the events of userID. With
+// HashChain, the remaining events no longer pass VerifyAuditChain at the
+// purged positions; keep the audit record of the erasure request itself to
+// account for the gap.
+func (s *MemoryAuditStore) PurgeUser(_ context.Context, userID string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	before := len(s.events)
+	s.events = slices.DeleteFunc(s.events, func(e AuditEvent) bool { return e.User == userID })
+	return before - len(s.events), nil
+}
+
+// AuditConfig defines the configuration for audit logging middleware.
+type AuditConfig struct {
+	// Sink receives audit events.
+	// Default: NewSlogAuditSink(nil)
+	Sink AuditSink
+
+	// Match selects which requests are audited.
+	// Default: state-changing methods (POST, PUT, PATCH, DELETE)
+	Match func(*ginji.Context) bool
+
+	// UserFunc extracts the acting user from context.
+	// Default: the "user" context value set by the auth middleware
+	UserFunc func(*ginji.Context) string
+
+	// ResourceIDFunc extracts the affected resource ID.
+	// Default: the ":id" route parameter
+	ResourceIDFunc func(*ginji.Context) string
+
+	// HashChain links every event to the previous one with a SHA-256 hash,
+	// so deleted or altered records can be detected with VerifyAuditChain.
+	HashChain bool
+
+	// TrustedProxies is a list of trusted proxy IP addresses used when
+	// resolving the client IP.
+	TrustedProxies []string
+
+	// OnError is called when the sink fails. If nil, errors are ignored.
+	OnError func(error)
+
+	// Scrubber, if set, removes PII from events before they are hashed
+	// and written.
+	Scrubber *Scrubber
+
+	// SkipFunc allows skipping auditing for certain requests.
+	SkipFunc Skipper
+}
+
+// DefaultAuditConfig returns default audit configuration.
+func DefaultAuditConfig() AuditConfig {
+	return AuditConfig{
+		Sink:           NewSlogAuditSink(nil),
+		Match:          auditStateChanging,
+		UserFunc:       auditUser,
+		ResourceIDFunc: func(c *ginji.Context) string { return c.Param("id") },
+	}
+}
+
+// AuditLog returns audit logging middleware that writes to sink.
+func AuditLog(sink AuditSink) ginji.Middleware {
+	config := DefaultAuditConfig()
+	config.Sink = sink
+	return AuditLogWithConfig(config)
+}
+
+// AuditLogWithConfig returns audit logging middleware with custom configuration.
+func AuditLogWithConfig(config AuditConfig) ginji.Middleware {
+	defaults := DefaultAuditConfig()
+	if config.Sink == nil {
+		config.Sink = defaults.Sink
+	}
+	if config.Match == nil {
+		config.Match = defaults.Match
+	}
+	if config.UserFunc == nil {
+		config.UserFunc = defaults.UserFunc
+	}
+	if config.ResourceIDFunc == nil {
+		config.ResourceIDFunc = defaults.ResourceIDFunc
+	}
+
+	var (
+		mu       sync.Mutex
+		lastHash string
+	)
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		if !config.Match(c) {
+			return c.Next()
+		}
+
+		start := time.Now()
+		err := c.Next()
+
+		event := AuditEvent{
+			Time:       start.UTC(),
+			User:       config.UserFunc(c),
+			Method:     c.Req.Method,
+			Path:       c.Req.URL.Path,
+			ResourceID: config.ResourceIDFunc(c),
+			IP:         clientIP(c.Req, config.TrustedProxies),
+			RequestID:  GetRequestID(c),
+			Status:     c.StatusCode(),
+		}
+		if config.Scrubber != nil {
+			event = config.Scrubber.AuditEvent(event)
+		}
+
+		if config.HashChain {
+			// The chain must be extended and written in order
+			mu.Lock()
+			defer mu.Unlock()
+			event.PrevHash = lastHash
+			event.Hash = hashAuditEvent(event)
+		}
+
+		if sinkErr := config.Sink.WriteAudit(c.Req.Context(), event); sinkErr != nil {
+			if config.OnError != nil {
+				config.OnError(sinkErr)
+			}
+		} else if config.HashChain {
+			lastHash = event.Hash
+		}
+
+		return err
+	}
+}
+
+// auditStateChanging matches requests that modify state.
+func auditStateChanging(c *ginji.Context) bool {
+	switch c.Req.Method {
+	case "POST", "PUT", "PATCH", "DELETE":
+		return true
+	}
+	return false
+}
+
+// auditUser renders the authenticated user stored in context.
+func auditUser(c *ginji.Context) string {
+	user, exists := UserFrom(c)
+	if !exists {
+		return ""
+	}
+	switch u := user.(type) {
+	case string:
+		return u
+	case map[string]any:
+		for _, key := range []string{"id", "username", "email"} {
+			if v, ok := u[key]; ok {
+				return fmt.Sprint(v)
+			}
+		}
+	case fmt.Stringer:
+		return u.String()
+	}
+	return fmt.Sprint(user)
+}
+
+// hashAuditEvent computes the chained hash of an event.
+func hashAuditEvent(e AuditEvent) string {
+	e.Hash = ""
+	data, _ := json.Marshal(e)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyAuditChain checks that events form an unbroken hash chain.
+// It returns an error identifying the first event that was altered,
+// removed or reordered.
+func VerifyAuditChain(events []AuditEvent) error {
+	prev := ""
+	if len(events) > 0 {
+		prev = events[0].PrevHash
+	}
+	for i, e := range events {
+		if e.PrevHash != prev {
+			return fmt.Errorf("audit chain broken at event %d: previous hash mismatch", i)
+		}
+		if hashAuditEvent(e) != e.Hash {
+			return fmt.Errorf("audit chain broken at event %d: hash mismatch", i)
+		}
+		prev = e.Hash
+	}
+	return nil
+}
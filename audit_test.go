@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/ginjigo/ginji"
+)
+
+// memoryAuditSink collects audit events for tests.
+type memoryAuditSink struct {
+	events []AuditEvent
+}
+
+func (s *memoryAuditSink) WriteAudit(_ context.Context, e AuditEvent) error {
+	s.events = append(s.events, e)
+	return nil
+}
+
+func TestAuditLogRecordsStateChanges(t *testing.T) {
+	sink := &memoryAuditSink{}
+
+	app := ginji.New()
+	app.Use(RequestID())
+	app.Use(ginji.MockMiddleware("user", "alice"))
+	app.Use(AuditLog(sink))
+
+	app.Get("/orders/:id", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "order")
+	})
+	app.Delete("/orders/:id", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "deleted")
+	})
+
+	ginji.PerformRequest(app, "GET", "/orders/7", nil)
+	ginji.PerformRequest(app, "DELETE", "/orders/7", nil)
+
+	if len(sink.events) != 1 {
+		t.Fatalf("Expected 1 audit event, got %d", len(sink.events))
+	}
+
+	e := sink.events[0]
+	if e.User != "alice" || e.Method != "DELETE" || e.ResourceID != "7" || e.Status != ginji.StatusOK {
+		t.Errorf("Unexpected audit event: %+v", e)
+	}
+	if e.RequestID == "" || e.IP == "" {
+		t.Errorf("Expected request ID and IP to be recorded: %+v", e)
+	}
+}
+
+func TestAuditLogHashChain(t *testing.T) {
+	sink := &memoryAuditSink{}
+
+	app := ginji.New()
+	app.Use(AuditLogWithConfig(AuditConfig{
+		Sink:      sink,
+		HashChain: true,
+	}))
+
+	app.Post("/items", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusCreated, "created")
+	})
+
+	for i := 0; i < 3; i++ {
+		ginji.PerformRequest(app, "POST", "/items", nil)
+	}
+
+	if err := VerifyAuditChain(sink.events); err != nil {
+		t.Fatalf("Expected valid chain, got %v", err)
+	}
+
+	tampered := append([]AuditEvent(nil), sink.events...)
+	tampered[1].User = "mallory"
+	if err := VerifyAuditChain(tampered); err == nil {
+		t.Error("Expected tampered chain to fail verification")
+	}
+
+	removed := []AuditEvent{sink.events[0], sink.events[2]}
+	if err := VerifyAuditChain(removed); err == nil {
+		t.Error("Expected chain with removed event to fail verification")
+	}
+}
+
+func TestWriterAuditSink(t *testing.T) {
+	var buf bytes.Buffer
+
+	app := ginji.New()
+	app.Use(AuditLogWithConfig(AuditConfig{
+		Sink:  NewWriterAuditSink(&buf),
+		Match: func(c *ginji.Context) bool { return true },
+	}))
+
+	app.Get("/secret", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	ginji.PerformRequest(app, "GET", "/secret", nil)
+
+	var e AuditEvent
+	if err := json.Unmarshal(buf.Bytes(), &e); err != nil {
+		t.Fatalf("Expected JSON line, got %q: %v", buf.String(), err)
+	}
+	if e.Path != "/secret" {
+		t.Errorf("Expected path /secret, got %s", e.Path)
+	}
+}
+
+func TestMemoryAuditStore(t *testing.T) {
+	store := NewMemoryAuditStore(3)
+	ctx := context.Background()
+	for _, user := range []string{"alice", "bob", "alice", "alice"} {
+		_ = store.WriteAudit(ctx, AuditEvent{User: user})
+	}
+
+	events := store.Events()
+	if len(events) != 3 || events[0].User != "bob" {
+		t.Fatalf("Expected the oldest event to be evicted, got %+v", events)
+	}
+	if records, _ := store.ExportUser(ctx, "alice"); len(records) != 2 {
+		t.Errorf("Expected 2 exported events, got %d", len(records))
+	}
+	if n, _ := store.PurgeUser(ctx, "alice"); n != 2 {
+		t.Errorf("Expected 2 purged events, got %d", n)
+	}
+	if events := store.Events(); len(events) != 1 || events[0].User != "bob" {
+		t.Errorf("Expected only bob's event to remain, got %+v", events)
+	}
+}
@@ -24,7 +24,7 @@ type AuthConfig struct {
 	ContextKey string
 
 	// SkipFunc allows skipping authentication for certain requests.
-	SkipFunc func(*ginji.Context) bool
+	SkipFunc Skipper
 }
 
 // BasicAuthConfig defines configuration for Basic Authentication.
@@ -41,6 +41,9 @@ type BasicAuthConfig struct {
 
 	// ContextKey to store authenticated username.
 	ContextKey string
+
+	// SkipFunc allows skipping authentication for certain requests.
+	SkipFunc Skipper
 }
 
 // BearerAuthConfig defines configuration for Bearer token authentication.
@@ -53,6 +56,9 @@ type BearerAuthConfig struct {
 
 	// Realm for WWW-Authenticate header.
 	Realm string
+
+	// SkipFunc allows skipping authentication for certain requests.
+	SkipFunc Skipper
 }
 
 // APIKeyConfig defines configuration for API Key authentication.
@@ -68,6 +74,9 @@ type APIKeyConfig struct {
 
 	// ContextKey to store authenticated user.
 	ContextKey string
+
+	// SkipFunc allows skipping authentication for certain requests.
+	SkipFunc Skipper
 }
 
 // BasicAuth returns middleware for HTTP Basic Authentication.
@@ -89,6 +98,11 @@ func BasicAuthWithConfig(config BasicAuthConfig) ginji.Middleware {
 	}
 
 	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
 		auth := c.Header("Authorization")
 
 		if auth == "" {
@@ -134,6 +148,7 @@ func BasicAuthWithConfig(config BasicAuthConfig) ginji.Middleware {
 
 		// Store username in context
 		c.Set(config.ContextKey, username)
+		SetUser(c, username)
 		return c.Next()
 	}
 }
@@ -157,6 +172,11 @@ func BearerAuthWithConfig(config BearerAuthConfig) ginji.Middleware {
 	}
 
 	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
 		auth := c.Header("Authorization")
 
 		if auth == "" {
@@ -186,6 +206,7 @@ func BearerAuthWithConfig(config BearerAuthConfig) ginji.Middleware {
 
 		// Store user in context
 		c.Set(config.ContextKey, user)
+		SetUser(c, user)
 		return c.Next()
 	}
 }
@@ -206,6 +227,11 @@ func APIKeyWithConfig(config APIKeyConfig) ginji.Middleware {
 	}
 
 	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
 		var apiKey string
 
 		// Try header first
@@ -236,6 +262,7 @@ func APIKeyWithConfig(config APIKeyConfig) ginji.Middleware {
 
 		// Store user in context
 		c.Set(config.ContextKey, user)
+		SetUser(c, user)
 		return c.Next()
 	}
 }
@@ -260,7 +287,7 @@ func unauthorizedBearer(c *ginji.Context, realm string) {
 // Expects user to be a map[string]any with a "role" or "roles" field.
 func RequireRole(role string) ginji.Middleware {
 	return func(c *ginji.Context) error {
-		user, exists := c.Get("user")
+		user, exists := UserFrom(c)
 		if !exists {
 			c.AbortWithStatusJSON(ginji.StatusForbidden, ginji.H{
 				"error": "Access denied",
@@ -268,26 +295,7 @@ func RequireRole(role string) ginji.Middleware {
 			return nil
 		}
 
-		// Check if user has the required role
-		hasRole := false
-		if userMap, ok := user.(map[string]any); ok {
-			// Check single role field
-			if userRole, ok := userMap["role"].(string); ok && userRole == role {
-				hasRole = true
-			}
-
-			// Check roles array
-			if roles, ok := userMap["roles"].([]string); ok {
-				for _, r := range roles {
-					if r == role {
-						hasRole = true
-						break
-					}
-				}
-			}
-		}
-
-		if !hasRole {
+		if !userHasRole(user, role) {
 			c.AbortWithStatusJSON(ginji.StatusForbidden, ginji.H{
 				"error": "Insufficient permissions",
 			})
@@ -297,3 +305,27 @@ func RequireRole(role string) ginji.Middleware {
 		return c.Next()
 	}
 }
+
+// userHasRole reports whether user, a map[string]any, has role in its
+// "role" or "roles" field.
+func userHasRole(user any, role string) bool {
+	userMap, ok := user.(map[string]any)
+	if !ok {
+		return false
+	}
+
+	// Check single role field
+	if userRole, ok := userMap["role"].(string); ok && userRole == role {
+		return true
+	}
+
+	// Check roles array
+	if roles, ok := userMap["roles"].([]string); ok {
+		for _, r := range roles {
+			if r == role {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,174 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// AuthorizeConfig defines the configuration for policy-based authorization.
+type AuthorizeConfig struct {
+	// Engine decides the requests. Required.
+	Engine PolicyEngine
+
+	// SubjectFunc returns the subject of the request.
+	// Default: the authenticated user's ID (see UserFrom), or the "sub"
+	// claim
+	SubjectFunc func(*ginji.Context) string
+
+	// ObjectFunc returns the object of the request. Where the router's
+	// pattern cannot be derived from the path, RoutePattern reports the
+	// raw path, so record it with SetRoutePattern on such routes.
+	// Default: the route pattern (see RoutePattern)
+	ObjectFunc func(*ginji.Context) string
+
+	// ActionFunc returns the action of the request.
+	// Default: the HTTP method
+	ActionFunc func(*ginji.Context) string
+
+	// AttributesFunc returns additional policy input. Attributes are not
+	// part of the cache key, so disable caching when decisions depend on
+	// them.
+	// Default: nil
+	AttributesFunc func(*ginji.Context) map[string]any
+
+	// CacheTTL caches decisions per subject, object and action for this
+	// long. Errors are never cached.
+	// Default: 0 (disabled)
+	CacheTTL time.Duration
+
+	// CacheSize is the maximum number of cached decisions.
+	// Default: 10000
+	CacheSize int
+
+	// OnDecision is called with every decision, cached or not, e.g. to
+	// audit denials. Err is the engine error, if any.
+	OnDecision func(c *ginji.Context, req PolicyRequest, allowed bool, err error)
+
+	// StatusCode is the status sent when access is denied.
+	// Default: 403
+	StatusCode int
+
+	// ErrorMessage is the error sent when access is denied.
+	// Default: "Access denied"
+	ErrorMessage string
+
+	// SkipFunc allows skipping authorization for certain requests.
+	SkipFunc Skipper
+}
+
+// DefaultAuthorizeConfig returns default authorization configuration.
+func DefaultAuthorizeConfig() AuthorizeConfig {
+	return AuthorizeConfig{
+		SubjectFunc:  defaultPolicySubject,
+		ObjectFunc:   RoutePattern,
+		ActionFunc:   func(c *ginji.Context) string { return c.Req.Method },
+		CacheSize:    10000,
+		StatusCode:   ginji.StatusForbidden,
+		ErrorMessage: "Access denied",
+	}
+}
+
+// Authorize returns middleware that asks engine whether the user may
+// perform the request.
+func Authorize(engine PolicyEngine) ginji.Middleware {
+	config := DefaultAuthorizeConfig()
+	config.Engine = engine
+	return AuthorizeWithConfig(config)
+}
+
+// AuthorizeWithConfig returns middleware that asks the policy engine
+// whether the subject may perform the action on the object. Engine errors
+// deny the request with 503. It panics if Engine is nil.
+func AuthorizeWithConfig(config AuthorizeConfig) ginji.Middleware {
+	defaults := DefaultAuthorizeConfig()
+	if config.Engine == nil {
+		panic("Authorize: Engine is required")
+	}
+	if config.SubjectFunc == nil {
+		config.SubjectFunc = defaults.SubjectFunc
+	}
+	if config.ObjectFunc == nil {
+		config.ObjectFunc = defaults.ObjectFunc
+	}
+	if config.ActionFunc == nil {
+		config.ActionFunc = defaults.ActionFunc
+	}
+	if config.CacheSize <= 0 {
+		config.CacheSize = defaults.CacheSize
+	}
+	if config.StatusCode == 0 {
+		config.StatusCode = defaults.StatusCode
+	}
+	if config.ErrorMessage == "" {
+		config.ErrorMessage = defaults.ErrorMessage
+	}
+
+	var cache *lruCache[string, bool]
+	if config.CacheTTL > 0 {
+		cache = newLRUCache[string, bool](config.CacheSize, config.CacheTTL)
+	}
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		req := PolicyRequest{
+			Subject: config.SubjectFunc(c),
+			Object:  config.ObjectFunc(c),
+			Action:  config.ActionFunc(c),
+		}
+		if config.AttributesFunc != nil {
+			req.Attributes = config.AttributesFunc(c)
+		}
+
+		key := req.Subject + "\x00" + req.Object + "\x00" + req.Action
+		allowed, cached := false, false
+		if cache != nil {
+			allowed, cached = cache.Get(key)
+		}
+
+		var err error
+		if !cached {
+			allowed, err = config.Engine.Authorize(c.Req.Context(), req)
+			if err == nil && cache != nil {
+				cache.Add(key, allowed)
+			}
+		}
+
+		if config.OnDecision != nil {
+			config.OnDecision(c, req, allowed && err == nil, err)
+		}
+
+		if err != nil {
+			c.AbortWithStatusJSON(ginji.StatusServiceUnavailable, ginji.H{
+				"error": "Authorization unavailable",
+			})
+			return nil
+		}
+		if !allowed {
+			c.AbortWithStatusJSON(config.StatusCode, ginji.H{
+				"error": config.ErrorMessage,
+			})
+			return nil
+		}
+
+		return c.Next()
+	}
+}
+
+// defaultPolicySubject returns the authenticated user's ID, or the "sub"
+// claim of a token.
+func defaultPolicySubject(c *ginji.Context) string {
+	if subject := auditUser(c); subject != "" {
+		return subject
+	}
+	if claims, ok := ClaimsFrom(c); ok {
+		if sub, ok := claims["sub"].(string); ok {
+			return sub
+		}
+	}
+	return ""
+}
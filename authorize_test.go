@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+func TestAuthorize(t *testing.T) {
+	engine := NewCasbinEngine(fakeEnforcer{{"alice", "/reports", "GET"}: true})
+	app := ginji.New()
+	app.Use(func(c *ginji.Context) error {
+		SetUser(c, map[string]any{"id": "alice"})
+		return c.Next()
+	})
+	app.Use(Authorize(engine))
+	app.Get("/reports", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "reports")
+	})
+	app.Delete("/reports", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "deleted")
+	})
+
+	ginji.AssertStatus(t, ginji.PerformRequest(app, "GET", "/reports", nil), ginji.StatusOK)
+
+	w := ginji.PerformRequest(app, "DELETE", "/reports", nil)
+	ginji.AssertStatus(t, w, ginji.StatusForbidden)
+	ginji.AssertBody(t, w, "Access denied")
+}
+
+func TestAuthorizeSubjectFromClaims(t *testing.T) {
+	var subject string
+	app := ginji.New()
+	app.Use(func(c *ginji.Context) error {
+		SetClaims(c, map[string]any{"sub": "service-42"})
+		return c.Next()
+	})
+	app.Use(Authorize(PolicyEngineFunc(func(ctx context.Context, req PolicyRequest) (bool, error) {
+		subject = req.Subject
+		return true, nil
+	})))
+	app.Get("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	ginji.AssertStatus(t, ginji.PerformRequest(app, "GET", "/", nil), ginji.StatusOK)
+	if subject != "service-42" {
+		t.Errorf("Expected subject from sub claim, got %q", subject)
+	}
+}
+
+func TestAuthorizeCache(t *testing.T) {
+	var calls atomic.Int32
+	config := DefaultAuthorizeConfig()
+	config.CacheTTL = time.Minute
+	config.Engine = PolicyEngineFunc(func(ctx context.Context, req PolicyRequest) (bool, error) {
+		calls.Add(1)
+		return req.Action == "GET", nil
+	})
+	app := ginji.New()
+	app.Use(func(c *ginji.Context) error {
+		SetUser(c, map[string]any{"id": "alice"})
+		return c.Next()
+	})
+	app.Use(AuthorizeWithConfig(config))
+	app.Get("/reports", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "reports")
+	})
+	app.Delete("/reports", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "deleted")
+	})
+
+	for i := 0; i < 3; i++ {
+		ginji.AssertStatus(t, ginji.PerformRequest(app, "GET", "/reports", nil), ginji.StatusOK)
+		ginji.AssertStatus(t, ginji.PerformRequest(app, "DELETE", "/reports", nil), ginji.StatusForbidden)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("Expected 2 engine calls with caching, got %d", n)
+	}
+}
+
+func TestAuthorizeEngineError(t *testing.T) {
+	var decisions []error
+	config := DefaultAuthorizeConfig()
+	config.CacheTTL = time.Minute
+	config.Engine = PolicyEngineFunc(func(ctx context.Context, req PolicyRequest) (bool, error) {
+		return true, errors.New("policy server down")
+	})
+	config.OnDecision = func(c *ginji.Context, req PolicyRequest, allowed bool, err error) {
+		if allowed {
+			t.Error("Expected failed decision not to be allowed")
+		}
+		decisions = append(decisions, err)
+	}
+	app := ginji.New()
+	app.Use(func(c *ginji.Context) error {
+		SetUser(c, map[string]any{"id": "alice"})
+		return c.Next()
+	})
+	app.Use(AuthorizeWithConfig(config))
+	app.Get("/reports", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "reports")
+	})
+
+	ginji.AssertStatus(t, ginji.PerformRequest(app, "GET", "/reports", nil), ginji.StatusServiceUnavailable)
+	ginji.AssertStatus(t, ginji.PerformRequest(app, "GET", "/reports", nil), ginji.StatusServiceUnavailable)
+	if len(decisions) != 2 || decisions[0] == nil || decisions[1] == nil {
+		t.Errorf("Expected errors to be reported and not cached, got %v", decisions)
+	}
+}
+
+func TestAuthorizeOnDecision(t *testing.T) {
+	var denied []PolicyRequest
+	config := DefaultAuthorizeConfig()
+	config.Engine = NewCasbinEngine(fakeEnforcer{})
+	config.AttributesFunc = func(c *ginji.Context) map[string]any {
+		return map[string]any{"ip": c.Req.RemoteAddr}
+	}
+	config.OnDecision = func(c *ginji.Context, req PolicyRequest, allowed bool, err error) {
+		if !allowed {
+			denied = append(denied, req)
+		}
+	}
+	app := ginji.New()
+	app.Use(func(c *ginji.Context) error {
+		SetUser(c, map[string]any{"id": "alice"})
+		return c.Next()
+	})
+	app.Use(AuthorizeWithConfig(config))
+	app.Get("/reports", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "reports")
+	})
+	app.Delete("/reports", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "deleted")
+	})
+
+	ginji.AssertStatus(t, ginji.PerformRequest(app, "DELETE", "/reports", nil), ginji.StatusForbidden)
+	if len(denied) != 1 || denied[0].Subject != "alice" || denied[0].Action != "DELETE" || denied[0].Attributes["ip"] == nil {
+		t.Errorf("Expected denial to be reported, got %+v", denied)
+	}
+}
+
+func TestAuthorizeRequiresEngine(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic without Engine")
+		}
+	}()
+	AuthorizeWithConfig(AuthorizeConfig{})
+}
@@ -1,13 +1,38 @@
 package middleware
 
 import (
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/ginjigo/ginji"
 )
 
+// ErrDecompressedBodyTooLarge is returned when reading a compressed request
+// body that inflates beyond BodyLimitConfig.MaxDecompressedBytes.
+var ErrDecompressedBodyTooLarge = errors.New("decompressed request body too large")
+
+// BodyLimitAction is what BodyLimit does with the rest of a request body
+// after rejecting it.
+type BodyLimitAction int
+
+const (
+	// BodyLimitDrain reads and discards up to DrainLimit bytes of the body
+	// so the connection can be reused.
+	BodyLimitDrain BodyLimitAction = iota
+
+	// BodyLimitClose closes the connection without reading the body,
+	// which protects bandwidth but makes the client reconnect.
+	BodyLimitClose
+)
+
 // BodyLimitConfig defines the configuration for body limit middleware.
 type BodyLimitConfig struct {
 	// MaxBytes is the maximum allowed size of the request body in bytes.
@@ -20,14 +45,64 @@ type BodyLimitConfig struct {
 	// StatusCode is the HTTP status code to return when limit is exceeded.
 	// Defaults to 413 (Request Entity Too Large).
 	StatusCode int
+
+	// MaxDecompressedBytes enables decompression of gzip and deflate
+	// encoded bodies and caps their decompressed size, so small compressed
+	// payloads cannot inflate into zip bombs. Handlers read the plain body
+	// and get ErrDecompressedBodyTooLarge past the cap; if they then do
+	// not respond, the request is rejected with StatusCode.
+	// Default: 0 (compressed bodies are passed through)
+	MaxDecompressedBytes int64
+
+	// QuotaBytes is the cumulative number of body bytes a client may upload
+	// per QuotaWindow.
+	// Default: 0 (no quota)
+	QuotaBytes int64
+
+	// QuotaWindow is the period over which QuotaBytes applies.
+	// Default: 1 hour
+	QuotaWindow time.Duration
+
+	// QuotaKeyFunc identifies the client a quota applies to. Requests for
+	// which it returns "" are not counted.
+	// Default: ByIP(), the client IP without the port
+	QuotaKeyFunc func(*ginji.Context) string
+
+	// QuotaStore keeps the quota counters. Pass the rate limiter's Limiter
+	// to share its buckets and key cap. If nil, a private one is created.
+	QuotaStore *Limiter
+
+	// QuotaStatusCode is the HTTP status code returned when a quota is used up.
+	// Default: 429 (Too Many Requests)
+	QuotaStatusCode int
+
+	// OnViolation selects whether the rest of a rejected body is drained
+	// or the connection closed.
+	// Default: BodyLimitDrain
+	OnViolation BodyLimitAction
+
+	// DrainLimit caps the bytes drained after a violation; larger bodies
+	// close the connection.
+	// Default: 1 MB
+	DrainLimit int64
+
+	// Metrics records rejected requests as body_limit_rejected_total,
+	// labelled by reason ("size", "decompressed" or "quota"). Optional.
+	Metrics *Metrics
+
+	// SkipFunc allows skipping the limit for certain requests.
+	SkipFunc Skipper
 }
 
 // DefaultBodyLimitConfig returns a default configuration with 4MB limit.
 func DefaultBodyLimitConfig() BodyLimitConfig {
 	return BodyLimitConfig{
-		MaxBytes:     4 << 20, // 4 MB
-		ErrorMessage: "",
-		StatusCode:   http.StatusRequestEntityTooLarge,
+		MaxBytes:        4 << 20, // 4 MB
+		ErrorMessage:    "",
+		StatusCode:      http.StatusRequestEntityTooLarge,
+		QuotaWindow:     time.Hour,
+		QuotaStatusCode: http.StatusTooManyRequests,
+		DrainLimit:      1 << 20, // 1 MB
 	}
 }
 
@@ -43,6 +118,39 @@ func BodyLimit(maxBytes int64) ginji.Middleware {
 
 // BodyLimitWithConfig returns a middleware with custom configuration.
 func BodyLimitWithConfig(config BodyLimitConfig) ginji.Middleware {
+	config = normalizeBodyLimitConfig(config)
+	if config.QuotaBytes > 0 && config.QuotaStore == nil {
+		config.QuotaStore = NewLimiter(RateLimiterConfig{Window: config.QuotaWindow})
+	}
+	describeBodyLimitMetrics(config.Metrics)
+	return func(c *ginji.Context) error {
+		return limitBody(c, config)
+	}
+}
+
+// BodyLimitDynamic returns a middleware that reads its configuration from
+// configFunc on every request, so the limit can be changed at runtime.
+// configFunc must be safe for concurrent use; a Reloader's Load method is.
+func BodyLimitDynamic(configFunc func() BodyLimitConfig) ginji.Middleware {
+	var (
+		storeOnce sync.Once
+		store     *Limiter
+	)
+	return func(c *ginji.Context) error {
+		config := normalizeBodyLimitConfig(configFunc())
+		if config.QuotaBytes > 0 && config.QuotaStore == nil {
+			storeOnce.Do(func() {
+				store = NewLimiter(RateLimiterConfig{Window: config.QuotaWindow})
+			})
+			config.QuotaStore = store
+		}
+		describeBodyLimitMetrics(config.Metrics)
+		return limitBody(c, config)
+	}
+}
+
+// normalizeBodyLimitConfig fills in defaults for unset fields.
+func normalizeBodyLimitConfig(config BodyLimitConfig) BodyLimitConfig {
 	// Set defaults
 	if config.MaxBytes <= 0 {
 		config.MaxBytes = DefaultBodyLimitConfig().MaxBytes
@@ -54,53 +162,252 @@ func BodyLimitWithConfig(config BodyLimitConfig) ginji.Middleware {
 		config.ErrorMessage = fmt.Sprintf("Request body too large. Maximum allowed size is %d bytes", config.MaxBytes)
 	}
 
-	return func(c *ginji.Context) error {
-		// Check Content-Length header first (if present)
-		if c.Req.ContentLength > config.MaxBytes {
-			c.AbortWithStatusJSON(config.StatusCode, ginji.H{
-				"error":    config.ErrorMessage,
-				"maxBytes": config.MaxBytes,
-				"received": c.Req.ContentLength,
+	defaults := DefaultBodyLimitConfig()
+	if config.QuotaWindow <= 0 {
+		config.QuotaWindow = defaults.QuotaWindow
+	}
+	if config.QuotaKeyFunc == nil {
+		config.QuotaKeyFunc = ByIP()
+	}
+	if config.QuotaStatusCode == 0 {
+		config.QuotaStatusCode = defaults.QuotaStatusCode
+	}
+	if config.DrainLimit <= 0 {
+		config.DrainLimit = defaults.DrainLimit
+	}
+	return config
+}
+
+// describeBodyLimitMetrics registers the BodyLimit metrics.
+func describeBodyLimitMetrics(m *Metrics) {
+	if m != nil {
+		m.Describe("body_limit_rejected_total", MetricCounter, "Requests rejected by BodyLimit, by reason.")
+	}
+}
+
+// limitBody enforces config on the request body and continues the chain.
+func limitBody(c *ginji.Context, config BodyLimitConfig) error {
+	// Skip if skip function returns true
+	if config.SkipFunc != nil && config.SkipFunc(c) {
+		return c.Next()
+	}
+
+	// Check Content-Length header first (if present)
+	if c.Req.ContentLength > config.MaxBytes {
+		rejectBody(c, &config, "size")
+		c.AbortWithStatusJSON(config.StatusCode, ginji.H{
+			"error":    config.ErrorMessage,
+			"maxBytes": config.MaxBytes,
+			"received": c.Req.ContentLength,
+		})
+		return nil
+	}
+
+	// Charge the quota up front when the size is known
+	quotaKey := ""
+	if config.QuotaBytes > 0 {
+		quotaKey = config.QuotaKeyFunc(c)
+	}
+	if quotaKey != "" && c.Req.ContentLength > 0 {
+		if ok, remaining, reset := takeBodyQuota(&config, quotaKey, c.Req.ContentLength); !ok {
+			rejectBody(c, &config, "quota")
+			c.SetHeader("Retry-After", strconv.FormatInt(secondsUntil(reset), 10))
+			c.AbortWithStatusJSON(config.QuotaStatusCode, ginji.H{
+				"error":      "Upload quota exceeded",
+				"quotaBytes": config.QuotaBytes,
+				"remaining":  remaining,
 			})
 			return nil
 		}
+		quotaKey = ""
+	}
+
+	if c.Req.Body == nil {
+		return c.Next()
+	}
+
+	// Wrap the request body with a limited reader
+	body := &limitedReadCloser{
+		ReadCloser: c.Req.Body,
+		limit:      config.MaxBytes,
+		read:       0,
+		config:     &config,
+		context:    c,
+		quotaKey:   quotaKey,
+	}
+	c.Req.Body = body
+
+	var inflated *decompressedReadCloser
+	if config.MaxDecompressedBytes > 0 {
+		var err error
+		if inflated, err = decompressBody(c, body, &config); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, ginji.H{"error": "Invalid compressed request body"})
+			return nil
+		}
+	}
 
-		// Wrap the request body with a limited reader
-		if c.Req.Body != nil {
-			c.Req.Body = &limitedReadCloser{
-				ReadCloser: c.Req.Body,
-				limit:      config.MaxBytes,
-				read:       0,
-				config:     &config,
-				context:    c,
+	rw := WrapResponseWriter(c)
+	if config.OnViolation == BodyLimitClose {
+		rw.Before(func(h http.Header) {
+			if body.violation != "" || inflated != nil && inflated.exceeded {
+				h.Set("Connection", "close")
 			}
+		})
+	}
+
+	err := c.Next()
+
+	if inflated != nil && inflated.exceeded && !rw.Written() {
+		c.AbortWithStatusJSON(config.StatusCode, ginji.H{
+			"error":    fmt.Sprintf("Decompressed request body too large. Maximum allowed size is %d bytes", config.MaxDecompressedBytes),
+			"maxBytes": config.MaxDecompressedBytes,
+		})
+		err = nil
+	}
+	if (body.violation != "" || inflated != nil && inflated.exceeded) && config.OnViolation == BodyLimitDrain {
+		_, _ = io.CopyN(io.Discard, body.ReadCloser, config.DrainLimit)
+	}
+	return err
+}
+
+// decompressBody replaces a gzip or deflate encoded request body with its
+// decompressed form, capped at MaxDecompressedBytes. Bodies with other
+// encodings are left alone.
+func decompressBody(c *ginji.Context, body io.ReadCloser, config *BodyLimitConfig) (*decompressedReadCloser, error) {
+	var (
+		r   io.Reader
+		err error
+	)
+	switch strings.ToLower(strings.TrimSpace(c.Req.Header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+		r, err = gzip.NewReader(body)
+	case "deflate":
+		r, err = zlib.NewReader(body)
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	inflated := &decompressedReadCloser{r: r, body: body, limit: config.MaxDecompressedBytes, config: config}
+	c.Req.Body = inflated
+	c.Req.Header.Del("Content-Encoding")
+	c.Req.Header.Del("Content-Length")
+	c.Req.ContentLength = -1
+	return inflated, nil
+}
+
+// decompressedReadCloser counts decompressed bytes and enforces a limit.
+type decompressedReadCloser struct {
+	r        io.Reader
+	body     io.Closer
+	limit    int64
+	read     int64
+	exceeded bool
+	config   *BodyLimitConfig
+}
+
+// Read reads decompressed data while enforcing the limit.
+func (d *decompressedReadCloser) Read(p []byte) (int, error) {
+	if d.exceeded {
+		return 0, ErrDecompressedBodyTooLarge
+	}
+
+	// Never read more than one byte past the limit
+	if remaining := d.limit - d.read + 1; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := d.r.Read(p)
+	d.read += int64(n)
+	if d.read > d.limit {
+		d.exceeded = true
+		if d.config.Metrics != nil {
+			d.config.Metrics.Inc("body_limit_rejected_total", "reason", "decompressed")
 		}
+		return n - int(d.read-d.limit), ErrDecompressedBodyTooLarge
+	}
+	return n, err
+}
 
-		return c.Next()
+// Close closes the compressed body.
+func (d *decompressedReadCloser) Close() error {
+	return d.body.Close()
+}
+
+// rejectBody records a rejection and prepares the connection for the
+// response according to OnViolation.
+func rejectBody(c *ginji.Context, config *BodyLimitConfig, reason string) {
+	if config.Metrics != nil {
+		config.Metrics.Inc("body_limit_rejected_total", "reason", reason)
+	}
+	if c.Req.Body == nil || c.Req.Body == http.NoBody {
+		return
 	}
+
+	if config.OnViolation == BodyLimitDrain && c.Req.ContentLength >= 0 && c.Req.ContentLength <= config.DrainLimit {
+		_, _ = io.Copy(io.Discard, c.Req.Body)
+		return
+	}
+	c.SetHeader("Connection", "close")
+}
+
+// takeBodyQuota charges n bytes to the quota for key.
+func takeBodyQuota(config *BodyLimitConfig, key string, n int64) (bool, int, time.Time) {
+	limit := RouteLimit{Max: int(config.QuotaBytes), Window: config.QuotaWindow}
+	return config.QuotaStore.Take("bodyquota|"+key, limit, int(n))
 }
 
 // limitedReadCloser wraps an io.ReadCloser and enforces a size limit.
 type limitedReadCloser struct {
 	io.ReadCloser
-	limit   int64
-	read    int64
-	config  *BodyLimitConfig
-	context *ginji.Context
+	limit     int64
+	read      int64
+	config    *BodyLimitConfig
+	context   *ginji.Context
+	quotaKey  string // charged as the body is read when its size is unknown
+	violation string
 }
 
 // Read reads from the underlying reader while enforcing the limit.
 func (l *limitedReadCloser) Read(p []byte) (n int, err error) {
+	if l.violation != "" {
+		return 0, l.violationError()
+	}
+
 	n, err = l.ReadCloser.Read(p)
 	l.read += int64(n)
 
 	if l.read > l.limit {
-		return n, fmt.Errorf("request body size exceeds limit of %d bytes", l.limit)
+		l.violate("size")
+		return n, l.violationError()
+	}
+	if l.quotaKey != "" && n > 0 {
+		if ok, _, _ := takeBodyQuota(l.config, l.quotaKey, int64(n)); !ok {
+			l.violate("quota")
+			return n, l.violationError()
+		}
 	}
 
 	return n, err
 }
 
+// violate records the first violation.
+func (l *limitedReadCloser) violate(reason string) {
+	l.violation = reason
+	if l.config.Metrics != nil {
+		l.config.Metrics.Inc("body_limit_rejected_total", "reason", reason)
+	}
+}
+
+// violationError returns the error reported to the handler.
+func (l *limitedReadCloser) violationError() error {
+	if l.violation == "quota" {
+		return fmt.Errorf("request body exceeds upload quota of %d bytes", l.config.QuotaBytes)
+	}
+	return fmt.Errorf("request body size exceeds limit of %d bytes", l.limit)
+}
+
 // Helper functions for common size limits
 
 // BodyLimit1MB returns middleware with 1MB limit.
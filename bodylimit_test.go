@@ -2,7 +2,11 @@ package middleware
 
 import (
 	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 
@@ -142,3 +146,195 @@ func TestDefaultBodyLimitConfig(t *testing.T) {
 		t.Errorf("Expected default status code 413, got %d", config.StatusCode)
 	}
 }
+
+func TestBodyLimitDynamic(t *testing.T) {
+	limits := NewReloader(BodyLimitConfig{MaxBytes: 100})
+
+	app := ginji.New()
+	app.Use(BodyLimitDynamic(limits.Load))
+	app.Post("/upload", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	body := strings.Repeat("x", 50)
+	w := ginji.PerformRequest(app, "POST", "/upload", strings.NewReader(body))
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+
+	limits.Store(BodyLimitConfig{MaxBytes: 10})
+	w = ginji.PerformRequest(app, "POST", "/upload", strings.NewReader(body))
+	ginji.AssertStatus(t, w, http.StatusRequestEntityTooLarge)
+}
+
+func TestBodyLimitQuota(t *testing.T) {
+	metrics := NewMetrics(DefaultMetricsConfig())
+
+	app := ginji.New()
+	app.Use(BodyLimitWithConfig(BodyLimitConfig{
+		MaxBytes:   100,
+		QuotaBytes: 150,
+		Metrics:    metrics,
+	}))
+	app.Post("/upload", func(c *ginji.Context) error {
+		if _, err := io.ReadAll(c.Req.Body); err != nil {
+			return c.Text(http.StatusRequestEntityTooLarge, err.Error())
+		}
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	body := strings.Repeat("x", 60)
+	for range 2 {
+		w := ginji.PerformRequest(app, "POST", "/upload", strings.NewReader(body))
+		ginji.AssertStatus(t, w, ginji.StatusOK)
+	}
+
+	// The quota follows the client IP across connections
+	req := httptest.NewRequest("POST", "/upload", strings.NewReader(body))
+	req.RemoteAddr = "192.0.2.1:5678"
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	ginji.AssertStatus(t, w, http.StatusTooManyRequests)
+	ginji.AssertBody(t, w, "Upload quota exceeded")
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("Expected Retry-After header")
+	}
+
+	if got := metrics.Value("body_limit_rejected_total", "reason", "quota"); got != 1 {
+		t.Errorf("Expected 1 quota rejection, got %v", got)
+	}
+}
+
+func TestBodyLimitQuotaUnknownLength(t *testing.T) {
+	store := NewLimiter(DefaultRateLimiterConfig())
+	defer func() { _ = store.Close() }()
+
+	app := ginji.New()
+	app.Use(BodyLimitWithConfig(BodyLimitConfig{
+		MaxBytes:   100,
+		QuotaBytes: 50,
+		QuotaStore: store,
+	}))
+	app.Post("/upload", func(c *ginji.Context) error {
+		if _, err := io.ReadAll(c.Req.Body); err != nil {
+			return c.Text(http.StatusRequestEntityTooLarge, err.Error())
+		}
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	req := httptest.NewRequest("POST", "/upload", strings.NewReader(strings.Repeat("x", 80)))
+	req.ContentLength = -1
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+
+	ginji.AssertStatus(t, w, http.StatusRequestEntityTooLarge)
+	ginji.AssertBody(t, w, "upload quota of 50 bytes")
+}
+
+func TestBodyLimitOnViolation(t *testing.T) {
+	tests := []struct {
+		name       string
+		action     BodyLimitAction
+		drainLimit int64
+		wantClose  bool
+	}{
+		{"drain", BodyLimitDrain, 0, false},
+		{"drain over limit", BodyLimitDrain, 10, true},
+		{"close", BodyLimitClose, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics := NewMetrics(DefaultMetricsConfig())
+
+			app := ginji.New()
+			app.Use(BodyLimitWithConfig(BodyLimitConfig{
+				MaxBytes:    20,
+				OnViolation: tt.action,
+				DrainLimit:  tt.drainLimit,
+				Metrics:     metrics,
+			}))
+			app.Post("/upload", func(c *ginji.Context) error {
+				return c.Text(ginji.StatusOK, "ok")
+			})
+
+			w := ginji.PerformRequest(app, "POST", "/upload", strings.NewReader(strings.Repeat("x", 100)))
+			ginji.AssertStatus(t, w, http.StatusRequestEntityTooLarge)
+
+			if got := w.Header().Get("Connection") == "close"; got != tt.wantClose {
+				t.Errorf("Expected Connection: close to be %v", tt.wantClose)
+			}
+			if got := metrics.Value("body_limit_rejected_total", "reason", "size"); got != 1 {
+				t.Errorf("Expected 1 size rejection, got %v", got)
+			}
+		})
+	}
+}
+
+// gzipBody returns s gzip-compressed.
+func gzipBody(t *testing.T, s string) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestBodyLimitDecompressed(t *testing.T) {
+	metrics := NewMetrics(DefaultMetricsConfig())
+
+	app := ginji.New()
+	app.Use(BodyLimitWithConfig(BodyLimitConfig{
+		MaxBytes:             4 << 10,
+		MaxDecompressedBytes: 1 << 16,
+		Metrics:              metrics,
+	}))
+	app.Post("/upload", func(c *ginji.Context) error {
+		data, err := io.ReadAll(c.Req.Body)
+		if err != nil {
+			return err
+		}
+		return c.Text(ginji.StatusOK, fmt.Sprintf("%d %q", len(data), c.Header("Content-Encoding")))
+	})
+
+	// A small body is inflated for the handler
+	req := httptest.NewRequest("POST", "/upload", gzipBody(t, strings.Repeat("a", 1000)))
+	req.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	ginji.AssertBody(t, w, `1000 ""`)
+
+	// A couple of compressed kilobytes inflating to 1 MB are rejected
+	bomb := gzipBody(t, strings.Repeat("a", 1<<20))
+	if bomb.Len() > 4<<10 {
+		t.Fatalf("Expected the compressed bomb to fit MaxBytes, got %d bytes", bomb.Len())
+	}
+	req = httptest.NewRequest("POST", "/upload", bomb)
+	req.Header.Set("Content-Encoding", "gzip")
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	ginji.AssertStatus(t, w, http.StatusRequestEntityTooLarge)
+	ginji.AssertBody(t, w, "Decompressed request body too large")
+
+	if got := metrics.Value("body_limit_rejected_total", "reason", "decompressed"); got != 1 {
+		t.Errorf("Expected 1 decompressed rejection, got %v", got)
+	}
+}
+
+func TestBodyLimitDecompressedInvalid(t *testing.T) {
+	app := ginji.New()
+	app.Use(BodyLimitWithConfig(BodyLimitConfig{MaxDecompressedBytes: 100}))
+	app.Post("/upload", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	req := httptest.NewRequest("POST", "/upload", strings.NewReader("not gzip"))
+	req.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	ginji.AssertStatus(t, w, http.StatusBadRequest)
+}
@@ -0,0 +1,340 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// BotAction is the action taken for a request based on its bot score.
+type BotAction int
+
+const (
+	// BotActionAllow lets the request through.
+	BotActionAllow BotAction = iota
+	// BotActionTarpit delays the request before letting it through.
+	BotActionTarpit
+	// BotActionChallenge asks the client to prove it is human.
+	BotActionChallenge
+	// BotActionBlock rejects the request.
+	BotActionBlock
+)
+
+// String returns the name of the action.
+func (a BotAction) String() string {
+	switch a {
+	case BotActionTarpit:
+		return "tarpit"
+	case BotActionChallenge:
+		return "challenge"
+	case BotActionBlock:
+		return "block"
+	default:
+		return "allow"
+	}
+}
+
+// BotDetector inspects a request and returns a score contribution together
+// with a short reason. A zero score means the detector found nothing.
+type BotDetector func(*ginji.Context) (score int, reason string)
+
+// BotScoreBand maps a minimum score to an action.
+type BotScoreBand struct {
+	// MinScore is the lowest total score this band applies to.
+	MinScore int
+
+	// Action is taken when the score falls into this band.
+	Action BotAction
+}
+
+// BotResult is stored in context with the outcome of the bot checks.
+type BotResult struct {
+	Score   int
+	Reasons []string
+	Action  BotAction
+}
+
+// BotGuardConfig defines the configuration for bot detection middleware.
+type BotGuardConfig struct {
+	// Detectors are custom detectors run in addition to the built-in ones.
+	Detectors []BotDetector
+
+	// DisableBuiltinDetectors turns off the User-Agent and header fingerprint checks.
+	DisableBuiltinDetectors bool
+
+	// HoneypotFields are hidden form fields that humans leave empty.
+	HoneypotFields []string
+
+	// TimingField is a form field holding the time at which the form was
+	// rendered, as returned by BotFormTimestamp. Forms submitted faster
+	// than MinSubmitTime or later than MaxSubmitAge, and forms without a
+	// timestamp signed with TimingKey, are scored as bots.
+	TimingField string
+
+	// TimingKey is the HMAC-SHA256 key signing the TimingField
+	// timestamps. It is required with TimingField.
+	TimingKey []byte
+
+	// MinSubmitTime is the minimum plausible time for a human to fill a form.
+	// Default: 2 seconds
+	MinSubmitTime time.Duration
+
+	// MaxSubmitAge is the maximum age of a form timestamp, so that a
+	// timestamp cannot be replayed indefinitely.
+	// Default: 24 hours
+	MaxSubmitAge time.Duration
+
+	// Bands maps score ranges to actions. The band with the highest
+	// MinScore not exceeding the request score wins.
+	// Default: 30 tarpit, 50 challenge, 80 block
+	Bands []BotScoreBand
+
+	// TarpitDelay is how long tarpitted requests are held.
+	// Default: 3 seconds
+	TarpitDelay time.Duration
+
+	// ChallengeHandler is called for requests in the challenge band.
+	// If nil, a default 403 response with "challenge": true is sent.
+	ChallengeHandler func(*ginji.Context)
+
+	// BlockStatusCode is the status code for blocked requests.
+	// Default: 403 Forbidden
+	BlockStatusCode int
+
+	// ContextKey is the key used to store the BotResult in context.
+	// Default: "bot"
+	ContextKey string
+
+	// SkipFunc allows skipping bot detection for certain requests.
+	SkipFunc Skipper
+}
+
+// DefaultBotGuardConfig returns default bot guard configuration.
+func DefaultBotGuardConfig() BotGuardConfig {
+	return BotGuardConfig{
+		MinSubmitTime: 2 * time.Second,
+		MaxSubmitAge:  24 * time.Hour,
+		Bands: []BotScoreBand{
+			{MinScore: 30, Action: BotActionTarpit},
+			{MinScore: 50, Action: BotActionChallenge},
+			{MinScore: 80, Action: BotActionBlock},
+		},
+		TarpitDelay:     3 * time.Second,
+		BlockStatusCode: http.StatusForbidden,
+		ContextKey:      "bot",
+	}
+}
+
+// BotGuard returns bot detection middleware with default configuration.
+func BotGuard() ginji.Middleware {
+	return BotGuardWithConfig(DefaultBotGuardConfig())
+}
+
+// BotGuardWithConfig returns bot detection middleware with custom configuration.
+func BotGuardWithConfig(config BotGuardConfig) ginji.Middleware {
+	defaults := DefaultBotGuardConfig()
+	if config.MinSubmitTime <= 0 {
+		config.MinSubmitTime = defaults.MinSubmitTime
+	}
+	if config.MaxSubmitAge <= 0 {
+		config.MaxSubmitAge = defaults.MaxSubmitAge
+	}
+	if len(config.Bands) == 0 {
+		config.Bands = defaults.Bands
+	}
+	if config.TarpitDelay <= 0 {
+		config.TarpitDelay = defaults.TarpitDelay
+	}
+	if config.BlockStatusCode == 0 {
+		config.BlockStatusCode = defaults.BlockStatusCode
+	}
+	if config.ContextKey == "" {
+		config.ContextKey = defaults.ContextKey
+	}
+	if config.TimingField != "" && len(config.TimingKey) == 0 {
+		panic("BotGuard: TimingKey is required to check TimingField")
+	}
+
+	// Sort bands so the highest threshold is checked first
+	bands := make([]BotScoreBand, len(config.Bands))
+	copy(bands, config.Bands)
+	sort.Slice(bands, func(i, j int) bool {
+		return bands[i].MinScore > bands[j].MinScore
+	})
+
+	var detectors []BotDetector
+	if !config.DisableBuiltinDetectors {
+		detectors = append(detectors, detectUserAgent, detectMissingHeaders)
+	}
+	if len(config.HoneypotFields) > 0 {
+		detectors = append(detectors, honeypotDetector(config.HoneypotFields))
+	}
+	if config.TimingField != "" {
+		detectors = append(detectors, timingDetector(config.TimingField, config.TimingKey, config.MinSubmitTime, config.MaxSubmitAge))
+	}
+	detectors = append(detectors, config.Detectors...)
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		result := BotResult{Action: BotActionAllow}
+		for _, detect := range detectors {
+			if score, reason := detect(c); score != 0 {
+				result.Score += score
+				result.Reasons = append(result.Reasons, reason)
+			}
+		}
+
+		for _, band := range bands {
+			if result.Score >= band.MinScore {
+				result.Action = band.Action
+				break
+			}
+		}
+
+		c.Set(config.ContextKey, result)
+		setContextValue(c, botResultContextKey, result)
+
+		switch result.Action {
+		case BotActionTarpit:
+			select {
+			case <-time.After(config.TarpitDelay):
+			case <-c.Req.Context().Done():
+				c.Abort()
+				return nil
+			}
+		case BotActionChallenge:
+			if config.ChallengeHandler != nil {
+				config.ChallengeHandler(c)
+			} else {
+				c.AbortWithStatusJSON(ginji.StatusForbidden, ginji.H{
+					"error":     "Verification required",
+					"challenge": true,
+				})
+			}
+			return nil
+		case BotActionBlock:
+			c.AbortWithStatusJSON(config.BlockStatusCode, ginji.H{
+				"error": "Access denied",
+			})
+			return nil
+		}
+
+		return c.Next()
+	}
+}
+
+// botUserAgentMarkers are substrings found in automated clients.
+var botUserAgentMarkers = []string{
+	"bot", "crawler", "spider", "scrapy", "curl", "wget", "python-requests",
+	"python-urllib", "go-http-client", "java/", "libwww", "httpclient", "okhttp",
+}
+
+// detectUserAgent scores missing, tool-like and headless User-Agents.
+func detectUserAgent(c *ginji.Context) (int, string) {
+	ua := strings.ToLower(c.Header("User-Agent"))
+	if ua == "" {
+		return 40, "missing user agent"
+	}
+	if strings.Contains(ua, "headless") || strings.Contains(ua, "phantomjs") {
+		return 50, "headless browser"
+	}
+	for _, marker := range botUserAgentMarkers {
+		if strings.Contains(ua, marker) {
+			return 30, "automated user agent"
+		}
+	}
+	return 0, ""
+}
+
+// detectMissingHeaders scores requests lacking headers every browser sends.
+func detectMissingHeaders(c *ginji.Context) (int, string) {
+	score := 0
+	for _, header := range []string{"Accept", "Accept-Language", "Accept-Encoding"} {
+		if c.Header(header) == "" {
+			score += 10
+		}
+	}
+	if score == 0 {
+		return 0, ""
+	}
+	return score, "missing browser headers"
+}
+
+// honeypotDetector flags form submissions that fill in hidden fields.
+func honeypotDetector(fields []string) BotDetector {
+	return func(c *ginji.Context) (int, string) {
+		if !isFormRequest(c) {
+			return 0, ""
+		}
+		for _, field := range fields {
+			if c.Req.PostFormValue(field) != "" {
+				return 100, "honeypot field filled"
+			}
+		}
+		return 0, ""
+	}
+}
+
+// timingDetector flags forms submitted faster than a human could fill
+// them, and forms whose timestamp is missing, forged or expired.
+func timingDetector(field string, key []byte, minimum, maximum time.Duration) BotDetector {
+	return func(c *ginji.Context) (int, string) {
+		if !isFormRequest(c) {
+			return 0, ""
+		}
+		value := c.Req.PostFormValue(field)
+		unix, signature, ok := strings.Cut(value, ".")
+		if !ok || !hmac.Equal([]byte(signature), []byte(signFormTimestamp(key, unix))) {
+			return 30, "invalid form timestamp"
+		}
+		rendered, err := strconv.ParseInt(unix, 10, 64)
+		if err != nil {
+			return 30, "invalid form timestamp"
+		}
+		age := time.Since(time.Unix(rendered, 0))
+		if age < minimum {
+			return 50, "form submitted too quickly"
+		}
+		if age > maximum {
+			return 30, "form timestamp expired"
+		}
+		return 0, ""
+	}
+}
+
+// BotFormTimestamp returns the value of BotGuardConfig.TimingField for a
+// form rendered at t, signed with key so that clients cannot backdate it:
+//
+//	data["RenderedAt"] = middleware.BotFormTimestamp(timingKey, time.Now())
+//
+// and in the template:
+//
+//	<input type="hidden" name="rendered_at" value="{{.RenderedAt}}">
+func BotFormTimestamp(key []byte, t time.Time) string {
+	unix := strconv.FormatInt(t.Unix(), 10)
+	return unix + "." + signFormTimestamp(key, unix)
+}
+
+// signFormTimestamp computes the signature of a form timestamp.
+func signFormTimestamp(key []byte, unix string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(unix))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// GetBotResult is a helper to get the bot detection result from context.
+func GetBotResult(c *ginji.Context) (BotResult, bool) {
+	result, ok := c.Req.Context().Value(botResultContextKey).(BotResult)
+	return result, ok
+}
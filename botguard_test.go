@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// setBrowserHeaders sets the headers a typical desktop browser sends.
+func setBrowserHeaders(h http.Header) {
+	h.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) Firefox/120.0")
+	h.Set("Accept", "text/html")
+	h.Set("Accept-Language", "en-US")
+	h.Set("Accept-Encoding", "gzip")
+}
+
+func TestBotGuardAllowsBrowser(t *testing.T) {
+	app := ginji.New()
+	app.Use(BotGuard())
+
+	app.Get("/test", func(c *ginji.Context) error {
+		result, _ := GetBotResult(c)
+		return c.Text(ginji.StatusOK, result.Action.String())
+	})
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	setBrowserHeaders(req.Header)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+
+	if w.Code != ginji.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	ginji.AssertBody(t, w, "allow")
+}
+
+func TestBotGuardBlocksHeadless(t *testing.T) {
+	app := ginji.New()
+	app.Use(BotGuard())
+
+	app.Get("/test", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	// Headless UA (50) plus three missing headers (30) lands in the block band
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0 HeadlessChrome/120.0")
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+
+	if w.Code != ginji.StatusForbidden {
+		t.Errorf("Expected status 403, got %d", w.Code)
+	}
+}
+
+func TestBotGuardHoneypot(t *testing.T) {
+	app := ginji.New()
+	app.Use(BotGuardWithConfig(BotGuardConfig{
+		HoneypotFields: []string{"website"},
+	}))
+
+	app.Post("/signup", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	form := url.Values{"email": {"a@example.com"}, "website": {"http://spam"}}
+	req := httptest.NewRequest("POST", "/signup", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	setBrowserHeaders(req.Header)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+
+	if w.Code != ginji.StatusForbidden {
+		t.Errorf("Expected status 403 for filled honeypot, got %d", w.Code)
+	}
+}
+
+func TestBotGuardTimingChallenge(t *testing.T) {
+	key := []byte("timing-key")
+	app := ginji.New()
+	app.Use(BotGuardWithConfig(BotGuardConfig{
+		TimingField:   "rendered_at",
+		TimingKey:     key,
+		MinSubmitTime: time.Minute,
+		MaxSubmitAge:  time.Hour,
+		Bands:         []BotScoreBand{{MinScore: 30, Action: BotActionChallenge}},
+	}))
+
+	app.Post("/comment", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	tests := []struct {
+		name, renderedAt string
+		want             int
+	}{
+		{"too quick", BotFormTimestamp(key, time.Now()), ginji.StatusForbidden},
+		{"old enough", BotFormTimestamp(key, time.Now().Add(-2*time.Minute)), ginji.StatusOK},
+		{"unsigned", strconv.FormatInt(time.Now().Add(-2*time.Minute).Unix(), 10), ginji.StatusForbidden},
+		{"other key", BotFormTimestamp([]byte("other"), time.Now().Add(-2*time.Minute)), ginji.StatusForbidden},
+		{"expired", BotFormTimestamp(key, time.Now().Add(-2*time.Hour)), ginji.StatusForbidden},
+		{"missing", "", ginji.StatusForbidden},
+	}
+	for _, tt := range tests {
+		form := url.Values{"rendered_at": {tt.renderedAt}}
+		req := httptest.NewRequest("POST", "/comment", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		setBrowserHeaders(req.Header)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+
+		if w.Code != tt.want {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.want, w.Code)
+		}
+	}
+}
+
+func TestBotGuardTimingRequiresKey(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic for TimingField without TimingKey")
+		}
+	}()
+	BotGuardWithConfig(BotGuardConfig{TimingField: "rendered_at"})
+}
+
+func TestBotGuardCustomDetectorTarpit(t *testing.T) {
+	app := ginji.New()
+	app.Use(BotGuardWithConfig(BotGuardConfig{
+		DisableBuiltinDetectors: true,
+		TarpitDelay:             20 * time.Millisecond,
+		Detectors: []BotDetector{
+			func(c *ginji.Context) (int, string) {
+				if c.Header("X-Suspicious") != "" {
+					return 35, "suspicious header"
+				}
+				return 0, ""
+			},
+		},
+	}))
+
+	app.Get("/test", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.Header.Set("X-Suspicious", "1")
+	w := httptest.NewRecorder()
+
+	start := time.Now()
+	app.ServeHTTP(w, req)
+
+	if w.Code != ginji.StatusOK {
+		t.Errorf("Expected tarpitted request to succeed, got %d", w.Code)
+	}
+	if time.Since(start) < 20*time.Millisecond {
+		t.Error("Expected tarpitted request to be delayed")
+	}
+}
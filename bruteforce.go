@@ -0,0 +1,300 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// BruteForceConfig defines the configuration for the login brute force guard.
+type BruteForceConfig struct {
+	// MaxUserFailures locks a username after this many consecutive failures.
+	// Default: 5
+	MaxUserFailures int
+
+	// MaxIPFailures locks a client IP after this many failures, across all
+	// usernames.
+	// Default: 20
+	MaxIPFailures int
+
+	// BackoffAfter is the number of failures allowed before each further
+	// attempt must wait. The wait starts at BaseDelay and doubles with every
+	// failure up to MaxDelay.
+	// Default: 3
+	BackoffAfter int
+
+	// BaseDelay is the first backoff delay.
+	// Default: 1 second
+	BaseDelay time.Duration
+
+	// MaxDelay caps the backoff delay.
+	// Default: 1 minute
+	MaxDelay time.Duration
+
+	// LockoutDuration is how long a username or IP stays locked.
+	// Default: 15 minutes
+	LockoutDuration time.Duration
+
+	// ResetAfter forgets failures after this long without a new one.
+	// Default: 1 hour
+	ResetAfter time.Duration
+
+	// KeyFunc returns the client IP. If it returns an empty string, the
+	// remote address is used.
+	// Default: the remote address without port
+	KeyFunc func(*ginji.Context) string
+
+	// UsernameFunc returns the username of the login attempt, or "" if there
+	// is none.
+	// Default: the Basic Auth username, or the "username" form field
+	UsernameFunc func(*ginji.Context) string
+
+	// FailureFunc reports whether the attempt failed, after the handler ran.
+	// Default: the response status is 401, which BasicAuth, SessionAuth and
+	// the other authentication middleware send
+	FailureFunc func(*ginji.Context) bool
+
+	// Store keeps the failure records. Share a store between instances to
+	// count failures across them.
+	// Default: in-memory, holding up to 100000 keys
+	Store BruteForceStore
+
+	// OnLockout is called when a username or IP gets locked. Kind is "user"
+	// or "ip" and key the username or IP.
+	OnLockout func(c *ginji.Context, kind, key string, record BruteForceRecord)
+
+	// StatusCode is the status sent while locked or backing off.
+	// Default: 429
+	StatusCode int
+
+	// ErrorMessage is the error sent while locked or backing off.
+	// Default: "Too many failed login attempts"
+	ErrorMessage string
+
+	// SkipFunc allows skipping the guard for certain requests.
+	SkipFunc Skipper
+}
+
+// BruteForceRecord is the failure history of a username or IP.
+type BruteForceRecord struct {
+	Failures    int       `json:"failures"`
+	LastFailure time.Time `json:"last_failure"`
+	LockedUntil time.Time `json:"locked_until,omitzero"`
+}
+
+// BruteForceStore keeps brute force records. Implementations must be safe
+// for concurrent use; updates are read-modify-write, so concurrent failures
+// for the same key may occasionally be counted once.
+type BruteForceStore interface {
+	// Get returns the record stored under key.
+	Get(key string) (BruteForceRecord, bool)
+
+	// Set stores the record under key.
+	Set(key string, record BruteForceRecord)
+
+	// Delete removes the record under key.
+	Delete(key string)
+}
+
+// DefaultBruteForceConfig returns default brute force guard configuration.
+func DefaultBruteForceConfig() BruteForceConfig {
+	return BruteForceConfig{
+		MaxUserFailures: 5,
+		MaxIPFailures:   20,
+		BackoffAfter:    3,
+		BaseDelay:       time.Second,
+		MaxDelay:        time.Minute,
+		LockoutDuration: 15 * time.Minute,
+		ResetAfter:      time.Hour,
+		StatusCode:      ginji.StatusTooManyRequests,
+		ErrorMessage:    "Too many failed login attempts",
+	}
+}
+
+// memoryBruteForceStore is the default in-memory BruteForceStore.
+type memoryBruteForceStore struct {
+	records *lruCache[string, BruteForceRecord]
+}
+
+// NewMemoryBruteForceStore creates an in-memory store holding at most
+// maxKeys records, each kept for ttl after its last update.
+func NewMemoryBruteForceStore(maxKeys int, ttl time.Duration) BruteForceStore {
+	return &memoryBruteForceStore{records: newLRUCache[string, BruteForceRecord](maxKeys, ttl)}
+}
+
+func (s *memoryBruteForceStore) Get(key string) (BruteForceRecord, bool) {
+	return s.records.Get(key)
+}
+
+func (s *memoryBruteForceStore) Set(key string, record BruteForceRecord) {
+	s.records.Add(key, record)
+}
+
+func (s *memoryBruteForceStore) Delete(key string) {
+	s.records.Remove(key)
+}
+
+// BruteForce returns a brute force guard with default configuration.
+func BruteForce() ginji.Middleware {
+	return BruteForceWithConfig(DefaultBruteForceConfig())
+}
+
+// BruteForceWithConfig returns middleware that slows down and locks out
+// repeated failed logins per username and per client IP. Put it in front of
+// the authentication middleware or on the login route group:
+//
+//	auth := app.Group("/auth")
+//	auth.Use(middleware.BruteForce())
+//	auth.Post("/login", login)
+//
+// A successful login resets the username's failures but not the IP's, so a
+// single valid account cannot be used to keep guessing others.
+func BruteForceWithConfig(config BruteForceConfig) ginji.Middleware {
+	defaults := DefaultBruteForceConfig()
+	if config.MaxUserFailures <= 0 {
+		config.MaxUserFailures = defaults.MaxUserFailures
+	}
+	if config.MaxIPFailures <= 0 {
+		config.MaxIPFailures = defaults.MaxIPFailures
+	}
+	if config.BackoffAfter <= 0 {
+		config.BackoffAfter = defaults.BackoffAfter
+	}
+	if config.BaseDelay <= 0 {
+		config.BaseDelay = defaults.BaseDelay
+	}
+	if config.MaxDelay <= 0 {
+		config.MaxDelay = defaults.MaxDelay
+	}
+	if config.LockoutDuration <= 0 {
+		config.LockoutDuration = defaults.LockoutDuration
+	}
+	if config.ResetAfter <= 0 {
+		config.ResetAfter = defaults.ResetAfter
+	}
+	if config.KeyFunc == nil {
+		config.KeyFunc = func(c *ginji.Context) string {
+			return clientIP(c.Req, nil)
+		}
+	}
+	if config.UsernameFunc == nil {
+		config.UsernameFunc = defaultLoginUsername
+	}
+	if config.FailureFunc == nil {
+		config.FailureFunc = func(c *ginji.Context) bool {
+			return c.StatusCode() == http.StatusUnauthorized
+		}
+	}
+	if config.Store == nil {
+		config.Store = NewMemoryBruteForceStore(100000, max(config.ResetAfter, config.LockoutDuration))
+	}
+	if config.StatusCode == 0 {
+		config.StatusCode = defaults.StatusCode
+	}
+	if config.ErrorMessage == "" {
+		config.ErrorMessage = defaults.ErrorMessage
+	}
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		now := time.Now()
+		guards := []bruteForceGuard{{kind: "ip", key: keyOrIP(c, config.KeyFunc(c), nil), max: config.MaxIPFailures}}
+		if username := strings.ToLower(strings.TrimSpace(config.UsernameFunc(c))); username != "" {
+			guards = append(guards, bruteForceGuard{kind: "user", key: username, max: config.MaxUserFailures})
+		}
+
+		// Reject while any key is locked or backing off
+		var retryAt time.Time
+		for i := range guards {
+			g := &guards[i]
+			g.record, _ = config.Store.Get(g.storeKey())
+			if now.Sub(g.record.LastFailure) > config.ResetAfter && now.After(g.record.LockedUntil) {
+				g.record = BruteForceRecord{}
+			}
+			if until := bruteForceBlockedUntil(config, g.record); until.After(now) && until.After(retryAt) {
+				retryAt = until
+			}
+		}
+		if !retryAt.IsZero() {
+			c.SetHeader("Retry-After", strconv.FormatInt(secondsUntil(retryAt), 10))
+			c.AbortWithStatusJSON(config.StatusCode, ginji.H{
+				"error":   config.ErrorMessage,
+				"retryAt": retryAt.Format(time.RFC3339),
+			})
+			return nil
+		}
+
+		err := c.Next()
+
+		if config.FailureFunc(c) {
+			for _, g := range guards {
+				record := g.record
+				record.Failures++
+				record.LastFailure = now
+				if record.Failures >= g.max && !record.LockedUntil.After(now) {
+					record.LockedUntil = now.Add(config.LockoutDuration)
+					if config.OnLockout != nil {
+						config.OnLockout(c, g.kind, g.key, record)
+					}
+				}
+				config.Store.Set(g.storeKey(), record)
+			}
+		} else if c.StatusCode() < 400 {
+			for _, g := range guards {
+				if g.kind == "user" && g.record.Failures > 0 {
+					config.Store.Delete(g.storeKey())
+				}
+			}
+		}
+		return err
+	}
+}
+
+// bruteForceGuard is one key checked by the guard.
+type bruteForceGuard struct {
+	kind   string
+	key    string
+	max    int
+	record BruteForceRecord
+}
+
+// storeKey returns the key of the guard's record in the store.
+func (g bruteForceGuard) storeKey() string {
+	return "bruteforce|" + g.kind + "|" + g.key
+}
+
+// bruteForceBlockedUntil returns when the next attempt is allowed for record.
+func bruteForceBlockedUntil(config BruteForceConfig, record BruteForceRecord) time.Time {
+	until := record.LockedUntil
+	if excess := record.Failures - config.BackoffAfter; excess >= 0 {
+		delay := config.BaseDelay
+		for i := 0; i < excess && delay < config.MaxDelay; i++ {
+			delay *= 2
+		}
+		if backoff := record.LastFailure.Add(min(delay, config.MaxDelay)); backoff.After(until) {
+			until = backoff
+		}
+	}
+	return until
+}
+
+// defaultLoginUsername returns the Basic Auth username, or the "username"
+// field of a form body.
+func defaultLoginUsername(c *ginji.Context) string {
+	if username, _, ok := c.Req.BasicAuth(); ok {
+		return username
+	}
+	contentType := c.Header("Content-Type")
+	if strings.HasPrefix(contentType, "application/x-www-form-urlencoded") ||
+		strings.HasPrefix(contentType, "multipart/form-data") {
+		return c.Req.PostFormValue("username")
+	}
+	return ""
+}
@@ -0,0 +1,156 @@
+package middleware
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+func basicLogin(app *ginji.Engine, user, password string) *httptest.ResponseRecorder {
+	auth := base64.StdEncoding.EncodeToString([]byte(user + ":" + password))
+	return ginji.NewRequest(app, "GET", "/login").Header("Authorization", "Basic "+auth).Do()
+}
+
+func TestBruteForceUserLockout(t *testing.T) {
+	var locked []string
+	app := ginji.New()
+	app.Use(BruteForceWithConfig(BruteForceConfig{
+		MaxUserFailures: 3,
+		BackoffAfter:    100,
+		OnLockout: func(c *ginji.Context, kind, key string, record BruteForceRecord) {
+			locked = append(locked, fmt.Sprintf("%s:%s:%d", kind, key, record.Failures))
+		},
+	}))
+	app.Use(BasicAuth(map[string]string{"admin": "secret", "bob": "hunter2"}))
+	app.Get("/login", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "welcome")
+	})
+
+	for i := 0; i < 3; i++ {
+		ginji.AssertStatus(t, basicLogin(app, "admin", "wrong"), ginji.StatusUnauthorized)
+	}
+
+	// Even the right password is refused while locked
+	w := basicLogin(app, "Admin", "secret")
+	ginji.AssertStatus(t, w, ginji.StatusTooManyRequests)
+	ginji.AssertBody(t, w, "Too many failed login attempts")
+	if retry := w.Header().Get("Retry-After"); retry == "" || retry == "0" {
+		t.Errorf("Expected Retry-After, got %q", retry)
+	}
+	if len(locked) != 1 || locked[0] != "user:admin:3" {
+		t.Errorf("Expected one user lockout, got %v", locked)
+	}
+
+	// Other users from the same IP are unaffected
+	ginji.AssertStatus(t, basicLogin(app, "bob", "hunter2"), ginji.StatusOK)
+}
+
+func TestBruteForceIPLockout(t *testing.T) {
+	app := ginji.New()
+	app.Use(BruteForceWithConfig(BruteForceConfig{
+		MaxIPFailures: 4,
+		BackoffAfter:  100,
+	}))
+	app.Use(BasicAuth(map[string]string{"admin": "secret", "bob": "hunter2"}))
+	app.Get("/login", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "welcome")
+	})
+
+	for i := 0; i < 4; i++ {
+		ginji.AssertStatus(t, basicLogin(app, fmt.Sprintf("user%d", i), "guess"), ginji.StatusUnauthorized)
+	}
+	ginji.AssertStatus(t, basicLogin(app, "bob", "hunter2"), ginji.StatusTooManyRequests)
+}
+
+func TestBruteForceBackoff(t *testing.T) {
+	app := ginji.New()
+	app.Use(BruteForceWithConfig(BruteForceConfig{
+		BackoffAfter: 1,
+		BaseDelay:    50 * time.Millisecond,
+	}))
+	app.Use(BasicAuth(map[string]string{"admin": "secret", "bob": "hunter2"}))
+	app.Get("/login", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "welcome")
+	})
+
+	ginji.AssertStatus(t, basicLogin(app, "admin", "wrong"), ginji.StatusUnauthorized)
+	ginji.AssertStatus(t, basicLogin(app, "admin", "secret"), ginji.StatusTooManyRequests)
+
+	time.Sleep(60 * time.Millisecond)
+	ginji.AssertStatus(t, basicLogin(app, "admin", "secret"), ginji.StatusOK)
+}
+
+func TestBruteForceBackoffDoubles(t *testing.T) {
+	config := DefaultBruteForceConfig()
+	now := time.Now()
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{2, 0},
+		{3, time.Second},
+		{4, 2 * time.Second},
+		{5, 4 * time.Second},
+		{20, time.Minute},
+		{1000, time.Minute},
+	}
+	for _, tt := range tests {
+		until := bruteForceBlockedUntil(config, BruteForceRecord{Failures: tt.failures, LastFailure: now})
+		var got time.Duration
+		if !until.IsZero() {
+			got = until.Sub(now)
+		}
+		if got != tt.want {
+			t.Errorf("%d failures: expected %v backoff, got %v", tt.failures, tt.want, got)
+		}
+	}
+}
+
+func TestBruteForceSuccessResetsUser(t *testing.T) {
+	store := NewMemoryBruteForceStore(100, time.Hour)
+	app := ginji.New()
+	app.Use(BruteForceWithConfig(BruteForceConfig{BackoffAfter: 100, Store: store}))
+	app.Use(BasicAuth(map[string]string{"admin": "secret", "bob": "hunter2"}))
+	app.Get("/login", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "welcome")
+	})
+
+	ginji.AssertStatus(t, basicLogin(app, "admin", "wrong"), ginji.StatusUnauthorized)
+	ginji.AssertStatus(t, basicLogin(app, "admin", "wrong"), ginji.StatusUnauthorized)
+	ginji.AssertStatus(t, basicLogin(app, "admin", "secret"), ginji.StatusOK)
+
+	if _, ok := store.Get("bruteforce|user|admin"); ok {
+		t.Error("Expected successful login to reset the username")
+	}
+	if record, ok := store.Get("bruteforce|ip|192.0.2.1"); !ok || record.Failures != 2 {
+		t.Errorf("Expected IP failures to be kept, got %+v", record)
+	}
+}
+
+func TestBruteForceFormUsername(t *testing.T) {
+	app := ginji.New()
+	app.Use(BruteForceWithConfig(BruteForceConfig{MaxUserFailures: 2, BackoffAfter: 100}))
+	app.Post("/login", func(c *ginji.Context) error {
+		if c.Req.PostFormValue("password") != "secret" {
+			return c.JSON(ginji.StatusUnauthorized, ginji.H{"error": "Invalid credentials"})
+		}
+		return c.Text(ginji.StatusOK, "welcome")
+	})
+
+	login := func(password string) *httptest.ResponseRecorder {
+		return ginji.NewRequest(app, "POST", "/login").
+			Header("Content-Type", "application/x-www-form-urlencoded").
+			Body(strings.NewReader("username=alice&password=" + password)).
+			Do()
+	}
+
+	ginji.AssertStatus(t, login("a"), ginji.StatusUnauthorized)
+	ginji.AssertStatus(t, login("b"), ginji.StatusUnauthorized)
+	ginji.AssertStatus(t, login("secret"), ginji.StatusTooManyRequests)
+}
@@ -0,0 +1,201 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// BudgetConfig defines the configuration for latency budget propagation.
+type BudgetConfig struct {
+	// Header carries the remaining budget in milliseconds between
+	// services. A relative budget is used rather than a timestamp so that
+	// clock skew between hosts does not matter.
+	// Default: "X-Request-Budget"
+	Header string
+
+	// TrustInbound shortens the deadline to the budget of the caller when
+	// the request carries Header. Enable it for internal services only, as
+	// any client can send the header.
+	// Default: false
+	TrustInbound bool
+
+	// Default is the budget of requests without a deadline, e.g. when
+	// Timeout is not used.
+	// Default: 0 (no deadline)
+	Default time.Duration
+
+	// Reserve is kept back from the budget given to handlers, so that a
+	// response can still be written after downstream calls time out.
+	// Default: 0
+	Reserve time.Duration
+
+	// StatusCode is sent when a request arrives with its budget spent.
+	// Default: 504
+	StatusCode int
+
+	// SkipFunc allows skipping budget propagation for certain requests.
+	SkipFunc Skipper
+}
+
+// DefaultBudgetConfig returns default budget configuration.
+func DefaultBudgetConfig() BudgetConfig {
+	return BudgetConfig{
+		Header:     "X-Request-Budget",
+		StatusCode: ginji.StatusGatewayTimeout,
+	}
+}
+
+// Budget returns middleware that propagates the request deadline.
+func Budget() ginji.Middleware {
+	return BudgetWithConfig(DefaultBudgetConfig())
+}
+
+// BudgetWithConfig returns middleware that computes the time left to serve
+// the request from the request context deadline, as set by Timeout, and
+// the caller's budget if trusted. The deadline is applied to the request
+// context, so outbound calls made with it inherit the budget, and handlers
+// can read it with Remaining. Requests arriving with no budget left are
+// rejected without running the handler.
+//
+// Register it after Timeout:
+//
+//	app.Use(middleware.Timeout(5 * time.Second))
+//	app.Use(middleware.Budget())
+func BudgetWithConfig(config BudgetConfig) ginji.Middleware {
+	defaults := DefaultBudgetConfig()
+	if config.Header == "" {
+		config.Header = defaults.Header
+	}
+	if config.StatusCode == 0 {
+		config.StatusCode = defaults.StatusCode
+	}
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		now := time.Now()
+		deadline, ok := c.Req.Context().Deadline()
+		if config.TrustInbound {
+			if budget, valid := parseBudget(c.Req.Header.Get(config.Header)); valid {
+				if inbound := now.Add(budget); !ok || inbound.Before(deadline) {
+					deadline, ok = inbound, true
+				}
+			}
+		}
+		if !ok && config.Default > 0 {
+			deadline, ok = now.Add(config.Default), true
+		}
+		if !ok {
+			return c.Next()
+		}
+
+		deadline = deadline.Add(-config.Reserve)
+		if !deadline.After(now) {
+			c.AbortWithStatusJSON(config.StatusCode, ginji.H{
+				"error": "Request budget exhausted",
+			})
+			return nil
+		}
+
+		ctx, cancel := context.WithDeadline(c.Req.Context(), deadline)
+		defer cancel()
+		c.Req = c.Req.WithContext(ctx)
+
+		return c.Next()
+	}
+}
+
+// Remaining returns the time left before the request deadline, and false
+// if the request has none. Handlers use it to size downstream timeouts:
+//
+//	if left, ok := middleware.Remaining(c); ok && left < 100*time.Millisecond {
+//		return c.JSON(200, cachedResult)
+//	}
+func Remaining(c *ginji.Context) (time.Duration, bool) {
+	return remainingBudget(c.Req.Context())
+}
+
+// BudgetTransport is an http.RoundTripper that sends the remaining budget
+// of the request context to downstream services, so that they stop work
+// the caller can no longer use:
+//
+//	client := &http.Client{Transport: &middleware.BudgetTransport{}}
+//	req, _ := http.NewRequestWithContext(c.Req.Context(), "GET", url, nil)
+//	resp, err := client.Do(req)
+type BudgetTransport struct {
+	// Base sends the requests.
+	// Default: http.DefaultTransport
+	Base http.RoundTripper
+
+	// Header carries the budget.
+	// Default: "X-Request-Budget"
+	Header string
+}
+
+// RoundTrip sets the budget header of req from its context deadline. Calls
+// whose budget is already spent fail with context.DeadlineExceeded without
+// being sent.
+func (t *BudgetTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	header := t.Header
+	if header == "" {
+		header = DefaultBudgetConfig().Header
+	}
+
+	left, ok := remainingBudget(req.Context())
+	if !ok {
+		return base.RoundTrip(req)
+	}
+	if left <= 0 {
+		closeRequestBody(req)
+		return nil, context.DeadlineExceeded
+	}
+
+	// RoundTrippers must not modify the request
+	req = req.Clone(req.Context())
+	req.Header.Set(header, formatBudget(left))
+	return base.RoundTrip(req)
+}
+
+// remainingBudget returns the time left before the deadline of ctx.
+func remainingBudget(ctx context.Context) (time.Duration, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+	left := time.Until(deadline)
+	if left < 0 {
+		left = 0
+	}
+	return left, true
+}
+
+// parseBudget parses a budget header of whole milliseconds.
+func parseBudget(value string) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	ms, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || ms < 0 || ms > int64(24*time.Hour/time.Millisecond) {
+		return 0, false
+	}
+	return time.Duration(ms) * time.Millisecond, true
+}
+
+// formatBudget formats a budget in whole milliseconds, rounding down so
+// that downstream services never get more time than is left.
+func formatBudget(d time.Duration) string {
+	return strconv.FormatInt(int64(d/time.Millisecond), 10)
+}
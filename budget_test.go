@@ -0,0 +1,185 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+func TestBudgetFromTimeout(t *testing.T) {
+	app := ginji.New()
+	app.Use(Timeout(2 * time.Second))
+	app.Use(Budget())
+	app.Get("/", func(c *ginji.Context) error {
+		left, ok := Remaining(c)
+		if !ok {
+			return c.Text(ginji.StatusOK, "none")
+		}
+		return c.Text(ginji.StatusOK, strconv.FormatInt(int64(left/time.Millisecond), 10))
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	ms, err := strconv.Atoi(w.Body.String())
+	if err != nil || ms <= 1000 || ms > 2000 {
+		t.Errorf("Expected remaining budget near 2s, got %q", w.Body.String())
+	}
+}
+
+func TestBudgetWithoutDeadline(t *testing.T) {
+	app := ginji.New()
+	app.Use(Budget())
+	app.Get("/", func(c *ginji.Context) error {
+		if _, ok := Remaining(c); ok {
+			return c.Text(ginji.StatusOK, "deadline")
+		}
+		return c.Text(ginji.StatusOK, "none")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/", nil)
+	ginji.AssertBody(t, w, "none")
+}
+
+func TestBudgetDefaultAndReserve(t *testing.T) {
+	app := ginji.New()
+	app.Use(BudgetWithConfig(BudgetConfig{
+		Default: time.Second,
+		Reserve: 400 * time.Millisecond,
+	}))
+	app.Get("/", func(c *ginji.Context) error {
+		left, _ := Remaining(c)
+		return c.Text(ginji.StatusOK, strconv.FormatInt(int64(left/time.Millisecond), 10))
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/", nil)
+	ms, err := strconv.Atoi(w.Body.String())
+	if err != nil || ms <= 400 || ms > 600 {
+		t.Errorf("Expected remaining budget near 600ms, got %q", w.Body.String())
+	}
+}
+
+func TestBudgetInbound(t *testing.T) {
+	handler := func(c *ginji.Context) error {
+		left, ok := Remaining(c)
+		if !ok {
+			return c.Text(ginji.StatusOK, "none")
+		}
+		return c.Text(ginji.StatusOK, strconv.FormatInt(int64(left/time.Millisecond), 10))
+	}
+
+	t.Run("untrusted header is ignored", func(t *testing.T) {
+		app := ginji.New()
+		app.Use(Budget())
+		app.Get("/", handler)
+
+		w := ginji.NewRequest(app, "GET", "/").Header("X-Request-Budget", "300").Do()
+		ginji.AssertBody(t, w, "none")
+	})
+
+	t.Run("trusted header shortens the deadline", func(t *testing.T) {
+		app := ginji.New()
+		app.Use(Timeout(5 * time.Second))
+		app.Use(BudgetWithConfig(BudgetConfig{TrustInbound: true}))
+		app.Get("/", handler)
+
+		w := ginji.NewRequest(app, "GET", "/").Header("X-Request-Budget", "300").Do()
+		ms, err := strconv.Atoi(w.Body.String())
+		if err != nil || ms <= 0 || ms > 300 {
+			t.Errorf("Expected remaining budget under 300ms, got %q", w.Body.String())
+		}
+	})
+
+	t.Run("longer header keeps the deadline", func(t *testing.T) {
+		app := ginji.New()
+		app.Use(Timeout(time.Second))
+		app.Use(BudgetWithConfig(BudgetConfig{TrustInbound: true}))
+		app.Get("/", handler)
+
+		w := ginji.NewRequest(app, "GET", "/").Header("X-Request-Budget", "60000").Do()
+		ms, err := strconv.Atoi(w.Body.String())
+		if err != nil || ms > 1000 {
+			t.Errorf("Expected remaining budget under 1s, got %q", w.Body.String())
+		}
+	})
+
+	t.Run("invalid header is ignored", func(t *testing.T) {
+		app := ginji.New()
+		app.Use(BudgetWithConfig(BudgetConfig{TrustInbound: true}))
+		app.Get("/", handler)
+
+		w := ginji.NewRequest(app, "GET", "/").Header("X-Request-Budget", "-5").Do()
+		ginji.AssertBody(t, w, "none")
+	})
+
+	t.Run("spent budget is rejected", func(t *testing.T) {
+		app := ginji.New()
+		app.Use(BudgetWithConfig(BudgetConfig{TrustInbound: true}))
+		called := false
+		app.Get("/", func(c *ginji.Context) error {
+			called = true
+			return c.Text(ginji.StatusOK, "ok")
+		})
+
+		w := ginji.NewRequest(app, "GET", "/").Header("X-Request-Budget", "0").Do()
+		ginji.AssertStatus(t, w, ginji.StatusGatewayTimeout)
+		if called {
+			t.Error("Expected handler not to run")
+		}
+	})
+}
+
+func TestBudgetTransport(t *testing.T) {
+	var got string
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("X-Request-Budget")
+	}))
+	defer downstream.Close()
+
+	client := &http.Client{Transport: &BudgetTransport{}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 800*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", downstream.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	ms, err := strconv.Atoi(got)
+	if err != nil || ms <= 0 || ms > 800 {
+		t.Errorf("Expected budget header under 800ms, got %q", got)
+	}
+	if req.Header.Get("X-Request-Budget") != "" {
+		t.Error("Expected the original request to be unchanged")
+	}
+
+	t.Run("no deadline sends no header", func(t *testing.T) {
+		got = "unset"
+		req, _ := http.NewRequest("GET", downstream.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		if got != "" {
+			t.Errorf("Expected no budget header, got %q", got)
+		}
+	})
+
+	t.Run("spent budget is not sent", func(t *testing.T) {
+		ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		defer cancel()
+		req, _ := http.NewRequestWithContext(ctx, "GET", downstream.URL, nil)
+		_, err := (&BudgetTransport{}).RoundTrip(req)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected DeadlineExceeded, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/ginjigo/ginji"
+)
+
+// errBodyTooLarge is returned by bufferRequestBody when the body exceeds the cap.
+var errBodyTooLarge = errors.New("request body too large")
+
+// BufferBodyConfig defines the configuration for body buffering middleware.
+type BufferBodyConfig struct {
+	// MaxBytes is the maximum body size that is buffered.
+	// Default: 1MB
+	MaxBytes int64
+
+	// StatusCode is returned when the body exceeds MaxBytes.
+	// Default: 413 Request Entity Too Large
+	StatusCode int
+
+	// ContextKey is the key used to store the raw body in context.
+	// Default: "raw_body"
+	ContextKey string
+
+	// SkipFunc allows skipping buffering for certain requests.
+	SkipFunc Skipper
+}
+
+// DefaultBufferBodyConfig returns default body buffering configuration.
+func DefaultBufferBodyConfig() BufferBodyConfig {
+	return BufferBodyConfig{
+		MaxBytes:   1 << 20, // 1 MB
+		StatusCode: http.StatusRequestEntityTooLarge,
+		ContextKey: "raw_body",
+	}
+}
+
+// BufferBody returns middleware that reads the request body into memory,
+// stores it in context and resets c.Req.Body so handlers can still bind it.
+// Usage:
+//
+//	app.Use(middleware.BufferBody(1 << 20))
+//	raw := middleware.RawBody(c)
+func BufferBody(maxBytes int64) ginji.Middleware {
+	config := DefaultBufferBodyConfig()
+	config.MaxBytes = maxBytes
+	return BufferBodyWithConfig(config)
+}
+
+// BufferBodyWithConfig returns body buffering middleware with custom configuration.
+func BufferBodyWithConfig(config BufferBodyConfig) ginji.Middleware {
+	defaults := DefaultBufferBodyConfig()
+	if config.MaxBytes <= 0 {
+		config.MaxBytes = defaults.MaxBytes
+	}
+	if config.StatusCode == 0 {
+		config.StatusCode = defaults.StatusCode
+	}
+	if config.ContextKey == "" {
+		config.ContextKey = defaults.ContextKey
+	}
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		body, err := bufferRequestBody(c, config.MaxBytes)
+		if err != nil {
+			if errors.Is(err, errBodyTooLarge) {
+				c.AbortWithStatusJSON(config.StatusCode, ginji.H{
+					"error":    fmt.Sprintf("Request body too large. Maximum allowed size is %d bytes", config.MaxBytes),
+					"maxBytes": config.MaxBytes,
+				})
+				return nil
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, ginji.H{
+				"error": "Failed to read request body",
+			})
+			return nil
+		}
+
+		c.Set(config.ContextKey, body)
+		return c.Next()
+	}
+}
+
+// RawBody returns the request body buffered by BufferBody or another
+// middleware that needed the raw bytes. It returns nil if the body was
+// not buffered.
+func RawBody(c *ginji.Context) []byte {
+	body, _ := c.Req.Context().Value(rawBodyContextKey).([]byte)
+	return body
+}
+
+// bufferRequestBody reads the whole body up to maxBytes, stores it for
+// RawBody and replaces c.Req.Body with a fresh reader. A body buffered
+// earlier in the chain is reused.
+func bufferRequestBody(c *ginji.Context, maxBytes int64) ([]byte, error) {
+	if body, ok := c.Req.Context().Value(rawBodyContextKey).([]byte); ok {
+		if int64(len(body)) > maxBytes {
+			return nil, errBodyTooLarge
+		}
+		return body, nil
+	}
+
+	if c.Req.Body == nil || c.Req.Body == http.NoBody {
+		setContextValue(c, rawBodyContextKey, []byte{})
+		return []byte{}, nil
+	}
+	if c.Req.ContentLength > maxBytes {
+		return nil, errBodyTooLarge
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Req.Body, maxBytes+1))
+	_ = c.Req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > maxBytes {
+		return nil, errBodyTooLarge
+	}
+
+	c.Req.Body = io.NopCloser(bytes.NewReader(body))
+	setContextValue(c, rawBodyContextKey, body)
+	return body, nil
+}
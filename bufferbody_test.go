@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ginjigo/ginji"
+)
+
+func TestBufferBody(t *testing.T) {
+	app := ginji.New()
+	app.Use(BufferBody(64))
+	app.Post("/", func(c *ginji.Context) error {
+		// Handlers consume the body; the raw bytes remain available
+		var payload map[string]string
+		if err := c.BindJSON(&payload); err != nil {
+			return err
+		}
+		return c.Text(ginji.StatusOK, payload["name"]+"|"+string(RawBody(c)))
+	})
+
+	w := ginji.PerformRequest(app, "POST", "/", strings.NewReader(`{"name":"ginji"}`))
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	ginji.AssertBody(t, w, `ginji|{"name":"ginji"}`)
+}
+
+func TestBufferBodyTooLarge(t *testing.T) {
+	app := ginji.New()
+	app.Use(BufferBody(8))
+	app.Post("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	w := ginji.PerformRequest(app, "POST", "/", io.NopCloser(strings.NewReader("0123456789")))
+	ginji.AssertStatus(t, w, ginji.StatusRequestEntityTooLarge)
+}
+
+func TestBufferBodySharedWithWebhook(t *testing.T) {
+	app := ginji.New()
+	app.Use(BufferBody(1 << 10))
+	app.Use(WebhookVerify(GitHubWebhook(), testWebhookSecret))
+	app.Post("/hook", func(c *ginji.Context) error {
+		body, _ := io.ReadAll(c.Req.Body)
+		return c.Text(ginji.StatusOK, string(body))
+	})
+
+	body := `{"zen":"Keep it logically awesome."}`
+	w := sendWebhook(app, body, http.Header{
+		"X-Hub-Signature-256": {"sha256=" + hmacHex(testWebhookSecret, body)},
+	})
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	ginji.AssertBody(t, w, body)
+}
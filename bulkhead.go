@@ -0,0 +1,237 @@
+package middleware
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// BulkheadPool limits the concurrency of a group of routes.
+type BulkheadPool struct {
+	// MaxConcurrent is the number of requests served at once. Required.
+	MaxConcurrent int
+
+	// MaxQueue is the number of requests that may wait for a slot. Requests
+	// arriving at a full queue are rejected at once.
+	// Default: 0 (no queueing)
+	MaxQueue int
+
+	// QueueTimeout is how long a queued request waits for a slot before it
+	// is rejected.
+	// Default: 1 second
+	QueueTimeout time.Duration
+}
+
+// BulkheadConfig defines the configuration for bulkhead middleware.
+type BulkheadConfig struct {
+	// Pools maps path glob patterns (see PathGlob) to their pools, e.g.
+	// {"/reports/**": {MaxConcurrent: 5}}. The longest matching pattern
+	// wins, and each pattern has its own slots. Required.
+	Pools map[string]BulkheadPool
+
+	// Default is the pool of requests matching no pattern.
+	// Default: unlimited
+	Default *BulkheadPool
+
+	// StatusCode is sent when a request is rejected.
+	// Default: 503
+	StatusCode int
+
+	// Metrics exports the bulkhead_in_flight and bulkhead_queued gauges and
+	// the bulkhead_rejected_total counter, labelled by pool. Optional.
+	Metrics *Metrics
+
+	// OnReject is called for every rejected request with the pool pattern
+	// and the reason, "queue_full" or "queue_timeout".
+	OnReject func(c *ginji.Context, pool, reason string)
+
+	// SkipFunc allows skipping the bulkhead for certain requests.
+	SkipFunc Skipper
+}
+
+// BulkheadPoolStats is the state of one bulkhead pool.
+type BulkheadPoolStats struct {
+	// InFlight is the number of requests being served.
+	InFlight int `json:"inFlight"`
+
+	// Queued is the number of requests waiting for a slot.
+	Queued int64 `json:"queued"`
+
+	// Limit is the pool's MaxConcurrent.
+	Limit int `json:"limit"`
+
+	// Rejected is the number of requests rejected since start.
+	Rejected int64 `json:"rejected"`
+}
+
+// bulkheadPool is a compiled BulkheadPool.
+type bulkheadPool struct {
+	name     string
+	config   BulkheadPool
+	slots    chan struct{}
+	queued   atomic.Int64
+	rejected atomic.Int64
+}
+
+// Bulkhead isolates route groups in separate concurrency pools, so that a
+// slow endpoint exhausts its own pool instead of the whole server:
+//
+//	bulkhead := middleware.NewBulkhead(middleware.BulkheadConfig{
+//		Pools: map[string]middleware.BulkheadPool{
+//			"/reports/**": {MaxConcurrent: 5, MaxQueue: 10},
+//			"/api/**":     {MaxConcurrent: 500},
+//		},
+//	})
+//	app.Use(bulkhead.Middleware())
+//
+// Bulkhead implements StatsProvider.
+type Bulkhead struct {
+	config   BulkheadConfig
+	pools    []pathOverride[*bulkheadPool]
+	fallback *bulkheadPool
+}
+
+// NewBulkhead creates a bulkhead with the given configuration. It panics if
+// Pools is empty or a pool has no MaxConcurrent.
+func NewBulkhead(config BulkheadConfig) *Bulkhead {
+	if len(config.Pools) == 0 {
+		panic("Bulkhead: Pools is required")
+	}
+	if config.StatusCode == 0 {
+		config.StatusCode = ginji.StatusServiceUnavailable
+	}
+
+	pools := make(map[string]*bulkheadPool, len(config.Pools))
+	for pattern, pool := range config.Pools {
+		pools[pattern] = newBulkheadPool(pattern, pool)
+	}
+	b := &Bulkhead{config: config, pools: compilePathOverrides(pools)}
+	if config.Default != nil {
+		b.fallback = newBulkheadPool("default", *config.Default)
+	}
+
+	if m := config.Metrics; m != nil {
+		m.Describe("bulkhead_in_flight", MetricGauge, "Number of requests being served by a bulkhead pool.")
+		m.Describe("bulkhead_queued", MetricGauge, "Number of requests waiting for a bulkhead pool.")
+		m.Describe("bulkhead_rejected_total", MetricCounter, "Number of requests rejected by a bulkhead pool.")
+	}
+	return b
+}
+
+// newBulkheadPool compiles a pool.
+func newBulkheadPool(name string, config BulkheadPool) *bulkheadPool {
+	if config.MaxConcurrent <= 0 {
+		panic(fmt.Sprintf("Bulkhead: pool %q needs MaxConcurrent", name))
+	}
+	if config.QueueTimeout <= 0 {
+		config.QueueTimeout = time.Second
+	}
+	return &bulkheadPool{
+		name:   name,
+		config: config,
+		slots:  make(chan struct{}, config.MaxConcurrent),
+	}
+}
+
+// Middleware returns middleware that runs each request in the pool of its
+// path. Requests are rejected when the pool and its queue are full, or
+// when no slot frees up within QueueTimeout.
+func (b *Bulkhead) Middleware() ginji.Middleware {
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if b.config.SkipFunc != nil && b.config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		pool := lookupPathOverride(c, b.pools, b.fallback)
+		if pool == nil {
+			return c.Next()
+		}
+
+		if reason := b.acquire(c, pool); reason != "" {
+			pool.rejected.Add(1)
+			if m := b.config.Metrics; m != nil {
+				m.Inc("bulkhead_rejected_total", "pool", pool.name, "reason", reason)
+			}
+			if b.config.OnReject != nil {
+				b.config.OnReject(c, pool.name, reason)
+			}
+			c.SetHeader("Retry-After", "1")
+			c.AbortWithStatusJSON(b.config.StatusCode, ginji.H{
+				"error": "Too many concurrent requests",
+			})
+			return nil
+		}
+		defer b.release(pool)
+
+		return c.Next()
+	}
+}
+
+// acquire takes a slot in pool, queueing if allowed, and returns the
+// reason for a rejection or "".
+func (b *Bulkhead) acquire(c *ginji.Context, pool *bulkheadPool) string {
+	select {
+	case pool.slots <- struct{}{}:
+		b.record(pool)
+		return ""
+	default:
+	}
+
+	if pool.queued.Add(1) > int64(pool.config.MaxQueue) {
+		pool.queued.Add(-1)
+		return "queue_full"
+	}
+	b.record(pool)
+	defer func() {
+		pool.queued.Add(-1)
+		b.record(pool)
+	}()
+
+	timer := time.NewTimer(pool.config.QueueTimeout)
+	defer timer.Stop()
+	select {
+	case pool.slots <- struct{}{}:
+		return ""
+	case <-timer.C:
+		return "queue_timeout"
+	case <-c.Req.Context().Done():
+		return "queue_timeout"
+	}
+}
+
+// release frees a slot in pool.
+func (b *Bulkhead) release(pool *bulkheadPool) {
+	<-pool.slots
+	b.record(pool)
+}
+
+// record exports the gauges of pool.
+func (b *Bulkhead) record(pool *bulkheadPool) {
+	if m := b.config.Metrics; m != nil {
+		m.Set("bulkhead_in_flight", float64(len(pool.slots)), "pool", pool.name)
+		m.Set("bulkhead_queued", float64(pool.queued.Load()), "pool", pool.name)
+	}
+}
+
+// Stats implements StatsProvider, reporting each pool by pattern.
+func (b *Bulkhead) Stats() any {
+	stats := make(map[string]BulkheadPoolStats, len(b.pools)+1)
+	add := func(pool *bulkheadPool) {
+		stats[pool.name] = BulkheadPoolStats{
+			InFlight: len(pool.slots),
+			Queued:   pool.queued.Load(),
+			Limit:    pool.config.MaxConcurrent,
+			Rejected: pool.rejected.Load(),
+		}
+	}
+	for _, o := range b.pools {
+		add(o.value)
+	}
+	if b.fallback != nil {
+		add(b.fallback)
+	}
+	return stats
+}
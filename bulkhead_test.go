@@ -0,0 +1,179 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+func TestBulkheadIsolatesPools(t *testing.T) {
+	var rejected []string
+	bulkhead := NewBulkhead(BulkheadConfig{
+		Pools: map[string]BulkheadPool{
+			"/reports/**": {MaxConcurrent: 1},
+			"/api/**":     {MaxConcurrent: 10},
+		},
+		OnReject: func(c *ginji.Context, pool, reason string) {
+			rejected = append(rejected, pool+" "+reason)
+		},
+	})
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	app := ginji.New()
+	app.Use(bulkhead.Middleware())
+	app.Get("/reports/slow", func(c *ginji.Context) error {
+		started <- struct{}{}
+		<-release
+		return c.Text(ginji.StatusOK, "report")
+	})
+	app.Get("/api/fast", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "fast")
+	})
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- ginji.PerformRequest(app, "GET", "/reports/slow", nil) }()
+	<-started
+
+	w := ginji.PerformRequest(app, "GET", "/reports/slow", nil)
+	ginji.AssertStatus(t, w, ginji.StatusServiceUnavailable)
+	ginji.AssertHeader(t, w, "Retry-After", "1")
+
+	// The full reports pool does not affect other routes
+	w = ginji.PerformRequest(app, "GET", "/api/fast", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+
+	stats := bulkhead.Stats().(map[string]BulkheadPoolStats)
+	if s := stats["/reports/**"]; s.InFlight != 1 || s.Rejected != 1 || s.Limit != 1 {
+		t.Errorf("Unexpected reports pool stats %+v", s)
+	}
+
+	close(release)
+	ginji.AssertStatus(t, <-done, ginji.StatusOK)
+
+	if len(rejected) != 1 || rejected[0] != "/reports/** queue_full" {
+		t.Errorf("Expected one queue_full rejection, got %v", rejected)
+	}
+}
+
+func TestBulkheadQueue(t *testing.T) {
+	bulkhead := NewBulkhead(BulkheadConfig{
+		Pools: map[string]BulkheadPool{
+			"/reports/**": {MaxConcurrent: 1, MaxQueue: 1, QueueTimeout: time.Second},
+		},
+	})
+	started, release := make(chan struct{}, 2), make(chan struct{})
+	app := ginji.New()
+	app.Use(bulkhead.Middleware())
+	app.Get("/reports/slow", func(c *ginji.Context) error {
+		started <- struct{}{}
+		<-release
+		return c.Text(ginji.StatusOK, "report")
+	})
+
+	var wg sync.WaitGroup
+	codes := make([]int, 2)
+	for i := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes[i] = ginji.PerformRequest(app, "GET", "/reports/slow", nil).Code
+		}()
+		if i == 0 {
+			<-started
+		}
+	}
+
+	// Wait for the second request to queue
+	deadline := time.Now().Add(time.Second)
+	for bulkhead.Stats().(map[string]BulkheadPoolStats)["/reports/**"].Queued != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected a queued request")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// The queue is full
+	w := ginji.PerformRequest(app, "GET", "/reports/slow", nil)
+	ginji.AssertStatus(t, w, ginji.StatusServiceUnavailable)
+
+	close(release)
+	wg.Wait()
+	for i, code := range codes {
+		if code != ginji.StatusOK {
+			t.Errorf("Expected request %d to succeed, got %d", i, code)
+		}
+	}
+}
+
+func TestBulkheadQueueTimeout(t *testing.T) {
+	metrics := NewMetrics(DefaultMetricsConfig())
+	bulkhead := NewBulkhead(BulkheadConfig{
+		Pools: map[string]BulkheadPool{
+			"/reports/**": {MaxConcurrent: 1, MaxQueue: 5, QueueTimeout: 50 * time.Millisecond},
+		},
+		Metrics: metrics,
+	})
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	app := ginji.New()
+	app.Use(bulkhead.Middleware())
+	app.Get("/reports/slow", func(c *ginji.Context) error {
+		started <- struct{}{}
+		<-release
+		return c.Text(ginji.StatusOK, "report")
+	})
+
+	done := make(chan struct{})
+	go func() {
+		ginji.PerformRequest(app, "GET", "/reports/slow", nil)
+		close(done)
+	}()
+	<-started
+
+	w := ginji.PerformRequest(app, "GET", "/reports/slow", nil)
+	ginji.AssertStatus(t, w, ginji.StatusServiceUnavailable)
+
+	if v := metrics.Value("bulkhead_rejected_total", "pool", "/reports/**", "reason", "queue_timeout"); v != 1 {
+		t.Errorf("Expected one queue_timeout rejection, got %v", v)
+	}
+	if v := metrics.Value("bulkhead_in_flight", "pool", "/reports/**"); v != 1 {
+		t.Errorf("Expected one request in flight, got %v", v)
+	}
+
+	close(release)
+	<-done
+	if v := metrics.Value("bulkhead_in_flight", "pool", "/reports/**"); v != 0 {
+		t.Errorf("Expected no request in flight, got %v", v)
+	}
+}
+
+func TestBulkheadDefaultPool(t *testing.T) {
+	bulkhead := NewBulkhead(BulkheadConfig{
+		Pools:   map[string]BulkheadPool{"/reports/**": {MaxConcurrent: 1}},
+		Default: &BulkheadPool{MaxConcurrent: 100},
+	})
+
+	app := ginji.New()
+	app.Use(bulkhead.Middleware())
+	app.Get("/other", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/other", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+
+	stats := bulkhead.Stats().(map[string]BulkheadPoolStats)
+	if s, ok := stats["default"]; !ok || s.Limit != 100 || s.InFlight != 0 {
+		t.Errorf("Unexpected default pool stats %+v", s)
+	}
+}
+
+func TestBulkheadRequiresPools(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic without pools")
+		}
+	}()
+	NewBulkhead(BulkheadConfig{})
+}
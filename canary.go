@@ -0,0 +1,193 @@
+package middleware
+
+import (
+	"hash/fnv"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// Canary variants.
+const (
+	CanaryVariantStable = "stable"
+	CanaryVariantCanary = "canary"
+)
+
+// CanaryConfig defines the configuration for canary middleware.
+type CanaryConfig struct {
+	// Weight is the initial percentage (0-100) of requests sent to the canary.
+	Weight int
+
+	// Handler serves canary requests. Either Handler or Upstream is required.
+	Handler ginji.Handler
+
+	// Upstream is the base URL canary requests are proxied to.
+	Upstream string
+
+	// KeyFunc returns a stable key, such as a user ID, that is hashed to
+	// assign the variant. If it is nil or returns "", the variant is
+	// chosen at random and remembered with a cookie.
+	KeyFunc func(*ginji.Context) string
+
+	// CookieName is the cookie used to keep clients on their variant.
+	// Default: "canary"
+	CookieName string
+
+	// CookieMaxAge is how long variant cookies are kept.
+	// Default: 24 hours
+	CookieMaxAge time.Duration
+
+	// Header reports the variant that served the request.
+	// Default: "X-Canary-Variant"
+	Header string
+
+	// ContextKey is the key used to store the variant in context.
+	// Default: "canary_variant"
+	ContextKey string
+
+	// SkipFunc allows skipping the split for certain requests.
+	SkipFunc Skipper
+}
+
+// DefaultCanaryConfig returns default canary configuration.
+func DefaultCanaryConfig() CanaryConfig {
+	return CanaryConfig{
+		CookieName:   "canary",
+		CookieMaxAge: 24 * time.Hour,
+		Header:       "X-Canary-Variant",
+		ContextKey:   "canary_variant",
+	}
+}
+
+// Canary splits traffic between the regular handler chain and a canary.
+// The weight can be changed at runtime with SetWeight.
+type Canary struct {
+	config CanaryConfig
+	weight atomic.Int32
+	proxy  *httputil.ReverseProxy
+}
+
+// NewCanary creates a canary splitter. It panics if neither Handler nor
+// Upstream is set or the upstream URL is invalid.
+func NewCanary(config CanaryConfig) *Canary {
+	defaults := DefaultCanaryConfig()
+	if config.CookieName == "" {
+		config.CookieName = defaults.CookieName
+	}
+	if config.CookieMaxAge == 0 {
+		config.CookieMaxAge = defaults.CookieMaxAge
+	}
+	if config.Header == "" {
+		config.Header = defaults.Header
+	}
+	if config.ContextKey == "" {
+		config.ContextKey = defaults.ContextKey
+	}
+
+	cn := &Canary{config: config}
+	switch {
+	case config.Handler != nil:
+	case config.Upstream != "":
+		target, err := url.Parse(config.Upstream)
+		if err != nil || target.Host == "" {
+			panic("Canary: invalid Upstream URL: " + config.Upstream)
+		}
+		cn.proxy = httputil.NewSingleHostReverseProxy(target)
+	default:
+		panic("Canary: Handler or Upstream is required")
+	}
+	cn.SetWeight(config.Weight)
+	return cn
+}
+
+// SetWeight sets the percentage of requests sent to the canary, clamped to 0-100.
+func (cn *Canary) SetWeight(weight int) {
+	weight = max(0, min(100, weight))
+	cn.weight.Store(int32(weight))
+}
+
+// Weight returns the current canary percentage.
+func (cn *Canary) Weight() int {
+	return int(cn.weight.Load())
+}
+
+// Middleware returns middleware that sends the canary share of requests to
+// the canary handler or upstream and lets the rest continue down the chain.
+func (cn *Canary) Middleware() ginji.Middleware {
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if cn.config.SkipFunc != nil && cn.config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		variant := cn.variant(c)
+		c.Set(cn.config.ContextKey, variant)
+		setContextValue(c, canaryContextKey, variant)
+		c.SetHeader(cn.config.Header, variant)
+
+		if variant == CanaryVariantStable {
+			return c.Next()
+		}
+
+		c.Abort()
+		if cn.proxy != nil {
+			cn.proxy.ServeHTTP(c.Res, c.Req)
+			return nil
+		}
+		return cn.config.Handler(c)
+	}
+}
+
+// variant assigns the request to a variant, keeping sticky assignments
+// unless the weight has moved to either extreme.
+func (cn *Canary) variant(c *ginji.Context) string {
+	weight := cn.Weight()
+	switch weight {
+	case 0:
+		return CanaryVariantStable
+	case 100:
+		return CanaryVariantCanary
+	}
+
+	if cn.config.KeyFunc != nil {
+		if key := cn.config.KeyFunc(c); key != "" {
+			h := fnv.New32a()
+			_, _ = h.Write([]byte(key))
+			if int(h.Sum32()%100) < weight {
+				return CanaryVariantCanary
+			}
+			return CanaryVariantStable
+		}
+	}
+
+	if cookie, err := c.Cookie(cn.config.CookieName); err == nil {
+		if cookie.Value == CanaryVariantStable || cookie.Value == CanaryVariantCanary {
+			return cookie.Value
+		}
+	}
+
+	variant := CanaryVariantStable
+	if rand.IntN(100) < weight {
+		variant = CanaryVariantCanary
+	}
+	c.SetCookie(&http.Cookie{
+		Name:     cn.config.CookieName,
+		Value:    variant,
+		Path:     "/",
+		MaxAge:   int(cn.config.CookieMaxAge.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return variant
+}
+
+// GetCanaryVariant returns the variant assigned by the Canary middleware.
+func GetCanaryVariant(c *ginji.Context) string {
+	variant, _ := c.Req.Context().Value(canaryContextKey).(string)
+	return variant
+}
@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/ginjigo/ginji"
+)
+
+func canaryHandler(c *ginji.Context) error {
+	return c.Text(ginji.StatusOK, "canary")
+}
+
+func TestCanaryWeightExtremes(t *testing.T) {
+	cn := NewCanary(CanaryConfig{Weight: 0, Handler: canaryHandler})
+	app := ginji.New()
+	app.Use(cn.Middleware())
+	app.Get("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "stable")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/", nil)
+	ginji.AssertBody(t, w, "stable")
+	ginji.AssertHeader(t, w, "X-Canary-Variant", CanaryVariantStable)
+
+	cn.SetWeight(100)
+	w = ginji.PerformRequest(app, "GET", "/", nil)
+	ginji.AssertBody(t, w, "canary")
+	ginji.AssertHeader(t, w, "X-Canary-Variant", CanaryVariantCanary)
+
+	cn.SetWeight(250)
+	if cn.Weight() != 100 {
+		t.Errorf("Expected weight clamped to 100, got %d", cn.Weight())
+	}
+}
+
+func TestCanaryStickyCookie(t *testing.T) {
+	cn := NewCanary(CanaryConfig{Weight: 50, Handler: canaryHandler})
+	app := ginji.New()
+	app.Use(cn.Middleware())
+	app.Get("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "stable")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/", nil)
+	cookie := findCookie(w.Result().Cookies(), "canary")
+	if cookie == nil {
+		t.Fatal("Expected variant cookie")
+	}
+
+	for i := 0; i < 20; i++ {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.AddCookie(&http.Cookie{Name: "canary", Value: cookie.Value})
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, req)
+		ginji.AssertHeader(t, rec, "X-Canary-Variant", cookie.Value)
+	}
+}
+
+func TestCanaryHashSplit(t *testing.T) {
+	cn := NewCanary(CanaryConfig{
+		Weight:  30,
+		Handler: canaryHandler,
+		KeyFunc: func(c *ginji.Context) string { return c.Query("user") },
+	})
+	app := ginji.New()
+	app.Use(cn.Middleware())
+	app.Get("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "stable")
+	})
+
+	canaries := 0
+	for i := 0; i < 1000; i++ {
+		path := "/?user=" + strconv.Itoa(i)
+		first := ginji.PerformRequest(app, "GET", path, nil).Header().Get("X-Canary-Variant")
+		second := ginji.PerformRequest(app, "GET", path, nil).Header().Get("X-Canary-Variant")
+		if first != second {
+			t.Fatalf("Expected user %d to stay on %s, got %s", i, first, second)
+		}
+		if first == CanaryVariantCanary {
+			canaries++
+		}
+	}
+
+	if canaries < 200 || canaries > 400 {
+		t.Errorf("Expected roughly 30%% canary traffic, got %d of 1000", canaries)
+	}
+}
+
+func TestCanaryUpstream(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("upstream " + r.URL.Path))
+	}))
+	defer upstream.Close()
+
+	cn := NewCanary(CanaryConfig{Weight: 100, Upstream: upstream.URL})
+	app := ginji.New()
+	app.Use(cn.Middleware())
+	app.Get("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "stable")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/", nil)
+	ginji.AssertBody(t, w, "upstream /")
+}
@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/ginjigo/ginji"
+)
+
+// Chain composes middleware into one, running them in order.
+//
+//	api.Use(middleware.Chain(middleware.RequestID(), middleware.Logger()))
+func Chain(middlewares ...ginji.Middleware) ginji.Middleware {
+	return ginji.Combine(middlewares...)
+}
+
+// When runs mw only for requests matching predicate.
+func When(predicate ginji.ConditionFunc, mw ginji.Middleware) ginji.Middleware {
+	return func(c *ginji.Context) error {
+		if predicate(c) {
+			return mw(c)
+		}
+		return c.Next()
+	}
+}
+
+// Unless runs mw for every request whose path does not match pathGlob.
+//
+//	app.Use(middleware.Unless("/health/**", middleware.Logger()))
+func Unless(pathGlob string, mw ginji.Middleware) ginji.Middleware {
+	matches := PathGlob(pathGlob)
+	return When(func(c *ginji.Context) bool { return !matches(c) }, mw)
+}
+
+// ForPaths runs mw only for requests whose path matches pathGlob.
+func ForPaths(pathGlob string, mw ginji.Middleware) ginji.Middleware {
+	return When(PathGlob(pathGlob), mw)
+}
+
+// ForMethods runs mw only for the given comma-separated HTTP methods.
+//
+//	app.Use(middleware.ForMethods("POST,PUT,PATCH", middleware.BodyLimit(1<<20)))
+func ForMethods(methods string, mw ginji.Middleware) ginji.Middleware {
+	allowed := make(map[string]bool)
+	for _, m := range strings.Split(methods, ",") {
+		allowed[strings.ToUpper(strings.TrimSpace(m))] = true
+	}
+	return When(func(c *ginji.Context) bool { return allowed[c.Req.Method] }, mw)
+}
+
+// PathGlob returns a predicate matching request paths against a glob.
+// "*" matches within a single path segment and "**" matches any number
+// of segments, so "/api/*/users" and "/static/**" are both valid.
+func PathGlob(pattern string) ginji.ConditionFunc {
+	patternParts := strings.Split(strings.Trim(pattern, "/"), "/")
+	return func(c *ginji.Context) bool {
+		return matchGlobSegments(patternParts, strings.Split(strings.Trim(c.Req.URL.Path, "/"), "/"))
+	}
+}
+
+// matchGlobSegments matches path segments against glob segments.
+func matchGlobSegments(pattern, parts []string) bool {
+	for i, p := range pattern {
+		if p == "**" {
+			rest := pattern[i+1:]
+			for j := i; j <= len(parts); j++ {
+				if matchGlobSegments(rest, parts[j:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if i >= len(parts) {
+			return false
+		}
+		if ok, err := path.Match(p, parts[i]); err != nil || !ok {
+			return false
+		}
+	}
+	return len(pattern) == len(parts)
+}
+
+// pathOverride is a per-path configuration value keyed by a PathGlob pattern.
+type pathOverride[T any] struct {
+	pattern string
+	match   ginji.ConditionFunc
+	value   T
+}
+
+// compilePathOverrides compiles overrides so the longest pattern is tried first.
+func compilePathOverrides[T any](overrides map[string]T) []pathOverride[T] {
+	compiled := make([]pathOverride[T], 0, len(overrides))
+	for pattern, value := range overrides {
+		compiled = append(compiled, pathOverride[T]{pattern: pattern, match: PathGlob(pattern), value: value})
+	}
+	sort.Slice(compiled, func(i, j int) bool {
+		if len(compiled[i].pattern) != len(compiled[j].pattern) {
+			return len(compiled[i].pattern) > len(compiled[j].pattern)
+		}
+		return compiled[i].pattern < compiled[j].pattern
+	})
+	return compiled
+}
+
+// lookupPathOverride returns the value of the longest pattern matching the
+// request path, or fallback if none match.
+func lookupPathOverride[T any](c *ginji.Context, overrides []pathOverride[T], fallback T) T {
+	for _, o := range overrides {
+		if o.match(c) {
+			return o.value
+		}
+	}
+	return fallback
+}
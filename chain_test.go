@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ginjigo/ginji"
+)
+
+// markMiddleware appends name to the X-Ran response header.
+func markMiddleware(name string) ginji.Middleware {
+	return func(c *ginji.Context) error {
+		c.Res.Header().Add("X-Ran", name)
+		return c.Next()
+	}
+}
+
+func TestChainOrder(t *testing.T) {
+	app := ginji.New()
+	app.Use(Chain(markMiddleware("a"), markMiddleware("b")))
+	app.Use(markMiddleware("c"))
+	app.Get("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/", nil)
+	got := w.Header().Values("X-Ran")
+	if len(got) != 3 || got[0] != "a" || got[1] != "b" || got[2] != "c" {
+		t.Errorf("Expected a, b, c, got %v", got)
+	}
+}
+
+func TestConditionalApplication(t *testing.T) {
+	app := ginji.New()
+	app.Use(Unless("/health/**", markMiddleware("logged")))
+	app.Use(ForPaths("/api/*/users", markMiddleware("users")))
+	app.Use(ForMethods("POST, put", markMiddleware("write")))
+	app.Use(When(func(c *ginji.Context) bool { return c.Header("X-Debug") == "1" }, markMiddleware("debug")))
+
+	handler := func(c *ginji.Context) error { return c.Text(ginji.StatusOK, "ok") }
+	app.Get("/health/live", handler)
+	app.Get("/api/v1/users", handler)
+	app.Post("/api/v1/orders", handler)
+
+	tests := []struct {
+		method, path string
+		want         []string
+	}{
+		{"GET", "/health/live", nil},
+		{"GET", "/api/v1/users", []string{"logged", "users"}},
+		{"POST", "/api/v1/orders", []string{"logged", "write"}},
+	}
+	for _, tt := range tests {
+		w := ginji.PerformRequest(app, tt.method, tt.path, nil)
+		got := w.Header().Values("X-Ran")
+		if len(got) != len(tt.want) {
+			t.Errorf("%s %s: expected %v, got %v", tt.method, tt.path, tt.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s %s: expected %v, got %v", tt.method, tt.path, tt.want, got)
+			}
+		}
+	}
+}
+
+func TestPathGlob(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"/static/**", "/static/css/app.css", true},
+		{"/static/**", "/static", true},
+		{"/api/*/users", "/api/v2/users", true},
+		{"/api/*/users", "/api/v2/orders", false},
+		{"/**/*.json", "/a/b/c.json", true},
+		{"/admin", "/admin/users", false},
+	}
+	for _, tt := range tests {
+		c := &ginji.Context{Req: httptest.NewRequest("GET", tt.path, nil)}
+		if got := PathGlob(tt.pattern)(c); got != tt.want {
+			t.Errorf("PathGlob(%q) on %q = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// ChainProfilerConfig defines the configuration for middleware chain
+// profiling.
+type ChainProfilerConfig struct {
+	// SampleSize is the number of recent timings kept per middleware to
+	// compute percentiles.
+	// Default: 1024
+	SampleSize int
+
+	// Metrics exports the middleware_duration_seconds histogram, labelled
+	// by middleware, for percentiles with histogram_quantile. Optional.
+	Metrics *Metrics
+
+	// SkipFunc allows skipping profiling for certain requests. Skipped
+	// requests still run the wrapped middleware.
+	SkipFunc Skipper
+}
+
+// DefaultChainProfilerConfig returns default chain profiler configuration.
+func DefaultChainProfilerConfig() ChainProfilerConfig {
+	return ChainProfilerConfig{
+		SampleSize: 1024,
+	}
+}
+
+// MiddlewareTimingStats is the time spent in one profiled middleware.
+type MiddlewareTimingStats struct {
+	// Count is the number of profiled requests since start.
+	Count int64 `json:"count"`
+
+	// Total is the time spent in the middleware since start.
+	Total string `json:"total"`
+
+	// P50 and P99 are percentiles over the recent samples.
+	P50 string `json:"p50"`
+	P99 string `json:"p99"`
+
+	// Max is the slowest recent sample.
+	Max string `json:"max"`
+}
+
+// ChainProfiler measures the time each middleware in a chain spends on a
+// request, excluding the middleware and handlers it calls through
+// c.Next, so that operators can find which one slows requests down:
+//
+//	profiler := middleware.NewChainProfiler(middleware.ChainProfilerConfig{Metrics: metrics})
+//	app.Use(profiler.Wrap("auth", middleware.BearerAuth(validate)))
+//	app.Use(profiler.Wrap("ratelimit", middleware.RateLimit(100, time.Minute)))
+//	app.Get("/users", profiler.WrapHandler("users", listUsers))
+//
+// Time spent in middleware that is not wrapped counts toward the nearest
+// wrapped middleware running it, so wrap route handlers too, or the last
+// wrapped middleware is charged for them.
+//
+// ChainProfiler implements StatsProvider, reporting p50 and p99 per
+// middleware, and its Handler serves the same stats as JSON.
+type ChainProfiler struct {
+	config  ChainProfilerConfig
+	mu      sync.RWMutex
+	timings map[string]*middlewareTimings
+}
+
+// middlewareTimings holds the samples of one middleware.
+type middlewareTimings struct {
+	mu      sync.Mutex
+	count   int64
+	total   time.Duration
+	samples []time.Duration // ring buffer of recent samples
+	next    int
+}
+
+// chainProfileFrame is a running profiled middleware. nested accumulates
+// the time of the profiled middleware it calls.
+type chainProfileFrame struct {
+	parent *chainProfileFrame
+	nested time.Duration
+}
+
+// NewChainProfiler creates a chain profiler with the given configuration.
+func NewChainProfiler(config ChainProfilerConfig) *ChainProfiler {
+	defaults := DefaultChainProfilerConfig()
+	if config.SampleSize <= 0 {
+		config.SampleSize = defaults.SampleSize
+	}
+	if m := config.Metrics; m != nil {
+		m.Describe("middleware_duration_seconds", MetricHistogram, "Time spent in a middleware, excluding the middleware it calls.")
+	}
+	return &ChainProfiler{
+		config:  config,
+		timings: make(map[string]*middlewareTimings),
+	}
+}
+
+// Wrap returns mw timed under name. Several middleware wrapped under the
+// same name are reported together.
+func (p *ChainProfiler) Wrap(name string, mw ginji.Middleware) ginji.Middleware {
+	timings := p.timingsFor(name)
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if p.config.SkipFunc != nil && p.config.SkipFunc(c) {
+			return mw(c)
+		}
+
+		var parent *chainProfileFrame
+		if v, exists := c.Get("chain_profile"); exists {
+			parent, _ = v.(*chainProfileFrame)
+		}
+		frame := &chainProfileFrame{parent: parent}
+		c.Set("chain_profile", frame)
+
+		start := time.Now()
+		defer func() {
+			elapsed := time.Since(start)
+			c.Set("chain_profile", parent)
+			if parent != nil {
+				parent.nested += elapsed
+			}
+			p.record(name, timings, elapsed-frame.nested)
+		}()
+
+		return mw(c)
+	}
+}
+
+// WrapHandler returns h timed under name.
+func (p *ChainProfiler) WrapHandler(name string, h ginji.Handler) ginji.Handler {
+	return ginji.Handler(p.Wrap(name, ginji.Middleware(h)))
+}
+
+// Stats implements StatsProvider, reporting the timings of each middleware
+// by name.
+func (p *ChainProfiler) Stats() any {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	stats := make(map[string]MiddlewareTimingStats, len(p.timings))
+	for name, t := range p.timings {
+		stats[name] = t.stats()
+	}
+	return stats
+}
+
+// Handler returns a handler serving Stats as JSON, for mounting on an
+// internal route.
+func (p *ChainProfiler) Handler() ginji.Handler {
+	return func(c *ginji.Context) error {
+		return c.JSON(http.StatusOK, p.Stats())
+	}
+}
+
+// Reset discards all recorded timings, e.g. after a deployment.
+func (p *ChainProfiler) Reset() {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, t := range p.timings {
+		t.mu.Lock()
+		t.count, t.total, t.next = 0, 0, 0
+		t.samples = t.samples[:0]
+		t.mu.Unlock()
+	}
+}
+
+// timingsFor returns the timings of name, creating them if needed.
+func (p *ChainProfiler) timingsFor(name string) *middlewareTimings {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	t, ok := p.timings[name]
+	if !ok {
+		t = &middlewareTimings{samples: make([]time.Duration, 0, p.config.SampleSize)}
+		p.timings[name] = t
+	}
+	return t
+}
+
+// record adds a sample to t and exports it.
+func (p *ChainProfiler) record(name string, t *middlewareTimings, d time.Duration) {
+	d = max(d, 0)
+
+	t.mu.Lock()
+	t.count++
+	t.total += d
+	if len(t.samples) < cap(t.samples) {
+		t.samples = append(t.samples, d)
+	} else {
+		t.samples[t.next] = d
+		t.next = (t.next + 1) % len(t.samples)
+	}
+	t.mu.Unlock()
+
+	if m := p.config.Metrics; m != nil {
+		m.Observe("middleware_duration_seconds", d.Seconds(), "middleware", name)
+	}
+}
+
+// stats computes the MiddlewareTimingStats snapshot of t.
+func (t *middlewareTimings) stats() MiddlewareTimingStats {
+	t.mu.Lock()
+	count, total := t.count, t.total
+	samples := slices.Clone(t.samples)
+	t.mu.Unlock()
+
+	slices.Sort(samples)
+	var maxSample time.Duration
+	if len(samples) > 0 {
+		maxSample = samples[len(samples)-1]
+	}
+	return MiddlewareTimingStats{
+		Count: count,
+		Total: total.String(),
+		P50:   percentile(samples, 0.50).String(),
+		P99:   percentile(samples, 0.99).String(),
+		Max:   maxSample.String(),
+	}
+}
+
+// percentile returns the q-th percentile of sorted samples using the
+// nearest-rank method, or 0 if there are none.
+func percentile(sorted []time.Duration, q float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(q*float64(len(sorted)))) - 1
+	return sorted[min(max(rank, 0), len(sorted)-1)]
+}
@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+func sleepMiddleware(d time.Duration) ginji.Middleware {
+	return func(c *ginji.Context) error {
+		time.Sleep(d)
+		return c.Next()
+	}
+}
+
+func TestChainProfiler(t *testing.T) {
+	metrics := NewMetrics(DefaultMetricsConfig())
+	profiler := NewChainProfiler(ChainProfilerConfig{Metrics: metrics})
+
+	app := ginji.New()
+	app.Use(profiler.Wrap("outer", sleepMiddleware(10*time.Millisecond)))
+	app.Use(profiler.Wrap("inner", sleepMiddleware(2*time.Millisecond)))
+	app.Get("/", profiler.WrapHandler("handler", func(c *ginji.Context) error {
+		time.Sleep(40 * time.Millisecond)
+		return c.Text(ginji.StatusOK, "ok")
+	}))
+	app.Get("/stats", profiler.Handler())
+
+	for range 3 {
+		w := ginji.PerformRequest(app, "GET", "/", nil)
+		ginji.AssertBody(t, w, "ok")
+	}
+
+	stats := profiler.Stats().(map[string]MiddlewareTimingStats)
+	duration := func(name string) time.Duration {
+		t.Helper()
+		d, err := time.ParseDuration(stats[name].P50)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	if n := stats["outer"].Count; n != 3 {
+		t.Errorf("Expected 3 samples, got %d", n)
+	}
+	// Each middleware is charged its own time, not that of the chain after it
+	if d := duration("outer"); d < 10*time.Millisecond || d >= 40*time.Millisecond {
+		t.Errorf("Expected outer to take about 10ms, got %v", d)
+	}
+	if d := duration("inner"); d < 2*time.Millisecond || d >= 40*time.Millisecond {
+		t.Errorf("Expected inner to take about 2ms, got %v", d)
+	}
+	if d := duration("handler"); d < 40*time.Millisecond {
+		t.Errorf("Expected handler to take about 40ms, got %v", d)
+	}
+
+	var buf bytes.Buffer
+	metrics.WriteTo(&buf)
+	if !strings.Contains(buf.String(), `http_middleware_duration_seconds_count{middleware="inner"} 3`) {
+		t.Errorf("Expected the duration histogram in %s", buf.String())
+	}
+
+	w := ginji.PerformRequest(app, "GET", "/stats", nil)
+	var served map[string]MiddlewareTimingStats
+	if err := json.Unmarshal(w.Body.Bytes(), &served); err != nil {
+		t.Fatal(err)
+	}
+	if served["handler"].Count != 3 {
+		t.Errorf("Expected the handler stats, got %+v", served)
+	}
+
+	profiler.Reset()
+	if n := profiler.Stats().(map[string]MiddlewareTimingStats)["outer"].Count; n != 0 {
+		t.Errorf("Expected no samples after Reset, got %d", n)
+	}
+}
+
+func TestChainProfilerUnwrappedMiddleware(t *testing.T) {
+	profiler := NewChainProfiler(ChainProfilerConfig{})
+
+	app := ginji.New()
+	app.Use(profiler.Wrap("outer", func(c *ginji.Context) error { return c.Next() }))
+	app.Use(sleepMiddleware(20 * time.Millisecond))
+	app.Get("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+	ginji.PerformRequest(app, "GET", "/", nil)
+
+	stats := profiler.Stats().(map[string]MiddlewareTimingStats)
+	if d, _ := time.ParseDuration(stats["outer"].P50); d < 20*time.Millisecond {
+		t.Errorf("Expected unwrapped middleware to count toward outer, got %v", d)
+	}
+}
+
+func TestChainProfilerSampleWindow(t *testing.T) {
+	profiler := NewChainProfiler(ChainProfilerConfig{SampleSize: 2})
+	timings := profiler.timingsFor("mw")
+	for _, d := range []time.Duration{time.Second, time.Millisecond, 2 * time.Millisecond} {
+		profiler.record("mw", timings, d)
+	}
+
+	stats := timings.stats()
+	if stats.Count != 3 || stats.Max != "2ms" || stats.P50 != "1ms" {
+		t.Errorf("Expected only the last 2 samples in percentiles, got %+v", stats)
+	}
+	if stats.Total != "1.003s" {
+		t.Errorf("Expected the total of all samples, got %s", stats.Total)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	samples := make([]time.Duration, 100)
+	for i := range samples {
+		samples[i] = time.Duration(i+1) * time.Millisecond
+	}
+	if p := percentile(samples, 0.5); p != 50*time.Millisecond {
+		t.Errorf("Expected p50 of 50ms, got %v", p)
+	}
+	if p := percentile(samples, 0.99); p != 99*time.Millisecond {
+		t.Errorf("Expected p99 of 99ms, got %v", p)
+	}
+	if p := percentile(nil, 0.5); p != 0 {
+		t.Errorf("Expected 0 without samples, got %v", p)
+	}
+}
@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// ChaosConfig defines the configuration for fault injection middleware.
+type ChaosConfig struct {
+	// Enabled turns fault injection on. Chaos does nothing unless set.
+	Enabled bool
+
+	// OptInHeader, if set, limits faults to requests that carry this
+	// header with the value "1" or "true", so only test traffic is affected.
+	OptInHeader string
+
+	// Match selects the requests eligible for faults. Default: all requests.
+	Match func(*ginji.Context) bool
+
+	// Percentage of eligible requests that are affected (0-100).
+	// Default: 100
+	Percentage float64
+
+	// Latency is added to affected requests, plus up to LatencyJitter.
+	Latency       time.Duration
+	LatencyJitter time.Duration
+
+	// ErrorPercentage of affected requests fail with one of ErrorStatusCodes.
+	ErrorPercentage float64
+
+	// ErrorStatusCodes are the status codes used for injected errors.
+	// Default: 500, 502, 503
+	ErrorStatusCodes []int
+
+	// DropPercentage of affected requests have their connection closed
+	// without a response.
+	DropPercentage float64
+
+	// TruncatePercentage of affected requests have their response body cut
+	// in half while advertising the full Content-Length.
+	TruncatePercentage float64
+
+	// SkipFunc allows skipping fault injection for certain requests.
+	SkipFunc Skipper
+}
+
+// DefaultChaosConfig returns default chaos configuration. Faults are disabled.
+func DefaultChaosConfig() ChaosConfig {
+	return ChaosConfig{
+		Percentage:       100,
+		ErrorStatusCodes: []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable},
+	}
+}
+
+// ChaosWithConfig returns fault injection middleware for resilience testing.
+func ChaosWithConfig(config ChaosConfig) ginji.Middleware {
+	defaults := DefaultChaosConfig()
+	if config.Percentage == 0 {
+		config.Percentage = defaults.Percentage
+	}
+	if len(config.ErrorStatusCodes) == 0 {
+		config.ErrorStatusCodes = defaults.ErrorStatusCodes
+	}
+
+	return func(c *ginji.Context) error {
+		if !config.Enabled {
+			return c.Next()
+		}
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+		if config.OptInHeader != "" {
+			if v := c.Header(config.OptInHeader); v != "1" && v != "true" {
+				return c.Next()
+			}
+		}
+		if config.Match != nil && !config.Match(c) {
+			return c.Next()
+		}
+		if !chaosRoll(config.Percentage) {
+			return c.Next()
+		}
+
+		c.SetHeader("X-Chaos-Injected", "true")
+
+		if delay := config.Latency; delay > 0 || config.LatencyJitter > 0 {
+			if config.LatencyJitter > 0 {
+				delay += rand.N(config.LatencyJitter)
+			}
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-c.Req.Context().Done():
+				timer.Stop()
+				c.Abort()
+				return nil
+			}
+		}
+
+		switch {
+		case chaosRoll(config.DropPercentage):
+			c.Abort()
+			chaosDropConnection(c)
+			return nil
+
+		case chaosRoll(config.ErrorPercentage):
+			status := config.ErrorStatusCodes[rand.IntN(len(config.ErrorStatusCodes))]
+			c.AbortWithStatusJSON(status, ginji.H{
+				"error": "Injected fault",
+			})
+			return nil
+
+		case chaosRoll(config.TruncatePercentage):
+			originalRes := c.Res
+			buffered := newBufferedResponseWriter(originalRes)
+			c.Res = buffered
+			err := c.Next()
+			c.Res = originalRes
+
+			body := buffered.buf.Bytes()
+			for k, v := range buffered.header {
+				originalRes.Header()[k] = v
+			}
+			originalRes.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			originalRes.WriteHeader(buffered.status)
+			_, _ = originalRes.Write(body[:len(body)/2])
+
+			// net/http closes the connection when fewer bytes than the
+			// advertised Content-Length are written
+			return err
+		}
+
+		return c.Next()
+	}
+}
+
+// chaosRoll returns true with the given percentage chance.
+func chaosRoll(percentage float64) bool {
+	return percentage > 0 && rand.Float64()*100 < percentage
+}
+
+// chaosDropConnection closes the client connection without responding.
+func chaosDropConnection(c *ginji.Context) {
+	conn, _, err := http.NewResponseController(baseResponseWriter(c.Res)).Hijack()
+	if err != nil {
+		// Let net/http abort the response instead
+		panic(http.ErrAbortHandler)
+	}
+	_ = conn.Close()
+}
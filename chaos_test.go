@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+func TestChaosDisabledByDefault(t *testing.T) {
+	app := ginji.New()
+	app.Use(ChaosWithConfig(ChaosConfig{ErrorPercentage: 100}))
+	app.Get("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, strings.Repeat("a", 100))
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+}
+
+func TestChaosErrorsAndLatency(t *testing.T) {
+	app := ginji.New()
+	app.Use(ChaosWithConfig(ChaosConfig{
+		Enabled:          true,
+		Latency:          20 * time.Millisecond,
+		ErrorPercentage:  100,
+		ErrorStatusCodes: []int{ginji.StatusServiceUnavailable},
+	}))
+	app.Get("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, strings.Repeat("a", 100))
+	})
+
+	start := time.Now()
+	w := ginji.PerformRequest(app, "GET", "/", nil)
+
+	ginji.AssertStatus(t, w, ginji.StatusServiceUnavailable)
+	ginji.AssertHeader(t, w, "X-Chaos-Injected", "true")
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("Expected injected latency, took %v", elapsed)
+	}
+}
+
+func TestChaosOptInHeader(t *testing.T) {
+	app := ginji.New()
+	app.Use(ChaosWithConfig(ChaosConfig{
+		Enabled:         true,
+		OptInHeader:     "X-Chaos",
+		ErrorPercentage: 100,
+	}))
+	app.Get("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, strings.Repeat("a", 100))
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Chaos", "true")
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	if rec.Code < 500 {
+		t.Errorf("Expected injected error for opted-in request, got %d", rec.Code)
+	}
+}
+
+func TestChaosDropAndTruncate(t *testing.T) {
+	handler := func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, strings.Repeat("a", 100))
+	}
+
+	dropApp := ginji.New()
+	dropApp.Use(ChaosWithConfig(ChaosConfig{Enabled: true, DropPercentage: 100}))
+	dropApp.Get("/", handler)
+	drop := httptest.NewServer(dropApp)
+	defer drop.Close()
+
+	if resp, err := http.Get(drop.URL); err == nil {
+		_ = resp.Body.Close()
+		t.Error("Expected dropped connection to fail the request")
+	}
+
+	truncateApp := ginji.New()
+	truncateApp.Use(ChaosWithConfig(ChaosConfig{Enabled: true, TruncatePercentage: 100}))
+	truncateApp.Get("/", handler)
+	truncated := httptest.NewServer(truncateApp)
+	defer truncated.Close()
+
+	resp, err := http.Get(truncated.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err == nil {
+		t.Error("Expected truncated body to produce a read error")
+	}
+	if len(body) != 50 {
+		t.Errorf("Expected 50 of 100 bytes, got %d", len(body))
+	}
+}
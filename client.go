@@ -0,0 +1,225 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ClientMiddleware wraps an http.RoundTripper, the client-side counterpart
+// of ginji.Middleware.
+type ClientMiddleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc adapts a function to the http.RoundTripper interface.
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip calls f(req).
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// ErrClientRateLimited is returned by ClientRateLimit when a call is over
+// the limit.
+var ErrClientRateLimited = errors.New("client: rate limit exceeded")
+
+// ChainTransport returns base wrapped in middleware, so that outgoing calls
+// get the same treatment as incoming requests. The first middleware is the
+// outermost, as with app.Use:
+//
+//	client := &http.Client{Transport: middleware.ChainTransport(nil,
+//		middleware.ClientRequestID(),
+//		middleware.ClientLogger(logger),
+//		middleware.ClientRetry(middleware.DefaultClientRetryConfig()),
+//		breaker.Middleware(),
+//	)}
+//
+// A nil base uses http.DefaultTransport.
+func ChainTransport(base http.RoundTripper, middleware ...ClientMiddleware) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	for i := len(middleware) - 1; i >= 0; i-- {
+		base = middleware[i](base)
+	}
+	return base
+}
+
+// ClientRequestID returns client middleware that sends the ID of the
+// incoming request, taken from the outgoing request's context, in the
+// X-Request-ID header. Create outgoing requests with c.Req.Context().
+func ClientRequestID() ClientMiddleware {
+	return ClientRequestIDWithHeader("X-Request-ID")
+}
+
+// ClientRequestIDWithHeader is ClientRequestID with a custom header.
+func ClientRequestIDWithHeader(header string) ClientMiddleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			id, _ := req.Context().Value(requestIDContextKey).(string)
+			if id == "" || req.Header.Get(header) != "" {
+				return next.RoundTrip(req)
+			}
+			// RoundTrippers must not modify the request
+			req = req.Clone(req.Context())
+			req.Header.Set(header, id)
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// ClientBudget returns client middleware that sends the remaining budget
+// of the request context, as BudgetTransport does.
+func ClientBudget() ClientMiddleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return &BudgetTransport{Base: next}
+	}
+}
+
+// ClientLogger returns client middleware that logs every call with its
+// method, host, path, status, latency and the request ID of the incoming
+// request. Failed calls are logged at Error level and 5xx responses at
+// Warn. A nil logger uses slog.Default.
+func ClientLogger(logger *slog.Logger) ClientMiddleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			latency := time.Since(start)
+
+			l := logger
+			if l == nil {
+				l = slog.Default()
+			}
+			attrs := []slog.Attr{
+				slog.String("method", req.Method),
+				slog.String("host", req.URL.Host),
+				slog.String("path", req.URL.Path),
+				slog.Duration("latency", latency),
+			}
+			if id, ok := req.Context().Value(requestIDContextKey).(string); ok && id != "" {
+				attrs = append(attrs, slog.String("request_id", id))
+			}
+
+			level, message := slog.LevelInfo, "Outbound request"
+			switch {
+			case err != nil:
+				level, message = slog.LevelError, "Outbound request failed"
+				attrs = append(attrs, slog.String("error", err.Error()))
+			case resp.StatusCode >= 500:
+				level = slog.LevelWarn
+			}
+			if resp != nil {
+				attrs = append(attrs, slog.Int("status", resp.StatusCode))
+			}
+			l.LogAttrs(req.Context(), level, message, attrs...)
+			return resp, err
+		})
+	}
+}
+
+// ClientMetrics returns client middleware that records the
+// client_requests_total counter and client_request_duration_seconds
+// histogram, labelled by method and host, in m. Failed calls have the
+// status "error".
+func ClientMetrics(m *Metrics) ClientMiddleware {
+	m.Describe("client_requests_total", MetricCounter, "Total number of outbound HTTP requests.")
+	m.Describe("client_request_duration_seconds", MetricHistogram, "Outbound HTTP request latency in seconds.")
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+
+			status := "error"
+			if err == nil {
+				status = strconv.Itoa(resp.StatusCode)
+			}
+			labels := []string{"method", req.Method, "host", req.URL.Host}
+			m.Inc("client_requests_total", append(labels, "status", status)...)
+			m.Observe("client_request_duration_seconds", time.Since(start).Seconds(), labels...)
+			return resp, err
+		})
+	}
+}
+
+// ClientRateLimitConfig defines the configuration for outbound rate
+// limiting.
+type ClientRateLimitConfig struct {
+	// Limit is the number of calls allowed per window. Required.
+	Limit RouteLimit
+
+	// Limiter holds the buckets. Share a limiter with other rate limits to
+	// close it in one place.
+	// Default: a private limiter
+	Limiter *Limiter
+
+	// KeyFunc returns the bucket of a call.
+	// Default: the host
+	KeyFunc func(*http.Request) string
+
+	// Wait delays calls over the limit until the window resets, or the
+	// request context ends, instead of failing them.
+	// Default: false
+	Wait bool
+}
+
+// ClientRateLimit returns client middleware that limits the calls made to
+// each host, e.g. to stay within a third-party API's quota. Calls over the
+// limit fail with ErrClientRateLimited. It panics if Limit is not set.
+func ClientRateLimit(config ClientRateLimitConfig) ClientMiddleware {
+	if config.Limit.Max <= 0 || config.Limit.Window <= 0 {
+		panic("ClientRateLimit: Limit is required")
+	}
+	if config.Limiter == nil {
+		config.Limiter = NewLimiter(DefaultRateLimiterConfig())
+	}
+	if config.KeyFunc == nil {
+		config.KeyFunc = func(req *http.Request) string { return req.URL.Host }
+	}
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			key := "client|" + config.KeyFunc(req)
+			for {
+				allowed, _, resetTime := config.Limiter.Take(key, config.Limit, 1)
+				if allowed {
+					return next.RoundTrip(req)
+				}
+				if !config.Wait {
+					closeRequestBody(req)
+					return nil, ErrClientRateLimited
+				}
+				if err := sleepContext(req.Context(), time.Until(resetTime)); err != nil {
+					closeRequestBody(req)
+					return nil, err
+				}
+			}
+		})
+	}
+}
+
+// sleepContext waits for d or until ctx ends.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// closeRequestBody closes the body of a request that will not be sent, as
+// RoundTrippers must.
+func closeRequestBody(req *http.Request) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+}
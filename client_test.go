@@ -0,0 +1,175 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// stubTransport responds with status to every call and records the calls.
+type stubTransport struct {
+	status int
+	err    error
+	calls  []*http.Request
+}
+
+func (t *stubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.calls = append(t.calls, req)
+	if req.Body != nil {
+		_, _ = io.Copy(io.Discard, req.Body)
+		_ = req.Body.Close()
+	}
+	if t.err != nil {
+		return nil, t.err
+	}
+	return &http.Response{
+		StatusCode: t.status,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader("ok")),
+		Request:    req,
+	}, nil
+}
+
+func TestChainTransportOrder(t *testing.T) {
+	var order []string
+	mark := func(name string) ClientMiddleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				order = append(order, name)
+				return next.RoundTrip(req)
+			})
+		}
+	}
+
+	transport := ChainTransport(&stubTransport{status: 200}, mark("first"), mark("second"))
+	req := httptest.NewRequest("GET", "http://api.example.com/", nil)
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(order, ",") != "first,second" {
+		t.Errorf("Expected first,second, got %v", order)
+	}
+}
+
+func TestClientRequestID(t *testing.T) {
+	stub := &stubTransport{status: 200}
+	client := &http.Client{Transport: ChainTransport(stub, ClientRequestID())}
+
+	app := ginji.New()
+	app.Use(RequestID())
+	app.Get("/", func(c *ginji.Context) error {
+		req, _ := http.NewRequestWithContext(c.Req.Context(), "GET", "http://api.example.com/", nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if req.Header.Get("X-Request-ID") != "" {
+			t.Error("Expected the original request to be unchanged")
+		}
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	ginji.NewRequest(app, "GET", "/").Header("X-Request-ID", "req-42").Do()
+
+	if len(stub.calls) != 1 || stub.calls[0].Header.Get("X-Request-ID") != "req-42" {
+		t.Errorf("Expected the request ID to be propagated")
+	}
+}
+
+func TestClientLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	transport := ChainTransport(&stubTransport{status: 503}, ClientLogger(logger))
+	req := httptest.NewRequest("GET", "http://api.example.com/users", nil)
+	req = req.WithContext(context.WithValue(req.Context(), requestIDContextKey, "req-7"))
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{`"level":"WARN"`, `"host":"api.example.com"`, `"path":"/users"`, `"status":503`, `"request_id":"req-7"`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected %s in %s", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	transport = ChainTransport(&stubTransport{err: errors.New("connection refused")}, ClientLogger(logger))
+	_, _ = transport.RoundTrip(httptest.NewRequest("GET", "http://api.example.com/", nil))
+	if !strings.Contains(buf.String(), `"level":"ERROR"`) || !strings.Contains(buf.String(), "connection refused") {
+		t.Errorf("Expected an error entry, got %s", buf.String())
+	}
+}
+
+func TestClientMetrics(t *testing.T) {
+	metrics := NewMetrics(DefaultMetricsConfig())
+	transport := ChainTransport(&stubTransport{status: 200}, ClientMetrics(metrics))
+
+	for range 2 {
+		if _, err := transport.RoundTrip(httptest.NewRequest("GET", "http://api.example.com/", nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if v := metrics.Value("client_requests_total", "method", "GET", "host", "api.example.com", "status", "200"); v != 2 {
+		t.Errorf("Expected 2 requests, got %v", v)
+	}
+
+	var buf bytes.Buffer
+	metrics.WriteTo(&buf)
+	if !strings.Contains(buf.String(), "client_request_duration_seconds_count") {
+		t.Errorf("Expected the duration histogram, got %s", buf.String())
+	}
+}
+
+func TestClientRateLimit(t *testing.T) {
+	stub := &stubTransport{status: 200}
+	transport := ChainTransport(stub, ClientRateLimit(ClientRateLimitConfig{
+		Limit: RouteLimit{Max: 2, Window: time.Minute},
+	}))
+
+	for i := range 3 {
+		_, err := transport.RoundTrip(httptest.NewRequest("GET", "http://api.example.com/", nil))
+		if i < 2 && err != nil {
+			t.Fatalf("Expected call %d to pass, got %v", i, err)
+		}
+		if i == 2 && !errors.Is(err, ErrClientRateLimited) {
+			t.Errorf("Expected ErrClientRateLimited, got %v", err)
+		}
+	}
+
+	// Other hosts have their own bucket
+	if _, err := transport.RoundTrip(httptest.NewRequest("GET", "http://other.example.com/", nil)); err != nil {
+		t.Errorf("Expected another host to pass, got %v", err)
+	}
+	if len(stub.calls) != 3 {
+		t.Errorf("Expected 3 calls sent, got %d", len(stub.calls))
+	}
+}
+
+func TestClientRateLimitWait(t *testing.T) {
+	transport := ChainTransport(&stubTransport{status: 200}, ClientRateLimit(ClientRateLimitConfig{
+		Limit: RouteLimit{Max: 1, Window: time.Minute},
+		Wait:  true,
+	}))
+
+	if _, err := transport.RoundTrip(httptest.NewRequest("GET", "http://api.example.com/", nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest("GET", "http://api.example.com/", nil).WithContext(ctx)
+	if _, err := transport.RoundTrip(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the wait to end with the context, got %v", err)
+	}
+}
@@ -0,0 +1,271 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by a CircuitBreaker for calls to a host whose
+// circuit is open.
+var ErrCircuitOpen = errors.New("client: circuit breaker is open")
+
+// Circuit breaker states.
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+)
+
+// CircuitBreakerConfig defines the configuration for outbound circuit
+// breakers.
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures that opens
+	// the circuit of a host.
+	// Default: 5
+	FailureThreshold int
+
+	// Cooldown is how long a circuit stays open before a probe call is let
+	// through.
+	// Default: 30 seconds
+	Cooldown time.Duration
+
+	// IsFailure reports whether a call failed.
+	// Default: a transport error or a 5xx status
+	IsFailure func(resp *http.Response, err error) bool
+
+	// KeyFunc returns the circuit of a call.
+	// Default: the host
+	KeyFunc func(*http.Request) string
+
+	// OnStateChange is called when a circuit changes state. It must not
+	// block.
+	OnStateChange func(key, from, to string)
+}
+
+// DefaultCircuitBreakerConfig returns default circuit breaker configuration.
+func DefaultCircuitBreakerConfig() CircuitBreakerConfig {
+	return CircuitBreakerConfig{
+		FailureThreshold: 5,
+		Cooldown:         30 * time.Second,
+		IsFailure: func(resp *http.Response, err error) bool {
+			return err != nil || resp.StatusCode >= 500
+		},
+		KeyFunc: func(req *http.Request) string { return req.URL.Host },
+	}
+}
+
+// CircuitStats is the state of one circuit.
+type CircuitStats struct {
+	// State is CircuitClosed, CircuitOpen or CircuitHalfOpen.
+	State string `json:"state"`
+
+	// Failures is the number of consecutive failures.
+	Failures int `json:"failures"`
+
+	// OpenedAt is when the circuit last opened.
+	OpenedAt *time.Time `json:"openedAt,omitempty"`
+}
+
+// circuit is the state of the calls to one host.
+type circuit struct {
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// CircuitBreaker stops calling a host that keeps failing, so that callers
+// fail fast with ErrCircuitOpen instead of piling up on a dead dependency.
+// After the cooldown a single probe call is let through: its success
+// closes the circuit, its failure opens it again.
+//
+//	breaker := middleware.NewCircuitBreaker(middleware.DefaultCircuitBreakerConfig())
+//	client := &http.Client{Transport: middleware.ChainTransport(nil, breaker.Middleware())}
+//
+// CircuitBreaker implements StatsProvider and HealthReporter.
+type CircuitBreaker struct {
+	config CircuitBreakerConfig
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+// NewCircuitBreaker creates a circuit breaker with the given configuration.
+func NewCircuitBreaker(config CircuitBreakerConfig) *CircuitBreaker {
+	defaults := DefaultCircuitBreakerConfig()
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = defaults.FailureThreshold
+	}
+	if config.Cooldown <= 0 {
+		config.Cooldown = defaults.Cooldown
+	}
+	if config.IsFailure == nil {
+		config.IsFailure = defaults.IsFailure
+	}
+	if config.KeyFunc == nil {
+		config.KeyFunc = defaults.KeyFunc
+	}
+	return &CircuitBreaker{config: config, circuits: make(map[string]*circuit)}
+}
+
+// Middleware returns client middleware that applies the breaker.
+func (b *CircuitBreaker) Middleware() ClientMiddleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			key := b.config.KeyFunc(req)
+			allowed, probe := b.allow(key)
+			if !allowed {
+				closeRequestBody(req)
+				return nil, ErrCircuitOpen
+			}
+
+			resp, err := next.RoundTrip(req)
+			// A call canceled by its caller says nothing about the host
+			if err != nil && req.Context().Err() != nil {
+				b.done(key, probe, false, true)
+				return resp, err
+			}
+			b.done(key, probe, b.config.IsFailure(resp, err), false)
+			return resp, err
+		})
+	}
+}
+
+// State returns the state of the circuit of key.
+func (b *CircuitBreaker) State(key string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if c, ok := b.circuits[key]; ok {
+		return c.state
+	}
+	return CircuitClosed
+}
+
+// Stats implements StatsProvider, reporting each circuit by key.
+func (b *CircuitBreaker) Stats() any {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stats := make(map[string]CircuitStats, len(b.circuits))
+	for key, c := range b.circuits {
+		s := CircuitStats{State: c.state, Failures: c.failures}
+		if !c.openedAt.IsZero() {
+			openedAt := c.openedAt
+			s.OpenedAt = &openedAt
+		}
+		stats[key] = s
+	}
+	return stats
+}
+
+// HealthCheck implements HealthReporter. The breaker is unhealthy while
+// any circuit is open or half-open. Register it as an informational check
+// to report failing dependencies without failing readiness:
+//
+//	config.AddCheck("upstreams", middleware.HealthCheck{
+//		Severity: middleware.HealthInformational,
+//		Checker:  breaker.HealthCheck,
+//	})
+func (b *CircuitBreaker) HealthCheck() error {
+	b.mu.Lock()
+	var open []string
+	for key, c := range b.circuits {
+		if c.state != CircuitClosed {
+			open = append(open, key)
+		}
+	}
+	b.mu.Unlock()
+
+	if len(open) == 0 {
+		return nil
+	}
+	slices.Sort(open)
+	return fmt.Errorf("client: circuit open for %s", strings.Join(open, ", "))
+}
+
+// allow reports whether a call to key may be made, and whether it is the
+// probe of a half-open circuit.
+func (b *CircuitBreaker) allow(key string) (allowed, probe bool) {
+	b.mu.Lock()
+	c, ok := b.circuits[key]
+	if !ok {
+		c = &circuit{state: CircuitClosed}
+		b.circuits[key] = c
+	}
+
+	var from string
+	allowed = true
+	switch c.state {
+	case CircuitOpen:
+		if time.Since(c.openedAt) < b.config.Cooldown {
+			allowed = false
+			break
+		}
+		from = c.state
+		c.state = CircuitHalfOpen
+		c.probing, probe = true, true
+	case CircuitHalfOpen:
+		// Only one probe at a time
+		if c.probing {
+			allowed = false
+			break
+		}
+		c.probing, probe = true, true
+	}
+	b.mu.Unlock()
+
+	if from != "" {
+		b.changed(key, from, CircuitHalfOpen)
+	}
+	return allowed, probe
+}
+
+// done records the outcome of a call to key. Aborted calls release a probe
+// without changing the state, and calls started before the circuit opened
+// do not count.
+func (b *CircuitBreaker) done(key string, probe, failed, aborted bool) {
+	b.mu.Lock()
+	c := b.circuits[key]
+	from := c.state
+	if probe {
+		c.probing = false
+	}
+
+	switch {
+	case aborted:
+	case probe && from == CircuitHalfOpen:
+		if failed {
+			c.state = CircuitOpen
+			c.openedAt = time.Now()
+		} else {
+			c.state = CircuitClosed
+			c.failures = 0
+		}
+	case from != CircuitClosed:
+	case !failed:
+		c.failures = 0
+	default:
+		c.failures++
+		if c.failures >= b.config.FailureThreshold {
+			c.state = CircuitOpen
+			c.openedAt = time.Now()
+		}
+	}
+	to := c.state
+	b.mu.Unlock()
+
+	if to != from {
+		b.changed(key, from, to)
+	}
+}
+
+// changed reports a state change.
+func (b *CircuitBreaker) changed(key, from, to string) {
+	if b.config.OnStateChange != nil {
+		b.config.OnStateChange(key, from, to)
+	}
+}
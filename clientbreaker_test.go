@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var changes []string
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{
+		FailureThreshold: 3,
+		Cooldown:         50 * time.Millisecond,
+		OnStateChange: func(key, from, to string) {
+			changes = append(changes, key+":"+from+">"+to)
+		},
+	})
+	stub := &stubTransport{status: 500}
+	transport := ChainTransport(stub, breaker.Middleware())
+	call := func(host string) error {
+		_, err := transport.RoundTrip(httptest.NewRequest("GET", "http://"+host+"/", nil))
+		return err
+	}
+
+	for range 3 {
+		if err := call("api.example.com"); err != nil {
+			t.Fatalf("Expected the call to be sent, got %v", err)
+		}
+	}
+	if got := breaker.State("api.example.com"); got != CircuitOpen {
+		t.Fatalf("Expected open circuit, got %s", got)
+	}
+	if err := call("api.example.com"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen, got %v", err)
+	}
+	if len(stub.calls) != 3 {
+		t.Errorf("Expected no call while open, got %d", len(stub.calls))
+	}
+
+	// Other hosts are unaffected
+	stub.status = 200
+	if err := call("other.example.com"); err != nil {
+		t.Errorf("Expected another host to pass, got %v", err)
+	}
+
+	// A failed probe opens the circuit again
+	time.Sleep(60 * time.Millisecond)
+	stub.status = 500
+	if err := call("api.example.com"); err != nil {
+		t.Fatalf("Expected a probe, got %v", err)
+	}
+	if got := breaker.State("api.example.com"); got != CircuitOpen {
+		t.Fatalf("Expected the failed probe to open the circuit, got %s", got)
+	}
+
+	// A successful probe closes it
+	time.Sleep(60 * time.Millisecond)
+	stub.status = 200
+	if err := call("api.example.com"); err != nil {
+		t.Fatalf("Expected a probe, got %v", err)
+	}
+	if got := breaker.State("api.example.com"); got != CircuitClosed {
+		t.Errorf("Expected closed circuit, got %s", got)
+	}
+
+	expected := "api.example.com:closed>open,api.example.com:open>half-open,api.example.com:half-open>open," +
+		"api.example.com:open>half-open,api.example.com:half-open>closed"
+	if strings.Join(changes, ",") != expected {
+		t.Errorf("Unexpected state changes %v", changes)
+	}
+}
+
+func TestCircuitBreakerSingleProbe(t *testing.T) {
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1, Cooldown: time.Millisecond})
+	if allowed, _ := breaker.allow("host"); !allowed {
+		t.Fatal("Expected a closed circuit to allow calls")
+	}
+	breaker.done("host", false, true, false)
+	time.Sleep(5 * time.Millisecond)
+
+	if allowed, probe := breaker.allow("host"); !allowed || !probe {
+		t.Fatal("Expected a probe after the cooldown")
+	}
+	if allowed, _ := breaker.allow("host"); allowed {
+		t.Error("Expected a single probe at a time")
+	}
+}
+
+func TestCircuitBreakerSuccessResets(t *testing.T) {
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 2})
+	stub := &stubTransport{status: 500}
+	transport := ChainTransport(stub, breaker.Middleware())
+	call := func() {
+		_, _ = transport.RoundTrip(httptest.NewRequest("GET", "http://api.example.com/", nil))
+	}
+
+	call()
+	stub.status = 200
+	call()
+	stub.status = 500
+	call()
+	if got := breaker.State("api.example.com"); got != CircuitClosed {
+		t.Errorf("Expected failures to be consecutive, got %s", got)
+	}
+
+	stats := breaker.Stats().(map[string]CircuitStats)
+	if s := stats["api.example.com"]; s.Failures != 1 || s.State != CircuitClosed {
+		t.Errorf("Unexpected stats %+v", s)
+	}
+}
+
+func TestCircuitBreakerIgnoresCanceledCalls(t *testing.T) {
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1})
+	transport := ChainTransport(&stubTransport{err: context.Canceled}, breaker.Middleware())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest("GET", "http://api.example.com/", nil).WithContext(ctx)
+	_, _ = transport.RoundTrip(req)
+
+	if got := breaker.State("api.example.com"); got != CircuitClosed {
+		t.Errorf("Expected canceled calls not to count, got %s", got)
+	}
+}
+
+func TestCircuitBreakerHealthCheck(t *testing.T) {
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1, Cooldown: time.Minute})
+	transport := ChainTransport(&stubTransport{status: 500}, breaker.Middleware())
+
+	config := DefaultHealthCheckConfig()
+	config.AddComponent("upstreams", breaker)
+	app := ginji.New()
+	app.Use(HealthWithConfig(config))
+
+	w := ginji.PerformRequest(app, "GET", "/health/ready", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	ginji.AssertBody(t, w, `"upstreams":"UP"`)
+
+	_, _ = transport.RoundTrip(httptest.NewRequest("GET", "http://api.example.com/", nil))
+
+	w = ginji.PerformRequest(app, "GET", "/health/ready", nil)
+	ginji.AssertStatus(t, w, ginji.StatusServiceUnavailable)
+	ginji.AssertBody(t, w, "DOWN: client: circuit open for api.example.com")
+}
@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// ClientRetryConfig defines the configuration for retrying outbound calls.
+type ClientRetryConfig struct {
+	// MaxRetries is the number of retries after the first attempt.
+	// Default: 2
+	MaxRetries int
+
+	// Backoff is the base delay between attempts. It doubles with every
+	// retry and is jittered.
+	// Default: 100 milliseconds
+	Backoff time.Duration
+
+	// MaxBackoff caps the delay between attempts, including delays asked
+	// for by a Retry-After header.
+	// Default: 2 seconds
+	MaxBackoff time.Duration
+
+	// RetryStatuses are the response statuses that are retried.
+	// Default: 429, 502, 503 and 504
+	RetryStatuses []int
+
+	// ShouldRetry overrides RetryStatuses and the transport error check.
+	// Non-idempotent requests are never retried, whatever it returns.
+	ShouldRetry func(resp *http.Response, err error) bool
+}
+
+// DefaultClientRetryConfig returns default outbound retry configuration.
+func DefaultClientRetryConfig() ClientRetryConfig {
+	return ClientRetryConfig{
+		MaxRetries: 2,
+		Backoff:    100 * time.Millisecond,
+		MaxBackoff: 2 * time.Second,
+		RetryStatuses: []int{
+			http.StatusTooManyRequests,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+	}
+}
+
+// ClientRetry returns client middleware that retries failed calls with
+// jittered exponential backoff. Only idempotent methods, and requests with
+// an Idempotency-Key header, are retried, and only if their body can be
+// replayed with GetBody, as it can for bodies from bytes and strings. A
+// Retry-After header sets the delay. Retries stop when the request context
+// ends.
+func ClientRetry(config ClientRetryConfig) ClientMiddleware {
+	defaults := DefaultClientRetryConfig()
+	if config.MaxRetries <= 0 {
+		config.MaxRetries = defaults.MaxRetries
+	}
+	if config.Backoff <= 0 {
+		config.Backoff = defaults.Backoff
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = defaults.MaxBackoff
+	}
+	if config.RetryStatuses == nil {
+		config.RetryStatuses = defaults.RetryStatuses
+	}
+	if config.ShouldRetry == nil {
+		config.ShouldRetry = func(resp *http.Response, err error) bool {
+			if err != nil {
+				return true
+			}
+			return slices.Contains(config.RetryStatuses, resp.StatusCode)
+		}
+	}
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if !retryableRequest(req) {
+				return next.RoundTrip(req)
+			}
+
+			for attempt := 0; ; attempt++ {
+				if attempt > 0 && req.GetBody != nil {
+					body, err := req.GetBody()
+					if err != nil {
+						return nil, err
+					}
+					req = req.Clone(req.Context())
+					req.Body = body
+				}
+
+				resp, err := next.RoundTrip(req)
+				if attempt >= config.MaxRetries || req.Context().Err() != nil || !config.ShouldRetry(resp, err) {
+					return resp, err
+				}
+
+				delay := clientRetryDelay(config, attempt, resp)
+				if resp != nil {
+					// Drain the body so the connection can be reused
+					_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+					_ = resp.Body.Close()
+				}
+				if err := sleepContext(req.Context(), delay); err != nil {
+					return nil, err
+				}
+			}
+		})
+	}
+}
+
+// retryableRequest reports whether req is idempotent and can be sent again.
+func retryableRequest(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+// clientRetryDelay returns the delay before the retry following attempt:
+// the response's Retry-After, or a full-jitter exponential backoff.
+func clientRetryDelay(config ClientRetryConfig, attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+			if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds >= 0 {
+				return min(time.Duration(seconds)*time.Second, config.MaxBackoff)
+			}
+			if at, err := http.ParseTime(retryAfter); err == nil {
+				return min(time.Until(at), config.MaxBackoff)
+			}
+		}
+	}
+	backoff := min(config.Backoff<<attempt, config.MaxBackoff)
+	return rand.N(backoff) + 1
+}
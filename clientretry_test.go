@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sequenceTransport responds with the given statuses in turn.
+type sequenceTransport struct {
+	statuses []int
+	headers  []http.Header
+	bodies   []string
+}
+
+func (t *sequenceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	n := len(t.bodies)
+	body := ""
+	if req.Body != nil {
+		data, _ := io.ReadAll(req.Body)
+		body = string(data)
+	}
+	t.bodies = append(t.bodies, body)
+
+	status := t.statuses[min(n, len(t.statuses)-1)]
+	if status == 0 {
+		return nil, errors.New("connection reset")
+	}
+	header := make(http.Header)
+	if n < len(t.headers) && t.headers[n] != nil {
+		header = t.headers[n]
+	}
+	return &http.Response{StatusCode: status, Header: header, Body: io.NopCloser(strings.NewReader(""))}, nil
+}
+
+func fastRetry() ClientRetryConfig {
+	config := DefaultClientRetryConfig()
+	config.Backoff = time.Millisecond
+	return config
+}
+
+func TestClientRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		header   string
+		statuses []int
+		attempts int
+		status   int
+	}{
+		{"success", "GET", "", []int{200}, 1, 200},
+		{"retries 503", "GET", "", []int{503, 503, 200}, 3, 200},
+		{"gives up", "GET", "", []int{503}, 3, 503},
+		{"transport error", "GET", "", []int{0, 200}, 2, 200},
+		{"no retry on 500", "GET", "", []int{500, 200}, 1, 500},
+		{"no retry of POST", "POST", "", []int{503, 200}, 1, 503},
+		{"POST with idempotency key", "POST", "key-1", []int{503, 200}, 2, 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seq := &sequenceTransport{statuses: tt.statuses}
+			transport := ChainTransport(seq, ClientRetry(fastRetry()))
+
+			req, _ := http.NewRequest(tt.method, "http://api.example.com/", strings.NewReader("payload"))
+			if tt.header != "" {
+				req.Header.Set("Idempotency-Key", tt.header)
+			}
+			resp, err := transport.RoundTrip(req)
+			if tt.status != 0 && (err != nil || resp.StatusCode != tt.status) {
+				t.Errorf("Expected status %d, got %v, %v", tt.status, resp, err)
+			}
+			if len(seq.bodies) != tt.attempts {
+				t.Errorf("Expected %d attempts, got %d", tt.attempts, len(seq.bodies))
+			}
+			for i, body := range seq.bodies {
+				if body != "payload" {
+					t.Errorf("Expected attempt %d to send the body, got %q", i, body)
+				}
+			}
+		})
+	}
+}
+
+func TestClientRetryUnreplayableBody(t *testing.T) {
+	seq := &sequenceTransport{statuses: []int{503, 200}}
+	transport := ChainTransport(seq, ClientRetry(fastRetry()))
+
+	req := httptest.NewRequest("PUT", "http://api.example.com/", io.NopCloser(strings.NewReader("stream")))
+	req.GetBody = nil
+	resp, _ := transport.RoundTrip(req)
+	if resp.StatusCode != 503 || len(seq.bodies) != 1 {
+		t.Errorf("Expected a single attempt, got %d", len(seq.bodies))
+	}
+}
+
+func TestClientRetryAfter(t *testing.T) {
+	config := fastRetry()
+	config.MaxBackoff = 50 * time.Millisecond
+	seq := &sequenceTransport{
+		statuses: []int{429, 200},
+		headers:  []http.Header{{"Retry-After": []string{"120"}}},
+	}
+	transport := ChainTransport(seq, ClientRetry(config))
+
+	start := time.Now()
+	resp, err := transport.RoundTrip(httptest.NewRequest("GET", "http://api.example.com/", nil))
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("Expected success, got %v, %v", resp, err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond || elapsed > time.Second {
+		t.Errorf("Expected Retry-After capped at MaxBackoff, waited %s", elapsed)
+	}
+}
+
+func TestClientRetryContext(t *testing.T) {
+	config := fastRetry()
+	config.Backoff = time.Second
+	seq := &sequenceTransport{statuses: []int{503}}
+	transport := ChainTransport(seq, ClientRetry(config))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest("GET", "http://api.example.com/", nil).WithContext(ctx)
+	_, err := transport.RoundTrip(req)
+	if len(seq.bodies) == 3 {
+		t.Error("Expected retries to stop with the context")
+	}
+	if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected DeadlineExceeded, got %v", err)
+	}
+}
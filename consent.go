@@ -0,0 +1,254 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// Consent categories. Applications may use their own as well.
+const (
+	// ConsentNecessary covers cookies the site cannot work without, such
+	// as sessions and CSRF tokens. It is always granted.
+	ConsentNecessary = "necessary"
+
+	ConsentPreferences = "preferences"
+	ConsentAnalytics   = "analytics"
+	ConsentExperiments = "experiments"
+	ConsentMarketing   = "marketing"
+)
+
+// ErrNoConsent is returned by GrantConsent when no Consent middleware runs
+// for the request.
+var ErrNoConsent = errors.New("consent: no Consent middleware")
+
+// ConsentConfig defines the configuration for consent middleware.
+type ConsentConfig struct {
+	// CookieName is the cookie holding the granted categories, written by
+	// GrantConsent or by a client-side consent banner.
+	// Default: "consent"
+	CookieName string
+
+	// HeaderName is a request header holding the granted categories, for
+	// API and mobile clients. It takes precedence over the cookie.
+	// Default: "X-Consent"
+	HeaderName string
+
+	// ParseFunc parses the cookie or header value into categories.
+	// Default: a comma-separated list, e.g. "analytics,experiments"
+	ParseFunc func(value string) []string
+
+	// CookieCategories maps the names of cookies set downstream to their
+	// category. Cookies of categories that are not granted are removed
+	// from the response.
+	// Default: {"canary": ConsentExperiments}
+	CookieCategories map[string]string
+
+	// DefaultCategory is the category of cookies missing from
+	// CookieCategories. Set it to e.g. ConsentAnalytics to block every
+	// cookie that is not explicitly listed as necessary.
+	// Default: ConsentNecessary
+	DefaultCategory string
+
+	// CookieMaxAge is how long the cookie written by GrantConsent is kept.
+	// Default: 180 days
+	CookieMaxAge time.Duration
+
+	// CookieSecure sets the Secure flag on the cookie written by
+	// GrantConsent.
+	// Default: false
+	CookieSecure bool
+
+	// SkipFunc allows skipping consent gating for certain requests.
+	SkipFunc Skipper
+}
+
+// DefaultConsentConfig returns default consent configuration.
+func DefaultConsentConfig() ConsentConfig {
+	return ConsentConfig{
+		CookieName:       "consent",
+		HeaderName:       "X-Consent",
+		ParseFunc:        parseConsentList,
+		CookieCategories: map[string]string{"canary": ConsentExperiments},
+		DefaultCategory:  ConsentNecessary,
+		CookieMaxAge:     180 * 24 * time.Hour,
+	}
+}
+
+// ConsentDecision is the consent of the client making a request.
+type ConsentDecision struct {
+	// Given reports whether the client made a choice. Until then only
+	// necessary cookies are allowed.
+	Given bool
+
+	// Categories are the granted categories.
+	Categories []string
+}
+
+// Granted reports whether category is granted. ConsentNecessary always
+// is.
+func (c ConsentDecision) Granted(category string) bool {
+	return category == ConsentNecessary || slices.Contains(c.Categories, category)
+}
+
+// consentState is the per-request state of the Consent middleware.
+type consentState struct {
+	config  *ConsentConfig
+	consent ConsentDecision
+}
+
+// Consent returns consent middleware with default configuration.
+func Consent() ginji.Middleware {
+	return ConsentWithConfig(DefaultConsentConfig())
+}
+
+// ConsentWithConfig returns middleware that reads the client's consent
+// from a cookie or header, exposes it with ConsentFrom and ConsentGranted,
+// and removes the cookies of categories that are not granted from the
+// response, whichever middleware or handler set them. Register it before
+// the middleware setting such cookies:
+//
+//	app.Use(middleware.Consent())
+//	app.Use(canary.Middleware())
+//	app.Post("/consent", func(c *ginji.Context) error {
+//		categories := strings.Split(c.FormValue("categories"), ",")
+//		if err := middleware.GrantConsent(c, categories...); err != nil {
+//			return err
+//		}
+//		return c.Text(ginji.StatusOK, "ok")
+//	})
+//
+// Removing cookies is always allowed, so that withdrawing consent can
+// clear them.
+func ConsentWithConfig(config ConsentConfig) ginji.Middleware {
+	defaults := DefaultConsentConfig()
+	if config.CookieName == "" {
+		config.CookieName = defaults.CookieName
+	}
+	if config.HeaderName == "" {
+		config.HeaderName = defaults.HeaderName
+	}
+	if config.ParseFunc == nil {
+		config.ParseFunc = defaults.ParseFunc
+	}
+	if config.CookieCategories == nil {
+		config.CookieCategories = defaults.CookieCategories
+	}
+	if config.DefaultCategory == "" {
+		config.DefaultCategory = defaults.DefaultCategory
+	}
+	if config.CookieMaxAge == 0 {
+		config.CookieMaxAge = defaults.CookieMaxAge
+	}
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		state := &consentState{config: &config}
+		if value := c.Req.Header.Get(config.HeaderName); value != "" {
+			state.consent = ConsentDecision{Given: true, Categories: config.ParseFunc(value)}
+		} else if cookie, err := c.Req.Cookie(config.CookieName); err == nil {
+			state.consent = ConsentDecision{Given: true, Categories: config.ParseFunc(cookie.Value)}
+		}
+		setContextValue(c, consentContextKey, state)
+
+		rw := WrapResponseWriter(c)
+		rw.Before(func(h http.Header) {
+			filterConsentCookies(h, state)
+		})
+
+		err := c.Next()
+
+		rw.runBeforeHooks()
+		return err
+	}
+}
+
+// filterConsentCookies removes the Set-Cookie headers of categories that
+// are not granted.
+func filterConsentCookies(h http.Header, state *consentState) {
+	values := h.Values("Set-Cookie")
+	if len(values) == 0 {
+		return
+	}
+
+	kept := make([]string, 0, len(values))
+	for _, value := range values {
+		cookie, err := http.ParseSetCookie(value)
+		if err != nil || cookie.Name == state.config.CookieName || cookie.MaxAge < 0 {
+			kept = append(kept, value)
+			continue
+		}
+		category, ok := state.config.CookieCategories[cookie.Name]
+		if !ok {
+			category = state.config.DefaultCategory
+		}
+		if state.consent.Granted(category) {
+			kept = append(kept, value)
+		}
+	}
+
+	if len(kept) == 0 {
+		h.Del("Set-Cookie")
+		return
+	}
+	h["Set-Cookie"] = kept
+}
+
+// parseConsentList parses a comma-separated list of categories.
+func parseConsentList(value string) []string {
+	var categories []string
+	for category := range strings.SplitSeq(value, ",") {
+		if category = strings.TrimSpace(category); category != "" {
+			categories = append(categories, category)
+		}
+	}
+	return categories
+}
+
+// ConsentFrom returns the consent read by the Consent middleware.
+func ConsentFrom(c *ginji.Context) (ConsentDecision, bool) {
+	state, ok := c.Req.Context().Value(consentContextKey).(*consentState)
+	if !ok {
+		return ConsentDecision{}, false
+	}
+	return state.consent, true
+}
+
+// ConsentGranted reports whether category is granted for the request, for
+// code deciding whether to track or personalize. It returns true when no
+// Consent middleware runs, so that middleware calling it work unchanged
+// without one.
+func ConsentGranted(c *ginji.Context, category string) bool {
+	consent, ok := ConsentFrom(c)
+	return !ok || consent.Granted(category)
+}
+
+// GrantConsent records the client's choice: it writes the consent cookie
+// with exactly categories and applies them to the rest of the request, so
+// that cookies set afterwards are let through. Pass no categories to
+// withdraw consent.
+func GrantConsent(c *ginji.Context, categories ...string) error {
+	state, ok := c.Req.Context().Value(consentContextKey).(*consentState)
+	if !ok {
+		return ErrNoConsent
+	}
+	state.consent = ConsentDecision{Given: true, Categories: categories}
+
+	http.SetCookie(c.Res, &http.Cookie{
+		Name:     state.config.CookieName,
+		Value:    strings.Join(categories, ","),
+		Path:     "/",
+		MaxAge:   int(state.config.CookieMaxAge.Seconds()),
+		Secure:   state.config.CookieSecure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
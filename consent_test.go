@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ginjigo/ginji"
+)
+
+// cookieNames returns the names of the cookies set by a response.
+func cookieNames(cookies []*http.Cookie) []string {
+	names := make([]string, len(cookies))
+	for i, cookie := range cookies {
+		names[i] = cookie.Name
+	}
+	return names
+}
+
+func TestConsentSuppressesCookies(t *testing.T) {
+	config := DefaultConsentConfig()
+	config.CookieCategories = map[string]string{"canary": ConsentExperiments, "_ga": ConsentAnalytics}
+	app := ginji.New()
+	app.Use(ConsentWithConfig(config))
+	app.Use(func(c *ginji.Context) error {
+		c.SetCookie(&http.Cookie{Name: "canary", Value: CanaryVariantStable})
+		return c.Next()
+	})
+	app.Get("/", func(c *ginji.Context) error {
+		c.SetCookie(&http.Cookie{Name: "session", Value: "s"})
+		c.SetCookie(&http.Cookie{Name: "_ga", Value: "g"})
+		return c.Text(ginji.StatusOK, "ok")
+	})
+	app.Post("/consent", func(c *ginji.Context) error {
+		if err := GrantConsent(c, strings.Split(c.FormValue("categories"), ",")...); err != nil {
+			return err
+		}
+		c.SetCookie(&http.Cookie{Name: "_ga", Value: "g"})
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	tests := []struct {
+		name   string
+		header string
+		cookie string
+		want   string
+	}{
+		{"no consent", "", "", "session"},
+		{"analytics cookie", "", "analytics", "session,_ga"},
+		{"all header", "analytics, experiments", "", "canary,session,_ga"},
+		{"header wins", "experiments", "analytics", "canary,session"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := ginji.NewRequest(app, "GET", "/")
+			if tt.header != "" {
+				req = req.Header("X-Consent", tt.header)
+			}
+			if tt.cookie != "" {
+				req = req.Cookie(&http.Cookie{Name: "consent", Value: tt.cookie})
+			}
+			w := req.Do()
+			ginji.AssertStatus(t, w, ginji.StatusOK)
+			if got := strings.Join(cookieNames(w.Result().Cookies()), ","); got != tt.want {
+				t.Errorf("Expected cookies %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestConsentDefaultCategory(t *testing.T) {
+	config := DefaultConsentConfig()
+	config.CookieCategories = map[string]string{"session": ConsentNecessary}
+	config.DefaultCategory = ConsentAnalytics
+	app := ginji.New()
+	app.Use(ConsentWithConfig(config))
+	app.Use(func(c *ginji.Context) error {
+		c.SetCookie(&http.Cookie{Name: "canary", Value: CanaryVariantStable})
+		return c.Next()
+	})
+	app.Get("/", func(c *ginji.Context) error {
+		c.SetCookie(&http.Cookie{Name: "session", Value: "s"})
+		c.SetCookie(&http.Cookie{Name: "_ga", Value: "g"})
+		return c.Text(ginji.StatusOK, "ok")
+	})
+	app.Post("/consent", func(c *ginji.Context) error {
+		if err := GrantConsent(c, strings.Split(c.FormValue("categories"), ",")...); err != nil {
+			return err
+		}
+		c.SetCookie(&http.Cookie{Name: "_ga", Value: "g"})
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/", nil)
+	if got := strings.Join(cookieNames(w.Result().Cookies()), ","); got != "session" {
+		t.Errorf("Expected only listed necessary cookies, got %q", got)
+	}
+}
+
+func TestGrantConsent(t *testing.T) {
+	config := DefaultConsentConfig()
+	config.CookieCategories = map[string]string{"_ga": ConsentAnalytics}
+	app := ginji.New()
+	app.Use(ConsentWithConfig(config))
+	app.Use(func(c *ginji.Context) error {
+		c.SetCookie(&http.Cookie{Name: "canary", Value: CanaryVariantStable})
+		return c.Next()
+	})
+	app.Get("/", func(c *ginji.Context) error {
+		c.SetCookie(&http.Cookie{Name: "session", Value: "s"})
+		c.SetCookie(&http.Cookie{Name: "_ga", Value: "g"})
+		return c.Text(ginji.StatusOK, "ok")
+	})
+	app.Post("/consent", func(c *ginji.Context) error {
+		if err := GrantConsent(c, strings.Split(c.FormValue("categories"), ",")...); err != nil {
+			return err
+		}
+		c.SetCookie(&http.Cookie{Name: "_ga", Value: "g"})
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	req := ginji.NewRequest(app, "POST", "/consent").
+		Header("Content-Type", "application/x-www-form-urlencoded").
+		Body(strings.NewReader("categories=analytics"))
+	w := req.Do()
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+
+	cookies := w.Result().Cookies()
+	if got := strings.Join(cookieNames(cookies), ","); got != "canary,consent,_ga" {
+		t.Fatalf("Expected the consent cookie and the newly allowed cookie, got %q", got)
+	}
+	if cookies[1].Value != "analytics" || cookies[1].MaxAge != 180*24*3600 {
+		t.Errorf("Unexpected consent cookie %+v", cookies[1])
+	}
+
+	// Cookies can always be removed
+	h := http.Header{}
+	h.Add("Set-Cookie", (&http.Cookie{Name: "_ga", MaxAge: -1}).String())
+	filterConsentCookies(h, &consentState{config: &config})
+	if len(h.Values("Set-Cookie")) != 1 {
+		t.Error("Expected cookie removal to be let through")
+	}
+}
+
+func TestConsentFrom(t *testing.T) {
+	bare := ginji.New()
+	bare.Get("/", func(c *ginji.Context) error {
+		if _, ok := ConsentFrom(c); ok || !ConsentGranted(c, ConsentAnalytics) {
+			t.Error("Expected everything to be granted without Consent middleware")
+		}
+		if err := GrantConsent(c, ConsentAnalytics); err != ErrNoConsent {
+			t.Errorf("Expected ErrNoConsent, got %v", err)
+		}
+		return c.Text(ginji.StatusOK, "ok")
+	})
+	app := ginji.New()
+	app.Use(Consent())
+	app.Get("/", func(c *ginji.Context) error {
+		consent, ok := ConsentFrom(c)
+		if !ok || !consent.Given || !consent.Granted(ConsentAnalytics) || consent.Granted(ConsentMarketing) {
+			t.Errorf("Unexpected consent %+v", consent)
+		}
+		if !ConsentGranted(c, ConsentNecessary) || ConsentGranted(c, ConsentMarketing) {
+			t.Error("Unexpected ConsentGranted results")
+		}
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	ginji.PerformRequest(bare, "GET", "/", nil)
+	w := ginji.NewRequest(app, "GET", "/").Header("X-Consent", "analytics").Do()
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+}
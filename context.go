@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/ginjigo/ginji"
+)
+
+// contextKey is the type of the well-known keys under which middleware
+// store values in the request context. Being unexported, the keys cannot
+// collide with, or drift from, user-chosen ContextKey strings.
+type contextKey int
+
+const (
+	userContextKey contextKey = iota
+	requestIDContextKey
+	sessionContextKey
+	claimsContextKey
+	routePatternContextKey
+	graphQLContextKey
+	querySpecContextKey
+	loggerContextKey
+	txnContextKey
+	samplingContextKey
+	consentContextKey
+	privacyContextKey
+	eventStreamContextKey
+	webSocketContextKey
+	secureCookieContextKey
+	botResultContextKey
+	geoInfoContextKey
+	userAgentContextKey
+	longPollContextKey
+	canaryContextKey
+	webhookContextKey
+	rawBodyContextKey
+	assetsContextKey
+	rendererContextKey
+	originalMethodContextKey
+	cspNonceContextKey
+)
+
+// Session is the interface of a server-side session.
+type Session interface {
+	// ID returns the session identifier.
+	ID() string
+
+	// Get returns the value stored under key, or nil.
+	Get(key string) any
+
+	// Set stores value under key.
+	Set(key string, value any)
+
+	// Delete removes key from the session.
+	Delete(key string)
+}
+
+// setContextValue stores value in the request context under key.
+func setContextValue(c *ginji.Context, key contextKey, value any) {
+	c.Req = c.Req.WithContext(context.WithValue(c.Req.Context(), key, value))
+	if c.Request != nil {
+		c.Request.Request = c.Req
+	}
+}
+
+// SetUser stores the authenticated user. Authentication middleware call it
+// in addition to storing the user under their configured ContextKey.
+func SetUser(c *ginji.Context, user any) {
+	setContextValue(c, userContextKey, user)
+}
+
+// UserFrom returns the authenticated user set by any of the authentication
+// middleware, falling back to the conventional "user" key.
+func UserFrom(c *ginji.Context) (any, bool) {
+	if user := c.Req.Context().Value(userContextKey); user != nil {
+		return user, true
+	}
+	user, exists := c.Get("user")
+	return user, exists && user != nil
+}
+
+// SetRequestID stores the request ID.
+func SetRequestID(c *ginji.Context, id string) {
+	setContextValue(c, requestIDContextKey, id)
+}
+
+// RequestIDFrom returns the request ID set by the RequestID middleware.
+func RequestIDFrom(c *ginji.Context) string {
+	if id, ok := c.Req.Context().Value(requestIDContextKey).(string); ok {
+		return id
+	}
+	return c.GetString("request_id")
+}
+
+// SetSession stores the current session. No middleware in this package
+// loads sessions, so the application's session middleware has to call it
+// for SessionAuth, StepUp, Form and Render to find the session:
+//
+//	app.Use(func(c *ginji.Context) error {
+//		middleware.SetSession(c, store.Load(c.Req))
+//		return c.Next()
+//	})
+func SetSession(c *ginji.Context, session Session) {
+	setContextValue(c, sessionContextKey, session)
+}
+
+// SessionFrom returns the session stored with SetSession, or under the
+// "session" key.
+func SessionFrom(c *ginji.Context) (Session, bool) {
+	if session, ok := c.Req.Context().Value(sessionContextKey).(Session); ok {
+		return session, true
+	}
+	val, _ := c.Get("session")
+	session, ok := val.(Session)
+	return session, ok
+}
+
+// SetClaims stores verified token claims. No middleware in this package
+// verifies tokens, so the application's JWT middleware has to call it for
+// Scope, Authorize, StepUp, ByJWTClaim and the request logger to read the
+// claims.
+func SetClaims(c *ginji.Context, claims map[string]any) {
+	setContextValue(c, claimsContextKey, claims)
+}
+
+// ClaimsFrom returns the claims stored with SetClaims, or under the
+// "claims" key.
+func ClaimsFrom(c *ginji.Context) (map[string]any, bool) {
+	if claims, ok := c.Req.Context().Value(claimsContextKey).(map[string]any); ok {
+		return claims, true
+	}
+	val, _ := c.Get("claims")
+	claims, ok := val.(map[string]any)
+	return claims, ok
+}
@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// mapSession is a minimal Session for tests.
+type mapSession map[string]any
+
+func (s mapSession) ID() string                { return "test-session" }
+func (s mapSession) Get(key string) any        { return s[key] }
+func (s mapSession) Set(key string, value any) { s[key] = value }
+func (s mapSession) Delete(key string)         { delete(s, key) }
+
+func TestTypedAccessorsIgnoreContextKeyDrift(t *testing.T) {
+	app := ginji.New()
+	app.Use(RequestIDWithConfig(RequestIDConfig{ContextKey: "rid"}))
+	app.Use(Timeout(time.Second))
+	app.Use(BasicAuthWithConfig(BasicAuthConfig{
+		Users:      map[string]string{"alice": "secret"},
+		ContextKey: "principal",
+	}))
+	app.Use(UserAgentWithConfig(UserAgentConfig{ContextKey: "ua"}))
+	app.Use(BufferBodyWithConfig(BufferBodyConfig{ContextKey: "body"}))
+	app.Use(GeoIPWithConfig(GeoIPConfig{
+		Resolver: GeoResolverFunc(func(net.IP) (GeoInfo, error) {
+			return GeoInfo{Country: "DE"}, nil
+		}),
+		ContextKey: "location",
+	}))
+
+	app.Post("/", func(c *ginji.Context) error {
+		user, ok := UserFrom(c)
+		if !ok || user != "alice" {
+			t.Errorf("Expected user alice, got %v", user)
+		}
+		if RequestIDFrom(c) == "" {
+			t.Error("Expected request ID")
+		}
+		if ua, ok := GetUserAgent(c); !ok || ua.Browser != "Firefox" {
+			t.Errorf("Expected Firefox user agent, got %+v", ua)
+		}
+		if body := RawBody(c); string(body) != "hello" {
+			t.Errorf("Expected raw body, got %q", body)
+		}
+		if info, ok := GetGeoInfo(c); !ok || info.Country != "DE" {
+			t.Errorf("Expected country DE, got %+v", info)
+		}
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader("hello"))
+	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0")
+	req.SetBasicAuth("alice", "secret")
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+}
+
+func TestSessionAndClaimsAccessors(t *testing.T) {
+	app := ginji.New()
+	app.Use(func(c *ginji.Context) error {
+		SetSession(c, mapSession{"cart": 3})
+		SetClaims(c, map[string]any{"sub": "42"})
+		return c.Next()
+	})
+
+	app.Get("/", func(c *ginji.Context) error {
+		session, ok := SessionFrom(c)
+		if !ok || session.Get("cart") != 3 {
+			t.Errorf("Expected session with cart, got %v", session)
+		}
+		claims, ok := ClaimsFrom(c)
+		if !ok || claims["sub"] != "42" {
+			t.Errorf("Expected claims with sub, got %v", claims)
+		}
+		if _, ok := UserFrom(c); ok {
+			t.Error("Expected no user")
+		}
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	ginji.AssertStatus(t, ginji.PerformRequest(app, "GET", "/", nil), ginji.StatusOK)
+}
+
+// discardWriter is a reusable ResponseWriter for benchmarks and allocation
+// tests.
+type discardWriter struct {
+	header http.Header
+}
+
+func (w *discardWriter) Header() http.Header         { return w.header }
+func (w *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardWriter) WriteHeader(int)             {}
+
+// serveFunc returns a function serving req on a fresh app with mws, reusing
+// the request and writer so that only the app's allocations are counted.
+func serveFunc(req *http.Request, mws ...ginji.Middleware) func() {
+	app := ginji.New()
+	for _, mw := range mws {
+		app.Use(mw)
+	}
+	app.Get("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+	w := &discardWriter{header: make(http.Header)}
+	return func() {
+		clear(w.header)
+		app.ServeHTTP(w, req)
+	}
+}
+
+// middlewareAllocs returns the allocations mw adds to serving req. It skips
+// the test under the race detector.
+func middlewareAllocs(t *testing.T, req *http.Request, mw ginji.Middleware) float64 {
+	t.Helper()
+	if raceEnabled {
+		t.Skip("allocation counts are unreliable with the race detector")
+	}
+	base := testing.AllocsPerRun(100, serveFunc(req))
+	return testing.AllocsPerRun(100, serveFunc(req, mw)) - base
+}
+
+// benchmarkMiddleware benchmarks serving req through mw.
+func benchmarkMiddleware(b *testing.B, req *http.Request, mw ginji.Middleware) {
+	serve := serveFunc(req, mw)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		serve()
+	}
+}
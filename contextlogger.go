@@ -0,0 +1,162 @@
+package middleware
+
+import (
+	"log/slog"
+	"sync"
+
+	"github.com/ginjigo/ginji"
+)
+
+// ContextLoggerConfig defines the configuration for per-request loggers.
+type ContextLoggerConfig struct {
+	// Logger is the logger requests derive from. If nil, uses engine's
+	// logger.
+	Logger *slog.Logger
+
+	// UserFunc returns the user of the request for the "user" attribute.
+	// Default: the authenticated user's ID (see UserFrom)
+	UserFunc func(*ginji.Context) string
+
+	// TenantFunc returns the tenant of the request for the "tenant"
+	// attribute.
+	// Default: the "tenant" or "tenant_id" field of the user, a Tenant()
+	// method of the user, or the "tenant" claim of a token
+	TenantFunc func(*ginji.Context) string
+
+	// Attrs returns additional attributes, computed once per request.
+	Attrs func(*ginji.Context) []slog.Attr
+
+	// SkipFunc allows skipping logger injection for certain requests.
+	SkipFunc Skipper
+}
+
+// DefaultContextLoggerConfig returns default per-request logger
+// configuration.
+func DefaultContextLoggerConfig() ContextLoggerConfig {
+	return ContextLoggerConfig{
+		UserFunc:   auditUser,
+		TenantFunc: defaultLogTenant,
+	}
+}
+
+// ContextLogger returns middleware that injects a per-request logger.
+func ContextLogger() ginji.Middleware {
+	return ContextLoggerWithConfig(DefaultContextLoggerConfig())
+}
+
+// ContextLoggerWithConfig returns middleware that stores a logger carrying
+// the request_id, route, user and tenant of the request, for handlers to
+// retrieve with Log:
+//
+//	app.Use(middleware.RequestID())
+//	app.Use(middleware.ContextLogger())
+//
+//	app.Get("/orders/:id", func(c *ginji.Context) error {
+//		middleware.Log(c).Info("loading order", "order", c.Param("id"))
+//		...
+//	})
+//
+// The user, tenant and route are read when Log is called, so they are
+// present even when authentication runs after ContextLogger.
+func ContextLoggerWithConfig(config ContextLoggerConfig) ginji.Middleware {
+	defaults := DefaultContextLoggerConfig()
+	if config.UserFunc == nil {
+		config.UserFunc = defaults.UserFunc
+	}
+	if config.TenantFunc == nil {
+		config.TenantFunc = defaults.TenantFunc
+	}
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		base := requestLogger(c, config.Logger)
+		if id := RequestIDFrom(c); id != "" {
+			base = base.With(slog.String("request_id", id))
+		}
+		if config.Attrs != nil {
+			if attrs := config.Attrs(c); len(attrs) > 0 {
+				base = slog.New(base.Handler().WithAttrs(attrs))
+			}
+		}
+
+		setContextValue(c, loggerContextKey, &contextLogger{config: &config, base: base})
+		return c.Next()
+	}
+}
+
+// Log returns the logger of the request, set up by ContextLogger. Without
+// ContextLogger, it returns the engine's logger with the request ID.
+func Log(c *ginji.Context) *slog.Logger {
+	if l, ok := c.Req.Context().Value(loggerContextKey).(*contextLogger); ok {
+		return l.logger(c)
+	}
+	logger := requestLogger(c, nil)
+	if id := RequestIDFrom(c); id != "" {
+		logger = logger.With(slog.String("request_id", id))
+	}
+	return logger
+}
+
+// contextLogger is the logger of a request. The derived logger is cached
+// until the route, user or tenant changes.
+type contextLogger struct {
+	config *ContextLoggerConfig
+	base   *slog.Logger
+
+	mu                  sync.Mutex
+	route, user, tenant string
+	cached              *slog.Logger
+}
+
+// logger returns the logger with the current route, user and tenant.
+func (l *contextLogger) logger(c *ginji.Context) *slog.Logger {
+	route := RoutePattern(c)
+	user := l.config.UserFunc(c)
+	tenant := l.config.TenantFunc(c)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.cached != nil && route == l.route && user == l.user && tenant == l.tenant {
+		return l.cached
+	}
+
+	attrs := make([]slog.Attr, 0, 3)
+	attrs = append(attrs, slog.String("route", route))
+	if user != "" {
+		attrs = append(attrs, slog.String("user", user))
+	}
+	if tenant != "" {
+		attrs = append(attrs, slog.String("tenant", tenant))
+	}
+	l.route, l.user, l.tenant = route, user, tenant
+	l.cached = slog.New(l.base.Handler().WithAttrs(attrs))
+	return l.cached
+}
+
+// defaultLogTenant reads the tenant from the authenticated user, as the
+// "tenant" or "tenant_id" field of a map[string]any or from a Tenant()
+// method, and otherwise from the "tenant" claim of a token.
+func defaultLogTenant(c *ginji.Context) string {
+	if user, ok := UserFrom(c); ok {
+		switch u := user.(type) {
+		case interface{ Tenant() string }:
+			return u.Tenant()
+		case map[string]any:
+			for _, field := range []string{"tenant", "tenant_id"} {
+				if tenant, ok := u[field].(string); ok {
+					return tenant
+				}
+			}
+		}
+	}
+	if claims, ok := ClaimsFrom(c); ok {
+		if tenant, ok := claims["tenant"].(string); ok {
+			return tenant
+		}
+	}
+	return ""
+}
@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/ginjigo/ginji"
+)
+
+func TestContextLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	app := ginji.New()
+	app.Use(RequestID())
+	app.Use(ContextLoggerWithConfig(ContextLoggerConfig{
+		Logger: logger,
+		Attrs: func(c *ginji.Context) []slog.Attr {
+			return []slog.Attr{slog.String("service", "orders")}
+		},
+	}))
+	app.Use(func(c *ginji.Context) error {
+		// Authentication runs after the logger is injected
+		SetUser(c, map[string]any{"id": "u1", "tenant": "acme"})
+		return c.Next()
+	})
+	app.Get("/orders/:id", func(c *ginji.Context) error {
+		Log(c).Info("loading order", "order", c.Param("id"))
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	w := ginji.NewRequest(app, "GET", "/orders/42").Header("X-Request-ID", "req-1").Do()
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Failed to parse log entry %q: %v", buf.String(), err)
+	}
+	expected := map[string]any{
+		"msg":        "loading order",
+		"request_id": "req-1",
+		"route":      "/orders/:id",
+		"user":       "u1",
+		"tenant":     "acme",
+		"service":    "orders",
+		"order":      "42",
+	}
+	for key, value := range expected {
+		if entry[key] != value {
+			t.Errorf("Expected %s=%v, got %v", key, value, entry[key])
+		}
+	}
+}
+
+func TestContextLoggerAnonymous(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	app := ginji.New()
+	app.Use(ContextLoggerWithConfig(ContextLoggerConfig{Logger: logger}))
+	app.Get("/", func(c *ginji.Context) error {
+		Log(c).Info("first")
+		Log(c).Info("second")
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	ginji.PerformRequest(app, "GET", "/", nil)
+
+	if strings.Contains(buf.String(), `"user"`) || strings.Contains(buf.String(), `"tenant"`) {
+		t.Errorf("Expected no user or tenant attributes, got %s", buf.String())
+	}
+	if n := strings.Count(buf.String(), `"route":"/"`); n != 2 {
+		t.Errorf("Expected the route on both entries, got %s", buf.String())
+	}
+}
+
+func TestLogWithoutContextLogger(t *testing.T) {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	defer slog.SetDefault(previous)
+
+	app := ginji.New()
+	app.Use(RequestID())
+	app.Get("/", func(c *ginji.Context) error {
+		Log(c).Info("hello")
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	ginji.NewRequest(app, "GET", "/").Header("X-Request-ID", "req-2").Do()
+
+	if !strings.Contains(buf.String(), `"request_id":"req-2"`) {
+		t.Errorf("Expected request_id in log entry, got %s", buf.String())
+	}
+}
+
+func TestDefaultLogTenant(t *testing.T) {
+	app := ginji.New()
+	app.Get("/", func(c *ginji.Context) error {
+		SetClaims(c, map[string]any{"tenant": "globex"})
+		return c.Text(ginji.StatusOK, defaultLogTenant(c))
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/", nil)
+	ginji.AssertBody(t, w, "globex")
+}
@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/ginjigo/ginji"
+)
+
+// CookieRules are the attributes enforced on outbound cookies.
+type CookieRules struct {
+	// Secure forces the Secure attribute.
+	Secure bool
+
+	// HTTPOnly forces the HttpOnly attribute.
+	HTTPOnly bool
+
+	// SameSite forces the SameSite attribute. Zero leaves it unchanged.
+	SameSite http.SameSite
+
+	// Domain forces the Domain attribute. Empty leaves it unchanged.
+	Domain string
+}
+
+// CookiePolicyConfig defines the configuration for cookie policy middleware.
+type CookiePolicyConfig struct {
+	// Rules are enforced on every cookie set by downstream handlers.
+	Rules CookieRules
+
+	// Overrides replaces Rules for cookies with the given names.
+	Overrides map[string]CookieRules
+
+	// Exempt lists cookie names that are never rewritten.
+	Exempt []string
+
+	// EnforcePrefixes applies the requirements of the __Host- and __Secure-
+	// name prefixes: both require Secure, and __Host- additionally requires
+	// Path=/ and no Domain.
+	// Default: true
+	EnforcePrefixes bool
+
+	// SkipFunc allows skipping the policy for certain requests.
+	SkipFunc Skipper
+}
+
+// DefaultCookiePolicyConfig returns a policy that makes every cookie
+// Secure, HttpOnly and SameSite=Lax.
+func DefaultCookiePolicyConfig() CookiePolicyConfig {
+	return CookiePolicyConfig{
+		Rules: CookieRules{
+			Secure:   true,
+			HTTPOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		},
+		EnforcePrefixes: true,
+	}
+}
+
+// CookiePolicy returns cookie policy middleware with default configuration.
+func CookiePolicy() ginji.Middleware {
+	return CookiePolicyWithConfig(DefaultCookiePolicyConfig())
+}
+
+// CookiePolicyWithConfig returns middleware that rewrites outbound
+// Set-Cookie headers so every cookie meets the configured policy,
+// regardless of how the handler set it.
+func CookiePolicyWithConfig(config CookiePolicyConfig) ginji.Middleware {
+	exempt := make(map[string]bool, len(config.Exempt))
+	for _, name := range config.Exempt {
+		exempt[name] = true
+	}
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		rw := WrapResponseWriter(c)
+		rw.Before(func(h http.Header) {
+			applyCookiePolicy(h, &config, exempt)
+		})
+
+		err := c.Next()
+
+		rw.runBeforeHooks()
+		return err
+	}
+}
+
+// applyCookiePolicy rewrites all Set-Cookie headers in h.
+func applyCookiePolicy(h http.Header, config *CookiePolicyConfig, exempt map[string]bool) {
+	values := h.Values("Set-Cookie")
+	if len(values) == 0 {
+		return
+	}
+
+	rewritten := make([]string, 0, len(values))
+	for _, value := range values {
+		cookie, err := http.ParseSetCookie(value)
+		if err != nil || exempt[cookie.Name] {
+			rewritten = append(rewritten, value)
+			continue
+		}
+
+		rules := config.Rules
+		if override, ok := config.Overrides[cookie.Name]; ok {
+			rules = override
+		}
+
+		if rules.Secure {
+			cookie.Secure = true
+		}
+		if rules.HTTPOnly {
+			cookie.HttpOnly = true
+		}
+		if rules.SameSite != 0 {
+			cookie.SameSite = rules.SameSite
+		}
+		if rules.Domain != "" {
+			cookie.Domain = rules.Domain
+		}
+
+		if config.EnforcePrefixes {
+			switch {
+			case strings.HasPrefix(cookie.Name, "__Host-"):
+				cookie.Secure = true
+				cookie.Path = "/"
+				cookie.Domain = ""
+			case strings.HasPrefix(cookie.Name, "__Secure-"):
+				cookie.Secure = true
+			}
+		}
+
+		// SameSite=None is only honored by browsers on Secure cookies
+		if cookie.SameSite == http.SameSiteNoneMode {
+			cookie.Secure = true
+		}
+
+		if s := cookie.String(); s != "" {
+			rewritten = append(rewritten, s)
+		} else {
+			rewritten = append(rewritten, value)
+		}
+	}
+
+	h["Set-Cookie"] = rewritten
+}
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ginjigo/ginji"
+)
+
+// findCookie returns the cookie with the given name, or nil.
+func findCookie(cookies []*http.Cookie, name string) *http.Cookie {
+	for _, cookie := range cookies {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func TestCookiePolicyDefault(t *testing.T) {
+	app := ginji.New()
+	app.Use(CookiePolicy())
+
+	app.Get("/login", func(c *ginji.Context) error {
+		c.SetCookie(&http.Cookie{Name: "session", Value: "abc", Path: "/"})
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/login", nil)
+	cookie := findCookie(w.Result().Cookies(), "session")
+	if cookie == nil {
+		t.Fatal("Expected session cookie")
+	}
+	if !cookie.Secure || !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode {
+		t.Errorf("Expected Secure, HttpOnly and SameSite=Lax, got %+v", cookie)
+	}
+}
+
+func TestCookiePolicyOverridesAndExempt(t *testing.T) {
+	app := ginji.New()
+	app.Use(CookiePolicyWithConfig(CookiePolicyConfig{
+		Rules: CookieRules{Secure: true, HTTPOnly: true},
+		Overrides: map[string]CookieRules{
+			"ui_theme": {Secure: true},
+		},
+		Exempt: []string{"legacy"},
+	}))
+
+	app.Get("/", func(c *ginji.Context) error {
+		c.SetCookie(&http.Cookie{Name: "ui_theme", Value: "dark"})
+		c.SetCookie(&http.Cookie{Name: "legacy", Value: "1"})
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/", nil)
+	cookies := w.Result().Cookies()
+
+	theme := findCookie(cookies, "ui_theme")
+	if theme == nil || !theme.Secure || theme.HttpOnly {
+		t.Errorf("Expected ui_theme to be Secure but readable by scripts, got %+v", theme)
+	}
+
+	legacy := findCookie(cookies, "legacy")
+	if legacy == nil || legacy.Secure || legacy.HttpOnly {
+		t.Errorf("Expected legacy cookie untouched, got %+v", legacy)
+	}
+}
+
+func TestCookiePolicyHostPrefix(t *testing.T) {
+	app := ginji.New()
+	app.Use(CookiePolicyWithConfig(CookiePolicyConfig{EnforcePrefixes: true}))
+
+	app.Get("/", func(c *ginji.Context) error {
+		c.SetCookie(&http.Cookie{Name: "__Host-id", Value: "1", Path: "/app", Domain: "example.com"})
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/", nil)
+	cookie := findCookie(w.Result().Cookies(), "__Host-id")
+	if cookie == nil {
+		t.Fatal("Expected __Host-id cookie")
+	}
+	if !cookie.Secure || cookie.Path != "/" || cookie.Domain != "" {
+		t.Errorf("Expected __Host- requirements enforced, got %+v", cookie)
+	}
+}
+
+func TestCookiePolicyNoBody(t *testing.T) {
+	app := ginji.New()
+	app.Use(CookiePolicy())
+
+	app.Get("/", func(c *ginji.Context) error {
+		c.SetCookie(&http.Cookie{Name: "flag", Value: "1"})
+		return nil
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/", nil)
+	cookie := findCookie(w.Result().Cookies(), "flag")
+	if cookie == nil || !cookie.Secure {
+		t.Errorf("Expected policy applied to response without body, got %+v", cookie)
+	}
+}
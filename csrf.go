@@ -53,9 +53,33 @@ type CSRFConfig struct {
 	// Default: "csrf"
 	ContextKey string
 
+	// SecureCookie signs (and optionally encrypts) the CSRF cookie.
+	// If nil, the token is stored in the cookie as-is.
+	SecureCookie *SecureCookie
+
+	// TokenEndpoint is a path that returns the current token as JSON, e.g.
+	// "/csrf-token", for single-page apps that cannot read the cookie.
+	// Default: "" (disabled)
+	TokenEndpoint string
+
+	// ExposeHeader is a response header that carries the current token on
+	// safe requests, e.g. "X-CSRF-Token".
+	// Default: "" (disabled)
+	ExposeHeader string
+
+	// TrustedOrigins lists the origins, such as "https://app.example.com"
+	// or "https://*.example.com", of front ends served from another site.
+	// They may read the token from TokenEndpoint and ExposeHeader with
+	// credentials. When set, only they may send state-changing requests
+	// with an Origin header other than the request's own host.
+	TrustedOrigins []string
+
 	// ErrorHandler is called when CSRF validation fails.
 	// If nil, a default 403 response is sent.
 	ErrorHandler func(*ginji.Context)
+
+	// SkipFunc allows skipping CSRF protection for certain requests.
+	SkipFunc Skipper
 }
 
 // DefaultCSRFConfig returns default CSRF configuration.
@@ -73,6 +97,20 @@ func DefaultCSRFConfig() CSRFConfig {
 	}
 }
 
+// CrossSiteCSRFConfig returns a configuration for single-page apps on
+// another site than the API: the cookie is sent cross-site (SameSite=None,
+// which requires Secure) and the token is available from GET /csrf-token
+// and the X-CSRF-Token response header for the given origins.
+func CrossSiteCSRFConfig(trustedOrigins ...string) CSRFConfig {
+	config := DefaultCSRFConfig()
+	config.CookieSecure = true
+	config.CookieSameSite = http.SameSiteNoneMode
+	config.TokenEndpoint = "/csrf-token"
+	config.ExposeHeader = "X-CSRF-Token"
+	config.TrustedOrigins = trustedOrigins
+	return config
+}
+
 // CSRF returns a CSRF protection middleware with default configuration.
 func CSRF() ginji.Middleware {
 	return CSRFWithConfig(DefaultCSRFConfig())
@@ -108,21 +146,47 @@ func CSRFWithConfig(config CSRFConfig) ginji.Middleware {
 	lookupSource := parts[0]
 	lookupName := parts[1]
 
+	// Browsers drop SameSite=None cookies without the Secure flag
+	if config.CookieSameSite == http.SameSiteNoneMode && !config.CookieSecure {
+		panic("CSRF: SameSite=None requires CookieSecure")
+	}
+
+	trustedOrigins := newOriginMatcher(config.TrustedOrigins)
+
 	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
 		// Get or create token
 		token := ""
 		cookie, err := c.Cookie(config.CookieName)
 		if err == nil && cookie.Value != "" {
 			token = cookie.Value
-		} else {
+			if config.SecureCookie != nil {
+				// A cookie that fails verification is treated as missing
+				token, _ = config.SecureCookie.Decode(config.CookieName, cookie.Value)
+			}
+		}
+		if token == "" {
 			// Generate new token
 			token = generateCSRFToken(config.TokenLength)
 		}
 
+		cookieValue := token
+		if config.SecureCookie != nil {
+			encoded, err := config.SecureCookie.Encode(config.CookieName, token)
+			if err != nil {
+				return err
+			}
+			cookieValue = encoded
+		}
+
 		// Set cookie
 		http.SetCookie(c.Res, &http.Cookie{
 			Name:     config.CookieName,
-			Value:    token,
+			Value:    cookieValue,
 			Path:     config.CookiePath,
 			Domain:   config.CookieDomain,
 			MaxAge:   config.CookieMaxAge,
@@ -134,12 +198,44 @@ func CSRFWithConfig(config CSRFConfig) ginji.Middleware {
 		// Store token in context for templates
 		c.Set(config.ContextKey, token)
 
+		// Only trusted front ends on other origins may read the token
+		origin := c.Header("Origin")
+		crossOrigin := origin != "" && !sameOrigin(c.Req, origin)
+		trusted := !crossOrigin || trustedOrigins.match(origin)
+		if trusted && crossOrigin {
+			c.SetHeader("Access-Control-Allow-Origin", origin)
+			c.SetHeader("Access-Control-Allow-Credentials", "true")
+			AddVary(c.Res.Header(), "Origin")
+		}
+
 		// Skip validation for safe methods
 		method := c.Req.Method
-		if method == "GET" || method == "HEAD" || method == "OPTIONS" || method == "TRACE" {
+		if isSafeMethod(method) {
+			if trusted && config.TokenEndpoint != "" && c.Req.URL.Path == config.TokenEndpoint && method == "GET" {
+				c.SetHeader("Cache-Control", "no-store")
+				c.AbortWithStatusJSON(ginji.StatusOK, ginji.H{"token": token})
+				return nil
+			}
+			if trusted && config.ExposeHeader != "" {
+				c.SetHeader(config.ExposeHeader, token)
+				if crossOrigin {
+					c.Res.Header().Add("Access-Control-Expose-Headers", config.ExposeHeader)
+				}
+			}
 			return c.Next()
 		}
 
+		if !trusted && !trustedOrigins.empty() {
+			if config.ErrorHandler != nil {
+				config.ErrorHandler(c)
+			} else {
+				c.AbortWithStatusJSON(ginji.StatusForbidden, ginji.H{
+					"error": "CSRF origin not trusted",
+				})
+			}
+			return nil
+		}
+
 		// Extract token from request
 		var clientToken string
 		switch lookupSource {
@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ginjigo/ginji"
+)
+
+func TestCSRFValidation(t *testing.T) {
+	app := ginji.New()
+	app.Use(CSRFWithConfig(DefaultCSRFConfig()))
+	app.Get("/page", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "page")
+	})
+	app.Post("/submit", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "submitted")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/page", nil)
+	cookie := findCookie(w.Result().Cookies(), "_csrf")
+	if cookie == nil {
+		t.Fatal("Expected CSRF cookie")
+	}
+
+	w = ginji.NewRequest(app, "POST", "/submit").Cookie(cookie).Do()
+	ginji.AssertStatus(t, w, ginji.StatusForbidden)
+
+	w = ginji.NewRequest(app, "POST", "/submit").Cookie(cookie).Header("X-CSRF-Token", cookie.Value).Do()
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+}
+
+func TestCSRFTokenEndpoint(t *testing.T) {
+	app := ginji.New()
+	app.Use(CSRFWithConfig(CrossSiteCSRFConfig("https://app.example.com")))
+	app.Get("/page", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "page")
+	})
+	app.Post("/submit", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "submitted")
+	})
+
+	req := httptest.NewRequest("GET", "/csrf-token", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	ginji.AssertHeader(t, w, "Access-Control-Allow-Origin", "https://app.example.com")
+	ginji.AssertHeader(t, w, "Access-Control-Allow-Credentials", "true")
+	ginji.AssertHeader(t, w, "Cache-Control", "no-store")
+
+	var body struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Token == "" {
+		t.Fatalf("Expected token in JSON body, got %s", w.Body.String())
+	}
+
+	cookie := findCookie(w.Result().Cookies(), "_csrf")
+	if cookie == nil || cookie.SameSite != http.SameSiteNoneMode || !cookie.Secure {
+		t.Fatalf("Expected SameSite=None; Secure cookie, got %+v", cookie)
+	}
+
+	// The SPA echoes the token on a state-changing request
+	req = httptest.NewRequest("POST", "/submit", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("X-CSRF-Token", body.Token)
+	req.AddCookie(cookie)
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+}
+
+func TestCSRFExposeHeader(t *testing.T) {
+	app := ginji.New()
+	app.Use(CSRFWithConfig(CrossSiteCSRFConfig("https://app.example.com")))
+	app.Get("/page", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "page")
+	})
+
+	req := httptest.NewRequest("GET", "/page", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+
+	if w.Header().Get("X-CSRF-Token") == "" {
+		t.Error("Expected token in response header")
+	}
+	ginji.AssertHeader(t, w, "Access-Control-Expose-Headers", "X-CSRF-Token")
+}
+
+func TestCSRFUntrustedOrigin(t *testing.T) {
+	app := ginji.New()
+	app.Use(CSRFWithConfig(CrossSiteCSRFConfig("https://app.example.com")))
+	app.Get("/page", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "page")
+	})
+	app.Post("/submit", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "submitted")
+	})
+
+	// Untrusted origins cannot read the token
+	req := httptest.NewRequest("GET", "/csrf-token", nil)
+	req.Header.Set("Origin", "https://evil.example")
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if strings.Contains(w.Body.String(), "token") || w.Header().Get("X-CSRF-Token") != "" {
+		t.Error("Expected token to be withheld from untrusted origin")
+	}
+	if w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Error("Expected no CORS headers for untrusted origin")
+	}
+
+	// Nor send state-changing requests, even with a valid token
+	cookie := findCookie(w.Result().Cookies(), "_csrf")
+	req = httptest.NewRequest("POST", "/submit", nil)
+	req.Header.Set("Origin", "https://evil.example")
+	req.Header.Set("X-CSRF-Token", cookie.Value)
+	req.AddCookie(cookie)
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	ginji.AssertStatus(t, w, ginji.StatusForbidden)
+	ginji.AssertBody(t, w, "CSRF origin not trusted")
+}
+
+func TestCSRFSameSiteNoneRequiresSecure(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic for SameSite=None without Secure")
+		}
+	}()
+	config := DefaultCSRFConfig()
+	config.CookieSameSite = http.SameSiteNoneMode
+	CSRFWithConfig(config)
+}
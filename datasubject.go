@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// UserDataStore is implemented by stores holding records about users, so
+// that data subject requests (GDPR access and erasure) can be served
+// without reaching into store internals. SessionAuth and MemoryAuditStore
+// implement it. Implementations must be safe for concurrent use.
+type UserDataStore interface {
+	// ExportUser returns the records stored about userID, ready to be
+	// encoded as JSON. Secrets such as tokens are left out.
+	ExportUser(ctx context.Context, userID string) ([]any, error)
+
+	// PurgeUser deletes the records stored about userID and returns how
+	// many were deleted.
+	PurgeUser(ctx context.Context, userID string) (int, error)
+}
+
+// ErrUserDataUnsupported is returned by UserDataStore implementations
+// whose backing store cannot enumerate records by user.
+var ErrUserDataUnsupported = errors.New("datasubject: store cannot enumerate records by user")
+
+// ExportUserData collects the records of userID from every store, keyed by
+// store name, e.g. for a data export endpoint:
+//
+//	stores := map[string]middleware.UserDataStore{"sessions": auth, "audit": auditStore}
+//	data, err := middleware.ExportUserData(ctx, userID, stores)
+//	if err != nil {
+//		return err
+//	}
+//	return c.JSON(ginji.StatusOK, data)
+//
+// Every store is queried even if one fails; the errors are joined.
+func ExportUserData(ctx context.Context, userID string, stores map[string]UserDataStore) (map[string][]any, error) {
+	data := make(map[string][]any, len(stores))
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(stores)) {
+		records, err := stores[name].ExportUser(ctx, userID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("datasubject: %s: %w", name, err))
+			continue
+		}
+		if records == nil {
+			records = []any{}
+		}
+		data[name] = records
+	}
+	return data, errors.Join(errs...)
+}
+
+// PurgeUserData deletes the records of userID from every store and returns
+// how many were deleted per store name. Every store is purged even if one
+// fails; the errors are joined, so that a retry finishes the job.
+func PurgeUserData(ctx context.Context, userID string, stores map[string]UserDataStore) (map[string]int, error) {
+	purged := make(map[string]int, len(stores))
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(stores)) {
+		n, err := stores[name].PurgeUser(ctx, userID)
+		purged[name] = n
+		if err != nil {
+			errs = append(errs, fmt.Errorf("datasubject: %s: %w", name, err))
+		}
+	}
+	return purged, errors.Join(errs...)
+}
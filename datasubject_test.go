@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// failingUserDataStore is a UserDataStore whose calls fail.
+type failingUserDataStore struct{}
+
+func (failingUserDataStore) ExportUser(context.Context, string) ([]any, error) {
+	return nil, ErrUserDataUnsupported
+}
+
+func (failingUserDataStore) PurgeUser(context.Context, string) (int, error) {
+	return 0, ErrUserDataUnsupported
+}
+
+func TestUserData(t *testing.T) {
+	ctx := context.Background()
+	audit := NewMemoryAuditStore(0)
+	_ = audit.WriteAudit(ctx, AuditEvent{User: "alice", Path: "/orders"})
+	_ = audit.WriteAudit(ctx, AuditEvent{User: "bob", Path: "/orders"})
+	stores := map[string]UserDataStore{
+		"audit":  audit,
+		"broken": failingUserDataStore{},
+		"empty":  NewMemoryAuditStore(0),
+	}
+
+	data, err := ExportUserData(ctx, "alice", stores)
+	if !errors.Is(err, ErrUserDataUnsupported) {
+		t.Errorf("Expected the failing store's error, got %v", err)
+	}
+	if len(data["audit"]) != 1 || data["audit"][0].(AuditEvent).Path != "/orders" {
+		t.Errorf("Unexpected exported audit records %v", data["audit"])
+	}
+	if records, ok := data["empty"]; !ok || records == nil {
+		t.Error("Expected stores without records to export an empty list")
+	}
+
+	purged, err := PurgeUserData(ctx, "alice", stores)
+	if !errors.Is(err, ErrUserDataUnsupported) {
+		t.Errorf("Expected the failing store's error, got %v", err)
+	}
+	if purged["audit"] != 1 || len(audit.Events()) != 1 {
+		t.Errorf("Expected alice's audit event to be purged, got %v", purged)
+	}
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/ginjigo/ginji"
+)
+
+// EarlyHintsConfig defines the configuration for early hints middleware.
+type EarlyHintsConfig struct {
+	// Links are Link header values hinted for every request, e.g.
+	// Preload("/static/app.css", "style").
+	Links []string
+
+	// PathLinks adds links for paths matching a glob pattern (see
+	// PathGlob). The longest matching pattern wins.
+	PathLinks map[string][]string
+
+	// SkipFunc allows skipping the hints for certain requests.
+	SkipFunc Skipper
+}
+
+// EarlyHints returns middleware that sends links as 103 Early Hints.
+//
+//	app.Use(middleware.EarlyHints(
+//		middleware.Preload("/static/app.css", "style"),
+//		middleware.Preload("/static/app.js", "script"),
+//	))
+func EarlyHints(links ...string) ginji.Middleware {
+	return EarlyHintsWithConfig(EarlyHintsConfig{Links: links})
+}
+
+// EarlyHintsWithConfig returns middleware that sends a 103 Early Hints
+// response with the configured Link headers to GET requests before
+// running the handler, so browsers start loading the linked resources
+// while the page is generated. The links stay set for the final response.
+func EarlyHintsWithConfig(config EarlyHintsConfig) ginji.Middleware {
+	pathLinks := compilePathOverrides(config.PathLinks)
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		if c.Req.Method == http.MethodGet {
+			WriteEarlyHints(c, slices.Concat(config.Links, lookupPathOverride(c, pathLinks, nil))...)
+		}
+		return c.Next()
+	}
+}
+
+// WriteEarlyHints adds links as Link headers and sends them in a 103 Early
+// Hints response. It does nothing without links, once the response has
+// started, or for HTTP/1.0 clients, which do not understand 1xx responses.
+func WriteEarlyHints(c *ginji.Context, links ...string) {
+	if len(links) == 0 || !c.Req.ProtoAtLeast(1, 1) {
+		return
+	}
+	if rw, ok := c.Res.(*ResponseWriter); ok && rw.Written() {
+		return
+	}
+
+	header := c.Res.Header()
+	for _, link := range links {
+		header.Add("Link", link)
+	}
+	c.Res.WriteHeader(http.StatusEarlyHints)
+}
+
+// Preload returns a Link header value that preloads url as the given
+// destination, e.g. "style", "script", "font" or "image".
+func Preload(url, as string) string {
+	link := "<" + url + ">; rel=preload; as=" + as
+	if as == "font" {
+		// Fonts are always fetched in CORS mode
+		link += "; crossorigin"
+	}
+	return link
+}
@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"testing"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// getWithHints requests url and returns the response and the Link headers
+// of 103 responses received before it.
+func getWithHints(t *testing.T, url string) (*http.Response, []string) {
+	t.Helper()
+	var hints []string
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			if code == http.StatusEarlyHints {
+				hints = append(hints, header.Values("Link")...)
+			}
+			return nil
+		},
+	}
+	req, _ := http.NewRequest("GET", url, nil)
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp, hints
+}
+
+func TestEarlyHints(t *testing.T) {
+	app := ginji.New()
+	app.Use(EarlyHintsWithConfig(EarlyHintsConfig{
+		Links:     []string{Preload("/app.css", "style")},
+		PathLinks: map[string][]string{"/blog/*": {Preload("/blog.js", "script")}},
+	}))
+	app.Get("/", func(c *ginji.Context) error {
+		return c.HTML(ginji.StatusOK, "<h1>home</h1>")
+	})
+	app.Get("/blog/:slug", func(c *ginji.Context) error {
+		return c.HTML(ginji.StatusOK, "<h1>post</h1>")
+	})
+	server := httptest.NewServer(app)
+	defer server.Close()
+
+	resp, hints := getWithHints(t, server.URL+"/blog/hello")
+	want := []string{"</app.css>; rel=preload; as=style", "</blog.js>; rel=preload; as=script"}
+	if len(hints) != 2 || hints[0] != want[0] || hints[1] != want[1] {
+		t.Errorf("Expected hints %v, got %v", want, hints)
+	}
+	if resp.StatusCode != http.StatusOK || len(resp.Header.Values("Link")) != 2 {
+		t.Errorf("Expected final response to repeat the links, got %d %v", resp.StatusCode, resp.Header.Values("Link"))
+	}
+
+	_, hints = getWithHints(t, server.URL+"/")
+	if len(hints) != 1 {
+		t.Errorf("Expected one hint, got %v", hints)
+	}
+}
+
+func TestEarlyHintsThroughTimeout(t *testing.T) {
+	app := ginji.New()
+	app.Use(Timeout(time.Second))
+	app.Get("/", func(c *ginji.Context) error {
+		c.SetHeader("X-Final", "yes")
+		WriteEarlyHints(c, Preload("/font.woff2", "font"))
+		return c.Text(ginji.StatusOK, "ok")
+	})
+	server := httptest.NewServer(app)
+	defer server.Close()
+
+	resp, hints := getWithHints(t, server.URL+"/")
+	if len(hints) != 1 || hints[0] != "</font.woff2>; rel=preload; as=font; crossorigin" {
+		t.Errorf("Expected font hint, got %v", hints)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("X-Final") != "yes" || resp.Header.Get("Link") == "" {
+		t.Errorf("Unexpected final response %d %v", resp.StatusCode, resp.Header)
+	}
+}
+
+func TestEarlyHintsSkipsNonGet(t *testing.T) {
+	app := ginji.New()
+	app.Use(EarlyHints(Preload("/app.css", "style")))
+	app.Post("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusCreated, "created")
+	})
+
+	w := ginji.PerformRequest(app, "POST", "/", nil)
+	ginji.AssertStatus(t, w, ginji.StatusCreated)
+	if w.Header().Get("Link") != "" {
+		t.Error("Expected no Link header for POST")
+	}
+}
@@ -0,0 +1,308 @@
+package middleware
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// ErrorBudgetConfig defines the configuration for error budget tracking.
+type ErrorBudgetConfig struct {
+	// Window is the period over which the error rate is measured.
+	// Default: 1 minute
+	Window time.Duration
+
+	// Threshold is the error rate, between 0 and 1, at which the budget
+	// trips.
+	// Default: 0.5
+	Threshold float64
+
+	// MinRequests is the number of requests in the window needed before
+	// the budget can trip, so that a few early errors do not trip it.
+	// Default: 20
+	MinRequests int
+
+	// Cooldown is how long the budget stays tripped before it recovers.
+	// Default: 30 seconds
+	Cooldown time.Duration
+
+	// IsError reports whether a request counts as an error. Panics always
+	// count.
+	// Default: a 5xx status or an error returned by the handler
+	IsError func(c *ginji.Context, err error) bool
+
+	// ShedLoad rejects requests with 503 while the budget is tripped, like
+	// an open circuit breaker, instead of only failing readiness.
+	// Default: false
+	ShedLoad bool
+
+	// Metrics exports the state as the error_budget_tripped and
+	// error_budget_error_rate gauges. Optional.
+	Metrics *Metrics
+
+	// OnStateChange is called when the budget trips or recovers, e.g. to
+	// raise an alert. It must not block.
+	OnStateChange func(tripped bool, errorRate float64)
+
+	// SkipFunc allows skipping error tracking for certain requests.
+	SkipFunc Skipper
+}
+
+// DefaultErrorBudgetConfig returns default error budget configuration.
+func DefaultErrorBudgetConfig() ErrorBudgetConfig {
+	return ErrorBudgetConfig{
+		Window:      time.Minute,
+		Threshold:   0.5,
+		MinRequests: 20,
+		Cooldown:    30 * time.Second,
+		IsError:     defaultErrorBudgetIsError,
+	}
+}
+
+// ErrorBudgetStats is the state of an ErrorBudget.
+type ErrorBudgetStats struct {
+	// Tripped reports whether the error rate exceeded the threshold.
+	Tripped bool `json:"tripped"`
+
+	// Requests is the number of requests in the window.
+	Requests int `json:"requests"`
+
+	// Errors is the number of failed requests in the window.
+	Errors int `json:"errors"`
+
+	// ErrorRate is Errors divided by Requests.
+	ErrorRate float64 `json:"errorRate"`
+
+	// RecoversAt is when a tripped budget recovers.
+	RecoversAt *time.Time `json:"recoversAt,omitempty"`
+}
+
+// errorBudgetBuckets is the number of buckets the window is split into.
+const errorBudgetBuckets = 10
+
+// errorBudgetBucket counts the requests of one slice of the window.
+type errorBudgetBucket struct {
+	slot   int64
+	total  int
+	errors int
+}
+
+// ErrorBudget tracks the rate of failed requests and panics, and trips when
+// it exceeds a threshold: readiness fails, so the load balancer moves
+// traffic away, and with ShedLoad requests are rejected until the cooldown
+// ends. Register it inside the recovery middleware so that it sees panics,
+// and add it to the readiness checks:
+//
+//	budget := middleware.NewErrorBudget(middleware.DefaultErrorBudgetConfig())
+//	app.Use(ginji.Recovery())
+//	app.Use(budget.Middleware())
+//
+//	health := middleware.DefaultHealthCheckConfig()
+//	health.AddComponent("errors", budget)
+//	app.Use(middleware.HealthWithConfig(health))
+//
+// ErrorBudget implements HealthReporter and StatsProvider.
+type ErrorBudget struct {
+	config      ErrorBudgetConfig
+	bucketWidth time.Duration
+
+	mu         sync.Mutex
+	buckets    [errorBudgetBuckets]errorBudgetBucket
+	tripped    bool
+	recoversAt time.Time
+}
+
+// NewErrorBudget creates an error budget with the given configuration.
+func NewErrorBudget(config ErrorBudgetConfig) *ErrorBudget {
+	defaults := DefaultErrorBudgetConfig()
+	if config.Window <= 0 {
+		config.Window = defaults.Window
+	}
+	if config.Threshold <= 0 || config.Threshold > 1 {
+		config.Threshold = defaults.Threshold
+	}
+	if config.MinRequests <= 0 {
+		config.MinRequests = defaults.MinRequests
+	}
+	if config.Cooldown <= 0 {
+		config.Cooldown = defaults.Cooldown
+	}
+	if config.IsError == nil {
+		config.IsError = defaults.IsError
+	}
+
+	b := &ErrorBudget{
+		config:      config,
+		bucketWidth: max(config.Window/errorBudgetBuckets, time.Millisecond),
+	}
+	if m := config.Metrics; m != nil {
+		m.Describe("error_budget_tripped", MetricGauge, "Whether the error budget is tripped.")
+		m.Describe("error_budget_error_rate", MetricGauge, "Rate of failed requests in the error budget window.")
+		m.Set("error_budget_tripped", 0)
+	}
+	return b
+}
+
+// Middleware returns middleware that records the outcome of every request.
+// Panics are recorded and re-raised for the recovery middleware.
+func (b *ErrorBudget) Middleware() ginji.Middleware {
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if b.config.SkipFunc != nil && b.config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		if b.config.ShedLoad {
+			if tripped, recoversAt := b.state(time.Now()); tripped {
+				c.SetHeader("Retry-After", strconv.FormatInt(max(secondsUntil(recoversAt), 1), 10))
+				c.AbortWithStatusJSON(ginji.StatusServiceUnavailable, ginji.H{
+					"error": "Service temporarily unavailable",
+				})
+				return nil
+			}
+		}
+
+		completed := false
+		defer func() {
+			if !completed {
+				b.Record(true)
+			}
+		}()
+
+		err := c.Next()
+		completed = true
+		b.Record(b.config.IsError(c, err))
+		return err
+	}
+}
+
+// Record records the outcome of a request handled outside of Middleware.
+func (b *ErrorBudget) Record(failed bool) {
+	now := time.Now()
+	slot := now.UnixNano() / int64(b.bucketWidth)
+
+	b.mu.Lock()
+	recovered := b.recoverLocked(now)
+	bucket := &b.buckets[slot%errorBudgetBuckets]
+	if bucket.slot != slot {
+		*bucket = errorBudgetBucket{slot: slot}
+	}
+	bucket.total++
+	if failed {
+		bucket.errors++
+	}
+
+	total, errors := b.countLocked(slot)
+	rate := float64(errors) / float64(total)
+	trip := !b.tripped && total >= b.config.MinRequests && rate >= b.config.Threshold
+	if trip {
+		b.tripped = true
+		b.recoversAt = now.Add(b.config.Cooldown)
+	}
+	b.mu.Unlock()
+
+	if m := b.config.Metrics; m != nil {
+		m.Set("error_budget_error_rate", rate)
+	}
+	if recovered {
+		b.changed(false, 0)
+	}
+	if trip {
+		b.changed(true, rate)
+	}
+}
+
+// Tripped reports whether the error budget is tripped.
+func (b *ErrorBudget) Tripped() bool {
+	tripped, _ := b.state(time.Now())
+	return tripped
+}
+
+// HealthCheck implements HealthReporter. A tripped budget is unhealthy.
+func (b *ErrorBudget) HealthCheck() error {
+	now := time.Now()
+	tripped, recoversAt := b.state(now)
+	if !tripped {
+		return nil
+	}
+	return fmt.Errorf("errorbudget: error rate exceeded %.0f%%, recovering in %s",
+		b.config.Threshold*100, recoversAt.Sub(now).Round(time.Second))
+}
+
+// Stats implements StatsProvider.
+func (b *ErrorBudget) Stats() any {
+	now := time.Now()
+	b.state(now)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	total, errors := b.countLocked(now.UnixNano() / int64(b.bucketWidth))
+	stats := ErrorBudgetStats{Tripped: b.tripped, Requests: total, Errors: errors}
+	if total > 0 {
+		stats.ErrorRate = float64(errors) / float64(total)
+	}
+	if b.tripped {
+		recoversAt := b.recoversAt
+		stats.RecoversAt = &recoversAt
+	}
+	return stats
+}
+
+// state returns whether the budget is tripped and when it recovers,
+// recovering it first if the cooldown has ended.
+func (b *ErrorBudget) state(now time.Time) (bool, time.Time) {
+	b.mu.Lock()
+	recovered := b.recoverLocked(now)
+	tripped, recoversAt := b.tripped, b.recoversAt
+	b.mu.Unlock()
+
+	if recovered {
+		b.changed(false, 0)
+	}
+	return tripped, recoversAt
+}
+
+// recoverLocked ends the trip once the cooldown has passed. The window is
+// cleared so that the errors that tripped the budget do not trip it again.
+func (b *ErrorBudget) recoverLocked(now time.Time) bool {
+	if !b.tripped || now.Before(b.recoversAt) {
+		return false
+	}
+	b.tripped = false
+	b.recoversAt = time.Time{}
+	b.buckets = [errorBudgetBuckets]errorBudgetBucket{}
+	return true
+}
+
+// countLocked sums the buckets within the window ending at slot.
+func (b *ErrorBudget) countLocked(slot int64) (total, errors int) {
+	for _, bucket := range b.buckets {
+		if bucket.slot > slot-errorBudgetBuckets && bucket.slot <= slot {
+			total += bucket.total
+			errors += bucket.errors
+		}
+	}
+	return total, errors
+}
+
+// changed reports a state change to the metrics and callback.
+func (b *ErrorBudget) changed(tripped bool, rate float64) {
+	if m := b.config.Metrics; m != nil {
+		value := 0.0
+		if tripped {
+			value = 1
+		}
+		m.Set("error_budget_tripped", value)
+	}
+	if b.config.OnStateChange != nil {
+		b.config.OnStateChange(tripped, rate)
+	}
+}
+
+// defaultErrorBudgetIsError counts 5xx responses and handler errors.
+func defaultErrorBudgetIsError(c *ginji.Context, err error) bool {
+	return err != nil || c.StatusCode() >= 500
+}
@@ -0,0 +1,169 @@
+package middleware
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+func TestErrorBudgetTripsReadiness(t *testing.T) {
+	var changes []bool
+	budget := NewErrorBudget(ErrorBudgetConfig{
+		MinRequests: 4,
+		Threshold:   0.5,
+		Cooldown:    100 * time.Millisecond,
+		OnStateChange: func(tripped bool, rate float64) {
+			changes = append(changes, tripped)
+		},
+	})
+	health := DefaultHealthCheckConfig()
+	health.AddComponent("errors", budget)
+
+	app := ginji.New()
+	app.Use(HealthWithConfig(health))
+	app.Use(ginji.Recovery())
+	app.Use(budget.Middleware())
+	app.Get("/ok", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+	app.Get("/fail", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusInternalServerError, "fail")
+	})
+	app.Get("/panic", func(c *ginji.Context) error {
+		panic("boom")
+	})
+
+	ginji.PerformRequest(app, "GET", "/ok", nil)
+	ginji.PerformRequest(app, "GET", "/fail", nil)
+	ginji.PerformRequest(app, "GET", "/ok", nil)
+	if budget.Tripped() {
+		t.Fatal("Expected budget not to trip below MinRequests")
+	}
+
+	w := ginji.PerformRequest(app, "GET", "/panic", nil)
+	ginji.AssertStatus(t, w, ginji.StatusInternalServerError)
+	if !budget.Tripped() {
+		t.Fatal("Expected budget to trip at 50% errors")
+	}
+
+	w = ginji.PerformRequest(app, "GET", "/health/ready", nil)
+	ginji.AssertStatus(t, w, ginji.StatusServiceUnavailable)
+	ginji.AssertBody(t, w, "errorbudget")
+
+	// Without ShedLoad requests are still served
+	w = ginji.PerformRequest(app, "GET", "/ok", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+
+	time.Sleep(150 * time.Millisecond)
+
+	w = ginji.PerformRequest(app, "GET", "/health/ready", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+
+	if len(changes) != 2 || !changes[0] || changes[1] {
+		t.Errorf("Expected trip then recovery, got %v", changes)
+	}
+	stats := budget.Stats().(ErrorBudgetStats)
+	if stats.Tripped || stats.Errors != 0 {
+		t.Errorf("Expected a cleared window after recovery, got %+v", stats)
+	}
+}
+
+func TestErrorBudgetShedLoad(t *testing.T) {
+	budget := NewErrorBudget(ErrorBudgetConfig{
+		MinRequests: 2,
+		Cooldown:    time.Minute,
+		ShedLoad:    true,
+	})
+	health := DefaultHealthCheckConfig()
+	health.AddComponent("errors", budget)
+
+	app := ginji.New()
+	app.Use(HealthWithConfig(health))
+	app.Use(ginji.Recovery())
+	app.Use(budget.Middleware())
+	app.Get("/ok", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+	app.Get("/fail", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusInternalServerError, "fail")
+	})
+	app.Get("/panic", func(c *ginji.Context) error {
+		panic("boom")
+	})
+
+	ginji.PerformRequest(app, "GET", "/fail", nil)
+	ginji.PerformRequest(app, "GET", "/fail", nil)
+
+	w := ginji.PerformRequest(app, "GET", "/ok", nil)
+	ginji.AssertStatus(t, w, ginji.StatusServiceUnavailable)
+	ginji.AssertHeader(t, w, "Retry-After", "60")
+
+	stats := budget.Stats().(ErrorBudgetStats)
+	if !stats.Tripped || stats.Requests != 2 || stats.Errors != 2 || stats.RecoversAt == nil {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestErrorBudgetIsError(t *testing.T) {
+	var handled atomic.Int32
+	budget := NewErrorBudget(ErrorBudgetConfig{
+		MinRequests: 2,
+		IsError: func(c *ginji.Context, err error) bool {
+			handled.Add(1)
+			return errors.Is(err, errTestBudget)
+		},
+	})
+
+	app := ginji.New()
+	app.Use(budget.Middleware())
+	app.Get("/", func(c *ginji.Context) error {
+		c.Text(ginji.StatusInternalServerError, "fail")
+		return errTestBudget
+	})
+
+	ginji.PerformRequest(app, "GET", "/", nil)
+	ginji.PerformRequest(app, "GET", "/", nil)
+
+	if handled.Load() != 2 || !budget.Tripped() {
+		t.Errorf("Expected custom IsError to trip the budget")
+	}
+}
+
+var errTestBudget = errors.New("downstream failed")
+
+func TestErrorBudgetWindow(t *testing.T) {
+	budget := NewErrorBudget(ErrorBudgetConfig{
+		Window:      100 * time.Millisecond,
+		MinRequests: 2,
+	})
+
+	budget.Record(true)
+	time.Sleep(150 * time.Millisecond)
+	budget.Record(true)
+	if budget.Tripped() {
+		t.Error("Expected errors outside the window to be forgotten")
+	}
+	budget.Record(true)
+	if !budget.Tripped() {
+		t.Error("Expected errors within the window to trip the budget")
+	}
+}
+
+func TestErrorBudgetMetrics(t *testing.T) {
+	metrics := NewMetrics(DefaultMetricsConfig())
+	budget := NewErrorBudget(ErrorBudgetConfig{MinRequests: 4, Metrics: metrics})
+
+	budget.Record(false)
+	budget.Record(true)
+	if v := metrics.Value("error_budget_error_rate"); v != 0.5 {
+		t.Errorf("Expected error rate 0.5, got %v", v)
+	}
+	budget.Record(true)
+	budget.Record(true)
+	if v := metrics.Value("error_budget_tripped"); v != 1 {
+		t.Errorf("Expected tripped gauge 1, got %v", v)
+	}
+}
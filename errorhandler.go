@@ -0,0 +1,192 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/ginjigo/ginji"
+)
+
+// errorReferenceAlphabet is Crockford's base32, which avoids characters
+// that are easily confused when read out over the phone.
+const errorReferenceAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ErrorResponse is the body of error responses written by ErrorHandler.
+type ErrorResponse struct {
+	Error     string                 `json:"error"`
+	Code      int                    `json:"code"`
+	Details   any                    `json:"details,omitempty"`
+	Errors    ginji.ValidationErrors `json:"errors,omitempty"`
+	RequestID string                 `json:"request_id,omitempty"`
+	Reference string                 `json:"reference"`
+}
+
+// ErrorHandlerConfig defines the configuration for error handler
+// middleware.
+type ErrorHandlerConfig struct {
+	// Logger logs every error response with its reference. If nil, uses
+	// engine's logger.
+	Logger *slog.Logger
+
+	// ReferenceFunc generates the reference codes of error responses.
+	// Default: 8 random base32 characters, e.g. "7K2M-9QXA"
+	ReferenceFunc func() string
+
+	// DisableRecovery lets panics propagate instead of turning them into
+	// 500 responses that log their stack trace.
+	DisableRecovery bool
+
+	// SkipFunc allows skipping the middleware for certain requests.
+	SkipFunc Skipper
+}
+
+// DefaultErrorHandlerConfig returns default error handler configuration.
+func DefaultErrorHandlerConfig() ErrorHandlerConfig {
+	return ErrorHandlerConfig{
+		ReferenceFunc: newErrorReference,
+	}
+}
+
+// ErrorHandler returns error handler middleware with default configuration.
+func ErrorHandler() ginji.Middleware {
+	return ErrorHandlerWithConfig(DefaultErrorHandlerConfig())
+}
+
+// ErrorHandlerWithConfig returns middleware that renders errors returned by
+// handlers, and panics, as JSON responses carrying the request ID and a
+// short reference code:
+//
+//	{"error": "Internal Server Error", "code": 500,
+//	 "request_id": "0f8fad5b-...", "reference": "7K2M-9QXA"}
+//
+// Every error is logged with the same reference, and panics with their
+// stack trace, so support can go from a reference a user reports to the
+// log line explaining it. Messages of errors other than *ginji.HTTPError
+// are not exposed. Register it after RequestID and before the middleware
+// whose errors it renders; errors after the response has been written are
+// only logged.
+func ErrorHandlerWithConfig(config ErrorHandlerConfig) ginji.Middleware {
+	defaults := DefaultErrorHandlerConfig()
+	if config.ReferenceFunc == nil {
+		config.ReferenceFunc = defaults.ReferenceFunc
+	}
+
+	return func(c *ginji.Context) (err error) {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		WrapResponseWriter(c)
+		if !config.DisableRecovery {
+			defer func() {
+				if r := recover(); r != nil {
+					if r == http.ErrAbortHandler {
+						panic(r)
+					}
+					handleErrorResponse(c, &config, fmt.Errorf("panic: %v", r), debug.Stack())
+					err = nil
+				}
+			}()
+		}
+
+		if err = c.Next(); err != nil {
+			handleErrorResponse(c, &config, err, nil)
+		}
+		return nil
+	}
+}
+
+// ErrorHandlerFunc returns a ginji.ErrorHandler rendering errors as
+// ErrorHandler does, for app.SetErrorHandler, so that c.AbortWithError and
+// ginji.DefaultErrorHandler produce the same responses.
+func ErrorHandlerFunc(config ErrorHandlerConfig) ginji.ErrorHandler {
+	if config.ReferenceFunc == nil {
+		config.ReferenceFunc = DefaultErrorHandlerConfig().ReferenceFunc
+	}
+	return func(c *ginji.Context, err error) {
+		handleErrorResponse(c, &config, err, nil)
+	}
+}
+
+// ErrorReference returns the reference code of the error response written
+// for the request, or "".
+func ErrorReference(c *ginji.Context) string {
+	return c.GetString("error_reference")
+}
+
+// handleErrorResponse logs err under a new reference and writes the error
+// response, unless one has already been written.
+func handleErrorResponse(c *ginji.Context, config *ErrorHandlerConfig, err error, stack []byte) {
+	status, message := errorStatus(err)
+	response := ErrorResponse{
+		Error:     message,
+		Code:      status,
+		RequestID: RequestIDFrom(c),
+		Reference: config.ReferenceFunc(),
+	}
+	var httpErr *ginji.HTTPError
+	if errors.As(err, &httpErr) && status < 500 {
+		response.Details = httpErr.Details
+	}
+	var validationErrs ginji.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		response.Code, response.Error, response.Errors = http.StatusUnprocessableEntity, "Validation failed", validationErrs
+	}
+	c.Set("error_reference", response.Reference)
+
+	level := slog.LevelWarn
+	if response.Code >= 500 {
+		level = slog.LevelError
+	}
+	attrs := []slog.Attr{
+		slog.String("reference", response.Reference),
+		slog.String("request_id", response.RequestID),
+		slog.String("method", c.Req.Method),
+		slog.String("path", c.Req.URL.Path),
+		slog.Int("status", response.Code),
+		slog.String("error", err.Error()),
+	}
+	if stack != nil {
+		attrs = append(attrs, slog.String("stack", string(stack)))
+	}
+	requestLogger(c, config.Logger).LogAttrs(c.Req.Context(), level, "Request failed", attrs...)
+
+	if rw, ok := c.Res.(*ResponseWriter); ok && rw.Written() {
+		return
+	}
+	_ = c.JSON(response.Code, response)
+	c.Abort()
+}
+
+// errorStatus returns the status and client message of err. Messages of
+// errors other than *ginji.HTTPError, and of server errors, are not
+// exposed.
+func errorStatus(err error) (int, string) {
+	var httpErr *ginji.HTTPError
+	if errors.As(err, &httpErr) {
+		if httpErr.Code >= 500 {
+			return httpErr.Code, http.StatusText(httpErr.Code)
+		}
+		return httpErr.Code, httpErr.Message
+	}
+	return http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)
+}
+
+// newErrorReference returns a random reference code such as "7K2M-9QXA".
+func newErrorReference() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	ref := make([]byte, 0, 9)
+	for i, v := range b {
+		if i == 4 {
+			ref = append(ref, '-')
+		}
+		ref = append(ref, errorReferenceAlphabet[v&31])
+	}
+	return string(ref)
+}
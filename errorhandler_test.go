@@ -0,0 +1,200 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/ginjigo/ginji"
+)
+
+var errorReferencePattern = regexp.MustCompile(`^[0-9A-HJKMNP-TV-Z]{4}-[0-9A-HJKMNP-TV-Z]{4}$`)
+
+func decodeErrorResponse(t *testing.T, body []byte) ErrorResponse {
+	t.Helper()
+	var res ErrorResponse
+	if err := json.Unmarshal(body, &res); err != nil {
+		t.Fatalf("Expected a JSON error response, got %s", body)
+	}
+	return res
+}
+
+func TestErrorHandler(t *testing.T) {
+	var buf bytes.Buffer
+	app := ginji.New()
+	app.Use(RequestID())
+	app.Use(ErrorHandlerWithConfig(ErrorHandlerConfig{
+		Logger: slog.New(slog.NewJSONHandler(&buf, nil)),
+	}))
+	app.Get("/db", func(c *ginji.Context) error {
+		return errors.New("pq: connection refused")
+	})
+	app.Get("/missing", func(c *ginji.Context) error {
+		return ginji.NewHTTPError(ginji.StatusNotFound, "User not found").WithDetails("id=7")
+	})
+	app.Get("/invalid", func(c *ginji.Context) error {
+		return ginji.ValidationErrors{{Field: "Email", Message: "is required"}}
+	})
+	app.Get("/panic", func(c *ginji.Context) error {
+		var m map[string]int
+		m["boom"]++
+		return nil
+	})
+	app.Get("/partial", func(c *ginji.Context) error {
+		c.Text(ginji.StatusOK, "partial")
+		return errors.New("stream failed")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/db", nil)
+	ginji.AssertStatus(t, w, ginji.StatusInternalServerError)
+	res := decodeErrorResponse(t, w.Body.Bytes())
+	if res.Error != "Internal Server Error" || strings.Contains(w.Body.String(), "pq:") {
+		t.Errorf("Expected the internal error to be hidden, got %s", w.Body.String())
+	}
+	if res.RequestID == "" || res.RequestID != w.Header().Get("X-Request-ID") {
+		t.Errorf("Expected the request ID in the body, got %q", res.RequestID)
+	}
+	if !errorReferencePattern.MatchString(res.Reference) {
+		t.Errorf("Unexpected reference %q", res.Reference)
+	}
+
+	// The log line links the reference to the error
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["reference"] != res.Reference || entry["error"] != "pq: connection refused" ||
+		entry["request_id"] != res.RequestID || entry["level"] != "ERROR" {
+		t.Errorf("Expected the reference and error in the log, got %v", entry)
+	}
+
+	w = ginji.PerformRequest(app, "GET", "/missing", nil)
+	ginji.AssertStatus(t, w, ginji.StatusNotFound)
+	res = decodeErrorResponse(t, w.Body.Bytes())
+	if res.Error != "User not found" || res.Details != "id=7" || res.Reference == "" {
+		t.Errorf("Expected the HTTP error message and details, got %+v", res)
+	}
+
+	w = ginji.PerformRequest(app, "GET", "/invalid", nil)
+	ginji.AssertStatus(t, w, ginji.StatusUnprocessableEntity)
+	if res = decodeErrorResponse(t, w.Body.Bytes()); len(res.Errors) != 1 || res.Errors[0].Field != "Email" {
+		t.Errorf("Expected the validation errors, got %+v", res)
+	}
+}
+
+func TestErrorHandlerPanic(t *testing.T) {
+	var buf bytes.Buffer
+	app := ginji.New()
+	app.Use(RequestID())
+	app.Use(ErrorHandlerWithConfig(ErrorHandlerConfig{
+		Logger: slog.New(slog.NewJSONHandler(&buf, nil)),
+	}))
+	app.Get("/db", func(c *ginji.Context) error {
+		return errors.New("pq: connection refused")
+	})
+	app.Get("/missing", func(c *ginji.Context) error {
+		return ginji.NewHTTPError(ginji.StatusNotFound, "User not found").WithDetails("id=7")
+	})
+	app.Get("/invalid", func(c *ginji.Context) error {
+		return ginji.ValidationErrors{{Field: "Email", Message: "is required"}}
+	})
+	app.Get("/panic", func(c *ginji.Context) error {
+		var m map[string]int
+		m["boom"]++
+		return nil
+	})
+	app.Get("/partial", func(c *ginji.Context) error {
+		c.Text(ginji.StatusOK, "partial")
+		return errors.New("stream failed")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/panic", nil)
+	ginji.AssertStatus(t, w, ginji.StatusInternalServerError)
+	res := decodeErrorResponse(t, w.Body.Bytes())
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	stack, _ := entry["stack"].(string)
+	if entry["reference"] != res.Reference || !strings.Contains(stack, "errorhandler_test.go") {
+		t.Errorf("Expected the panic stack under the reference, got %v", entry)
+	}
+	if strings.Contains(w.Body.String(), "nil map") {
+		t.Error("Expected the panic message to be hidden")
+	}
+}
+
+func TestErrorHandlerAfterWrite(t *testing.T) {
+	var buf bytes.Buffer
+	app := ginji.New()
+	app.Use(RequestID())
+	app.Use(ErrorHandlerWithConfig(ErrorHandlerConfig{
+		Logger: slog.New(slog.NewJSONHandler(&buf, nil)),
+	}))
+	app.Get("/db", func(c *ginji.Context) error {
+		return errors.New("pq: connection refused")
+	})
+	app.Get("/missing", func(c *ginji.Context) error {
+		return ginji.NewHTTPError(ginji.StatusNotFound, "User not found").WithDetails("id=7")
+	})
+	app.Get("/invalid", func(c *ginji.Context) error {
+		return ginji.ValidationErrors{{Field: "Email", Message: "is required"}}
+	})
+	app.Get("/panic", func(c *ginji.Context) error {
+		var m map[string]int
+		m["boom"]++
+		return nil
+	})
+	app.Get("/partial", func(c *ginji.Context) error {
+		c.Text(ginji.StatusOK, "partial")
+		return errors.New("stream failed")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/partial", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	if w.Body.String() != "partial" {
+		t.Errorf("Expected the written response to be kept, got %q", w.Body.String())
+	}
+	if !strings.Contains(buf.String(), "stream failed") {
+		t.Error("Expected the error to be logged")
+	}
+}
+
+func TestErrorHandlerFunc(t *testing.T) {
+	app := ginji.New()
+	app.SetErrorHandler(ErrorHandlerFunc(ErrorHandlerConfig{
+		Logger:        slog.New(slog.NewJSONHandler(&bytes.Buffer{}, nil)),
+		ReferenceFunc: func() string { return "REF-1" },
+	}))
+	app.Use(RequestIDWithConfig(RequestIDConfig{Generator: func() string { return "req-1" }}))
+	app.Get("/", func(c *ginji.Context) error {
+		c.AbortWithError(ginji.StatusForbidden, errors.New("Not yours"))
+		if ErrorReference(c) != "REF-1" {
+			t.Errorf("Expected the reference in the context, got %q", ErrorReference(c))
+		}
+		return nil
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/", nil)
+	ginji.AssertStatus(t, w, ginji.StatusForbidden)
+	res := decodeErrorResponse(t, w.Body.Bytes())
+	if res.Error != "Not yours" || res.RequestID != "req-1" || res.Reference != "REF-1" {
+		t.Errorf("Unexpected response %+v", res)
+	}
+}
+
+func TestNewErrorReference(t *testing.T) {
+	seen := make(map[string]bool)
+	for range 100 {
+		ref := newErrorReference()
+		if !errorReferencePattern.MatchString(ref) || seen[ref] {
+			t.Fatalf("Expected unique base32 references, got %q", ref)
+		}
+		seen[ref] = true
+	}
+}
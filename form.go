@@ -0,0 +1,211 @@
+package middleware
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/ginjigo/ginji"
+)
+
+// FormHelperConfig defines the configuration for form helper middleware.
+type FormHelperConfig struct {
+	// SessionKey is the session key holding the submitted form across the
+	// redirect after a failed validation.
+	// Default: "_form"
+	SessionKey string
+
+	// Exclude lists fields that are never stored for repopulation.
+	// Default: ["password", "password_confirmation", "_csrf"]
+	Exclude []string
+
+	// FlashMessage is added as an "error" flash when validation fails.
+	// Set it to "-" to add no flash.
+	// Default: "Please correct the errors below."
+	FlashMessage string
+
+	// SkipFunc allows skipping the middleware for certain requests.
+	SkipFunc Skipper
+}
+
+// DefaultFormHelperConfig returns default form helper configuration.
+func DefaultFormHelperConfig() FormHelperConfig {
+	return FormHelperConfig{
+		SessionKey:   "_form",
+		Exclude:      []string{"password", "password_confirmation", "_csrf"},
+		FlashMessage: "Please correct the errors below.",
+	}
+}
+
+// FormState is a submitted form and its validation errors, kept to
+// repopulate the form after a redirect.
+type FormState struct {
+	// Values are the submitted values, without excluded fields.
+	Values url.Values `json:"values,omitempty"`
+
+	// Errors maps form field names to their validation messages.
+	Errors map[string][]string `json:"errors,omitempty"`
+}
+
+// Value returns the submitted value of field.
+func (f FormState) Value(field string) string {
+	return f.Values.Get(field)
+}
+
+// Error returns the first validation message of field, or "".
+func (f FormState) Error(field string) string {
+	if msgs := f.Errors[field]; len(msgs) > 0 {
+		return msgs[0]
+	}
+	return ""
+}
+
+// HasError reports whether field failed validation.
+func (f FormState) HasError(field string) bool {
+	return len(f.Errors[field]) > 0
+}
+
+// FormHelper returns form helper middleware with default configuration.
+func FormHelper() ginji.Middleware {
+	return FormHelperWithConfig(DefaultFormHelperConfig())
+}
+
+// FormHelperWithConfig returns middleware that supports the
+// Post/Redirect/Get pattern for HTML forms. Handlers bind posts with
+// BindForm; when validation fails, the submitted values and field errors
+// are kept in the session, and the page the user is redirected to reads
+// them back with FormFrom, or as .Form when rendered with Render:
+//
+//	app.Post("/signup", func(c *ginji.Context) error {
+//		var input SignupInput
+//		if err := middleware.BindForm(c, &input); err != nil {
+//			return c.Redirect(http.StatusSeeOther, "/signup")
+//		}
+//		...
+//	})
+//
+//	<input name="email" value="{{.Form.Value "email"}}">
+//	{{with .Form.Error "email"}}<p class="error">{{.}}</p>{{end}}
+//
+// It needs session middleware to run first.
+func FormHelperWithConfig(config FormHelperConfig) ginji.Middleware {
+	defaults := DefaultFormHelperConfig()
+	if config.SessionKey == "" {
+		config.SessionKey = defaults.SessionKey
+	}
+	if config.Exclude == nil {
+		config.Exclude = defaults.Exclude
+	}
+	if config.FlashMessage == "" {
+		config.FlashMessage = defaults.FlashMessage
+	}
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		c.Set("form_helper", &config)
+		return c.Next()
+	}
+}
+
+// BindForm binds the request form, or JSON body, into the struct pointed to
+// by v and validates it with its `ginji` tags. On a validation failure, the
+// submitted values and errors are stored in the session for the next page
+// and the ginji.ValidationErrors are returned.
+func BindForm(c *ginji.Context, v any) error {
+	err := c.BindValidate(v)
+	var verrs ginji.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return err
+	}
+
+	config := formHelperConfig(c)
+	session, ok := SessionFrom(c)
+	if !ok {
+		return err
+	}
+
+	state := FormState{Values: url.Values{}, Errors: make(map[string][]string)}
+	for name, values := range c.Req.PostForm {
+		if !slices.Contains(config.Exclude, name) {
+			state.Values[name] = values
+		}
+	}
+	names := formFieldNames(reflect.TypeOf(v))
+	for _, verr := range verrs {
+		field := verr.Field
+		if name, ok := names[field]; ok {
+			field = name
+		}
+		state.Errors[field] = append(state.Errors[field], verr.Message)
+	}
+	session.Set(config.SessionKey, state)
+
+	if config.FlashMessage != "-" {
+		_ = AddFlash(c, "error", config.FlashMessage)
+	}
+	return err
+}
+
+// FormFrom returns the form state kept by BindForm for the current page and
+// removes it from the session, so it is shown once. It returns an empty
+// FormState if there is none.
+func FormFrom(c *ginji.Context) FormState {
+	if v, exists := c.Get("form"); exists {
+		if state, ok := v.(FormState); ok {
+			return state
+		}
+	}
+
+	var state FormState
+	if session, ok := SessionFrom(c); ok {
+		key := formHelperConfig(c).SessionKey
+		if saved, ok := session.Get(key).(FormState); ok {
+			state = saved
+			session.Delete(key)
+		}
+	}
+	c.Set("form", state)
+	return state
+}
+
+// formHelperConfig returns the configuration of the FormHelper middleware,
+// or the default one.
+func formHelperConfig(c *ginji.Context) *FormHelperConfig {
+	if v, exists := c.Get("form_helper"); exists {
+		if config, ok := v.(*FormHelperConfig); ok {
+			return config
+		}
+	}
+	config := DefaultFormHelperConfig()
+	return &config
+}
+
+// formFieldNames maps the struct field names of t, as reported in
+// validation errors, to their form field names.
+func formFieldNames(t reflect.Type) map[string]string {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	names := make(map[string]string, t.NumField())
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name := field.Tag.Get("form")
+		if name == "" {
+			name, _, _ = strings.Cut(field.Tag.Get("json"), ",")
+		}
+		if name != "" && name != "-" {
+			names[field.Name] = name
+		}
+	}
+	return names
+}
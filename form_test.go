@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/ginjigo/ginji"
+)
+
+type signupForm struct {
+	Email    string `form:"email" ginji:"required,email"`
+	Name     string `json:"name" ginji:"required,min=3"`
+	Password string `form:"password" ginji:"required"`
+}
+
+func TestFormHelper(t *testing.T) {
+	renderer, err := NewRenderer(RenderConfig{FS: fstest.MapFS{
+		"pages/signup.html": {Data: []byte(`{{range .Flashes}}[{{.Message}}]{{end}}` +
+			`<input name="email" value="{{.Form.Value "email"}}">{{.Form.Error "email"}}` +
+			`<input name="name" value="{{.Form.Value "name"}}">{{.Form.Error "name"}}` +
+			`<input name="password" value="{{.Form.Value "password"}}">`)},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session := mapSession{}
+	app := ginji.New()
+	app.Use(func(c *ginji.Context) error {
+		SetSession(c, session)
+		return c.Next()
+	})
+	app.Use(FormHelper())
+	app.Use(renderer.Middleware())
+	var saved signupForm
+	app.Post("/signup", func(c *ginji.Context) error {
+		var input signupForm
+		if err := BindForm(c, &input); err != nil {
+			return c.Redirect(http.StatusSeeOther, "/signup")
+		}
+		saved = input
+		return c.Text(ginji.StatusOK, "welcome")
+	})
+	app.Get("/signup", func(c *ginji.Context) error {
+		return Render(c, "signup", nil)
+	})
+
+	post := func(form url.Values) *httptest.ResponseRecorder {
+		return ginji.PerformFormRequest(app, "POST", "/signup", form)
+	}
+
+	w := post(url.Values{"email": {"not-an-email"}, "name": {"Al"}, "password": {"hunter2"}})
+	ginji.AssertStatus(t, w, ginji.StatusSeeOther)
+
+	w = ginji.PerformRequest(app, "GET", "/signup", nil)
+	body := w.Body.String()
+	for _, want := range []string{
+		"[Please correct the errors below.]",
+		`<input name="email" value="not-an-email">must be a valid email`,
+		`<input name="name" value="Al">must be at least 3 characters`,
+		`<input name="password" value="">`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %s in %q", want, body)
+		}
+	}
+
+	// The form is shown once
+	w = ginji.PerformRequest(app, "GET", "/signup", nil)
+	if strings.Contains(w.Body.String(), "not-an-email") {
+		t.Error("Expected the form state to be consumed")
+	}
+
+	w = post(url.Values{"email": {"al@example.com"}, "name": {"Alice"}, "password": {"hunter2"}})
+	ginji.AssertBody(t, w, "welcome")
+	if saved.Email != "al@example.com" || saved.Name != "Alice" {
+		t.Errorf("Expected the form to be bound, got %+v", saved)
+	}
+}
+
+func TestBindFormErrors(t *testing.T) {
+	session := mapSession{}
+	app := ginji.New()
+	app.Use(func(c *ginji.Context) error {
+		SetSession(c, session)
+		return c.Next()
+	})
+	app.Use(FormHelperWithConfig(FormHelperConfig{FlashMessage: "-"}))
+	app.Post("/", func(c *ginji.Context) error {
+		var input signupForm
+		err := BindForm(c, &input)
+		var verrs ginji.ValidationErrors
+		if !errors.As(err, &verrs) {
+			t.Errorf("Expected validation errors, got %v", err)
+		}
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	ginji.PerformFormRequest(app, "POST", "/", url.Values{"name": {"Bob"}})
+
+	state, ok := session["_form"].(FormState)
+	if !ok {
+		t.Fatal("Expected the form state in the session")
+	}
+	if !state.HasError("email") || !state.HasError("password") || state.HasError("name") {
+		t.Errorf("Expected errors keyed by form field, got %v", state.Errors)
+	}
+	if state.Value("name") != "Bob" {
+		t.Errorf("Expected the submitted value, got %v", state.Values)
+	}
+	if _, ok := session[flashSessionKey]; ok {
+		t.Error("Expected no flash")
+	}
+}
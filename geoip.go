@@ -0,0 +1,267 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// GeoInfo describes the location of a client IP.
+type GeoInfo struct {
+	// Country is the ISO 3166-1 alpha-2 country code.
+	Country string `json:"country,omitempty"`
+
+	// Region is the ISO 3166-2 subdivision code without country prefix.
+	Region string `json:"region,omitempty"`
+
+	// City is the city name.
+	City string `json:"city,omitempty"`
+
+	// ASN is the autonomous system number.
+	ASN uint `json:"asn,omitempty"`
+
+	// ASOrg is the autonomous system organization.
+	ASOrg string `json:"as_org,omitempty"`
+}
+
+// GeoResolver looks up location information for an IP.
+type GeoResolver interface {
+	Resolve(ip net.IP) (GeoInfo, error)
+}
+
+// GeoResolverFunc adapts a function to the GeoResolver interface.
+type GeoResolverFunc func(ip net.IP) (GeoInfo, error)
+
+// Resolve calls f(ip).
+func (f GeoResolverFunc) Resolve(ip net.IP) (GeoInfo, error) {
+	return f(ip)
+}
+
+// GeoCountryResolver adapts a GeoResolver to the CountryResolver used by
+// AccessWindow, so that both can share one database.
+func GeoCountryResolver(resolver GeoResolver) CountryResolver {
+	return geoCountryResolver{resolver}
+}
+
+// geoCountryResolver resolves countries with a GeoResolver.
+type geoCountryResolver struct {
+	resolver GeoResolver
+}
+
+// Country returns the country of ip.
+func (r geoCountryResolver) Country(ip string) (string, error) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return "", fmt.Errorf("geoip: invalid IP %q", ip)
+	}
+	info, err := r.resolver.Resolve(parsed)
+	if err != nil {
+		return "", err
+	}
+	return info.Country, nil
+}
+
+// MMDBReader is the lookup method of a MaxMind database reader. It is
+// satisfied by *maxminddb.Reader from github.com/oschwald/maxminddb-golang.
+type MMDBReader interface {
+	Lookup(ip net.IP, result any) error
+}
+
+// mmdbCityRecord is the subset of a GeoIP2/GeoLite2 City record we decode.
+type mmdbCityRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	Subdivisions []struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"subdivisions"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+}
+
+// mmdbASNRecord is a GeoIP2/GeoLite2 ASN record.
+type mmdbASNRecord struct {
+	ASN   uint   `maxminddb:"autonomous_system_number"`
+	ASOrg string `maxminddb:"autonomous_system_organization"`
+}
+
+// MMDBResolver resolves locations from MaxMind City and ASN databases.
+type MMDBResolver struct {
+	// City is a City or Country database reader. Optional.
+	City MMDBReader
+
+	// ASN is an ASN database reader. Optional.
+	ASN MMDBReader
+
+	// Language selects the localized city name.
+	// Default: "en"
+	Language string
+}
+
+// NewMMDBResolver creates a resolver backed by MaxMind databases.
+// Either reader may be nil.
+func NewMMDBResolver(city, asn MMDBReader) *MMDBResolver {
+	return &MMDBResolver{City: city, ASN: asn, Language: "en"}
+}
+
+// Resolve looks up ip in the configured databases.
+func (r *MMDBResolver) Resolve(ip net.IP) (GeoInfo, error) {
+	var info GeoInfo
+
+	if r.City != nil {
+		var rec mmdbCityRecord
+		if err := r.City.Lookup(ip, &rec); err != nil {
+			return info, err
+		}
+		info.Country = rec.Country.ISOCode
+		if len(rec.Subdivisions) > 0 {
+			info.Region = rec.Subdivisions[0].ISOCode
+		}
+		lang := r.Language
+		if lang == "" {
+			lang = "en"
+		}
+		info.City = rec.City.Names[lang]
+	}
+
+	if r.ASN != nil {
+		var rec mmdbASNRecord
+		if err := r.ASN.Lookup(ip, &rec); err != nil {
+			return info, err
+		}
+		info.ASN = rec.ASN
+		info.ASOrg = rec.ASOrg
+	}
+
+	return info, nil
+}
+
+// GeoIPConfig defines the configuration for GeoIP middleware.
+type GeoIPConfig struct {
+	// Resolver performs the lookups. Required.
+	Resolver GeoResolver
+
+	// CacheSize is the maximum number of cached lookups.
+	// Default: 10000
+	CacheSize int
+
+	// CacheTTL is how long lookups are cached.
+	// Default: 1 hour
+	CacheTTL time.Duration
+
+	// ContextKey is the key used to store the GeoInfo in context.
+	// Default: "geo"
+	ContextKey string
+
+	// Headers adds X-Geo-Country, X-Geo-Region, X-Geo-City and X-Geo-ASN
+	// response headers.
+	Headers bool
+
+	// TrustedProxies is a list of trusted proxy IP addresses used when
+	// resolving the client IP.
+	TrustedProxies []string
+
+	// SkipFunc allows skipping the lookup for certain requests.
+	SkipFunc Skipper
+}
+
+// DefaultGeoIPConfig returns default GeoIP configuration.
+func DefaultGeoIPConfig() GeoIPConfig {
+	return GeoIPConfig{
+		CacheSize:  10000,
+		CacheTTL:   time.Hour,
+		ContextKey: "geo",
+	}
+}
+
+// GeoIP returns middleware that enriches the context with client location.
+func GeoIP(resolver GeoResolver) ginji.Middleware {
+	config := DefaultGeoIPConfig()
+	config.Resolver = resolver
+	return GeoIPWithConfig(config)
+}
+
+// GeoIPWithConfig returns GeoIP middleware with custom configuration.
+func GeoIPWithConfig(config GeoIPConfig) ginji.Middleware {
+	if config.Resolver == nil {
+		panic("GeoIP: Resolver is required")
+	}
+	defaults := DefaultGeoIPConfig()
+	if config.CacheSize == 0 {
+		config.CacheSize = defaults.CacheSize
+	}
+	if config.CacheTTL == 0 {
+		config.CacheTTL = defaults.CacheTTL
+	}
+	if config.ContextKey == "" {
+		config.ContextKey = defaults.ContextKey
+	}
+
+	cache := newLRUCache[string, GeoInfo](config.CacheSize, config.CacheTTL)
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		addr := clientIP(c.Req, config.TrustedProxies)
+		info, ok := cache.Get(addr)
+		if !ok {
+			var err error
+			if ip := net.ParseIP(addr); ip != nil {
+				info, err = config.Resolver.Resolve(ip)
+			}
+			// Failed lookups are retried on the next request
+			if err == nil {
+				cache.Add(addr, info)
+			}
+		}
+
+		c.Set(config.ContextKey, info)
+		setContextValue(c, geoInfoContextKey, info)
+
+		if config.Headers {
+			if info.Country != "" {
+				c.SetHeader("X-Geo-Country", info.Country)
+			}
+			if info.Region != "" {
+				c.SetHeader("X-Geo-Region", info.Region)
+			}
+			if info.City != "" {
+				c.SetHeader("X-Geo-City", info.City)
+			}
+			if info.ASN != 0 {
+				c.SetHeader("X-Geo-ASN", strconv.FormatUint(uint64(info.ASN), 10))
+			}
+		}
+
+		return c.Next()
+	}
+}
+
+// GetGeoInfo returns the client location stored by the GeoIP middleware.
+func GetGeoInfo(c *ginji.Context) (GeoInfo, bool) {
+	info, ok := c.Req.Context().Value(geoInfoContextKey).(GeoInfo)
+	return info, ok
+}
+
+// RateLimitByCountry returns middleware that limits requests per client country.
+// It requires the GeoIP middleware to run first; clients without a resolved
+// country are limited by IP.
+func RateLimitByCountry(max int, window time.Duration) ginji.Middleware {
+	config := DefaultRateLimiterConfig()
+	config.Max = max
+	config.Window = window
+	config.KeyFunc = func(c *ginji.Context) string {
+		if info, ok := GetGeoInfo(c); ok && info.Country != "" {
+			return "country:" + info.Country
+		}
+		return defaultKeyFunc(c)
+	}
+	return RateLimitWithConfig(config)
+}
@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/ginjigo/ginji"
+)
+
+// fakeMMDB answers lookups from a static table keyed by IP.
+type fakeMMDB struct {
+	city    map[string]string
+	lookups int
+}
+
+func (db *fakeMMDB) Lookup(ip net.IP, result any) error {
+	db.lookups++
+	country, ok := db.city[ip.String()]
+	if !ok {
+		return errors.New("not found")
+	}
+	switch rec := result.(type) {
+	case *mmdbCityRecord:
+		rec.Country.ISOCode = country
+		rec.City.Names = map[string]string{"en": "Berlin"}
+	case *mmdbASNRecord:
+		rec.ASN = 64500
+		rec.ASOrg = "Example Net"
+	}
+	return nil
+}
+
+func TestGeoIPEnrichesContext(t *testing.T) {
+	db := &fakeMMDB{city: map[string]string{"192.0.2.1": "DE"}}
+
+	app := ginji.New()
+	app.Use(GeoIPWithConfig(GeoIPConfig{
+		Resolver: NewMMDBResolver(db, db),
+		Headers:  true,
+	}))
+	app.Get("/", func(c *ginji.Context) error {
+		info, ok := GetGeoInfo(c)
+		if !ok {
+			t.Error("Expected geo info in context")
+		}
+		return c.JSON(ginji.StatusOK, info)
+	})
+
+	for i := 0; i < 3; i++ {
+		// httptest requests originate from 192.0.2.1
+		w := ginji.PerformRequest(app, "GET", "/", nil)
+		ginji.AssertHeader(t, w, "X-Geo-Country", "DE")
+		ginji.AssertHeader(t, w, "X-Geo-City", "Berlin")
+		ginji.AssertHeader(t, w, "X-Geo-ASN", "64500")
+	}
+
+	// One city and one ASN lookup; the rest are served from cache
+	if db.lookups != 2 {
+		t.Errorf("Expected 2 database lookups, got %d", db.lookups)
+	}
+}
+
+func TestGeoIPAccessWindowIntegration(t *testing.T) {
+	resolver := GeoResolverFunc(func(ip net.IP) (GeoInfo, error) {
+		return GeoInfo{Country: "US"}, nil
+	})
+
+	app := ginji.New()
+	app.Use(GeoIP(resolver))
+	app.Use(AccessWindowWithConfig(AccessWindowConfig{
+		CountryResolver: GeoCountryResolver(resolver),
+		DeniedCountries: []string{"US"},
+	}))
+	app.Get("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/", nil)
+	if w.Code != ginji.StatusForbidden {
+		t.Errorf("Expected 403 for denied country, got %d", w.Code)
+	}
+}
+
+func TestGeoIPRetriesFailedLookups(t *testing.T) {
+	lookups := 0
+	resolver := GeoResolverFunc(func(ip net.IP) (GeoInfo, error) {
+		lookups++
+		if lookups == 1 {
+			return GeoInfo{}, errors.New("unavailable")
+		}
+		return GeoInfo{Country: "DE"}, nil
+	})
+
+	app := ginji.New()
+	app.Use(GeoIP(resolver))
+	app.Get("/", func(c *ginji.Context) error {
+		info, _ := GetGeoInfo(c)
+		return c.Text(ginji.StatusOK, info.Country)
+	})
+
+	for _, want := range []string{"", "DE", "DE"} {
+		ginji.AssertBody(t, ginji.PerformRequest(app, "GET", "/", nil), want)
+	}
+	if lookups != 2 {
+		t.Errorf("Expected failed lookup to be retried once, got %d lookups", lookups)
+	}
+}
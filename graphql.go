@@ -0,0 +1,356 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/ginjigo/ginji"
+)
+
+// GraphQLGuardConfig defines the configuration for the GraphQL guard.
+type GraphQLGuardConfig struct {
+	// MaxDepth is the maximum field nesting of an operation, counting
+	// fields inside fragments.
+	// Default: 10
+	MaxDepth int
+
+	// MaxComplexity is the maximum number of fields an operation selects,
+	// with fragments counted every time they are spread.
+	// Default: 1000
+	MaxComplexity int
+
+	// MaxAliases is the maximum number of aliased fields per operation,
+	// which limits batching the same expensive field under many names.
+	// Default: 20
+	MaxAliases int
+
+	// MaxBatchSize is the maximum number of operations in a batched
+	// (JSON array) request.
+	// Default: 10
+	MaxBatchSize int
+
+	// MaxBodyBytes is the maximum request body read to find the document.
+	// Default: 1MB
+	MaxBodyBytes int64
+
+	// BlockIntrospection rejects __schema and __type queries. __typename is
+	// always allowed.
+	// Default: true in ginji.ReleaseMode
+	BlockIntrospection bool
+
+	// RouteLabel appends the operation type and name to the route pattern
+	// (see RoutePattern), so Logger, SlowLog and Metrics tell operations
+	// apart: "/graphql query GetUser". Operation names are chosen by
+	// clients, so this can increase the number of metric series.
+	// Default: true
+	RouteLabel bool
+
+	// SkipFunc allows skipping the guard for certain requests.
+	SkipFunc Skipper
+}
+
+// GraphQLOperation describes the operation of a GraphQL request.
+type GraphQLOperation struct {
+	// Type is "query", "mutation" or "subscription".
+	Type string `json:"type"`
+
+	// Name is the operation name, empty for anonymous operations.
+	Name string `json:"name,omitempty"`
+
+	// Depth is the maximum field nesting.
+	Depth int `json:"depth"`
+
+	// Complexity is the number of selected fields.
+	Complexity int `json:"complexity"`
+
+	// Aliases is the number of aliased fields.
+	Aliases int `json:"aliases"`
+
+	// BatchSize is the number of operations in a batched request, or 1.
+	BatchSize int `json:"batch_size"`
+}
+
+// graphQLRequest is one operation of a GraphQL request body.
+type graphQLRequest struct {
+	Query         string `json:"query"`
+	OperationName string `json:"operationName"`
+}
+
+// graphQLViolation is a document rejected by the guard.
+type graphQLViolation struct {
+	code    string
+	message string
+}
+
+func (v *graphQLViolation) Error() string {
+	return v.message
+}
+
+// maxGraphQLNodes bounds the work of analyzing a document whose fragments
+// expand exponentially, even with MaxComplexity disabled.
+const maxGraphQLNodes = 100000
+
+// DefaultGraphQLGuardConfig returns default GraphQL guard configuration.
+func DefaultGraphQLGuardConfig() GraphQLGuardConfig {
+	return GraphQLGuardConfig{
+		MaxDepth:           10,
+		MaxComplexity:      1000,
+		MaxAliases:         20,
+		MaxBatchSize:       10,
+		MaxBodyBytes:       1 << 20, // 1 MB
+		BlockIntrospection: ginji.GetMode() == ginji.ReleaseMode,
+		RouteLabel:         true,
+	}
+}
+
+// GraphQLGuard returns a GraphQL guard with default configuration.
+func GraphQLGuard() ginji.Middleware {
+	return GraphQLGuardWithConfig(DefaultGraphQLGuardConfig())
+}
+
+// GraphQLGuardWithConfig returns middleware that parses GraphQL requests
+// (GET, JSON and application/graphql POST) and rejects operations that are
+// too deep, too complex, use too many aliases or query the schema when
+// introspection is blocked. Rejections use the GraphQL error format with
+// status 400. The operation is available to handlers and other middleware
+// through GraphQLOperationFrom.
+//
+//	api := app.Group("/graphql")
+//	api.Use(middleware.GraphQLGuard())
+func GraphQLGuardWithConfig(config GraphQLGuardConfig) ginji.Middleware {
+	defaults := DefaultGraphQLGuardConfig()
+	if config.MaxDepth <= 0 {
+		config.MaxDepth = defaults.MaxDepth
+	}
+	if config.MaxComplexity <= 0 {
+		config.MaxComplexity = defaults.MaxComplexity
+	}
+	if config.MaxAliases <= 0 {
+		config.MaxAliases = defaults.MaxAliases
+	}
+	if config.MaxBatchSize <= 0 {
+		config.MaxBatchSize = defaults.MaxBatchSize
+	}
+	if config.MaxBodyBytes <= 0 {
+		config.MaxBodyBytes = defaults.MaxBodyBytes
+	}
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		requests, err := readGraphQLRequests(c, config.MaxBodyBytes)
+		if errors.Is(err, errBodyTooLarge) {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, graphQLErrors("BAD_REQUEST",
+				fmt.Sprintf("Request body too large. Maximum allowed size is %d bytes", config.MaxBodyBytes)))
+			return nil
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, graphQLErrors("BAD_REQUEST", err.Error()))
+			return nil
+		}
+		if len(requests) == 0 {
+			return c.Next()
+		}
+		if len(requests) > config.MaxBatchSize {
+			c.AbortWithStatusJSON(http.StatusBadRequest, graphQLErrors("BATCH_LIMIT_EXCEEDED",
+				fmt.Sprintf("Batch of %d operations exceeds the maximum of %d", len(requests), config.MaxBatchSize)))
+			return nil
+		}
+
+		var first GraphQLOperation
+		for i, req := range requests {
+			op, err := analyzeGraphQL(req, config)
+			if err != nil {
+				var violation *graphQLViolation
+				if !errors.As(err, &violation) {
+					violation = &graphQLViolation{code: "GRAPHQL_PARSE_FAILED", message: err.Error()}
+				}
+				c.AbortWithStatusJSON(http.StatusBadRequest, graphQLErrors(violation.code, violation.message))
+				return nil
+			}
+			if i == 0 {
+				first = op
+			}
+		}
+		first.BatchSize = len(requests)
+		setContextValue(c, graphQLContextKey, first)
+
+		if config.RouteLabel {
+			switch {
+			case len(requests) > 1:
+				SetRoutePattern(c, RoutePattern(c)+" batch")
+			case first.Name != "":
+				SetRoutePattern(c, RoutePattern(c)+" "+first.Type+" "+first.Name)
+			}
+		}
+
+		return c.Next()
+	}
+}
+
+// GraphQLOperationFrom returns the operation recorded by GraphQLGuard. For
+// batched requests it is the first operation.
+func GraphQLOperationFrom(c *ginji.Context) (GraphQLOperation, bool) {
+	op, ok := c.Req.Context().Value(graphQLContextKey).(GraphQLOperation)
+	return op, ok
+}
+
+// readGraphQLRequests extracts the operations of a GraphQL request. It
+// returns none for requests that carry no document, such as persisted
+// queries or unrelated methods.
+func readGraphQLRequests(c *ginji.Context, maxBytes int64) ([]graphQLRequest, error) {
+	switch c.Req.Method {
+	case http.MethodGet:
+		if query := c.Query("query"); query != "" {
+			return []graphQLRequest{{Query: query, OperationName: c.Query("operationName")}}, nil
+		}
+		return nil, nil
+	case http.MethodPost:
+	default:
+		return nil, nil
+	}
+
+	body, err := bufferRequestBody(c, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(c.Header("Content-Type"))
+	if mediaType == "application/graphql" {
+		return []graphQLRequest{{Query: string(body), OperationName: c.Query("operationName")}}, nil
+	}
+
+	trimmed := strings.TrimSpace(string(body))
+	if trimmed == "" {
+		return nil, nil
+	}
+	var requests []graphQLRequest
+	if strings.HasPrefix(trimmed, "[") {
+		err = json.Unmarshal(body, &requests)
+	} else {
+		var req graphQLRequest
+		err = json.Unmarshal(body, &req)
+		requests = []graphQLRequest{req}
+	}
+	if err != nil {
+		return nil, errors.New("Invalid GraphQL request body")
+	}
+
+	// Requests without a document are left to the server
+	if len(requests) == 1 && requests[0].Query == "" {
+		return nil, nil
+	}
+	return requests, nil
+}
+
+// analyzeGraphQL parses req and checks it against the configured limits.
+func analyzeGraphQL(req graphQLRequest, config GraphQLGuardConfig) (GraphQLOperation, error) {
+	if req.Query == "" {
+		return GraphQLOperation{}, &graphQLViolation{code: "BAD_REQUEST", message: "Missing GraphQL query"}
+	}
+	doc, err := parseGraphQL(req.Query)
+	if err != nil {
+		return GraphQLOperation{}, err
+	}
+	op, err := doc.operation(req.OperationName)
+	if err != nil {
+		return GraphQLOperation{}, err
+	}
+
+	a := &gqlAnalyzer{doc: doc, config: config, visiting: make(map[string]bool)}
+	if err := a.walk(op.selections, 0); err != nil {
+		return GraphQLOperation{}, err
+	}
+	return GraphQLOperation{
+		Type:       op.typ,
+		Name:       op.name,
+		Depth:      a.depth,
+		Complexity: a.complexity,
+		Aliases:    a.aliases,
+	}, nil
+}
+
+// gqlAnalyzer measures an operation, expanding fragment spreads.
+type gqlAnalyzer struct {
+	doc        *gqlDocument
+	config     GraphQLGuardConfig
+	visiting   map[string]bool // fragments on the current path
+	nodes      int
+	depth      int
+	complexity int
+	aliases    int
+}
+
+// walk measures selections at depth and stops at the first violated limit.
+func (a *gqlAnalyzer) walk(selections []*gqlSelection, depth int) error {
+	for _, sel := range selections {
+		a.nodes++
+		if a.nodes > maxGraphQLNodes {
+			return &graphQLViolation{code: "COMPLEXITY_LIMIT_EXCEEDED", message: "Query is too complex"}
+		}
+
+		switch {
+		case sel.spread != "":
+			fragment, ok := a.doc.fragments[sel.spread]
+			if !ok {
+				return &graphQLViolation{code: "GRAPHQL_VALIDATION_FAILED", message: fmt.Sprintf("Unknown fragment %q", sel.spread)}
+			}
+			if a.visiting[sel.spread] {
+				return &graphQLViolation{code: "GRAPHQL_VALIDATION_FAILED", message: fmt.Sprintf("Fragment %q spreads itself", sel.spread)}
+			}
+			a.visiting[sel.spread] = true
+			if err := a.walk(fragment, depth); err != nil {
+				return err
+			}
+			delete(a.visiting, sel.spread)
+
+		case sel.name == "":
+			if err := a.walk(sel.selections, depth); err != nil {
+				return err
+			}
+
+		default:
+			if a.config.BlockIntrospection && (sel.name == "__schema" || sel.name == "__type") {
+				return &graphQLViolation{code: "INTROSPECTION_DISABLED", message: "GraphQL introspection is not allowed"}
+			}
+			a.complexity++
+			if a.complexity > a.config.MaxComplexity {
+				return &graphQLViolation{code: "COMPLEXITY_LIMIT_EXCEEDED",
+					message: fmt.Sprintf("Query complexity exceeds the maximum of %d", a.config.MaxComplexity)}
+			}
+			if sel.alias != "" {
+				a.aliases++
+				if a.aliases > a.config.MaxAliases {
+					return &graphQLViolation{code: "ALIAS_LIMIT_EXCEEDED",
+						message: fmt.Sprintf("Query uses more than %d aliases", a.config.MaxAliases)}
+				}
+			}
+			a.depth = max(a.depth, depth+1)
+			if a.depth > a.config.MaxDepth {
+				return &graphQLViolation{code: "DEPTH_LIMIT_EXCEEDED",
+					message: fmt.Sprintf("Query depth exceeds the maximum of %d", a.config.MaxDepth)}
+			}
+			if err := a.walk(sel.selections, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// graphQLErrors returns a GraphQL error response body.
+func graphQLErrors(code, message string) ginji.H {
+	return ginji.H{
+		"errors": []ginji.H{{
+			"message":    message,
+			"extensions": ginji.H{"code": code},
+		}},
+	}
+}
@@ -0,0 +1,167 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ginjigo/ginji"
+)
+
+func graphQLBody(query, operationName string) *strings.Reader {
+	body, _ := json.Marshal(map[string]string{"query": query, "operationName": operationName})
+	return strings.NewReader(string(body))
+}
+
+func TestGraphQLGuardLimits(t *testing.T) {
+	config := DefaultGraphQLGuardConfig()
+	config.MaxDepth = 3
+	config.MaxComplexity = 5
+	config.MaxAliases = 2
+	config.BlockIntrospection = true
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		code   string
+	}{
+		{"allowed", `query GetUser { user(id: 1) { name friends { name } } }`, ginji.StatusOK, ""},
+		{"too deep", `{ a { b { c { d } } } }`, ginji.StatusBadRequest, "DEPTH_LIMIT_EXCEEDED"},
+		{"too complex", `{ a b c d e f }`, ginji.StatusBadRequest, "COMPLEXITY_LIMIT_EXCEEDED"},
+		{"too many aliases", `{ a1: a a2: a a3: a }`, ginji.StatusBadRequest, "ALIAS_LIMIT_EXCEEDED"},
+		{"deep fragment", `{ a { ...F } } fragment F on T { b { c { d } } }`, ginji.StatusBadRequest, "DEPTH_LIMIT_EXCEEDED"},
+		{"fragment cycle", `{ a { ...F } } fragment F on T { b { ...F } }`, ginji.StatusBadRequest, "GRAPHQL_VALIDATION_FAILED"},
+		{"unknown fragment", `{ a { ...F } }`, ginji.StatusBadRequest, "GRAPHQL_VALIDATION_FAILED"},
+		{"introspection", `{ __schema { types { name } } }`, ginji.StatusBadRequest, "INTROSPECTION_DISABLED"},
+		{"typename", `{ a { __typename } }`, ginji.StatusOK, ""},
+		{"syntax error", `{ a `, ginji.StatusBadRequest, "GRAPHQL_PARSE_FAILED"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := ginji.New()
+			app.Use(GraphQLGuardWithConfig(config))
+			handler := func(c *ginji.Context) error {
+				op, _ := GraphQLOperationFrom(c)
+				return c.JSON(ginji.StatusOK, ginji.H{"operation": op, "route": RoutePattern(c)})
+			}
+			app.Get("/graphql", handler)
+			app.Post("/graphql", handler)
+			w := ginji.PerformRequest(app, "POST", "/graphql", graphQLBody(tt.query, ""))
+			ginji.AssertStatus(t, w, tt.status)
+			if tt.code != "" {
+				ginji.AssertBody(t, w, `"code":"`+tt.code+`"`)
+			}
+		})
+	}
+}
+
+func TestGraphQLGuardOperation(t *testing.T) {
+	app := ginji.New()
+	app.Use(GraphQLGuardWithConfig(DefaultGraphQLGuardConfig()))
+	handler := func(c *ginji.Context) error {
+		op, _ := GraphQLOperationFrom(c)
+		return c.JSON(ginji.StatusOK, ginji.H{"operation": op, "route": RoutePattern(c)})
+	}
+	app.Get("/graphql", handler)
+	app.Post("/graphql", handler)
+
+	query := `query A { a } mutation UpdateUser($id: ID!) { updateUser(id: $id) { id name } }`
+	w := ginji.PerformRequest(app, "POST", "/graphql", graphQLBody(query, "UpdateUser"))
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+
+	var resp struct {
+		Operation GraphQLOperation `json:"operation"`
+		Route     string           `json:"route"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	want := GraphQLOperation{Type: "mutation", Name: "UpdateUser", Depth: 2, Complexity: 3, BatchSize: 1}
+	if resp.Operation != want {
+		t.Errorf("Expected operation %+v, got %+v", want, resp.Operation)
+	}
+	if resp.Route != "/graphql mutation UpdateUser" {
+		t.Errorf("Expected route label, got %q", resp.Route)
+	}
+
+	w = ginji.PerformRequest(app, "POST", "/graphql", graphQLBody(query, ""))
+	ginji.AssertStatus(t, w, ginji.StatusBadRequest)
+}
+
+func TestGraphQLGuardRequestFormats(t *testing.T) {
+	app := ginji.New()
+	app.Use(GraphQLGuardWithConfig(DefaultGraphQLGuardConfig()))
+	handler := func(c *ginji.Context) error {
+		op, _ := GraphQLOperationFrom(c)
+		return c.JSON(ginji.StatusOK, ginji.H{"operation": op, "route": RoutePattern(c)})
+	}
+	app.Get("/graphql", handler)
+	app.Post("/graphql", handler)
+
+	w := ginji.PerformRequest(app, "GET", "/graphql?query="+url.QueryEscape("query Q { a }"), nil)
+	ginji.AssertBody(t, w, `"route":"/graphql query Q"`)
+
+	w = ginji.NewRequest(app, "POST", "/graphql").
+		Header("Content-Type", "application/graphql").
+		Body(strings.NewReader("{ a }")).
+		Do()
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	ginji.AssertBody(t, w, `"route":"/graphql"`)
+
+	w = ginji.PerformRequest(app, "POST", "/graphql", strings.NewReader(`[{"query":"{ a }"},{"query":"{ b }"}]`))
+	ginji.AssertBody(t, w, `"batch_size":2`)
+	ginji.AssertBody(t, w, `"route":"/graphql batch"`)
+
+	w = ginji.PerformRequest(app, "POST", "/graphql", strings.NewReader(`{"extensions":{}}`))
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+
+	w = ginji.PerformRequest(app, "POST", "/graphql", strings.NewReader(`{"query":`))
+	ginji.AssertStatus(t, w, ginji.StatusBadRequest)
+}
+
+func TestGraphQLGuardBatchLimit(t *testing.T) {
+	config := DefaultGraphQLGuardConfig()
+	config.MaxBatchSize = 2
+	app := ginji.New()
+	app.Use(GraphQLGuardWithConfig(config))
+	handler := func(c *ginji.Context) error {
+		op, _ := GraphQLOperationFrom(c)
+		return c.JSON(ginji.StatusOK, ginji.H{"operation": op, "route": RoutePattern(c)})
+	}
+	app.Get("/graphql", handler)
+	app.Post("/graphql", handler)
+
+	body := `[{"query":"{ a }"},{"query":"{ b }"},{"query":"{ c }"}]`
+	w := ginji.PerformRequest(app, "POST", "/graphql", strings.NewReader(body))
+	ginji.AssertStatus(t, w, ginji.StatusBadRequest)
+	ginji.AssertBody(t, w, "BATCH_LIMIT_EXCEEDED")
+}
+
+func TestGraphQLGuardFragmentExpansion(t *testing.T) {
+	// Each fragment spreads the next one twice, doubling the field count
+	var b strings.Builder
+	b.WriteString("{ ...F0 }")
+	for i := 0; i < 30; i++ {
+		b.WriteString(" fragment F" + strconv.Itoa(i) + " on T { ...F" + strconv.Itoa(i+1) + " ...F" + strconv.Itoa(i+1) + " }")
+	}
+	b.WriteString(" fragment F30 on T { a }")
+
+	config := DefaultGraphQLGuardConfig()
+	config.MaxComplexity = 1 << 30
+	app := ginji.New()
+	app.Use(GraphQLGuardWithConfig(config))
+	handler := func(c *ginji.Context) error {
+		op, _ := GraphQLOperationFrom(c)
+		return c.JSON(ginji.StatusOK, ginji.H{"operation": op, "route": RoutePattern(c)})
+	}
+	app.Get("/graphql", handler)
+	app.Post("/graphql", handler)
+
+	w := ginji.PerformRequest(app, "POST", "/graphql", graphQLBody(b.String(), ""))
+	ginji.AssertStatus(t, w, ginji.StatusBadRequest)
+	ginji.AssertBody(t, w, "COMPLEXITY_LIMIT_EXCEEDED")
+}
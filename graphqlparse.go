@@ -0,0 +1,416 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// This file holds a small GraphQL parser for GraphQLGuard. It understands
+// just enough of the executable document syntax to measure documents; it
+// does not validate them against a schema.
+
+// gqlTokenKind is the kind of a GraphQL token.
+type gqlTokenKind int
+
+const (
+	gqlEOF gqlTokenKind = iota
+	gqlPunct
+	gqlName
+	gqlValue // number or string
+)
+
+// gqlToken is a lexed GraphQL token.
+type gqlToken struct {
+	kind  gqlTokenKind
+	value string
+}
+
+// gqlSelection is a field, fragment spread or inline fragment.
+type gqlSelection struct {
+	name       string // field name, empty for fragments
+	alias      string
+	spread     string // fragment name of a spread
+	selections []*gqlSelection
+}
+
+// gqlOperation is an operation definition.
+type gqlOperation struct {
+	typ        string // query, mutation or subscription
+	name       string
+	selections []*gqlSelection
+}
+
+// gqlDocument is a parsed executable document.
+type gqlDocument struct {
+	operations []*gqlOperation
+	fragments  map[string][]*gqlSelection
+}
+
+// errGraphQLSyntax is wrapped by all parse errors.
+var errGraphQLSyntax = errors.New("graphql: syntax error")
+
+// lexGraphQL splits a document into tokens.
+func lexGraphQL(src string) ([]gqlToken, error) {
+	var tokens []gqlToken
+	i := 0
+	for i < len(src) {
+		ch := src[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' || ch == ',':
+			i++
+		case ch == '#':
+			for i < len(src) && src[i] != '\n' && src[i] != '\r' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "\ufeff"):
+			i += len("\ufeff")
+		case strings.HasPrefix(src[i:], "..."):
+			tokens = append(tokens, gqlToken{gqlPunct, "..."})
+			i += 3
+		case strings.IndexByte("!$&()/:=@[]{|}", ch) >= 0:
+			tokens = append(tokens, gqlToken{gqlPunct, string(ch)})
+			i++
+		case ch == '_' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z':
+			start := i
+			for i < len(src) && isGraphQLNameChar(src[i]) {
+				i++
+			}
+			tokens = append(tokens, gqlToken{gqlName, src[start:i]})
+		case ch == '-' || ch >= '0' && ch <= '9':
+			start := i
+			i++
+			for i < len(src) && (isGraphQLNameChar(src[i]) || src[i] == '.' || src[i] == '+' || src[i] == '-') {
+				i++
+			}
+			tokens = append(tokens, gqlToken{gqlValue, src[start:i]})
+		case strings.HasPrefix(src[i:], `"""`):
+			end := strings.Index(src[i+3:], `"""`)
+			for end > 0 && src[i+3+end-1] == '\\' {
+				next := strings.Index(src[i+3+end+1:], `"""`)
+				if next < 0 {
+					end = -1
+					break
+				}
+				end += next + 1
+			}
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unterminated block string", errGraphQLSyntax)
+			}
+			tokens = append(tokens, gqlToken{gqlValue, src[i : i+3+end+3]})
+			i += 3 + end + 3
+		case ch == '"':
+			start := i
+			i++
+			for i < len(src) && src[i] != '"' {
+				if src[i] == '\\' {
+					i++
+				}
+				if i < len(src) && (src[i] == '\n' || src[i] == '\r') {
+					return nil, fmt.Errorf("%w: unterminated string", errGraphQLSyntax)
+				}
+				i++
+			}
+			if i >= len(src) {
+				return nil, fmt.Errorf("%w: unterminated string", errGraphQLSyntax)
+			}
+			i++
+			tokens = append(tokens, gqlToken{gqlValue, src[start:i]})
+		default:
+			return nil, fmt.Errorf("%w: unexpected character %q", errGraphQLSyntax, ch)
+		}
+	}
+	return tokens, nil
+}
+
+// isGraphQLNameChar reports whether ch may continue a name.
+func isGraphQLNameChar(ch byte) bool {
+	return ch == '_' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9'
+}
+
+// gqlParser parses a token stream.
+type gqlParser struct {
+	tokens []gqlToken
+	pos    int
+	depth  int // nesting of selection sets, bounded to stop stack exhaustion
+}
+
+// maxGraphQLNesting bounds the parser's recursion regardless of MaxDepth.
+const maxGraphQLNesting = 256
+
+// parseGraphQL parses an executable document.
+func parseGraphQL(src string) (*gqlDocument, error) {
+	tokens, err := lexGraphQL(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &gqlParser{tokens: tokens}
+	doc := &gqlDocument{fragments: make(map[string][]*gqlSelection)}
+
+	for p.peek().kind != gqlEOF {
+		tok := p.peek()
+		switch {
+		case tok.kind == gqlPunct && tok.value == "{":
+			selections, err := p.parseSelectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, &gqlOperation{typ: "query", selections: selections})
+		case tok.kind == gqlName && (tok.value == "query" || tok.value == "mutation" || tok.value == "subscription"):
+			op, err := p.parseOperation()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, op)
+		case tok.kind == gqlName && tok.value == "fragment":
+			name, selections, err := p.parseFragment()
+			if err != nil {
+				return nil, err
+			}
+			if _, exists := doc.fragments[name]; exists {
+				return nil, fmt.Errorf("%w: duplicate fragment %q", errGraphQLSyntax, name)
+			}
+			doc.fragments[name] = selections
+		default:
+			return nil, p.unexpected()
+		}
+	}
+
+	if len(doc.operations) == 0 {
+		return nil, fmt.Errorf("%w: no operation", errGraphQLSyntax)
+	}
+	return doc, nil
+}
+
+func (p *gqlParser) peek() gqlToken {
+	if p.pos >= len(p.tokens) {
+		return gqlToken{kind: gqlEOF}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *gqlParser) next() gqlToken {
+	tok := p.peek()
+	if p.pos < len(p.tokens) {
+		p.pos++
+	}
+	return tok
+}
+
+// isPunct reports whether the next token is the punctuator s.
+func (p *gqlParser) isPunct(s string) bool {
+	tok := p.peek()
+	return tok.kind == gqlPunct && tok.value == s
+}
+
+// expectPunct consumes the punctuator s.
+func (p *gqlParser) expectPunct(s string) error {
+	if !p.isPunct(s) {
+		return p.unexpected()
+	}
+	p.pos++
+	return nil
+}
+
+// expectName consumes and returns a name.
+func (p *gqlParser) expectName() (string, error) {
+	tok := p.peek()
+	if tok.kind != gqlName {
+		return "", p.unexpected()
+	}
+	p.pos++
+	return tok.value, nil
+}
+
+// unexpected returns an error for the next token.
+func (p *gqlParser) unexpected() error {
+	tok := p.peek()
+	if tok.kind == gqlEOF {
+		return fmt.Errorf("%w: unexpected end of document", errGraphQLSyntax)
+	}
+	return fmt.Errorf("%w: unexpected %q", errGraphQLSyntax, tok.value)
+}
+
+// enter increases the nesting depth.
+func (p *gqlParser) enter() error {
+	p.depth++
+	if p.depth > maxGraphQLNesting {
+		return fmt.Errorf("%w: document nested too deeply", errGraphQLSyntax)
+	}
+	return nil
+}
+
+// parseOperation parses a named or typed operation definition.
+func (p *gqlParser) parseOperation() (*gqlOperation, error) {
+	op := &gqlOperation{typ: p.next().value}
+	if p.peek().kind == gqlName {
+		op.name = p.next().value
+	}
+	if p.isPunct("(") {
+		if err := p.skipBalanced("(", ")"); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.skipDirectives(); err != nil {
+		return nil, err
+	}
+	selections, err := p.parseSelectionSet()
+	if err != nil {
+		return nil, err
+	}
+	op.selections = selections
+	return op, nil
+}
+
+// parseFragment parses a fragment definition.
+func (p *gqlParser) parseFragment() (string, []*gqlSelection, error) {
+	p.next() // fragment
+	name, err := p.expectName()
+	if err != nil {
+		return "", nil, err
+	}
+	if on, err := p.expectName(); err != nil || on != "on" {
+		return "", nil, fmt.Errorf("%w: expected \"on\" in fragment %q", errGraphQLSyntax, name)
+	}
+	if _, err := p.expectName(); err != nil {
+		return "", nil, err
+	}
+	if err := p.skipDirectives(); err != nil {
+		return "", nil, err
+	}
+	selections, err := p.parseSelectionSet()
+	return name, selections, err
+}
+
+// parseSelectionSet parses a braced list of selections.
+func (p *gqlParser) parseSelectionSet() ([]*gqlSelection, error) {
+	if err := p.expectPunct("{"); err != nil {
+		return nil, err
+	}
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+
+	var selections []*gqlSelection
+	for !p.isPunct("}") {
+		selection, err := p.parseSelection()
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, selection)
+	}
+	p.pos++
+	if len(selections) == 0 {
+		return nil, fmt.Errorf("%w: empty selection set", errGraphQLSyntax)
+	}
+	return selections, nil
+}
+
+// parseSelection parses a field, fragment spread or inline fragment.
+func (p *gqlParser) parseSelection() (*gqlSelection, error) {
+	if p.isPunct("...") {
+		p.pos++
+		tok := p.peek()
+		if tok.kind == gqlName && tok.value != "on" {
+			p.pos++
+			return &gqlSelection{spread: tok.value}, p.skipDirectives()
+		}
+		if tok.kind == gqlName {
+			p.pos++
+			if _, err := p.expectName(); err != nil {
+				return nil, err
+			}
+		}
+		if err := p.skipDirectives(); err != nil {
+			return nil, err
+		}
+		selections, err := p.parseSelectionSet()
+		return &gqlSelection{selections: selections}, err
+	}
+
+	name, err := p.expectName()
+	if err != nil {
+		return nil, err
+	}
+	field := &gqlSelection{name: name}
+	if p.isPunct(":") {
+		p.pos++
+		field.alias = name
+		if field.name, err = p.expectName(); err != nil {
+			return nil, err
+		}
+	}
+	if p.isPunct("(") {
+		if err := p.skipBalanced("(", ")"); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.skipDirectives(); err != nil {
+		return nil, err
+	}
+	if p.isPunct("{") {
+		if field.selections, err = p.parseSelectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return field, nil
+}
+
+// skipDirectives skips any directives.
+func (p *gqlParser) skipDirectives() error {
+	for p.isPunct("@") {
+		p.pos++
+		if _, err := p.expectName(); err != nil {
+			return err
+		}
+		if p.isPunct("(") {
+			if err := p.skipBalanced("(", ")"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// skipBalanced skips from an opening punctuator to its matching closing
+// one, such as arguments or variable definitions with nested values.
+func (p *gqlParser) skipBalanced(open, close string) error {
+	if err := p.expectPunct(open); err != nil {
+		return err
+	}
+	nesting := 1
+	for nesting > 0 {
+		tok := p.next()
+		switch {
+		case tok.kind == gqlEOF:
+			return fmt.Errorf("%w: unclosed %q", errGraphQLSyntax, open)
+		case tok.kind != gqlPunct:
+		case tok.value == "(" || tok.value == "[" || tok.value == "{":
+			nesting++
+			if nesting > maxGraphQLNesting {
+				return fmt.Errorf("%w: value nested too deeply", errGraphQLSyntax)
+			}
+		case tok.value == ")" || tok.value == "]" || tok.value == "}":
+			nesting--
+		}
+	}
+	return nil
+}
+
+// operation returns the operation to execute: the one named name, or the
+// only one if name is empty.
+func (doc *gqlDocument) operation(name string) (*gqlOperation, error) {
+	if name == "" {
+		if len(doc.operations) > 1 {
+			return nil, errors.New("graphql: operationName is required for documents with several operations")
+		}
+		return doc.operations[0], nil
+	}
+	for _, op := range doc.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("graphql: unknown operation %q", name)
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseGraphQL(t *testing.T) {
+	doc, err := parseGraphQL(`
+		# Fetch a user
+		query GetUser($id: ID!, $opts: [Opt!] = [{a: "}"}]) @cached(ttl: 60) {
+			u: user(id: $id, filter: {name: """block "quoted" \""" string"""}) {
+				...UserFields
+				... on Admin @include(if: true) { permissions }
+				... { id }
+			}
+		}
+		fragment UserFields on User { name, email }
+	`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	op, err := doc.operation("GetUser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op.typ != "query" || len(op.selections) != 1 {
+		t.Fatalf("Unexpected operation %+v", op)
+	}
+	user := op.selections[0]
+	if user.name != "user" || user.alias != "u" || len(user.selections) != 3 {
+		t.Fatalf("Unexpected field %+v", user)
+	}
+	if user.selections[0].spread != "UserFields" {
+		t.Errorf("Expected fragment spread, got %+v", user.selections[0])
+	}
+	if len(doc.fragments["UserFields"]) != 2 {
+		t.Errorf("Expected fragment with 2 fields, got %d", len(doc.fragments["UserFields"]))
+	}
+}
+
+func TestParseGraphQLErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"empty", ""},
+		{"unclosed", "{ a { b }"},
+		{"empty selection", "{ }"},
+		{"unterminated string", `{ a(s: "x) }`},
+		{"unterminated block string", `{ a(s: """x) }`},
+		{"bad character", "{ a ; }"},
+		{"schema definition", "type Query { a: Int }"},
+		{"duplicate fragment", "{ ...F } fragment F on T { a } fragment F on T { b }"},
+		{"too deeply nested", strings.Repeat("{ a ", 300) + strings.Repeat("}", 300)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseGraphQL(tt.query); !errors.Is(err, errGraphQLSyntax) {
+				t.Errorf("Expected syntax error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestGraphQLDocumentOperation(t *testing.T) {
+	doc, err := parseGraphQL("query A { a } mutation B { b }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := doc.operation(""); err == nil {
+		t.Error("Expected error without operation name")
+	}
+	if _, err := doc.operation("C"); err == nil {
+		t.Error("Expected error for unknown operation")
+	}
+	if op, err := doc.operation("B"); err != nil || op.typ != "mutation" {
+		t.Errorf("Expected mutation B, got %+v, %v", op, err)
+	}
+}
@@ -0,0 +1,359 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// gRPC-Web content types. The text variants carry base64-encoded frames.
+const (
+	grpcWebContentType     = "application/grpc-web"
+	grpcWebTextContentType = "application/grpc-web-text"
+	grpcContentType        = "application/grpc"
+)
+
+// grpcWebTrailerFlag marks a gRPC-Web frame that carries trailers.
+const grpcWebTrailerFlag = 0x80
+
+// grpcWebRequestHeaders are the request headers sent by gRPC-Web clients.
+var grpcWebRequestHeaders = []string{"Content-Type", "X-Grpc-Web", "X-User-Agent", "Grpc-Timeout", "Authorization"}
+
+// grpcWebExposedHeaders are the response headers gRPC-Web clients read.
+var grpcWebExposedHeaders = []string{"Grpc-Status", "Grpc-Message", "Grpc-Status-Details-Bin"}
+
+// GRPCWebConfig defines the configuration for the gRPC-Web middleware.
+type GRPCWebConfig struct {
+	// Backend serves the translated gRPC requests. A *grpc.Server can be
+	// used directly since it implements http.Handler; a reverse proxy with
+	// an HTTP/2 transport reaches a remote server. Required.
+	Backend http.Handler
+
+	// AllowedOrigins lists the origins allowed to call the service from a
+	// browser. Entries may be "*" or use a wildcard subdomain such as
+	// "https://*.example.com".
+	// Default: none (same origin only)
+	AllowedOrigins []string
+
+	// AllowedHeaders are request headers allowed in addition to the ones
+	// gRPC-Web clients send, e.g. custom metadata.
+	AllowedHeaders []string
+
+	// ExposedHeaders are response metadata headers the browser may read in
+	// addition to the gRPC status headers.
+	ExposedHeaders []string
+
+	// AllowCredentials allows cookies on cross-origin calls. It cannot be
+	// combined with a "*" origin.
+	// Default: false
+	AllowCredentials bool
+
+	// MaxAge is how long browsers may cache preflight responses.
+	// Default: 10 minutes
+	MaxAge time.Duration
+
+	// SkipFunc allows skipping the translation for certain requests.
+	SkipFunc Skipper
+}
+
+// DefaultGRPCWebConfig returns default gRPC-Web configuration.
+func DefaultGRPCWebConfig() GRPCWebConfig {
+	return GRPCWebConfig{
+		MaxAge: 10 * time.Minute,
+	}
+}
+
+// GRPCWeb returns middleware serving gRPC-Web clients from backend.
+//
+//	app.Use(middleware.GRPCWeb(grpcServer))
+func GRPCWeb(backend http.Handler) ginji.Middleware {
+	config := DefaultGRPCWebConfig()
+	config.Backend = backend
+	return GRPCWebWithConfig(config)
+}
+
+// GRPCWebWithConfig returns middleware that translates gRPC-Web requests,
+// in binary or base64 text encoding, to gRPC requests for the backend and
+// the backend's responses back to gRPC-Web, sending gRPC trailers as a
+// trailer frame. It answers CORS preflight requests of gRPC-Web clients.
+// Other requests, such as REST calls, continue down the chain. It panics
+// if Backend is nil or if credentials are allowed for any origin.
+func GRPCWebWithConfig(config GRPCWebConfig) ginji.Middleware {
+	defaults := DefaultGRPCWebConfig()
+	if config.Backend == nil {
+		panic("GRPCWeb: Backend is required")
+	}
+	if config.MaxAge == 0 {
+		config.MaxAge = defaults.MaxAge
+	}
+
+	anyOrigin := false
+	for _, origin := range config.AllowedOrigins {
+		anyOrigin = anyOrigin || origin == "*"
+	}
+	if anyOrigin && config.AllowCredentials {
+		panic("GRPCWeb: cannot use AllowCredentials with wildcard origin '*'")
+	}
+	origins := newOriginMatcher(config.AllowedOrigins)
+	allowedHeaders := strings.Join(append(append([]string{}, grpcWebRequestHeaders...), config.AllowedHeaders...), ", ")
+	exposedHeaders := strings.Join(append(append([]string{}, grpcWebExposedHeaders...), config.ExposedHeaders...), ", ")
+
+	// allowOrigin sets the CORS headers for origin and reports whether it
+	// may call the service.
+	allowOrigin := func(c *ginji.Context, origin string) bool {
+		AddVary(c.Res.Header(), "Origin")
+		if origin == "" || sameOrigin(c.Req, origin) {
+			return true
+		}
+		if !anyOrigin && !origins.match(origin) {
+			return false
+		}
+		if anyOrigin {
+			c.SetHeader("Access-Control-Allow-Origin", "*")
+		} else {
+			c.SetHeader("Access-Control-Allow-Origin", origin)
+		}
+		if config.AllowCredentials {
+			c.SetHeader("Access-Control-Allow-Credentials", "true")
+		}
+		return true
+	}
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		if isGRPCWebPreflight(c.Req) {
+			if !allowOrigin(c, c.Header("Origin")) {
+				c.AbortWithStatusJSON(ginji.StatusForbidden, ginji.H{
+					"error": "Origin not allowed",
+				})
+				return nil
+			}
+			c.SetHeader("Access-Control-Allow-Methods", "POST, OPTIONS")
+			c.SetHeader("Access-Control-Allow-Headers", allowedHeaders)
+			c.SetHeader("Access-Control-Max-Age", strconv.Itoa(int(config.MaxAge.Seconds())))
+			c.Res.WriteHeader(ginji.StatusNoContent)
+			c.Abort()
+			return nil
+		}
+
+		contentType := c.Header("Content-Type")
+		if !strings.HasPrefix(contentType, grpcWebContentType) {
+			return c.Next()
+		}
+		if !allowOrigin(c, c.Header("Origin")) {
+			c.AbortWithStatusJSON(ginji.StatusForbidden, ginji.H{
+				"error": "Origin not allowed",
+			})
+			return nil
+		}
+		c.SetHeader("Access-Control-Expose-Headers", exposedHeaders)
+
+		text := strings.HasPrefix(contentType, grpcWebTextContentType)
+		req := c.Req.Clone(c.Req.Context())
+		req.ProtoMajor, req.ProtoMinor, req.Proto = 2, 0, "HTTP/2"
+		req.ContentLength = -1
+		req.Header.Del("Content-Length")
+		req.Header.Set("Te", "trailers")
+		if text {
+			req.Header.Set("Content-Type", grpcContentType+strings.TrimPrefix(contentType, grpcWebTextContentType))
+			req.Body = io.NopCloser(&grpcWebTextReader{r: c.Req.Body})
+		} else {
+			req.Header.Set("Content-Type", grpcContentType+strings.TrimPrefix(contentType, grpcWebContentType))
+		}
+
+		w := &grpcWebResponseWriter{w: c.Res, header: make(http.Header), text: text}
+		config.Backend.ServeHTTP(w, req)
+		w.finish()
+		c.Abort()
+		return nil
+	}
+}
+
+// isGRPCWebPreflight reports whether r is a CORS preflight request of a
+// gRPC-Web client.
+func isGRPCWebPreflight(r *http.Request) bool {
+	if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+		return false
+	}
+	for _, name := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+		if strings.EqualFold(strings.TrimSpace(name), "x-grpc-web") {
+			return true
+		}
+	}
+	return false
+}
+
+// grpcWebResponseWriter translates a gRPC response to gRPC-Web. Headers
+// are buffered so trailers, which the backend declares with the Trailer
+// header or sets with http.TrailerPrefix, can be sent as a trailer frame.
+type grpcWebResponseWriter struct {
+	w           http.ResponseWriter
+	header      http.Header
+	declared    []string
+	text        bool
+	wroteHeader bool
+}
+
+// Header returns the backend's response headers.
+func (w *grpcWebResponseWriter) Header() http.Header {
+	return w.header
+}
+
+// WriteHeader sends the headers that are not trailers. gRPC always
+// responds with 200 and reports errors in the status trailers.
+func (w *grpcWebResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	for _, value := range w.header.Values("Trailer") {
+		for _, name := range strings.Split(value, ",") {
+			w.declared = append(w.declared, http.CanonicalHeaderKey(strings.TrimSpace(name)))
+		}
+	}
+
+	header := w.w.Header()
+	for name, values := range w.header {
+		if name == "Trailer" || name == "Content-Length" || strings.HasPrefix(name, http.TrailerPrefix) {
+			continue
+		}
+		header[name] = values
+	}
+	contentType := grpcWebContentType
+	if w.text {
+		contentType = grpcWebTextContentType
+	}
+	if suffix, ok := strings.CutPrefix(w.header.Get("Content-Type"), grpcContentType); ok {
+		contentType += suffix
+	}
+	header.Set("Content-Type", contentType)
+	w.w.WriteHeader(code)
+}
+
+// Write sends response frames, encoding them in text mode.
+func (w *grpcWebResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.text {
+		return w.w.Write(b)
+	}
+	if _, err := w.w.Write([]byte(base64.StdEncoding.EncodeToString(b))); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Flush sends buffered frames to the client.
+func (w *grpcWebResponseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	_ = http.NewResponseController(w.w).Flush()
+}
+
+// finish sends the trailers as a trailer frame. A trailers-only response,
+// in which the backend sent the status with the headers, gets the status in
+// both, as clients read it from either.
+func (w *grpcWebResponseWriter) finish() {
+	trailers := make(http.Header)
+	if !w.wroteHeader {
+		for _, name := range grpcWebExposedHeaders {
+			if values, ok := w.header[name]; ok {
+				trailers[name] = values
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	for _, name := range w.declared {
+		if values, ok := w.header[name]; ok {
+			trailers[name] = values
+		}
+	}
+	for name, values := range w.header {
+		if trailer, ok := strings.CutPrefix(name, http.TrailerPrefix); ok {
+			trailers[http.CanonicalHeaderKey(trailer)] = values
+		}
+	}
+
+	names := make([]string, 0, len(trailers))
+	for name := range trailers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var block bytes.Buffer
+	for _, name := range names {
+		for _, value := range trailers[name] {
+			block.WriteString(strings.ToLower(name) + ": " + value + "\r\n")
+		}
+	}
+
+	frame := make([]byte, 5, 5+block.Len())
+	frame[0] = grpcWebTrailerFlag
+	binary.BigEndian.PutUint32(frame[1:], uint32(block.Len()))
+	frame = append(frame, block.Bytes()...)
+	_, _ = w.Write(frame)
+	w.Flush()
+}
+
+// grpcWebTextReader decodes a base64 gRPC-Web request body. Clients may
+// send several padded chunks, so padding can appear mid-stream.
+type grpcWebTextReader struct {
+	r   io.Reader
+	in  []byte // undecoded input
+	out []byte // decoded, unread output
+	err error
+}
+
+// Read decodes complete base64 quanta as they arrive.
+func (t *grpcWebTextReader) Read(p []byte) (int, error) {
+	for len(t.out) == 0 {
+		if t.err != nil {
+			if t.err == io.EOF && len(t.in) > 0 {
+				t.err = io.ErrUnexpectedEOF
+			}
+			return 0, t.err
+		}
+
+		buf := make([]byte, 4096)
+		n, err := t.r.Read(buf)
+		t.in = append(t.in, bytes.Join(bytes.Fields(buf[:n]), nil)...)
+		t.err = err
+
+		// Decode up to the end of each padded quantum separately
+		full := len(t.in) / 4 * 4
+		for start := 0; start < full; {
+			end := full
+			if i := bytes.IndexByte(t.in[start:full], '='); i >= 0 {
+				end = start + (i/4+1)*4
+			}
+			decoded := make([]byte, base64.StdEncoding.DecodedLen(end-start))
+			m, err := base64.StdEncoding.Decode(decoded, t.in[start:end])
+			if err != nil {
+				t.err = err
+				return 0, err
+			}
+			t.out = append(t.out, decoded[:m]...)
+			start = end
+		}
+		t.in = t.in[full:]
+	}
+
+	n := copy(p, t.out)
+	t.out = t.out[n:]
+	return n, nil
+}
@@ -0,0 +1,181 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ginjigo/ginji"
+)
+
+// grpcFrame returns a length-prefixed gRPC frame.
+func grpcFrame(flag byte, payload string) []byte {
+	frame := make([]byte, 5, 5+len(payload))
+	frame[0] = flag
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(payload)))
+	return append(frame, payload...)
+}
+
+// grpcEchoServer answers like a gRPC server: it echoes the request message
+// in upper case and reports the status in trailers.
+func grpcEchoServer(t *testing.T) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 || r.Header.Get("Content-Type") != "application/grpc+proto" {
+			t.Errorf("Unexpected gRPC request %s %q", r.Proto, r.Header.Get("Content-Type"))
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil || len(body) < 5 {
+			t.Fatalf("Failed to read request: %v", err)
+		}
+
+		w.Header().Set("Content-Type", "application/grpc+proto")
+		w.Header().Add("Trailer", "Grpc-Status")
+		w.Header().Add("Trailer", "Grpc-Message")
+		w.WriteHeader(http.StatusOK)
+		w.Write(grpcFrame(0, strings.ToUpper(string(body[5:]))))
+		w.(http.Flusher).Flush()
+		w.Header().Set("Grpc-Status", "0")
+		w.Header().Set("Grpc-Message", "")
+		w.Header().Set(http.TrailerPrefix+"X-Request-Cost", "3")
+	})
+}
+
+func TestGRPCWebBinary(t *testing.T) {
+	config := DefaultGRPCWebConfig()
+	config.Backend = grpcEchoServer(t)
+	app := ginji.New()
+	app.Use(GRPCWebWithConfig(config))
+
+	w := ginji.NewRequest(app, "POST", "/echo.Echo/Say").
+		Header("Content-Type", "application/grpc-web+proto").
+		Header("X-Grpc-Web", "1").
+		Body(bytes.NewReader(grpcFrame(0, "hello"))).
+		Do()
+
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	ginji.AssertHeader(t, w, "Content-Type", "application/grpc-web+proto")
+	if w.Header().Get("Trailer") != "" || w.Header().Get("Grpc-Status") != "" {
+		t.Error("Expected trailers to be sent in the body")
+	}
+
+	trailers := "grpc-message: \r\ngrpc-status: 0\r\nx-request-cost: 3\r\n"
+	want := append(grpcFrame(0, "HELLO"), grpcFrame(grpcWebTrailerFlag, trailers)...)
+	if !bytes.Equal(w.Body.Bytes(), want) {
+		t.Errorf("Expected body %q, got %q", want, w.Body.Bytes())
+	}
+}
+
+func TestGRPCWebText(t *testing.T) {
+	config := DefaultGRPCWebConfig()
+	config.Backend = grpcEchoServer(t)
+	app := ginji.New()
+	app.Use(GRPCWebWithConfig(config))
+
+	// Two padded chunks, as sent by streaming clients
+	frame := grpcFrame(0, "hi there")
+	body := base64.StdEncoding.EncodeToString(frame[:4]) + base64.StdEncoding.EncodeToString(frame[4:])
+	w := ginji.NewRequest(app, "POST", "/echo.Echo/Say").
+		Header("Content-Type", "application/grpc-web-text+proto").
+		Body(strings.NewReader(body)).
+		Do()
+
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	ginji.AssertHeader(t, w, "Content-Type", "application/grpc-web-text+proto")
+
+	decoded, err := io.ReadAll(&grpcWebTextReader{r: w.Body})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(decoded, grpcFrame(0, "HI THERE")) {
+		t.Errorf("Unexpected response %q", decoded)
+	}
+}
+
+func TestGRPCWebPassThrough(t *testing.T) {
+	config := DefaultGRPCWebConfig()
+	config.Backend = grpcEchoServer(t)
+	app := ginji.New()
+	app.Use(GRPCWebWithConfig(config))
+	app.Get("/api/users", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "rest")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/api/users", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	ginji.AssertBody(t, w, "rest")
+}
+
+func TestGRPCWebCORS(t *testing.T) {
+	config := DefaultGRPCWebConfig()
+	config.AllowedOrigins = []string{"https://app.example.com"}
+	config.ExposedHeaders = []string{"X-Request-Cost"}
+	config.Backend = grpcEchoServer(t)
+	app := ginji.New()
+	app.Use(GRPCWebWithConfig(config))
+
+	preflight := func(origin string) *ginji.Request {
+		return ginji.NewRequest(app, "OPTIONS", "/echo.Echo/Say").
+			Header("Origin", origin).
+			Header("Access-Control-Request-Method", "POST").
+			Header("Access-Control-Request-Headers", "content-type,x-grpc-web,x-user-agent")
+	}
+
+	w := preflight("https://app.example.com").Do()
+	ginji.AssertStatus(t, w, ginji.StatusNoContent)
+	ginji.AssertHeader(t, w, "Access-Control-Allow-Origin", "https://app.example.com")
+	ginji.AssertHeader(t, w, "Access-Control-Allow-Methods", "POST, OPTIONS")
+	ginji.AssertHeader(t, w, "Access-Control-Max-Age", "600")
+	if !strings.Contains(w.Header().Get("Access-Control-Allow-Headers"), "X-Grpc-Web") {
+		t.Errorf("Expected gRPC-Web headers to be allowed, got %q", w.Header().Get("Access-Control-Allow-Headers"))
+	}
+
+	w = preflight("https://evil.example").Do()
+	ginji.AssertStatus(t, w, ginji.StatusForbidden)
+
+	w = ginji.NewRequest(app, "POST", "/echo.Echo/Say").
+		Header("Origin", "https://app.example.com").
+		Header("Content-Type", "application/grpc-web+proto").
+		Body(bytes.NewReader(grpcFrame(0, "x"))).
+		Do()
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	ginji.AssertHeader(t, w, "Access-Control-Allow-Origin", "https://app.example.com")
+	ginji.AssertHeader(t, w, "Access-Control-Expose-Headers", "Grpc-Status, Grpc-Message, Grpc-Status-Details-Bin, X-Request-Cost")
+}
+
+func TestGRPCWebRejectsCredentialsForAnyOrigin(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic for credentials with wildcard origin")
+		}
+	}()
+	GRPCWebWithConfig(GRPCWebConfig{
+		Backend:          http.NotFoundHandler(),
+		AllowedOrigins:   []string{"*"},
+		AllowCredentials: true,
+	})
+}
+
+func TestGRPCWebTrailersOnly(t *testing.T) {
+	app := ginji.New()
+	app.Use(GRPCWeb(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Grpc-Status", "12")
+		w.Header().Set("Grpc-Message", "unimplemented")
+	})))
+
+	w := ginji.NewRequest(app, "POST", "/echo.Echo/Missing").
+		Header("Content-Type", "application/grpc-web").
+		Body(bytes.NewReader(grpcFrame(0, ""))).
+		Do()
+
+	ginji.AssertHeader(t, w, "Content-Type", "application/grpc-web")
+	ginji.AssertHeader(t, w, "Grpc-Status", "12")
+	want := grpcFrame(grpcWebTrailerFlag, "grpc-message: unimplemented\r\ngrpc-status: 12\r\n")
+	if !bytes.Equal(w.Body.Bytes(), want) {
+		t.Errorf("Expected body %q, got %q", want, w.Body.Bytes())
+	}
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import "encoding/json"
+
+// halLink is a HAL link object.
+type halLink struct {
+	Href string `json:"href"`
+}
+
+// halResource builds the HAL representation of node: its attributes as
+// properties, its ID as "id", links under "_links" and related resources
+// under "_embedded".
+func halResource(config ResourceConfig, node *resourceNode) map[string]any {
+	doc := make(map[string]any, len(node.attributes)+3)
+	for name, value := range node.attributes {
+		doc[name] = value
+	}
+	if node.id != "" {
+		doc["id"] = node.id
+	}
+
+	links := make(map[string]halLink, len(node.links)+1)
+	for name, href := range node.links {
+		links[name] = halLink{Href: href}
+	}
+	if self := node.selfLink(config); self != "" {
+		links["self"] = halLink{Href: self}
+	}
+	if len(links) > 0 {
+		doc["_links"] = links
+	}
+
+	if len(node.relationships) > 0 {
+		embedded := make(map[string]any, len(node.relationships))
+		for _, relation := range node.relationships {
+			switch {
+			case relation.many:
+				items := make([]map[string]any, len(relation.nodes))
+				for i, related := range relation.nodes {
+					items[i] = halResource(config, related)
+				}
+				embedded[relation.name] = items
+			case len(relation.nodes) == 1:
+				embedded[relation.name] = halResource(config, relation.nodes[0])
+			default:
+				embedded[relation.name] = json.RawMessage("null")
+			}
+		}
+		doc["_embedded"] = embedded
+	}
+	return doc
+}
+
+// halCollection builds the HAL representation of a list of resources,
+// embedded under their type.
+func halCollection(config ResourceConfig, self string, nodes []*resourceNode) map[string]any {
+	items := make([]map[string]any, len(nodes))
+	for i, node := range nodes {
+		items[i] = halResource(config, node)
+	}
+
+	doc := map[string]any{
+		"_links": map[string]halLink{"self": {Href: self}},
+		"count":  len(nodes),
+	}
+	if len(nodes) > 0 {
+		doc["_embedded"] = map[string]any{nodes[0].typ: items}
+	}
+	return doc
+}
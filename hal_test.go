@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"testing"
+
+	"github.com/ginjigo/ginji"
+)
+
+func TestHALResource(t *testing.T) {
+	config := DefaultResourceConfig()
+	config.Format = ResourceHAL
+	config.BaseURL = "https://api.example.com/"
+	app := ginji.New()
+	app.Use(ResourceWithConfig(config))
+	app.Get("/articles", func(c *ginji.Context) error {
+		return WriteResource(c, ginji.StatusOK, testArticles())
+	})
+	app.Get("/articles/1", func(c *ginji.Context) error {
+		return WriteResource(c, ginji.StatusOK, testArticles()[0])
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/articles/1", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	ginji.AssertHeader(t, w, "Content-Type", "application/hal+json")
+	ginji.AssertBody(t, w, `{"_embedded":{"author":{"_links":{"self":{"href":"https://api.example.com/people/9"}},"id":"9","name":"Dan"},`+
+		`"comments":[{"_links":{"self":{"href":"https://api.example.com/comments/5"}},"body":"First!","id":"5"}]},`+
+		`"_links":{"html":{"href":"https://example.com/a/1"},"self":{"href":"https://api.example.com/articles/1"}},"id":"1","title":"JSON:API"}`)
+}
+
+func TestHALCollection(t *testing.T) {
+	app := ginji.New()
+	app.Use(ResourceWithConfig(ResourceConfig{Format: ResourceHAL}))
+	app.Get("/articles", func(c *ginji.Context) error {
+		return WriteResource(c, ginji.StatusOK, testArticles())
+	})
+	app.Get("/articles/1", func(c *ginji.Context) error {
+		return WriteResource(c, ginji.StatusOK, testArticles()[0])
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/articles?page=2", nil)
+	ginji.AssertBody(t, w, `"_links":{"self":{"href":"/articles?page=2"}}`)
+	ginji.AssertBody(t, w, `"count":2`)
+	ginji.AssertBody(t, w, `"_embedded":{"articles":[{`)
+	ginji.AssertBody(t, w, `"comments":[]`)
+}
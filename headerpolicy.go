@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/ginjigo/ginji"
+)
+
+// HeaderPolicyConfig defines the configuration for response header policy middleware.
+type HeaderPolicyConfig struct {
+	// Remove lists headers stripped from every response.
+	// Default: Server, X-Powered-By, X-AspNet-Version, X-AspNetMvc-Version
+	Remove []string
+
+	// Set lists headers set on every response, overriding any value the
+	// handler wrote (e.g. {"X-Environment": "staging"}).
+	Set map[string]string
+
+	// SkipFunc allows skipping the policy for certain requests.
+	SkipFunc Skipper
+}
+
+// DefaultHeaderPolicyConfig returns a policy that strips common server fingerprints.
+func DefaultHeaderPolicyConfig() HeaderPolicyConfig {
+	return HeaderPolicyConfig{
+		Remove: []string{"Server", "X-Powered-By", "X-AspNet-Version", "X-AspNetMvc-Version"},
+	}
+}
+
+// HeaderPolicy returns response header policy middleware with default configuration.
+func HeaderPolicy() ginji.Middleware {
+	return HeaderPolicyWithConfig(DefaultHeaderPolicyConfig())
+}
+
+// HeaderPolicyWithConfig returns middleware that strips and overrides
+// response headers after the handler runs.
+func HeaderPolicyWithConfig(config HeaderPolicyConfig) ginji.Middleware {
+	if config.Remove == nil {
+		config.Remove = DefaultHeaderPolicyConfig().Remove
+	}
+
+	apply := func(h http.Header) {
+		for _, name := range config.Remove {
+			h.Del(name)
+		}
+		for name, value := range config.Set {
+			h.Set(name, value)
+		}
+	}
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		rw := WrapResponseWriter(c)
+		rw.Before(apply)
+
+		err := c.Next()
+
+		rw.runBeforeHooks()
+		return err
+	}
+}
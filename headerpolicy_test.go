@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"testing"
+
+	"github.com/ginjigo/ginji"
+)
+
+func TestHeaderPolicyDefault(t *testing.T) {
+	app := ginji.New()
+	app.Use(HeaderPolicy())
+
+	app.Get("/", func(c *ginji.Context) error {
+		c.SetHeader("Server", "nginx/1.25")
+		c.SetHeader("X-Powered-By", "PHP/8.2")
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/", nil)
+
+	if w.Header().Get("Server") != "" {
+		t.Errorf("Expected Server header removed, got %q", w.Header().Get("Server"))
+	}
+	if w.Header().Get("X-Powered-By") != "" {
+		t.Errorf("Expected X-Powered-By header removed, got %q", w.Header().Get("X-Powered-By"))
+	}
+}
+
+func TestHeaderPolicySet(t *testing.T) {
+	app := ginji.New()
+	app.Use(HeaderPolicyWithConfig(HeaderPolicyConfig{
+		Remove: []string{},
+		Set: map[string]string{
+			"X-Environment": "staging",
+			"Server":        "ginji",
+		},
+	}))
+
+	app.Get("/", func(c *ginji.Context) error {
+		c.SetHeader("Server", "custom")
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/", nil)
+
+	ginji.AssertHeader(t, w, "X-Environment", "staging")
+	ginji.AssertHeader(t, w, "Server", "ginji")
+}
+
+func TestHeaderPolicySkipFunc(t *testing.T) {
+	app := ginji.New()
+	app.Use(HeaderPolicyWithConfig(HeaderPolicyConfig{
+		SkipFunc: func(c *ginji.Context) bool {
+			return c.Req.URL.Path == "/raw"
+		},
+	}))
+
+	app.Get("/raw", func(c *ginji.Context) error {
+		c.SetHeader("Server", "upstream")
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/raw", nil)
+	ginji.AssertHeader(t, w, "Server", "upstream")
+}
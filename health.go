@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"strings"
 	"sync"
 	"time"
 
@@ -11,6 +12,39 @@ import (
 // It should return an error if the component is unhealthy.
 type HealthChecker func() error
 
+// HealthReporter is implemented by the package's own long-lived
+// components, such as Limiter, SSEBroker, CircuitBreaker, StatsDSink and
+// OTLPExporter, so their state can be included in readiness checks.
+type HealthReporter interface {
+	// HealthCheck returns an error if the component cannot do its job.
+	HealthCheck() error
+}
+
+// HealthSeverity is how a failing health check affects readiness.
+type HealthSeverity int
+
+const (
+	// HealthCritical checks make the app not ready when they fail.
+	HealthCritical HealthSeverity = iota
+	// HealthInformational checks are reported, but their failures only
+	// degrade the status and do not flip readiness.
+	HealthInformational
+)
+
+// HealthCheck is a readiness check with a group and a severity.
+type HealthCheck struct {
+	// Checker checks the component.
+	Checker HealthChecker
+
+	// Group groups related checks, e.g. "db", "cache" or "external", so
+	// that they can be checked alone with ?group=db.
+	Group string
+
+	// Severity is whether a failure makes the app not ready.
+	// Default: HealthCritical
+	Severity HealthSeverity
+}
+
 // HealthCheckConfig defines the configuration for health check middleware.
 type HealthCheckConfig struct {
 	// LivenessPath is the path for liveness probes.
@@ -25,32 +59,81 @@ type HealthCheckConfig struct {
 	// Liveness checks are typically simpler (just checking if the app is running).
 	Checkers map[string]HealthChecker
 
+	// Components are middleware components whose health is checked for
+	// readiness alongside Checkers, e.g. {"ratelimit": limiter}.
+	Components map[string]HealthReporter
+
+	// Checks are grouped health checks with a severity, run for readiness
+	// alongside Checkers, which are critical and ungrouped.
+	Checks map[string]HealthCheck
+
 	// Timeout is the maximum time to wait for all health checks.
 	// Default: 5 seconds
 	Timeout time.Duration
 
+	// FailureThreshold is the number of consecutive failures after which a
+	// check is reported DOWN, so that transient blips do not flap probes.
+	// Default: 1
+	FailureThreshold int
+
+	// SuccessThreshold is the number of consecutive successes after which a
+	// failing check is reported UP again.
+	// Default: 1
+	SuccessThreshold int
+
+	// HistorySize is the number of recent results kept per check and shown
+	// in verbose responses (?verbose=true).
+	// Default: 0 (no history)
+	HistorySize int
+
 	// DisableLiveness disables the liveness endpoint.
 	DisableLiveness bool
 
 	// DisableReadiness disables the readiness endpoint.
 	DisableReadiness bool
+
+	// SkipFunc allows skipping the health endpoints for certain requests.
+	SkipFunc Skipper
 }
 
-// HealthStatus represents the health status response.
+// HealthStatus represents the health status response. Status is "UP",
+// "DEGRADED" when only informational checks fail, or "DOWN".
 type HealthStatus struct {
-	Status  string            `json:"status"`
-	Checks  map[string]string `json:"checks,omitempty"`
-	Message string            `json:"message,omitempty"`
-	Time    string            `json:"time"`
+	Status  string                       `json:"status"`
+	Checks  map[string]string            `json:"checks,omitempty"`
+	Groups  map[string]string            `json:"groups,omitempty"`
+	Details map[string]HealthCheckDetail `json:"details,omitempty"`
+	Message string                       `json:"message,omitempty"`
+	Time    string                       `json:"time"`
+}
+
+// HealthCheckDetail is the state of a check in verbose readiness
+// responses.
+type HealthCheckDetail struct {
+	// Status is the reported status, which lags the last result until
+	// FailureThreshold or SuccessThreshold is reached.
+	Status string `json:"status"`
+	// Error is the error of the last failure, if the last result failed.
+	Error string `json:"error,omitempty"`
+	// Streak is the number of consecutive results equal to the last one.
+	Streak int `json:"streak"`
+	// Flaps is the number of times the reported status changed.
+	Flaps int `json:"flaps"`
+	// Since is when the reported status last changed.
+	Since string `json:"since"`
+	// History holds the recent results, oldest first, as "UP" or "DOWN".
+	History []string `json:"history,omitempty"`
 }
 
 // DefaultHealthCheckConfig returns default health check configuration.
 func DefaultHealthCheckConfig() HealthCheckConfig {
 	return HealthCheckConfig{
-		LivenessPath:  "/health/live",
-		ReadinessPath: "/health/ready",
-		Checkers:      make(map[string]HealthChecker),
-		Timeout:       5 * time.Second,
+		LivenessPath:     "/health/live",
+		ReadinessPath:    "/health/ready",
+		Checkers:         make(map[string]HealthChecker),
+		Timeout:          5 * time.Second,
+		FailureThreshold: 1,
+		SuccessThreshold: 1,
 	}
 }
 
@@ -71,11 +154,30 @@ func HealthWithConfig(config HealthCheckConfig) ginji.Middleware {
 	if config.Timeout == 0 {
 		config.Timeout = 5 * time.Second
 	}
-	if config.Checkers == nil {
-		config.Checkers = make(map[string]HealthChecker)
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = 1
+	}
+	if config.SuccessThreshold <= 0 {
+		config.SuccessThreshold = 1
 	}
+	checks := make(map[string]HealthCheck, len(config.Checkers)+len(config.Components)+len(config.Checks))
+	for name, checker := range config.Checkers {
+		checks[name] = HealthCheck{Checker: checker}
+	}
+	for name, component := range config.Components {
+		checks[name] = HealthCheck{Checker: component.HealthCheck}
+	}
+	for name, check := range config.Checks {
+		checks[name] = check
+	}
+	history := &healthHistory{config: config, checks: make(map[string]*healthCheckHistory, len(checks))}
 
 	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
 		path := c.Req.URL.Path
 
 		// Liveness probe -		// Health check endpoint - checks basic app health
@@ -84,21 +186,41 @@ func HealthWithConfig(config HealthCheckConfig) ginji.Middleware {
 				Status: "UP",
 				Time:   time.Now().UTC().Format(time.RFC3339),
 			}
+			c.Abort()
 			return c.JSON(ginji.StatusOK, status)
 		}
 
 		// Readiness probe - checks if the app is ready to serve traffic
 		if !config.DisableReadiness && path == config.ReadinessPath {
-			return handleReadiness(c, config)
+			c.Abort()
+			return handleReadiness(c, config, checks, history)
 		}
 
 		return c.Next()
 	}
 }
 
-// handleReadiness handles the readiness probe request.
-func handleReadiness(c *ginji.Context, config HealthCheckConfig) error {
-	if len(config.Checkers) == 0 {
+// handleReadiness handles the readiness probe request, running only the
+// checks of the group given by the "group" query parameter, if any.
+func handleReadiness(c *ginji.Context, config HealthCheckConfig, checks map[string]HealthCheck, history *healthHistory) error {
+	if group := c.Query("group"); group != "" {
+		selected := make(map[string]HealthCheck)
+		for name, check := range checks {
+			if check.Group == group {
+				selected[name] = check
+			}
+		}
+		if len(selected) == 0 {
+			return c.JSON(ginji.StatusNotFound, HealthStatus{
+				Status:  "UNKNOWN",
+				Message: "unknown health check group: " + group,
+				Time:    time.Now().UTC().Format(time.RFC3339),
+			})
+		}
+		checks = selected
+	}
+
+	if len(checks) == 0 {
 		// No checkers configured, assume ready
 		status := HealthStatus{
 			Status: "UP",
@@ -109,13 +231,13 @@ func handleReadiness(c *ginji.Context, config HealthCheckConfig) error {
 
 	// Run all health checkers with timeout
 	results := make(map[string]string)
+	failed := make(map[string]bool)
 	var mu sync.Mutex
 	var wg sync.WaitGroup
 	done := make(chan struct{})
-	allHealthy := true
 
 	// Run checkers concurrently
-	for name, checker := range config.Checkers {
+	for name, check := range checks {
 		wg.Add(1)
 		go func(name string, checker HealthChecker) {
 			defer wg.Done()
@@ -123,14 +245,14 @@ func handleReadiness(c *ginji.Context, config HealthCheckConfig) error {
 			if err := checker(); err != nil {
 				mu.Lock()
 				results[name] = "DOWN: " + err.Error()
-				allHealthy = false
+				failed[name] = true
 				mu.Unlock()
 			} else {
 				mu.Lock()
 				results[name] = "UP"
 				mu.Unlock()
 			}
-		}(name, checker)
+		}(name, check.Checker)
 	}
 
 	// Wait for all checkers or timeout
@@ -144,11 +266,11 @@ func handleReadiness(c *ginji.Context, config HealthCheckConfig) error {
 		// All checkers completed
 	case <-time.After(config.Timeout):
 		// Timeout occurred
-		allHealthy = false
 		mu.Lock()
-		for name := range config.Checkers {
+		for name := range checks {
 			if _, exists := results[name]; !exists {
 				results[name] = "DOWN: timeout"
+				failed[name] = true
 			}
 		}
 		mu.Unlock()
@@ -160,21 +282,179 @@ func handleReadiness(c *ginji.Context, config HealthCheckConfig) error {
 	for k, v := range results {
 		resultsCopy[k] = v
 	}
+	failedCopy := make(map[string]bool, len(failed))
+	for k, v := range failed {
+		failedCopy[k] = v
+	}
 	mu.Unlock()
 
+	// Damp the results through the history of each check
+	verbose := c.Query("verbose") == "true"
+	var details map[string]HealthCheckDetail
+	if verbose {
+		details = make(map[string]HealthCheckDetail, len(checks))
+	}
+	now := time.Now()
+	for name := range checks {
+		detail := history.record(name, resultsCopy[name], failedCopy[name], now)
+		failedCopy[name] = detail.Status == "DOWN"
+		if failedCopy[name] {
+			resultsCopy[name] = "DOWN: " + detail.lastError
+		} else {
+			resultsCopy[name] = "UP"
+		}
+		if verbose {
+			details[name] = detail.HealthCheckDetail
+		}
+	}
+
+	// A group, and the app, is DOWN if a critical check fails and DEGRADED
+	// if only informational checks do
+	overall := "UP"
+	groups := make(map[string]string)
+	for name, check := range checks {
+		state := "UP"
+		if failedCopy[name] {
+			state = "DEGRADED"
+			if check.Severity == HealthCritical {
+				state = "DOWN"
+			}
+		}
+		overall = worseHealthState(overall, state)
+		if check.Group != "" {
+			groups[check.Group] = worseHealthState(groups[check.Group], state)
+		}
+	}
+
 	status := HealthStatus{
-		Checks: resultsCopy,
-		Time:   time.Now().UTC().Format(time.RFC3339),
+		Status:  overall,
+		Checks:  resultsCopy,
+		Details: details,
+		Time:    now.UTC().Format(time.RFC3339),
+	}
+	if len(groups) > 0 {
+		status.Groups = groups
 	}
 
-	if allHealthy {
-		status.Status = "UP"
+	if overall != "DOWN" {
 		return c.JSON(ginji.StatusOK, status)
 	}
-	status.Status = "DOWN"
 	return c.JSON(ginji.StatusServiceUnavailable, status)
 }
 
+// worseHealthState returns the worse of two states, treating "" as UP.
+func worseHealthState(a, b string) string {
+	rank := func(state string) int {
+		switch state {
+		case "DOWN":
+			return 2
+		case "DEGRADED":
+			return 1
+		default:
+			return 0
+		}
+	}
+	if rank(b) > rank(a) || a == "" {
+		return b
+	}
+	return a
+}
+
+// healthHistory tracks the recent results of the readiness checks.
+type healthHistory struct {
+	config HealthCheckConfig
+
+	mu     sync.Mutex
+	checks map[string]*healthCheckHistory
+}
+
+// healthCheckHistory is the history of a check.
+type healthCheckHistory struct {
+	down      bool
+	lastDown  bool
+	lastError string
+	streak    int
+	flaps     int
+	since     time.Time
+	results   []bool // ring buffer of failures
+	next      int
+}
+
+// healthCheckRecord is a check's state after recording a result.
+type healthCheckRecord struct {
+	HealthCheckDetail
+	lastError string
+}
+
+// record adds a result of the check and returns its damped state. The first
+// result is reported as is; later ones change the reported status only
+// after FailureThreshold failures or SuccessThreshold successes in a row.
+func (h *healthHistory) record(name, result string, failed bool, now time.Time) healthCheckRecord {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	check, ok := h.checks[name]
+	if !ok {
+		check = &healthCheckHistory{down: failed, lastDown: failed, since: now}
+		h.checks[name] = check
+	}
+
+	if ok && failed == check.lastDown {
+		check.streak++
+	} else {
+		check.streak = 1
+	}
+	check.lastDown = failed
+	if failed {
+		check.lastError = strings.TrimPrefix(result, "DOWN: ")
+	}
+
+	threshold := h.config.SuccessThreshold
+	if failed {
+		threshold = h.config.FailureThreshold
+	}
+	if failed != check.down && check.streak >= threshold {
+		check.down = failed
+		check.flaps++
+		check.since = now
+	}
+
+	if size := h.config.HistorySize; size > 0 {
+		if len(check.results) < size {
+			check.results = append(check.results, failed)
+		} else {
+			check.results[check.next] = failed
+		}
+		check.next = (check.next + 1) % size
+	}
+
+	record := healthCheckRecord{
+		HealthCheckDetail: HealthCheckDetail{
+			Status: "UP",
+			Streak: check.streak,
+			Flaps:  check.flaps,
+			Since:  check.since.UTC().Format(time.RFC3339),
+		},
+		lastError: check.lastError,
+	}
+	if check.down {
+		record.Status = "DOWN"
+	}
+	if failed {
+		record.Error = check.lastError
+	}
+	if n := len(check.results); n > 0 {
+		record.History = make([]string, n)
+		for i := range n {
+			record.History[i] = "UP"
+			if check.results[(check.next+i)%n] {
+				record.History[i] = "DOWN"
+			}
+		}
+	}
+	return record
+}
+
 // AddHealthChecker adds a health checker to the configuration.
 func (config *HealthCheckConfig) AddHealthChecker(name string, checker HealthChecker) {
 	if config.Checkers == nil {
@@ -183,6 +463,22 @@ func (config *HealthCheckConfig) AddHealthChecker(name string, checker HealthChe
 	config.Checkers[name] = checker
 }
 
+// AddCheck adds a grouped health check to the configuration.
+func (config *HealthCheckConfig) AddCheck(name string, check HealthCheck) {
+	if config.Checks == nil {
+		config.Checks = make(map[string]HealthCheck)
+	}
+	config.Checks[name] = check
+}
+
+// AddComponent adds a middleware component to the readiness checks.
+func (config *HealthCheckConfig) AddComponent(name string, component HealthReporter) {
+	if config.Components == nil {
+		config.Components = make(map[string]HealthReporter)
+	}
+	config.Components[name] = component
+}
+
 // SimpleHealthCheck returns a basic health check middleware for Kubernetes-style probes.
 func SimpleHealthCheck(livePath, readyPath string) ginji.Middleware {
 	config := HealthCheckConfig{
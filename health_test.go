@@ -1,7 +1,10 @@
 package middleware
 
 import (
+	"encoding/json"
 	"errors"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
@@ -226,3 +229,147 @@ func TestDefaultHealthCheckConfig(t *testing.T) {
 		t.Errorf("Expected default timeout 5s, got %v", config.Timeout)
 	}
 }
+
+func TestHealthComponents(t *testing.T) {
+	limiter := NewLimiter(RateLimiterConfig{Max: 10, Window: time.Minute})
+	broker := NewSSEBroker(DefaultSSEBrokerConfig())
+	defer broker.Close()
+
+	config := DefaultHealthCheckConfig()
+	config.AddComponent("ratelimit", limiter)
+	config.AddComponent("sse", broker)
+
+	app := ginji.New()
+	app.Use(HealthWithConfig(config))
+
+	w := ginji.PerformRequest(app, "GET", "/health/ready", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	ginji.AssertBody(t, w, `"ratelimit":"UP"`)
+	ginji.AssertBody(t, w, `"sse":"UP"`)
+
+	_ = limiter.Close()
+
+	w = ginji.PerformRequest(app, "GET", "/health/ready", nil)
+	ginji.AssertStatus(t, w, ginji.StatusServiceUnavailable)
+	ginji.AssertBody(t, w, "DOWN: ratelimit: limiter is closed")
+}
+
+func TestHealthGroupsAndSeverity(t *testing.T) {
+	cacheErr := errors.New("redis: connection refused")
+	var cacheDown, dbDown bool
+
+	config := DefaultHealthCheckConfig()
+	config.AddCheck("postgres", HealthCheck{
+		Group: "db",
+		Checker: func() error {
+			if dbDown {
+				return errors.New("no connection")
+			}
+			return nil
+		},
+	})
+	config.AddCheck("redis", HealthCheck{
+		Group:    "cache",
+		Severity: HealthInformational,
+		Checker: func() error {
+			if cacheDown {
+				return cacheErr
+			}
+			return nil
+		},
+	})
+	config.AddHealthChecker("disk", func() error { return nil })
+
+	app := ginji.New()
+	app.Use(HealthWithConfig(config))
+
+	w := ginji.PerformRequest(app, "GET", "/health/ready", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	ginji.AssertBody(t, w, `"groups":{"cache":"UP","db":"UP"}`)
+
+	// Informational failures degrade the status but keep the app ready
+	cacheDown = true
+	w = ginji.PerformRequest(app, "GET", "/health/ready", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	ginji.AssertBody(t, w, `"status":"DEGRADED"`)
+	ginji.AssertBody(t, w, `"cache":"DEGRADED"`)
+	ginji.AssertBody(t, w, "DOWN: redis: connection refused")
+
+	dbDown = true
+	w = ginji.PerformRequest(app, "GET", "/health/ready", nil)
+	ginji.AssertStatus(t, w, ginji.StatusServiceUnavailable)
+	ginji.AssertBody(t, w, `"status":"DOWN"`)
+
+	// Filtering runs only the checks of the group
+	w = ginji.PerformRequest(app, "GET", "/health/ready?group=cache", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	ginji.AssertBody(t, w, `"checks":{"redis":"DOWN: redis: connection refused"}`)
+
+	w = ginji.PerformRequest(app, "GET", "/health/ready?group=db", nil)
+	ginji.AssertStatus(t, w, ginji.StatusServiceUnavailable)
+	ginji.AssertBody(t, w, `"groups":{"db":"DOWN"}`)
+
+	w = ginji.PerformRequest(app, "GET", "/health/ready?group=queue", nil)
+	ginji.AssertStatus(t, w, ginji.StatusNotFound)
+}
+
+func TestHealthFlapDamping(t *testing.T) {
+	var down bool
+	config := DefaultHealthCheckConfig()
+	config.FailureThreshold = 3
+	config.SuccessThreshold = 2
+	config.HistorySize = 4
+	config.AddHealthChecker("database", func() error {
+		if down {
+			return errors.New("connection reset")
+		}
+		return nil
+	})
+
+	app := ginji.New()
+	app.Use(HealthWithConfig(config))
+	ready := func() int {
+		return ginji.PerformRequest(app, "GET", "/health/ready", nil).Code
+	}
+
+	if code := ready(); code != ginji.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+
+	// Two failures in a row are a blip
+	down = true
+	for i := range 2 {
+		if code := ready(); code != ginji.StatusOK {
+			t.Fatalf("Expected failure %d to be damped, got %d", i+1, code)
+		}
+	}
+	if code := ready(); code != ginji.StatusServiceUnavailable {
+		t.Fatalf("Expected 503 after 3 failures, got %d", code)
+	}
+
+	// One success is not enough to recover
+	down = false
+	if code := ready(); code != ginji.StatusServiceUnavailable {
+		t.Fatalf("Expected 503 after 1 success, got %d", code)
+	}
+
+	w := ginji.PerformRequest(app, "GET", "/health/ready?verbose=true", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	var status HealthStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	detail := status.Details["database"]
+	if detail.Status != "UP" || detail.Streak != 2 || detail.Flaps != 2 {
+		t.Errorf("Unexpected detail %+v", detail)
+	}
+	if want := []string{"DOWN", "DOWN", "UP", "UP"}; !slices.Equal(detail.History, want) {
+		t.Errorf("Expected history %v, got %v", want, detail.History)
+	}
+
+	// Details are only included on request
+	w = ginji.PerformRequest(app, "GET", "/health/ready", nil)
+	if strings.Contains(w.Body.String(), "details") {
+		t.Error("Expected no details without verbose")
+	}
+}
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"net"
+	"net/http"
 	"strings"
 )
 
@@ -27,3 +28,30 @@ func isIPInCIDR(ipStr, cidr string) bool {
 
 	return ipNet.Contains(parsedIP)
 }
+
+// clientIP returns the client IP address without port. X-Forwarded-For and
+// X-Real-IP are only honored when the direct peer is a trusted proxy.
+func clientIP(r *http.Request, trustedProxies []string) string {
+	remoteAddr := r.RemoteAddr
+	remoteIP := remoteAddr
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		remoteIP = host
+	}
+
+	for _, proxy := range trustedProxies {
+		if remoteIP == proxy || remoteAddr == proxy || isIPInCIDR(remoteAddr, proxy) {
+			if ip := r.Header.Get("X-Forwarded-For"); ip != "" {
+				if idx := strings.Index(ip, ","); idx != -1 {
+					return strings.TrimSpace(ip[:idx])
+				}
+				return strings.TrimSpace(ip)
+			}
+			if ip := r.Header.Get("X-Real-IP"); ip != "" {
+				return strings.TrimSpace(ip)
+			}
+			break
+		}
+	}
+
+	return remoteIP
+}
@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/ginjigo/ginji"
+)
+
+// IPFilterConfig defines the configuration for IP filter middleware.
+type IPFilterConfig struct {
+	// Allow lists the IP addresses or CIDR ranges that may access the
+	// application. If empty, every address not denied is allowed.
+	Allow []string
+
+	// Deny lists the IP addresses or CIDR ranges that are blocked.
+	// Deny takes precedence over Allow.
+	Deny []string
+
+	// TrustedProxies is a list of trusted proxy IP addresses used when
+	// resolving the client IP.
+	TrustedProxies []string
+
+	// StatusCode is the HTTP status code for blocked requests.
+	// Default: 403 Forbidden
+	StatusCode int
+
+	// Message is returned when a request is blocked.
+	// Default: "Access denied"
+	Message string
+
+	// SkipFunc allows skipping the filter for certain requests.
+	SkipFunc Skipper
+}
+
+// DefaultIPFilterConfig returns default IP filter configuration.
+func DefaultIPFilterConfig() IPFilterConfig {
+	return IPFilterConfig{
+		StatusCode: http.StatusForbidden,
+		Message:    "Access denied",
+	}
+}
+
+// IPFilter returns middleware that only admits the given IP addresses or
+// CIDR ranges.
+func IPFilter(allow ...string) ginji.Middleware {
+	config := DefaultIPFilterConfig()
+	config.Allow = allow
+	return IPFilterWithConfig(config)
+}
+
+// IPFilterWithConfig returns IP filter middleware with custom configuration.
+func IPFilterWithConfig(config IPFilterConfig) ginji.Middleware {
+	config = normalizeIPFilterConfig(config)
+	allow, err := parseIPNets(config.Allow)
+	if err != nil {
+		panic("IPFilter: " + err.Error())
+	}
+	deny, err := parseIPNets(config.Deny)
+	if err != nil {
+		panic("IPFilter: " + err.Error())
+	}
+
+	return func(c *ginji.Context) error {
+		return filterIP(c, config, allow, deny)
+	}
+}
+
+// IPFilterDynamic returns IP filter middleware that reads its configuration
+// from configFunc on every request, so the lists can be changed at runtime.
+// configFunc must be safe for concurrent use; a Reloader's Load method is.
+// Invalid entries are ignored.
+func IPFilterDynamic(configFunc func() IPFilterConfig) ginji.Middleware {
+	return func(c *ginji.Context) error {
+		config := normalizeIPFilterConfig(configFunc())
+		allow, _ := parseIPNets(config.Allow)
+		deny, _ := parseIPNets(config.Deny)
+		return filterIP(c, config, allow, deny)
+	}
+}
+
+// normalizeIPFilterConfig fills in defaults for unset fields.
+func normalizeIPFilterConfig(config IPFilterConfig) IPFilterConfig {
+	defaults := DefaultIPFilterConfig()
+	if config.StatusCode == 0 {
+		config.StatusCode = defaults.StatusCode
+	}
+	if config.Message == "" {
+		config.Message = defaults.Message
+	}
+	return config
+}
+
+// filterIP blocks the request unless the client IP passes the lists.
+func filterIP(c *ginji.Context, config IPFilterConfig, allow, deny []*net.IPNet) error {
+	// Skip if skip function returns true
+	if config.SkipFunc != nil && config.SkipFunc(c) {
+		return c.Next()
+	}
+
+	ip := net.ParseIP(clientIP(c.Req, config.TrustedProxies))
+	blocked := ip == nil || ipInNets(ip, deny) || (len(config.Allow) > 0 && !ipInNets(ip, allow))
+	if blocked {
+		c.AbortWithStatusJSON(config.StatusCode, ginji.H{
+			"error": config.Message,
+		})
+		return nil
+	}
+
+	return c.Next()
+}
+
+// parseIPNets parses IP addresses and CIDR ranges. Valid entries are
+// returned even when an error is reported for an invalid one.
+func parseIPNets(entries []string) ([]*net.IPNet, error) {
+	var (
+		nets     []*net.IPNet
+		firstErr error
+	)
+	for _, entry := range entries {
+		if _, ipNet, err := net.ParseCIDR(entry); err == nil {
+			nets = append(nets, ipNet)
+			continue
+		}
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			if firstErr == nil {
+				firstErr = &net.ParseError{Type: "IP address", Text: entry}
+			}
+			continue
+		}
+		bits := 8 * net.IPv6len
+		if v4 := ip.To4(); v4 != nil {
+			ip, bits = v4, 8*net.IPv4len
+		}
+		nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+	return nets, firstErr
+}
+
+// ipInNets reports whether ip falls in any of nets.
+func ipInNets(ip net.IP, nets []*net.IPNet) bool {
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"testing"
+
+	"github.com/ginjigo/ginji"
+)
+
+// httptest requests come from 192.0.2.1.
+
+func TestIPFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		config IPFilterConfig
+		want   int
+	}{
+		{"allowed by CIDR", IPFilterConfig{Allow: []string{"192.0.2.0/24"}}, ginji.StatusOK},
+		{"not in allow list", IPFilterConfig{Allow: []string{"10.0.0.1"}}, ginji.StatusForbidden},
+		{"denied by address", IPFilterConfig{Deny: []string{"192.0.2.1"}}, ginji.StatusForbidden},
+		{"deny wins over allow", IPFilterConfig{Allow: []string{"192.0.2.0/24"}, Deny: []string{"192.0.2.1/32"}}, ginji.StatusForbidden},
+		{"no lists", IPFilterConfig{}, ginji.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := ginji.New()
+			app.Use(IPFilterWithConfig(tt.config))
+			app.Get("/", func(c *ginji.Context) error {
+				return c.Text(ginji.StatusOK, "ok")
+			})
+			w := ginji.PerformRequest(app, "GET", "/", nil)
+			ginji.AssertStatus(t, w, tt.want)
+		})
+	}
+}
+
+func TestIPFilterInvalidEntryPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic for invalid address")
+		}
+	}()
+	IPFilter("not-an-ip")
+}
+
+func TestIPFilterDynamic(t *testing.T) {
+	lists := NewReloader(IPFilterConfig{})
+	app := ginji.New()
+	app.Use(IPFilterDynamic(lists.Load))
+	app.Get("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+
+	lists.Update(func(c IPFilterConfig) IPFilterConfig {
+		c.Deny = append(c.Deny, "192.0.2.0/24")
+		return c
+	})
+	w = ginji.PerformRequest(app, "GET", "/", nil)
+	ginji.AssertStatus(t, w, ginji.StatusForbidden)
+	ginji.AssertBody(t, w, "Access denied")
+}
@@ -0,0 +1,169 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// JournaldConfig defines the configuration for a journald log handler.
+type JournaldConfig struct {
+	// Socket is the path of the journald native protocol socket.
+	// Default: "/run/systemd/journal/socket"
+	Socket string
+
+	// Identifier is sent as SYSLOG_IDENTIFIER.
+	// Default: the program name
+	Identifier string
+
+	// Level is the minimum level sent.
+	// Default: slog.LevelInfo
+	Level slog.Leveler
+}
+
+// DefaultJournaldConfig returns default journald configuration.
+func DefaultJournaldConfig() JournaldConfig {
+	return JournaldConfig{
+		Socket:     "/run/systemd/journal/socket",
+		Identifier: filepath.Base(os.Args[0]),
+		Level:      slog.LevelInfo,
+	}
+}
+
+// JournaldHandler is a slog.Handler sending records to systemd-journald
+// over its native protocol. Attributes become journal fields, upper-cased
+// with invalid characters replaced by "_", so that they can be queried
+// with journalctl, e.g. journalctl STATUS=500. Levels map to PRIORITY as
+// they do for SyslogHandler.
+//
+//	h, err := middleware.NewJournaldHandler(middleware.DefaultJournaldConfig())
+//	app.Use(middleware.LoggerWithConfig(middleware.LoggerConfig{Handler: h}))
+//
+// Entries must fit in one datagram, about 200KB by default.
+type JournaldHandler struct {
+	config JournaldConfig
+	conn   *net.UnixConn
+	addr   *net.UnixAddr
+	attrs  []flatAttr
+	prefix string
+}
+
+// NewJournaldHandler opens a socket to journald. It returns an error if
+// the journald socket does not exist.
+func NewJournaldHandler(config JournaldConfig) (*JournaldHandler, error) {
+	defaults := DefaultJournaldConfig()
+	if config.Socket == "" {
+		config.Socket = defaults.Socket
+	}
+	if config.Identifier == "" {
+		config.Identifier = defaults.Identifier
+	}
+	if config.Level == nil {
+		config.Level = defaults.Level
+	}
+
+	if _, err := os.Stat(config.Socket); err != nil {
+		return nil, fmt.Errorf("journald: %w", err)
+	}
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("journald: %w", err)
+	}
+	return &JournaldHandler{
+		config: config,
+		conn:   conn,
+		addr:   &net.UnixAddr{Name: config.Socket, Net: "unixgram"},
+	}, nil
+}
+
+// Enabled implements slog.Handler.
+func (h *JournaldHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.config.Level.Level()
+}
+
+// Handle implements slog.Handler.
+func (h *JournaldHandler) Handle(_ context.Context, r slog.Record) error {
+	var b bytes.Buffer
+	journalField(&b, "MESSAGE", r.Message)
+	journalField(&b, "PRIORITY", strconv.Itoa(syslogSeverity(r.Level)))
+	journalField(&b, "SYSLOG_IDENTIFIER", h.config.Identifier)
+	for _, a := range recordAttrs(h.attrs, h.prefix, r) {
+		if name := journalFieldName(a.key); name != "" {
+			journalField(&b, name, a.value)
+		}
+	}
+
+	if _, _, err := h.conn.WriteMsgUnix(b.Bytes(), nil, h.addr); err != nil {
+		return fmt.Errorf("journald: %w", err)
+	}
+	return nil
+}
+
+// WithAttrs implements slog.Handler.
+func (h *JournaldHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.attrs = appendFlatAttrs(slices.Clip(h.attrs), h.prefix, attrs)
+	return &clone
+}
+
+// WithGroup implements slog.Handler.
+func (h *JournaldHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.prefix = h.prefix + name + "."
+	return &clone
+}
+
+// Close closes the socket.
+func (h *JournaldHandler) Close() error {
+	return h.conn.Close()
+}
+
+// journalField writes a field in the journald native format, using the
+// length-prefixed form for values containing newlines.
+func journalField(b *bytes.Buffer, name, value string) {
+	b.WriteString(name)
+	if !strings.Contains(value, "\n") {
+		b.WriteByte('=')
+		b.WriteString(value)
+		b.WriteByte('\n')
+		return
+	}
+	b.WriteByte('\n')
+	_ = binary.Write(b, binary.LittleEndian, uint64(len(value)))
+	b.WriteString(value)
+	b.WriteByte('\n')
+}
+
+// journalFieldName returns key as a journal field name: upper-case
+// letters, digits and underscores, not starting with an underscore or a
+// digit, which are reserved or invalid. It returns "" for keys with no
+// usable characters and for the fields set by the handler.
+func journalFieldName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+	name = strings.TrimLeft(name, "_0123456789")
+	switch name {
+	case "MESSAGE", "PRIORITY", "SYSLOG_IDENTIFIER":
+		return ""
+	}
+	return name[:min(len(name), 64)]
+}
@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/binary"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// parseJournalFields decodes a journald native protocol datagram.
+func parseJournalFields(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	fields := make(map[string]string)
+	for len(data) > 0 {
+		line, rest, _ := bytes.Cut(data, []byte("\n"))
+		if name, value, ok := bytes.Cut(line, []byte("=")); ok {
+			fields[string(name)] = string(value)
+			data = rest
+			continue
+		}
+		if len(rest) < 8 {
+			t.Fatalf("Truncated field %s", line)
+		}
+		n := binary.LittleEndian.Uint64(rest[:8])
+		fields[string(line)] = string(rest[8 : 8+n])
+		data = rest[8+n+1:]
+	}
+	return fields
+}
+
+func TestJournaldHandler(t *testing.T) {
+	// Unix socket paths are limited in length
+	dir, err := os.MkdirTemp("", "jd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "socket")
+	server, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	h, err := NewJournaldHandler(JournaldConfig{Socket: socket, Identifier: "shop"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	logger := slog.New(h).With("route", "/users/:id").WithGroup("http")
+	logger.Debug("Dropped")
+	logger.Error("Server error", "status", 500, "stack", "line 1\nline 2", "_hidden", "x", "priority", "0")
+
+	buf := make([]byte, 65536)
+	server.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := server.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := parseJournalFields(t, buf[:n])
+
+	want := map[string]string{
+		"MESSAGE":           "Server error",
+		"PRIORITY":          "3",
+		"SYSLOG_IDENTIFIER": "shop",
+		"ROUTE":             "/users/:id",
+		"HTTP_STATUS":       "500",
+		"HTTP_STACK":        "line 1\nline 2",
+		"HTTP__HIDDEN":      "x",
+		"HTTP_PRIORITY":     "0",
+	}
+	for name, value := range want {
+		if fields[name] != value {
+			t.Errorf("Expected %s=%q, got %q", name, value, fields[name])
+		}
+	}
+}
+
+func TestJournalFieldName(t *testing.T) {
+	tests := map[string]string{
+		"status":     "STATUS",
+		"user-agent": "USER_AGENT",
+		"_trusted":   "TRUSTED",
+		"2xx":        "XX",
+		"message":    "",
+		"!!!":        "",
+	}
+	for key, want := range tests {
+		if got := journalFieldName(key); got != want {
+			t.Errorf("journalFieldName(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestJournaldHandlerMissingSocket(t *testing.T) {
+	if _, err := NewJournaldHandler(JournaldConfig{Socket: filepath.Join(t.TempDir(), "missing")}); err == nil {
+		t.Error("Expected an error without a journald socket")
+	}
+}
@@ -0,0 +1,448 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ginjigo/ginji"
+)
+
+// jsonapiMediaType is the JSON:API media type.
+const jsonapiMediaType = "application/vnd.api+json"
+
+// JSONAPIError is a JSON:API error object. The resource middleware renders
+// JSONAPIErrors returned by handlers, such as those of BindResource, as
+// JSON:API error documents.
+type JSONAPIError struct {
+	// Status is the HTTP status.
+	Status int
+
+	// Title is a short summary of the problem.
+	Title string
+
+	// Detail explains this occurrence of the problem.
+	Detail string
+
+	// Pointer is the JSON Pointer to the offending member of the request
+	// document, e.g. "/data/attributes/title".
+	Pointer string
+}
+
+// Error implements the error interface.
+func (e *JSONAPIError) Error() string {
+	if e.Detail != "" {
+		return fmt.Sprintf("jsonapi: %s: %s", e.Title, e.Detail)
+	}
+	return "jsonapi: " + e.Title
+}
+
+// jsonapiResource is a resource object.
+type jsonapiResource struct {
+	Type          string                         `json:"type"`
+	ID            string                         `json:"id,omitempty"`
+	Attributes    map[string]json.RawMessage     `json:"attributes,omitempty"`
+	Relationships map[string]jsonapiRelationship `json:"relationships,omitempty"`
+	Links         map[string]string              `json:"links,omitempty"`
+}
+
+// jsonapiIdentifier is a resource identifier object.
+type jsonapiIdentifier struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// jsonapiRelationship is a relationship object. Data is nil, a
+// jsonapiIdentifier or a slice of them.
+type jsonapiRelationship struct {
+	Data any `json:"data"`
+}
+
+// jsonapiDoc is a top-level document.
+type jsonapiDoc struct {
+	Data     any               `json:"data"`
+	Included []jsonapiResource `json:"included,omitempty"`
+	Links    map[string]string `json:"links,omitempty"`
+}
+
+// jsonapiDocument builds the document of nodes. Related resources with
+// attributes are included once, except those that are primary data.
+func jsonapiDocument(config ResourceConfig, self string, nodes []*resourceNode, many bool) jsonapiDoc {
+	seen := make(map[string]bool)
+	for _, node := range nodes {
+		seen[node.typ+"\x00"+node.id] = true
+	}
+
+	var included []jsonapiResource
+	var include func(node *resourceNode)
+	include = func(node *resourceNode) {
+		for _, relation := range node.relationships {
+			for _, related := range relation.nodes {
+				key := related.typ + "\x00" + related.id
+				if seen[key] || (len(related.attributes) == 0 && len(related.relationships) == 0) {
+					continue
+				}
+				seen[key] = true
+				included = append(included, jsonapiResourceObject(config, related))
+				include(related)
+			}
+		}
+	}
+
+	data := make([]jsonapiResource, len(nodes))
+	for i, node := range nodes {
+		data[i] = jsonapiResourceObject(config, node)
+		include(node)
+	}
+
+	doc := jsonapiDoc{Included: included}
+	if many {
+		doc.Data = data
+		doc.Links = map[string]string{"self": self}
+	} else {
+		doc.Data = data[0]
+	}
+	return doc
+}
+
+// jsonapiResourceObject returns the resource object of node.
+func jsonapiResourceObject(config ResourceConfig, node *resourceNode) jsonapiResource {
+	resource := jsonapiResource{Type: node.typ, ID: node.id}
+	if len(node.attributes) > 0 {
+		resource.Attributes = node.attributes
+	}
+	if len(node.relationships) > 0 {
+		resource.Relationships = make(map[string]jsonapiRelationship, len(node.relationships))
+		for _, relation := range node.relationships {
+			var data any
+			switch {
+			case relation.many:
+				ids := make([]jsonapiIdentifier, len(relation.nodes))
+				for i, related := range relation.nodes {
+					ids[i] = jsonapiIdentifier{Type: related.typ, ID: related.id}
+				}
+				data = ids
+			case len(relation.nodes) == 1:
+				data = jsonapiIdentifier{Type: relation.nodes[0].typ, ID: relation.nodes[0].id}
+			}
+			resource.Relationships[relation.name] = jsonapiRelationship{Data: data}
+		}
+	}
+
+	links := make(map[string]string, len(node.links)+1)
+	for name, href := range node.links {
+		links[name] = href
+	}
+	if self := node.selfLink(config); self != "" {
+		links["self"] = self
+	}
+	if len(links) > 0 {
+		resource.Links = links
+	}
+	return resource
+}
+
+// validateJSONAPIRequest checks the media types and request document of
+// a JSON:API request.
+func validateJSONAPIRequest(c *ginji.Context, maxBytes int64) *JSONAPIError {
+	if accept := c.Header("Accept"); accept != "" && !jsonapiAcceptable(accept) {
+		return &JSONAPIError{Status: http.StatusNotAcceptable, Title: "Not Acceptable",
+			Detail: "The JSON:API media type is only offered with unsupported parameters"}
+	}
+
+	if c.Req.Body == nil || c.Req.Body == http.NoBody || c.Req.ContentLength == 0 {
+		return nil
+	}
+	mediaType, params, err := mime.ParseMediaType(c.Header("Content-Type"))
+	if err != nil || mediaType != jsonapiMediaType || !jsonapiParamsSupported(params) {
+		return &JSONAPIError{Status: http.StatusUnsupportedMediaType, Title: "Unsupported Media Type",
+			Detail: "Request documents must use the " + jsonapiMediaType + " media type"}
+	}
+
+	body, err := bufferRequestBody(c, maxBytes)
+	if errors.Is(err, errBodyTooLarge) {
+		return &JSONAPIError{Status: http.StatusRequestEntityTooLarge, Title: "Request Entity Too Large",
+			Detail: fmt.Sprintf("Request documents are limited to %d bytes", maxBytes)}
+	}
+	if err != nil {
+		return &JSONAPIError{Status: http.StatusBadRequest, Title: "Bad Request", Detail: "Failed to read request body"}
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	_, apiErr := parseJSONAPIDocument(body)
+	return apiErr
+}
+
+// jsonapiAcceptable reports whether an Accept header allows JSON:API
+// responses: it must not list the JSON:API media type only with
+// parameters other than ext and profile.
+func jsonapiAcceptable(accept string) bool {
+	found, acceptable := false, false
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || mediaType != jsonapiMediaType {
+			continue
+		}
+		found = true
+		delete(params, "q")
+		acceptable = acceptable || jsonapiParamsSupported(params)
+	}
+	return !found || acceptable
+}
+
+// jsonapiParamsSupported reports whether params only has ext and profile.
+func jsonapiParamsSupported(params map[string]string) bool {
+	for name := range params {
+		if name != "ext" && name != "profile" {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonapiRequestResource is a resource object of a request document.
+type jsonapiRequestResource struct {
+	Type          string                     `json:"type"`
+	ID            *string                    `json:"id"`
+	Attributes    map[string]json.RawMessage `json:"attributes"`
+	Relationships map[string]struct {
+		Data json.RawMessage `json:"data"`
+	} `json:"relationships"`
+}
+
+// parseJSONAPIDocument validates a request document and returns its
+// primary data, which is nil for null data.
+func parseJSONAPIDocument(body []byte) (*jsonapiRequestResource, *JSONAPIError) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, &JSONAPIError{Status: http.StatusBadRequest, Title: "Invalid Document", Detail: "Request body must be a JSON object"}
+	}
+	data, ok := doc["data"]
+	if !ok {
+		return nil, &JSONAPIError{Status: http.StatusBadRequest, Title: "Invalid Document",
+			Detail: "Request documents must have a data member"}
+	}
+	if bytes.Equal(data, []byte("null")) {
+		return nil, nil
+	}
+
+	var resource jsonapiRequestResource
+	if err := json.Unmarshal(data, &resource); err != nil {
+		return nil, &JSONAPIError{Status: http.StatusBadRequest, Title: "Invalid Document",
+			Detail: "Primary data must be a resource object", Pointer: "/data"}
+	}
+	if resource.Type == "" {
+		return nil, &JSONAPIError{Status: http.StatusBadRequest, Title: "Invalid Document",
+			Detail: "Resource objects must have a type", Pointer: "/data/type"}
+	}
+	for name, relationship := range resource.Relationships {
+		if relationship.Data == nil {
+			return nil, &JSONAPIError{Status: http.StatusBadRequest, Title: "Invalid Document",
+				Detail: "Relationships must have a data member", Pointer: "/data/relationships/" + name}
+		}
+	}
+	return &resource, nil
+}
+
+// BindResource decodes the JSON:API request document into v, a pointer to
+// an annotated struct. Attributes and relationships that v does not
+// declare, and types that do not match, are rejected. Errors are
+// *JSONAPIError.
+func BindResource(c *ginji.Context, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("resource: BindResource requires a pointer to a struct")
+	}
+
+	body, err := bufferRequestBody(c, resourceConfigFrom(c).MaxBodyBytes)
+	if err != nil {
+		return &JSONAPIError{Status: http.StatusBadRequest, Title: "Bad Request", Detail: "Failed to read request body"}
+	}
+	resource, apiErr := parseJSONAPIDocument(body)
+	if apiErr != nil {
+		return apiErr
+	}
+	if resource == nil {
+		return &JSONAPIError{Status: http.StatusBadRequest, Title: "Invalid Document",
+			Detail: "Primary data must be a resource object", Pointer: "/data"}
+	}
+	return bindJSONAPIResource(rv.Elem(), resource)
+}
+
+// bindJSONAPIResource sets the annotated fields of v from resource.
+func bindJSONAPIResource(v reflect.Value, resource *jsonapiRequestResource) error {
+	t := v.Type()
+	attributes := make(map[string]bool)
+	relationships := make(map[string]bool)
+
+	for i := 0; i < t.NumField(); i++ {
+		tag, ok := parseResourceTag(t.Field(i))
+		if !ok {
+			continue
+		}
+		field := v.Field(i)
+
+		switch tag.kind {
+		case "primary":
+			if resource.Type != tag.name {
+				return &JSONAPIError{Status: http.StatusConflict, Title: "Conflict",
+					Detail: fmt.Sprintf("Expected type %q", tag.name), Pointer: "/data/type"}
+			}
+			if resource.ID != nil {
+				if err := setResourceID(field, *resource.ID); err != nil {
+					return &JSONAPIError{Status: http.StatusBadRequest, Title: "Invalid Document",
+						Detail: err.Error(), Pointer: "/data/id"}
+				}
+			}
+		case "attr":
+			attributes[tag.name] = true
+			raw, ok := resource.Attributes[tag.name]
+			if !ok {
+				continue
+			}
+			if err := json.Unmarshal(raw, field.Addr().Interface()); err != nil {
+				return &JSONAPIError{Status: http.StatusBadRequest, Title: "Invalid Attribute",
+					Detail: err.Error(), Pointer: "/data/attributes/" + tag.name}
+			}
+		case "relation":
+			relationships[tag.name] = true
+			relationship, ok := resource.Relationships[tag.name]
+			if !ok {
+				continue
+			}
+			if err := bindJSONAPIRelationship(field, relationship.Data); err != nil {
+				return &JSONAPIError{Status: http.StatusBadRequest, Title: "Invalid Relationship",
+					Detail: err.Error(), Pointer: "/data/relationships/" + tag.name}
+			}
+		}
+	}
+
+	for name := range resource.Attributes {
+		if !attributes[name] {
+			return &JSONAPIError{Status: http.StatusBadRequest, Title: "Unknown Attribute",
+				Detail: fmt.Sprintf("Unknown attribute %q", name), Pointer: "/data/attributes/" + name}
+		}
+	}
+	for name := range resource.Relationships {
+		if !relationships[name] {
+			return &JSONAPIError{Status: http.StatusBadRequest, Title: "Unknown Relationship",
+				Detail: fmt.Sprintf("Unknown relationship %q", name), Pointer: "/data/relationships/" + name}
+		}
+	}
+	return nil
+}
+
+// bindJSONAPIRelationship sets field, a to-one or to-many relationship,
+// to resources with the IDs of the linkage data.
+func bindJSONAPIRelationship(field reflect.Value, data json.RawMessage) error {
+	if field.Kind() == reflect.Slice {
+		var ids []jsonapiIdentifier
+		if err := json.Unmarshal(data, &ids); err != nil {
+			return errors.New("to-many relationships must have an array of resource identifiers")
+		}
+		slice := reflect.MakeSlice(field.Type(), 0, len(ids))
+		for _, id := range ids {
+			elem, err := newRelatedResource(field.Type().Elem(), id)
+			if err != nil {
+				return err
+			}
+			slice = reflect.Append(slice, elem)
+		}
+		field.Set(slice)
+		return nil
+	}
+
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+	var id jsonapiIdentifier
+	if err := json.Unmarshal(data, &id); err != nil {
+		return errors.New("to-one relationships must have a resource identifier or null")
+	}
+	elem, err := newRelatedResource(field.Type(), id)
+	if err != nil {
+		return err
+	}
+	field.Set(elem)
+	return nil
+}
+
+// newRelatedResource returns a value of typ, an annotated struct or a
+// pointer to one, with the ID of id.
+func newRelatedResource(typ reflect.Type, id jsonapiIdentifier) (reflect.Value, error) {
+	structType := typ
+	if typ.Kind() == reflect.Pointer {
+		structType = typ.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("resource: %s is not a resource", typ)
+	}
+
+	elem := reflect.New(structType).Elem()
+	for i := 0; i < structType.NumField(); i++ {
+		tag, ok := parseResourceTag(structType.Field(i))
+		if !ok || tag.kind != "primary" {
+			continue
+		}
+		if id.Type != tag.name {
+			return reflect.Value{}, fmt.Errorf("expected type %q, got %q", tag.name, id.Type)
+		}
+		if err := setResourceID(elem.Field(i), id.ID); err != nil {
+			return reflect.Value{}, err
+		}
+	}
+	if typ.Kind() == reflect.Pointer {
+		return elem.Addr(), nil
+	}
+	return elem, nil
+}
+
+// setResourceID sets a string or integer ID field.
+func setResourceID(field reflect.Value, id string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(id)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(id, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid id %q", id)
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(id, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid id %q", id)
+		}
+		field.SetUint(n)
+	default:
+		return fmt.Errorf("resource: unsupported id type %s", field.Type())
+	}
+	return nil
+}
+
+// writeJSONAPIError writes err as a JSON:API error document.
+func writeJSONAPIError(c *ginji.Context, err *JSONAPIError) {
+	object := ginji.H{
+		"status": strconv.Itoa(err.Status),
+		"title":  err.Title,
+	}
+	if err.Detail != "" {
+		object["detail"] = err.Detail
+	}
+	if err.Pointer != "" {
+		object["source"] = ginji.H{"pointer": err.Pointer}
+	}
+	body, _ := json.Marshal(ginji.H{"errors": []ginji.H{object}})
+
+	c.SetHeader("Content-Type", jsonapiMediaType)
+	c.Res.WriteHeader(err.Status)
+	_, _ = c.Res.Write(body)
+}
@@ -0,0 +1,150 @@
+package middleware
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ginjigo/ginji"
+)
+
+func TestJSONAPIDocument(t *testing.T) {
+	app := ginji.New()
+	app.Use(ResourceWithConfig(DefaultResourceConfig()))
+	app.Get("/articles", func(c *ginji.Context) error {
+		return WriteResource(c, ginji.StatusOK, testArticles())
+	})
+	app.Get("/articles/1", func(c *ginji.Context) error {
+		return WriteResource(c, ginji.StatusOK, testArticles()[0])
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/articles/1", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	ginji.AssertHeader(t, w, "Content-Type", "application/vnd.api+json")
+	ginji.AssertBody(t, w, `{"data":{"type":"articles","id":"1","attributes":{"title":"JSON:API"},`+
+		`"relationships":{"author":{"data":{"type":"people","id":"9"}},"comments":{"data":[{"type":"comments","id":"5"}]}},`+
+		`"links":{"html":"https://example.com/a/1","self":"/articles/1"}},`+
+		`"included":[{"type":"people","id":"9","attributes":{"name":"Dan"},"links":{"self":"/people/9"}},`+
+		`{"type":"comments","id":"5","attributes":{"body":"First!"},"links":{"self":"/comments/5"}}]}`)
+}
+
+func TestJSONAPICollection(t *testing.T) {
+	app := ginji.New()
+	app.Use(ResourceWithConfig(DefaultResourceConfig()))
+	app.Get("/articles", func(c *ginji.Context) error {
+		return WriteResource(c, ginji.StatusOK, testArticles())
+	})
+	app.Get("/articles/1", func(c *ginji.Context) error {
+		return WriteResource(c, ginji.StatusOK, testArticles()[0])
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/articles", nil)
+	body := w.Body.String()
+	if strings.Count(body, `{"type":"people","id":"9","attributes"`) != 1 {
+		t.Errorf("Expected the shared author to be included once, got %s", body)
+	}
+	ginji.AssertBody(t, w, `"comments":{"data":[]}`)
+	ginji.AssertBody(t, w, `"links":{"self":"/articles"}`)
+}
+
+func postJSONAPI(app *ginji.Engine, contentType, body string) *ginji.Request {
+	return ginji.NewRequest(app, "POST", "/articles").
+		Header("Content-Type", contentType).
+		Body(strings.NewReader(body))
+}
+
+func TestBindResource(t *testing.T) {
+	var got testArticle
+	app := ginji.New()
+	app.Use(Resources(ResourceJSONAPI))
+	app.Post("/articles", func(c *ginji.Context) error {
+		if err := BindResource(c, &got); err != nil {
+			return err
+		}
+		return WriteResource(c, ginji.StatusCreated, &got)
+	})
+
+	body := `{"data":{"type":"articles","id":"7","attributes":{"title":"Hello","draft":true},` +
+		`"relationships":{"author":{"data":{"type":"people","id":"3"}},"comments":{"data":[{"type":"comments","id":"c1"}]}}}}`
+	w := postJSONAPI(app, "application/vnd.api+json", body).Do()
+	ginji.AssertStatus(t, w, ginji.StatusCreated)
+
+	if got.ID != "7" || got.Title != "Hello" || !got.Draft {
+		t.Errorf("Unexpected attributes %+v", got)
+	}
+	if got.Author == nil || got.Author.ID != 3 {
+		t.Errorf("Expected author 3, got %+v", got.Author)
+	}
+	if len(got.Comments) != 1 || got.Comments[0].ID != "c1" {
+		t.Errorf("Expected comment c1, got %+v", got.Comments)
+	}
+}
+
+func TestJSONAPIRequestValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		accept      string
+		body        string
+		status      int
+		want        string
+	}{
+		{"wrong media type", "application/json", "", `{"data":null}`, ginji.StatusUnsupportedMediaType, `"title":"Unsupported Media Type"`},
+		{"media type parameter", "application/vnd.api+json; charset=utf-8", "", `{"data":null}`, ginji.StatusUnsupportedMediaType, `"status":"415"`},
+		{"profile parameter", `application/vnd.api+json; profile="https://example.com/p"`, "", `{"data":{"type":"articles"}}`, ginji.StatusCreated, ""},
+		{"unacceptable", "application/vnd.api+json", "application/vnd.api+json; version=2", `{"data":{"type":"articles"}}`, ginji.StatusNotAcceptable, `"status":"406"`},
+		{"acceptable", "application/vnd.api+json", "application/vnd.api+json; version=2, application/vnd.api+json", `{"data":{"type":"articles"}}`, ginji.StatusCreated, ""},
+		{"not json", "application/vnd.api+json", "", `data`, ginji.StatusBadRequest, "must be a JSON object"},
+		{"missing data", "application/vnd.api+json", "", `{"meta":{}}`, ginji.StatusBadRequest, "must have a data member"},
+		{"missing type", "application/vnd.api+json", "", `{"data":{"id":"1"}}`, ginji.StatusBadRequest, `"source":{"pointer":"/data/type"}`},
+		{"relationship without data", "application/vnd.api+json", "", `{"data":{"type":"articles","relationships":{"author":{}}}}`, ginji.StatusBadRequest, `"/data/relationships/author"`},
+		{"type conflict", "application/vnd.api+json", "", `{"data":{"type":"people"}}`, ginji.StatusConflict, `"status":"409"`},
+		{"unknown attribute", "application/vnd.api+json", "", `{"data":{"type":"articles","attributes":{"Internal":"x"}}}`, ginji.StatusBadRequest, `"/data/attributes/Internal"`},
+		{"wrong attribute type", "application/vnd.api+json", "", `{"data":{"type":"articles","attributes":{"title":1}}}`, ginji.StatusBadRequest, `"title":"Invalid Attribute"`},
+		{"bad related type", "application/vnd.api+json", "", `{"data":{"type":"articles","relationships":{"author":{"data":{"type":"cats","id":"1"}}}}}`, ginji.StatusBadRequest, `expected type \"people\"`},
+		{"bad related id", "application/vnd.api+json", "", `{"data":{"type":"articles","relationships":{"author":{"data":{"type":"people","id":"x"}}}}}`, ginji.StatusBadRequest, `invalid id \"x\"`},
+	}
+
+	app := ginji.New()
+	app.Use(Resources(ResourceJSONAPI))
+	app.Post("/articles", func(c *ginji.Context) error {
+		var got testArticle
+		if err := BindResource(c, &got); err != nil {
+			return err
+		}
+		return WriteResource(c, ginji.StatusCreated, &got)
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := postJSONAPI(app, tt.contentType, tt.body)
+			if tt.accept != "" {
+				req = req.Header("Accept", tt.accept)
+			}
+			w := req.Do()
+			ginji.AssertStatus(t, w, tt.status)
+			if tt.want != "" {
+				ginji.AssertHeader(t, w, "Content-Type", "application/vnd.api+json")
+				ginji.AssertBody(t, w, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONAPIGetWithoutBody(t *testing.T) {
+	app := ginji.New()
+	app.Use(Resources(ResourceJSONAPI))
+	var got error
+	app.Get("/articles", func(c *ginji.Context) error {
+		var article testArticle
+		got = BindResource(c, &article)
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/articles", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	var apiErr *JSONAPIError
+	if !errors.As(got, &apiErr) || apiErr.Status != ginji.StatusBadRequest {
+		t.Errorf("Expected 400 JSONAPIError, got %v", got)
+	}
+}
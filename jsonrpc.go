@@ -0,0 +1,295 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/ginjigo/ginji"
+)
+
+// JSON-RPC 2.0 error codes.
+const (
+	JSONRPCParseError     = -32700
+	JSONRPCInvalidRequest = -32600
+	JSONRPCMethodNotFound = -32601
+	JSONRPCInvalidParams  = -32602
+	JSONRPCInternalError  = -32603
+)
+
+// JSONRPCError is a JSON-RPC error. Methods return it to send a specific
+// code; other errors are sent as internal errors without their message.
+type JSONRPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+// Error implements the error interface.
+func (e *JSONRPCError) Error() string {
+	return fmt.Sprintf("jsonrpc: %d %s", e.Code, e.Message)
+}
+
+// JSONRPCHandler handles a JSON-RPC method call. Params is the raw
+// "params" member, empty if absent. The result is encoded as JSON.
+type JSONRPCHandler func(c *ginji.Context, params json.RawMessage) (any, error)
+
+// JSONRPCConfig defines the configuration for a JSON-RPC endpoint.
+type JSONRPCConfig struct {
+	// Path is the path the endpoint is mounted at.
+	// Default: "/rpc"
+	Path string
+
+	// MaxBatchSize is the maximum number of calls in a batch.
+	// Default: 100
+	MaxBatchSize int
+
+	// MaxBodyBytes is the maximum request body size.
+	// Default: 1MB
+	MaxBodyBytes int64
+
+	// OnError is called with errors returned by methods that are not
+	// JSONRPCErrors, e.g. to log them, since they are not sent to clients.
+	OnError func(c *ginji.Context, method string, err error)
+
+	// SkipFunc allows skipping the endpoint for certain requests.
+	SkipFunc Skipper
+}
+
+// jsonrpcRequest is a decoded call. ID is nil for notifications.
+type jsonrpcRequest struct {
+	Version string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// jsonrpcResponse is the response to a call.
+type jsonrpcResponse struct {
+	Version string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *JSONRPCError   `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// nullID is the id of responses to requests whose id is unknown.
+var nullID = json.RawMessage("null")
+
+// DefaultJSONRPCConfig returns default JSON-RPC configuration.
+func DefaultJSONRPCConfig() JSONRPCConfig {
+	return JSONRPCConfig{
+		Path:         "/rpc",
+		MaxBatchSize: 100,
+		MaxBodyBytes: 1 << 20, // 1 MB
+	}
+}
+
+// JSONRPC is a JSON-RPC 2.0 endpoint dispatching calls to registered
+// methods:
+//
+//	rpc := middleware.NewJSONRPC(middleware.DefaultJSONRPCConfig())
+//	rpc.Register("math.add", middleware.JSONRPCMethod(func(c *ginji.Context, p [2]int) (int, error) {
+//		return p[0] + p[1], nil
+//	}))
+//	app.Use(rpc.Middleware())
+type JSONRPC struct {
+	config  JSONRPCConfig
+	mu      sync.RWMutex
+	methods map[string]JSONRPCHandler
+}
+
+// NewJSONRPC creates a JSON-RPC endpoint with the given configuration.
+func NewJSONRPC(config JSONRPCConfig) *JSONRPC {
+	defaults := DefaultJSONRPCConfig()
+	if config.Path == "" {
+		config.Path = defaults.Path
+	}
+	if config.MaxBatchSize <= 0 {
+		config.MaxBatchSize = defaults.MaxBatchSize
+	}
+	if config.MaxBodyBytes <= 0 {
+		config.MaxBodyBytes = defaults.MaxBodyBytes
+	}
+	return &JSONRPC{config: config, methods: make(map[string]JSONRPCHandler)}
+}
+
+// Register adds a method, replacing any method of the same name.
+func (r *JSONRPC) Register(name string, handler JSONRPCHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.methods[name] = handler
+}
+
+// JSONRPCMethod adapts a typed function to a JSONRPCHandler. Params are
+// decoded into P, by position for arrays and slices or by name for
+// structs and maps; decoding errors are sent as invalid params.
+func JSONRPCMethod[P, R any](fn func(*ginji.Context, P) (R, error)) JSONRPCHandler {
+	return func(c *ginji.Context, raw json.RawMessage) (any, error) {
+		var params P
+		if len(raw) > 0 {
+			if err := json.Unmarshal(raw, &params); err != nil {
+				return nil, &JSONRPCError{Code: JSONRPCInvalidParams, Message: "Invalid params", Data: err.Error()}
+			}
+		}
+		return fn(c, params)
+	}
+}
+
+// Middleware returns middleware serving the endpoint at the configured
+// path. Calls are handled in order, batches included, and the request
+// context is available to methods through c.Req.Context(). Responses to
+// requests that contain only notifications are empty with status 204.
+func (r *JSONRPC) Middleware() ginji.Middleware {
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if r.config.SkipFunc != nil && r.config.SkipFunc(c) {
+			return c.Next()
+		}
+		if c.Req.URL.Path != r.config.Path {
+			return c.Next()
+		}
+		if c.Req.Method != http.MethodPost {
+			c.SetHeader("Allow", http.MethodPost)
+			c.AbortWithStatusJSON(ginji.StatusMethodNotAllowed, jsonrpcFailure(nullID, JSONRPCInvalidRequest, "Method not allowed"))
+			return nil
+		}
+
+		body, err := bufferRequestBody(c, r.config.MaxBodyBytes)
+		if errors.Is(err, errBodyTooLarge) {
+			c.AbortWithStatusJSON(ginji.StatusRequestEntityTooLarge, jsonrpcFailure(nullID, JSONRPCInvalidRequest, "Request too large"))
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		body = bytes.TrimSpace(body)
+		if !bytes.HasPrefix(body, []byte("[")) {
+			response := r.handle(c, body, true)
+			if response == nil {
+				c.Res.WriteHeader(ginji.StatusNoContent)
+				c.Abort()
+				return nil
+			}
+			c.AbortWithStatusJSON(ginji.StatusOK, response)
+			return nil
+		}
+
+		var batch []json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil {
+			c.AbortWithStatusJSON(ginji.StatusOK, jsonrpcFailure(nullID, JSONRPCParseError, "Parse error"))
+			return nil
+		}
+		if len(batch) == 0 {
+			c.AbortWithStatusJSON(ginji.StatusOK, jsonrpcFailure(nullID, JSONRPCInvalidRequest, "Invalid Request"))
+			return nil
+		}
+		if len(batch) > r.config.MaxBatchSize {
+			c.AbortWithStatusJSON(ginji.StatusOK, jsonrpcFailure(nullID, JSONRPCInvalidRequest,
+				fmt.Sprintf("Batch exceeds the maximum of %d calls", r.config.MaxBatchSize)))
+			return nil
+		}
+
+		responses := make([]*jsonrpcResponse, 0, len(batch))
+		for _, call := range batch {
+			if response := r.handle(c, call, false); response != nil {
+				responses = append(responses, response)
+			}
+		}
+		if len(responses) == 0 {
+			c.Res.WriteHeader(ginji.StatusNoContent)
+			c.Abort()
+			return nil
+		}
+		c.AbortWithStatusJSON(ginji.StatusOK, responses)
+		return nil
+	}
+}
+
+// handle runs one call and returns its response, or nil for
+// notifications. Single calls label the route with the method name.
+func (r *JSONRPC) handle(c *ginji.Context, raw json.RawMessage, single bool) *jsonrpcResponse {
+	var req jsonrpcRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return jsonrpcFailure(nullID, JSONRPCInvalidRequest, "Invalid Request")
+		}
+		return jsonrpcFailure(nullID, JSONRPCParseError, "Parse error")
+	}
+
+	id := req.ID
+	if !validJSONRPCID(id) {
+		return jsonrpcFailure(nullID, JSONRPCInvalidRequest, "Invalid Request")
+	}
+	if id == nil {
+		id = nullID
+	}
+	if req.Version != "2.0" || req.Method == "" {
+		return jsonrpcFailure(id, JSONRPCInvalidRequest, "Invalid Request")
+	}
+	if len(req.Params) > 0 && req.Params[0] != '[' && req.Params[0] != '{' {
+		return jsonrpcFailure(id, JSONRPCInvalidParams, "Invalid params")
+	}
+	notification := req.ID == nil
+
+	r.mu.RLock()
+	handler, ok := r.methods[req.Method]
+	r.mu.RUnlock()
+	if !ok {
+		if notification {
+			return nil
+		}
+		return jsonrpcFailure(id, JSONRPCMethodNotFound, "Method not found")
+	}
+
+	if single {
+		SetRoutePattern(c, r.config.Path+" "+req.Method)
+	}
+	result, err := handler(c, req.Params)
+	if notification {
+		if err != nil && r.config.OnError != nil {
+			r.config.OnError(c, req.Method, err)
+		}
+		return nil
+	}
+	if err != nil {
+		var rpcErr *JSONRPCError
+		if errors.As(err, &rpcErr) {
+			return &jsonrpcResponse{Version: "2.0", Error: rpcErr, ID: id}
+		}
+		if r.config.OnError != nil {
+			r.config.OnError(c, req.Method, err)
+		}
+		return jsonrpcFailure(id, JSONRPCInternalError, "Internal error")
+	}
+
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		if r.config.OnError != nil {
+			r.config.OnError(c, req.Method, err)
+		}
+		return jsonrpcFailure(id, JSONRPCInternalError, "Internal error")
+	}
+	return &jsonrpcResponse{Version: "2.0", Result: encoded, ID: id}
+}
+
+// validJSONRPCID reports whether id is absent or a string, number or null.
+func validJSONRPCID(id json.RawMessage) bool {
+	if id == nil {
+		return true
+	}
+	switch id[0] {
+	case '{', '[', 't', 'f':
+		return false
+	}
+	return true
+}
+
+// jsonrpcFailure returns an error response.
+func jsonrpcFailure(id json.RawMessage, code int, message string) *jsonrpcResponse {
+	return &jsonrpcResponse{Version: "2.0", Error: &JSONRPCError{Code: code, Message: message}, ID: id}
+}
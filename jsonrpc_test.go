@@ -0,0 +1,167 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ginjigo/ginji"
+)
+
+type rpcKey struct{}
+
+func TestJSONRPC(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"positional params", `{"jsonrpc":"2.0","method":"add","params":[2,3],"id":1}`,
+			`{"jsonrpc":"2.0","result":5,"id":1}`},
+		{"named params", `{"jsonrpc":"2.0","method":"greet","params":{"name":"ann"},"id":"a"}`,
+			`{"jsonrpc":"2.0","result":"hello ann","id":"a"}`},
+		{"null result", `{"jsonrpc":"2.0","method":"notify","id":2}`,
+			`{"jsonrpc":"2.0","result":null,"id":2}`},
+		{"null id", `{"jsonrpc":"2.0","method":"add","params":[1,1],"id":null}`,
+			`{"jsonrpc":"2.0","result":2,"id":null}`},
+		{"method not found", `{"jsonrpc":"2.0","method":"nope","id":3}`,
+			`{"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found"},"id":3}`},
+		{"invalid params", `{"jsonrpc":"2.0","method":"add","params":["x"],"id":4}`,
+			`"code":-32602`},
+		{"scalar params", `{"jsonrpc":"2.0","method":"add","params":1,"id":5}`,
+			`{"jsonrpc":"2.0","error":{"code":-32602,"message":"Invalid params"},"id":5}`},
+		{"custom error", `{"jsonrpc":"2.0","method":"fail","id":6}`,
+			`{"jsonrpc":"2.0","error":{"code":42,"message":"custom","data":"details"},"id":6}`},
+		{"internal error", `{"jsonrpc":"2.0","method":"crash","id":7}`,
+			`{"jsonrpc":"2.0","error":{"code":-32603,"message":"Internal error"},"id":7}`},
+		{"context", `{"jsonrpc":"2.0","method":"context","id":8}`,
+			`{"jsonrpc":"2.0","result":"propagated","id":8}`},
+		{"parse error", `{"jsonrpc":"2.0","method`,
+			`{"jsonrpc":"2.0","error":{"code":-32700,"message":"Parse error"},"id":null}`},
+		{"wrong version", `{"jsonrpc":"1.0","method":"add","id":9}`,
+			`{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":9}`},
+		{"invalid id", `{"jsonrpc":"2.0","method":"add","id":{}}`,
+			`{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}`},
+		{"empty batch", `[]`,
+			`{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}`},
+		{"batch", `[{"jsonrpc":"2.0","method":"add","params":[1,2],"id":1},{"jsonrpc":"2.0","method":"notify"},1]`,
+			`[{"jsonrpc":"2.0","result":3,"id":1},{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}]`},
+	}
+
+	rpc := NewJSONRPC(DefaultJSONRPCConfig())
+	rpc.Register("add", JSONRPCMethod(func(c *ginji.Context, p [2]int) (int, error) {
+		return p[0] + p[1], nil
+	}))
+	rpc.Register("greet", JSONRPCMethod(func(c *ginji.Context, p struct{ Name string }) (string, error) {
+		return "hello " + p.Name, nil
+	}))
+	rpc.Register("notify", func(c *ginji.Context, params json.RawMessage) (any, error) {
+		return nil, nil
+	})
+	rpc.Register("fail", func(c *ginji.Context, params json.RawMessage) (any, error) {
+		return nil, &JSONRPCError{Code: 42, Message: "custom", Data: "details"}
+	})
+	rpc.Register("crash", func(c *ginji.Context, params json.RawMessage) (any, error) {
+		return nil, errors.New("database password leaked")
+	})
+	rpc.Register("context", func(c *ginji.Context, params json.RawMessage) (any, error) {
+		return c.Req.Context().Value(rpcKey{}), nil
+	})
+
+	app := ginji.New()
+	app.Use(func(c *ginji.Context) error {
+		c.Req = c.Req.WithContext(context.WithValue(c.Req.Context(), rpcKey{}, "propagated"))
+		return c.Next()
+	})
+	app.Use(rpc.Middleware())
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := ginji.PerformRequest(app, "POST", "/rpc", strings.NewReader(tt.body))
+			ginji.AssertStatus(t, w, ginji.StatusOK)
+			ginji.AssertBody(t, w, tt.want)
+			if strings.Contains(w.Body.String(), "password") {
+				t.Error("Internal error message leaked")
+			}
+		})
+	}
+}
+
+func TestJSONRPCNotifications(t *testing.T) {
+	var notified []string
+	rpc := NewJSONRPC(DefaultJSONRPCConfig())
+	rpc.Register("notify", func(c *ginji.Context, params json.RawMessage) (any, error) {
+		notified = append(notified, string(params))
+		return nil, nil
+	})
+
+	app := ginji.New()
+	app.Use(rpc.Middleware())
+
+	w := ginji.PerformRequest(app, "POST", "/rpc", strings.NewReader(`{"jsonrpc":"2.0","method":"notify","params":[1]}`))
+	ginji.AssertStatus(t, w, ginji.StatusNoContent)
+	if w.Body.Len() != 0 {
+		t.Errorf("Expected empty body, got %q", w.Body.String())
+	}
+
+	body := `[{"jsonrpc":"2.0","method":"notify","params":[2]},{"jsonrpc":"2.0","method":"unknown"}]`
+	w = ginji.PerformRequest(app, "POST", "/rpc", strings.NewReader(body))
+	ginji.AssertStatus(t, w, ginji.StatusNoContent)
+
+	if strings.Join(notified, ",") != "[1],[2]" {
+		t.Errorf("Expected both notifications, got %v", notified)
+	}
+}
+
+func TestJSONRPCRouting(t *testing.T) {
+	config := DefaultJSONRPCConfig()
+	config.MaxBatchSize = 1
+	rpc := NewJSONRPC(config)
+	rpc.Register("add", JSONRPCMethod(func(c *ginji.Context, p [2]int) (int, error) {
+		return p[0] + p[1], nil
+	}))
+
+	app := ginji.New()
+	app.Use(rpc.Middleware())
+	app.Get("/health", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/health", nil)
+	ginji.AssertBody(t, w, "ok")
+
+	w = ginji.PerformRequest(app, "GET", "/rpc", nil)
+	ginji.AssertStatus(t, w, ginji.StatusMethodNotAllowed)
+	ginji.AssertHeader(t, w, "Allow", "POST")
+
+	body := `[{"jsonrpc":"2.0","method":"add","id":1},{"jsonrpc":"2.0","method":"add","id":2}]`
+	w = ginji.PerformRequest(app, "POST", "/rpc", strings.NewReader(body))
+	ginji.AssertBody(t, w, "Batch exceeds the maximum of 1 calls")
+}
+
+func TestJSONRPCOnError(t *testing.T) {
+	var logged []string
+	config := DefaultJSONRPCConfig()
+	config.OnError = func(c *ginji.Context, method string, err error) {
+		logged = append(logged, method+": "+err.Error())
+	}
+	rpc := NewJSONRPC(config)
+	rpc.Register("fail", func(c *ginji.Context, params json.RawMessage) (any, error) {
+		return nil, &JSONRPCError{Code: 42, Message: "custom", Data: "details"}
+	})
+	rpc.Register("crash", func(c *ginji.Context, params json.RawMessage) (any, error) {
+		return nil, errors.New("database password leaked")
+	})
+
+	app := ginji.New()
+	app.Use(rpc.Middleware())
+
+	ginji.PerformRequest(app, "POST", "/rpc", strings.NewReader(`{"jsonrpc":"2.0","method":"crash","id":1}`))
+	ginji.PerformRequest(app, "POST", "/rpc", strings.NewReader(`{"jsonrpc":"2.0","method":"fail","id":2}`))
+
+	if len(logged) != 1 || logged[0] != "crash: database password leaked" {
+		t.Errorf("Expected only the internal error to be reported, got %v", logged)
+	}
+}
@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/ginjigo/ginji"
+)
+
+// KeyFunc identifies the client or resource a request belongs to, for the
+// KeyFunc options of RateLimit, TieredRateLimit, BruteForce and the
+// QuotaKeyFunc of BodyLimit. The builders below return "" when the request
+// lacks the value. RateLimit, TieredRateLimit and BruteForce then fall back
+// to the client IP, so that such clients do not share one bucket, while
+// RateLimitPolicy and the BodyLimit quota do not apply to the request.
+// Combine them with CombineKeys to key by several values:
+//
+//	config.KeyFunc = middleware.CombineKeys(
+//		middleware.ByIP("10.0.0.0/8"),
+//		middleware.ByHeader("X-API-Key"),
+//	)
+type KeyFunc func(*ginji.Context) string
+
+// ByIP keys requests by client IP, without the port. X-Forwarded-For and
+// X-Real-IP are honored only when the peer is one of trustedProxies.
+func ByIP(trustedProxies ...string) KeyFunc {
+	return func(c *ginji.Context) string {
+		return "ip:" + clientIP(c.Req, trustedProxies)
+	}
+}
+
+// ByHeader keys requests by the value of a request header.
+func ByHeader(name string) KeyFunc {
+	prefix := "header:" + strings.ToLower(name) + ":"
+	return func(c *ginji.Context) string {
+		if v := c.Req.Header.Get(name); v != "" {
+			return prefix + v
+		}
+		return ""
+	}
+}
+
+// ByCookie keys requests by the value of a cookie.
+func ByCookie(name string) KeyFunc {
+	prefix := "cookie:" + name + ":"
+	return func(c *ginji.Context) string {
+		if cookie, err := c.Req.Cookie(name); err == nil && cookie.Value != "" {
+			return prefix + cookie.Value
+		}
+		return ""
+	}
+}
+
+// ByJWTClaim keys requests by a claim of the JWT claims stored by JWT
+// middleware, e.g. "sub" or "tenant_id".
+func ByJWTClaim(claim string) KeyFunc {
+	prefix := "claim:" + claim + ":"
+	return func(c *ginji.Context) string {
+		claims, ok := ClaimsFrom(c)
+		if !ok {
+			return ""
+		}
+		v, ok := claims[claim]
+		if !ok || v == nil {
+			return ""
+		}
+		return prefix + fmt.Sprint(v)
+	}
+}
+
+// keyOrIP returns key, or the client IP if the KeyFunc found nothing to key
+// the request by.
+func keyOrIP(c *ginji.Context, key string, trustedProxies []string) string {
+	if key != "" {
+		return key
+	}
+	return "ip:" + clientIP(c.Req, trustedProxies)
+}
+
+// ByPath keys requests by URL path.
+func ByPath() KeyFunc {
+	return func(c *ginji.Context) string {
+		return "path:" + c.Req.URL.Path
+	}
+}
+
+// CombineKeys keys requests by all of keys, hashing them into a stable
+// fixed-length key so that secrets such as API keys are not kept or
+// reported in the clear. It returns "" when every key is empty.
+func CombineKeys(keys ...KeyFunc) KeyFunc {
+	return func(c *ginji.Context) string {
+		h := sha256.New()
+		empty := true
+		for _, key := range keys {
+			part := key(c)
+			if part != "" {
+				empty = false
+			}
+			// Length-prefix each part so that different splits of the same
+			// bytes hash differently
+			fmt.Fprintf(h, "%d:%s", len(part), part)
+		}
+		if empty {
+			return ""
+		}
+		return hex.EncodeToString(h.Sum(nil)[:16])
+	}
+}
@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+func newKeyFuncContext(req *http.Request) *ginji.Context {
+	return ginji.NewContext(httptest.NewRecorder(), req, ginji.New())
+}
+
+func TestKeyFuncs(t *testing.T) {
+	req := httptest.NewRequest("GET", "/orders/7", nil)
+	req.RemoteAddr = "10.0.0.1:4321"
+	req.Header.Set("X-Forwarded-For", "203.0.113.9, 10.0.0.1")
+	req.Header.Set("X-API-Key", "k-123")
+	req.AddCookie(&http.Cookie{Name: "sid", Value: "s-456"})
+	c := newKeyFuncContext(req)
+	SetClaims(c, map[string]any{"sub": "user-1", "tenant": 42.0})
+
+	tests := []struct {
+		name string
+		key  KeyFunc
+		want string
+	}{
+		{"ip", ByIP(), "ip:10.0.0.1"},
+		{"ip behind proxy", ByIP("10.0.0.0/8"), "ip:203.0.113.9"},
+		{"header", ByHeader("X-API-Key"), "header:x-api-key:k-123"},
+		{"missing header", ByHeader("Authorization"), ""},
+		{"cookie", ByCookie("sid"), "cookie:sid:s-456"},
+		{"missing cookie", ByCookie("other"), ""},
+		{"claim", ByJWTClaim("sub"), "claim:sub:user-1"},
+		{"number claim", ByJWTClaim("tenant"), "claim:tenant:42"},
+		{"missing claim", ByJWTClaim("email"), ""},
+		{"path", ByPath(), "path:/orders/7"},
+	}
+	for _, tt := range tests {
+		if got := tt.key(c); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestCombineKeys(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-API-Key", "secret")
+	c := newKeyFuncContext(req)
+
+	key := CombineKeys(ByIP(), ByHeader("X-API-Key"))
+	got := key(c)
+	if len(got) != 32 || got != key(c) {
+		t.Fatalf("Expected a stable 32 character key, got %q", got)
+	}
+	if got == CombineKeys(ByHeader("X-API-Key"), ByIP())(c) {
+		t.Error("Expected the order of keys to matter")
+	}
+
+	// Splitting the same bytes differently gives a different key
+	split := func(a, b string) string {
+		return CombineKeys(
+			func(*ginji.Context) string { return a },
+			func(*ginji.Context) string { return b },
+		)(c)
+	}
+	if split("ab", "c") == split("a", "bc") {
+		t.Error("Expected different splits to give different keys")
+	}
+
+	if got := CombineKeys(ByHeader("X-Missing"), ByCookie("sid"))(c); got != "" {
+		t.Errorf("Expected an empty key when every part is empty, got %q", got)
+	}
+}
+
+func TestKeyFuncRateLimit(t *testing.T) {
+	config := DefaultRateLimiterConfig()
+	config.Max = 1
+	config.Window = time.Minute
+	config.KeyFunc = ByHeader("X-API-Key")
+
+	app := ginji.New()
+	app.Use(RateLimitWithConfig(config))
+	app.Get("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	for _, key := range []string{"a", "b"} {
+		w := ginji.NewRequest(app, "GET", "/").Header("X-API-Key", key).Do()
+		ginji.AssertStatus(t, w, ginji.StatusOK)
+	}
+	w := ginji.NewRequest(app, "GET", "/").Header("X-API-Key", "a").Do()
+	ginji.AssertStatus(t, w, ginji.StatusTooManyRequests)
+
+	// Clients without the header are limited by IP, not in one shared bucket
+	for _, addr := range []string{"192.0.2.1:1234", "192.0.2.2:1234"} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = addr
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		ginji.AssertStatus(t, w, ginji.StatusOK)
+	}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "192.0.2.1:5678"
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	ginji.AssertStatus(t, w, ginji.StatusTooManyRequests)
+}
@@ -0,0 +1,310 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrNoKeys is returned by key providers that have no keys.
+var ErrNoKeys = errors.New("keyprovider: no keys")
+
+// Key is a secret key with an optional ID, such as a JWT "kid", naming it
+// in signed data so verifiers can pick the key directly.
+type Key struct {
+	ID    string
+	Value []byte
+}
+
+// KeyProvider supplies the keys of SignedURL, SecureCookie and
+// WebhookVerify, so that keys are loaded and rotated the same way across
+// the crypto middleware. The first key signs new data and every key is
+// accepted when verifying, which allows rotating keys without downtime:
+// add the new key second, deploy, move it first, then drop the old one.
+// Implementations must be safe for concurrent use.
+type KeyProvider interface {
+	// Keys returns the current keys, the signing key first.
+	Keys() ([]Key, error)
+}
+
+// KeyProviderFunc adapts a function to the KeyProvider interface.
+type KeyProviderFunc func() ([]Key, error)
+
+// Keys calls f().
+func (f KeyProviderFunc) Keys() ([]Key, error) {
+	return f()
+}
+
+// StaticKeys returns a provider of fixed keys.
+func StaticKeys(keys ...Key) KeyProvider {
+	return KeyProviderFunc(func() ([]Key, error) {
+		if len(keys) == 0 {
+			return nil, ErrNoKeys
+		}
+		return keys, nil
+	})
+}
+
+// EnvKeys returns a provider reading keys from environment variables on
+// every call, each key named after its variable. Unset variables are
+// skipped, so that a rotated-out key can simply be removed.
+func EnvKeys(names ...string) KeyProvider {
+	return KeyProviderFunc(func() ([]Key, error) {
+		var keys []Key
+		for _, name := range names {
+			if v, ok := os.LookupEnv(name); ok && v != "" {
+				keys = append(keys, Key{ID: name, Value: []byte(v)})
+			}
+		}
+		if len(keys) == 0 {
+			return nil, ErrNoKeys
+		}
+		return keys, nil
+	})
+}
+
+// FileKeys returns a provider of the keys in path, reloaded when the file
+// changes as with Reloader.WatchFile, polling every interval until ctx is
+// done. Each non-empty line not starting with "#" is a key, optionally
+// prefixed by its ID and a colon:
+//
+//	# signing key first
+//	2025-06:Zm9vYmFyYmF6cXV4cXV1eGNvcmdlZ3JhdWx0Z2FycGx5
+//	2025-01:b2xkIGtleSBzdGlsbCBhY2NlcHRlZCBmb3IgdmVyaWZ5
+//
+// Key values are used as written. Invalid reloads are passed to onError,
+// if set, and keep the current keys.
+func FileKeys(ctx context.Context, path string, interval time.Duration, onError func(error)) (KeyProvider, error) {
+	keys := NewReloader[[]Key](nil)
+	if err := keys.WatchFile(ctx, path, interval, parseKeyFile, onError); err != nil {
+		return nil, err
+	}
+	return KeyProviderFunc(func() ([]Key, error) {
+		return keys.Load(), nil
+	}), nil
+}
+
+// parseKeyFile parses the key file format of FileKeys.
+func parseKeyFile(data []byte) ([]Key, error) {
+	var keys []Key
+	for line := range bytes.Lines(data) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		var key Key
+		if id, value, ok := bytes.Cut(line, []byte(":")); ok {
+			key.ID, line = string(bytes.TrimSpace(id)), bytes.TrimSpace(value)
+		}
+		if len(line) == 0 {
+			return nil, fmt.Errorf("keyprovider: empty key %q", key.ID)
+		}
+		key.Value = bytes.Clone(line)
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+	return keys, nil
+}
+
+// KeyFetcher loads keys from a secret store, such as VaultKVKeys,
+// VaultTransitKeys or KMSKeys.
+type KeyFetcher func(ctx context.Context) ([]Key, error)
+
+// RefreshingKeysConfig defines the configuration for RefreshingKeys.
+type RefreshingKeysConfig struct {
+	// Interval is how often the keys are fetched again, picking up
+	// rotations.
+	// Default: 5 minutes
+	Interval time.Duration
+
+	// RetryInterval is how soon a failed fetch is retried.
+	// Default: 30 seconds, or Interval if shorter
+	RetryInterval time.Duration
+
+	// Timeout bounds each fetch.
+	// Default: 10 seconds
+	Timeout time.Duration
+
+	// MaxStale is how long the last fetched keys keep being served while
+	// fetches fail. After that, Keys returns the fetch error, so signing
+	// and verification fail closed.
+	// Default: 0 (serve the last keys until a fetch succeeds)
+	MaxStale time.Duration
+
+	// OnError is called with every failed fetch. Optional.
+	OnError func(error)
+}
+
+// DefaultRefreshingKeysConfig returns default refreshing keys
+// configuration.
+func DefaultRefreshingKeysConfig() RefreshingKeysConfig {
+	return RefreshingKeysConfig{
+		Interval:      5 * time.Minute,
+		RetryInterval: 30 * time.Second,
+		Timeout:       10 * time.Second,
+	}
+}
+
+// RefreshingKeys is a KeyProvider caching the keys of a KeyFetcher and
+// fetching them again in the background, so that requests never wait on
+// the secret store and keep working through its outages:
+//
+//	keys, err := middleware.NewRefreshingKeys(
+//		middleware.VaultKVKeys(middleware.VaultConfig{}, "secret", "app/cookie-keys"),
+//		middleware.DefaultRefreshingKeysConfig(),
+//	)
+//	defer keys.Close()
+//	cookies, err := middleware.NewSecureCookie(middleware.SecureCookieConfig{HashKeyProvider: keys})
+//
+// RefreshingKeys implements HealthReporter, reporting unhealthy while
+// fetches fail.
+type RefreshingKeys struct {
+	fetch  KeyFetcher
+	config RefreshingKeysConfig
+
+	mu        sync.RWMutex
+	keys      []Key
+	fetchedAt time.Time
+	lastErr   error
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewRefreshingKeys fetches the keys and starts refreshing them. It returns
+// the error of the initial fetch.
+func NewRefreshingKeys(fetch KeyFetcher, config RefreshingKeysConfig) (*RefreshingKeys, error) {
+	defaults := DefaultRefreshingKeysConfig()
+	if config.Interval <= 0 {
+		config.Interval = defaults.Interval
+	}
+	if config.RetryInterval <= 0 {
+		config.RetryInterval = min(defaults.RetryInterval, config.Interval)
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+
+	k := &RefreshingKeys{fetch: fetch, config: config, done: make(chan struct{})}
+	if err := k.Refresh(context.Background()); err != nil {
+		return nil, err
+	}
+	go k.refreshLoop()
+	return k, nil
+}
+
+// Keys returns the cached keys, or the last fetch error once they are
+// older than MaxStale.
+func (k *RefreshingKeys) Keys() ([]Key, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if k.lastErr != nil && k.config.MaxStale > 0 && time.Since(k.fetchedAt) > k.config.MaxStale {
+		return nil, k.lastErr
+	}
+	return k.keys, nil
+}
+
+// Refresh fetches the keys now, e.g. right after rotating them. On failure
+// the cached keys are kept.
+func (k *RefreshingKeys) Refresh(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, k.config.Timeout)
+	defer cancel()
+
+	keys, err := k.fetch(ctx)
+	if err == nil && len(keys) == 0 {
+		err = ErrNoKeys
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.lastErr = err
+	if err != nil {
+		return err
+	}
+	k.keys, k.fetchedAt = keys, time.Now()
+	return nil
+}
+
+// HealthCheck implements HealthReporter.
+func (k *RefreshingKeys) HealthCheck() error {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.lastErr
+}
+
+// Close stops refreshing the keys. The cached keys are still served.
+func (k *RefreshingKeys) Close() error {
+	k.closeOnce.Do(func() {
+		close(k.done)
+	})
+	return nil
+}
+
+// refreshLoop refreshes the keys every Interval, retrying failures every
+// RetryInterval, until Close.
+func (k *RefreshingKeys) refreshLoop() {
+	timer := time.NewTimer(k.config.Interval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-k.done:
+			return
+		case <-timer.C:
+		}
+
+		next := k.config.Interval
+		if err := k.Refresh(context.Background()); err != nil {
+			next = k.config.RetryInterval
+			if k.config.OnError != nil {
+				k.config.OnError(err)
+			}
+		}
+		timer.Reset(next)
+	}
+}
+
+// staticKeyValues wraps raw keys, as found in the Keys options predating
+// KeyProvider, in a provider.
+func staticKeyValues(values [][]byte) KeyProvider {
+	keys := make([]Key, len(values))
+	for i, v := range values {
+		keys[i] = Key{Value: v}
+	}
+	return StaticKeys(keys...)
+}
+
+// loadKeys returns the keys of provider, checking that there is at least
+// one and that every key is at least minLen bytes long.
+func loadKeys(provider KeyProvider, minLen int) ([]Key, error) {
+	keys, err := provider.Keys()
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+	for _, key := range keys {
+		if len(key.Value) < minLen {
+			return nil, fmt.Errorf("keyprovider: key %q is shorter than %d bytes", key.ID, minLen)
+		}
+	}
+	return keys, nil
+}
+
+// findKey returns the key with the given ID.
+func findKey(keys []Key, id string) (Key, bool) {
+	for _, key := range keys {
+		if key.ID == id {
+			return key, true
+		}
+	}
+	return Key{}, false
+}
@@ -0,0 +1,263 @@
+package middleware
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+func TestStaticAndEnvKeys(t *testing.T) {
+	if _, err := StaticKeys().Keys(); !errors.Is(err, ErrNoKeys) {
+		t.Errorf("Expected ErrNoKeys, got %v", err)
+	}
+
+	t.Setenv("APP_KEY_NEW", "new-secret")
+	t.Setenv("APP_KEY_OLD", "old-secret")
+	keys, err := EnvKeys("APP_KEY_NEW", "APP_KEY_UNSET", "APP_KEY_OLD").Keys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0].ID != "APP_KEY_NEW" || string(keys[1].Value) != "old-secret" {
+		t.Errorf("Unexpected keys %+v", keys)
+	}
+	if _, err := EnvKeys("APP_KEY_UNSET").Keys(); !errors.Is(err, ErrNoKeys) {
+		t.Errorf("Expected ErrNoKeys, got %v", err)
+	}
+}
+
+func TestParseKeyFile(t *testing.T) {
+	keys, err := parseKeyFile([]byte("# comment\n\n2025-06: first \nsecond\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0].ID != "2025-06" || string(keys[0].Value) != "first" ||
+		keys[1].ID != "" || string(keys[1].Value) != "second" {
+		t.Errorf("Unexpected keys %+v", keys)
+	}
+
+	for _, data := range []string{"", "# only comments\n", "kid:\n"} {
+		if _, err := parseKeyFile([]byte(data)); err == nil {
+			t.Errorf("Expected an error for %q", data)
+		}
+	}
+}
+
+func TestFileKeysReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys")
+	if err := os.WriteFile(path, []byte("v1:"+strings.Repeat("a", 32)+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	provider, err := FileKeys(ctx, path, 5*time.Millisecond, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := NewURLSigner(SignedURLConfig{KeyProvider: provider})
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := ginji.New()
+	app.Use(signer.Middleware())
+	app.Get("/files/:name", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "file "+c.Param("name"))
+	})
+
+	oldLink, err := signer.Sign("/files/a.txt", SignedURLOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(oldLink, "kid=v1") {
+		t.Errorf("Expected the key ID in the link, got %s", oldLink)
+	}
+
+	// Rotate: the new key signs, the old one still verifies
+	rotated := "v2:" + strings.Repeat("b", 32) + "\nv1:" + strings.Repeat("a", 32) + "\n"
+	if err := os.WriteFile(path, []byte(rotated), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		keys, _ := provider.Keys()
+		if keys[0].ID == "v2" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the rotated keys to be loaded")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	newLink, _ := signer.Sign("/files/a.txt", SignedURLOptions{})
+	if !strings.Contains(newLink, "kid=v2") {
+		t.Errorf("Expected the new key ID in the link, got %s", newLink)
+	}
+	for _, link := range []string{oldLink, newLink} {
+		w := ginji.PerformRequest(app, "GET", link, nil)
+		ginji.AssertStatus(t, w, ginji.StatusOK)
+	}
+
+	// The key ID is signed, so it cannot be swapped for another key's
+	w := ginji.PerformRequest(app, "GET", strings.Replace(oldLink, "kid=v1", "kid=v2", 1), nil)
+	ginji.AssertStatus(t, w, ginji.StatusForbidden)
+}
+
+func TestSecureCookieKeyProvider(t *testing.T) {
+	keys := NewReloader([]Key{{Value: testHashKey}})
+	provider := KeyProviderFunc(func() ([]Key, error) { return keys.Load(), nil })
+	sc, err := NewSecureCookie(SecureCookieConfig{
+		HashKeyProvider:  provider,
+		BlockKeyProvider: StaticKeys(Key{Value: testBlockKey}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encoded, err := sc.Encode("session", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys.Store([]Key{{Value: testHashKey2}, {Value: testHashKey}})
+	if value, err := sc.Decode("session", encoded); err != nil || value != "alice" {
+		t.Errorf("Expected the old key to still verify, got %q, %v", value, err)
+	}
+	keys.Store([]Key{{Value: testHashKey2}})
+	if _, err := sc.Decode("session", encoded); !errors.Is(err, ErrCookieInvalid) {
+		t.Errorf("Expected the dropped key to fail, got %v", err)
+	}
+
+	keys.Store([]Key{{Value: []byte("short")}})
+	if _, err := sc.Encode("session", "alice"); err == nil {
+		t.Error("Expected an error for a short key")
+	}
+}
+
+func TestWebhookSecretProvider(t *testing.T) {
+	t.Setenv("WEBHOOK_SECRET", testWebhookSecret)
+	config := DefaultWebhookConfig()
+	config.Verifier = GitHubWebhook()
+	config.SecretProvider = EnvKeys("WEBHOOK_SECRET_NEXT", "WEBHOOK_SECRET")
+	app := ginji.New()
+	app.Use(WebhookVerifyWithConfig(config))
+	app.Post("/hook", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	body := `{"action":"closed"}`
+	w := sendWebhook(app, body, http.Header{
+		"X-Hub-Signature-256": {"sha256=" + hmacHex(testWebhookSecret, body)},
+	})
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+}
+
+func TestRefreshingKeys(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		fail    bool
+		fetches int
+		errs    int
+	)
+	fetch := func(ctx context.Context) ([]Key, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		fetches++
+		if fail {
+			return nil, errors.New("vault: sealed")
+		}
+		return []Key{{ID: strconv.Itoa(fetches), Value: testHashKey}}, nil
+	}
+
+	keys, err := NewRefreshingKeys(fetch, RefreshingKeysConfig{
+		Interval: 10 * time.Millisecond,
+		MaxStale: 200 * time.Millisecond,
+		OnError: func(error) {
+			mu.Lock()
+			errs++
+			mu.Unlock()
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer keys.Close()
+
+	waitFor := func(what string, cond func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatalf("Timed out waiting for %s", what)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	waitFor("a refresh", func() bool {
+		current, _ := keys.Keys()
+		return current[0].ID != "1"
+	})
+
+	// Failed fetches keep serving the last keys until MaxStale
+	mu.Lock()
+	fail = true
+	mu.Unlock()
+	waitFor("a failed fetch", func() bool { return keys.HealthCheck() != nil })
+	if current, err := keys.Keys(); err != nil || len(current) != 1 {
+		t.Errorf("Expected the cached keys while stale, got %v", err)
+	}
+	waitFor("MaxStale", func() bool {
+		_, err := keys.Keys()
+		return err != nil
+	})
+	mu.Lock()
+	if errs == 0 {
+		t.Error("Expected OnError to be called")
+	}
+	fail = false
+	mu.Unlock()
+
+	if err := keys.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keys.Keys(); err != nil || keys.HealthCheck() != nil {
+		t.Errorf("Expected recovery after a successful fetch, got %v", err)
+	}
+
+	if _, err := NewRefreshingKeys(func(context.Context) ([]Key, error) { return nil, nil }, RefreshingKeysConfig{}); !errors.Is(err, ErrNoKeys) {
+		t.Errorf("Expected ErrNoKeys from the initial fetch, got %v", err)
+	}
+}
+
+func TestKMSKeys(t *testing.T) {
+	t.Setenv("WRAPPED_KEY", base64.StdEncoding.EncodeToString([]byte("wrapped:secret")))
+	decrypt := func(ctx context.Context, blob []byte) ([]byte, error) {
+		plain, ok := strings.CutPrefix(string(blob), "wrapped:")
+		if !ok {
+			return nil, errors.New("InvalidCiphertextException")
+		}
+		return []byte(plain), nil
+	}
+
+	keys, err := KMSKeys(decrypt, EnvKeys("WRAPPED_KEY"))(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0].ID != "WRAPPED_KEY" || string(keys[0].Value) != "secret" {
+		t.Errorf("Unexpected keys %+v", keys)
+	}
+
+	t.Setenv("WRAPPED_KEY", base64.StdEncoding.EncodeToString([]byte("tampered")))
+	if _, err := KMSKeys(decrypt, EnvKeys("WRAPPED_KEY"))(context.Background()); err == nil {
+		t.Error("Expected the decrypt error")
+	}
+}
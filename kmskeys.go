@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+)
+
+// KMSDecryptFunc decrypts a data key with a cloud KMS, so that this package
+// does not depend on the cloud SDKs. With AWS KMS:
+//
+//	decrypt := func(ctx context.Context, blob []byte) ([]byte, error) {
+//		out, err := client.Decrypt(ctx, &kms.DecryptInput{CiphertextBlob: blob})
+//		if err != nil {
+//			return nil, err
+//		}
+//		return out.Plaintext, nil
+//	}
+//
+// With Google Cloud KMS:
+//
+//	decrypt := func(ctx context.Context, blob []byte) ([]byte, error) {
+//		resp, err := client.Decrypt(ctx, &kmspb.DecryptRequest{Name: keyName, Ciphertext: blob})
+//		if err != nil {
+//			return nil, err
+//		}
+//		return resp.Plaintext, nil
+//	}
+type KMSDecryptFunc func(ctx context.Context, ciphertext []byte) ([]byte, error)
+
+// KMSKeys returns a KeyFetcher for envelope-encrypted keys: the keys of
+// wrapped are base64-encoded data keys encrypted under a KMS key, e.g. the
+// CiphertextBlob of AWS GenerateDataKey, and are decrypted with decrypt on
+// every fetch. Keys keep their IDs and order, so rotating means adding a
+// new wrapped key to the environment or key file:
+//
+//	keys, err := middleware.NewRefreshingKeys(
+//		middleware.KMSKeys(decrypt, middleware.EnvKeys("COOKIE_KEY_2", "COOKIE_KEY_1")),
+//		middleware.DefaultRefreshingKeysConfig(),
+//	)
+//
+// Plaintext keys only live in memory; use it with NewRefreshingKeys so that
+// KMS is not called per request.
+func KMSKeys(decrypt KMSDecryptFunc, wrapped KeyProvider) KeyFetcher {
+	return func(ctx context.Context) ([]Key, error) {
+		blobs, err := wrapped.Keys()
+		if err != nil {
+			return nil, fmt.Errorf("kms: %w", err)
+		}
+
+		keys := make([]Key, len(blobs))
+		for i, blob := range blobs {
+			ciphertext, err := base64.StdEncoding.DecodeString(string(blob.Value))
+			if err != nil {
+				return nil, fmt.Errorf("kms: key %q: %w", blob.ID, err)
+			}
+			plaintext, err := decrypt(ctx, ciphertext)
+			if err != nil {
+				return nil, fmt.Errorf("kms: key %q: %w", blob.ID, err)
+			}
+			keys[i] = Key{ID: blob.ID, Value: plaintext}
+		}
+		return keys, nil
+	}
+}
@@ -1,7 +1,10 @@
 package middleware
 
 import (
+	"io"
 	"log/slog"
+	"math/rand/v2"
+	"sync"
 	"time"
 
 	"github.com/ginjigo/ginji"
@@ -12,11 +15,85 @@ type LoggerConfig struct {
 	// Logger is the slog logger instance to use. If nil, uses engine's logger.
 	Logger *slog.Logger
 
+	// Handler receives the entries when Logger is nil, e.g. a
+	// SyslogHandler or JournaldHandler.
+	Handler slog.Handler
+
+	// Output receives the entries as JSON lines when Logger and Handler are
+	// nil, e.g. a RotatingFile.
+	Output io.Writer
+
 	// SkipPaths is a list of paths to skip logging (e.g., health checks).
 	SkipPaths []string
 
 	// SkipFunc allows custom logic to skip logging for certain requests.
-	SkipFunc func(*ginji.Context) bool
+	SkipFunc Skipper
+
+	// Scrubber, if set, removes PII from the logged path and query string.
+	Scrubber *Scrubber
+
+	// SampleRate is the fraction (0-1] of successful responses that are
+	// logged. Responses with a 4xx or 5xx status are always logged.
+	// Requests carrying a decision from Sampling middleware follow it
+	// instead.
+	// Default: 1 (log every request)
+	SampleRate float64
+
+	// PathSampleRates overrides SampleRate for paths matching a glob
+	// pattern (see PathGlob). The longest matching pattern wins, and a
+	// rate of 0 drops successful responses for that path.
+	PathSampleRates map[string]float64
+
+	// MaxPerSecond caps the number of entries logged per second so a
+	// traffic spike cannot flood the log pipeline. Entries over the limit
+	// are dropped and counted in the next entry that is logged.
+	// Default: 0 (no limit)
+	MaxPerSecond int
+
+	// Burst is the number of entries that may be logged at once before
+	// MaxPerSecond applies.
+	// Default: MaxPerSecond
+	Burst int
+
+	// SlowThreshold marks requests slower than this as slow: they are
+	// always logged, at Warn level or above, with a slow=true attribute.
+	// Default: 0 (disabled)
+	SlowThreshold time.Duration
+
+	// RouteSlowThresholds overrides SlowThreshold for paths matching a
+	// glob pattern (see PathGlob). The longest matching pattern wins.
+	RouteSlowThresholds map[string]time.Duration
+
+	// OnSlow is called for every slow request, e.g. to raise an alert.
+	OnSlow func(c *ginji.Context, latency time.Duration)
+}
+
+// logBurstLimiter is a token bucket that bounds the log rate.
+type logBurstLimiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   float64
+	tokens  float64
+	last    time.Time
+	dropped int64
+}
+
+// allow takes a token if one is available and returns the number of
+// entries dropped since the last allowed one.
+func (l *logBurstLimiter) allow(now time.Time) (bool, int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	if l.tokens < 1 {
+		l.dropped++
+		return false, 0
+	}
+	l.tokens--
+	dropped := l.dropped
+	l.dropped = 0
+	return true, dropped
 }
 
 // DefaultLoggerConfig returns the default logger configuration.
@@ -38,6 +115,33 @@ func LoggerWithConfig(config LoggerConfig) ginji.Middleware {
 		skipPaths[path] = true
 	}
 
+	if config.SampleRate <= 0 {
+		config.SampleRate = 1
+	}
+	if config.Logger == nil && config.Handler != nil {
+		config.Logger = slog.New(config.Handler)
+	}
+	if config.Logger == nil && config.Output != nil {
+		config.Logger = slog.New(slog.NewJSONHandler(config.Output, nil))
+	}
+
+	pathRates := compilePathOverrides(config.PathSampleRates)
+	slowThresholds := compilePathOverrides(config.RouteSlowThresholds)
+
+	var limiter *logBurstLimiter
+	if config.MaxPerSecond > 0 {
+		burst := config.Burst
+		if burst <= 0 {
+			burst = config.MaxPerSecond
+		}
+		limiter = &logBurstLimiter{
+			rate:   float64(config.MaxPerSecond),
+			burst:  float64(burst),
+			tokens: float64(burst),
+			last:   time.Now(),
+		}
+	}
+
 	return func(c *ginji.Context) error {
 		// Skip logging if path is in skip list
 		if skipPaths[c.Req.URL.Path] {
@@ -49,6 +153,7 @@ func LoggerWithConfig(config LoggerConfig) ginji.Middleware {
 			return c.Next()
 		}
 
+		rw := WrapResponseWriter(c)
 		start := time.Now()
 		path := c.Req.URL.Path
 		query := c.Req.URL.RawQuery
@@ -59,34 +164,69 @@ func LoggerWithConfig(config LoggerConfig) ginji.Middleware {
 		// Calculate latency
 		latency := time.Since(start)
 
-		// Determine which logger to use
-		logger := config.Logger
-		if logger == nil {
-			// Use engine's logger if available
-			if c.Req.Context().Value("engine") != nil {
-				if engine, ok := c.Req.Context().Value("engine").(*ginji.Engine); ok {
-					logger = engine.Logger
+		// Slow requests are always logged
+		threshold := lookupPathOverride(c, slowThresholds, config.SlowThreshold)
+		slow := threshold > 0 && latency > threshold
+		if slow && config.OnSlow != nil {
+			config.OnSlow(c, latency)
+		}
+
+		// Follow the request's sampling decision if there is one, otherwise
+		// sample successful responses and always keep errors
+		statusCode := c.StatusCode()
+		sampled := false
+		if state := samplingFrom(c); state != nil {
+			if !slow && !state.keep(c, err) {
+				return err
+			}
+			sampled = state.head && state.rate < 1
+		} else if statusCode < 400 && !slow {
+			rate := lookupPathOverride(c, pathRates, config.SampleRate)
+			if rate < 1 {
+				if rand.Float64() >= rate {
+					return err
 				}
+				sampled = true
+			}
+		}
+
+		var dropped int64
+		if limiter != nil {
+			var ok bool
+			if ok, dropped = limiter.allow(time.Now()); !ok {
+				return err
 			}
 		}
 
-		// Fallback to default slog if no logger configured
-		if logger == nil {
-			logger = slog.Default()
+		logger := requestLogger(c, config.Logger)
+
+		if config.Scrubber != nil {
+			path, query = config.Scrubber.Text(path), config.Scrubber.Query(query)
 		}
 
-		// Build log attributes
-		attrs := []slog.Attr{
+		// Build log attributes in a pooled slice
+		ap := loggerAttrPool.Get().(*[]slog.Attr)
+		attrs := append(*ap,
 			slog.Int("status", c.StatusCode()),
 			slog.String("method", c.Req.Method),
 			slog.String("path", path),
-			slog.String("ip", c.Req.RemoteAddr),
+			slog.String("route", RoutePattern(c)),
 			slog.Duration("latency", latency),
-			slog.String("user_agent", c.Header("User-Agent")),
-		}
+			slog.Int64("bytes", rw.Size()),
+		)
 
-		if query != "" {
-			attrs = append(attrs, slog.String("query", query))
+		// Leave out client details for clients that opted out of tracking
+		if privacy := privacyOptOut(c); privacy == nil || !privacy.MinimalLogs {
+			attrs = append(attrs,
+				slog.String("ip", c.Req.RemoteAddr),
+				slog.String("user_agent", c.Header("User-Agent")),
+			)
+			if query != "" {
+				attrs = append(attrs, slog.String("query", query))
+			}
+			if geo, ok := GetGeoInfo(c); ok && geo.Country != "" {
+				attrs = append(attrs, slog.String("country", geo.Country))
+			}
 		}
 
 		// Add error if present
@@ -94,8 +234,17 @@ func LoggerWithConfig(config LoggerConfig) ginji.Middleware {
 			attrs = append(attrs, slog.Bool("aborted", true))
 		}
 
+		if slow {
+			attrs = append(attrs, slog.Bool("slow", true))
+		}
+		if sampled {
+			attrs = append(attrs, slog.Bool("sampled", true))
+		}
+		if dropped > 0 {
+			attrs = append(attrs, slog.Int64("dropped", dropped))
+		}
+
 		// Log at appropriate level based on status code
-		statusCode := c.StatusCode()
 		level := slog.LevelInfo
 		message := "Request processed"
 
@@ -105,9 +254,38 @@ func LoggerWithConfig(config LoggerConfig) ginji.Middleware {
 		} else if statusCode >= 400 {
 			level = slog.LevelWarn
 			message = "Client error"
+		} else if slow {
+			level = slog.LevelWarn
+			message = "Slow request"
 		}
 
 		logger.LogAttrs(c.Req.Context(), level, message, attrs...)
+		clear(attrs)
+		*ap = attrs[:0]
+		loggerAttrPool.Put(ap)
 		return err
 	}
 }
+
+// loggerAttrPool holds attribute slices for LoggerWithConfig, sized for the
+// largest set of attributes it logs.
+var loggerAttrPool = sync.Pool{
+	New: func() any {
+		attrs := make([]slog.Attr, 0, 14)
+		return &attrs
+	},
+}
+
+// requestLogger returns logger, falling back to the engine's logger and
+// then to slog.Default.
+func requestLogger(c *ginji.Context, logger *slog.Logger) *slog.Logger {
+	if logger != nil {
+		return logger
+	}
+
+	// Use engine's logger if available
+	if engine, ok := c.Req.Context().Value("engine").(*ginji.Engine); ok && engine.Logger != nil {
+		return engine.Logger
+	}
+	return slog.Default()
+}
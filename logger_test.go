@@ -2,10 +2,12 @@ package middleware
 
 import (
 	"bytes"
+	"io"
 	"log/slog"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/ginjigo/ginji"
 )
@@ -153,3 +155,192 @@ func TestLoggerSkipFunc(t *testing.T) {
 		t.Error("Expected no log output when skip function returns true")
 	}
 }
+
+func TestLoggerSampling(t *testing.T) {
+	app := ginji.New()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	app.Use(LoggerWithConfig(LoggerConfig{
+		Logger:          logger,
+		SampleRate:      0.5,
+		PathSampleRates: map[string]float64{"/noisy/**": 0, "/noisy/important": 1},
+	}))
+
+	app.Get("/ok", func(c *ginji.Context) error {
+		return c.Text(200, "OK")
+	})
+	app.Get("/fail", func(c *ginji.Context) error {
+		return c.Text(500, "fail")
+	})
+	app.Get("/noisy/poll", func(c *ginji.Context) error {
+		return c.Text(200, "OK")
+	})
+	app.Get("/noisy/important", func(c *ginji.Context) error {
+		return c.Text(200, "OK")
+	})
+
+	for range 200 {
+		ginji.PerformRequest(app, "GET", "/ok", nil)
+	}
+	kept := strings.Count(buf.String(), "\n")
+	if kept == 0 || kept == 200 {
+		t.Errorf("Expected about half of 2xx entries to be logged, got %d of 200", kept)
+	}
+	if strings.Count(buf.String(), `"sampled":true`) != kept {
+		t.Error("Expected sampled entries to carry sampled=true")
+	}
+
+	buf.Reset()
+	for range 20 {
+		ginji.PerformRequest(app, "GET", "/fail", nil)
+	}
+	if got := strings.Count(buf.String(), "\n"); got != 20 {
+		t.Errorf("Expected every 5xx entry to be logged, got %d of 20", got)
+	}
+	if strings.Contains(buf.String(), "sampled") {
+		t.Error("Expected error entries not to be marked as sampled")
+	}
+
+	buf.Reset()
+	for range 20 {
+		ginji.PerformRequest(app, "GET", "/noisy/poll", nil)
+	}
+	if buf.Len() > 0 {
+		t.Error("Expected path override of 0 to drop successful entries")
+	}
+
+	ginji.PerformRequest(app, "GET", "/noisy/important", nil)
+	if !strings.Contains(buf.String(), "/noisy/important") || strings.Contains(buf.String(), "sampled") {
+		t.Errorf("Expected the longest matching override to log unsampled, got %s", buf.String())
+	}
+}
+
+func TestLoggerBurstLimit(t *testing.T) {
+	app := ginji.New()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	app.Use(LoggerWithConfig(LoggerConfig{
+		Logger:       logger,
+		MaxPerSecond: 1,
+		Burst:        3,
+	}))
+
+	app.Get("/fail", func(c *ginji.Context) error {
+		return c.Text(500, "fail")
+	})
+
+	for range 10 {
+		ginji.PerformRequest(app, "GET", "/fail", nil)
+	}
+	if got := strings.Count(buf.String(), "\n"); got != 3 {
+		t.Errorf("Expected burst of 3 entries, got %d", got)
+	}
+}
+
+func TestLogBurstLimiterReportsDropped(t *testing.T) {
+	now := time.Now()
+	l := &logBurstLimiter{rate: 1, burst: 1, tokens: 1, last: now}
+
+	if ok, _ := l.allow(now); !ok {
+		t.Fatal("Expected first entry to be allowed")
+	}
+	for range 4 {
+		if ok, _ := l.allow(now); ok {
+			t.Fatal("Expected entries over the limit to be dropped")
+		}
+	}
+
+	ok, dropped := l.allow(now.Add(time.Second))
+	if !ok || dropped != 4 {
+		t.Errorf("Expected refill with 4 dropped, got ok=%v dropped=%d", ok, dropped)
+	}
+}
+
+func TestLoggerSlowThreshold(t *testing.T) {
+	app := ginji.New()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	var alerted []string
+	app.Use(LoggerWithConfig(LoggerConfig{
+		Logger:              logger,
+		SampleRate:          0.0001,
+		SlowThreshold:       20 * time.Millisecond,
+		RouteSlowThresholds: map[string]time.Duration{"/export": time.Hour},
+		OnSlow: func(c *ginji.Context, latency time.Duration) {
+			alerted = append(alerted, c.Req.URL.Path)
+		},
+	}))
+
+	app.Get("/slow", func(c *ginji.Context) error {
+		time.Sleep(30 * time.Millisecond)
+		return c.Text(200, "OK")
+	})
+	app.Get("/export", func(c *ginji.Context) error {
+		time.Sleep(30 * time.Millisecond)
+		return c.Text(500, "fail")
+	})
+
+	ginji.PerformRequest(app, "GET", "/slow", nil)
+	out := buf.String()
+	if !strings.Contains(out, `"slow":true`) || !strings.Contains(out, `"level":"WARN"`) {
+		t.Errorf("Expected slow request to be logged at WARN with slow=true, got %s", out)
+	}
+	if strings.Contains(out, "sampled") {
+		t.Error("Expected slow requests to bypass sampling")
+	}
+
+	buf.Reset()
+	ginji.PerformRequest(app, "GET", "/export", nil)
+	if strings.Contains(buf.String(), "slow") {
+		t.Errorf("Expected route threshold to apply, got %s", buf.String())
+	}
+
+	if len(alerted) != 1 || alerted[0] != "/slow" {
+		t.Errorf("Expected one alert for /slow, got %v", alerted)
+	}
+}
+
+func TestLoggerRoute(t *testing.T) {
+	app := ginji.New()
+
+	var buf bytes.Buffer
+	app.Use(LoggerWithConfig(LoggerConfig{Logger: slog.New(slog.NewJSONHandler(&buf, nil))}))
+	app.Get("/users/:id", func(c *ginji.Context) error {
+		return c.Text(200, "OK")
+	})
+
+	ginji.PerformRequest(app, "GET", "/users/12345", nil)
+	out := buf.String()
+	if !strings.Contains(out, `"route":"/users/:id"`) || !strings.Contains(out, `"path":"/users/12345"`) {
+		t.Errorf("Expected route pattern and raw path, got %s", out)
+	}
+}
+
+func TestLoggerSkipAllocs(t *testing.T) {
+	req := httptest.NewRequest("GET", "/health", nil)
+	mw := LoggerWithConfig(LoggerConfig{
+		Logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+		SkipPaths: []string{"/health"},
+	})
+	if n := middlewareAllocs(t, req, mw); n > 0 {
+		t.Errorf("Expected no allocations for a skipped path, got %v", n)
+	}
+}
+
+func BenchmarkLogger(b *testing.B) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	req := httptest.NewRequest("GET", "/?page=2", nil)
+	benchmarkMiddleware(b, req, LoggerWithConfig(LoggerConfig{Logger: logger}))
+}
+
+func BenchmarkLoggerSkip(b *testing.B) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	req := httptest.NewRequest("GET", "/health", nil)
+	benchmarkMiddleware(b, req, LoggerWithConfig(LoggerConfig{Logger: logger, SkipPaths: []string{"/health"}}))
+}
@@ -0,0 +1,228 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// ErrLongPollTimeout is returned by Wait when no message arrives in time.
+var ErrLongPollTimeout = errors.New("long poll: no message before hold time expired")
+
+// LongPollConfig defines the configuration for long polling.
+type LongPollConfig struct {
+	// MaxHold is the maximum time a request is parked.
+	// Default: 30 seconds
+	MaxHold time.Duration
+
+	// DeadlineMargin is subtracted from the request deadline, e.g. one set
+	// by the Timeout middleware, so parked requests answer before it fires.
+	// Default: 100 milliseconds
+	DeadlineMargin time.Duration
+
+	// TopicFunc extracts the topic to wait on.
+	// Default: the ":topic" route parameter, falling back to the "topic" query parameter
+	TopicFunc func(*ginji.Context) string
+
+	// ContextKey is the key used to store the received message in context.
+	// Default: "longpoll"
+	ContextKey string
+
+	// SkipFunc allows skipping long polling for certain requests.
+	SkipFunc Skipper
+}
+
+// DefaultLongPollConfig returns default long poll configuration.
+func DefaultLongPollConfig() LongPollConfig {
+	return LongPollConfig{
+		MaxHold:        30 * time.Second,
+		DeadlineMargin: 100 * time.Millisecond,
+		TopicFunc:      defaultLongPollTopic,
+		ContextKey:     "longpoll",
+	}
+}
+
+// defaultLongPollTopic reads the topic from the route or query string.
+func defaultLongPollTopic(c *ginji.Context) string {
+	if topic := c.Param("topic"); topic != "" {
+		return topic
+	}
+	return c.Query("topic")
+}
+
+// LongPoll parks requests until a message is published on their topic.
+type LongPoll struct {
+	config  LongPollConfig
+	mu      sync.Mutex
+	waiters map[string]map[chan any]struct{}
+}
+
+// NewLongPoll creates a long poll hub with the given configuration.
+func NewLongPoll(config LongPollConfig) *LongPoll {
+	defaults := DefaultLongPollConfig()
+	if config.MaxHold == 0 {
+		config.MaxHold = defaults.MaxHold
+	}
+	if config.DeadlineMargin == 0 {
+		config.DeadlineMargin = defaults.DeadlineMargin
+	}
+	if config.TopicFunc == nil {
+		config.TopicFunc = defaults.TopicFunc
+	}
+	if config.ContextKey == "" {
+		config.ContextKey = defaults.ContextKey
+	}
+
+	return &LongPoll{
+		config:  config,
+		waiters: make(map[string]map[chan any]struct{}),
+	}
+}
+
+// Publish wakes every request waiting on topic with msg and returns the
+// number of requests woken.
+func (lp *LongPoll) Publish(topic string, msg any) int {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+
+	waiters := lp.waiters[topic]
+	for ch := range waiters {
+		// Channels are buffered and receive at most one message
+		ch <- msg
+	}
+	delete(lp.waiters, topic)
+	return len(waiters)
+}
+
+// Waiting returns the number of requests parked on topic.
+func (lp *LongPoll) Waiting(topic string) int {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	return len(lp.waiters[topic])
+}
+
+// Wait blocks until a message is published on topic, the hold time expires
+// or ctx is done. The hold time is shortened to end before ctx's deadline.
+func (lp *LongPoll) Wait(ctx context.Context, topic string) (any, error) {
+	hold := lp.config.MaxHold
+	if deadline, ok := ctx.Deadline(); ok {
+		if remaining := time.Until(deadline) - lp.config.DeadlineMargin; remaining < hold {
+			hold = remaining
+		}
+	}
+	if hold <= 0 {
+		return nil, ErrLongPollTimeout
+	}
+
+	ch := make(chan any, 1)
+	lp.mu.Lock()
+	if lp.waiters[topic] == nil {
+		lp.waiters[topic] = make(map[chan any]struct{})
+	}
+	lp.waiters[topic][ch] = struct{}{}
+	lp.mu.Unlock()
+
+	timer := time.NewTimer(hold)
+	defer timer.Stop()
+
+	select {
+	case msg := <-ch:
+		return msg, nil
+	case <-timer.C:
+		if msg, ok := lp.remove(topic, ch); ok {
+			return msg, nil
+		}
+		return nil, ErrLongPollTimeout
+	case <-ctx.Done():
+		if msg, ok := lp.remove(topic, ch); ok {
+			return msg, nil
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// remove unregisters a waiter that gave up. It returns the message if one
+// was published before the waiter was removed, which Publish counted as
+// delivered.
+func (lp *LongPoll) remove(topic string, ch chan any) (any, bool) {
+	lp.mu.Lock()
+	if waiters, ok := lp.waiters[topic]; ok {
+		delete(waiters, ch)
+		if len(waiters) == 0 {
+			delete(lp.waiters, topic)
+		}
+	}
+	lp.mu.Unlock()
+
+	select {
+	case msg := <-ch:
+		return msg, true
+	default:
+		return nil, false
+	}
+}
+
+// Middleware returns middleware that parks the request until a message is
+// published on its topic, stores the message in context and calls the next
+// handler. If the hold time expires it responds with 204 No Content; if the
+// client disconnects the chain is aborted without a response.
+func (lp *LongPoll) Middleware() ginji.Middleware {
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if lp.config.SkipFunc != nil && lp.config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		topic := lp.config.TopicFunc(c)
+		if topic == "" {
+			c.AbortWithStatusJSON(http.StatusBadRequest, ginji.H{
+				"error": "Missing topic",
+			})
+			return nil
+		}
+
+		msg, err := lp.Wait(c.Req.Context(), topic)
+		switch {
+		case errors.Is(err, ErrLongPollTimeout):
+			c.Res.WriteHeader(http.StatusNoContent)
+			c.Abort()
+			return nil
+		case err != nil:
+			c.Abort()
+			return nil
+		}
+
+		c.Set(lp.config.ContextKey, msg)
+		setContextValue(c, longPollContextKey, msg)
+		return c.Next()
+	}
+}
+
+// Handler is a ginji handler that waits for a message and responds with it
+// as JSON, or with 204 No Content if none arrives in time.
+func (lp *LongPoll) Handler(c *ginji.Context) error {
+	topic := lp.config.TopicFunc(c)
+	if topic == "" {
+		return c.JSON(http.StatusBadRequest, ginji.H{"error": "Missing topic"})
+	}
+
+	msg, err := lp.Wait(c.Req.Context(), topic)
+	switch {
+	case errors.Is(err, ErrLongPollTimeout):
+		c.Res.WriteHeader(http.StatusNoContent)
+		return nil
+	case err != nil:
+		return nil
+	}
+	return c.JSON(http.StatusOK, msg)
+}
+
+// GetLongPollMessage returns the message stored by the LongPoll middleware.
+func GetLongPollMessage(c *ginji.Context) (any, bool) {
+	msg := c.Req.Context().Value(longPollContextKey)
+	return msg, msg != nil
+}
@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+func waitForPollers(t *testing.T, lp *LongPoll, topic string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for lp.Waiting(topic) != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d waiters on %s, got %d", n, topic, lp.Waiting(topic))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLongPollPublishWakesWaiters(t *testing.T) {
+	lp := NewLongPoll(DefaultLongPollConfig())
+
+	app := ginji.New()
+	app.Get("/poll/:topic", lp.Handler)
+
+	results := make(chan *httptest.ResponseRecorder, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			results <- ginji.PerformRequest(app, "GET", "/poll/orders", nil)
+		}()
+	}
+
+	waitForPollers(t, lp, "orders", 2)
+	if woken := lp.Publish("orders", ginji.H{"id": 42}); woken != 2 {
+		t.Errorf("Expected 2 waiters woken, got %d", woken)
+	}
+
+	for i := 0; i < 2; i++ {
+		w := <-results
+		ginji.AssertStatus(t, w, ginji.StatusOK)
+		ginji.AssertBody(t, w, `"id":42`)
+	}
+}
+
+func TestLongPollMessageRacingCancel(t *testing.T) {
+	lp := NewLongPoll(DefaultLongPollConfig())
+	ctx, cancel := context.WithCancel(context.Background())
+
+	type result struct {
+		msg any
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		msg, err := lp.Wait(ctx, "orders")
+		done <- result{msg, err}
+	}()
+	waitForPollers(t, lp, "orders", 1)
+
+	// Deliver the message as Publish does, after the waiter gave up but
+	// before it could unregister
+	lp.mu.Lock()
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	for ch := range lp.waiters["orders"] {
+		ch <- 42
+	}
+	delete(lp.waiters, "orders")
+	lp.mu.Unlock()
+
+	if r := <-done; r.err != nil || r.msg != 42 {
+		t.Errorf("Expected message 42 to be delivered, got %v, %v", r.msg, r.err)
+	}
+}
+
+func TestLongPollHoldExpires(t *testing.T) {
+	lp := NewLongPoll(LongPollConfig{MaxHold: 20 * time.Millisecond})
+
+	app := ginji.New()
+	app.Get("/poll/:topic", lp.Handler)
+
+	w := ginji.PerformRequest(app, "GET", "/poll/orders", nil)
+	ginji.AssertStatus(t, w, ginji.StatusNoContent)
+	if lp.Waiting("orders") != 0 {
+		t.Error("Expected expired waiter to be removed")
+	}
+}
+
+func TestLongPollRespectsTimeoutMiddleware(t *testing.T) {
+	lp := NewLongPoll(LongPollConfig{MaxHold: 10 * time.Second})
+
+	app := ginji.New()
+	app.Use(Timeout(200 * time.Millisecond))
+	app.Use(lp.Middleware())
+	app.Get("/poll/:topic", func(c *ginji.Context) error {
+		msg, _ := GetLongPollMessage(c)
+		return c.JSON(ginji.StatusOK, msg)
+	})
+
+	start := time.Now()
+	w := ginji.PerformRequest(app, "GET", "/poll/orders", nil)
+
+	// The poll ends just before the timeout instead of producing a 504
+	ginji.AssertStatus(t, w, ginji.StatusNoContent)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected poll to end near the timeout, took %v", elapsed)
+	}
+}
+
+func TestLongPollClientDisconnect(t *testing.T) {
+	lp := NewLongPoll(DefaultLongPollConfig())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := lp.Wait(ctx, "orders")
+		done <- err
+	}()
+
+	waitForPollers(t, lp, "orders", 1)
+	cancel()
+
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if lp.Waiting("orders") != 0 {
+		t.Error("Expected disconnected waiter to be removed")
+	}
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRUCacheEviction(t *testing.T) {
+	cache := newLRUCache[string, int](2, 0)
+	cache.Add("a", 1)
+	cache.Add("b", 2)
+	cache.Get("a")
+	cache.Add("c", 3)
+
+	if _, ok := cache.Get("b"); ok {
+		t.Error("Expected least recently used entry to be evicted")
+	}
+	if v, ok := cache.Get("a"); !ok || v != 1 {
+		t.Errorf("Expected a=1, got %d, %v", v, ok)
+	}
+	if cache.Len() != 2 {
+		t.Errorf("Expected 2 entries, got %d", cache.Len())
+	}
+}
+
+func TestLRUCacheTTL(t *testing.T) {
+	cache := newLRUCache[string, int](10, time.Millisecond)
+	cache.Add("a", 1)
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok := cache.Get("a"); ok {
+		t.Error("Expected expired entry to be dropped")
+	}
+}
+
+func TestLRUCacheRemove(t *testing.T) {
+	cache := newLRUCache[string, int](10, 0)
+	cache.Add("a", 1)
+	cache.Remove("a")
+	cache.Remove("missing")
+
+	if _, ok := cache.Get("a"); ok {
+		t.Error("Expected removed entry to be gone")
+	}
+	if cache.Len() != 0 {
+		t.Errorf("Expected 0 entries, got %d", cache.Len())
+	}
+}
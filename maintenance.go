@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// MaintenanceConfig defines the configuration for maintenance mode middleware.
+type MaintenanceConfig struct {
+	// Enabled turns maintenance mode on.
+	Enabled bool
+
+	// Message is returned while maintenance mode is on.
+	// Default: "Service is under maintenance"
+	Message string
+
+	// StatusCode is the HTTP status code returned while maintenance mode is on.
+	// Default: 503 Service Unavailable
+	StatusCode int
+
+	// RetryAfter sets the Retry-After header on maintenance responses.
+	// Default: 0 (not set)
+	RetryAfter time.Duration
+
+	// AllowIPs lists IP addresses or CIDR ranges, such as operators' networks,
+	// that bypass maintenance mode.
+	AllowIPs []string
+
+	// TrustedProxies is a list of trusted proxy IP addresses used when
+	// resolving the client IP.
+	TrustedProxies []string
+
+	// SkipFunc allows requests, such as health probes, to bypass maintenance
+	// mode. SkipHealthEndpoints is a common choice.
+	SkipFunc Skipper
+}
+
+// DefaultMaintenanceConfig returns default maintenance configuration.
+func DefaultMaintenanceConfig() MaintenanceConfig {
+	return MaintenanceConfig{
+		Message:    "Service is under maintenance",
+		StatusCode: http.StatusServiceUnavailable,
+	}
+}
+
+// Maintenance returns middleware that answers every request with 503.
+// It is most useful through MaintenanceDynamic, which toggles the mode at
+// runtime.
+func Maintenance() ginji.Middleware {
+	config := DefaultMaintenanceConfig()
+	config.Enabled = true
+	return MaintenanceWithConfig(config)
+}
+
+// MaintenanceWithConfig returns maintenance middleware with custom configuration.
+func MaintenanceWithConfig(config MaintenanceConfig) ginji.Middleware {
+	config = normalizeMaintenanceConfig(config)
+	allow, err := parseIPNets(config.AllowIPs)
+	if err != nil {
+		panic("Maintenance: " + err.Error())
+	}
+
+	return func(c *ginji.Context) error {
+		return serveMaintenance(c, config, allow)
+	}
+}
+
+// MaintenanceDynamic returns maintenance middleware that reads its
+// configuration from configFunc on every request, so maintenance mode can be
+// switched on and off at runtime. configFunc must be safe for concurrent use;
+// a Reloader's Load method is.
+//
+//	maintenance := middleware.NewReloader(middleware.DefaultMaintenanceConfig())
+//	app.Use(middleware.MaintenanceDynamic(maintenance.Load))
+func MaintenanceDynamic(configFunc func() MaintenanceConfig) ginji.Middleware {
+	return func(c *ginji.Context) error {
+		config := configFunc()
+		if !config.Enabled {
+			return c.Next()
+		}
+		config = normalizeMaintenanceConfig(config)
+		allow, _ := parseIPNets(config.AllowIPs)
+		return serveMaintenance(c, config, allow)
+	}
+}
+
+// normalizeMaintenanceConfig fills in defaults for unset fields.
+func normalizeMaintenanceConfig(config MaintenanceConfig) MaintenanceConfig {
+	defaults := DefaultMaintenanceConfig()
+	if config.Message == "" {
+		config.Message = defaults.Message
+	}
+	if config.StatusCode == 0 {
+		config.StatusCode = defaults.StatusCode
+	}
+	return config
+}
+
+// serveMaintenance answers the request with the maintenance response unless
+// maintenance mode is off or the request is exempt.
+func serveMaintenance(c *ginji.Context, config MaintenanceConfig, allow []*net.IPNet) error {
+	if !config.Enabled {
+		return c.Next()
+	}
+
+	// Skip if skip function returns true
+	if config.SkipFunc != nil && config.SkipFunc(c) {
+		return c.Next()
+	}
+
+	if len(allow) > 0 {
+		if ip := net.ParseIP(clientIP(c.Req, config.TrustedProxies)); ip != nil && ipInNets(ip, allow) {
+			return c.Next()
+		}
+	}
+
+	if config.RetryAfter > 0 {
+		c.SetHeader("Retry-After", strconv.Itoa(int(config.RetryAfter.Seconds())))
+	}
+	c.AbortWithStatusJSON(config.StatusCode, ginji.H{
+		"error": config.Message,
+	})
+	return nil
+}
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+func TestMaintenance(t *testing.T) {
+	config := DefaultMaintenanceConfig()
+	config.Enabled = true
+	config.RetryAfter = 2 * time.Minute
+	config.SkipFunc = SkipHealthEndpoints()
+	app := ginji.New()
+	app.Use(MaintenanceWithConfig(config))
+	handler := func(c *ginji.Context) error { return c.Text(ginji.StatusOK, "ok") }
+	app.Get("/", handler)
+	app.Get("/healthz", handler)
+
+	w := ginji.PerformRequest(app, "GET", "/", nil)
+	ginji.AssertStatus(t, w, ginji.StatusServiceUnavailable)
+	ginji.AssertHeader(t, w, "Retry-After", "120")
+	ginji.AssertBody(t, w, "under maintenance")
+
+	w = ginji.PerformRequest(app, "GET", "/healthz", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+}
+
+func TestMaintenanceAllowIPs(t *testing.T) {
+	config := DefaultMaintenanceConfig()
+	config.Enabled = true
+	config.AllowIPs = []string{"192.0.2.0/24"}
+	app := ginji.New()
+	app.Use(MaintenanceWithConfig(config))
+	handler := func(c *ginji.Context) error { return c.Text(ginji.StatusOK, "ok") }
+	app.Get("/", handler)
+	app.Get("/healthz", handler)
+
+	w := ginji.PerformRequest(app, "GET", "/", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+}
+
+func TestMaintenanceDynamic(t *testing.T) {
+	mode := NewReloader(DefaultMaintenanceConfig())
+	app := ginji.New()
+	app.Use(MaintenanceDynamic(mode.Load))
+	handler := func(c *ginji.Context) error { return c.Text(ginji.StatusOK, "ok") }
+	app.Get("/", handler)
+	app.Get("/healthz", handler)
+
+	w := ginji.PerformRequest(app, "GET", "/", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+
+	mode.Update(func(c MaintenanceConfig) MaintenanceConfig {
+		c.Enabled = true
+		return c
+	})
+	w = ginji.PerformRequest(app, "GET", "/", nil)
+	ginji.AssertStatus(t, w, ginji.StatusServiceUnavailable)
+
+	mode.Update(func(c MaintenanceConfig) MaintenanceConfig {
+		c.Enabled = false
+		return c
+	})
+	w = ginji.PerformRequest(app, "GET", "/", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+}
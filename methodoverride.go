@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/ginjigo/ginji"
+)
+
+// MethodOverrideConfig defines the configuration for method override.
+type MethodOverrideConfig struct {
+	// Header is the header carrying the override method.
+	// Default: "X-HTTP-Method-Override"
+	Header string
+
+	// FormField is the form or query field carrying the override method.
+	// Default: "_method"
+	FormField string
+
+	// AllowedMethods is the list of methods a POST request may be overridden to.
+	// Default: PUT, PATCH, DELETE
+	AllowedMethods []string
+
+	// ContextKey is the key used to store the original method in context.
+	// Default: "original_method"
+	ContextKey string
+
+	// SkipFunc allows skipping method override for certain requests.
+	SkipFunc Skipper
+}
+
+// DefaultMethodOverrideConfig returns default method override configuration.
+func DefaultMethodOverrideConfig() MethodOverrideConfig {
+	return MethodOverrideConfig{
+		Header:         "X-HTTP-Method-Override",
+		FormField:      "_method",
+		AllowedMethods: []string{http.MethodPut, http.MethodPatch, http.MethodDelete},
+		ContextKey:     "original_method",
+	}
+}
+
+// MethodOverride returns a hook that lets HTML forms and restricted clients
+// tunnel PUT, PATCH and DELETE requests through POST.
+//
+// The method has to be rewritten before the router matches the request,
+// so the hook is registered with OnRequest rather than Use:
+//
+//	app.OnRequest(middleware.MethodOverride())
+func MethodOverride() ginji.HookFunc {
+	return MethodOverrideWithConfig(DefaultMethodOverrideConfig())
+}
+
+// MethodOverrideWithConfig returns a method override hook with custom configuration.
+func MethodOverrideWithConfig(config MethodOverrideConfig) ginji.HookFunc {
+	// Set defaults
+	if config.Header == "" {
+		config.Header = "X-HTTP-Method-Override"
+	}
+	if config.FormField == "" {
+		config.FormField = "_method"
+	}
+	if len(config.AllowedMethods) == 0 {
+		config.AllowedMethods = DefaultMethodOverrideConfig().AllowedMethods
+	}
+	if config.ContextKey == "" {
+		config.ContextKey = "original_method"
+	}
+
+	allowed := make(map[string]bool, len(config.AllowedMethods))
+	for _, method := range config.AllowedMethods {
+		allowed[strings.ToUpper(method)] = true
+	}
+
+	return func(c *ginji.Context) {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return
+		}
+
+		// Only POST requests may be overridden
+		if c.Req.Method != http.MethodPost {
+			return
+		}
+
+		method := c.Header(config.Header)
+		if method == "" {
+			method = overrideFromForm(c, config.FormField)
+		}
+		method = strings.ToUpper(strings.TrimSpace(method))
+
+		if method == "" || !allowed[method] {
+			return
+		}
+
+		c.Set(config.ContextKey, c.Req.Method)
+		setContextValue(c, originalMethodContextKey, c.Req.Method)
+		c.Req.Method = method
+	}
+}
+
+// overrideFromForm reads the override field from the query string or a form body.
+func overrideFromForm(c *ginji.Context, field string) string {
+	if method := c.Query(field); method != "" {
+		return method
+	}
+
+	// Avoid consuming non-form bodies such as JSON payloads
+	if isFormRequest(c) {
+		return c.Req.PostFormValue(field)
+	}
+
+	return ""
+}
+
+// isFormRequest reports whether the request carries a form-encoded body.
+func isFormRequest(c *ginji.Context) bool {
+	contentType := c.Header("Content-Type")
+	return strings.HasPrefix(contentType, "application/x-www-form-urlencoded") ||
+		strings.HasPrefix(contentType, "multipart/form-data")
+}
+
+// OriginalMethod is a helper to get the method a request was sent with
+// before it was overridden.
+func OriginalMethod(c *ginji.Context) string {
+	if method, _ := c.Req.Context().Value(originalMethodContextKey).(string); method != "" {
+		return method
+	}
+	return c.Req.Method
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ginjigo/ginji"
+)
+
+func TestMethodOverrideHeader(t *testing.T) {
+	app := ginji.New()
+	app.OnRequest(MethodOverride())
+
+	app.Delete("/items/:id", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "deleted from "+OriginalMethod(c))
+	})
+
+	req := httptest.NewRequest("POST", "/items/1", nil)
+	req.Header.Set("X-HTTP-Method-Override", "DELETE")
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+
+	if w.Code != ginji.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	ginji.AssertBody(t, w, "deleted from POST")
+}
+
+func TestMethodOverrideFormField(t *testing.T) {
+	app := ginji.New()
+	app.OnRequest(MethodOverride())
+
+	app.Put("/items/:id", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, c.FormValue("name"))
+	})
+
+	form := url.Values{"_method": {"put"}, "name": {"widget"}}
+	req := httptest.NewRequest("POST", "/items/1", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+
+	if w.Code != ginji.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	ginji.AssertBody(t, w, "widget")
+}
+
+func TestMethodOverrideOnlyFromPost(t *testing.T) {
+	app := ginji.New()
+	app.OnRequest(MethodOverride())
+
+	app.Get("/items", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, c.Req.Method)
+	})
+
+	req := httptest.NewRequest("GET", "/items?_method=DELETE", nil)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+
+	ginji.AssertBody(t, w, "GET")
+}
+
+func TestMethodOverrideDisallowedMethod(t *testing.T) {
+	app := ginji.New()
+	app.OnRequest(MethodOverrideWithConfig(MethodOverrideConfig{
+		AllowedMethods: []string{"DELETE"},
+	}))
+
+	app.Post("/items", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, c.Req.Method)
+	})
+
+	req := httptest.NewRequest("POST", "/items", nil)
+	req.Header.Set("X-HTTP-Method-Override", "PATCH")
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+
+	ginji.AssertBody(t, w, "POST")
+}
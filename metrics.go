@@ -0,0 +1,409 @@
+package middleware
+
+import (
+	"bytes"
+	"cmp"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// MetricsConfig defines the configuration for request metrics.
+type MetricsConfig struct {
+	// Namespace prefixes every metric name.
+	// Default: "http"
+	Namespace string
+
+	// Buckets are the upper bounds, in seconds, of the request duration
+	// histogram.
+	// Default: 5ms to 10s
+	Buckets []float64
+
+	// SLOs are latency objectives keyed by a path glob pattern (see
+	// PathGlob). The longest matching pattern wins.
+	SLOs map[string]SLOTarget
+
+	// ApdexTarget is the Apdex satisfied threshold T. Requests up to T are
+	// satisfied, up to 4T tolerating, and slower requests or 5xx responses
+	// frustrated.
+	// Default: 0 (Apdex disabled)
+	ApdexTarget time.Duration
+
+	// IncludeRawPath adds the raw request path as a "path" label next to
+	// the "route" pattern label. Beware that it makes the number of series
+	// unbounded.
+	IncludeRawPath bool
+
+	// Sinks receive every metric update as it happens, e.g. StatsDSink.
+	// The Prometheus exposition from Handler works regardless.
+	Sinks []MetricsSink
+
+	// SkipFunc allows skipping metrics for certain requests.
+	SkipFunc Skipper
+}
+
+// MetricsSink receives metric updates from Metrics. Names include the
+// namespace and labels are alternating names and values. Implementations
+// must be safe for concurrent use and should not block.
+type MetricsSink interface {
+	// Count reports a counter increment.
+	Count(name string, delta float64, labels []string)
+
+	// Gauge reports the new value of a gauge.
+	Gauge(name string, value float64, labels []string)
+
+	// Histogram reports a single observation.
+	Histogram(name string, value float64, labels []string)
+}
+
+// DefaultMetricsConfig returns default metrics configuration.
+func DefaultMetricsConfig() MetricsConfig {
+	return MetricsConfig{
+		Namespace: "http",
+		Buckets:   []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}
+}
+
+// Metric types accepted by Metrics.Describe.
+const (
+	MetricCounter   = "counter"
+	MetricGauge     = "gauge"
+	MetricHistogram = "histogram"
+)
+
+// metricFamily holds all series of one metric name.
+type metricFamily struct {
+	name    string
+	help    string
+	typ     string
+	buckets []float64
+	series  map[string]*metricSeries
+}
+
+// metricSeries is one labelled time series.
+type metricSeries struct {
+	labels  []string // alternating names and values
+	value   float64
+	count   uint64
+	buckets []uint64
+}
+
+// Metrics collects request metrics and serves them in the Prometheus
+// text exposition format. Other middleware record their own metrics into
+// it with Inc, Set and Observe.
+//
+//	metrics := middleware.NewMetrics(middleware.DefaultMetricsConfig())
+//	app.Use(metrics.Middleware())
+//	app.Get("/metrics", metrics.Handler())
+type Metrics struct {
+	config   MetricsConfig
+	slos     []pathOverride[SLOTarget]
+	mu       sync.Mutex
+	families map[string]*metricFamily
+}
+
+// NewMetrics creates a metrics collector with the given configuration.
+func NewMetrics(config MetricsConfig) *Metrics {
+	defaults := DefaultMetricsConfig()
+	if config.Namespace == "" {
+		config.Namespace = defaults.Namespace
+	}
+	if len(config.Buckets) == 0 {
+		config.Buckets = defaults.Buckets
+	}
+	config.Buckets = append([]float64(nil), config.Buckets...)
+	sort.Float64s(config.Buckets)
+
+	m := &Metrics{
+		config:   config,
+		slos:     compilePathOverrides(config.SLOs),
+		families: make(map[string]*metricFamily),
+	}
+	m.describe("requests_total", MetricCounter, "Total number of HTTP requests.")
+	m.describe("requests_in_flight", MetricGauge, "Number of HTTP requests being served.")
+	m.describe("request_duration_seconds", MetricHistogram, "HTTP request latency in seconds.")
+	m.describeSLO()
+	return m
+}
+
+// Middleware returns middleware that records request count, latency and
+// in-flight requests.
+func (m *Metrics) Middleware() ginji.Middleware {
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if m.config.SkipFunc != nil && m.config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		m.Add("requests_in_flight", 1)
+		start := time.Now()
+
+		err := c.Next()
+
+		latency := time.Since(start)
+		m.Add("requests_in_flight", -1)
+
+		status := c.StatusCode()
+		route := RoutePattern(c)
+		labels := []string{"method", c.Req.Method, "route", route}
+		if m.config.IncludeRawPath {
+			labels = append(labels, "path", c.Req.URL.Path)
+		}
+		m.Inc("requests_total", append(labels, "status", strconv.Itoa(status))...)
+		m.Observe("request_duration_seconds", latency.Seconds(), labels...)
+		m.observeSLO(c, route, status, latency)
+		return err
+	}
+}
+
+// Handler returns a handler serving the metrics in the Prometheus text format.
+func (m *Metrics) Handler() ginji.Handler {
+	return func(c *ginji.Context) error {
+		var buf bytes.Buffer
+		m.WriteTo(&buf)
+		c.SetHeader("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		c.Res.WriteHeader(http.StatusOK)
+		_, err := c.Res.Write(buf.Bytes())
+		return err
+	}
+}
+
+// Describe registers the type and help text of a metric. Metrics recorded
+// without being described are exported as untyped.
+func (m *Metrics) Describe(name, typ, help string) {
+	m.describe(name, typ, help)
+}
+
+// Inc adds one to a counter. Labels are alternating names and values.
+func (m *Metrics) Inc(name string, labels ...string) {
+	m.Add(name, 1, labels...)
+}
+
+// Add adds delta to a counter or gauge.
+func (m *Metrics) Add(name string, delta float64, labels ...string) {
+	m.mu.Lock()
+	s := m.seriesLocked(name, labels)
+	s.value += delta
+	value, typ := s.value, m.familyLocked(name).typ
+	m.mu.Unlock()
+
+	for _, sink := range m.config.Sinks {
+		if typ == MetricGauge {
+			sink.Gauge(m.fullName(name), value, labels)
+		} else {
+			sink.Count(m.fullName(name), delta, labels)
+		}
+	}
+}
+
+// Set sets a gauge.
+func (m *Metrics) Set(name string, value float64, labels ...string) {
+	m.mu.Lock()
+	m.seriesLocked(name, labels).value = value
+	m.mu.Unlock()
+
+	for _, sink := range m.config.Sinks {
+		sink.Gauge(m.fullName(name), value, labels)
+	}
+}
+
+// Observe records a histogram observation.
+func (m *Metrics) Observe(name string, value float64, labels ...string) {
+	// Notify sinks after the lock is released
+	defer func() {
+		for _, sink := range m.config.Sinks {
+			sink.Histogram(m.fullName(name), value, labels)
+		}
+	}()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	family := m.familyLocked(name)
+	if family.typ != MetricHistogram {
+		family.typ = MetricHistogram
+		family.buckets = m.config.Buckets
+	}
+	s := m.seriesLocked(name, labels)
+	if s.buckets == nil {
+		s.buckets = make([]uint64, len(family.buckets))
+	}
+	for i, upper := range family.buckets {
+		if value <= upper {
+			s.buckets[i]++
+		}
+	}
+	s.count++
+	s.value += value
+}
+
+// Value returns the current value of a counter or gauge series.
+func (m *Metrics) Value(name string, labels ...string) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	family, ok := m.families[m.fullName(name)]
+	if !ok {
+		return 0
+	}
+	if s, ok := family.series[seriesKey(labels)]; ok {
+		return s.value
+	}
+	return 0
+}
+
+// WriteTo writes all metrics in the Prometheus text exposition format.
+func (m *Metrics) WriteTo(buf *bytes.Buffer) {
+	m.refreshSLOGauges()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.families))
+	for name := range m.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		family := m.families[name]
+		if len(family.series) == 0 {
+			continue
+		}
+		if family.help != "" {
+			buf.WriteString("# HELP " + name + " " + family.help + "\n")
+		}
+		buf.WriteString("# TYPE " + name + " " + cmp.Or(family.typ, "untyped") + "\n")
+
+		keys := make([]string, 0, len(family.series))
+		for key := range family.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			s := family.series[key]
+			if family.typ != MetricHistogram {
+				writeSample(buf, name, s.labels, s.value)
+				continue
+			}
+			for i, upper := range family.buckets {
+				writeSample(buf, name+"_bucket", append(s.labels[:len(s.labels):len(s.labels)], "le", formatFloat(upper)), float64(s.buckets[i]))
+			}
+			writeSample(buf, name+"_bucket", append(s.labels[:len(s.labels):len(s.labels)], "le", "+Inf"), float64(s.count))
+			writeSample(buf, name+"_sum", s.labels, s.value)
+			writeSample(buf, name+"_count", s.labels, float64(s.count))
+		}
+	}
+}
+
+// describe registers a metric family under the namespace.
+func (m *Metrics) describe(name, typ, help string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	family := m.familyLocked(name)
+	family.typ = typ
+	family.help = help
+	if typ == MetricHistogram {
+		family.buckets = m.config.Buckets
+	}
+}
+
+// fullName prefixes name with the namespace.
+func (m *Metrics) fullName(name string) string {
+	return m.config.Namespace + "_" + name
+}
+
+// familyLocked returns the family for name, creating it if needed.
+func (m *Metrics) familyLocked(name string) *metricFamily {
+	full := m.fullName(name)
+	family, ok := m.families[full]
+	if !ok {
+		family = &metricFamily{name: full, series: make(map[string]*metricSeries)}
+		m.families[full] = family
+	}
+	return family
+}
+
+// seriesLocked returns the series for name and labels, creating it if needed.
+func (m *Metrics) seriesLocked(name string, labels []string) *metricSeries {
+	family := m.familyLocked(name)
+	key := seriesKey(labels)
+	s, ok := family.series[key]
+	if !ok {
+		s = &metricSeries{labels: append([]string(nil), labels...)}
+		family.series[key] = s
+	}
+	return s
+}
+
+// seriesKey encodes labels as a map key.
+func seriesKey(labels []string) string {
+	return strings.Join(labels, "\xff")
+}
+
+// writeSample writes one sample line.
+func writeSample(buf *bytes.Buffer, name string, labels []string, value float64) {
+	buf.WriteString(name)
+	if len(labels) > 0 {
+		buf.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(labels[i] + `="` + escapeLabelValue(labels[i+1]) + `"`)
+		}
+		buf.WriteByte('}')
+	}
+	buf.WriteString(" " + formatFloat(value) + "\n")
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeLabelValue escapes backslashes, quotes and newlines.
+func escapeLabelValue(v string) string {
+	return labelValueEscaper.Replace(v)
+}
+
+// formatFloat formats a sample value.
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// snapshot returns a copy of all non-empty metric families.
+func (m *Metrics) snapshot() []metricFamily {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	families := make([]metricFamily, 0, len(m.families))
+	for _, family := range m.families {
+		if len(family.series) == 0 {
+			continue
+		}
+		copied := *family
+		copied.series = make(map[string]*metricSeries, len(family.series))
+		for key, s := range family.series {
+			series := *s
+			series.buckets = append([]uint64(nil), s.buckets...)
+			copied.series[key] = &series
+		}
+		families = append(families, copied)
+	}
+	sort.Slice(families, func(i, j int) bool { return families[i].name < families[j].name })
+	return families
+}
@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ginjigo/ginji"
+)
+
+func TestMetricsExposition(t *testing.T) {
+	m := NewMetrics(DefaultMetricsConfig())
+	app := ginji.New()
+	app.Use(m.Middleware())
+	app.Get("/metrics", m.Handler())
+	app.Get("/users", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "users")
+	})
+	app.Get("/fail", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusInternalServerError, "fail")
+	})
+
+	ginji.PerformRequest(app, "GET", "/users", nil)
+	ginji.PerformRequest(app, "GET", "/users", nil)
+	ginji.PerformRequest(app, "GET", "/fail", nil)
+
+	w := ginji.PerformRequest(app, "GET", "/metrics", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	ginji.AssertHeader(t, w, "Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	body := w.Body.String()
+	for _, want := range []string{
+		"# TYPE http_requests_total counter",
+		`http_requests_total{method="GET",route="/users",status="200"} 2`,
+		`http_requests_total{method="GET",route="/fail",status="500"} 1`,
+		"# TYPE http_request_duration_seconds histogram",
+		`http_request_duration_seconds_bucket{method="GET",route="/users",le="+Inf"} 2`,
+		`http_request_duration_seconds_count{method="GET",route="/users"} 2`,
+		`http_requests_in_flight 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected exposition to contain %q, got:\n%s", want, body)
+		}
+	}
+}
+
+func TestMetricsCustomSeries(t *testing.T) {
+	m := NewMetrics(MetricsConfig{Namespace: "app", Buckets: []float64{1, 0.1}})
+	m.Describe("jobs_total", MetricCounter, "Jobs processed.")
+	m.Inc("jobs_total", "queue", "a\"b")
+	m.Add("jobs_total", 2, "queue", "a\"b")
+	m.Set("workers", 4)
+	m.Observe("job_seconds", 0.5)
+
+	if got := m.Value("jobs_total", "queue", "a\"b"); got != 3 {
+		t.Errorf("Expected counter value 3, got %v", got)
+	}
+
+	var buf bytes.Buffer
+	m.WriteTo(&buf)
+	body := buf.String()
+	for _, want := range []string{
+		"# HELP app_jobs_total Jobs processed.",
+		`app_jobs_total{queue="a\"b"} 3`,
+		"# TYPE app_workers untyped",
+		"app_workers 4",
+		`app_job_seconds_bucket{le="0.1"} 0`,
+		`app_job_seconds_bucket{le="1"} 1`,
+		"app_job_seconds_sum 0.5",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected exposition to contain %q, got:\n%s", want, body)
+		}
+	}
+}
+
+func TestMetricsSkipFunc(t *testing.T) {
+	m := NewMetrics(MetricsConfig{SkipFunc: SkipPaths("/metrics")})
+	app := ginji.New()
+	app.Use(m.Middleware())
+	app.Get("/metrics", m.Handler())
+
+	ginji.PerformRequest(app, "GET", "/metrics", nil)
+	if got := m.Value("requests_total", "method", "GET", "route", "/metrics", "status", "200"); got != 0 {
+		t.Errorf("Expected skipped request not to be counted, got %v", got)
+	}
+}
+
+func TestMetricsRoutePattern(t *testing.T) {
+	m := NewMetrics(MetricsConfig{IncludeRawPath: true})
+	app := ginji.New()
+	app.Use(m.Middleware())
+	app.Get("/metrics", m.Handler())
+	app.Get("/users", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "users")
+	})
+	app.Get("/users/:id", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, c.Param("id"))
+	})
+
+	ginji.PerformRequest(app, "GET", "/users/1", nil)
+	ginji.PerformRequest(app, "GET", "/users/2", nil)
+	ginji.PerformRequest(app, "GET", "/no/such/page", nil)
+
+	var buf bytes.Buffer
+	m.WriteTo(&buf)
+	body := buf.String()
+	for _, want := range []string{
+		`http_requests_total{method="GET",route="/users/:id",path="/users/1",status="200"} 1`,
+		`http_requests_total{method="GET",route="/users/:id",path="/users/2",status="200"} 1`,
+		`http_requests_total{method="GET",route="<unmatched>",path="/no/such/page",status="404"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected exposition to contain %q, got:\n%s", want, body)
+		}
+	}
+}
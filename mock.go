@@ -0,0 +1,330 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	mathrand "math/rand/v2"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// MockFixture is a canned response for a route.
+//
+// String values in Body (and a string Body itself) are Go templates that
+// can echo the request and generate random data:
+//
+//	{{.Method}} {{.Path}} {{.Param "id"}} {{.Query "q"}} {{.Header "X-Name"}} {{.Body}}
+//	{{uuid}} {{randInt 1 100}} {{randString 8}} {{randChoice "a" "b"}} {{now}}
+type MockFixture struct {
+	// Method to match. Empty matches any method.
+	Method string `json:"method" yaml:"method"`
+
+	// Path pattern to match, supporting ":name" parameters and a trailing "*".
+	Path string `json:"path" yaml:"path"`
+
+	// Status code of the response.
+	// Default: 200
+	Status int `json:"status" yaml:"status"`
+
+	// Headers added to the response.
+	Headers map[string]string `json:"headers" yaml:"headers"`
+
+	// Body is returned as text if it is a string and as JSON otherwise.
+	Body any `json:"body" yaml:"body"`
+
+	// Latency simulates a slow backend, e.g. "250ms".
+	Latency string `json:"latency" yaml:"latency"`
+
+	latency time.Duration
+}
+
+// MockConfig defines the configuration for mock middleware.
+type MockConfig struct {
+	// Enabled turns mocking on. Requests pass through unless set.
+	Enabled bool
+
+	// Files are fixture files, each containing a list of MockFixture.
+	Files []string
+
+	// Fixtures are additional inline fixtures.
+	Fixtures []MockFixture
+
+	// Unmarshal decodes fixture files that are not JSON, e.g. yaml.Unmarshal
+	// from gopkg.in/yaml.v3 for .yaml and .yml files.
+	Unmarshal func([]byte, any) error
+
+	// SkipFunc allows skipping mocking for certain requests.
+	SkipFunc Skipper
+}
+
+// Mock returns middleware that serves canned responses from fixture files.
+func Mock(files ...string) ginji.Middleware {
+	return MockWithConfig(MockConfig{Enabled: true, Files: files})
+}
+
+// MockWithConfig returns mock middleware with custom configuration.
+// Requests that match no fixture continue to the real handlers.
+// It panics if a fixture file cannot be loaded.
+func MockWithConfig(config MockConfig) ginji.Middleware {
+	if !config.Enabled {
+		return func(c *ginji.Context) error {
+			return c.Next()
+		}
+	}
+
+	fixtures, err := loadMockFixtures(config)
+	if err != nil {
+		panic("Mock: " + err.Error())
+	}
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		for i := range fixtures {
+			f := &fixtures[i]
+			if f.Method != "" && !strings.EqualFold(f.Method, c.Req.Method) {
+				continue
+			}
+			params, ok := matchMockPath(f.Path, c.Req.URL.Path)
+			if !ok {
+				continue
+			}
+			c.Abort()
+			return serveMockFixture(c, f, params)
+		}
+
+		return c.Next()
+	}
+}
+
+// loadMockFixtures reads fixture files and validates all fixtures.
+func loadMockFixtures(config MockConfig) ([]MockFixture, error) {
+	var fixtures []MockFixture
+	for _, file := range config.Files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		unmarshal := json.Unmarshal
+		if ext := strings.ToLower(filepath.Ext(file)); ext != ".json" {
+			if config.Unmarshal == nil {
+				return nil, fmt.Errorf("%s: no Unmarshal configured for %s files", file, ext)
+			}
+			unmarshal = config.Unmarshal
+		}
+
+		var loaded []MockFixture
+		if err := unmarshal(data, &loaded); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		fixtures = append(fixtures, loaded...)
+	}
+	fixtures = append(fixtures, config.Fixtures...)
+
+	for i := range fixtures {
+		f := &fixtures[i]
+		if f.Path == "" {
+			return nil, fmt.Errorf("fixture %d: path is required", i)
+		}
+		if f.Status == 0 {
+			f.Status = http.StatusOK
+		}
+		if f.Latency != "" {
+			d, err := time.ParseDuration(f.Latency)
+			if err != nil {
+				return nil, fmt.Errorf("fixture %s: invalid latency: %w", f.Path, err)
+			}
+			f.latency = d
+		}
+		if _, err := renderMockValue(f.Body, nil); err != nil {
+			return nil, fmt.Errorf("fixture %s: %w", f.Path, err)
+		}
+	}
+	return fixtures, nil
+}
+
+// matchMockPath matches a fixture path pattern and returns its parameters.
+func matchMockPath(pattern, path string) (map[string]string, bool) {
+	patternParts := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathParts := strings.Split(strings.Trim(path, "/"), "/")
+	params := make(map[string]string)
+
+	for i, part := range patternParts {
+		if part == "*" {
+			params["*"] = strings.Join(pathParts[i:], "/")
+			return params, true
+		}
+		if i >= len(pathParts) {
+			return nil, false
+		}
+		switch {
+		case strings.HasPrefix(part, ":"):
+			params[part[1:]] = pathParts[i]
+		case part != pathParts[i]:
+			return nil, false
+		}
+	}
+	return params, len(patternParts) == len(pathParts)
+}
+
+// serveMockFixture renders and writes a fixture response.
+func serveMockFixture(c *ginji.Context, f *MockFixture, params map[string]string) error {
+	if f.latency > 0 {
+		timer := time.NewTimer(f.latency)
+		select {
+		case <-timer.C:
+		case <-c.Req.Context().Done():
+			timer.Stop()
+			return nil
+		}
+	}
+
+	var body []byte
+	if c.Req.Body != nil {
+		body, _ = io.ReadAll(c.Req.Body)
+	}
+	data := &mockRequestData{c: c, params: params, body: string(body)}
+
+	rendered, err := renderMockValue(f.Body, data)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ginji.H{"error": err.Error()})
+	}
+
+	c.SetHeader("X-Mock", "true")
+	for k, v := range f.Headers {
+		c.SetHeader(k, v)
+	}
+
+	switch v := rendered.(type) {
+	case nil:
+		c.Res.WriteHeader(f.Status)
+		return nil
+	case string:
+		if c.Res.Header().Get("Content-Type") == "" {
+			c.SetHeader("Content-Type", "text/plain; charset=utf-8")
+		}
+		c.Res.WriteHeader(f.Status)
+		_, err := c.Res.Write([]byte(v))
+		return err
+	default:
+		return c.JSON(f.Status, v)
+	}
+}
+
+// mockRequestData is the template data for fixture bodies.
+type mockRequestData struct {
+	c      *ginji.Context
+	params map[string]string
+	body   string
+}
+
+// Method returns the request method.
+func (d *mockRequestData) Method() string { return d.c.Req.Method }
+
+// Path returns the request path.
+func (d *mockRequestData) Path() string { return d.c.Req.URL.Path }
+
+// Body returns the raw request body.
+func (d *mockRequestData) Body() string { return d.body }
+
+// Param returns a path parameter captured by the fixture pattern.
+func (d *mockRequestData) Param(name string) string { return d.params[name] }
+
+// Query returns a query string parameter.
+func (d *mockRequestData) Query(name string) string { return d.c.Query(name) }
+
+// Header returns a request header.
+func (d *mockRequestData) Header(name string) string { return d.c.Header(name) }
+
+// mockTemplateFuncs are the random data helpers available to fixtures.
+var mockTemplateFuncs = template.FuncMap{
+	"uuid": func() string {
+		b := make([]byte, 16)
+		_, _ = rand.Read(b)
+		b[6] = (b[6] & 0x0f) | 0x40
+		b[8] = (b[8] & 0x3f) | 0x80
+		return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+	},
+	"randInt": func(lo, hi int) int {
+		if hi <= lo {
+			return lo
+		}
+		n, _ := rand.Int(rand.Reader, big.NewInt(int64(hi-lo+1)))
+		return lo + int(n.Int64())
+	},
+	"randString": func(n int) string {
+		const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = letters[mathrand.IntN(len(letters))]
+		}
+		return string(b)
+	},
+	"randChoice": func(choices ...string) string {
+		if len(choices) == 0 {
+			return ""
+		}
+		return choices[mathrand.IntN(len(choices))]
+	},
+	"now": func() string {
+		return time.Now().UTC().Format(time.RFC3339)
+	},
+}
+
+// renderMockValue executes templates in every string of v. With nil data
+// the templates are only parsed, to validate fixtures at load time.
+func renderMockValue(v any, data *mockRequestData) (any, error) {
+	switch val := v.(type) {
+	case string:
+		if !strings.Contains(val, "{{") {
+			return val, nil
+		}
+		tmpl, err := template.New("fixture").Funcs(mockTemplateFuncs).Parse(val)
+		if err != nil {
+			return nil, err
+		}
+		if data == nil {
+			return val, nil
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return nil, err
+		}
+		return buf.String(), nil
+	case map[string]any:
+		out := make(map[string]any, len(val))
+		for k, item := range val {
+			rendered, err := renderMockValue(item, data)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = rendered
+		}
+		return out, nil
+	case []any:
+		out := make([]any, len(val))
+		for i, item := range val {
+			rendered, err := renderMockValue(item, data)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = rendered
+		}
+		return out, nil
+	}
+	return v, nil
+}
@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+const mockFixtureJSON = `[
+	{
+		"method": "GET",
+		"path": "/users/:id",
+		"body": {"id": "{{.Param \"id\"}}", "name": "User {{.Param \"id\"}}", "token": "{{randString 12}}"}
+	},
+	{
+		"method": "POST",
+		"path": "/echo",
+		"status": 201,
+		"headers": {"Content-Type": "text/plain"},
+		"body": "you sent {{.Body}} via {{.Method}}",
+		"latency": "20ms"
+	}
+]`
+
+func writeMockFixture(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestMockServesFixtures(t *testing.T) {
+	app := ginji.New()
+	app.Use(Mock(writeMockFixture(t, "fixtures.json", mockFixtureJSON)))
+	app.Get("/real", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "real")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/users/42", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	ginji.AssertHeader(t, w, "X-Mock", "true")
+
+	var user map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &user); err != nil {
+		t.Fatal(err)
+	}
+	if user["id"] != "42" || user["name"] != "User 42" || len(user["token"]) != 12 {
+		t.Errorf("Unexpected templated body: %v", user)
+	}
+
+	start := time.Now()
+	w = ginji.PerformRequest(app, "POST", "/echo", strings.NewReader("hello"))
+	ginji.AssertStatus(t, w, ginji.StatusCreated)
+	ginji.AssertBody(t, w, "you sent hello via POST")
+	if time.Since(start) < 20*time.Millisecond {
+		t.Error("Expected simulated latency")
+	}
+
+	// Unmatched routes reach the real handlers
+	w = ginji.PerformRequest(app, "GET", "/real", nil)
+	ginji.AssertBody(t, w, "real")
+}
+
+func TestMockDisabled(t *testing.T) {
+	app := ginji.New()
+	app.Use(MockWithConfig(MockConfig{
+		Fixtures: []MockFixture{{Path: "/users/*", Body: "mock"}},
+	}))
+	app.Get("/users/1", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "real")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/users/1", nil)
+	ginji.AssertBody(t, w, "real")
+}
+
+func TestMockCustomUnmarshal(t *testing.T) {
+	file := writeMockFixture(t, "fixtures.yaml", `[{"path": "/health", "body": "mocked"}]`)
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic for YAML file without Unmarshal")
+		}
+	}()
+
+	app := ginji.New()
+	app.Use(MockWithConfig(MockConfig{
+		Enabled:   true,
+		Files:     []string{file},
+		Unmarshal: json.Unmarshal, // stands in for a YAML decoder
+	}))
+	app.Get("/health", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "real")
+	})
+	ginji.AssertBody(t, ginji.PerformRequest(app, "GET", "/health", nil), "mocked")
+
+	Mock(file)
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ginjigo/ginji"
+)
+
+func TestOriginCheck(t *testing.T) {
+	app := ginji.New()
+	app.Use(OriginCheck("https://app.example.com", "https://*.example.org"))
+	app.Post("/submit", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+	app.Get("/page", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	tests := []struct {
+		name    string
+		method  string
+		origin  string
+		referer string
+		want    int
+	}{
+		{"trusted origin", "POST", "https://app.example.com", "", ginji.StatusOK},
+		{"trusted origin case", "POST", "HTTPS://APP.EXAMPLE.COM", "", ginji.StatusOK},
+		{"same origin", "POST", "http://example.com", "", ginji.StatusOK},
+		{"wildcard subdomain", "POST", "https://eu.api.example.org", "", ginji.StatusOK},
+		{"wildcard apex", "POST", "https://example.org", "", ginji.StatusForbidden},
+		{"wildcard scheme", "POST", "http://eu.example.org", "", ginji.StatusForbidden},
+		{"lookalike", "POST", "https://evilexample.org", "", ginji.StatusForbidden},
+		{"untrusted origin", "POST", "https://evil.example", "", ginji.StatusForbidden},
+		{"null origin", "POST", "null", "", ginji.StatusForbidden},
+		{"trusted referer", "POST", "", "https://app.example.com/form?x=1", ginji.StatusOK},
+		{"untrusted referer", "POST", "", "https://evil.example/form", ginji.StatusForbidden},
+		{"missing headers", "POST", "", "", ginji.StatusOK},
+		{"safe method", "GET", "https://evil.example", "", ginji.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := "/submit"
+			if tt.method == "GET" {
+				path = "/page"
+			}
+			req := httptest.NewRequest(tt.method, path, nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.referer != "" {
+				req.Header.Set("Referer", tt.referer)
+			}
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, req)
+			ginji.AssertStatus(t, w, tt.want)
+		})
+	}
+}
+
+func TestOriginCheckRejectMissing(t *testing.T) {
+	app := ginji.New()
+	app.Use(OriginCheckWithConfig(OriginCheckConfig{RejectMissing: true, Message: "Cross-site request blocked"}))
+	app.Post("/submit", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	w := ginji.PerformRequest(app, "POST", "/submit", nil)
+	ginji.AssertStatus(t, w, ginji.StatusForbidden)
+	ginji.AssertBody(t, w, "Cross-site request blocked")
+}
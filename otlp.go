@@ -0,0 +1,370 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// OTLPConfig defines the configuration for an OTLPExporter.
+type OTLPConfig struct {
+	// Endpoint is the OTLP/HTTP metrics endpoint of the collector.
+	// Default: "http://localhost:4318/v1/metrics"
+	Endpoint string
+
+	// Headers are added to every export request, e.g. for authentication.
+	Headers map[string]string
+
+	// ServiceName is reported as the service.name resource attribute.
+	// Default: "ginji"
+	ServiceName string
+
+	// Interval is how often metrics are exported.
+	// Default: 10 seconds
+	Interval time.Duration
+
+	// Timeout bounds each export request.
+	// Default: 10 seconds
+	Timeout time.Duration
+
+	// Client is the HTTP client used for exports.
+	// Default: http.DefaultClient
+	Client *http.Client
+
+	// OnError is called when a periodic export fails. Optional.
+	OnError func(error)
+}
+
+// DefaultOTLPConfig returns default OTLP exporter configuration.
+func DefaultOTLPConfig() OTLPConfig {
+	return OTLPConfig{
+		Endpoint:    "http://localhost:4318/v1/metrics",
+		ServiceName: "ginji",
+		Interval:    10 * time.Second,
+		Timeout:     10 * time.Second,
+		Client:      http.DefaultClient,
+	}
+}
+
+// OTLPExporter periodically pushes the metrics collected by Metrics to an
+// OpenTelemetry collector using OTLP/HTTP with JSON encoding. Sums and
+// histograms are exported with cumulative temporality.
+//
+//	exporter := middleware.NewOTLPExporter(metrics, middleware.OTLPConfig{ServiceName: "api"})
+//	app.UsePlugin(exporter) // Flushed and stopped by app.StopPlugins on shutdown
+type OTLPExporter struct {
+	metrics   *Metrics
+	config    OTLPConfig
+	start     time.Time
+	done      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+	mu        sync.Mutex
+	lastErr   error
+}
+
+// NewOTLPExporter creates an exporter for metrics and starts exporting
+// every Interval.
+func NewOTLPExporter(metrics *Metrics, config OTLPConfig) *OTLPExporter {
+	defaults := DefaultOTLPConfig()
+	if config.Endpoint == "" {
+		config.Endpoint = defaults.Endpoint
+	}
+	if config.ServiceName == "" {
+		config.ServiceName = defaults.ServiceName
+	}
+	if config.Interval <= 0 {
+		config.Interval = defaults.Interval
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+	if config.Client == nil {
+		config.Client = defaults.Client
+	}
+
+	e := &OTLPExporter{
+		metrics: metrics,
+		config:  config,
+		start:   time.Now(),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go e.exportLoop()
+	return e
+}
+
+// Export sends the current metrics to the collector.
+func (e *OTLPExporter) Export(ctx context.Context) error {
+	err := e.export(ctx)
+	e.mu.Lock()
+	e.lastErr = err
+	e.mu.Unlock()
+	return err
+}
+
+// HealthCheck implements HealthReporter by returning the error of the
+// most recent export, if it failed.
+func (e *OTLPExporter) HealthCheck() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.lastErr
+}
+
+// export sends one export request.
+func (e *OTLPExporter) export(ctx context.Context) error {
+	e.metrics.refreshSLOGauges()
+
+	body, err := json.Marshal(e.payload(time.Now()))
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.config.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range e.config.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := e.config.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("otlp: collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Close stops periodic exports and sends a final export.
+func (e *OTLPExporter) Close() error {
+	var err error
+	e.closeOnce.Do(func() {
+		close(e.done)
+		<-e.stopped
+		err = e.Export(context.Background())
+	})
+	return err
+}
+
+// Name implements ginji.Plugin.
+func (e *OTLPExporter) Name() string {
+	return "otlp-exporter"
+}
+
+// Version implements ginji.Plugin.
+func (e *OTLPExporter) Version() string {
+	return "1.0.0"
+}
+
+// Install implements ginji.Plugin.
+func (e *OTLPExporter) Install(*ginji.Engine) error {
+	return nil
+}
+
+// Start implements ginji.Plugin.
+func (e *OTLPExporter) Start() error {
+	return nil
+}
+
+// Stop implements ginji.Plugin by closing the exporter.
+func (e *OTLPExporter) Stop() error {
+	return e.Close()
+}
+
+// exportLoop exports every Interval until closed.
+func (e *OTLPExporter) exportLoop() {
+	defer close(e.stopped)
+
+	ticker := time.NewTicker(e.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := e.Export(context.Background()); err != nil && e.config.OnError != nil {
+				e.config.OnError(err)
+			}
+		case <-e.done:
+			return
+		}
+	}
+}
+
+// OTLP JSON document types. Only the fields used here are modelled.
+type (
+	otlpRequest struct {
+		ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+	}
+	otlpResourceMetrics struct {
+		Resource     otlpResource       `json:"resource"`
+		ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+	otlpScopeMetrics struct {
+		Scope   otlpScope    `json:"scope"`
+		Metrics []otlpMetric `json:"metrics"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpMetric struct {
+		Name        string         `json:"name"`
+		Description string         `json:"description,omitempty"`
+		Unit        string         `json:"unit,omitempty"`
+		Sum         *otlpSum       `json:"sum,omitempty"`
+		Gauge       *otlpGauge     `json:"gauge,omitempty"`
+		Histogram   *otlpHistogram `json:"histogram,omitempty"`
+	}
+	otlpSum struct {
+		DataPoints             []otlpNumberPoint `json:"dataPoints"`
+		AggregationTemporality int               `json:"aggregationTemporality"`
+		IsMonotonic            bool              `json:"isMonotonic"`
+	}
+	otlpGauge struct {
+		DataPoints []otlpNumberPoint `json:"dataPoints"`
+	}
+	otlpHistogram struct {
+		DataPoints             []otlpHistogramPoint `json:"dataPoints"`
+		AggregationTemporality int                  `json:"aggregationTemporality"`
+	}
+	otlpNumberPoint struct {
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		TimeUnixNano      string          `json:"timeUnixNano"`
+		AsDouble          float64         `json:"asDouble"`
+	}
+	otlpHistogramPoint struct {
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		TimeUnixNano      string          `json:"timeUnixNano"`
+		Count             string          `json:"count"`
+		Sum               float64         `json:"sum"`
+		BucketCounts      []string        `json:"bucketCounts"`
+		ExplicitBounds    []float64       `json:"explicitBounds"`
+	}
+	otlpAttribute struct {
+		Key   string         `json:"key"`
+		Value otlpValueUnion `json:"value"`
+	}
+	otlpValueUnion struct {
+		StringValue string `json:"stringValue"`
+	}
+)
+
+// otlpCumulative is AGGREGATION_TEMPORALITY_CUMULATIVE.
+const otlpCumulative = 2
+
+// payload converts a metrics snapshot into an OTLP export request.
+func (e *OTLPExporter) payload(now time.Time) otlpRequest {
+	start := strconv.FormatInt(e.start.UnixNano(), 10)
+	ts := strconv.FormatInt(now.UnixNano(), 10)
+
+	var metrics []otlpMetric
+	for _, family := range e.metrics.snapshot() {
+		metric := otlpMetric{Name: family.name, Description: family.help}
+
+		keys := make([]string, 0, len(family.series))
+		for key := range family.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		switch family.typ {
+		case MetricHistogram:
+			metric.Unit = otlpUnit(family.name)
+			metric.Histogram = &otlpHistogram{AggregationTemporality: otlpCumulative}
+			for _, key := range keys {
+				s := family.series[key]
+				// OTLP bucket counts are per bucket, not cumulative
+				counts := make([]string, len(family.buckets)+1)
+				var prev uint64
+				for i, cumulative := range s.buckets {
+					counts[i] = strconv.FormatUint(cumulative-prev, 10)
+					prev = cumulative
+				}
+				counts[len(family.buckets)] = strconv.FormatUint(s.count-prev, 10)
+				metric.Histogram.DataPoints = append(metric.Histogram.DataPoints, otlpHistogramPoint{
+					Attributes:        otlpAttributes(s.labels),
+					StartTimeUnixNano: start,
+					TimeUnixNano:      ts,
+					Count:             strconv.FormatUint(s.count, 10),
+					Sum:               s.value,
+					BucketCounts:      counts,
+					ExplicitBounds:    family.buckets,
+				})
+			}
+		case MetricCounter:
+			metric.Sum = &otlpSum{AggregationTemporality: otlpCumulative, IsMonotonic: true}
+			for _, key := range keys {
+				s := family.series[key]
+				metric.Sum.DataPoints = append(metric.Sum.DataPoints, otlpNumberPoint{
+					Attributes:        otlpAttributes(s.labels),
+					StartTimeUnixNano: start,
+					TimeUnixNano:      ts,
+					AsDouble:          s.value,
+				})
+			}
+		default:
+			metric.Gauge = &otlpGauge{}
+			for _, key := range keys {
+				s := family.series[key]
+				metric.Gauge.DataPoints = append(metric.Gauge.DataPoints, otlpNumberPoint{
+					Attributes:        otlpAttributes(s.labels),
+					StartTimeUnixNano: start,
+					TimeUnixNano:      ts,
+					AsDouble:          s.value,
+				})
+			}
+		}
+		metrics = append(metrics, metric)
+	}
+
+	return otlpRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource: otlpResource{Attributes: []otlpAttribute{
+			{Key: "service.name", Value: otlpValueUnion{StringValue: e.config.ServiceName}},
+		}},
+		ScopeMetrics: []otlpScopeMetrics{{
+			Scope:   otlpScope{Name: "github.com/ginjigo/middleware"},
+			Metrics: metrics,
+		}},
+	}}}
+}
+
+// otlpAttributes converts alternating label names and values.
+func otlpAttributes(labels []string) []otlpAttribute {
+	attrs := make([]otlpAttribute, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		attrs = append(attrs, otlpAttribute{Key: labels[i], Value: otlpValueUnion{StringValue: labels[i+1]}})
+	}
+	return attrs
+}
+
+// otlpUnit derives the UCUM unit from a metric name suffix.
+func otlpUnit(name string) string {
+	switch {
+	case strings.HasSuffix(name, "_seconds"):
+		return "s"
+	case strings.HasSuffix(name, "_bytes"):
+		return "By"
+	}
+	return ""
+}
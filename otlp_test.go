@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+func TestOTLPExporter(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		bodies  []string
+		headers []http.Header
+	)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		headers = append(headers, r.Header.Clone())
+		mu.Unlock()
+	}))
+	defer collector.Close()
+
+	m := NewMetrics(MetricsConfig{Buckets: []float64{0.5, 1}})
+	app := ginji.New()
+	app.Use(m.Middleware())
+	app.Get("/metrics", m.Handler())
+	app.Get("/users", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "users")
+	})
+	app.Get("/fail", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusInternalServerError, "fail")
+	})
+	ginji.PerformRequest(app, "GET", "/users", nil)
+	m.Observe("job_seconds", 0.7)
+
+	exporter := NewOTLPExporter(m, OTLPConfig{
+		Endpoint:    collector.URL + "/v1/metrics",
+		ServiceName: "api",
+		Headers:     map[string]string{"Authorization": "Bearer token"},
+		Interval:    time.Hour,
+	})
+	if err := exporter.Close(); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(bodies) != 1 {
+		t.Fatalf("Expected one export on Close, got %d", len(bodies))
+	}
+	if headers[0].Get("Authorization") != "Bearer token" || headers[0].Get("Content-Type") != "application/json" {
+		t.Errorf("Expected auth and JSON headers, got %v", headers[0])
+	}
+
+	var req otlpRequest
+	if err := json.Unmarshal([]byte(bodies[0]), &req); err != nil {
+		t.Fatalf("Invalid OTLP JSON: %v", err)
+	}
+	rm := req.ResourceMetrics[0]
+	if rm.Resource.Attributes[0].Value.StringValue != "api" {
+		t.Errorf("Expected service.name api, got %+v", rm.Resource.Attributes)
+	}
+
+	byName := make(map[string]otlpMetric)
+	for _, metric := range rm.ScopeMetrics[0].Metrics {
+		byName[metric.Name] = metric
+	}
+
+	total := byName["http_requests_total"]
+	if total.Sum == nil || !total.Sum.IsMonotonic || total.Sum.DataPoints[0].AsDouble != 1 {
+		t.Errorf("Expected monotonic request counter, got %+v", total)
+	}
+	if byName["http_requests_in_flight"].Gauge == nil {
+		t.Error("Expected in-flight gauge")
+	}
+
+	job := byName["http_job_seconds"]
+	if job.Histogram == nil || job.Unit != "s" {
+		t.Fatalf("Expected histogram in seconds, got %+v", job)
+	}
+	point := job.Histogram.DataPoints[0]
+	if strings.Join(point.BucketCounts, ",") != "0,1,0" || point.Count != "1" {
+		t.Errorf("Expected per-bucket counts 0,1,0, got %v (count %s)", point.BucketCounts, point.Count)
+	}
+}
+
+func TestOTLPExporterError(t *testing.T) {
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer collector.Close()
+
+	exporter := NewOTLPExporter(NewMetrics(DefaultMetricsConfig()), OTLPConfig{Endpoint: collector.URL, Interval: time.Hour})
+	defer func() { _ = exporter.Close() }()
+
+	err := exporter.Export(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("Expected collector error, got %v", err)
+	}
+}
+
+func TestOTLPExporterHealthCheck(t *testing.T) {
+	status := http.StatusServiceUnavailable
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer collector.Close()
+
+	exporter := NewOTLPExporter(NewMetrics(DefaultMetricsConfig()), OTLPConfig{Endpoint: collector.URL, Interval: time.Hour})
+	defer func() { _ = exporter.Close() }()
+
+	if err := exporter.HealthCheck(); err != nil {
+		t.Errorf("Expected healthy exporter before the first export, got %v", err)
+	}
+	_ = exporter.Export(context.Background())
+	if err := exporter.HealthCheck(); err == nil {
+		t.Error("Expected failed export to make the exporter unhealthy")
+	}
+
+	status = http.StatusOK
+	_ = exporter.Export(context.Background())
+	if err := exporter.HealthCheck(); err != nil {
+		t.Errorf("Expected recovery after a successful export, got %v", err)
+	}
+}
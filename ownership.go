@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"errors"
+
+	"github.com/ginjigo/ginji"
+)
+
+// ErrResourceNotFound is returned by an OwnershipConfig.OwnerFunc when the
+// resource does not exist.
+var ErrResourceNotFound = errors.New("ownership: resource not found")
+
+// OwnershipConfig defines the configuration for ownership checks.
+type OwnershipConfig struct {
+	// OwnerFunc loads the resource of the request and returns its owner's
+	// ID, or ErrResourceNotFound. Other errors are returned from the
+	// middleware. Required.
+	OwnerFunc func(*ginji.Context) (string, error)
+
+	// UserIDFunc returns the authenticated user's ID.
+	// Default: the "id", "username" or "email" field of the user (see
+	// UserFrom), or the "sub" claim
+	UserIDFunc func(*ginji.Context) string
+
+	// BypassRoles may access any resource, e.g. "admin". Roles are read as
+	// in RequireRole.
+	// Default: nil
+	BypassRoles []string
+
+	// DeniedStatus is sent when the user does not own the resource. Use
+	// 404 to hide that the resource exists.
+	// Default: 403
+	DeniedStatus int
+
+	// SkipFunc allows skipping the check for certain requests.
+	SkipFunc Skipper
+}
+
+// DefaultOwnershipConfig returns default ownership configuration.
+func DefaultOwnershipConfig() OwnershipConfig {
+	return OwnershipConfig{
+		UserIDFunc:   defaultPolicySubject,
+		DeniedStatus: ginji.StatusForbidden,
+	}
+}
+
+// RequireOwnership returns middleware that only lets the owner of a
+// resource through. Attach it to the group of routes it protects:
+//
+//	posts := app.Group("/posts")
+//	posts.Use(middleware.RequireOwnership(func(c *ginji.Context) (string, error) {
+//		post, err := store.FindPost(c.Param("id"))
+//		if err != nil {
+//			return "", middleware.ErrResourceNotFound
+//		}
+//		return post.AuthorID, nil
+//	}))
+//	posts.Put("/:id", updatePost)
+func RequireOwnership(ownerFunc func(*ginji.Context) (string, error)) ginji.Middleware {
+	config := DefaultOwnershipConfig()
+	config.OwnerFunc = ownerFunc
+	return RequireOwnershipWithConfig(config)
+}
+
+// RequireOwnershipWithConfig returns middleware with custom ownership
+// configuration. Unauthenticated requests get 401 and missing resources
+// 404. It panics if OwnerFunc is nil.
+func RequireOwnershipWithConfig(config OwnershipConfig) ginji.Middleware {
+	defaults := DefaultOwnershipConfig()
+	if config.OwnerFunc == nil {
+		panic("RequireOwnership: OwnerFunc is required")
+	}
+	if config.UserIDFunc == nil {
+		config.UserIDFunc = defaults.UserIDFunc
+	}
+	if config.DeniedStatus == 0 {
+		config.DeniedStatus = defaults.DeniedStatus
+	}
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		userID := config.UserIDFunc(c)
+		if userID == "" {
+			c.AbortWithStatusJSON(ginji.StatusUnauthorized, ginji.H{
+				"error": "Unauthorized",
+			})
+			return nil
+		}
+
+		// Admins may access everything, even missing resources
+		if user, ok := UserFrom(c); ok {
+			for _, role := range config.BypassRoles {
+				if userHasRole(user, role) {
+					return c.Next()
+				}
+			}
+		}
+
+		ownerID, err := config.OwnerFunc(c)
+		if errors.Is(err, ErrResourceNotFound) {
+			c.AbortWithStatusJSON(ginji.StatusNotFound, ginji.H{
+				"error": "Not found",
+			})
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if ownerID != userID {
+			if config.DeniedStatus == ginji.StatusNotFound {
+				c.AbortWithStatusJSON(ginji.StatusNotFound, ginji.H{
+					"error": "Not found",
+				})
+				return nil
+			}
+			c.AbortWithStatusJSON(config.DeniedStatus, ginji.H{
+				"error": "Access denied",
+			})
+			return nil
+		}
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ginjigo/ginji"
+)
+
+// postOwners maps post IDs to their authors.
+var postOwners = map[string]string{"1": "alice", "2": "bob"}
+
+func postOwner(c *ginji.Context) (string, error) {
+	owner, ok := postOwners[c.Param("id")]
+	if !ok {
+		return "", ErrResourceNotFound
+	}
+	return owner, nil
+}
+
+func TestRequireOwnership(t *testing.T) {
+	tests := []struct {
+		name string
+		user any
+		path string
+		want int
+	}{
+		{"owner", map[string]any{"id": "alice"}, "/posts/1", ginji.StatusOK},
+		{"string user", "alice", "/posts/1", ginji.StatusOK},
+		{"other user", map[string]any{"id": "alice"}, "/posts/2", ginji.StatusForbidden},
+		{"missing resource", map[string]any{"id": "alice"}, "/posts/9", ginji.StatusNotFound},
+		{"unauthenticated", nil, "/posts/1", ginji.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := ginji.New()
+			app.Use(func(c *ginji.Context) error {
+				if tt.user != nil {
+					SetUser(c, tt.user)
+				}
+				return c.Next()
+			})
+			app.Use(RequireOwnership(postOwner))
+			app.Put("/posts/:id", func(c *ginji.Context) error {
+				return c.Text(ginji.StatusOK, "updated")
+			})
+			ginji.AssertStatus(t, ginji.PerformRequest(app, "PUT", tt.path, nil), tt.want)
+		})
+	}
+}
+
+func TestRequireOwnershipHideExistence(t *testing.T) {
+	config := DefaultOwnershipConfig()
+	config.OwnerFunc = postOwner
+	config.DeniedStatus = ginji.StatusNotFound
+	app := ginji.New()
+	app.Use(func(c *ginji.Context) error {
+		SetUser(c, map[string]any{"id": "alice"})
+		return c.Next()
+	})
+	app.Use(RequireOwnershipWithConfig(config))
+	app.Put("/posts/:id", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "updated")
+	})
+
+	w := ginji.PerformRequest(app, "PUT", "/posts/2", nil)
+	ginji.AssertStatus(t, w, ginji.StatusNotFound)
+	ginji.AssertBody(t, w, "Not found")
+}
+
+func TestRequireOwnershipBypassRoles(t *testing.T) {
+	config := DefaultOwnershipConfig()
+	config.OwnerFunc = postOwner
+	config.BypassRoles = []string{"admin"}
+
+	var user any
+	app := ginji.New()
+	app.Use(func(c *ginji.Context) error {
+		SetUser(c, user)
+		return c.Next()
+	})
+	app.Use(RequireOwnershipWithConfig(config))
+	app.Put("/posts/:id", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "updated")
+	})
+
+	user = map[string]any{"id": "carol", "roles": []string{"editor", "admin"}}
+	ginji.AssertStatus(t, ginji.PerformRequest(app, "PUT", "/posts/2", nil), ginji.StatusOK)
+
+	user = map[string]any{"id": "carol", "role": "editor"}
+	ginji.AssertStatus(t, ginji.PerformRequest(app, "PUT", "/posts/2", nil), ginji.StatusForbidden)
+}
+
+func TestRequireOwnershipLoaderError(t *testing.T) {
+	errDB := errors.New("database unavailable")
+	var got error
+
+	app := ginji.New()
+	app.Use(func(c *ginji.Context) error {
+		SetUser(c, "alice")
+		got = c.Next()
+		return got
+	})
+	app.Use(RequireOwnership(func(c *ginji.Context) (string, error) {
+		return "", errDB
+	}))
+	app.Put("/posts/:id", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "updated")
+	})
+
+	ginji.PerformRequest(app, "PUT", "/posts/1", nil)
+	if !errors.Is(got, errDB) {
+		t.Errorf("Expected loader error, got %v", got)
+	}
+}
@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/ginjigo/ginji"
+)
+
+// PathNormalizeConfig defines the configuration for path normalization.
+type PathNormalizeConfig struct {
+	// Redirect issues a redirect to the canonical path instead of rewriting
+	// the request in place.
+	// Default: false
+	Redirect bool
+
+	// RedirectCode is the status code used for canonical redirects.
+	// Default: 301 for GET/HEAD, 308 for other methods
+	RedirectCode int
+
+	// RemoveTrailingSlash strips a trailing slash from non-root paths.
+	// Default: false (trailing slashes are preserved)
+	RemoveTrailingSlash bool
+
+	// ErrorMessage is returned when a path is rejected.
+	// Default: "Invalid request path"
+	ErrorMessage string
+
+	// SkipFunc allows skipping normalization for certain requests.
+	SkipFunc Skipper
+}
+
+// DefaultPathNormalizeConfig returns default path normalization configuration.
+func DefaultPathNormalizeConfig() PathNormalizeConfig {
+	return PathNormalizeConfig{
+		ErrorMessage: "Invalid request path",
+	}
+}
+
+// PathNormalize returns a hook that canonicalizes request paths and rejects
+// null bytes and traversal attempts with 400 before routing.
+//
+// It must run before the router matches the request:
+//
+//	app.OnRequest(middleware.PathNormalize())
+func PathNormalize() ginji.HookFunc {
+	return PathNormalizeWithConfig(DefaultPathNormalizeConfig())
+}
+
+// PathNormalizeWithConfig returns a path normalization hook with custom configuration.
+func PathNormalizeWithConfig(config PathNormalizeConfig) ginji.HookFunc {
+	if config.ErrorMessage == "" {
+		config.ErrorMessage = "Invalid request path"
+	}
+
+	return func(c *ginji.Context) {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return
+		}
+
+		original := c.Req.URL.Path
+
+		normalized, ok := normalizePath(c.Req.URL.EscapedPath(), config.RemoveTrailingSlash)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusBadRequest, ginji.H{
+				"error": config.ErrorMessage,
+			})
+			return
+		}
+
+		if normalized == original {
+			return
+		}
+
+		if config.Redirect {
+			code := config.RedirectCode
+			if code == 0 {
+				code = http.StatusMovedPermanently
+				if c.Req.Method != http.MethodGet && c.Req.Method != http.MethodHead {
+					code = http.StatusPermanentRedirect
+				}
+			}
+			target := (&url.URL{Path: normalized, RawQuery: c.Req.URL.RawQuery}).String()
+			_ = c.Redirect(code, target)
+			c.Abort()
+			return
+		}
+
+		c.Req.URL.Path = normalized
+		c.Req.URL.RawPath = ""
+	}
+}
+
+// normalizePath decodes and cleans an escaped request path.
+// It reports false when the path contains null bytes, invalid escapes,
+// double-encoded separators or dot segments that climb above the root.
+func normalizePath(escaped string, removeTrailingSlash bool) (string, bool) {
+	decoded, err := url.PathUnescape(escaped)
+	if err != nil {
+		return "", false
+	}
+
+	if strings.ContainsRune(decoded, 0) || strings.ContainsRune(decoded, '\\') {
+		return "", false
+	}
+
+	// A second round of escapes after decoding means the client double-encoded
+	// separators or dots to sneak them past upstream filters.
+	lower := strings.ToLower(decoded)
+	if strings.Contains(lower, "%2e") || strings.Contains(lower, "%2f") || strings.Contains(lower, "%00") || strings.Contains(lower, "%5c") {
+		return "", false
+	}
+
+	// Reject dot segments that would escape the root
+	depth := 0
+	for _, segment := range strings.Split(decoded, "/") {
+		switch segment {
+		case "", ".":
+		case "..":
+			depth--
+			if depth < 0 {
+				return "", false
+			}
+		default:
+			depth++
+		}
+	}
+
+	trailing := strings.HasSuffix(decoded, "/") && !removeTrailingSlash
+	cleaned := path.Clean("/" + decoded)
+	if trailing && cleaned != "/" {
+		cleaned += "/"
+	}
+
+	return cleaned, true
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ginjigo/ginji"
+)
+
+func TestPathNormalizeRewrite(t *testing.T) {
+	app := ginji.New()
+	app.OnRequest(PathNormalize())
+
+	app.Get("/api/users", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, c.Req.URL.Path)
+	})
+
+	paths := []string{"//api//users", "/api/./users", "/api/x/../users"}
+	for _, p := range paths {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.URL.Path = p
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+
+		if w.Code != ginji.StatusOK {
+			t.Errorf("%s: Expected status 200, got %d", p, w.Code)
+		}
+		ginji.AssertBody(t, w, "/api/users")
+	}
+}
+
+func TestPathNormalizeRejectsTraversal(t *testing.T) {
+	app := ginji.New()
+	app.OnRequest(PathNormalize())
+
+	app.Get("/files/*path", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "file")
+	})
+
+	paths := []string{
+		"/files/../../etc/passwd",
+		"/files/%2e%2e/%2e%2e/%2e%2e/etc/passwd",
+		"/files/%252e%252e/secret",
+		"/files/name%00.txt",
+	}
+	for _, p := range paths {
+		w := ginji.PerformRequest(app, "GET", p, nil)
+		if w.Code != ginji.StatusBadRequest {
+			t.Errorf("%s: Expected status 400, got %d", p, w.Code)
+		}
+	}
+}
+
+func TestPathNormalizeRedirect(t *testing.T) {
+	app := ginji.New()
+	app.OnRequest(PathNormalizeWithConfig(PathNormalizeConfig{
+		Redirect:            true,
+		RemoveTrailingSlash: true,
+	}))
+
+	app.Get("/docs", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "docs")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/docs/?page=2", nil)
+	if w.Code != ginji.StatusMovedPermanently {
+		t.Fatalf("Expected status 301, got %d", w.Code)
+	}
+	ginji.AssertHeader(t, w, "Location", "/docs?page=2")
+
+	w = ginji.PerformRequest(app, "GET", "/docs", nil)
+	if w.Code != ginji.StatusOK {
+		t.Errorf("Expected status 200 for canonical path, got %d", w.Code)
+	}
+}
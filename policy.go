@@ -0,0 +1,157 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// PolicyRequest is the input of an authorization decision.
+type PolicyRequest struct {
+	// Subject is who is acting, usually the user ID.
+	Subject string `json:"subject"`
+
+	// Object is what is acted on, usually the route or a resource ID.
+	Object string `json:"object"`
+
+	// Action is what is done, usually the HTTP method.
+	Action string `json:"action"`
+
+	// Attributes carry additional input for attribute-based policies.
+	Attributes map[string]any `json:"attributes,omitempty"`
+}
+
+// PolicyEngine decides authorization requests.
+type PolicyEngine interface {
+	// Authorize reports whether the request is allowed.
+	Authorize(ctx context.Context, req PolicyRequest) (bool, error)
+}
+
+// PolicyEngineFunc adapts a function to the PolicyEngine interface, e.g. for
+// an embedded Rego query:
+//
+//	query, _ := rego.New(rego.Query("data.httpapi.authz.allow"), rego.Module("authz.rego", policy)).PrepareForEval(ctx)
+//	engine := middleware.PolicyEngineFunc(func(ctx context.Context, req middleware.PolicyRequest) (bool, error) {
+//		rs, err := query.Eval(ctx, rego.EvalInput(req))
+//		return err == nil && rs.Allowed(), err
+//	})
+type PolicyEngineFunc func(ctx context.Context, req PolicyRequest) (bool, error)
+
+// Authorize calls f(ctx, req).
+func (f PolicyEngineFunc) Authorize(ctx context.Context, req PolicyRequest) (bool, error) {
+	return f(ctx, req)
+}
+
+// CasbinEnforcer is the method of *casbin.Enforcer used by NewCasbinEngine,
+// so that this package does not depend on Casbin.
+type CasbinEnforcer interface {
+	Enforce(rvals ...any) (bool, error)
+}
+
+// casbinEngine adapts a Casbin enforcer.
+type casbinEngine struct {
+	enforcer CasbinEnforcer
+}
+
+// NewCasbinEngine returns a PolicyEngine enforcing a Casbin model with the
+// usual (sub, obj, act) request definition:
+//
+//	enforcer, _ := casbin.NewEnforcer("model.conf", "policy.csv")
+//	app.Use(middleware.Authorize(middleware.NewCasbinEngine(enforcer)))
+func NewCasbinEngine(enforcer CasbinEnforcer) PolicyEngine {
+	return &casbinEngine{enforcer: enforcer}
+}
+
+// Authorize enforces (subject, object, action).
+func (e *casbinEngine) Authorize(_ context.Context, req PolicyRequest) (bool, error) {
+	return e.enforcer.Enforce(req.Subject, req.Object, req.Action)
+}
+
+// OPAConfig defines the configuration for the Open Policy Agent adapter.
+type OPAConfig struct {
+	// URL is the Data API URL of the decision, e.g.
+	// "http://localhost:8181/v1/data/httpapi/authz/allow". Required.
+	URL string
+
+	// Client sends the queries.
+	// Default: an http.Client with Timeout
+	Client *http.Client
+
+	// Timeout bounds each query when Client is not set.
+	// Default: 2 seconds
+	Timeout time.Duration
+
+	// Headers are added to every query, e.g. an Authorization bearer token.
+	Headers map[string]string
+}
+
+// opaEngine queries an OPA server over HTTP.
+type opaEngine struct {
+	config OPAConfig
+}
+
+// NewOPAEngine returns a PolicyEngine querying an OPA server's Data API.
+// The PolicyRequest is sent as the input document, and the decision must be
+// a boolean or an object with a boolean "allow" field. An undefined
+// decision denies.
+func NewOPAEngine(config OPAConfig) (PolicyEngine, error) {
+	if config.URL == "" {
+		return nil, errors.New("opa: URL is required")
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 2 * time.Second
+	}
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: config.Timeout}
+	}
+	return &opaEngine{config: config}, nil
+}
+
+// Authorize queries OPA with req as input.
+func (e *opaEngine) Authorize(ctx context.Context, req PolicyRequest) (bool, error) {
+	body, err := json.Marshal(map[string]any{"input": req})
+	if err != nil {
+		return false, fmt.Errorf("opa: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, e.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("opa: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	for name, value := range e.config.Headers {
+		httpReq.Header.Set(name, value)
+	}
+
+	resp, err := e.config.Client.Do(httpReq)
+	if err != nil {
+		return false, fmt.Errorf("opa: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("opa: unexpected status %d", resp.StatusCode)
+	}
+
+	var decision struct {
+		Result any `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&decision); err != nil {
+		return false, fmt.Errorf("opa: %w", err)
+	}
+
+	switch result := decision.Result.(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return result, nil
+	case map[string]any:
+		allow, _ := result["allow"].(bool)
+		return allow, nil
+	}
+	return false, fmt.Errorf("opa: unexpected decision %v", decision.Result)
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeEnforcer is a CasbinEnforcer allowing a fixed set of requests.
+type fakeEnforcer map[[3]string]bool
+
+func (e fakeEnforcer) Enforce(rvals ...any) (bool, error) {
+	var key [3]string
+	for i := range key {
+		key[i], _ = rvals[i].(string)
+	}
+	return e[key], nil
+}
+
+func TestCasbinEngine(t *testing.T) {
+	engine := NewCasbinEngine(fakeEnforcer{{"alice", "/data", "GET"}: true})
+
+	if ok, err := engine.Authorize(context.Background(), PolicyRequest{Subject: "alice", Object: "/data", Action: "GET"}); err != nil || !ok {
+		t.Errorf("Expected alice to read /data, got %v, %v", ok, err)
+	}
+	if ok, _ := engine.Authorize(context.Background(), PolicyRequest{Subject: "alice", Object: "/data", Action: "DELETE"}); ok {
+		t.Error("Expected alice not to delete /data")
+	}
+}
+
+func TestOPAEngine(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		status   int
+		want     bool
+		wantErr  bool
+	}{
+		{"boolean", `{"result": true}`, http.StatusOK, true, false},
+		{"allow object", `{"result": {"allow": true, "reason": "owner"}}`, http.StatusOK, true, false},
+		{"denied", `{"result": false}`, http.StatusOK, false, false},
+		{"undefined", `{}`, http.StatusOK, false, false},
+		{"server error", `{"code": "internal_error"}`, http.StatusInternalServerError, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var input map[string]any
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "Bearer opa-token" {
+					t.Errorf("Expected configured headers, got %q", r.Header.Get("Authorization"))
+				}
+				_ = json.NewDecoder(r.Body).Decode(&input)
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.response))
+			}))
+			defer server.Close()
+
+			engine, err := NewOPAEngine(OPAConfig{
+				URL:     server.URL + "/v1/data/httpapi/authz/allow",
+				Headers: map[string]string{"Authorization": "Bearer opa-token"},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ok, err := engine.Authorize(context.Background(), PolicyRequest{Subject: "alice", Object: "/data", Action: "GET"})
+			if (err != nil) != tt.wantErr || ok != tt.want {
+				t.Errorf("Expected %v (error %v), got %v, %v", tt.want, tt.wantErr, ok, err)
+			}
+			if in, _ := input["input"].(map[string]any); in["subject"] != "alice" || in["action"] != "GET" {
+				t.Errorf("Expected policy request as input, got %v", input)
+			}
+		})
+	}
+}
+
+func TestNewOPAEngineRequiresURL(t *testing.T) {
+	if _, err := NewOPAEngine(OPAConfig{}); err == nil {
+		t.Error("Expected error without URL")
+	}
+}
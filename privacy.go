@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/ginjigo/ginji"
+)
+
+// PrivacyPreference is the privacy signal sent by the client making a
+// request.
+type PrivacyPreference struct {
+	// DoNotTrack is set by "DNT: 1".
+	DoNotTrack bool
+
+	// GlobalPrivacyControl is set by "Sec-GPC: 1", which some
+	// jurisdictions treat as a legally binding opt-out of sale and sharing.
+	GlobalPrivacyControl bool
+}
+
+// OptedOut reports whether the client opted out of tracking.
+func (p PrivacyPreference) OptedOut() bool {
+	return p.DoNotTrack || p.GlobalPrivacyControl
+}
+
+// PrivacyConfig defines the configuration for privacy middleware.
+type PrivacyConfig struct {
+	// HonorDNT treats "DNT: 1" as an opt-out. If neither HonorDNT nor
+	// HonorGPC is set, both are honored.
+	// Default: true
+	HonorDNT bool
+
+	// HonorGPC treats "Sec-GPC: 1" as an opt-out.
+	// Default: true
+	HonorGPC bool
+
+	// OmitBodies keeps Recorder from capturing the bodies of opted-out
+	// requests and their responses.
+	// Default: true
+	OmitBodies bool
+
+	// MinimalLogs keeps Logger to status, method, path, route, latency and
+	// size for opted-out requests, leaving out the client IP, user agent,
+	// query string and country.
+	// Default: true
+	MinimalLogs bool
+
+	// ExperimentCookies are removed from the responses to opted-out
+	// requests, so that clients are not kept in experiment variants.
+	// Default: ["canary"]
+	ExperimentCookies []string
+
+	// SkipFunc allows skipping privacy handling for certain requests.
+	SkipFunc Skipper
+}
+
+// DefaultPrivacyConfig returns default privacy configuration.
+func DefaultPrivacyConfig() PrivacyConfig {
+	return PrivacyConfig{
+		HonorDNT:          true,
+		HonorGPC:          true,
+		OmitBodies:        true,
+		MinimalLogs:       true,
+		ExperimentCookies: []string{"canary"},
+	}
+}
+
+// privacyState is the per-request state of the Privacy middleware.
+type privacyState struct {
+	config *PrivacyConfig
+	pref   PrivacyPreference
+}
+
+// Privacy returns privacy middleware with default configuration.
+func Privacy() ginji.Middleware {
+	return PrivacyWithConfig(DefaultPrivacyConfig())
+}
+
+// PrivacyWithConfig returns middleware that detects the Do-Not-Track and
+// Global Privacy Control headers, exposes the preference with PrivacyFrom
+// and TrackingAllowed, and scales down what is recorded about opted-out
+// requests. Register it before Logger, Recorder and the middleware setting
+// experiment cookies.
+func PrivacyWithConfig(config PrivacyConfig) ginji.Middleware {
+	if !config.HonorDNT && !config.HonorGPC {
+		config.HonorDNT, config.HonorGPC = true, true
+	}
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		state := &privacyState{config: &config}
+		state.pref.DoNotTrack = config.HonorDNT && strings.TrimSpace(c.Req.Header.Get("DNT")) == "1"
+		state.pref.GlobalPrivacyControl = config.HonorGPC && strings.TrimSpace(c.Req.Header.Get("Sec-GPC")) == "1"
+		setContextValue(c, privacyContextKey, state)
+
+		if !state.pref.OptedOut() || len(config.ExperimentCookies) == 0 {
+			return c.Next()
+		}
+
+		rw := WrapResponseWriter(c)
+		rw.Before(func(h http.Header) {
+			removeCookies(h, config.ExperimentCookies)
+		})
+
+		err := c.Next()
+
+		rw.runBeforeHooks()
+		return err
+	}
+}
+
+// removeCookies removes the Set-Cookie headers setting the named cookies.
+// Removals of those cookies are kept, so that stale variants are cleared.
+func removeCookies(h http.Header, names []string) {
+	values := h.Values("Set-Cookie")
+	if len(values) == 0 {
+		return
+	}
+
+	kept := make([]string, 0, len(values))
+	for _, value := range values {
+		cookie, err := http.ParseSetCookie(value)
+		if err != nil || cookie.MaxAge < 0 || !slices.Contains(names, cookie.Name) {
+			kept = append(kept, value)
+		}
+	}
+
+	if len(kept) == 0 {
+		h.Del("Set-Cookie")
+		return
+	}
+	h["Set-Cookie"] = kept
+}
+
+// PrivacyFrom returns the preference detected by the Privacy middleware.
+func PrivacyFrom(c *ginji.Context) (PrivacyPreference, bool) {
+	state, ok := c.Req.Context().Value(privacyContextKey).(*privacyState)
+	if !ok {
+		return PrivacyPreference{}, false
+	}
+	return state.pref, true
+}
+
+// TrackingAllowed reports whether the client did not opt out of tracking,
+// for analytics and personalization code. It returns true when no Privacy
+// middleware runs.
+func TrackingAllowed(c *ginji.Context) bool {
+	pref, _ := PrivacyFrom(c)
+	return !pref.OptedOut()
+}
+
+// privacyOptOut returns the Privacy configuration if the request opted
+// out, or nil.
+func privacyOptOut(c *ginji.Context) *PrivacyConfig {
+	state, ok := c.Req.Context().Value(privacyContextKey).(*privacyState)
+	if !ok || !state.pref.OptedOut() {
+		return nil
+	}
+	return state.config
+}
@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ginjigo/ginji"
+)
+
+func TestPrivacyDetectsSignals(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  PrivacyConfig
+		headers map[string]string
+		want    PrivacyPreference
+	}{
+		{"none", DefaultPrivacyConfig(), nil, PrivacyPreference{}},
+		{"dnt", DefaultPrivacyConfig(), map[string]string{"DNT": "1"}, PrivacyPreference{DoNotTrack: true}},
+		{"dnt off", DefaultPrivacyConfig(), map[string]string{"DNT": "0"}, PrivacyPreference{}},
+		{"gpc", DefaultPrivacyConfig(), map[string]string{"Sec-GPC": "1"}, PrivacyPreference{GlobalPrivacyControl: true}},
+		{"gpc only", PrivacyConfig{HonorGPC: true}, map[string]string{"DNT": "1"}, PrivacyPreference{}},
+		{"zero config", PrivacyConfig{}, map[string]string{"DNT": "1"}, PrivacyPreference{DoNotTrack: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got PrivacyPreference
+			var allowed bool
+			app := ginji.New()
+			app.Use(PrivacyWithConfig(tt.config))
+			app.Get("/", func(c *ginji.Context) error {
+				got, _ = PrivacyFrom(c)
+				allowed = TrackingAllowed(c)
+				return c.Text(ginji.StatusOK, "ok")
+			})
+
+			req := ginji.NewRequest(app, "GET", "/")
+			for k, v := range tt.headers {
+				req = req.Header(k, v)
+			}
+			req.Do()
+			if got != tt.want || allowed == tt.want.OptedOut() {
+				t.Errorf("Expected %+v, got %+v (tracking allowed: %v)", tt.want, got, allowed)
+			}
+		})
+	}
+}
+
+func TestPrivacyOptOut(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewRecorder(RecorderConfig{Enabled: true})
+
+	app := ginji.New()
+	app.Use(Privacy())
+	app.Use(LoggerWithConfig(LoggerConfig{Logger: slog.New(slog.NewJSONHandler(&buf, nil))}))
+	app.Use(r.Middleware())
+	app.Post("/checkout", func(c *ginji.Context) error {
+		c.SetCookie(&http.Cookie{Name: "canary", Value: CanaryVariantStable})
+		c.SetCookie(&http.Cookie{Name: "session", Value: "s"})
+		return c.Text(ginji.StatusOK, "order placed")
+	})
+
+	send := func(gpc bool) *httptest.ResponseRecorder {
+		buf.Reset()
+		req := httptest.NewRequest("POST", "/checkout?ref=newsletter", strings.NewReader("card=4111"))
+		req.Header.Set("User-Agent", "test-agent")
+		if gpc {
+			req.Header.Set("Sec-GPC", "1")
+		}
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		return w
+	}
+
+	// Opted-out clients get minimal logs, no bodies and no experiment cookie
+	w := send(true)
+	if got := strings.Join(cookieNames(w.Result().Cookies()), ","); got != "session" {
+		t.Errorf("Expected the experiment cookie to be removed, got %q", got)
+	}
+	for _, field := range []string{"test-agent", "newsletter", `"ip"`} {
+		if strings.Contains(buf.String(), field) {
+			t.Errorf("Expected %s to be left out of the log, got %s", field, buf.String())
+		}
+	}
+	if !strings.Contains(buf.String(), `"path":"/checkout"`) {
+		t.Errorf("Expected the path to be logged, got %s", buf.String())
+	}
+	e := r.Entries()[0]
+	if e.Request.Body != "" || e.Response.Body != "" || e.Response.BodySize != len("order placed") {
+		t.Errorf("Expected bodies to be omitted, got %+v", e)
+	}
+
+	// Other clients are unaffected
+	w = send(false)
+	if got := strings.Join(cookieNames(w.Result().Cookies()), ","); got != "canary,session" {
+		t.Errorf("Expected all cookies, got %q", got)
+	}
+	if !strings.Contains(buf.String(), "test-agent") || !strings.Contains(buf.String(), "newsletter") {
+		t.Errorf("Expected a detailed log, got %s", buf.String())
+	}
+	if e := r.Entries()[1]; e.Request.Body != "card=4111" || e.Response.Body != "order placed" {
+		t.Errorf("Expected bodies to be recorded, got %+v", e)
+	}
+}
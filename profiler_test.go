@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"encoding/base64"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ginjigo/ginji"
+)
+
+func profilerRequest(app *ginji.Engine, path string, authorized bool) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, nil)
+	if authorized {
+		req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte("ops:secret")))
+	}
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	return w
+}
+
+func TestProfilerEndpoints(t *testing.T) {
+	config := DefaultProfilerConfig()
+	config.Auth = BasicAuth(map[string]string{"ops": "secret"})
+	app := ginji.New()
+	app.Use(ProfilerWithConfig(config))
+	app.Get("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	w := profilerRequest(app, "/debug/pprof/", false)
+	ginji.AssertStatus(t, w, ginji.StatusUnauthorized)
+
+	w = profilerRequest(app, "/debug/pprof/", true)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	ginji.AssertBody(t, w, "goroutine")
+
+	w = profilerRequest(app, "/debug/pprof/heap?debug=1", true)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	ginji.AssertBody(t, w, "heap profile")
+
+	w = profilerRequest(app, "/debug/vars", true)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	ginji.AssertBody(t, w, "memstats")
+
+	w = profilerRequest(app, "/", false)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+}
+
+func TestProfilerEnableEnv(t *testing.T) {
+	config := DefaultProfilerConfig()
+	config.Prefix = "/ops"
+	config.Auth = BasicAuth(map[string]string{"ops": "secret"})
+	config.EnableEnv = "GINJI_TEST_PROFILER"
+
+	t.Setenv("GINJI_TEST_PROFILER", "")
+	app := ginji.New()
+	app.Use(ProfilerWithConfig(config))
+	w := profilerRequest(app, "/ops/pprof/", true)
+	ginji.AssertStatus(t, w, ginji.StatusNotFound)
+
+	t.Setenv("GINJI_TEST_PROFILER", "true")
+	app = ginji.New()
+	app.Use(ProfilerWithConfig(config))
+	w = profilerRequest(app, "/ops/pprof/", true)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+}
+
+func TestProfilerRequiresAuth(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic without Auth")
+		}
+	}()
+	ProfilerWithConfig(ProfilerConfig{})
+}
@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+
+	"github.com/ginjigo/ginji"
+)
+
+// PushAsset is a critical asset of a page.
+type PushAsset struct {
+	// Path is the asset's absolute path, e.g. "/static/app.css".
+	Path string `json:"path"`
+
+	// As is the preload destination, e.g. "style", "script", "font" or
+	// "image".
+	As string `json:"as"`
+}
+
+// PushConfig defines the configuration for push middleware.
+type PushConfig struct {
+	// Assets maps glob patterns of page paths (see PathGlob) to their
+	// critical assets. The longest matching pattern wins.
+	Assets map[string][]PushAsset
+
+	// Manifest is the path of a JSON file in the same shape as Assets,
+	// loaded when the middleware is created:
+	//
+	//	{
+	//		"/": [{"path": "/static/app.css", "as": "style"}],
+	//		"/blog/*": [{"path": "/static/blog.js", "as": "script"}]
+	//	}
+	//
+	// Its entries are merged with Assets, which take precedence.
+	Manifest string
+
+	// SkipFunc allows skipping the push for certain requests.
+	SkipFunc Skipper
+}
+
+// Push returns middleware that pushes the assets listed in a manifest file.
+func Push(manifest string) ginji.Middleware {
+	return PushWithConfig(PushConfig{Manifest: manifest})
+}
+
+// PushWithConfig returns middleware that, for successful GET requests to the
+// configured pages, pushes the page's critical assets over HTTP/2 server push.
+// Where push is not supported, as over HTTP/1 or when the client disabled
+// it, the assets are announced as Link preload headers instead. Handlers
+// can opt a response out with NoPush.
+//
+// It panics if neither Assets nor Manifest is set, or if the manifest cannot
+// be loaded.
+func PushWithConfig(config PushConfig) ginji.Middleware {
+	assets := config.Assets
+	if config.Manifest != "" {
+		loaded, err := loadPushManifest(config.Manifest)
+		if err != nil {
+			panic("Push: " + err.Error())
+		}
+		for pattern, list := range config.Assets {
+			loaded[pattern] = list
+		}
+		assets = loaded
+	}
+	if len(assets) == 0 {
+		panic("Push: Assets or Manifest is required")
+	}
+	pathAssets := compilePathOverrides(assets)
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		if c.Req.Method != http.MethodGet {
+			return c.Next()
+		}
+		list := lookupPathOverride(c, pathAssets, nil)
+		if len(list) == 0 {
+			return c.Next()
+		}
+
+		rw := WrapResponseWriter(c)
+		rw.Before(func(h http.Header) {
+			if v, exists := c.Get("nopush"); exists {
+				if b, ok := v.(bool); ok && b {
+					return
+				}
+			}
+			// The Content-Type may not be set yet; the configured routes
+			// are taken to be pages unless it says otherwise
+			contentType := h.Get("Content-Type")
+			if rw.Status() != http.StatusOK || (contentType != "" && !isHTML(contentType)) {
+				return
+			}
+
+			pusher := findPusher(rw.ResponseWriter)
+			for _, asset := range list {
+				if pusher != nil && pushAsset(c, pusher, asset) == nil {
+					continue
+				}
+				h.Add("Link", Preload(asset.Path, asset.As))
+			}
+		})
+		return c.Next()
+	}
+}
+
+// NoPush opts the current response out of Push, e.g. for a client that is
+// known to have the assets cached. It must be called before the response
+// status is written.
+func NoPush(c *ginji.Context) {
+	c.Set("nopush", true)
+}
+
+// loadPushManifest reads a push manifest file.
+func loadPushManifest(path string) (map[string][]PushAsset, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var manifest map[string][]PushAsset
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if manifest == nil {
+		manifest = make(map[string][]PushAsset)
+	}
+	return manifest, nil
+}
+
+// pushAsset pushes asset, forwarding the request headers that select the
+// variant of the asset the client will ask for.
+func pushAsset(c *ginji.Context, pusher http.Pusher, asset PushAsset) error {
+	header := make(http.Header)
+	for _, name := range []string{"Accept-Encoding", "Accept-Language", "User-Agent"} {
+		if v := c.Req.Header.Get(name); v != "" {
+			header.Set(name, v)
+		}
+	}
+	return pusher.Push(asset.Path, &http.PushOptions{Header: header})
+}
+
+// findPusher returns the first writer that supports server push, looking
+// through wrapping writers, or nil.
+func findPusher(w http.ResponseWriter) http.Pusher {
+	for w != nil {
+		if p, ok := w.(http.Pusher); ok {
+			return p
+		}
+		w = unwrapResponseWriter(w)
+	}
+	return nil
+}
+
+// isHTML reports whether contentType is an HTML media type.
+func isHTML(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
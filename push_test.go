@@ -0,0 +1,150 @@
+package middleware
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ginjigo/ginji"
+)
+
+// recordingPusher records pushes, failing those of paths in reject.
+type recordingPusher struct {
+	http.ResponseWriter
+	pushed []string
+	header []http.Header
+	reject string
+}
+
+func (p *recordingPusher) Push(target string, opts *http.PushOptions) error {
+	if target == p.reject {
+		return http.ErrNotSupported
+	}
+	p.pushed = append(p.pushed, target)
+	p.header = append(p.header, opts.Header)
+	return nil
+}
+
+func TestPushLinkFallback(t *testing.T) {
+	app := ginji.New()
+	app.Use(PushWithConfig(PushConfig{
+		Assets: map[string][]PushAsset{
+			"/":       {{Path: "/app.css", As: "style"}},
+			"/blog/*": {{Path: "/blog.js", As: "script"}, {Path: "/serif.woff2", As: "font"}},
+			"/api/**": {{Path: "/app.css", As: "style"}},
+		},
+	}))
+	app.Get("/", func(c *ginji.Context) error {
+		return c.HTML(ginji.StatusOK, "<h1>home</h1>")
+	})
+	app.Get("/blog/:slug", func(c *ginji.Context) error {
+		return c.HTML(ginji.StatusOK, "<h1>post</h1>")
+	})
+	app.Get("/missing", func(c *ginji.Context) error {
+		return c.HTML(ginji.StatusNotFound, "<h1>not found</h1>")
+	})
+	app.Get("/api/users", func(c *ginji.Context) error {
+		c.SetHeader("Content-Type", "application/json")
+		return c.Text(ginji.StatusOK, "[]")
+	})
+	app.Get("/cached", func(c *ginji.Context) error {
+		NoPush(c)
+		return c.HTML(ginji.StatusOK, "<h1>cached</h1>")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/blog/hello", nil)
+	links := w.Header().Values("Link")
+	want := []string{"</blog.js>; rel=preload; as=script", "</serif.woff2>; rel=preload; as=font; crossorigin"}
+	if strings.Join(links, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected links %v, got %v", want, links)
+	}
+
+	w = ginji.PerformRequest(app, "GET", "/", nil)
+	ginji.AssertHeader(t, w, "Link", "</app.css>; rel=preload; as=style")
+
+	for _, path := range []string{"/missing", "/api/users", "/cached"} {
+		w = ginji.PerformRequest(app, "GET", path, nil)
+		if links := w.Header().Values("Link"); len(links) != 0 {
+			t.Errorf("Expected no links for %s, got %v", path, links)
+		}
+	}
+	w = ginji.PerformRequest(app, "HEAD", "/", nil)
+	if links := w.Header().Values("Link"); len(links) != 0 {
+		t.Errorf("Expected no links for HEAD, got %v", links)
+	}
+}
+
+func TestPushServerPush(t *testing.T) {
+	pusher := &recordingPusher{reject: "/b.js"}
+	app := ginji.New()
+	app.Use(func(c *ginji.Context) error {
+		pusher.ResponseWriter = c.Res
+		c.Res = pusher
+		return c.Next()
+	})
+	app.Use(PushWithConfig(PushConfig{
+		Assets: map[string][]PushAsset{"/": {{Path: "/a.css", As: "style"}, {Path: "/b.js", As: "script"}}},
+	}))
+	app.Get("/", func(c *ginji.Context) error {
+		return c.HTML(ginji.StatusOK, "<h1>home</h1>")
+	})
+
+	w := ginji.NewRequest(app, "GET", "/").Header("Accept-Encoding", "br").Do()
+
+	if len(pusher.pushed) != 1 || pusher.pushed[0] != "/a.css" {
+		t.Fatalf("Expected /a.css to be pushed, got %v", pusher.pushed)
+	}
+	if pusher.header[0].Get("Accept-Encoding") != "br" {
+		t.Error("Expected Accept-Encoding to be forwarded to the push")
+	}
+	// A failed push falls back to a Link header
+	links := w.Header().Values("Link")
+	if len(links) != 1 || links[0] != "</b.js>; rel=preload; as=script" {
+		t.Errorf("Expected a Link for the rejected push, got %v", links)
+	}
+}
+
+func TestPushManifest(t *testing.T) {
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "push.json")
+	data := `{"/": [{"path": "/app.css", "as": "style"}], "/about": [{"path": "/about.js", "as": "script"}]}`
+	if err := os.WriteFile(manifest, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	app := ginji.New()
+	app.Use(PushWithConfig(PushConfig{
+		Manifest: manifest,
+		Assets:   map[string][]PushAsset{"/about": {{Path: "/override.js", As: "script"}}},
+	}))
+	app.Get("/", func(c *ginji.Context) error {
+		return c.HTML(ginji.StatusOK, "<h1>home</h1>")
+	})
+	app.Get("/about", func(c *ginji.Context) error {
+		return c.HTML(ginji.StatusOK, "<h1>about</h1>")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/", nil)
+	ginji.AssertHeader(t, w, "Link", "</app.css>; rel=preload; as=style")
+	w = ginji.PerformRequest(app, "GET", "/about", nil)
+	ginji.AssertHeader(t, w, "Link", "</override.js>; rel=preload; as=script")
+}
+
+func TestPushPanics(t *testing.T) {
+	tests := map[string]PushConfig{
+		"no assets":        {},
+		"missing manifest": {Manifest: filepath.Join(t.TempDir(), "missing.json")},
+	}
+	for name, config := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("Expected a panic")
+				}
+			}()
+			PushWithConfig(config)
+		})
+	}
+}
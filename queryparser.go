@@ -0,0 +1,360 @@
+package middleware
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// QueryFieldType is the type query values of a field are coerced to.
+type QueryFieldType int
+
+// Query field types.
+const (
+	QueryString QueryFieldType = iota // string
+	QueryInt                          // int64
+	QueryFloat                        // float64
+	QueryBool                         // bool
+	QueryTime                         // time.Time, RFC 3339 or YYYY-MM-DD
+)
+
+// Filter operators.
+const (
+	QueryOpEq       = "eq"
+	QueryOpNe       = "ne"
+	QueryOpGt       = "gt"
+	QueryOpGte      = "gte"
+	QueryOpLt       = "lt"
+	QueryOpLte      = "lte"
+	QueryOpIn       = "in"
+	QueryOpContains = "contains"
+	QueryOpPrefix   = "prefix"
+)
+
+// QueryField describes a field clients may filter or sort by.
+type QueryField struct {
+	// Type is the type filter values are coerced to.
+	// Default: QueryString
+	Type QueryFieldType
+
+	// Column is the name of the field in the data layer, e.g. a database
+	// column, so clients never choose it.
+	// Default: the field name
+	Column string
+
+	// Filter allows filtering by the field.
+	Filter bool
+
+	// Sort allows sorting by the field.
+	Sort bool
+
+	// Operators are the allowed filter operators.
+	// Default: eq, ne, in, contains and prefix for strings; eq and ne for
+	// bools; eq, ne, gt, gte, lt, lte and in otherwise
+	Operators []string
+}
+
+// QueryParserConfig defines the configuration for QueryParser middleware.
+type QueryParserConfig struct {
+	// Fields are the fields clients may filter and sort by. Required.
+	Fields map[string]QueryField
+
+	// DefaultSort is used when the request has no sort parameter, in the
+	// same format, e.g. "-created_at".
+	// Default: "" (unsorted)
+	DefaultSort string
+
+	// MaxFilters is the maximum number of filters per request.
+	// Default: 10
+	MaxFilters int
+
+	// MaxSortFields is the maximum number of sort fields per request.
+	// Default: 3
+	MaxSortFields int
+
+	// MaxInValues is the maximum number of values of an "in" filter.
+	// Default: 100
+	MaxInValues int
+
+	// SkipFunc allows skipping parsing for certain requests.
+	SkipFunc Skipper
+}
+
+// QuerySpec is the validated filter and sort specification of a request.
+type QuerySpec struct {
+	Filters []QueryFilter
+	Sort    []QuerySort
+}
+
+// QueryFilter is a filter condition. Value has the field's type, or is a
+// []any of it for the "in" operator.
+type QueryFilter struct {
+	Field  string
+	Column string
+	Op     string
+	Value  any
+}
+
+// QuerySort is a sort key.
+type QuerySort struct {
+	Field  string
+	Column string
+	Desc   bool
+}
+
+// DefaultQueryParserConfig returns default query parser configuration.
+func DefaultQueryParserConfig() QueryParserConfig {
+	return QueryParserConfig{
+		MaxFilters:    10,
+		MaxSortFields: 3,
+		MaxInValues:   100,
+	}
+}
+
+// QueryParser returns middleware that parses filter and sort parameters
+// for the given fields.
+func QueryParser(fields map[string]QueryField) ginji.Middleware {
+	config := DefaultQueryParserConfig()
+	config.Fields = fields
+	return QueryParserWithConfig(config)
+}
+
+// QueryParserWithConfig returns middleware that parses filter and sort
+// query parameters into a QuerySpec, available to handlers through
+// QuerySpecFrom:
+//
+//	?filter[status]=active&filter[price][lt]=100&filter[tag][in]=a,b&sort=-created_at,name
+//
+// Unknown fields, disallowed operators and values that do not match the
+// field type are rejected with 400. It panics if Fields is empty or
+// DefaultSort is invalid.
+func QueryParserWithConfig(config QueryParserConfig) ginji.Middleware {
+	defaults := DefaultQueryParserConfig()
+	if len(config.Fields) == 0 {
+		panic("QueryParser: Fields is required")
+	}
+	if config.MaxFilters <= 0 {
+		config.MaxFilters = defaults.MaxFilters
+	}
+	if config.MaxSortFields <= 0 {
+		config.MaxSortFields = defaults.MaxSortFields
+	}
+	if config.MaxInValues <= 0 {
+		config.MaxInValues = defaults.MaxInValues
+	}
+
+	config.Fields = compileQueryFields(config.Fields)
+
+	defaultSort, err := parseQuerySort(config, config.DefaultSort)
+	if err != nil {
+		panic("QueryParser: invalid DefaultSort: " + err.Error())
+	}
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		spec, err := parseQuerySpec(config, c.Req.URL.Query())
+		if err != nil {
+			c.AbortWithStatusJSON(ginji.StatusBadRequest, ginji.H{
+				"error": "Invalid query: " + err.Error(),
+			})
+			return nil
+		}
+		if spec.Sort == nil {
+			spec.Sort = defaultSort
+		}
+
+		setContextValue(c, querySpecContextKey, spec)
+		return c.Next()
+	}
+}
+
+// QuerySpecFrom returns the QuerySpec parsed by QueryParser.
+func QuerySpecFrom(c *ginji.Context) (*QuerySpec, bool) {
+	spec, ok := c.Req.Context().Value(querySpecContextKey).(*QuerySpec)
+	return spec, ok
+}
+
+// parseQuerySpec parses the filter and sort parameters of query.
+func parseQuerySpec(config QueryParserConfig, query map[string][]string) (*QuerySpec, error) {
+	spec := &QuerySpec{}
+
+	for key, values := range query {
+		name, op, ok, err := parseFilterKey(key)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		field, ok := config.Fields[name]
+		if !ok || !field.Filter {
+			return nil, fmt.Errorf("unknown filter field %q", name)
+		}
+		if !slices.Contains(field.Operators, op) {
+			return nil, fmt.Errorf("operator %q is not allowed for field %q", op, name)
+		}
+
+		for _, raw := range values {
+			value, err := coerceQueryFilter(config, field, op, raw)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value for filter %q: %v", name, err)
+			}
+			spec.Filters = append(spec.Filters, QueryFilter{Field: name, Column: field.Column, Op: op, Value: value})
+		}
+		if len(spec.Filters) > config.MaxFilters {
+			return nil, fmt.Errorf("too many filters, the maximum is %d", config.MaxFilters)
+		}
+	}
+
+	// Query parameters are unordered; sort filters for stable output
+	sort.SliceStable(spec.Filters, func(i, j int) bool {
+		if spec.Filters[i].Field != spec.Filters[j].Field {
+			return spec.Filters[i].Field < spec.Filters[j].Field
+		}
+		return spec.Filters[i].Op < spec.Filters[j].Op
+	})
+
+	if values, ok := query["sort"]; ok {
+		sorts, err := parseQuerySort(config, strings.Join(values, ","))
+		if err != nil {
+			return nil, err
+		}
+		spec.Sort = sorts
+	}
+	return spec, nil
+}
+
+// parseFilterKey parses a filter key, "filter[field]" or
+// "filter[field][op]", and reports whether key is a filter at all.
+func parseFilterKey(key string) (name, op string, ok bool, err error) {
+	rest, ok := strings.CutPrefix(key, "filter[")
+	if !ok {
+		return "", "", false, nil
+	}
+	name, rest, ok = strings.Cut(rest, "]")
+	if !ok || name == "" {
+		return "", "", true, fmt.Errorf("malformed filter parameter %q", key)
+	}
+	if rest == "" {
+		return name, QueryOpEq, true, nil
+	}
+	if len(rest) < 3 || rest[0] != '[' || rest[len(rest)-1] != ']' || strings.ContainsAny(rest[1:len(rest)-1], "[]") {
+		return "", "", true, fmt.Errorf("malformed filter parameter %q", key)
+	}
+	return name, rest[1 : len(rest)-1], true, nil
+}
+
+// parseQuerySort parses a comma-separated list of fields, each optionally
+// prefixed with "-" for descending order.
+func parseQuerySort(config QueryParserConfig, value string) ([]QuerySort, error) {
+	if value == "" {
+		return nil, nil
+	}
+	parts := strings.Split(value, ",")
+	if len(parts) > config.MaxSortFields {
+		return nil, fmt.Errorf("too many sort fields, the maximum is %d", config.MaxSortFields)
+	}
+
+	sorts := make([]QuerySort, 0, len(parts))
+	for _, part := range parts {
+		name, desc := strings.CutPrefix(strings.TrimSpace(part), "-")
+		field, ok := config.Fields[name]
+		if !ok || !field.Sort {
+			return nil, fmt.Errorf("unknown sort field %q", name)
+		}
+		sorts = append(sorts, QuerySort{Field: name, Column: field.Column, Desc: desc})
+	}
+	return sorts, nil
+}
+
+// coerceQueryFilter converts the raw value of a filter to the field type.
+func coerceQueryFilter(config QueryParserConfig, field QueryField, op, raw string) (any, error) {
+	if op != QueryOpIn {
+		return coerceQueryValue(field.Type, raw)
+	}
+
+	parts := strings.Split(raw, ",")
+	if len(parts) > config.MaxInValues {
+		return nil, fmt.Errorf("more than %d values", config.MaxInValues)
+	}
+	values := make([]any, len(parts))
+	for i, part := range parts {
+		value, err := coerceQueryValue(field.Type, part)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// coerceQueryValue converts raw to typ.
+func coerceQueryValue(typ QueryFieldType, raw string) (any, error) {
+	switch typ {
+	case QueryInt:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer", raw)
+		}
+		return n, nil
+	case QueryFloat:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", raw)
+		}
+		return f, nil
+	case QueryBool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a boolean", raw)
+		}
+		return b, nil
+	case QueryTime:
+		if t, err := time.Parse(time.RFC3339, raw); err == nil {
+			return t, nil
+		}
+		t, err := time.Parse(time.DateOnly, raw)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a date or RFC 3339 time", raw)
+		}
+		return t, nil
+	default:
+		return raw, nil
+	}
+}
+
+// compileQueryFields returns a copy of fields with defaults filled in.
+func compileQueryFields(fields map[string]QueryField) map[string]QueryField {
+	compiled := make(map[string]QueryField, len(fields))
+	for name, field := range fields {
+		if field.Column == "" {
+			field.Column = name
+		}
+		if len(field.Operators) == 0 {
+			field.Operators = defaultQueryOperators(field.Type)
+		}
+		compiled[name] = field
+	}
+	return compiled
+}
+
+// defaultQueryOperators returns the operators allowed by default for typ.
+func defaultQueryOperators(typ QueryFieldType) []string {
+	switch typ {
+	case QueryString:
+		return []string{QueryOpEq, QueryOpNe, QueryOpIn, QueryOpContains, QueryOpPrefix}
+	case QueryBool:
+		return []string{QueryOpEq, QueryOpNe}
+	default:
+		return []string{QueryOpEq, QueryOpNe, QueryOpGt, QueryOpGte, QueryOpLt, QueryOpLte, QueryOpIn}
+	}
+}
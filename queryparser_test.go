@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+func testQueryFields() map[string]QueryField {
+	return map[string]QueryField{
+		"status":     {Filter: true, Sort: true},
+		"price":      {Type: QueryFloat, Filter: true, Sort: true},
+		"stock":      {Type: QueryInt, Filter: true, Operators: []string{QueryOpGt}},
+		"active":     {Type: QueryBool, Filter: true},
+		"created_at": {Type: QueryTime, Column: "created", Filter: true, Sort: true},
+		"secret":     {},
+	}
+}
+
+func TestQueryParser(t *testing.T) {
+	config := DefaultQueryParserConfig()
+	config.Fields = testQueryFields()
+	config.DefaultSort = "-created_at"
+	app := ginji.New()
+	app.Use(QueryParserWithConfig(config))
+	app.Get("/products", func(c *ginji.Context) error {
+		spec, _ := QuerySpecFrom(c)
+		return c.JSON(ginji.StatusOK, spec)
+	})
+
+	w := ginji.PerformRequest(app, "GET",
+		"/products?filter[status]=active&filter[price][lt]=9.5&filter[stock][gt]=0&filter[status][in]=a,b&filter[active]=true&sort=-price,status&page=2", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+
+	var spec QuerySpec
+	if err := json.Unmarshal(w.Body.Bytes(), &spec); err != nil {
+		t.Fatal(err)
+	}
+	want := QuerySpec{
+		Filters: []QueryFilter{
+			{Field: "active", Column: "active", Op: "eq", Value: true},
+			{Field: "price", Column: "price", Op: "lt", Value: 9.5},
+			{Field: "status", Column: "status", Op: "eq", Value: "active"},
+			{Field: "status", Column: "status", Op: "in", Value: []any{"a", "b"}},
+			{Field: "stock", Column: "stock", Op: "gt", Value: float64(0)},
+		},
+		Sort: []QuerySort{
+			{Field: "price", Column: "price", Desc: true},
+			{Field: "status", Column: "status"},
+		},
+	}
+	if !reflect.DeepEqual(spec, want) {
+		t.Errorf("Expected %+v, got %+v", want, spec)
+	}
+}
+
+func TestQueryParserTypes(t *testing.T) {
+	config := DefaultQueryParserConfig()
+	config.Fields = compileQueryFields(testQueryFields())
+
+	spec, err := parseQuerySpec(config, map[string][]string{
+		"filter[stock][gt]":       {"3"},
+		"filter[created_at][gte]": {"2024-05-01"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Filters[0].Value != time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC) || spec.Filters[0].Column != "created" {
+		t.Errorf("Expected date filter on created column, got %+v", spec.Filters[0])
+	}
+	if spec.Filters[1].Value != int64(3) {
+		t.Errorf("Expected int64 value, got %T", spec.Filters[1].Value)
+	}
+}
+
+func TestQueryParserDefaultSort(t *testing.T) {
+	config := DefaultQueryParserConfig()
+	config.Fields = testQueryFields()
+	config.DefaultSort = "-created_at"
+	app := ginji.New()
+	app.Use(QueryParserWithConfig(config))
+	app.Get("/products", func(c *ginji.Context) error {
+		spec, _ := QuerySpecFrom(c)
+		return c.JSON(ginji.StatusOK, spec)
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/products", nil)
+	ginji.AssertBody(t, w, `"Sort":[{"Field":"created_at","Column":"created","Desc":true}]`)
+}
+
+func TestQueryParserRejects(t *testing.T) {
+	config := DefaultQueryParserConfig()
+	config.Fields = testQueryFields()
+	config.MaxSortFields = 2
+	config.MaxInValues = 2
+	app := ginji.New()
+	app.Use(QueryParserWithConfig(config))
+	app.Get("/products", func(c *ginji.Context) error {
+		spec, _ := QuerySpecFrom(c)
+		return c.JSON(ginji.StatusOK, spec)
+	})
+
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"unknown filter", "filter[owner]=me", `unknown filter field \"owner\"`},
+		{"not filterable", "filter[secret]=x", `unknown filter field \"secret\"`},
+		{"operator not allowed", "filter[stock][lt]=5", `operator \"lt\" is not allowed`},
+		{"wrong type", "filter[price]=cheap", `\"cheap\" is not a number`},
+		{"bad bool", "filter[active]=maybe", "is not a boolean"},
+		{"bad time", "filter[created_at]=yesterday", "is not a date"},
+		{"malformed", "filter[status][eq=x", "malformed filter parameter"},
+		{"nested", "filter[status][eq][x]=y", "malformed filter parameter"},
+		{"empty field", "filter[]=x", "malformed filter parameter"},
+		{"unknown sort", "sort=secret", `unknown sort field \"secret\"`},
+		{"too many sorts", "sort=status,price,created_at", "too many sort fields"},
+		{"too many in values", "filter[status][in]=a,b,c", "more than 2 values"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := ginji.PerformRequest(app, "GET", "/products?"+tt.query, nil)
+			ginji.AssertStatus(t, w, ginji.StatusBadRequest)
+			ginji.AssertBody(t, w, tt.want)
+		})
+	}
+}
+
+func TestQueryParserInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config QueryParserConfig
+	}{
+		{"no fields", QueryParserConfig{}},
+		{"bad default sort", QueryParserConfig{Fields: testQueryFields(), DefaultSort: "secret"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("Expected panic")
+				}
+			}()
+			QueryParserWithConfig(tt.config)
+		})
+	}
+}
@@ -0,0 +1,7 @@
+//go:build race
+
+package middleware
+
+// raceEnabled reports whether tests run with the race detector, which
+// allocates on its own and makes allocation counts unreliable.
+const raceEnabled = true
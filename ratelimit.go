@@ -1,10 +1,18 @@
 package middleware
 
 import (
+	"cmp"
+	"container/list"
+	"errors"
 	"fmt"
+	"math"
+	"net"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ginjigo/ginji"
@@ -19,8 +27,10 @@ type RateLimiterConfig struct {
 	Window time.Duration
 
 	// KeyFunc is a function that returns the key for rate limiting.
-	// Common keys: IP address, user ID, API key.
-	// Default: uses client IP
+	// Common keys: IP address, user ID, API key. Requests for which it
+	// returns an empty string are limited by client IP.
+	// Default: uses client IP, read from X-Forwarded-For for requests from
+	// TrustedProxies
 	KeyFunc func(*ginji.Context) string
 
 	// ErrorMessage is returned when rate limit is exceeded.
@@ -31,29 +41,267 @@ type RateLimiterConfig struct {
 	StatusCode int
 
 	// SkipFunc allows skipping rate limiting for certain requests.
-	SkipFunc func(*ginji.Context) bool
+	SkipFunc Skipper
 
 	// Headers determines whether to add rate limit headers to the response.
 	Headers bool
 
+	// HeaderStyle selects which rate limit headers are sent when Headers
+	// is true.
+	// Default: RateLimitHeadersLegacy
+	HeaderStyle RateLimitHeaderStyle
+
 	// TrustedProxies is a list of trusted proxy IP addresses.
 	// If empty, X-Forwarded-For headers are not trusted.
 	TrustedProxies []string
+
+	// Routes overrides Max and Window for request paths matching a glob
+	// pattern (see PathGlob). Each route has its own buckets; when several
+	// patterns match, the longest one wins.
+	Routes map[string]RouteLimit
+
+	// CostFunc returns the number of tokens a request consumes, so
+	// expensive endpoints can count as several requests.
+	// Default: 1 per request
+	CostFunc func(*ginji.Context) int
+
+	// MaxKeys caps the number of tracked keys. When full, the least recently
+	// used key is evicted, bounding memory under key-spraying traffic. Large
+	// caps are split across lock shards, so eviction order is approximate.
+	// Default: 0 (unlimited)
+	MaxKeys int
+
+	// Name identifies the limiter when it is registered as a plugin.
+	// Default: "ratelimit"
+	Name string
+
+	// Policies are additional limits enforced alongside Max and Window,
+	// e.g. a global limit and a per-API-key limit. A request must pass every
+	// policy; the most restrictive one is reported in headers.
+	Policies []RateLimitPolicy
+
+	// Allowlist exempts matching requests from rate limiting entirely.
+	Allowlist RateLimitList
+
+	// Denylist rejects matching requests with 403 Forbidden before any
+	// limit is checked. It takes precedence over Allowlist.
+	Denylist RateLimitList
+
+	// Penalty bans keys that keep exceeding the limit.
+	// Default: no bans
+	Penalty RateLimitPenalty
+
+	// APIKeyHeader is the header holding the API key matched against
+	// Allowlist.APIKeys and Denylist.APIKeys.
+	// Default: "X-API-Key"
+	APIKeyHeader string
+
+	// OnError is called when a dynamic limiter (see NewLimiterDynamic)
+	// reads a configuration with invalid Allowlist or Denylist entries,
+	// once per change of the lists. The invalid entries are ignored.
+	// Optional.
+	OnError func(error)
+
+	// allow and deny are the parsed lists.
+	allow, deny rateLimitMatcher
+}
+
+// RateLimitPenalty bans keys that exceed the limit too often. Requests
+// from banned keys are rejected without touching the buckets.
+type RateLimitPenalty struct {
+	// Violations is the number of denied requests within Window after
+	// which the key is banned. Only denials by the key's own buckets
+	// count; those by policies keyed otherwise, e.g. GlobalRateLimitKey,
+	// do not.
+	// Default: 0 (no bans)
+	Violations int
+
+	// Window is the period in which violations are counted.
+	// Default: the limiter's Window
+	Window time.Duration
+
+	// Duration is how long a key stays banned.
+	// Default: 15 minutes
+	Duration time.Duration
+
+	// StatusCode is the HTTP status code for banned keys, e.g. 403.
+	// Default: the limiter's StatusCode
+	StatusCode int
+
+	// OnBan is called when a key is banned. Optional.
+	OnBan func(key string, until time.Time)
+}
+
+// rateLimitViolations counts the denied requests of a key.
+type rateLimitViolations struct {
+	count int
+	start time.Time
+}
+
+// RateLimitList matches requests by client IP, API key or user ID.
+type RateLimitList struct {
+	// IPs lists IP addresses or CIDR ranges, matched against the client IP
+	// resolved with TrustedProxies. NewLimiter panics on invalid entries;
+	// dynamic limiters ignore them and report them to OnError.
+	IPs []string
+
+	// APIKeys lists API keys, matched against the APIKeyHeader header.
+	APIKeys []string
+
+	// UserIDs lists user IDs, matched against the authenticated user set
+	// with SetUser or the "sub" claim of JWT claims.
+	UserIDs []string
+}
+
+// rateLimitMatcher is a parsed RateLimitList.
+type rateLimitMatcher struct {
+	nets    []*net.IPNet
+	apiKeys map[string]struct{}
+	userIDs map[string]struct{}
+}
+
+// newRateLimitMatcher parses list. Invalid IP entries are reported but the
+// valid ones are kept.
+func newRateLimitMatcher(list RateLimitList) (rateLimitMatcher, error) {
+	nets, err := parseIPNets(list.IPs)
+	m := rateLimitMatcher{nets: nets}
+	if len(list.APIKeys) > 0 {
+		m.apiKeys = make(map[string]struct{}, len(list.APIKeys))
+		for _, key := range list.APIKeys {
+			m.apiKeys[key] = struct{}{}
+		}
+	}
+	if len(list.UserIDs) > 0 {
+		m.userIDs = make(map[string]struct{}, len(list.UserIDs))
+		for _, id := range list.UserIDs {
+			m.userIDs[id] = struct{}{}
+		}
+	}
+	return m, err
+}
+
+// empty reports whether the matcher matches nothing.
+func (m *rateLimitMatcher) empty() bool {
+	return len(m.nets) == 0 && len(m.apiKeys) == 0 && len(m.userIDs) == 0
+}
+
+// match reports whether the request matches an entry.
+func (m *rateLimitMatcher) match(c *ginji.Context, config *RateLimiterConfig) bool {
+	if len(m.nets) > 0 {
+		if ip := net.ParseIP(clientIP(c.Req, config.TrustedProxies)); ip != nil && ipInNets(ip, m.nets) {
+			return true
+		}
+	}
+	if len(m.apiKeys) > 0 {
+		if key := c.Req.Header.Get(config.APIKeyHeader); key != "" {
+			if _, ok := m.apiKeys[key]; ok {
+				return true
+			}
+		}
+	}
+	if len(m.userIDs) > 0 {
+		if id := defaultPolicySubject(c); id != "" {
+			if _, ok := m.userIDs[id]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RateLimitPolicy is a named limit with its own key, such as a global
+// limit or a per-API-key limit.
+type RateLimitPolicy struct {
+	// Name identifies the policy in rate limit responses. Required.
+	Name string
+
+	// Max is the maximum number of requests allowed in the time window.
+	// Default: the limiter's Max
+	Max int
+
+	// Window is the time window for rate limiting.
+	// Default: the limiter's Window
+	Window time.Duration
+
+	// KeyFunc returns the key to limit by. Requests for which it returns
+	// an empty string are not subject to the policy.
+	// Default: GlobalRateLimitKey
+	KeyFunc func(*ginji.Context) string
+}
+
+// GlobalRateLimitKey puts every request in the same bucket, for policies
+// that limit total throughput.
+func GlobalRateLimitKey(*ginji.Context) string {
+	return "global"
+}
+
+// rateLimitCheck is one limit evaluated for a request.
+type rateLimitCheck struct {
+	policy    string
+	key       string
+	client    bool // the bucket belongs to the requesting client
+	limit     RouteLimit
+	allowed   bool
+	remaining int
+	resetTime time.Time
+}
+
+// RateLimitHeaderStyle selects the rate limit response headers.
+type RateLimitHeaderStyle int
+
+const (
+	// RateLimitHeadersLegacy sends X-RateLimit-Limit, X-RateLimit-Remaining
+	// and X-RateLimit-Reset, with Reset as a Unix timestamp.
+	RateLimitHeadersLegacy RateLimitHeaderStyle = iota
+
+	// RateLimitHeadersStandard sends the IETF draft RateLimit-Limit,
+	// RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy headers,
+	// with Reset in seconds from now.
+	RateLimitHeadersStandard
+
+	// RateLimitHeadersBoth sends both sets of headers.
+	RateLimitHeadersBoth
+)
+
+// RouteLimit is a route-scoped rate limit. Zero fields inherit the
+// limiter's Max and Window.
+type RouteLimit struct {
+	Max    int
+	Window time.Duration
 }
 
 // bucket represents a token bucket for rate limiting.
+// It is guarded by the mutex of the shard holding it.
 type bucket struct {
+	key       string
 	tokens    int
+	window    time.Duration
 	lastReset time.Time
-	mu        sync.Mutex
 }
 
-// rateLimiter manages rate limiting buckets.
-type rateLimiter struct {
-	buckets   map[string]*bucket
-	mu        sync.RWMutex
-	config    RateLimiterConfig
+// maxLimiterShards is the number of bucket shards used by large limiters.
+const maxLimiterShards = 64
+
+// limiterShard holds the buckets for a subset of keys, so requests for
+// different keys rarely contend on the same lock.
+type limiterShard struct {
+	mu      sync.Mutex
+	buckets map[string]*list.Element
+	lru     *list.List // Most recently used bucket first
+}
+
+// Limiter is a rate limiter whose buckets are cleaned up by a background
+// goroutine. Close stops the goroutine; registering the limiter as a plugin
+// with app.UsePlugin closes it when the engine shuts down.
+type Limiter struct {
+	shards    []*limiterShard
+	config    func() RateLimiterConfig
 	cleanupCh chan struct{} // Channel to signal cleanup goroutine to stop
+	closeOnce sync.Once
+
+	penaltyMu  sync.Mutex
+	violations map[string]*rateLimitViolations
+	bans       map[string]time.Time // Ban expiry by key
 }
 
 // DefaultRateLimiterConfig returns default rate limiter configuration.
@@ -61,7 +309,6 @@ func DefaultRateLimiterConfig() RateLimiterConfig {
 	return RateLimiterConfig{
 		Max:          100,
 		Window:       time.Minute,
-		KeyFunc:      defaultKeyFunc,
 		ErrorMessage: "",
 		StatusCode:   http.StatusTooManyRequests,
 		Headers:      true,
@@ -116,7 +363,52 @@ func RateLimit(max int, window time.Duration) ginji.Middleware {
 }
 
 // RateLimitWithConfig returns a rate limiter middleware with custom configuration.
+// Its cleanup goroutine runs for the life of the process; use NewLimiter for
+// limiters that need to be closed.
 func RateLimitWithConfig(config RateLimiterConfig) ginji.Middleware {
+	return NewLimiter(config).Middleware()
+}
+
+// RateLimitDynamic returns a rate limiter middleware that reads its
+// configuration from configFunc on every request, so limits can be changed
+// at runtime. Buckets are kept across changes. configFunc must be safe for
+// concurrent use. Pass a Reloader's Load method
+// to update the configuration from a file watcher or admin endpoint:
+//
+//	limits := middleware.NewReloader(middleware.DefaultRateLimiterConfig())
+//	app.Use(middleware.RateLimitDynamic(limits.Load))
+func RateLimitDynamic(configFunc func() RateLimiterConfig) ginji.Middleware {
+	return NewLimiterDynamic(configFunc).Middleware()
+}
+
+// RateLimitStats describes the state of a rate limiter.
+type RateLimitStats struct {
+	// Buckets is the number of tracked keys.
+	Buckets int `json:"buckets"`
+
+	// Exhausted is the number of keys currently being limited.
+	Exhausted int `json:"exhausted"`
+
+	// Banned is the number of keys currently banned by the Penalty.
+	Banned int `json:"banned"`
+
+	// Limit is the maximum number of requests per window.
+	Limit int `json:"limit"`
+
+	// Window is the rate limiting window.
+	Window string `json:"window"`
+}
+
+// RateLimitWithStats returns a rate limiter middleware along with a
+// StatsProvider reporting its bucket counts, e.g. for AdminEndpoints.
+func RateLimitWithStats(config RateLimiterConfig) (ginji.Middleware, StatsProvider) {
+	limiter := NewLimiter(config)
+	return limiter.Middleware(), limiter
+}
+
+// normalizeRateLimiterConfig fills in defaults for unset fields, taking the
+// parsed lists and default key function from lists.
+func normalizeRateLimiterConfig(config RateLimiterConfig, lists *rateLimitLists) RateLimiterConfig {
 	// Set defaults
 	if config.Max <= 0 {
 		config.Max = 100
@@ -125,56 +417,243 @@ func RateLimitWithConfig(config RateLimiterConfig) ginji.Middleware {
 		config.Window = time.Minute
 	}
 	if config.KeyFunc == nil {
-		config.KeyFunc = defaultKeyFunc
+		config.KeyFunc = lists.keyFunc
 	}
 	if config.StatusCode == 0 {
 		config.StatusCode = http.StatusTooManyRequests
 	}
-	if config.ErrorMessage == "" {
-		config.ErrorMessage = fmt.Sprintf("Rate limit exceeded. Maximum %d requests per %v", config.Max, config.Window)
+	if config.Name == "" {
+		config.Name = "ratelimit"
+	}
+	if config.APIKeyHeader == "" {
+		config.APIKeyHeader = "X-API-Key"
+	}
+	config.allow, config.deny = lists.allow, lists.deny
+	return config
+}
+
+// rateLimitLists holds what is derived from the Allowlist, Denylist and
+// TrustedProxies of a configuration, so dynamic limiters only rebuild it
+// when they change.
+type rateLimitLists struct {
+	allowlist, denylist RateLimitList
+	trustedProxies      []string
+	allow, deny         rateLimitMatcher
+	keyFunc             func(*ginji.Context) string
+}
+
+// newRateLimitLists parses the lists of config, keeping the valid entries
+// like newRateLimitMatcher.
+func newRateLimitLists(config RateLimiterConfig) (*rateLimitLists, error) {
+	lists := &rateLimitLists{
+		allowlist:      config.Allowlist.clone(),
+		denylist:       config.Denylist.clone(),
+		trustedProxies: slices.Clone(config.TrustedProxies),
+		keyFunc:        defaultKeyFunc,
 	}
-	// Setup key function with trusted proxies if configured
 	if len(config.TrustedProxies) > 0 {
-		// Override the default key function to use trusted proxy validation
-		config.KeyFunc = keyFuncWithTrustedProxies(config.TrustedProxies)
+		lists.keyFunc = keyFuncWithTrustedProxies(lists.trustedProxies)
+	}
+	var allowErr, denyErr error
+	lists.allow, allowErr = newRateLimitMatcher(config.Allowlist)
+	lists.deny, denyErr = newRateLimitMatcher(config.Denylist)
+	return lists, errors.Join(allowErr, denyErr)
+}
+
+// current reports whether lists were derived from the lists of config.
+func (l *rateLimitLists) current(config *RateLimiterConfig) bool {
+	return l.allowlist.equal(config.Allowlist) &&
+		l.denylist.equal(config.Denylist) &&
+		slices.Equal(l.trustedProxies, config.TrustedProxies)
+}
+
+// clone returns a copy of l that does not share its slices.
+func (l RateLimitList) clone() RateLimitList {
+	return RateLimitList{
+		IPs:     slices.Clone(l.IPs),
+		APIKeys: slices.Clone(l.APIKeys),
+		UserIDs: slices.Clone(l.UserIDs),
+	}
+}
+
+// equal reports whether l and other list the same entries.
+func (l RateLimitList) equal(other RateLimitList) bool {
+	return slices.Equal(l.IPs, other.IPs) &&
+		slices.Equal(l.APIKeys, other.APIKeys) &&
+		slices.Equal(l.UserIDs, other.UserIDs)
+}
+
+// NewLimiter creates a rate limiter and starts its cleanup goroutine.
+//
+//	limiter := middleware.NewLimiter(config)
+//	app.UsePlugin(limiter) // Closed by app.StopPlugins on shutdown
+//	app.Use(limiter.Middleware())
+func NewLimiter(config RateLimiterConfig) *Limiter {
+	lists, err := newRateLimitLists(config)
+	if err != nil {
+		panic("RateLimit: " + err.Error())
+	}
+	config = normalizeRateLimiterConfig(config, lists)
+	return newLimiter(func() RateLimiterConfig { return config })
+}
+
+// NewLimiterDynamic creates a rate limiter that reads its configuration from
+// configFunc, like RateLimitDynamic. The lists are parsed again only when
+// they change; invalid entries are reported to OnError.
+func NewLimiterDynamic(configFunc func() RateLimiterConfig) *Limiter {
+	var cached atomic.Pointer[rateLimitLists]
+	return newLimiter(func() RateLimiterConfig {
+		config := configFunc()
+		lists := cached.Load()
+		if lists == nil || !lists.current(&config) {
+			parsed, err := newRateLimitLists(config)
+			// Report each change once, even when requests race to parse it
+			if cached.CompareAndSwap(lists, parsed) && err != nil && config.OnError != nil {
+				config.OnError(fmt.Errorf("ratelimit: %w", err))
+			}
+			lists = parsed
+		}
+		return normalizeRateLimiterConfig(config, lists)
+	})
+}
+
+// newLimiter creates a limiter and starts its cleanup goroutine.
+func newLimiter(config func() RateLimiterConfig) *Limiter {
+	// Small key caps use fewer shards so eviction stays close to exact LRU
+	shards := maxLimiterShards
+	if maxKeys := config().MaxKeys; maxKeys > 0 {
+		shards = min(max(maxKeys/256, 1), maxLimiterShards)
 	}
 
-	limiter := &rateLimiter{
-		buckets:   make(map[string]*bucket),
-		config:    config,
-		cleanupCh: make(chan struct{}),
+	limiter := &Limiter{
+		shards:     make([]*limiterShard, shards),
+		config:     config,
+		cleanupCh:  make(chan struct{}),
+		violations: make(map[string]*rateLimitViolations),
+		bans:       make(map[string]time.Time),
+	}
+	for i := range limiter.shards {
+		limiter.shards[i] = &limiterShard{
+			buckets: make(map[string]*list.Element),
+			lru:     list.New(),
+		}
 	}
 
 	// Start cleanup goroutine with proper lifecycle management
 	go limiter.cleanup()
 
+	return limiter
+}
+
+// Middleware returns the rate limiting middleware. Every middleware returned
+// shares the limiter's buckets.
+func (rl *Limiter) Middleware() ginji.Middleware {
 	return func(c *ginji.Context) error {
+		config := rl.config()
+
 		// Skip if skip function returns true
 		if config.SkipFunc != nil && config.SkipFunc(c) {
 			return c.Next()
 		}
 
-		// Get the key for this request
-		key := config.KeyFunc(c)
+		// Denied requests are rejected and allowed ones bypass the limits
+		if !config.deny.empty() && config.deny.match(c, &config) {
+			c.AbortWithStatusJSON(http.StatusForbidden, ginji.H{
+				"error": "Access denied",
+			})
+			return nil
+		}
+		if !config.allow.empty() && config.allow.match(c, &config) {
+			return c.Next()
+		}
+
+		// Get the key and limit for this request
+		key := keyOrIP(c, config.KeyFunc(c), config.TrustedProxies)
+		clientKey := key
+		if config.Penalty.Violations > 0 {
+			if until, banned := rl.banned(clientKey, time.Now()); banned {
+				return rejectBanned(c, &config, until)
+			}
+		}
+		limit := RouteLimit{Max: config.Max, Window: config.Window}
+		if pattern, route, ok := matchRouteLimit(config.Routes, c.Req.URL.Path); ok {
+			key += "|" + pattern
+			if route.Max > 0 {
+				limit.Max = route.Max
+			}
+			if route.Window > 0 {
+				limit.Window = route.Window
+			}
+		}
 
-		// Check rate limit
-		allowed, remaining, resetTime := limiter.allow(key)
+		cost := 1
+		if config.CostFunc != nil {
+			cost = max(config.CostFunc(c), 0)
+		}
+
+		checks := make([]rateLimitCheck, 1, 1+len(config.Policies))
+		checks[0] = rateLimitCheck{policy: config.Name, key: key, client: true, limit: limit}
+		for _, policy := range config.Policies {
+			keyFunc := policy.KeyFunc
+			if keyFunc == nil {
+				keyFunc = GlobalRateLimitKey
+			}
+			policyKey := keyFunc(c)
+			if policyKey == "" {
+				continue
+			}
+			checks = append(checks, rateLimitCheck{
+				policy: policy.Name,
+				key:    "policy:" + policy.Name + "|" + policyKey,
+				client: policyKey == clientKey,
+				limit:  RouteLimit{Max: cmp.Or(max(policy.Max, 0), config.Max), Window: cmp.Or(policy.Window, config.Window)},
+			})
+		}
+
+		// Check every limit; tokens taken before a denial are returned
+		allowed, evaluated := true, checks
+		for i := range checks {
+			check := &checks[i]
+			check.allowed, check.remaining, check.resetTime = rl.allow(check.key, check.limit, cost, config.MaxKeys)
+			if !check.allowed {
+				allowed = false
+				for _, taken := range checks[:i] {
+					rl.refund(taken.key, cost)
+				}
+				evaluated = checks[:i+1]
+				break
+			}
+		}
+		reported := mostRestrictive(evaluated)
 
 		// Add rate limit headers if enabled
 		if config.Headers {
-			c.SetHeader("X-RateLimit-Limit", fmt.Sprintf("%d", config.Max))
-			c.SetHeader("X-RateLimit-Remaining", fmt.Sprintf("%d", remaining))
-			c.SetHeader("X-RateLimit-Reset", fmt.Sprintf("%d", resetTime.Unix()))
+			setRateLimitHeaders(c, config.HeaderStyle, reported, checks)
 		}
 
 		if !allowed {
-			c.SetHeader("Retry-After", fmt.Sprintf("%d", int(time.Until(resetTime).Seconds())))
-			c.AbortWithStatusJSON(config.StatusCode, ginji.H{
-				"error":   config.ErrorMessage,
-				"limit":   config.Max,
-				"window":  config.Window.String(),
-				"retryAt": resetTime.Format(time.RFC3339),
-			})
+			// Denials by shared buckets, such as a global policy, are not
+			// the client's violations
+			if config.Penalty.Violations > 0 && evaluated[len(evaluated)-1].client {
+				if until, banned := rl.violate(clientKey, config, time.Now()); banned {
+					return rejectBanned(c, &config, until)
+				}
+			}
+			message := config.ErrorMessage
+			if message == "" {
+				message = fmt.Sprintf("Rate limit exceeded. Maximum %d requests per %v", reported.limit.Max, reported.limit.Window)
+			}
+			body := ginji.H{
+				"error":   message,
+				"limit":   reported.limit.Max,
+				"window":  reported.limit.Window.String(),
+				"retryAt": reported.resetTime.Format(time.RFC3339),
+			}
+			if len(config.Policies) > 0 {
+				body["policy"] = reported.policy
+			}
+			c.SetHeader("Retry-After", strconv.FormatInt(secondsUntil(reported.resetTime), 10))
+			c.AbortWithStatusJSON(config.StatusCode, body)
 			return nil // Changed return to nil as AbortWithStatusJSON handles the response
 		}
 
@@ -182,61 +661,334 @@ func RateLimitWithConfig(config RateLimiterConfig) ginji.Middleware {
 	}
 }
 
-// allow checks if a request is allowed and returns the remaining count and reset time.
-func (rl *rateLimiter) allow(key string) (bool, int, time.Time) {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
+// rejectBanned responds to a request from a banned key.
+func rejectBanned(c *ginji.Context, config *RateLimiterConfig, until time.Time) error {
+	c.SetHeader("Retry-After", strconv.FormatInt(secondsUntil(until), 10))
+	c.AbortWithStatusJSON(cmp.Or(config.Penalty.StatusCode, config.StatusCode), ginji.H{
+		"error":       "Too many rate limit violations",
+		"bannedUntil": until.Format(time.RFC3339),
+	})
+	return nil
+}
+
+// banned returns when the ban of key ends, if it is banned.
+func (rl *Limiter) banned(key string, now time.Time) (time.Time, bool) {
+	rl.penaltyMu.Lock()
+	defer rl.penaltyMu.Unlock()
+
+	until, ok := rl.bans[key]
+	if ok && !now.Before(until) {
+		delete(rl.bans, key)
+		return time.Time{}, false
+	}
+	return until, ok
+}
+
+// violate records a denied request for key and bans the key when it has
+// exceeded the limit too often.
+func (rl *Limiter) violate(key string, config RateLimiterConfig, now time.Time) (time.Time, bool) {
+	penalty := config.Penalty
+	window := cmp.Or(penalty.Window, config.Window)
+	duration := cmp.Or(penalty.Duration, 15*time.Minute)
+
+	rl.penaltyMu.Lock()
+	v, ok := rl.violations[key]
+	if !ok || now.Sub(v.start) >= window {
+		v = &rateLimitViolations{start: now}
+		rl.violations[key] = v
+	}
+	v.count++
+	if v.count <= penalty.Violations {
+		rl.penaltyMu.Unlock()
+		return time.Time{}, false
+	}
+	until := now.Add(duration)
+	rl.bans[key] = until
+	delete(rl.violations, key)
+	rl.penaltyMu.Unlock()
+
+	if penalty.OnBan != nil {
+		penalty.OnBan(key, until)
+	}
+	return until, true
+}
+
+// Bans returns the banned keys and when their bans end.
+func (rl *Limiter) Bans() map[string]time.Time {
+	rl.penaltyMu.Lock()
+	defer rl.penaltyMu.Unlock()
+
+	now := time.Now()
+	bans := make(map[string]time.Time, len(rl.bans))
+	for key, until := range rl.bans {
+		if now.Before(until) {
+			bans[key] = until
+		}
+	}
+	return bans
+}
+
+// Unban lifts the ban of key and clears its violations. It reports whether
+// the key was banned.
+func (rl *Limiter) Unban(key string) bool {
+	rl.penaltyMu.Lock()
+	defer rl.penaltyMu.Unlock()
+
+	until, ok := rl.bans[key]
+	delete(rl.bans, key)
+	delete(rl.violations, key)
+	return ok && time.Now().Before(until)
+}
+
+// mostRestrictive returns the denied check, or else the one with the fewest
+// remaining requests.
+func mostRestrictive(checks []rateLimitCheck) rateLimitCheck {
+	reported := checks[0]
+	for _, check := range checks {
+		if !check.allowed {
+			return check
+		}
+		if check.remaining < reported.remaining ||
+			check.remaining == reported.remaining && check.resetTime.After(reported.resetTime) {
+			reported = check
+		}
+	}
+	return reported
+}
+
+// Rate limit header names, in canonical form so that setting them does not
+// allocate.
+var (
+	headerXRateLimitLimit     = http.CanonicalHeaderKey("X-RateLimit-Limit")
+	headerXRateLimitRemaining = http.CanonicalHeaderKey("X-RateLimit-Remaining")
+	headerXRateLimitReset     = http.CanonicalHeaderKey("X-RateLimit-Reset")
+	headerRateLimitLimit      = http.CanonicalHeaderKey("RateLimit-Limit")
+	headerRateLimitRemaining  = http.CanonicalHeaderKey("RateLimit-Remaining")
+	headerRateLimitReset      = http.CanonicalHeaderKey("RateLimit-Reset")
+	headerRateLimitPolicy     = http.CanonicalHeaderKey("RateLimit-Policy")
+)
+
+// setRateLimitHeaders adds the rate limit headers for style, reporting the
+// given check and listing every evaluated limit in RateLimit-Policy.
+func setRateLimitHeaders(c *ginji.Context, style RateLimitHeaderStyle, reported rateLimitCheck, checks []rateLimitCheck) {
+	h := c.Res.Header()
+	limit := strconv.Itoa(reported.limit.Max)
+	remaining := strconv.Itoa(reported.remaining)
+	if style == RateLimitHeadersLegacy || style == RateLimitHeadersBoth {
+		h.Set(headerXRateLimitLimit, limit)
+		h.Set(headerXRateLimitRemaining, remaining)
+		h.Set(headerXRateLimitReset, strconv.FormatInt(reported.resetTime.Unix(), 10))
+	}
+	if style == RateLimitHeadersStandard || style == RateLimitHeadersBoth {
+		var policy []byte
+		for i, check := range checks {
+			if i > 0 {
+				policy = append(policy, ", "...)
+			}
+			policy = strconv.AppendInt(policy, int64(check.limit.Max), 10)
+			policy = append(policy, ";w="...)
+			policy = strconv.AppendInt(policy, int64(math.Ceil(check.limit.Window.Seconds())), 10)
+		}
+		h.Set(headerRateLimitLimit, limit)
+		h.Set(headerRateLimitRemaining, remaining)
+		h.Set(headerRateLimitReset, strconv.FormatInt(secondsUntil(reported.resetTime), 10))
+		h.Set(headerRateLimitPolicy, string(policy))
+	}
+}
+
+// secondsUntil returns the whole seconds until t, rounded up so clients
+// never retry early.
+func secondsUntil(t time.Time) int64 {
+	return max(int64(math.Ceil(time.Until(t).Seconds())), 0)
+}
+
+// allow consumes cost tokens from the bucket for key and returns whether the
+// request is allowed, the remaining count and the reset time.
+func (rl *Limiter) allow(key string, limit RouteLimit, cost, maxKeys int) (bool, int, time.Time) {
+	shard := rl.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
 
 	now := time.Now()
 
 	// Get or create bucket
-	b, exists := rl.buckets[key]
-	if !exists {
+	var b *bucket
+	if el, exists := shard.buckets[key]; exists {
+		shard.lru.MoveToFront(el)
+		b = el.Value.(*bucket)
+	} else {
 		b = &bucket{
-			tokens:    rl.config.Max,
+			key:       key,
+			tokens:    limit.Max,
+			window:    limit.Window,
 			lastReset: now,
 		}
-		rl.buckets[key] = b
-	}
+		shard.buckets[key] = shard.lru.PushFront(b)
 
-	b.mu.Lock()
-	defer b.mu.Unlock()
+		// Evict the least recently used bucket when the shard is over capacity
+		if maxKeys > 0 && shard.lru.Len() > (maxKeys+len(rl.shards)-1)/len(rl.shards) {
+			oldest := shard.lru.Back()
+			shard.lru.Remove(oldest)
+			delete(shard.buckets, oldest.Value.(*bucket).key)
+		}
+	}
 
 	// Reset bucket if window has passed
-	if now.Sub(b.lastReset) >= rl.config.Window {
-		b.tokens = rl.config.Max
+	b.window = limit.Window
+	if now.Sub(b.lastReset) >= limit.Window {
+		b.tokens = limit.Max
 		b.lastReset = now
 	}
 
-	resetTime := b.lastReset.Add(rl.config.Window)
+	// A lowered limit takes effect immediately
+	if b.tokens > limit.Max {
+		b.tokens = limit.Max
+	}
+
+	resetTime := b.lastReset.Add(limit.Window)
 
-	// Check if tokens are available
-	if b.tokens > 0 {
-		b.tokens--
+	// Check if enough tokens are available
+	if b.tokens >= cost {
+		b.tokens -= cost
 		return true, b.tokens, resetTime
 	}
 
-	return false, 0, resetTime
+	return false, b.tokens, resetTime
+}
+
+// Take consumes cost tokens from the bucket for key under limit and
+// returns whether they were available, the tokens left and the time the
+// window resets. It lets other middleware, such as BodyLimit upload quotas,
+// share the limiter's buckets; prefix keys to keep them apart.
+func (rl *Limiter) Take(key string, limit RouteLimit, cost int) (bool, int, time.Time) {
+	return rl.allow(key, limit, cost, rl.config().MaxKeys)
+}
+
+// refund returns cost tokens to the bucket for key, undoing allow when a
+// later limit denies the request.
+func (rl *Limiter) refund(key string, cost int) {
+	shard := rl.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if el, exists := shard.buckets[key]; exists {
+		b := el.Value.(*bucket)
+		b.tokens += cost
+	}
+}
+
+// matchRouteLimit returns the longest pattern in routes matching path.
+func matchRouteLimit(routes map[string]RouteLimit, path string) (string, RouteLimit, bool) {
+	var (
+		best  string
+		limit RouteLimit
+		found bool
+	)
+	if len(routes) == 0 {
+		return best, limit, found
+	}
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	for pattern, route := range routes {
+		if found && (len(pattern) < len(best) || len(pattern) == len(best) && pattern > best) {
+			continue
+		}
+		if matchGlobSegments(strings.Split(strings.Trim(pattern, "/"), "/"), parts) {
+			best, limit, found = pattern, route, true
+		}
+	}
+	return best, limit, found
+}
+
+// shard returns the shard holding key.
+func (rl *Limiter) shard(key string) *limiterShard {
+	if len(rl.shards) == 1 {
+		return rl.shards[0]
+	}
+	// Inline 32-bit FNV-1a, avoiding the allocation of hash/fnv
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return rl.shards[h%uint32(len(rl.shards))]
+}
+
+// Len returns the number of tracked keys.
+func (rl *Limiter) Len() int {
+	n := 0
+	for _, shard := range rl.shards {
+		shard.mu.Lock()
+		n += len(shard.buckets)
+		shard.mu.Unlock()
+	}
+	return n
+}
+
+// Stats returns the limiter's current RateLimitStats.
+func (rl *Limiter) Stats() any {
+	config := rl.config()
+	stats := RateLimitStats{
+		Limit:  config.Max,
+		Window: config.Window.String(),
+	}
+
+	now := time.Now()
+	for _, shard := range rl.shards {
+		shard.mu.Lock()
+		for _, el := range shard.buckets {
+			b := el.Value.(*bucket)
+			if b.tokens == 0 && now.Sub(b.lastReset) < b.window {
+				stats.Exhausted++
+			}
+		}
+		stats.Buckets += len(shard.buckets)
+		shard.mu.Unlock()
+	}
+	stats.Banned = len(rl.Bans())
+	return stats
 }
 
 // cleanup removes old buckets periodically.
-func (rl *rateLimiter) cleanup() {
-	ticker := time.NewTicker(rl.config.Window)
+func (rl *Limiter) cleanup() {
+	window := rl.config().Window
+	ticker := time.NewTicker(window)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
-			rl.mu.Lock()
+			// Follow window changes made at runtime
+			if current := rl.config().Window; current != window {
+				window = current
+				ticker.Reset(window)
+			}
+
 			now := time.Now()
-			for key, b := range rl.buckets {
-				b.mu.Lock()
-				if now.Sub(b.lastReset) > rl.config.Window*2 {
-					delete(rl.buckets, key)
+			for _, shard := range rl.shards {
+				shard.mu.Lock()
+				for key, el := range shard.buckets {
+					if b := el.Value.(*bucket); now.Sub(b.lastReset) > b.window*2 {
+						shard.lru.Remove(el)
+						delete(shard.buckets, key)
+					}
+				}
+				shard.mu.Unlock()
+			}
+
+			// Drop expired bans and stale violation counts
+			penaltyWindow := cmp.Or(rl.config().Penalty.Window, window)
+			rl.penaltyMu.Lock()
+			for key, until := range rl.bans {
+				if !now.Before(until) {
+					delete(rl.bans, key)
+				}
+			}
+			for key, v := range rl.violations {
+				if now.Sub(v.start) >= penaltyWindow {
+					delete(rl.violations, key)
 				}
-				b.mu.Unlock()
 			}
-			rl.mu.Unlock()
+			rl.penaltyMu.Unlock()
 		case <-rl.cleanupCh:
 			// Cleanup signal received, stop the goroutine
 			return
@@ -244,9 +996,50 @@ func (rl *rateLimiter) cleanup() {
 	}
 }
 
-// Stop stops the cleanup goroutine and releases resources.
-func (rl *rateLimiter) Stop() {
-	close(rl.cleanupCh)
+// Close stops the cleanup goroutine. The limiter keeps limiting requests,
+// but expired buckets are no longer removed. It is safe to call Close more
+// than once.
+func (rl *Limiter) Close() error {
+	rl.closeOnce.Do(func() {
+		close(rl.cleanupCh)
+	})
+	return nil
+}
+
+// HealthCheck implements HealthReporter. A closed limiter is unhealthy.
+func (rl *Limiter) HealthCheck() error {
+	select {
+	case <-rl.cleanupCh:
+		return errors.New("ratelimit: limiter is closed")
+	default:
+		return nil
+	}
+}
+
+// Name implements ginji.Plugin.
+func (rl *Limiter) Name() string {
+	return rl.config().Name
+}
+
+// Version implements ginji.Plugin.
+func (rl *Limiter) Version() string {
+	return "1.0.0"
+}
+
+// Install implements ginji.Plugin. The middleware is not installed
+// automatically; use Middleware to apply it globally or to a group.
+func (rl *Limiter) Install(*ginji.Engine) error {
+	return nil
+}
+
+// Start implements ginji.Plugin.
+func (rl *Limiter) Start() error {
+	return nil
+}
+
+// Stop implements ginji.Plugin by closing the limiter.
+func (rl *Limiter) Stop() error {
+	return rl.Close()
 }
 
 // RateLimitPerSecond returns middleware that limits requests per second.
@@ -2,6 +2,8 @@ package middleware
 
 import (
 	"fmt"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -286,3 +288,555 @@ func TestDefaultRateLimiterConfig(t *testing.T) {
 		t.Error("Expected headers to be enabled by default")
 	}
 }
+
+func TestRateLimitDynamic(t *testing.T) {
+	limits := NewReloader(RateLimiterConfig{Max: 5, Window: time.Minute, Headers: true})
+
+	app := ginji.New()
+	app.Use(RateLimitDynamic(limits.Load))
+	app.Get("/test", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/test", nil)
+	ginji.AssertHeader(t, w, "X-RateLimit-Limit", "5")
+
+	// Lowering the limit applies to existing buckets
+	limits.Update(func(c RateLimiterConfig) RateLimiterConfig {
+		c.Max = 1
+		return c
+	})
+	w = ginji.PerformRequest(app, "GET", "/test", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	ginji.AssertHeader(t, w, "X-RateLimit-Limit", "1")
+
+	w = ginji.PerformRequest(app, "GET", "/test", nil)
+	ginji.AssertStatus(t, w, ginji.StatusTooManyRequests)
+}
+
+func TestRateLimitDynamicInvalidList(t *testing.T) {
+	var errs []error
+	limits := NewReloader(RateLimiterConfig{
+		Max:       1,
+		Window:    time.Minute,
+		Allowlist: RateLimitList{IPs: []string{"10.0.0.0/33", "192.0.2.0/24"}},
+		OnError:   func(err error) { errs = append(errs, err) },
+	})
+
+	app := ginji.New()
+	app.Use(RateLimitDynamic(limits.Load))
+	app.Get("/test", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	// The valid entries apply and the lists are parsed once
+	for range 3 {
+		w := ginji.PerformRequest(app, "GET", "/test", nil)
+		ginji.AssertStatus(t, w, ginji.StatusOK)
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "10.0.0.0/33") {
+		t.Fatalf("Expected one error for the invalid entry, got %v", errs)
+	}
+
+	// Changing the lists parses them again
+	limits.Update(func(c RateLimiterConfig) RateLimiterConfig {
+		c.Allowlist = RateLimitList{}
+		return c
+	})
+	ginji.AssertStatus(t, ginji.PerformRequest(app, "GET", "/test", nil), ginji.StatusOK)
+	ginji.AssertStatus(t, ginji.PerformRequest(app, "GET", "/test", nil), ginji.StatusTooManyRequests)
+	if len(errs) != 1 {
+		t.Errorf("Expected no error for valid lists, got %v", errs[1:])
+	}
+}
+
+func TestRateLimitRoutes(t *testing.T) {
+	config := DefaultRateLimiterConfig()
+	config.Max = 5
+	config.Routes = map[string]RouteLimit{
+		"/api/**":        {Max: 3},
+		"/api/login":     {Max: 1, Window: time.Hour},
+		"/api/*/reports": {Max: 2},
+	}
+
+	app := ginji.New()
+	app.Use(RateLimitWithConfig(config))
+	handler := func(c *ginji.Context) error { return c.Text(ginji.StatusOK, "ok") }
+	app.Get("/", handler)
+	app.Post("/api/login", handler)
+	app.Get("/api/users", handler)
+
+	w := ginji.PerformRequest(app, "POST", "/api/login", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	ginji.AssertHeader(t, w, "X-RateLimit-Limit", "1")
+
+	w = ginji.PerformRequest(app, "POST", "/api/login", nil)
+	ginji.AssertStatus(t, w, ginji.StatusTooManyRequests)
+	ginji.AssertBody(t, w, "Maximum 1 requests per 1h0m0s")
+
+	// Other routes have their own buckets
+	w = ginji.PerformRequest(app, "GET", "/api/users", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	ginji.AssertHeader(t, w, "X-RateLimit-Limit", "3")
+	ginji.AssertHeader(t, w, "X-RateLimit-Remaining", "2")
+
+	w = ginji.PerformRequest(app, "GET", "/", nil)
+	ginji.AssertHeader(t, w, "X-RateLimit-Limit", "5")
+	ginji.AssertHeader(t, w, "X-RateLimit-Remaining", "4")
+}
+
+func TestRateLimitCostFunc(t *testing.T) {
+	config := DefaultRateLimiterConfig()
+	config.Max = 10
+	config.CostFunc = func(c *ginji.Context) int {
+		if c.Req.URL.Path == "/export" {
+			return 6
+		}
+		return 1
+	}
+
+	app := ginji.New()
+	app.Use(RateLimitWithConfig(config))
+	handler := func(c *ginji.Context) error { return c.Text(ginji.StatusOK, "ok") }
+	app.Get("/export", handler)
+	app.Get("/item", handler)
+
+	w := ginji.PerformRequest(app, "GET", "/export", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	ginji.AssertHeader(t, w, "X-RateLimit-Remaining", "4")
+
+	// Not enough tokens left for another export, but enough for cheap requests
+	w = ginji.PerformRequest(app, "GET", "/export", nil)
+	ginji.AssertStatus(t, w, ginji.StatusTooManyRequests)
+	ginji.AssertHeader(t, w, "X-RateLimit-Remaining", "4")
+
+	w = ginji.PerformRequest(app, "GET", "/item", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	ginji.AssertHeader(t, w, "X-RateLimit-Remaining", "3")
+}
+
+func TestRateLimitStandardHeaders(t *testing.T) {
+	tests := []struct {
+		style       RateLimitHeaderStyle
+		legacy, std bool
+	}{
+		{RateLimitHeadersLegacy, true, false},
+		{RateLimitHeadersStandard, false, true},
+		{RateLimitHeadersBoth, true, true},
+	}
+	for _, tt := range tests {
+		config := DefaultRateLimiterConfig()
+		config.Max = 10
+		config.Window = 90 * time.Second
+		config.HeaderStyle = tt.style
+
+		app := ginji.New()
+		app.Use(RateLimitWithConfig(config))
+		app.Get("/test", func(c *ginji.Context) error {
+			return c.Text(ginji.StatusOK, "ok")
+		})
+
+		w := ginji.PerformRequest(app, "GET", "/test", nil)
+		if got := w.Header().Get("X-RateLimit-Limit") != ""; got != tt.legacy {
+			t.Errorf("style %d: legacy headers present = %v", tt.style, got)
+		}
+		if got := w.Header().Get("RateLimit-Limit") != ""; got != tt.std {
+			t.Errorf("style %d: standard headers present = %v", tt.style, got)
+		}
+		if !tt.std {
+			continue
+		}
+		ginji.AssertHeader(t, w, "RateLimit-Limit", "10")
+		ginji.AssertHeader(t, w, "RateLimit-Remaining", "9")
+		ginji.AssertHeader(t, w, "RateLimit-Reset", "90")
+		ginji.AssertHeader(t, w, "RateLimit-Policy", "10;w=90")
+	}
+}
+
+var _ ginji.Plugin = (*Limiter)(nil)
+
+func TestLimiterMaxKeys(t *testing.T) {
+	config := DefaultRateLimiterConfig()
+	config.MaxKeys = 2
+	config.KeyFunc = func(c *ginji.Context) string { return c.Query("key") }
+	limiter := NewLimiter(config)
+	defer limiter.Close()
+
+	app := ginji.New()
+	app.Use(limiter.Middleware())
+	app.Get("/test", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	for _, key := range []string{"a", "b", "a", "c"} {
+		ginji.PerformRequest(app, "GET", "/test?key="+key, nil)
+	}
+	if n := limiter.Len(); n != 2 {
+		t.Fatalf("Expected 2 keys, got %d", n)
+	}
+
+	// "b" was least recently used and evicted, so it starts with a full bucket
+	w := ginji.PerformRequest(app, "GET", "/test?key=b", nil)
+	ginji.AssertHeader(t, w, "X-RateLimit-Remaining", "99")
+	w = ginji.PerformRequest(app, "GET", "/test?key=a", nil)
+	ginji.AssertHeader(t, w, "X-RateLimit-Remaining", "99")
+}
+
+func TestLimiterClosedByPluginStop(t *testing.T) {
+	limiter := NewLimiter(DefaultRateLimiterConfig())
+
+	app := ginji.New()
+	app.UsePlugin(limiter)
+	if err := app.InstallPlugins(); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.StartPlugins(); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.StopPlugins(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-limiter.cleanupCh:
+	default:
+		t.Error("Expected limiter to be closed")
+	}
+
+	// Closing again is a no-op
+	if err := limiter.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+}
+
+// benchmarkLimiterKeys returns n distinct client keys.
+func benchmarkLimiterKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("10.%d.%d.%d:1234", i>>16&0xff, i>>8&0xff, i&0xff)
+	}
+	return keys
+}
+
+func BenchmarkLimiterAllowParallel(b *testing.B) {
+	for _, n := range []int{1, 1000, 100000} {
+		b.Run(fmt.Sprintf("keys=%d", n), func(b *testing.B) {
+			limiter := NewLimiter(RateLimiterConfig{Max: 1 << 30, Window: time.Hour})
+			defer limiter.Close()
+			keys := benchmarkLimiterKeys(n)
+			limit := RouteLimit{Max: 1 << 30, Window: time.Hour}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					limiter.allow(keys[i%n], limit, 1, 0)
+					i += 7919 // Spread goroutines across keys
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkLimiterAllowMaxKeys(b *testing.B) {
+	limiter := NewLimiter(RateLimiterConfig{Max: 100, Window: time.Hour, MaxKeys: 10000})
+	defer limiter.Close()
+	keys := benchmarkLimiterKeys(100000)
+	limit := RouteLimit{Max: 100, Window: time.Hour}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			limiter.allow(keys[i%len(keys)], limit, 1, 10000)
+			i += 7919
+		}
+	})
+}
+
+func BenchmarkRateLimitMiddleware(b *testing.B) {
+	app := ginji.New()
+	app.Use(RateLimit(1<<30, time.Hour))
+	app.Get("/test", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ginji.PerformRequest(app, "GET", "/test", nil)
+	}
+}
+
+func BenchmarkRateLimitMiddlewareHeaders(b *testing.B) {
+	for _, tt := range []struct {
+		name  string
+		style RateLimitHeaderStyle
+	}{
+		{"legacy", RateLimitHeadersLegacy},
+		{"standard", RateLimitHeadersStandard},
+		{"both", RateLimitHeadersBoth},
+	} {
+		b.Run(tt.name, func(b *testing.B) {
+			mw := RateLimitWithConfig(RateLimiterConfig{Max: 1 << 30, Window: time.Hour, Headers: true, HeaderStyle: tt.style})
+			benchmarkMiddleware(b, httptest.NewRequest("GET", "/", nil), mw)
+		})
+	}
+}
+
+func TestRateLimitSkipAllocs(t *testing.T) {
+	mw := RateLimitWithConfig(RateLimiterConfig{
+		Max:      10,
+		Window:   time.Hour,
+		SkipFunc: func(c *ginji.Context) bool { return true },
+	})
+	if n := middlewareAllocs(t, httptest.NewRequest("GET", "/", nil), mw); n > 0 {
+		t.Errorf("Expected no allocations when skipped, got %v", n)
+	}
+}
+
+func TestRateLimitPolicies(t *testing.T) {
+	config := DefaultRateLimiterConfig()
+	config.Max = 10
+	config.HeaderStyle = RateLimitHeadersBoth
+	config.Policies = []RateLimitPolicy{
+		{Name: "global", Max: 3, Window: time.Minute},
+		{Name: "apikey", Max: 1, Window: time.Hour, KeyFunc: func(c *ginji.Context) string {
+			return c.Query("key")
+		}},
+	}
+
+	app := ginji.New()
+	app.Use(RateLimitWithConfig(config))
+	app.Get("/test", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	// The API key policy only applies to requests with a key
+	w := ginji.PerformRequest(app, "GET", "/test?key=k1", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	ginji.AssertHeader(t, w, "X-RateLimit-Limit", "1")
+	ginji.AssertHeader(t, w, "X-RateLimit-Remaining", "0")
+	ginji.AssertHeader(t, w, "RateLimit-Policy", "10;w=60, 3;w=60, 1;w=3600")
+
+	w = ginji.PerformRequest(app, "GET", "/test?key=k1", nil)
+	ginji.AssertStatus(t, w, ginji.StatusTooManyRequests)
+	ginji.AssertBody(t, w, `"policy":"apikey"`)
+
+	// The denied request's global token was returned
+	w = ginji.PerformRequest(app, "GET", "/test", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	ginji.AssertHeader(t, w, "X-RateLimit-Limit", "3")
+	ginji.AssertHeader(t, w, "X-RateLimit-Remaining", "1")
+
+	ginji.PerformRequest(app, "GET", "/test", nil)
+	w = ginji.PerformRequest(app, "GET", "/test", nil)
+	ginji.AssertStatus(t, w, ginji.StatusTooManyRequests)
+	ginji.AssertBody(t, w, `"policy":"global"`)
+	ginji.AssertHeader(t, w, "RateLimit-Remaining", "0")
+}
+
+func TestRateLimitPolicyDefaults(t *testing.T) {
+	config := DefaultRateLimiterConfig()
+	config.Max = 2
+	config.Policies = []RateLimitPolicy{{Name: "total", Window: time.Hour}}
+
+	app := ginji.New()
+	app.Use(RateLimitWithConfig(config))
+	app.Get("/test", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	// The policy takes the limiter's Max, shared by all clients
+	var w *httptest.ResponseRecorder
+	for _, addr := range []string{"192.0.2.1:1234", "192.0.2.2:1234", "192.0.2.3:1234"} {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.RemoteAddr = addr
+		w = httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+	}
+	ginji.AssertStatus(t, w, ginji.StatusTooManyRequests)
+	ginji.AssertBody(t, w, `"policy":"total"`)
+}
+
+func TestRateLimitAllowAndDenyLists(t *testing.T) {
+	config := DefaultRateLimiterConfig()
+	config.Max = 1
+	config.Allowlist = RateLimitList{
+		IPs:     []string{"10.0.0.0/8"},
+		APIKeys: []string{"internal-key"},
+		UserIDs: []string{"ops"},
+	}
+	config.Denylist = RateLimitList{
+		APIKeys: []string{"revoked-key"},
+		UserIDs: []string{"mallory"},
+	}
+	config.TrustedProxies = []string{"192.0.2.1"}
+
+	app := ginji.New()
+	app.Use(func(c *ginji.Context) error {
+		if user := c.Header("X-User"); user != "" {
+			SetUser(c, user)
+		}
+		return c.Next()
+	})
+	app.Use(RateLimitWithConfig(config))
+	app.Get("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	request := func(header, value string) int {
+		return ginji.NewRequest(app, "GET", "/").Header(header, value).Do().Code
+	}
+
+	for name, header := range map[string][2]string{
+		"ip":      {"X-Forwarded-For", "10.1.2.3"},
+		"api key": {"X-API-Key", "internal-key"},
+		"user":    {"X-User", "ops"},
+	} {
+		for range 3 {
+			if code := request(header[0], header[1]); code != ginji.StatusOK {
+				t.Fatalf("Expected allowlisted %s to bypass the limit, got %d", name, code)
+			}
+		}
+	}
+
+	if code := request("X-API-Key", "revoked-key"); code != ginji.StatusForbidden {
+		t.Errorf("Expected denylisted API key to get 403, got %d", code)
+	}
+	if code := request("X-User", "mallory"); code != ginji.StatusForbidden {
+		t.Errorf("Expected denylisted user to get 403, got %d", code)
+	}
+
+	// Other clients are limited as usual
+	if code := request("X-Forwarded-For", "203.0.113.7"); code != ginji.StatusOK {
+		t.Errorf("Expected first request to pass, got %d", code)
+	}
+	if code := request("X-Forwarded-For", "203.0.113.7"); code != ginji.StatusTooManyRequests {
+		t.Errorf("Expected second request to be limited, got %d", code)
+	}
+}
+
+func TestRateLimitKeyFuncWithTrustedProxies(t *testing.T) {
+	config := DefaultRateLimiterConfig()
+	config.Max = 1
+	config.KeyFunc = ByHeader("X-Tenant")
+	config.TrustedProxies = []string{"192.0.2.1"}
+
+	app := ginji.New()
+	app.Use(RateLimitWithConfig(config))
+	app.Get("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	// The tenant is the key, whichever client the proxy forwards
+	request := func(tenant, forwardedFor string) int {
+		return ginji.NewRequest(app, "GET", "/").Header("X-Tenant", tenant).Header("X-Forwarded-For", forwardedFor).Do().Code
+	}
+	if code := request("acme", "203.0.113.7"); code != ginji.StatusOK {
+		t.Fatalf("Expected first request to pass, got %d", code)
+	}
+	if code := request("acme", "203.0.113.8"); code != ginji.StatusTooManyRequests {
+		t.Errorf("Expected second request of the tenant to be limited, got %d", code)
+	}
+
+	// Requests without the header fall back to the forwarded client IP
+	if code := request("", "203.0.113.9"); code != ginji.StatusOK {
+		t.Errorf("Expected first request without tenant to pass, got %d", code)
+	}
+	if code := request("", "203.0.113.10"); code != ginji.StatusOK {
+		t.Errorf("Expected another forwarded client to pass, got %d", code)
+	}
+}
+
+func TestRateLimitInvalidList(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic for an invalid CIDR")
+		}
+	}()
+	config := DefaultRateLimiterConfig()
+	config.Denylist.IPs = []string{"10.0.0.0/33"}
+	NewLimiter(config)
+}
+
+func TestRateLimitPenalty(t *testing.T) {
+	var banned []string
+	config := DefaultRateLimiterConfig()
+	config.Max = 1
+	config.Penalty = RateLimitPenalty{
+		Violations: 2,
+		Duration:   time.Hour,
+		StatusCode: ginji.StatusForbidden,
+		OnBan: func(key string, until time.Time) {
+			banned = append(banned, key)
+		},
+	}
+	limiter := NewLimiter(config)
+	defer limiter.Close()
+
+	app := ginji.New()
+	app.Use(limiter.Middleware())
+	app.Get("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	// One allowed request, then two violations within the limit
+	for i, want := range []int{ginji.StatusOK, ginji.StatusTooManyRequests, ginji.StatusTooManyRequests, ginji.StatusForbidden} {
+		if w := ginji.PerformRequest(app, "GET", "/", nil); w.Code != want {
+			t.Fatalf("Request %d: expected %d, got %d", i+1, want, w.Code)
+		}
+	}
+	key := httptest.NewRequest("GET", "/", nil).RemoteAddr
+	if len(banned) != 1 || banned[0] != key {
+		t.Fatalf("Expected OnBan for %s, got %v", key, banned)
+	}
+
+	// Banned keys are rejected without touching their bucket
+	w := ginji.PerformRequest(app, "GET", "/", nil)
+	ginji.AssertStatus(t, w, ginji.StatusForbidden)
+	ginji.AssertBody(t, w, "bannedUntil")
+	if retry := w.Header().Get("Retry-After"); retry != "3600" {
+		t.Errorf("Expected Retry-After of the ban, got %q", retry)
+	}
+	if stats := limiter.Stats().(RateLimitStats); stats.Banned != 1 {
+		t.Errorf("Expected 1 banned key, got %d", stats.Banned)
+	}
+
+	if !limiter.Unban(key) || limiter.Unban(key) {
+		t.Error("Expected Unban to lift the ban once")
+	}
+	if len(limiter.Bans()) != 0 {
+		t.Error("Expected no bans after Unban")
+	}
+	w = ginji.PerformRequest(app, "GET", "/", nil)
+	ginji.AssertStatus(t, w, ginji.StatusTooManyRequests)
+}
+
+func TestRateLimitPenaltyIgnoresSharedPolicies(t *testing.T) {
+	config := DefaultRateLimiterConfig()
+	config.Max = 100
+	config.Policies = []RateLimitPolicy{{Name: "global", Max: 1, Window: time.Minute}}
+	config.Penalty = RateLimitPenalty{Violations: 1, Duration: time.Hour}
+	limiter := NewLimiter(config)
+	defer limiter.Close()
+
+	app := ginji.New()
+	app.Use(limiter.Middleware())
+	app.Get("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	for i, want := range []int{ginji.StatusOK, ginji.StatusTooManyRequests, ginji.StatusTooManyRequests, ginji.StatusTooManyRequests} {
+		w := ginji.PerformRequest(app, "GET", "/", nil)
+		ginji.AssertStatus(t, w, want)
+		if i > 0 {
+			ginji.AssertBody(t, w, `"policy":"global"`)
+		}
+	}
+	if bans := limiter.Bans(); len(bans) != 0 {
+		t.Errorf("Expected no bans for global limit denials, got %v", bans)
+	}
+}
@@ -0,0 +1,367 @@
+package middleware
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// RecordedRequest is the captured request half of an exchange.
+type RecordedRequest struct {
+	Method        string      `json:"method"`
+	URL           string      `json:"url"`
+	Proto         string      `json:"proto"`
+	Header        http.Header `json:"header"`
+	Body          string      `json:"body,omitempty"`
+	BodyTruncated bool        `json:"body_truncated,omitempty"`
+}
+
+// RecordedResponse is the captured response half of an exchange.
+type RecordedResponse struct {
+	Status        int         `json:"status"`
+	Header        http.Header `json:"header"`
+	Body          string      `json:"body,omitempty"`
+	BodySize      int         `json:"body_size"`
+	BodyTruncated bool        `json:"body_truncated,omitempty"`
+}
+
+// RecordedExchange is a captured request/response pair.
+type RecordedExchange struct {
+	RequestID string           `json:"request_id,omitempty"`
+	StartedAt time.Time        `json:"started_at"`
+	Duration  time.Duration    `json:"duration"`
+	Request   RecordedRequest  `json:"request"`
+	Response  RecordedResponse `json:"response"`
+}
+
+// RecorderConfig defines the configuration for the traffic recorder.
+type RecorderConfig struct {
+	// Enabled starts the recorder enabled. It can be toggled at runtime.
+	Enabled bool
+
+	// Capacity is the number of exchanges kept in the ring buffer.
+	// Default: 100
+	Capacity int
+
+	// MaxBodySize is the maximum number of body bytes captured per
+	// request and response.
+	// Default: 64KB
+	MaxBodySize int64
+
+	// RedactHeaders are replaced with "[REDACTED]" in recordings.
+	// Default: Authorization, Proxy-Authorization, Cookie, Set-Cookie,
+	// X-API-Key, X-CSRF-Token
+	RedactHeaders []string
+
+	// Scrubber, if set, removes PII from recorded URLs and bodies.
+	Scrubber *Scrubber
+
+	// SkipFunc allows skipping recording for certain requests.
+	SkipFunc Skipper
+}
+
+// DefaultRecorderConfig returns default recorder configuration.
+func DefaultRecorderConfig() RecorderConfig {
+	return RecorderConfig{
+		Capacity:    100,
+		MaxBodySize: 64 * 1024,
+		RedactHeaders: []string{
+			"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie",
+			"X-API-Key", "X-CSRF-Token",
+		},
+	}
+}
+
+// Recorder captures recent traffic into a ring buffer for debugging.
+type Recorder struct {
+	config  RecorderConfig
+	enabled atomic.Bool
+
+	mu      sync.Mutex
+	entries []RecordedExchange
+	next    int
+	full    bool
+}
+
+// NewRecorder creates a recorder with the given configuration.
+func NewRecorder(config RecorderConfig) *Recorder {
+	defaults := DefaultRecorderConfig()
+	if config.Capacity <= 0 {
+		config.Capacity = defaults.Capacity
+	}
+	if config.MaxBodySize == 0 {
+		config.MaxBodySize = defaults.MaxBodySize
+	}
+	if config.RedactHeaders == nil {
+		config.RedactHeaders = defaults.RedactHeaders
+	}
+
+	r := &Recorder{
+		config:  config,
+		entries: make([]RecordedExchange, config.Capacity),
+	}
+	r.enabled.Store(config.Enabled)
+	return r
+}
+
+// Enable starts recording.
+func (r *Recorder) Enable() { r.enabled.Store(true) }
+
+// Disable stops recording. Already captured exchanges are kept.
+func (r *Recorder) Disable() { r.enabled.Store(false) }
+
+// Enabled reports whether the recorder is capturing traffic.
+func (r *Recorder) Enabled() bool { return r.enabled.Load() }
+
+// Clear discards all captured exchanges.
+func (r *Recorder) Clear() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = make([]RecordedExchange, r.config.Capacity)
+	r.next = 0
+	r.full = false
+}
+
+// Entries returns the captured exchanges, oldest first.
+func (r *Recorder) Entries() []RecordedExchange {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.full {
+		return append([]RecordedExchange(nil), r.entries[:r.next]...)
+	}
+	out := make([]RecordedExchange, 0, len(r.entries))
+	out = append(out, r.entries[r.next:]...)
+	return append(out, r.entries[:r.next]...)
+}
+
+// add stores an exchange, overwriting the oldest when full.
+func (r *Recorder) add(e RecordedExchange) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries[r.next] = e
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Middleware returns middleware that records exchanges while enabled.
+func (r *Recorder) Middleware() ginji.Middleware {
+	return func(c *ginji.Context) error {
+		if !r.Enabled() {
+			return c.Next()
+		}
+		// Skip if skip function returns true
+		if r.config.SkipFunc != nil && r.config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		// Clients that opted out of tracking only get their headers recorded
+		maxBody := r.config.MaxBodySize
+		if privacy := privacyOptOut(c); privacy != nil && privacy.OmitBodies {
+			maxBody = 0
+		}
+
+		start := time.Now()
+		var reqBody []byte
+		if maxBody > 0 {
+			reqBody = peekBody(c, maxBody+1)
+		}
+		reqHeader := r.redact(c.Req.Header)
+
+		var (
+			resBody strings.Builder
+			resSize int
+		)
+		rw := WrapResponseWriter(c)
+		rw.OnWrite(func(b []byte) {
+			if remaining := maxBody - int64(resBody.Len()); remaining > 0 {
+				resBody.Write(truncateBytes(b, remaining))
+			}
+			resSize += len(b)
+		})
+
+		err := c.Next()
+
+		// Follow the request's sampling decision if there is one
+		if state := samplingFrom(c); state != nil && !state.keep(c, err) {
+			return err
+		}
+
+		exchange := RecordedExchange{
+			RequestID: GetRequestID(c),
+			StartedAt: start,
+			Duration:  time.Since(start),
+			Request: RecordedRequest{
+				Method:        c.Req.Method,
+				URL:           requestURL(c.Req),
+				Proto:         c.Req.Proto,
+				Header:        reqHeader,
+				Body:          string(truncateBytes(reqBody, maxBody)),
+				BodyTruncated: int64(len(reqBody)) > maxBody,
+			},
+			Response: RecordedResponse{
+				Status:        rw.Status(),
+				Header:        r.redact(rw.Header()),
+				Body:          resBody.String(),
+				BodySize:      resSize,
+				BodyTruncated: int64(resSize) > maxBody,
+			},
+		}
+		if r.config.Scrubber != nil {
+			exchange.Request.URL = r.config.Scrubber.URL(exchange.Request.URL)
+			exchange.Request.Body = r.config.Scrubber.Body(exchange.Request.Body)
+			exchange.Response.Body = r.config.Scrubber.Body(exchange.Response.Body)
+		}
+		r.add(exchange)
+
+		return err
+	}
+}
+
+// redact clones h with sensitive headers masked.
+func (r *Recorder) redact(h http.Header) http.Header {
+	clone := h.Clone()
+	if clone == nil {
+		clone = http.Header{}
+	}
+	for _, name := range r.config.RedactHeaders {
+		if values := clone.Values(name); len(values) > 0 {
+			redacted := make([]string, len(values))
+			for i := range redacted {
+				redacted[i] = "[REDACTED]"
+			}
+			clone[http.CanonicalHeaderKey(name)] = redacted
+		}
+	}
+	return clone
+}
+
+// Handler returns an admin handler that dumps captured traffic as JSON, or
+// as a HAR archive when called with ?format=har.
+func (r *Recorder) Handler() ginji.Handler {
+	return func(c *ginji.Context) error {
+		entries := r.Entries()
+		if c.Query("format") == "har" {
+			c.SetHeader("Content-Disposition", `attachment; filename="recording.har"`)
+			return c.JSON(http.StatusOK, buildHAR(entries))
+		}
+		return c.JSON(http.StatusOK, ginji.H{
+			"enabled": r.Enabled(),
+			"entries": entries,
+		})
+	}
+}
+
+// truncateBytes shortens b to at most n bytes.
+func truncateBytes(b []byte, n int64) []byte {
+	if int64(len(b)) <= n {
+		return b
+	}
+	return b[:n]
+}
+
+// requestURL reconstructs the absolute URL of r.
+func requestURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + r.URL.RequestURI()
+}
+
+// harNameValue is a HAR header or query string entry.
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// harHeaders converts headers to HAR entries.
+func harHeaders(h http.Header) []harNameValue {
+	out := []harNameValue{}
+	for name, values := range h {
+		for _, v := range values {
+			out = append(out, harNameValue{Name: name, Value: v})
+		}
+	}
+	return out
+}
+
+// buildHAR renders exchanges as a HAR 1.2 document.
+func buildHAR(entries []RecordedExchange) ginji.H {
+	harEntries := make([]ginji.H, 0, len(entries))
+	for _, e := range entries {
+		ms := float64(e.Duration) / float64(time.Millisecond)
+
+		request := ginji.H{
+			"method":      e.Request.Method,
+			"url":         e.Request.URL,
+			"httpVersion": e.Request.Proto,
+			"headers":     harHeaders(e.Request.Header),
+			"queryString": harQueryString(e.Request.URL),
+			"cookies":     []any{},
+			"headersSize": -1,
+			"bodySize":    len(e.Request.Body),
+		}
+		if e.Request.Body != "" {
+			request["postData"] = ginji.H{
+				"mimeType": e.Request.Header.Get("Content-Type"),
+				"text":     e.Request.Body,
+			}
+		}
+
+		harEntries = append(harEntries, ginji.H{
+			"startedDateTime": e.StartedAt.Format(time.RFC3339Nano),
+			"time":            ms,
+			"request":         request,
+			"response": ginji.H{
+				"status":      e.Response.Status,
+				"statusText":  http.StatusText(e.Response.Status),
+				"httpVersion": e.Request.Proto,
+				"headers":     harHeaders(e.Response.Header),
+				"cookies":     []any{},
+				"content": ginji.H{
+					"size":     e.Response.BodySize,
+					"mimeType": e.Response.Header.Get("Content-Type"),
+					"text":     e.Response.Body,
+				},
+				"redirectURL": e.Response.Header.Get("Location"),
+				"headersSize": -1,
+				"bodySize":    e.Response.BodySize,
+			},
+			"cache":      ginji.H{},
+			"timings":    ginji.H{"send": 0, "wait": ms, "receive": 0},
+			"_requestId": e.RequestID,
+			"_truncated": e.Request.BodyTruncated || e.Response.BodyTruncated,
+		})
+	}
+
+	return ginji.H{
+		"log": ginji.H{
+			"version": "1.2",
+			"creator": ginji.H{"name": "ginji-middleware", "version": "1"},
+			"entries": harEntries,
+		},
+	}
+}
+
+// harQueryString extracts query parameters from a recorded URL.
+func harQueryString(rawURL string) []harNameValue {
+	out := []harNameValue{}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return out
+	}
+	for name, values := range u.Query() {
+		for _, v := range values {
+			out = append(out, harNameValue{Name: name, Value: v})
+		}
+	}
+	return out
+}
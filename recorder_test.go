@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ginjigo/ginji"
+)
+
+func TestRecorderCapturesExchanges(t *testing.T) {
+	r := NewRecorder(RecorderConfig{Enabled: true, MaxBodySize: 8})
+	app := ginji.New()
+	app.Use(r.Middleware())
+	app.Post("/echo", func(c *ginji.Context) error {
+		c.SetHeader("Set-Cookie", "session=secret")
+		return c.Text(ginji.StatusCreated, "response body that is long")
+	})
+	app.Get("/debug/traffic", r.Handler())
+
+	req := httptest.NewRequest("POST", "/echo?x=1", strings.NewReader("request body"))
+	req.Header.Set("Authorization", "Bearer secret")
+	app.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := r.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(entries))
+	}
+
+	e := entries[0]
+	if e.Request.Method != "POST" || !strings.HasSuffix(e.Request.URL, "/echo?x=1") {
+		t.Errorf("Unexpected request: %+v", e.Request)
+	}
+	if e.Request.Body != "request " || !e.Request.BodyTruncated {
+		t.Errorf("Expected truncated request body, got %q", e.Request.Body)
+	}
+	if e.Request.Header.Get("Authorization") != "[REDACTED]" {
+		t.Errorf("Expected Authorization to be redacted, got %q", e.Request.Header.Get("Authorization"))
+	}
+	if e.Response.Status != ginji.StatusCreated || e.Response.Body != "response" || !e.Response.BodyTruncated {
+		t.Errorf("Unexpected response: %+v", e.Response)
+	}
+	if e.Response.Header.Get("Set-Cookie") != "[REDACTED]" {
+		t.Errorf("Expected Set-Cookie to be redacted, got %q", e.Response.Header.Get("Set-Cookie"))
+	}
+}
+
+func TestRecorderToggleAndRing(t *testing.T) {
+	r := NewRecorder(RecorderConfig{Capacity: 2})
+	app := ginji.New()
+	app.Use(r.Middleware())
+	app.Post("/echo", func(c *ginji.Context) error {
+		c.SetHeader("Set-Cookie", "session=secret")
+		return c.Text(ginji.StatusCreated, "response body that is long")
+	})
+	app.Get("/debug/traffic", r.Handler())
+
+	ginji.PerformRequest(app, "POST", "/echo", nil)
+	if len(r.Entries()) != 0 {
+		t.Fatal("Expected nothing recorded while disabled")
+	}
+
+	r.Enable()
+	for _, q := range []string{"a", "b", "c"} {
+		ginji.PerformRequest(app, "POST", "/echo?q="+q, nil)
+	}
+
+	entries := r.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("Expected ring buffer of 2, got %d", len(entries))
+	}
+	if !strings.HasSuffix(entries[0].Request.URL, "q=b") || !strings.HasSuffix(entries[1].Request.URL, "q=c") {
+		t.Errorf("Expected oldest entries to be evicted, got %s and %s", entries[0].Request.URL, entries[1].Request.URL)
+	}
+}
+
+func TestRecorderHARExport(t *testing.T) {
+	r := NewRecorder(RecorderConfig{Enabled: true, SkipFunc: func(c *ginji.Context) bool {
+		return c.Req.URL.Path == "/debug/traffic"
+	}})
+	app := ginji.New()
+	app.Use(r.Middleware())
+	app.Post("/echo", func(c *ginji.Context) error {
+		c.SetHeader("Set-Cookie", "session=secret")
+		return c.Text(ginji.StatusCreated, "response body that is long")
+	})
+	app.Get("/debug/traffic", r.Handler())
+
+	ginji.PerformRequest(app, "POST", "/echo", strings.NewReader("hi"))
+	w := ginji.PerformRequest(app, "GET", "/debug/traffic?format=har", nil)
+
+	var har struct {
+		Log struct {
+			Version string `json:"version"`
+			Entries []struct {
+				Request struct {
+					Method string `json:"method"`
+				} `json:"request"`
+				Response struct {
+					Status int `json:"status"`
+				} `json:"response"`
+			} `json:"entries"`
+		} `json:"log"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &har); err != nil {
+		t.Fatal(err)
+	}
+	if har.Log.Version != "1.2" || len(har.Log.Entries) != 1 {
+		t.Fatalf("Unexpected HAR: %s", w.Body.String())
+	}
+	if har.Log.Entries[0].Request.Method != "POST" || har.Log.Entries[0].Response.Status != ginji.StatusCreated {
+		t.Errorf("Unexpected HAR entry: %+v", har.Log.Entries[0])
+	}
+}
@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Reloader holds a value that can be swapped atomically at runtime. Its Load
+// method matches the configFunc parameter of the Dynamic middleware
+// variants, so a single Reloader can feed a middleware while a file watcher
+// or admin endpoint updates it:
+//
+//	limits := middleware.NewReloader(middleware.DefaultRateLimiterConfig())
+//	app.Use(middleware.RateLimitDynamic(limits.Load))
+//	...
+//	limits.Update(func(c middleware.RateLimiterConfig) middleware.RateLimiterConfig {
+//		c.Max = 500
+//		return c
+//	})
+type Reloader[T any] struct {
+	value atomic.Pointer[T]
+	mu    sync.Mutex // serializes Update
+}
+
+// NewReloader creates a Reloader holding initial.
+func NewReloader[T any](initial T) *Reloader[T] {
+	r := &Reloader[T]{}
+	r.value.Store(&initial)
+	return r
+}
+
+// Load returns the current value.
+func (r *Reloader[T]) Load() T {
+	return *r.value.Load()
+}
+
+// Store replaces the current value.
+func (r *Reloader[T]) Store(value T) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.value.Store(&value)
+}
+
+// Update replaces the current value with fn applied to it and returns the
+// new value. Concurrent updates are applied one at a time.
+func (r *Reloader[T]) Update(fn func(T) T) T {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	value := fn(*r.value.Load())
+	r.value.Store(&value)
+	return value
+}
+
+// WatchFile loads the value from path with parse, then polls the file every
+// interval and reloads it when its contents change, until ctx is done. The
+// initial load error is returned; later read and parse errors are passed to
+// onError, if set, and leave the current value in place.
+func (r *Reloader[T]) WatchFile(ctx context.Context, path string, interval time.Duration, parse func([]byte) (T, error), onError func(error)) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	value, err := parse(data)
+	if err != nil {
+		return err
+	}
+	r.Store(value)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		last := data
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				if onError != nil {
+					onError(err)
+				}
+				continue
+			}
+			if bytes.Equal(data, last) {
+				continue
+			}
+			last = data
+
+			value, err := parse(data)
+			if err != nil {
+				if onError != nil {
+					onError(err)
+				}
+				continue
+			}
+			r.Store(value)
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReloaderUpdate(t *testing.T) {
+	r := NewReloader(0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.Update(func(n int) int { return n + 1 })
+		}()
+	}
+	wg.Wait()
+
+	if got := r.Load(); got != 50 {
+		t.Errorf("Expected 50, got %d", got)
+	}
+
+	r.Store(7)
+	if got := r.Load(); got != 7 {
+		t.Errorf("Expected 7, got %d", got)
+	}
+}
+
+func TestReloaderWatchFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "limit")
+	if err := os.WriteFile(path, []byte("10"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	parse := func(b []byte) (int, error) {
+		return strconv.Atoi(strings.TrimSpace(string(b)))
+	}
+	errs := make(chan error, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := NewReloader(0)
+	if err := r.WatchFile(ctx, path, 5*time.Millisecond, parse, func(err error) { errs <- err }); err != nil {
+		t.Fatalf("WatchFile: %v", err)
+	}
+	if got := r.Load(); got != 10 {
+		t.Fatalf("Expected initial value 10, got %d", got)
+	}
+
+	if err := os.WriteFile(path, []byte("25"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return r.Load() == 25 })
+
+	// Invalid contents are reported once and keep the current value
+	if err := os.WriteFile(path, []byte("oops"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errs:
+		var numErr *strconv.NumError
+		if !errors.As(err, &numErr) {
+			t.Errorf("Expected parse error, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected parse error to be reported")
+	}
+	if got := r.Load(); got != 25 {
+		t.Errorf("Expected value to stay 25, got %d", got)
+	}
+}
+
+func TestReloaderWatchFileMissing(t *testing.T) {
+	r := NewReloader(0)
+	err := r.WatchFile(context.Background(), filepath.Join(t.TempDir(), "missing"), time.Second, func(b []byte) (int, error) { return 0, nil }, nil)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected not-exist error, got %v", err)
+	}
+}
+
+// waitFor polls cond until it holds or two seconds pass.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Condition not met before deadline")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,428 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ginjigo/ginji"
+)
+
+// ErrNoRenderer is returned by Render when no Renderer middleware has stored
+// a renderer in the context.
+var ErrNoRenderer = errors.New("render: no renderer in context")
+
+// RenderConfig defines the configuration for template rendering.
+type RenderConfig struct {
+	// FS holds the templates, e.g. os.DirFS("templates"). Required.
+	FS fs.FS
+
+	// PagesDir is the directory of page templates. A page is rendered by
+	// its path relative to PagesDir without the extension, e.g. "home" or
+	// "blog/post".
+	// Default: "pages"
+	PagesDir string
+
+	// LayoutsDir is the directory of layout templates, parsed with every
+	// page.
+	// Default: "layouts"
+	LayoutsDir string
+
+	// PartialsDir is the directory of partial templates, parsed with every
+	// page.
+	// Default: "partials"
+	PartialsDir string
+
+	// Extension is the file extension of templates.
+	// Default: ".html"
+	Extension string
+
+	// Layout is the name of the layout file, without the extension, that
+	// pages are rendered through. The page fills in the blocks it defines,
+	// such as {{block "content" .}}. Pages are rendered on their own if the
+	// layout does not exist.
+	// Default: "base"
+	Layout string
+
+	// Funcs are added to the templates' function map.
+	Funcs template.FuncMap
+
+	// Reload re-parses a page's templates on every render, so edits show
+	// up without a restart. Enable it in development only.
+	// Default: false
+	Reload bool
+
+	// ContentType is the Content-Type of rendered pages.
+	// Default: "text/html; charset=utf-8"
+	ContentType string
+
+	// CSRFField is the name of the hidden input written by
+	// RenderData.CSRFField, matching a CSRF TokenLookup of "form:<name>".
+	// Default: "_csrf"
+	CSRFField string
+
+	// NonceFunc returns the request's Content-Security-Policy nonce, if any.
+	// Default: CSPNonce, the nonce of Secure with CSPNonce set
+	NonceFunc func(c *ginji.Context) string
+
+	// Data returns extra values made available to every page as
+	// RenderData.Values, e.g. the current locale.
+	Data func(c *ginji.Context) map[string]any
+
+	// ContextKey is the key used to store the Renderer in context.
+	// Default: "renderer"
+	ContextKey string
+
+	// SkipFunc allows skipping the middleware for certain requests.
+	SkipFunc Skipper
+}
+
+// DefaultRenderConfig returns default render configuration.
+func DefaultRenderConfig() RenderConfig {
+	return RenderConfig{
+		PagesDir:    "pages",
+		LayoutsDir:  "layouts",
+		PartialsDir: "partials",
+		Extension:   ".html",
+		Layout:      "base",
+		ContentType: "text/html; charset=utf-8",
+		CSRFField:   "_csrf",
+		NonceFunc:   CSPNonce,
+		ContextKey:  "renderer",
+	}
+}
+
+// RenderData is passed to every page. Handler data is available as .Data,
+// alongside the per-request values:
+//
+//	<form method="post">{{.CSRFField}} ...</form>
+//	{{range .Flashes}}<p class="{{.Kind}}">{{.Message}}</p>{{end}}
+//	<script nonce="{{.Nonce}}">...</script>
+//	<h1>{{.Data.Title}}</h1>
+type RenderData struct {
+	// Data is the value passed to Render.
+	Data any
+
+	// CSRFToken is the token set by the CSRF middleware.
+	CSRFToken string
+
+	// Nonce is the request's Content-Security-Policy nonce.
+	Nonce string
+
+	// User is the authenticated user, as returned by UserFrom.
+	User any
+
+	// Flashes are the flash messages added since the last page was
+	// rendered for the session.
+	Flashes []Flash
+
+	// Form is the form submitted before a failed validation, see
+	// FormHelper.
+	Form FormState
+
+	// Values holds the values returned by RenderConfig.Data.
+	Values map[string]any
+
+	csrfField string
+}
+
+// CSRFField returns a hidden form input holding the CSRF token.
+func (d RenderData) CSRFField() template.HTML {
+	return template.HTML(`<input type="hidden" name="` + template.HTMLEscapeString(d.csrfField) +
+		`" value="` + template.HTMLEscapeString(d.CSRFToken) + `">`)
+}
+
+// Renderer renders html/template pages composed of a layout, partials and
+// a page template:
+//
+//	templates/
+//		layouts/base.html    <html><body>{{template "nav.html" .}}{{block "content" .}}{{end}}</body></html>
+//		partials/nav.html
+//		pages/home.html      {{define "content"}}<h1>{{.Data.Title}}</h1>{{end}}
+//
+// Its middleware exposes it to handlers, which render pages with Render:
+//
+//	renderer, err := middleware.NewRenderer(middleware.RenderConfig{FS: os.DirFS("templates")})
+//	app.Use(renderer.Middleware())
+//	app.Get("/", func(c *ginji.Context) error {
+//		return middleware.Render(c, "home", ginji.H{"Title": "Home"})
+//	})
+type Renderer struct {
+	config RenderConfig
+	mu     sync.RWMutex
+	pages  map[string]*template.Template // by page name
+}
+
+// NewRenderer parses the templates in config.FS. It returns an error if the
+// templates cannot be read or parsed.
+func NewRenderer(config RenderConfig) (*Renderer, error) {
+	defaults := DefaultRenderConfig()
+	if config.FS == nil {
+		return nil, errors.New("render: FS is required")
+	}
+	if config.PagesDir == "" {
+		config.PagesDir = defaults.PagesDir
+	}
+	if config.LayoutsDir == "" {
+		config.LayoutsDir = defaults.LayoutsDir
+	}
+	if config.PartialsDir == "" {
+		config.PartialsDir = defaults.PartialsDir
+	}
+	if config.Extension == "" {
+		config.Extension = defaults.Extension
+	}
+	if config.Layout == "" {
+		config.Layout = defaults.Layout
+	}
+	if config.ContentType == "" {
+		config.ContentType = defaults.ContentType
+	}
+	if config.CSRFField == "" {
+		config.CSRFField = defaults.CSRFField
+	}
+	if config.NonceFunc == nil {
+		config.NonceFunc = defaults.NonceFunc
+	}
+	if config.ContextKey == "" {
+		config.ContextKey = defaults.ContextKey
+	}
+
+	r := &Renderer{config: config}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload re-parses all templates, e.g. after a deploy of new templates.
+func (r *Renderer) Reload() error {
+	shared, err := r.parseShared()
+	if err != nil {
+		return err
+	}
+	files, err := r.templateFiles(r.config.PagesDir)
+	if err != nil {
+		return err
+	}
+
+	pages := make(map[string]*template.Template, len(files))
+	for _, file := range files {
+		name := r.pageName(file)
+		t, err := r.parsePage(shared, file)
+		if err != nil {
+			return err
+		}
+		pages[name] = t
+	}
+
+	r.mu.Lock()
+	r.pages = pages
+	r.mu.Unlock()
+	return nil
+}
+
+// Middleware returns middleware that stores the Renderer in the context.
+func (r *Renderer) Middleware() ginji.Middleware {
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if r.config.SkipFunc != nil && r.config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		c.Set(r.config.ContextKey, r)
+		setContextValue(c, rendererContextKey, r)
+		return c.Next()
+	}
+}
+
+// Execute renders the page name with data to w, without per-request values.
+// It is useful for rendering emails and other pages outside a request.
+func (r *Renderer) Execute(w io.Writer, name string, data any) error {
+	t, err := r.page(name)
+	if err != nil {
+		return err
+	}
+	entry := r.config.Layout + r.config.Extension
+	if t.Lookup(entry) == nil {
+		entry = path.Base(r.pagePath(name))
+	}
+	return t.ExecuteTemplate(w, entry, data)
+}
+
+// Render renders the page name with status 200. See RenderStatus.
+func Render(c *ginji.Context, name string, data any) error {
+	return RenderStatus(c, ginji.StatusOK, name, data)
+}
+
+// RenderStatus renders the page name with the given status code, passing
+// data and the per-request values to the template as RenderData. The page
+// is rendered in full before anything is written, so a template error
+// leaves the response untouched for the error handler.
+func RenderStatus(c *ginji.Context, code int, name string, data any) error {
+	r, ok := RendererFrom(c)
+	if !ok {
+		return ErrNoRenderer
+	}
+
+	view := RenderData{
+		Data:      data,
+		CSRFToken: CSRFToken(c),
+		Nonce:     r.config.NonceFunc(c),
+		Flashes:   Flashes(c),
+		Form:      FormFrom(c),
+		csrfField: r.config.CSRFField,
+	}
+	view.User, _ = UserFrom(c)
+	if r.config.Data != nil {
+		view.Values = r.config.Data(c)
+	}
+
+	var buf bytes.Buffer
+	if err := r.Execute(&buf, name, view); err != nil {
+		return err
+	}
+
+	c.SetHeader("Content-Type", r.config.ContentType)
+	c.Status(code)
+	return c.Send(buf.Bytes())
+}
+
+// RendererFrom returns the Renderer stored by its middleware.
+func RendererFrom(c *ginji.Context) (*Renderer, bool) {
+	r, ok := c.Req.Context().Value(rendererContextKey).(*Renderer)
+	return r, ok
+}
+
+// page returns the parsed templates of the page name.
+func (r *Renderer) page(name string) (*template.Template, error) {
+	if r.config.Reload {
+		shared, err := r.parseShared()
+		if err != nil {
+			return nil, err
+		}
+		t, err := r.parsePage(shared, r.pagePath(name))
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("render: unknown page %q", name)
+		}
+		return t, err
+	}
+
+	r.mu.RLock()
+	t, ok := r.pages[name]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("render: unknown page %q", name)
+	}
+	return t, nil
+}
+
+// parseShared parses the layouts and partials.
+func (r *Renderer) parseShared() (*template.Template, error) {
+	shared := template.New("").Funcs(r.config.Funcs)
+	for _, dir := range []string{r.config.LayoutsDir, r.config.PartialsDir} {
+		files, err := r.templateFiles(dir)
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			continue
+		}
+		if _, err := shared.ParseFS(r.config.FS, files...); err != nil {
+			return nil, fmt.Errorf("render: %w", err)
+		}
+	}
+	return shared, nil
+}
+
+// parsePage parses the page file on top of a copy of shared.
+func (r *Renderer) parsePage(shared *template.Template, file string) (*template.Template, error) {
+	t, err := shared.Clone()
+	if err != nil {
+		return nil, fmt.Errorf("render: %w", err)
+	}
+	if _, err := t.ParseFS(r.config.FS, file); err != nil {
+		return nil, fmt.Errorf("render: %w", err)
+	}
+	return t, nil
+}
+
+// templateFiles returns the template files under dir, which may not exist.
+func (r *Renderer) templateFiles(dir string) ([]string, error) {
+	var files []string
+	err := fs.WalkDir(r.config.FS, dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if name == dir && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipDir
+			}
+			return err
+		}
+		if !d.IsDir() && strings.HasSuffix(name, r.config.Extension) {
+			files = append(files, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("render: %w", err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// pagePath returns the file of the page name.
+func (r *Renderer) pagePath(name string) string {
+	return path.Join(r.config.PagesDir, name) + r.config.Extension
+}
+
+// pageName returns the name of the page file.
+func (r *Renderer) pageName(file string) string {
+	name := strings.TrimPrefix(file, r.config.PagesDir+"/")
+	return strings.TrimSuffix(name, r.config.Extension)
+}
+
+// Flash is a one-time message shown on the next rendered page, such as
+// "Profile saved".
+type Flash struct {
+	// Kind is the message category, e.g. "success" or "error".
+	Kind string `json:"kind"`
+
+	// Message is the message text.
+	Message string `json:"message"`
+}
+
+// flashSessionKey is the session key holding pending flash messages.
+const flashSessionKey = "_flashes"
+
+// AddFlash adds a flash message to the session, to be shown on the next
+// rendered page, typically after a redirect. It returns ErrNoSession if no
+// session middleware has run.
+func AddFlash(c *ginji.Context, kind, message string) error {
+	session, ok := SessionFrom(c)
+	if !ok {
+		return ErrNoSession
+	}
+	flashes, _ := session.Get(flashSessionKey).([]Flash)
+	session.Set(flashSessionKey, append(flashes, Flash{Kind: kind, Message: message}))
+	return nil
+}
+
+// Flashes returns the pending flash messages and removes them from the
+// session. Render calls it for every page.
+func Flashes(c *ginji.Context) []Flash {
+	session, ok := SessionFrom(c)
+	if !ok {
+		return nil
+	}
+	flashes, _ := session.Get(flashSessionKey).([]Flash)
+	if len(flashes) > 0 {
+		session.Delete(flashSessionKey)
+	}
+	return flashes
+}
@@ -0,0 +1,168 @@
+package middleware
+
+import (
+	"errors"
+	"html/template"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/ginjigo/ginji"
+)
+
+func newTestRenderFS() fstest.MapFS {
+	return fstest.MapFS{
+		"layouts/base.html": {Data: []byte(`<html>{{template "nav.html" .}}{{block "content" .}}default{{end}}</html>`)},
+		"partials/nav.html": {Data: []byte(`<nav>{{with .User}}{{.}}{{else}}guest{{end}}</nav>`)},
+		"pages/home.html":   {Data: []byte(`{{define "content"}}<h1>{{.Data.Title | upper}}</h1>{{end}}`)},
+		"pages/blog/post.html": {Data: []byte(`{{define "content"}}{{range .Flashes}}<p class="{{.Kind}}">{{.Message}}</p>{{end}}` +
+			`<form>{{.CSRFField}}</form><script nonce="{{.Nonce}}"></script>{{.Values.locale}}{{end}}`)},
+		"pages/broken.html": {Data: []byte(`{{define "content"}}<p>{{index .Data.items 3}}</p>{{end}}`)},
+	}
+}
+
+func newTestRenderer(t *testing.T, fsys fstest.MapFS, reload bool) *Renderer {
+	t.Helper()
+	r, err := NewRenderer(RenderConfig{
+		FS:     fsys,
+		Funcs:  template.FuncMap{"upper": strings.ToUpper},
+		Reload: reload,
+		Data: func(c *ginji.Context) map[string]any {
+			return map[string]any{"locale": "en-GB"}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestRender(t *testing.T) {
+	session := mapSession{}
+	app := ginji.New()
+	app.Use(SecureWithConfig(SecureConfig{ContentSecurityPolicy: "script-src 'self'", CSPNonce: true}))
+	app.Use(newTestRenderer(t, newTestRenderFS(), false).Middleware())
+	app.Use(func(c *ginji.Context) error {
+		SetSession(c, session)
+		SetUser(c, "alice")
+		c.Set("csrf", "tok<en>")
+		return c.Next()
+	})
+	app.Get("/", func(c *ginji.Context) error {
+		return Render(c, "home", ginji.H{"Title": "Welcome"})
+	})
+	app.Post("/save", func(c *ginji.Context) error {
+		if err := AddFlash(c, "success", "Saved"); err != nil {
+			return err
+		}
+		return c.Text(ginji.StatusOK, "ok")
+	})
+	app.Get("/post", func(c *ginji.Context) error {
+		return RenderStatus(c, ginji.StatusCreated, "blog/post", nil)
+	})
+	app.Get("/broken", func(c *ginji.Context) error {
+		err := Render(c, "broken", ginji.H{"items": []int{1}})
+		if err == nil {
+			t.Error("Expected a template error")
+		}
+		return c.Text(ginji.StatusInternalServerError, "failed")
+	})
+	app.Get("/unknown", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, Render(c, "missing", nil).Error())
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	ginji.AssertHeader(t, w, "Content-Type", "text/html; charset=utf-8")
+	if w.Body.String() != "<html><nav>alice</nav><h1>WELCOME</h1></html>" {
+		t.Errorf("Unexpected page %q", w.Body.String())
+	}
+
+	ginji.PerformRequest(app, "POST", "/save", nil)
+	w = ginji.PerformRequest(app, "GET", "/post", nil)
+	ginji.AssertStatus(t, w, ginji.StatusCreated)
+	_, nonce, _ := strings.Cut(w.Header().Get("Content-Security-Policy"), "'nonce-")
+	nonce = strings.TrimSuffix(nonce, "'")
+	for _, want := range []string{
+		`<p class="success">Saved</p>`,
+		`<input type="hidden" name="_csrf" value="tok&lt;en&gt;">`,
+		`<script nonce="` + nonce + `">`,
+		"en-GB",
+	} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("Expected %s in %q", want, w.Body.String())
+		}
+	}
+
+	// Flashes are shown once
+	w = ginji.PerformRequest(app, "GET", "/post", nil)
+	if strings.Contains(w.Body.String(), "Saved") {
+		t.Error("Expected the flash to be consumed")
+	}
+
+	w = ginji.PerformRequest(app, "GET", "/broken", nil)
+	ginji.AssertBody(t, w, "failed")
+	if strings.Contains(w.Body.String(), "<html>") {
+		t.Error("Expected no partial page on a template error")
+	}
+
+	w = ginji.PerformRequest(app, "GET", "/unknown", nil)
+	ginji.AssertBody(t, w, `render: unknown page "missing"`)
+}
+
+func TestRenderReload(t *testing.T) {
+	fsys := newTestRenderFS()
+	r := newTestRenderer(t, fsys, true)
+
+	var buf strings.Builder
+	if err := r.Execute(&buf, "home", RenderData{Data: ginji.H{"Title": "one"}}); err != nil {
+		t.Fatal(err)
+	}
+	fsys["pages/home.html"] = &fstest.MapFile{Data: []byte(`{{define "content"}}edited{{end}}`)}
+	fsys["pages/new.html"] = &fstest.MapFile{Data: []byte(`{{define "content"}}new{{end}}`)}
+
+	buf.Reset()
+	if err := r.Execute(&buf, "home", RenderData{}); err != nil || !strings.Contains(buf.String(), "edited") {
+		t.Errorf("Expected the edited page, got %q, %v", buf.String(), err)
+	}
+	buf.Reset()
+	if err := r.Execute(&buf, "new", RenderData{}); err != nil || !strings.Contains(buf.String(), "new") {
+		t.Errorf("Expected the new page, got %q, %v", buf.String(), err)
+	}
+}
+
+func TestRenderWithoutLayout(t *testing.T) {
+	r, err := NewRenderer(RenderConfig{FS: fstest.MapFS{
+		"pages/plain.html": {Data: []byte(`plain {{.}}`)},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf strings.Builder
+	if err := r.Execute(&buf, "plain", "page"); err != nil || buf.String() != "plain page" {
+		t.Errorf("Expected the page on its own, got %q, %v", buf.String(), err)
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	if _, err := NewRenderer(RenderConfig{}); err == nil {
+		t.Error("Expected an error without FS")
+	}
+	if _, err := NewRenderer(RenderConfig{FS: fstest.MapFS{
+		"pages/bad.html": {Data: []byte(`{{if}}`)},
+	}}); err == nil {
+		t.Error("Expected a parse error")
+	}
+
+	app := ginji.New()
+	app.Get("/", func(c *ginji.Context) error {
+		if err := Render(c, "home", nil); !errors.Is(err, ErrNoRenderer) {
+			t.Errorf("Expected ErrNoRenderer, got %v", err)
+		}
+		if err := AddFlash(c, "info", "hi"); !errors.Is(err, ErrNoSession) {
+			t.Errorf("Expected ErrNoSession, got %v", err)
+		}
+		return c.Text(ginji.StatusOK, "ok")
+	})
+	ginji.PerformRequest(app, "GET", "/", nil)
+}
@@ -0,0 +1,167 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+
+	"github.com/ginjigo/ginji"
+)
+
+// RequestEchoConfig defines the configuration for request echo middleware.
+type RequestEchoConfig struct {
+	// Path is the path of the echo endpoint. It answers every method.
+	// Default: "/debug/request-echo"
+	Path string
+
+	// Auth guards the endpoint, e.g. BasicAuth or BearerAuth. Required.
+	Auth ginji.Middleware
+
+	// TrustedProxies are used to resolve the client IP, as in the other
+	// middleware, so the echo shows what they would see.
+	TrustedProxies []string
+
+	// MaxBodyBytes is the maximum number of body bytes echoed.
+	// Default: 64KB
+	MaxBodyBytes int64
+
+	// RedactHeaders are headers whose values are replaced with
+	// "[REDACTED]". Set to an empty, non-nil slice to echo all headers.
+	// Default: Authorization, Proxy-Authorization, Cookie
+	RedactHeaders []string
+
+	// EnableEnv gates the endpoint like ProfilerConfig.EnableEnv.
+	EnableEnv string
+
+	// SkipFunc allows skipping the middleware for certain requests.
+	SkipFunc Skipper
+}
+
+// DefaultRequestEchoConfig returns default request echo configuration.
+func DefaultRequestEchoConfig() RequestEchoConfig {
+	return RequestEchoConfig{
+		Path:          "/debug/request-echo",
+		MaxBodyBytes:  64 << 10, // 64 KB
+		RedactHeaders: []string{"Authorization", "Proxy-Authorization", "Cookie"},
+	}
+}
+
+// RequestEcho returns middleware that mounts a request echo endpoint
+// behind auth:
+//
+//	app.Use(middleware.RequestEcho(middleware.BasicAuth(operators)))
+//
+// It responds with the request as the application received it, which
+// helps debugging headers added or dropped by proxies and load balancers.
+func RequestEcho(auth ginji.Middleware) ginji.Middleware {
+	config := DefaultRequestEchoConfig()
+	config.Auth = auth
+	return RequestEchoWithConfig(config)
+}
+
+// RequestEchoWithConfig returns request echo middleware with custom
+// configuration. It panics if Auth is nil.
+func RequestEchoWithConfig(config RequestEchoConfig) ginji.Middleware {
+	defaults := DefaultRequestEchoConfig()
+	if config.Auth == nil {
+		panic("RequestEcho: Auth is required")
+	}
+	if config.Path == "" {
+		config.Path = defaults.Path
+	}
+	if config.MaxBodyBytes <= 0 {
+		config.MaxBodyBytes = defaults.MaxBodyBytes
+	}
+	if config.RedactHeaders == nil {
+		config.RedactHeaders = defaults.RedactHeaders
+	}
+
+	if !envEnabled(config.EnableEnv) {
+		return func(c *ginji.Context) error {
+			return c.Next()
+		}
+	}
+
+	redact := make(map[string]bool, len(config.RedactHeaders))
+	for _, name := range config.RedactHeaders {
+		redact[http.CanonicalHeaderKey(name)] = true
+	}
+
+	echo := func(c *ginji.Context) error {
+		r := c.Req
+
+		headers := make(map[string][]string, len(r.Header))
+		for name, values := range r.Header {
+			if redact[name] {
+				values = []string{"[REDACTED]"}
+			}
+			headers[name] = values
+		}
+
+		var body []byte
+		truncated := false
+		if r.Body != nil {
+			var err error
+			body, err = io.ReadAll(io.LimitReader(r.Body, config.MaxBodyBytes+1))
+			if err != nil {
+				c.AbortWithStatusJSON(ginji.StatusBadRequest, ginji.H{
+					"error": "Failed to read request body",
+				})
+				return nil
+			}
+			if int64(len(body)) > config.MaxBodyBytes {
+				body, truncated = body[:config.MaxBodyBytes], true
+			}
+		}
+
+		c.SetHeader("Cache-Control", "no-store")
+		c.AbortWithStatusJSON(ginji.StatusOK, ginji.H{
+			"method":         r.Method,
+			"url":            r.URL.String(),
+			"proto":          r.Proto,
+			"host":           r.Host,
+			"remote_addr":    r.RemoteAddr,
+			"client_ip":      clientIP(r, config.TrustedProxies),
+			"content_length": r.ContentLength,
+			"headers":        headers,
+			"trailers":       r.Trailer,
+			"tls":            echoTLS(r.TLS),
+			"body":           string(body),
+			"body_truncated": truncated,
+		})
+		return nil
+	}
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		if c.Req.URL.Path != config.Path {
+			return c.Next()
+		}
+		return ginji.Combine(config.Auth, echo)(c)
+	}
+}
+
+// echoTLS describes the TLS connection of a request, or returns nil for
+// plain HTTP.
+func echoTLS(state *tls.ConnectionState) ginji.H {
+	if state == nil {
+		return nil
+	}
+
+	peers := make([]string, len(state.PeerCertificates))
+	for i, cert := range state.PeerCertificates {
+		peers[i] = cert.Subject.String()
+	}
+	return ginji.H{
+		"version":             tls.VersionName(state.Version),
+		"cipher_suite":        tls.CipherSuiteName(state.CipherSuite),
+		"server_name":         state.ServerName,
+		"negotiated_protocol": state.NegotiatedProtocol,
+		"resumed":             state.DidResume,
+		"peer_certificates":   peers,
+	}
+}
@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ginjigo/ginji"
+)
+
+func TestRequestEcho(t *testing.T) {
+	config := DefaultRequestEchoConfig()
+	config.TrustedProxies = []string{"192.0.2.1"}
+	config.MaxBodyBytes = 5
+	config.Auth = BasicAuth(map[string]string{"ops": "secret"})
+	app := ginji.New()
+	app.Use(RequestEchoWithConfig(config))
+	app.Get("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "home")
+	})
+
+	w := ginji.NewRequest(app, "POST", "/debug/request-echo?x=1").
+		Header("Authorization", "Basic b3BzOnNlY3JldA==").
+		Header("X-Forwarded-For", "203.0.113.7, 10.0.0.1").
+		Header("X-Custom", "value").
+		Body(strings.NewReader("hello world")).
+		Do()
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	ginji.AssertHeader(t, w, "Cache-Control", "no-store")
+
+	var echo struct {
+		Method        string              `json:"method"`
+		URL           string              `json:"url"`
+		ClientIP      string              `json:"client_ip"`
+		Headers       map[string][]string `json:"headers"`
+		TLS           any                 `json:"tls"`
+		Body          string              `json:"body"`
+		BodyTruncated bool                `json:"body_truncated"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &echo); err != nil {
+		t.Fatal(err)
+	}
+
+	if echo.Method != "POST" || echo.URL != "/debug/request-echo?x=1" {
+		t.Errorf("Unexpected request line %s %s", echo.Method, echo.URL)
+	}
+	if echo.ClientIP != "203.0.113.7" {
+		t.Errorf("Expected forwarded client IP, got %q", echo.ClientIP)
+	}
+	if got := echo.Headers["Authorization"]; len(got) != 1 || got[0] != "[REDACTED]" {
+		t.Errorf("Expected Authorization to be redacted, got %v", got)
+	}
+	if got := echo.Headers["X-Custom"]; len(got) != 1 || got[0] != "value" {
+		t.Errorf("Expected X-Custom header, got %v", got)
+	}
+	if echo.TLS != nil {
+		t.Errorf("Expected no TLS info, got %v", echo.TLS)
+	}
+	if echo.Body != "hello" || !echo.BodyTruncated {
+		t.Errorf("Expected truncated body, got %q (truncated %v)", echo.Body, echo.BodyTruncated)
+	}
+}
+
+func TestRequestEchoRequiresAuth(t *testing.T) {
+	config := DefaultRequestEchoConfig()
+	config.Auth = BasicAuth(map[string]string{"ops": "secret"})
+	app := ginji.New()
+	app.Use(RequestEchoWithConfig(config))
+	app.Get("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "home")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/debug/request-echo", nil)
+	ginji.AssertStatus(t, w, ginji.StatusUnauthorized)
+
+	w = ginji.PerformRequest(app, "GET", "/", nil)
+	ginji.AssertBody(t, w, "home")
+}
+
+func TestRequestEchoEnableEnv(t *testing.T) {
+	config := DefaultRequestEchoConfig()
+	config.Auth = BasicAuth(map[string]string{"ops": "secret"})
+	config.EnableEnv = "TEST_REQUEST_ECHO"
+	t.Setenv("TEST_REQUEST_ECHO", "0")
+	app := ginji.New()
+	app.Use(RequestEchoWithConfig(config))
+
+	w := ginji.NewRequest(app, "GET", "/debug/request-echo").
+		Header("Authorization", "Basic b3BzOnNlY3JldA==").
+		Do()
+	ginji.AssertStatus(t, w, ginji.StatusNotFound)
+}
+
+func TestEchoTLS(t *testing.T) {
+	info := echoTLS(&tls.ConnectionState{
+		Version:            tls.VersionTLS13,
+		CipherSuite:        tls.TLS_AES_128_GCM_SHA256,
+		ServerName:         "api.example.com",
+		NegotiatedProtocol: "h2",
+	})
+	if info["version"] != "TLS 1.3" || info["cipher_suite"] != "TLS_AES_128_GCM_SHA256" || info["negotiated_protocol"] != "h2" {
+		t.Errorf("Unexpected TLS info %v", info)
+	}
+}
+
+func TestRequestEchoRequiresAuthConfig(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic without Auth")
+		}
+	}()
+	RequestEchoWithConfig(DefaultRequestEchoConfig())
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
+	"net/http"
 
 	"github.com/ginjigo/ginji"
 )
@@ -25,6 +26,9 @@ type RequestIDConfig struct {
 	// ContextKey is the key to store the request ID in context.
 	// Default: "request_id"
 	ContextKey string
+
+	// SkipFunc allows skipping request ID generation for certain requests.
+	SkipFunc Skipper
 }
 
 // DefaultRequestIDConfig returns default request ID configuration.
@@ -57,10 +61,17 @@ func RequestIDWithConfig(config RequestIDConfig) ginji.Middleware {
 	if config.ContextKey == "" {
 		config.ContextKey = "request_id"
 	}
+	requestHeader := http.CanonicalHeaderKey(config.RequestIDHeader)
+	responseHeader := http.CanonicalHeaderKey(config.ResponseIDHeader)
 
 	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
 		// Check if request already has an ID
-		requestID := c.Header(config.RequestIDHeader)
+		requestID := c.Req.Header.Get(requestHeader)
 		if requestID == "" {
 			// Generate new ID
 			requestID = config.Generator()
@@ -68,9 +79,10 @@ func RequestIDWithConfig(config RequestIDConfig) ginji.Middleware {
 
 		// Store in context
 		c.Set(config.ContextKey, requestID)
+		SetRequestID(c, requestID)
 
 		// Add to response header
-		c.SetHeader(config.ResponseIDHeader, requestID)
+		c.Res.Header().Set(responseHeader, requestID)
 
 		return c.Next()
 	}
@@ -78,8 +90,8 @@ func RequestIDWithConfig(config RequestIDConfig) ginji.Middleware {
 
 // generateUUID generates a UUID-like random identifier.
 func generateUUID() string {
-	b := make([]byte, 16)
-	if _, err := rand.Read(b); err != nil {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
 		panic(fmt.Sprintf("failed to generate UUID: %v", err))
 	}
 
@@ -87,16 +99,20 @@ func generateUUID() string {
 	b[6] = (b[6] & 0x0f) | 0x40 // Version 4
 	b[8] = (b[8] & 0x3f) | 0x80 // Variant is 10
 
-	return fmt.Sprintf("%s-%s-%s-%s-%s",
-		hex.EncodeToString(b[0:4]),
-		hex.EncodeToString(b[4:6]),
-		hex.EncodeToString(b[6:8]),
-		hex.EncodeToString(b[8:10]),
-		hex.EncodeToString(b[10:16]),
-	)
+	var buf [36]byte
+	hex.Encode(buf[0:8], b[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], b[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], b[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], b[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], b[10:16])
+	return string(buf[:])
 }
 
 // GetRequestID is a helper to get the request ID from context.
 func GetRequestID(c *ginji.Context) string {
-	return c.GetString("request_id")
+	return RequestIDFrom(c)
 }
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/ginjigo/ginji"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestGenerateUUID(t *testing.T) {
+	seen := make(map[string]bool)
+	for range 100 {
+		id := generateUUID()
+		if !uuidPattern.MatchString(id) {
+			t.Fatalf("Expected a version 4 UUID, got %q", id)
+		}
+		if seen[id] {
+			t.Fatalf("Duplicate ID %q", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestRequestIDHeaders(t *testing.T) {
+	app := ginji.New()
+	app.Use(RequestIDWithConfig(RequestIDConfig{
+		RequestIDHeader:  "x-correlation-id",
+		ResponseIDHeader: "x-trace-id",
+	}))
+	app.Get("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, RequestIDFrom(c))
+	})
+
+	w := ginji.NewRequest(app, "GET", "/").Header("X-Correlation-ID", "abc").Do()
+	ginji.AssertBody(t, w, "abc")
+	ginji.AssertHeader(t, w, "X-Trace-Id", "abc")
+
+	w = ginji.PerformRequest(app, "GET", "/", nil)
+	if id := w.Header().Get("X-Trace-Id"); !uuidPattern.MatchString(id) {
+		t.Errorf("Expected a generated ID, got %q", id)
+	}
+}
+
+func TestRequestIDSkipAllocs(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	mw := RequestIDWithConfig(RequestIDConfig{
+		SkipFunc: func(c *ginji.Context) bool { return true },
+	})
+	if n := middlewareAllocs(t, req, mw); n > 0 {
+		t.Errorf("Expected no allocations when skipped, got %v", n)
+	}
+}
+
+func BenchmarkRequestID(b *testing.B) {
+	benchmarkMiddleware(b, httptest.NewRequest("GET", "/", nil), RequestID())
+}
+
+func BenchmarkRequestIDPropagated(b *testing.B) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Request-ID", "0f8fad5b-d9cb-469f-a165-70867728950e")
+	benchmarkMiddleware(b, req, RequestID())
+}
+
+func BenchmarkGenerateUUID(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		generateUUID()
+	}
+}
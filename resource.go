@@ -0,0 +1,307 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/ginjigo/ginji"
+)
+
+// ResourceFormat is a hypermedia document format.
+type ResourceFormat int
+
+// Resource document formats.
+const (
+	ResourceJSONAPI ResourceFormat = iota // JSON:API, application/vnd.api+json
+	ResourceHAL                           // HAL, application/hal+json
+)
+
+// resourceConfigKey stores the ResourceConfig of the request.
+const resourceConfigKey = "resource_config"
+
+// ResourceConfig defines the configuration for the resource document
+// middleware.
+type ResourceConfig struct {
+	// Format is the document format of responses written with
+	// WriteResource.
+	// Default: ResourceJSONAPI
+	Format ResourceFormat
+
+	// BaseURL is prepended to the self links of resources, which are
+	// "<BaseURL>/<type>/<id>".
+	// Default: ""
+	BaseURL string
+
+	// SkipValidation disables validation of JSON:API request documents.
+	// Default: false
+	SkipValidation bool
+
+	// MaxBodyBytes is the maximum size of request documents.
+	// Default: 1MB
+	MaxBodyBytes int64
+
+	// SkipFunc allows skipping the middleware for certain requests.
+	SkipFunc Skipper
+}
+
+// ResourceLinker is implemented by resources with links besides self,
+// keyed by relation name.
+type ResourceLinker interface {
+	ResourceLinks() map[string]string
+}
+
+// resourceNode is an annotated struct prepared for encoding.
+type resourceNode struct {
+	typ           string
+	id            string
+	attributes    map[string]json.RawMessage
+	relationships []resourceRelation
+	links         map[string]string
+}
+
+// resourceRelation is a relationship of a resource.
+type resourceRelation struct {
+	name  string
+	many  bool
+	nodes []*resourceNode // empty for a nil to-one relationship
+}
+
+// DefaultResourceConfig returns default resource document configuration.
+func DefaultResourceConfig() ResourceConfig {
+	return ResourceConfig{
+		Format:       ResourceJSONAPI,
+		MaxBodyBytes: 1 << 20, // 1 MB
+	}
+}
+
+// Resources returns resource document middleware for format.
+func Resources(format ResourceFormat) ginji.Middleware {
+	config := DefaultResourceConfig()
+	config.Format = format
+	return ResourceWithConfig(config)
+}
+
+// ResourceWithConfig returns middleware for JSON:API or HAL APIs.
+// Handlers write structs annotated with "jsonapi" tags using
+// WriteResource, which renders them in the configured format:
+//
+//	type Article struct {
+//		ID     string  `jsonapi:"primary,articles"`
+//		Title  string  `jsonapi:"attr,title"`
+//		Author *Person `jsonapi:"relation,author"`
+//	}
+//
+// For JSON:API, the middleware also validates request documents and the
+// media type rules of the specification, and handlers decode request
+// documents with BindResource. Validation failures and JSONAPIErrors
+// returned by handlers are rendered as JSON:API error documents.
+func ResourceWithConfig(config ResourceConfig) ginji.Middleware {
+	defaults := DefaultResourceConfig()
+	if config.MaxBodyBytes <= 0 {
+		config.MaxBodyBytes = defaults.MaxBodyBytes
+	}
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		c.Set(resourceConfigKey, config)
+		if config.Format == ResourceJSONAPI && !config.SkipValidation {
+			if err := validateJSONAPIRequest(c, config.MaxBodyBytes); err != nil {
+				writeJSONAPIError(c, err)
+				c.Abort()
+				return nil
+			}
+		}
+
+		err := c.Next()
+		var apiErr *JSONAPIError
+		if errors.As(err, &apiErr) {
+			writeJSONAPIError(c, apiErr)
+			return nil
+		}
+		return err
+	}
+}
+
+// WriteResource writes v, an annotated struct, a pointer to one or a
+// slice of them, as a document in the format configured by the resource
+// middleware, or as JSON:API without it.
+func WriteResource(c *ginji.Context, code int, v any) error {
+	config := resourceConfigFrom(c)
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+
+	self := c.Req.URL.RequestURI()
+	var doc any
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		nodes := make([]*resourceNode, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			node, err := buildResourceNode(rv.Index(i), 0)
+			if err != nil {
+				return err
+			}
+			nodes = append(nodes, node)
+		}
+		if config.Format == ResourceHAL {
+			doc = halCollection(config, self, nodes)
+		} else {
+			doc = jsonapiDocument(config, self, nodes, true)
+		}
+	} else {
+		node, err := buildResourceNode(rv, 0)
+		if err != nil {
+			return err
+		}
+		if config.Format == ResourceHAL {
+			doc = halResource(config, node)
+		} else {
+			doc = jsonapiDocument(config, self, []*resourceNode{node}, false)
+		}
+	}
+
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	if config.Format == ResourceHAL {
+		c.SetHeader("Content-Type", "application/hal+json")
+	} else {
+		c.SetHeader("Content-Type", jsonapiMediaType)
+	}
+	c.Res.WriteHeader(code)
+	_, err = c.Res.Write(body)
+	return err
+}
+
+// resourceConfigFrom returns the configuration set by the middleware.
+func resourceConfigFrom(c *ginji.Context) ResourceConfig {
+	if val, ok := c.Get(resourceConfigKey); ok {
+		if config, ok := val.(ResourceConfig); ok {
+			return config
+		}
+	}
+	return DefaultResourceConfig()
+}
+
+// resourceTag is a parsed "jsonapi" struct tag.
+type resourceTag struct {
+	kind      string // primary, attr or relation
+	name      string
+	omitempty bool
+}
+
+// parseResourceTag parses the tag of field, reporting false for fields
+// without one.
+func parseResourceTag(field reflect.StructField) (resourceTag, bool) {
+	value, ok := field.Tag.Lookup("jsonapi")
+	if !ok || value == "-" {
+		return resourceTag{}, false
+	}
+	parts := strings.Split(value, ",")
+	tag := resourceTag{kind: parts[0]}
+	if len(parts) > 1 {
+		tag.name = parts[1]
+	}
+	for _, opt := range parts[2:] {
+		tag.omitempty = tag.omitempty || opt == "omitempty"
+	}
+	return tag, true
+}
+
+// maxResourceNesting bounds relationship recursion on cyclic data.
+const maxResourceNesting = 8
+
+// buildResourceNode prepares an annotated struct for encoding.
+func buildResourceNode(v reflect.Value, depth int) (*resourceNode, error) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, errors.New("resource: nil resource")
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("resource: %s is not a struct", v.Type())
+	}
+	if depth > maxResourceNesting {
+		return nil, errors.New("resource: relationships nested too deeply")
+	}
+
+	node := &resourceNode{attributes: make(map[string]json.RawMessage)}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag, ok := parseResourceTag(t.Field(i))
+		if !ok {
+			continue
+		}
+		field := v.Field(i)
+
+		switch tag.kind {
+		case "primary":
+			node.typ = tag.name
+			if !field.IsZero() {
+				node.id = fmt.Sprint(field.Interface())
+			}
+		case "attr":
+			if tag.omitempty && field.IsZero() {
+				continue
+			}
+			value, err := json.Marshal(field.Interface())
+			if err != nil {
+				return nil, fmt.Errorf("resource: attribute %q: %w", tag.name, err)
+			}
+			node.attributes[tag.name] = value
+		case "relation":
+			relation := resourceRelation{name: tag.name}
+			if field.Kind() == reflect.Slice || field.Kind() == reflect.Array {
+				relation.many = true
+				for j := 0; j < field.Len(); j++ {
+					related, err := buildResourceNode(field.Index(j), depth+1)
+					if err != nil {
+						return nil, err
+					}
+					relation.nodes = append(relation.nodes, related)
+				}
+			} else if !(field.Kind() == reflect.Pointer && field.IsNil()) {
+				related, err := buildResourceNode(field, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				relation.nodes = []*resourceNode{related}
+			} else if tag.omitempty {
+				continue
+			}
+			node.relationships = append(node.relationships, relation)
+		default:
+			return nil, fmt.Errorf("resource: unknown annotation %q on %s.%s", tag.kind, t.Name(), t.Field(i).Name)
+		}
+	}
+	if node.typ == "" {
+		return nil, fmt.Errorf("resource: %s has no primary annotation", t.Name())
+	}
+
+	if linker, ok := v.Interface().(ResourceLinker); ok {
+		node.links = linker.ResourceLinks()
+	} else if v.CanAddr() {
+		if linker, ok := v.Addr().Interface().(ResourceLinker); ok {
+			node.links = linker.ResourceLinks()
+		}
+	}
+	return node, nil
+}
+
+// selfLink returns the self link of node, or "" if it has no ID.
+func (node *resourceNode) selfLink(config ResourceConfig) string {
+	if node.id == "" {
+		return ""
+	}
+	return strings.TrimSuffix(config.BaseURL, "/") + "/" + node.typ + "/" + node.id
+}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ginjigo/ginji"
+)
+
+type testPerson struct {
+	ID   int    `jsonapi:"primary,people"`
+	Name string `jsonapi:"attr,name"`
+}
+
+type testComment struct {
+	ID   string `jsonapi:"primary,comments"`
+	Body string `jsonapi:"attr,body"`
+}
+
+type testArticle struct {
+	ID       string         `jsonapi:"primary,articles"`
+	Title    string         `jsonapi:"attr,title"`
+	Draft    bool           `jsonapi:"attr,draft,omitempty"`
+	Author   *testPerson    `jsonapi:"relation,author"`
+	Comments []*testComment `jsonapi:"relation,comments"`
+	Internal string
+}
+
+func (a *testArticle) ResourceLinks() map[string]string {
+	return map[string]string{"html": "https://example.com/a/" + a.ID}
+}
+
+func testArticles() []*testArticle {
+	author := &testPerson{ID: 9, Name: "Dan"}
+	return []*testArticle{
+		{ID: "1", Title: "JSON:API", Author: author, Comments: []*testComment{{ID: "5", Body: "First!"}}},
+		{ID: "2", Title: "HAL", Author: author},
+	}
+}
+
+func TestWriteResourceWithoutMiddleware(t *testing.T) {
+	app := ginji.New()
+	app.Get("/people/1", func(c *ginji.Context) error {
+		return WriteResource(c, ginji.StatusOK, testPerson{ID: 1, Name: "Ann"})
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/people/1", nil)
+	ginji.AssertHeader(t, w, "Content-Type", "application/vnd.api+json")
+	ginji.AssertBody(t, w, `{"data":{"type":"people","id":"1","attributes":{"name":"Ann"},"links":{"self":"/people/1"}}}`)
+}
+
+func TestWriteResourceInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		want  string
+	}{
+		{"not a struct", 42, "is not a struct"},
+		{"no primary", struct {
+			Name string `jsonapi:"attr,name"`
+		}{}, "has no primary annotation"},
+		{"unknown annotation", struct {
+			ID string `jsonapi:"key,things"`
+		}{}, `unknown annotation "key"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := ginji.New()
+			var got error
+			app.Get("/", func(c *ginji.Context) error {
+				got = WriteResource(c, ginji.StatusOK, tt.value)
+				return nil
+			})
+			ginji.PerformRequest(app, "GET", "/", nil)
+			if got == nil || !strings.Contains(got.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, got)
+			}
+		})
+	}
+}
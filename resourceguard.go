@@ -0,0 +1,295 @@
+package middleware
+
+import (
+	"bufio"
+	"math"
+	"os"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// ResourceUsage is a sample of the process's resource usage.
+type ResourceUsage struct {
+	// MemoryBytes is the memory mapped by the Go runtime, as counted
+	// against GOMEMLIMIT.
+	MemoryBytes uint64
+
+	// MemoryLimit is the soft memory limit, or 0 if there is none.
+	MemoryLimit uint64
+
+	// Goroutines is the number of live goroutines.
+	Goroutines int
+
+	// CPUThrottled is the fraction of cgroup CPU periods that were
+	// throttled since the previous sample, or 0 if unknown.
+	CPUThrottled float64
+}
+
+// ResourceGuardConfig defines the configuration for resource pressure load
+// shedding.
+type ResourceGuardConfig struct {
+	// MaxMemoryRatio sheds load when memory use reaches this fraction of
+	// MemoryLimit.
+	// Default: 0.9
+	MaxMemoryRatio float64
+
+	// MemoryLimit is the memory MaxMemoryRatio applies to. With no limit
+	// set here or in GOMEMLIMIT, memory is not checked.
+	// Default: GOMEMLIMIT
+	MemoryLimit uint64
+
+	// MaxGoroutines sheds load when the goroutine count reaches it.
+	// Default: 0 (not checked)
+	MaxGoroutines int
+
+	// MaxCPUThrottled sheds load when the fraction of throttled cgroup CPU
+	// periods reaches it, e.g. 0.2.
+	// Default: 0 (not checked)
+	MaxCPUThrottled float64
+
+	// CgroupCPUStat is the cgroup v2 cpu.stat file read for throttling.
+	// Default: "/sys/fs/cgroup/cpu.stat"
+	CgroupCPUStat string
+
+	// SampleInterval is how often usage is sampled. Samples are taken by
+	// requests, not in the background.
+	// Default: 1 second
+	SampleInterval time.Duration
+
+	// Sample reads the resource usage.
+	// Default: the Go runtime metrics and CgroupCPUStat
+	Sample func() ResourceUsage
+
+	// Critical selects requests that are never shed, e.g. health checks.
+	Critical Skipper
+
+	// MaxDelay holds shed requests for up to this long, waiting for the
+	// pressure to ease, before rejecting them.
+	// Default: 0 (reject at once)
+	MaxDelay time.Duration
+
+	// RetryAfter is sent in the Retry-After header of shed requests.
+	// Default: 5 seconds
+	RetryAfter time.Duration
+
+	// OnShed is called for every shed request with the reason, "memory",
+	// "goroutines" or "cpu".
+	OnShed func(c *ginji.Context, reason string)
+
+	// SkipFunc allows skipping the guard for certain requests.
+	SkipFunc Skipper
+}
+
+// DefaultResourceGuardConfig returns default resource guard configuration.
+func DefaultResourceGuardConfig() ResourceGuardConfig {
+	return ResourceGuardConfig{
+		MaxMemoryRatio: 0.9,
+		CgroupCPUStat:  "/sys/fs/cgroup/cpu.stat",
+		SampleInterval: time.Second,
+		RetryAfter:     5 * time.Second,
+	}
+}
+
+// ResourceGuard returns middleware that sheds requests under memory
+// pressure.
+func ResourceGuard() ginji.Middleware {
+	return ResourceGuardWithConfig(DefaultResourceGuardConfig())
+}
+
+// ResourceGuardWithConfig returns middleware that sheds requests with 503
+// while memory, goroutine or CPU usage is over its threshold, so that the
+// process recovers instead of failing every request:
+//
+//	app.Use(middleware.ResourceGuardWithConfig(middleware.ResourceGuardConfig{
+//		MaxGoroutines: 20000,
+//		Critical:      func(c *ginji.Context) bool { return strings.HasPrefix(c.Req.URL.Path, "/health") },
+//	}))
+func ResourceGuardWithConfig(config ResourceGuardConfig) ginji.Middleware {
+	defaults := DefaultResourceGuardConfig()
+	if config.MaxMemoryRatio <= 0 {
+		config.MaxMemoryRatio = defaults.MaxMemoryRatio
+	}
+	if config.CgroupCPUStat == "" {
+		config.CgroupCPUStat = defaults.CgroupCPUStat
+	}
+	if config.SampleInterval <= 0 {
+		config.SampleInterval = defaults.SampleInterval
+	}
+	if config.Sample == nil {
+		config.Sample = newResourceSampler(config.CgroupCPUStat, config.MaxCPUThrottled > 0)
+	}
+	if config.RetryAfter <= 0 {
+		config.RetryAfter = defaults.RetryAfter
+	}
+
+	guard := &resourceGuard{config: config}
+	retryAfter := strconv.FormatInt(int64(math.Ceil(config.RetryAfter.Seconds())), 10)
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+		if config.Critical != nil && config.Critical(c) {
+			return c.Next()
+		}
+
+		reason := guard.pressure()
+		if reason != "" && config.MaxDelay > 0 {
+			reason = guard.wait(c, reason)
+		}
+		if reason == "" {
+			return c.Next()
+		}
+
+		if config.OnShed != nil {
+			config.OnShed(c, reason)
+		}
+		c.SetHeader("Retry-After", retryAfter)
+		c.AbortWithStatusJSON(ginji.StatusServiceUnavailable, ginji.H{
+			"error": "Server overloaded",
+		})
+		return nil
+	}
+}
+
+// resourceGuard holds the latest usage sample.
+type resourceGuard struct {
+	config ResourceGuardConfig
+
+	mu      sync.Mutex // held while sampling
+	sampled atomic.Int64
+	reason  atomic.Pointer[string]
+}
+
+// pressure returns the reason resources are under pressure, or "",
+// sampling usage if the last sample is stale. Only one request samples at
+// a time; the others use the previous sample.
+func (g *resourceGuard) pressure() string {
+	now := time.Now().UnixNano()
+	if now-g.sampled.Load() >= int64(g.config.SampleInterval) && g.mu.TryLock() {
+		if now-g.sampled.Load() >= int64(g.config.SampleInterval) {
+			reason := g.evaluate(g.config.Sample())
+			g.reason.Store(&reason)
+			g.sampled.Store(now)
+		}
+		g.mu.Unlock()
+	}
+	if reason := g.reason.Load(); reason != nil {
+		return *reason
+	}
+	return ""
+}
+
+// evaluate returns the first threshold usage crosses.
+func (g *resourceGuard) evaluate(usage ResourceUsage) string {
+	limit := usage.MemoryLimit
+	if g.config.MemoryLimit > 0 {
+		limit = g.config.MemoryLimit
+	}
+	switch {
+	case limit > 0 && float64(usage.MemoryBytes) >= g.config.MaxMemoryRatio*float64(limit):
+		return "memory"
+	case g.config.MaxGoroutines > 0 && usage.Goroutines >= g.config.MaxGoroutines:
+		return "goroutines"
+	case g.config.MaxCPUThrottled > 0 && usage.CPUThrottled >= g.config.MaxCPUThrottled:
+		return "cpu"
+	}
+	return ""
+}
+
+// wait holds the request until the pressure eases or MaxDelay passes, and
+// returns the remaining reason for shedding it.
+func (g *resourceGuard) wait(c *ginji.Context, reason string) string {
+	deadline := time.NewTimer(g.config.MaxDelay)
+	defer deadline.Stop()
+	ticker := time.NewTicker(g.config.SampleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if reason = g.pressure(); reason == "" {
+				return ""
+			}
+		case <-deadline.C:
+			return reason
+		case <-c.Req.Context().Done():
+			return reason
+		}
+	}
+}
+
+// newResourceSampler returns a sampler of the Go runtime metrics, and of
+// the cgroup CPU throttling in cpuStat if cpu is set.
+func newResourceSampler(cpuStat string, cpu bool) func() ResourceUsage {
+	var (
+		mu                         sync.Mutex
+		lastPeriods, lastThrottled uint64
+	)
+	samples := []metrics.Sample{
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/memory/classes/heap/released:bytes"},
+		{Name: "/sched/goroutines:goroutines"},
+		{Name: "/gc/gomemlimit:bytes"},
+	}
+
+	return func() ResourceUsage {
+		mu.Lock()
+		defer mu.Unlock()
+
+		metrics.Read(samples)
+		usage := ResourceUsage{
+			MemoryBytes: samples[0].Value.Uint64() - samples[1].Value.Uint64(),
+			Goroutines:  int(samples[2].Value.Uint64()),
+		}
+		if limit := samples[3].Value.Uint64(); limit < math.MaxInt64 {
+			usage.MemoryLimit = limit
+		}
+
+		if cpu {
+			if periods, throttled, ok := readCgroupThrottling(cpuStat); ok {
+				if lastPeriods > 0 && periods > lastPeriods {
+					usage.CPUThrottled = float64(throttled-lastThrottled) / float64(periods-lastPeriods)
+				}
+				lastPeriods, lastThrottled = periods, throttled
+			}
+		}
+		return usage
+	}
+}
+
+// readCgroupThrottling reads the nr_periods and nr_throttled counters of a
+// cgroup v2 cpu.stat file.
+func readCgroupThrottling(path string) (periods, throttled uint64, ok bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, false
+	}
+	defer f.Close()
+
+	found := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		name, value, _ := strings.Cut(scanner.Text(), " ")
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			continue
+		}
+		switch name {
+		case "nr_periods":
+			periods = n
+			found++
+		case "nr_throttled":
+			throttled = n
+			found++
+		}
+	}
+	return periods, throttled, found == 2
+}
@@ -0,0 +1,184 @@
+package middleware
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+func TestResourceGuardThresholds(t *testing.T) {
+	tests := []struct {
+		name   string
+		config ResourceGuardConfig
+		usage  ResourceUsage
+		reason string
+	}{
+		{
+			name:  "memory under limit",
+			usage: ResourceUsage{MemoryBytes: 800, MemoryLimit: 1000},
+		},
+		{
+			name:   "memory over limit",
+			usage:  ResourceUsage{MemoryBytes: 950, MemoryLimit: 1000},
+			reason: "memory",
+		},
+		{
+			name:   "configured memory limit",
+			config: ResourceGuardConfig{MemoryLimit: 500, MaxMemoryRatio: 0.5},
+			usage:  ResourceUsage{MemoryBytes: 300},
+			reason: "memory",
+		},
+		{
+			name:  "no memory limit",
+			usage: ResourceUsage{MemoryBytes: 1 << 40},
+		},
+		{
+			name:   "goroutines",
+			config: ResourceGuardConfig{MaxGoroutines: 100},
+			usage:  ResourceUsage{Goroutines: 150},
+			reason: "goroutines",
+		},
+		{
+			name:   "cpu throttling",
+			config: ResourceGuardConfig{MaxCPUThrottled: 0.2},
+			usage:  ResourceUsage{CPUThrottled: 0.5},
+			reason: "cpu",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var shed string
+			config := tt.config
+			config.Sample = func() ResourceUsage { return tt.usage }
+			config.OnShed = func(c *ginji.Context, reason string) { shed = reason }
+			app := ginji.New()
+			app.Use(ResourceGuardWithConfig(config))
+			app.Get("/", func(c *ginji.Context) error {
+				return c.Text(ginji.StatusOK, "ok")
+			})
+
+			w := ginji.PerformRequest(app, "GET", "/", nil)
+			if tt.reason == "" {
+				ginji.AssertStatus(t, w, ginji.StatusOK)
+				return
+			}
+			ginji.AssertStatus(t, w, ginji.StatusServiceUnavailable)
+			ginji.AssertHeader(t, w, "Retry-After", "5")
+			if shed != tt.reason {
+				t.Errorf("Expected reason %q, got %q", tt.reason, shed)
+			}
+		})
+	}
+}
+
+func TestResourceGuardCritical(t *testing.T) {
+	app := ginji.New()
+	app.Use(ResourceGuardWithConfig(ResourceGuardConfig{
+		MaxGoroutines: 1,
+		Sample:        func() ResourceUsage { return ResourceUsage{Goroutines: 10} },
+		Critical:      func(c *ginji.Context) bool { return c.Req.URL.Path == "/health" },
+	}))
+	app.Get("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+	app.Get("/health", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "up")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/health", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+
+	w = ginji.PerformRequest(app, "GET", "/", nil)
+	ginji.AssertStatus(t, w, ginji.StatusServiceUnavailable)
+}
+
+func TestResourceGuardSampleInterval(t *testing.T) {
+	var samples atomic.Int32
+	app := ginji.New()
+	app.Use(ResourceGuardWithConfig(ResourceGuardConfig{
+		SampleInterval: time.Hour,
+		Sample: func() ResourceUsage {
+			samples.Add(1)
+			return ResourceUsage{}
+		},
+	}))
+	app.Get("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	for range 5 {
+		ginji.PerformRequest(app, "GET", "/", nil)
+	}
+	if n := samples.Load(); n != 1 {
+		t.Errorf("Expected one sample per interval, got %d", n)
+	}
+}
+
+func TestResourceGuardDelay(t *testing.T) {
+	var goroutines atomic.Int32
+	goroutines.Store(100)
+	app := ginji.New()
+	app.Use(ResourceGuardWithConfig(ResourceGuardConfig{
+		MaxGoroutines:  50,
+		SampleInterval: 10 * time.Millisecond,
+		MaxDelay:       time.Second,
+		Sample: func() ResourceUsage {
+			return ResourceUsage{Goroutines: int(goroutines.Load())}
+		},
+	}))
+	app.Get("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	time.AfterFunc(50*time.Millisecond, func() { goroutines.Store(10) })
+
+	w := ginji.PerformRequest(app, "GET", "/", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+}
+
+func TestResourceGuardDelayExpires(t *testing.T) {
+	app := ginji.New()
+	app.Use(ResourceGuardWithConfig(ResourceGuardConfig{
+		MaxGoroutines:  50,
+		SampleInterval: 10 * time.Millisecond,
+		MaxDelay:       50 * time.Millisecond,
+		Sample:         func() ResourceUsage { return ResourceUsage{Goroutines: 100} },
+	}))
+	app.Get("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	start := time.Now()
+	w := ginji.PerformRequest(app, "GET", "/", nil)
+	ginji.AssertStatus(t, w, ginji.StatusServiceUnavailable)
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Expected the request to be held for MaxDelay, took %s", elapsed)
+	}
+}
+
+func TestResourceSamplerCgroup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cpu.stat")
+	write := func(periods, throttled int) {
+		data := "usage_usec 100\nnr_periods " + strconv.Itoa(periods) + "\nnr_throttled " + strconv.Itoa(throttled) + "\nthrottled_usec 5\n"
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sample := newResourceSampler(path, true)
+	write(100, 10)
+	if usage := sample(); usage.CPUThrottled != 0 || usage.Goroutines == 0 || usage.MemoryBytes == 0 {
+		t.Errorf("Unexpected first sample %+v", usage)
+	}
+
+	write(200, 60)
+	if usage := sample(); usage.CPUThrottled != 0.5 {
+		t.Errorf("Expected half of the periods throttled, got %v", usage.CPUThrottled)
+	}
+}
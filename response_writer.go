@@ -0,0 +1,299 @@
+package middleware
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+
+	"github.com/ginjigo/ginji"
+)
+
+// ResponseWriter is the instrumented http.ResponseWriter shared by the
+// middleware in this package. It records the status code and body size,
+// runs hooks right before the headers are sent and around body writes,
+// passes body writes to observers and can cap the body size, while still
+// supporting Flush, Hijack and http.ResponseController.
+//
+// Middleware get the writer for a request with WrapResponseWriter, so
+// several middleware share one wrapper instead of stacking their own.
+type ResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	size        int64
+	limit       int64
+	wroteHeader bool
+	heldHeader  bool
+	hijacked    bool
+	exceeded    bool
+	before      []func(http.Header)
+	observers   []func([]byte)
+	writeHooks  []func(int) error
+	errorHooks  []func(error) error
+}
+
+// NewResponseWriter wraps w.
+func NewResponseWriter(w http.ResponseWriter) *ResponseWriter {
+	return &ResponseWriter{ResponseWriter: w}
+}
+
+// WrapResponseWriter returns the ResponseWriter of the request, installing
+// one as c.Res if it is not already wrapped.
+func WrapResponseWriter(c *ginji.Context) *ResponseWriter {
+	if rw, ok := c.Res.(*ResponseWriter); ok {
+		return rw
+	}
+	rw := NewResponseWriter(c.Res)
+	c.Res = rw
+	return rw
+}
+
+// Before registers fn to run once, right before the headers are sent.
+// Middleware use it to adjust headers that handlers set after the
+// middleware itself has run.
+func (w *ResponseWriter) Before(fn func(http.Header)) {
+	w.before = append(w.before, fn)
+}
+
+// OnWrite registers fn to receive every chunk of the response body.
+// fn must not retain the slice.
+func (w *ResponseWriter) OnWrite(fn func([]byte)) {
+	w.observers = append(w.observers, fn)
+}
+
+// BeforeWrite registers fn to run before every non-empty body write with
+// its size, and before every flush with n = 0. If fn returns an error, the write or
+// flush fails with it and nothing is sent.
+func (w *ResponseWriter) BeforeWrite(fn func(n int) error) {
+	w.writeHooks = append(w.writeHooks, fn)
+}
+
+// OnWriteError registers fn to run when a body write or flush to the client
+// fails. fn returns the error to report to the caller, normally err itself.
+func (w *ResponseWriter) OnWriteError(fn func(err error) error) {
+	w.errorHooks = append(w.errorHooks, fn)
+}
+
+// Limit caps the response body at n bytes. Writes past the cap fail with
+// ErrResponseTooLarge. The status is held back until the first body write,
+// so that a response whose first write is over the cap can still be
+// replaced. Passing n <= 0 removes the cap and sends a held back status.
+func (w *ResponseWriter) Limit(n int64) {
+	w.limit = max(n, 0)
+	if w.limit == 0 && w.heldHeader {
+		w.sendHeader()
+	}
+}
+
+// LimitExceeded reports whether a write was refused by Limit.
+func (w *ResponseWriter) LimitExceeded() bool {
+	return w.exceeded
+}
+
+// Status returns the response status code, or 200 if none was set.
+func (w *ResponseWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Size returns the number of body bytes written.
+func (w *ResponseWriter) Size() int64 {
+	return w.size
+}
+
+// Written reports whether the headers have been sent or the connection
+// hijacked.
+func (w *ResponseWriter) Written() bool {
+	return w.wroteHeader || w.hijacked
+}
+
+// WriteHeader runs the hooks and writes the status code, or holds it back
+// while a Limit is set. Informational statuses are passed through; later
+// duplicate calls are ignored.
+func (w *ResponseWriter) WriteHeader(statusCode int) {
+	if w.Written() || w.heldHeader {
+		return
+	}
+	if statusCode >= 100 && statusCode < 200 && statusCode != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	w.status = statusCode
+	if w.limit > 0 {
+		w.heldHeader = true
+		return
+	}
+	w.sendHeader()
+}
+
+// Write sends the headers if needed, notifies observers and writes the body.
+func (w *ResponseWriter) Write(b []byte) (int, error) {
+	if len(b) > 0 || w.exceeded {
+		if err := w.beforeWrite(len(b)); err != nil {
+			return 0, err
+		}
+	}
+	w.sendHeader()
+	for _, observe := range w.observers {
+		observe(b)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	if err != nil {
+		err = w.writeFailed(err)
+	}
+	return n, err
+}
+
+// ReadFrom copies r to the response, using the wrapped writer's ReadFrom,
+// e.g. sendfile, when no hooks or observers need to see the body.
+func (w *ResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	rf, ok := w.ResponseWriter.(io.ReaderFrom)
+	if !ok || len(w.observers) > 0 || len(w.writeHooks) > 0 || len(w.errorHooks) > 0 || w.limit > 0 {
+		return io.Copy(struct{ io.Writer }{w}, r)
+	}
+	w.sendHeader()
+	n, err := rf.ReadFrom(r)
+	w.size += n
+	return n, err
+}
+
+// Flush sends the headers if needed and flushes buffered data to the client.
+func (w *ResponseWriter) Flush() {
+	_ = w.FlushError()
+}
+
+// FlushError sends the headers if needed and flushes buffered data to the
+// client, returning http.ErrNotSupported if no wrapped writer can flush.
+func (w *ResponseWriter) FlushError() error {
+	if err := w.beforeWrite(0); err != nil {
+		return err
+	}
+	w.sendHeader()
+	err := flushResponse(w.ResponseWriter)
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		err = w.writeFailed(err)
+	}
+	return err
+}
+
+// Hijack takes over the connection. The hooks do not run.
+func (w *ResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(baseResponseWriter(w.ResponseWriter)).Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+	return conn, rw, err
+}
+
+// Unwrap returns the wrapped writer for http.ResponseController.
+func (w *ResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// sendHeader runs the hooks and writes the status, held back or 200, unless
+// the headers have already been sent.
+func (w *ResponseWriter) sendHeader() {
+	if w.Written() {
+		return
+	}
+	w.heldHeader = false
+	w.runBeforeHooks()
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(w.Status())
+}
+
+// discardHeader drops a held back status and lifts the limit, so that an
+// error response can replace a response that went over the limit.
+func (w *ResponseWriter) discardHeader() {
+	w.status = 0
+	w.heldHeader = false
+	w.limit = 0
+	w.exceeded = false
+}
+
+// beforeWrite enforces the limit and runs the write hooks for a write of n
+// bytes, or a flush if n is 0.
+func (w *ResponseWriter) beforeWrite(n int) error {
+	if w.limit > 0 && (w.exceeded || w.size+int64(n) > w.limit) {
+		w.exceeded = true
+		return ErrResponseTooLarge
+	}
+	for _, fn := range w.writeHooks {
+		if err := fn(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFailed runs the error hooks for a failed write or flush.
+func (w *ResponseWriter) writeFailed(err error) error {
+	for _, fn := range w.errorHooks {
+		err = fn(err)
+	}
+	return err
+}
+
+// runBeforeHooks runs the pending hooks. It is also called after the handler
+// returns, for responses with no body.
+func (w *ResponseWriter) runBeforeHooks() {
+	before := w.before
+	w.before = nil
+	for _, fn := range before {
+		fn(w.ResponseWriter.Header())
+	}
+}
+
+// unwrapResponseWriter returns the writer wrapped by w, or nil if w does not
+// wrap another writer. Writers without an Unwrap method, such as ginji's
+// internal status-capturing writer, are unwrapped through their embedded
+// http.ResponseWriter field.
+func unwrapResponseWriter(w http.ResponseWriter) http.ResponseWriter {
+	if u, ok := w.(interface{ Unwrap() http.ResponseWriter }); ok {
+		return u.Unwrap()
+	}
+
+	v := reflect.ValueOf(w)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	field := v.Elem().FieldByName("ResponseWriter")
+	if !field.IsValid() || !field.CanInterface() {
+		return nil
+	}
+	inner, _ := field.Interface().(http.ResponseWriter)
+	return inner
+}
+
+// flushResponse sends buffered data to the client, looking through wrapping
+// writers until one implements http.Flusher.
+func flushResponse(w http.ResponseWriter) error {
+	for w != nil {
+		switch f := w.(type) {
+		case interface{ FlushError() error }:
+			return f.FlushError()
+		case http.Flusher:
+			f.Flush()
+			return nil
+		}
+		w = unwrapResponseWriter(w)
+	}
+	return http.ErrNotSupported
+}
+
+// baseResponseWriter returns the innermost writer, normally the one created by
+// net/http, so http.ResponseController can reach its deadline controls.
+func baseResponseWriter(w http.ResponseWriter) http.ResponseWriter {
+	for {
+		inner := unwrapResponseWriter(w)
+		if inner == nil {
+			return w
+		}
+		w = inner
+	}
+}
@@ -0,0 +1,221 @@
+package middleware
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ginjigo/ginji"
+)
+
+func TestResponseWriterRecordsStatusAndSize(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rw := NewResponseWriter(rec)
+
+	if rw.Written() {
+		t.Fatal("expected new writer to be unwritten")
+	}
+	if rw.Status() != http.StatusOK {
+		t.Errorf("expected default status 200, got %d", rw.Status())
+	}
+
+	rw.WriteHeader(http.StatusCreated)
+	rw.WriteHeader(http.StatusInternalServerError)
+	_, _ = rw.Write([]byte("hello"))
+	_, _ = rw.Write([]byte(" world"))
+
+	if rw.Status() != http.StatusCreated {
+		t.Errorf("expected status 201, got %d", rw.Status())
+	}
+	if rw.Size() != 11 {
+		t.Errorf("expected size 11, got %d", rw.Size())
+	}
+	if rec.Code != http.StatusCreated {
+		t.Errorf("expected recorder status 201, got %d", rec.Code)
+	}
+}
+
+func TestResponseWriterBeforeHooks(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rw := NewResponseWriter(rec)
+
+	calls := 0
+	rw.Before(func(h http.Header) {
+		calls++
+		h.Set("X-Hook", "ran")
+	})
+
+	// Informational responses do not run the hooks
+	rw.WriteHeader(http.StatusEarlyHints)
+	if calls != 0 {
+		t.Fatalf("expected hooks to wait for the final status, ran %d times", calls)
+	}
+
+	_, _ = rw.Write([]byte("body"))
+	rw.runBeforeHooks()
+
+	if calls != 1 {
+		t.Errorf("expected hooks to run once, ran %d times", calls)
+	}
+	if rec.Header().Get("X-Hook") != "ran" {
+		t.Error("expected hook header to be sent")
+	}
+}
+
+func TestResponseWriterObservers(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rw := NewResponseWriter(rec)
+
+	var seen strings.Builder
+	rw.OnWrite(func(b []byte) { seen.Write(b) })
+
+	_, _ = rw.Write([]byte("abc"))
+	n, err := rw.ReadFrom(strings.NewReader("def"))
+	if err != nil || n != 3 {
+		t.Fatalf("ReadFrom returned %d, %v", n, err)
+	}
+
+	if seen.String() != "abcdef" {
+		t.Errorf("expected observer to see %q, got %q", "abcdef", seen.String())
+	}
+	if rw.Size() != 6 {
+		t.Errorf("expected size 6, got %d", rw.Size())
+	}
+}
+
+func TestResponseWriterFlush(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rw := NewResponseWriter(rec)
+
+	if err := http.NewResponseController(rw).Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if !rec.Flushed {
+		t.Error("expected recorder to be flushed")
+	}
+	if !rw.Written() {
+		t.Error("expected Flush to send the headers")
+	}
+}
+
+func TestResponseWriterWriteHooks(t *testing.T) {
+	rw := NewResponseWriter(&errorWriter{ResponseWriter: httptest.NewRecorder()})
+
+	var sizes []int
+	rw.BeforeWrite(func(n int) error {
+		sizes = append(sizes, n)
+		if n > 5 {
+			return errors.New("too big")
+		}
+		return nil
+	})
+	var failures int
+	rw.OnWriteError(func(err error) error {
+		failures++
+		return fmt.Errorf("wrapped: %w", err)
+	})
+
+	if _, err := rw.Write([]byte("toolong")); err == nil || err.Error() != "too big" {
+		t.Errorf("expected hook error, got %v", err)
+	}
+	if rw.Written() {
+		t.Error("expected refused write to send nothing")
+	}
+	if _, err := rw.Write([]byte("ok")); err == nil || err.Error() != "wrapped: broken pipe" {
+		t.Errorf("expected wrapped write error, got %v", err)
+	}
+	rw.Flush()
+
+	if len(sizes) != 3 || sizes[0] != 7 || sizes[1] != 2 || sizes[2] != 0 {
+		t.Errorf("expected hook sizes [7 2 0], got %v", sizes)
+	}
+	if failures != 1 {
+		t.Errorf("expected 1 write failure, got %d", failures)
+	}
+}
+
+func TestResponseWriterLimit(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rw := NewResponseWriter(rec)
+	rw.Limit(5)
+
+	rw.WriteHeader(http.StatusCreated)
+	if rw.Written() {
+		t.Fatal("expected status to be held back")
+	}
+	if _, err := rw.Write([]byte("too long")); !errors.Is(err, ErrResponseTooLarge) {
+		t.Errorf("expected ErrResponseTooLarge, got %v", err)
+	}
+	if !rw.LimitExceeded() || rw.Written() {
+		t.Error("expected refused write to be recorded and send nothing")
+	}
+
+	rec = httptest.NewRecorder()
+	rw = NewResponseWriter(rec)
+	rw.Limit(5)
+	rw.WriteHeader(http.StatusCreated)
+	rw.Limit(0)
+	if rec.Code != http.StatusCreated || !rw.Written() {
+		t.Errorf("expected lifting the limit to send status 201, got %d", rec.Code)
+	}
+}
+
+type hijackableRecorder struct {
+	*httptest.ResponseRecorder
+	conn net.Conn
+}
+
+func (h *hijackableRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return h.conn, bufio.NewReadWriter(bufio.NewReader(h.conn), bufio.NewWriter(h.conn)), nil
+}
+
+func TestResponseWriterHijack(t *testing.T) {
+	server, client := net.Pipe()
+	defer func() { _ = server.Close() }()
+	defer func() { _ = client.Close() }()
+
+	rw := NewResponseWriter(&hijackableRecorder{ResponseRecorder: httptest.NewRecorder(), conn: server})
+
+	conn, _, err := http.NewResponseController(rw).Hijack()
+	if err != nil {
+		t.Fatalf("Hijack failed: %v", err)
+	}
+	if conn != server {
+		t.Error("expected the underlying connection")
+	}
+	if !rw.Written() {
+		t.Error("expected hijacked writer to report written")
+	}
+}
+
+func TestWrapResponseWriterShared(t *testing.T) {
+	app := ginji.New()
+
+	var first, second *ResponseWriter
+	app.Use(func(c *ginji.Context) error {
+		first = WrapResponseWriter(c)
+		return c.Next()
+	})
+	app.Use(func(c *ginji.Context) error {
+		second = WrapResponseWriter(c)
+		return c.Next()
+	})
+	app.Get("/", func(c *ginji.Context) error {
+		return c.Text(http.StatusOK, "ok")
+	})
+
+	rec := ginji.PerformRequest(app, "GET", "/", nil)
+	ginji.AssertStatus(t, rec, http.StatusOK)
+
+	if first == nil || first != second {
+		t.Fatal("expected middleware to share one ResponseWriter")
+	}
+	if first.Size() != 2 {
+		t.Errorf("expected size 2, got %d", first.Size())
+	}
+}
@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// ErrResponseTooLarge is returned by writes to a response past its
+// ResponseLimit.
+var ErrResponseTooLarge = errors.New("responselimit: response body too large")
+
+// ResponseLimitConfig defines the configuration for response size limits.
+type ResponseLimitConfig struct {
+	// MaxBytes is the largest response body a handler may write.
+	// Default: 10MB
+	MaxBytes int64
+
+	// PathLimits overrides MaxBytes for paths matching a glob pattern (see
+	// PathGlob). The longest matching pattern wins, and a negative limit
+	// disables the check for that path.
+	PathLimits map[string]int64
+
+	// Logger logs responses over the limit. If nil, uses engine's logger.
+	Logger *slog.Logger
+
+	// OnExceeded is called for every response over the limit, e.g. to
+	// count them.
+	OnExceeded func(c *ginji.Context, limit int64)
+
+	// SkipFunc allows skipping the limit for certain requests.
+	SkipFunc Skipper
+}
+
+// DefaultResponseLimitConfig returns default response limit configuration.
+func DefaultResponseLimitConfig() ResponseLimitConfig {
+	return ResponseLimitConfig{
+		MaxBytes: 10 << 20,
+	}
+}
+
+// ResponseLimit returns middleware that limits response bodies to maxBytes.
+func ResponseLimit(maxBytes int64) ginji.Middleware {
+	config := DefaultResponseLimitConfig()
+	config.MaxBytes = maxBytes
+	return ResponseLimitWithConfig(config)
+}
+
+// ResponseLimitWithConfig returns middleware that caps the bytes a handler
+// may write, guarding against a runaway serialization filling memory and
+// the network. Writes past the limit fail with ErrResponseTooLarge. If
+// nothing has reached the client yet, the response is replaced with 500;
+// otherwise, for streamed responses, the connection is aborted so the
+// client cannot mistake the truncated body for a complete one. Event
+// streams and WebSocket upgrades are not limited.
+func ResponseLimitWithConfig(config ResponseLimitConfig) ginji.Middleware {
+	defaults := DefaultResponseLimitConfig()
+	if config.MaxBytes == 0 {
+		config.MaxBytes = defaults.MaxBytes
+	}
+	pathLimits := compilePathOverrides(config.PathLimits)
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		// Long-lived streams have no meaningful total size. Those set up
+		// later in the chain lift the limit themselves.
+		if _, ws := GetWebSocket(c); ws || isEventStream(c) {
+			return c.Next()
+		}
+
+		limit := lookupPathOverride(c, pathLimits, config.MaxBytes)
+		if limit < 0 {
+			return c.Next()
+		}
+
+		rw := WrapResponseWriter(c)
+		rw.Limit(limit)
+		err := c.Next()
+
+		if !rw.LimitExceeded() {
+			rw.Limit(0)
+			return err
+		}
+
+		requestLogger(c, config.Logger).LogAttrs(c.Req.Context(), slog.LevelError, "Response too large",
+			slog.String("method", c.Req.Method),
+			slog.String("path", c.Req.URL.Path),
+			slog.String("route", RoutePattern(c)),
+			slog.Int64("limit", limit),
+			slog.Int64("written", rw.Size()),
+			slog.Bool("streamed", rw.Written()),
+		)
+		if config.OnExceeded != nil {
+			config.OnExceeded(c, limit)
+		}
+
+		if rw.Written() {
+			// Fail the remaining writes so the server closes the connection
+			_ = http.NewResponseController(baseResponseWriter(rw)).SetWriteDeadline(time.Now())
+			c.Abort()
+			return nil
+		}
+
+		rw.discardHeader()
+		header := rw.Header()
+		header.Del("Content-Length")
+		header.Del("Content-Encoding")
+		header.Del("ETag")
+		c.AbortWithStatusJSON(ginji.StatusInternalServerError, ginji.H{
+			"error": "Internal Server Error",
+		})
+		return nil
+	}
+}
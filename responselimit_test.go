@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ginjigo/ginji"
+)
+
+func TestResponseLimit(t *testing.T) {
+	var buf bytes.Buffer
+	var exceeded int64
+	app := ginji.New()
+	app.Use(ResponseLimitWithConfig(ResponseLimitConfig{
+		MaxBytes:   100,
+		PathLimits: map[string]int64{"/export/*": 1000, "/unlimited": -1},
+		Logger:     slog.New(slog.NewJSONHandler(&buf, nil)),
+		OnExceeded: func(c *ginji.Context, limit int64) { exceeded = limit },
+	}))
+	var writeErr error
+	app.Get("/small", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusCreated, "small")
+	})
+	app.Get("/large", func(c *ginji.Context) error {
+		writeErr = c.JSON(ginji.StatusOK, ginji.H{"data": strings.Repeat("x", 200)})
+		return writeErr
+	})
+	app.Get("/export/:id", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, strings.Repeat("x", 500))
+	})
+	app.Get("/unlimited", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, strings.Repeat("x", 5000))
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/small", nil)
+	ginji.AssertStatus(t, w, ginji.StatusCreated)
+	ginji.AssertBody(t, w, "small")
+
+	w = ginji.PerformRequest(app, "GET", "/large", nil)
+	ginji.AssertStatus(t, w, ginji.StatusInternalServerError)
+	ginji.AssertBody(t, w, "Internal Server Error")
+	if strings.Contains(w.Body.String(), "xxx") {
+		t.Error("Expected none of the oversized body to be sent")
+	}
+	if !errors.Is(writeErr, ErrResponseTooLarge) {
+		t.Errorf("Expected ErrResponseTooLarge from the write, got %v", writeErr)
+	}
+	if exceeded != 100 {
+		t.Errorf("Expected OnExceeded with limit 100, got %d", exceeded)
+	}
+	if !strings.Contains(buf.String(), `"msg":"Response too large"`) || !strings.Contains(buf.String(), `"route":"/large"`) {
+		t.Errorf("Expected a log entry, got %s", buf.String())
+	}
+
+	w = ginji.PerformRequest(app, "GET", "/export/1", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	if w.Body.Len() != 500 {
+		t.Errorf("Expected the path limit to apply, got %d bytes", w.Body.Len())
+	}
+
+	w = ginji.PerformRequest(app, "GET", "/unlimited", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	if w.Body.Len() != 5000 {
+		t.Errorf("Expected no limit, got %d bytes", w.Body.Len())
+	}
+}
+
+func TestResponseLimitCountsAcrossWrites(t *testing.T) {
+	app := ginji.New()
+	app.Use(ResponseLimit(100))
+	var errs []error
+	app.Get("/", func(c *ginji.Context) error {
+		c.Status(ginji.StatusOK)
+		for range 3 {
+			_, err := c.Res.Write([]byte(strings.Repeat("x", 40)))
+			errs = append(errs, err)
+		}
+		return nil
+	})
+
+	ginji.PerformRequest(app, "GET", "/", nil)
+
+	if errs[0] != nil || errs[1] != nil || !errors.Is(errs[2], ErrResponseTooLarge) {
+		t.Errorf("Expected the third write to fail, got %v", errs)
+	}
+}
+
+func TestResponseLimitAbortsStream(t *testing.T) {
+	app := ginji.New()
+	app.Use(ResponseLimitWithConfig(ResponseLimitConfig{
+		MaxBytes: 1000,
+		Logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}))
+	app.Get("/stream", func(c *ginji.Context) error {
+		c.Status(ginji.StatusOK)
+		for range 10 {
+			if _, err := c.Res.Write([]byte(strings.Repeat("x", 300))); err != nil {
+				return err
+			}
+			_ = http.NewResponseController(c.Res).Flush()
+		}
+		return nil
+	})
+
+	server := httptest.NewServer(app)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected the stream to start with 200, got %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err == nil {
+		t.Errorf("Expected the truncated stream to fail, read %d bytes", len(body))
+	}
+	if len(body) > 1000 {
+		t.Errorf("Expected at most 1000 bytes, got %d", len(body))
+	}
+}
@@ -0,0 +1,353 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// rotatingFileTimeFormat is the timestamp in the names of rotated files.
+const rotatingFileTimeFormat = "2006-01-02T15-04-05.000000000"
+
+// RotatingFileConfig defines the configuration for a rotating log file.
+type RotatingFileConfig struct {
+	// Filename is the path of the file written to. Rotated files are kept
+	// next to it as name-<timestamp>.ext. Required.
+	Filename string
+
+	// MaxSize is the size in bytes at which the file is rotated.
+	// Default: 100MB
+	MaxSize int64
+
+	// RotateEvery rotates the file at every multiple of this interval, e.g.
+	// 24 * time.Hour for daily files starting at midnight UTC.
+	// Default: 0 (rotate by size only)
+	RotateEvery time.Duration
+
+	// Compress gzips rotated files.
+	Compress bool
+
+	// MaxAge removes rotated files older than this.
+	// Default: 0 (keep regardless of age)
+	MaxAge time.Duration
+
+	// MaxBackups is the number of rotated files kept.
+	// Default: 0 (keep all)
+	MaxBackups int
+
+	// FileMode is the permission of new files.
+	// Default: 0640
+	FileMode os.FileMode
+}
+
+// DefaultRotatingFileConfig returns default rotating file configuration.
+func DefaultRotatingFileConfig() RotatingFileConfig {
+	return RotatingFileConfig{
+		MaxSize:  100 << 20,
+		FileMode: 0o640,
+	}
+}
+
+// RotatingFile is an io.WriteCloser appending to a file that is rotated by
+// size and time, so that small deployments can keep access and audit logs
+// without an external log shipper:
+//
+//	file, err := middleware.NewRotatingFile(middleware.RotatingFileConfig{
+//		Filename:    "/var/log/app/access.log",
+//		RotateEvery: 24 * time.Hour,
+//		Compress:    true,
+//		MaxBackups:  14,
+//	})
+//	app.Use(middleware.LoggerWithConfig(middleware.LoggerConfig{Output: file}))
+//	app.Use(middleware.AuditLog(middleware.NewWriterAuditSink(auditFile)))
+//
+// Rotated files are compressed and pruned in the background. RotatingFile
+// implements ginji.Plugin so that registering it closes the file on
+// shutdown.
+type RotatingFile struct {
+	config RotatingFileConfig
+
+	mu         sync.Mutex
+	file       *os.File
+	size       int64
+	nextRotate time.Time
+	closed     bool
+
+	millMu sync.Mutex
+	millWg sync.WaitGroup
+}
+
+// NewRotatingFile opens, or creates, the file of config for appending.
+func NewRotatingFile(config RotatingFileConfig) (*RotatingFile, error) {
+	defaults := DefaultRotatingFileConfig()
+	if config.Filename == "" {
+		return nil, errors.New("rotatingfile: Filename is required")
+	}
+	if config.MaxSize <= 0 {
+		config.MaxSize = defaults.MaxSize
+	}
+	if config.FileMode == 0 {
+		config.FileMode = defaults.FileMode
+	}
+
+	f := &RotatingFile{config: config}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Write appends p to the file, rotating it first if p would exceed
+// MaxSize or the rotation interval has passed.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+	if f.size > 0 && (f.size+int64(len(p)) > f.config.MaxSize ||
+		!f.nextRotate.IsZero() && !time.Now().Before(f.nextRotate)) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Rotate closes the current file, renames it with a timestamp and opens a
+// new one, e.g. on SIGHUP.
+func (f *RotatingFile) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return os.ErrClosed
+	}
+	return f.rotate()
+}
+
+// Sync commits the file to stable storage.
+func (f *RotatingFile) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return os.ErrClosed
+	}
+	return f.file.Sync()
+}
+
+// Close closes the file and waits for background compression and pruning
+// to finish.
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return nil
+	}
+	f.closed = true
+	err := f.file.Close()
+	f.mu.Unlock()
+
+	f.millWg.Wait()
+	return err
+}
+
+// Name implements ginji.Plugin.
+func (f *RotatingFile) Name() string {
+	return "rotating-file:" + f.config.Filename
+}
+
+// Version implements ginji.Plugin.
+func (f *RotatingFile) Version() string {
+	return "1.0.0"
+}
+
+// Install implements ginji.Plugin.
+func (f *RotatingFile) Install(*ginji.Engine) error {
+	return nil
+}
+
+// Start implements ginji.Plugin.
+func (f *RotatingFile) Start() error {
+	return nil
+}
+
+// Stop implements ginji.Plugin by closing the file.
+func (f *RotatingFile) Stop() error {
+	return f.Close()
+}
+
+// open opens the file for appending and schedules the next time-based
+// rotation.
+func (f *RotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.config.Filename), 0o755); err != nil {
+		return fmt.Errorf("rotatingfile: %w", err)
+	}
+	file, err := os.OpenFile(f.config.Filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, f.config.FileMode)
+	if err != nil {
+		return fmt.Errorf("rotatingfile: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("rotatingfile: %w", err)
+	}
+
+	f.file, f.size = file, info.Size()
+	if every := f.config.RotateEvery; every > 0 {
+		f.nextRotate = time.Now().Truncate(every).Add(every)
+	}
+	return nil
+}
+
+// rotate moves the current file aside and opens a new one. f.mu must be
+// held.
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("rotatingfile: %w", err)
+	}
+	if err := os.Rename(f.config.Filename, f.backupName(time.Now())); err != nil {
+		return fmt.Errorf("rotatingfile: %w", err)
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	f.millWg.Add(1)
+	go func() {
+		defer f.millWg.Done()
+		f.mill()
+	}()
+	return nil
+}
+
+// backupName returns an unused name for a file rotated at t.
+func (f *RotatingFile) backupName(t time.Time) string {
+	dir, prefix, ext := f.nameParts()
+	for {
+		name := filepath.Join(dir, prefix+t.UTC().Format(rotatingFileTimeFormat)+ext)
+		if _, err := os.Stat(name); errors.Is(err, os.ErrNotExist) {
+			if _, err := os.Stat(name + ".gz"); errors.Is(err, os.ErrNotExist) {
+				return name
+			}
+		}
+		t = t.Add(time.Nanosecond)
+	}
+}
+
+// nameParts splits Filename into the directory, the prefix of rotated
+// files and the extension.
+func (f *RotatingFile) nameParts() (dir, prefix, ext string) {
+	dir = filepath.Dir(f.config.Filename)
+	base := filepath.Base(f.config.Filename)
+	ext = filepath.Ext(base)
+	return dir, strings.TrimSuffix(base, ext) + "-", ext
+}
+
+// rotatedFile is a rotated file found on disk.
+type rotatedFile struct {
+	path       string
+	rotatedAt  time.Time
+	compressed bool
+}
+
+// backups returns the rotated files, newest first.
+func (f *RotatingFile) backups() ([]rotatedFile, error) {
+	dir, prefix, ext := f.nameParts()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []rotatedFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		stamp := strings.TrimPrefix(name, prefix)
+		compressed := strings.HasSuffix(stamp, ext+".gz")
+		stamp = strings.TrimSuffix(strings.TrimSuffix(stamp, ".gz"), ext)
+		t, err := time.Parse(rotatingFileTimeFormat, stamp)
+		if err != nil {
+			continue
+		}
+		files = append(files, rotatedFile{path: filepath.Join(dir, name), rotatedAt: t, compressed: compressed})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].rotatedAt.After(files[j].rotatedAt) })
+	return files, nil
+}
+
+// mill prunes and compresses rotated files. Errors are ignored, as there
+// is nowhere to report them; the next rotation retries.
+func (f *RotatingFile) mill() {
+	f.millMu.Lock()
+	defer f.millMu.Unlock()
+
+	files, err := f.backups()
+	if err != nil {
+		return
+	}
+
+	cutoff := time.Now().Add(-f.config.MaxAge)
+	for i, file := range files {
+		expired := f.config.MaxAge > 0 && file.rotatedAt.Before(cutoff)
+		if expired || f.config.MaxBackups > 0 && i >= f.config.MaxBackups {
+			_ = os.Remove(file.path)
+			continue
+		}
+		if f.config.Compress && !file.compressed {
+			_ = compressFile(file.path, f.config.FileMode)
+		}
+	}
+}
+
+// compressFile gzips path into path.gz and removes path.
+func compressFile(path string, mode os.FileMode) (err error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := path + ".gz.tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			dst.Close()
+			os.Remove(tmp)
+		}
+	}()
+
+	gz := gzip.NewWriter(dst)
+	if _, err = io.Copy(gz, src); err != nil {
+		return err
+	}
+	if err = gz.Close(); err != nil {
+		return err
+	}
+	if err = dst.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, path+".gz"); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
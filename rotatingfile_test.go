@@ -0,0 +1,187 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+func newTestRotatingFile(t *testing.T, config RotatingFileConfig) *RotatingFile {
+	t.Helper()
+	if config.Filename == "" {
+		config.Filename = filepath.Join(t.TempDir(), "logs", "access.log")
+	}
+	f, err := NewRotatingFile(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRotatingFileSize(t *testing.T) {
+	f := newTestRotatingFile(t, RotatingFileConfig{MaxSize: 10})
+	for _, line := range []string{"first\n", "second\n", "third\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readFile(t, f.config.Filename); got != "third\n" {
+		t.Errorf("Expected the last line in the current file, got %q", got)
+	}
+	backups, err := f.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("Expected 2 rotated files, got %d", len(backups))
+	}
+	if got := readFile(t, backups[1].path); got != "first\n" {
+		t.Errorf("Expected the oldest file to hold the first line, got %q", got)
+	}
+	if !strings.HasPrefix(filepath.Base(backups[0].path), "access-") || filepath.Ext(backups[0].path) != ".log" {
+		t.Errorf("Unexpected rotated file name %s", backups[0].path)
+	}
+}
+
+func TestRotatingFileCompressAndRetention(t *testing.T) {
+	f := newTestRotatingFile(t, RotatingFileConfig{MaxSize: 1, Compress: true, MaxBackups: 2})
+	for _, line := range []string{"a\n", "b\n", "c\n", "d\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := f.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("Expected 2 rotated files to be kept, got %d", len(backups))
+	}
+	for i, want := range []string{"c\n", "b\n"} {
+		if !backups[i].compressed {
+			t.Fatalf("Expected %s to be compressed", backups[i].path)
+		}
+		file, err := os.Open(backups[i].path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(gz)
+		file.Close()
+		if string(data) != want {
+			t.Errorf("Expected %q in %s, got %q", want, backups[i].path, data)
+		}
+	}
+}
+
+func TestRotatingFileMaxAge(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, "app-"+time.Now().Add(-48*time.Hour).UTC().Format(rotatingFileTimeFormat)+".log")
+	if err := os.WriteFile(old, []byte("old\n"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	unrelated := filepath.Join(dir, "app-notes.log")
+	if err := os.WriteFile(unrelated, []byte("keep\n"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+
+	f := newTestRotatingFile(t, RotatingFileConfig{Filename: filepath.Join(dir, "app.log"), MaxAge: 24 * time.Hour})
+	f.Write([]byte("new\n"))
+	if err := f.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	if _, err := os.Stat(old); !errors.Is(err, os.ErrNotExist) {
+		t.Error("Expected the expired file to be removed")
+	}
+	if _, err := os.Stat(unrelated); err != nil {
+		t.Error("Expected files not matching the rotation pattern to be kept")
+	}
+	if backups, _ := f.backups(); len(backups) != 1 {
+		t.Errorf("Expected the fresh rotated file to be kept, got %d", len(backups))
+	}
+}
+
+func TestRotatingFileInterval(t *testing.T) {
+	f := newTestRotatingFile(t, RotatingFileConfig{RotateEvery: time.Hour})
+	if f.nextRotate.Sub(time.Now()) > time.Hour {
+		t.Errorf("Expected the next rotation within an hour, got %v", f.nextRotate)
+	}
+	f.Write([]byte("before\n"))
+
+	f.mu.Lock()
+	f.nextRotate = time.Now().Add(-time.Second)
+	f.mu.Unlock()
+	f.Write([]byte("after\n"))
+	f.Close()
+
+	if got := readFile(t, f.config.Filename); got != "after\n" {
+		t.Errorf("Expected a new file after the interval, got %q", got)
+	}
+	if _, err := f.Write([]byte("closed\n")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+}
+
+func TestRotatingFileAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, []byte("existing\n"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	f := newTestRotatingFile(t, RotatingFileConfig{Filename: path, MaxSize: 12})
+	f.Write([]byte("new\n"))
+	f.Close()
+
+	backups, _ := f.backups()
+	if len(backups) != 1 || readFile(t, backups[0].path) != "existing\n" {
+		t.Error("Expected the existing content to count toward MaxSize")
+	}
+
+	if _, err := NewRotatingFile(RotatingFileConfig{}); err == nil {
+		t.Error("Expected an error without Filename")
+	}
+}
+
+func TestLoggerOutput(t *testing.T) {
+	file := newTestRotatingFile(t, RotatingFileConfig{})
+	app := ginji.New()
+	app.Use(LoggerWithConfig(LoggerConfig{Output: file}))
+	app.Get("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+	ginji.PerformRequest(app, "GET", "/", nil)
+	file.Close()
+
+	if got := readFile(t, file.config.Filename); !strings.Contains(got, `"msg":"Request processed"`) {
+		t.Errorf("Expected a JSON entry in the file, got %q", got)
+	}
+}
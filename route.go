@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/ginjigo/ginji"
+)
+
+// RouteNotFound is the route pattern reported for requests that matched
+// no route, so scanners probing random paths cannot inflate metric
+// cardinality.
+const RouteNotFound = "<unmatched>"
+
+// SetRoutePattern records the route pattern of the request explicitly,
+// e.g. from a route middleware when the pattern cannot be derived.
+func SetRoutePattern(c *ginji.Context, pattern string) {
+	setContextValue(c, routePatternContextKey, pattern)
+}
+
+// RoutePattern returns the route template that matched the request, such
+// as "/users/:id" for "/users/12345". Logger and Metrics label requests
+// with it instead of the raw path.
+//
+// The router does not expose the matched pattern, so unless it was set
+// with SetRoutePattern it is rebuilt by replacing the path segments that
+// hold route parameters with their names. When that is ambiguous, e.g.
+// "/files/files" for "/files/:name", the raw path is reported instead;
+// set the pattern on such routes. It is only complete once the router has
+// run, i.e. after c.Next returns. Requests that matched no route report
+// RouteNotFound.
+func RoutePattern(c *ginji.Context) string {
+	if pattern, ok := c.Req.Context().Value(routePatternContextKey).(string); ok && pattern != "" {
+		return pattern
+	}
+
+	if len(c.Params) == 0 {
+		if c.StatusCode() == http.StatusNotFound {
+			return RouteNotFound
+		}
+		return c.Req.URL.Path
+	}
+	if pattern, ok := routePatternFromParams(c.Req.URL.Path, c.Params); ok {
+		return pattern
+	}
+	return c.Req.URL.Path
+}
+
+// routePatternFromParams replaces parameter values in path with ":name",
+// or "*name" for a catch-all parameter spanning several segments. Only
+// the values tell where the parameters are, so it reports false when a
+// parameter could sit at more than one position, e.g. because its value
+// equals a static segment or another parameter's value.
+func routePatternFromParams(path string, params map[string]string) (string, bool) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	names := make([]string, len(segments))
+	catchAll := make([]bool, len(segments))
+	taken := make([]bool, len(segments))
+	values := make(map[string]bool, len(params))
+
+	for name, value := range params {
+		if value == "" || values[value] {
+			return "", false
+		}
+		values[value] = true
+
+		at, n := -1, 1
+		if strings.Contains(value, "/") {
+			n = len(strings.Split(value, "/"))
+			if n <= len(segments) && strings.Join(segments[len(segments)-n:], "/") == value {
+				at = len(segments) - n
+			}
+		} else {
+			for i, segment := range segments {
+				if segment != value {
+					continue
+				}
+				if at >= 0 {
+					return "", false
+				}
+				at = i
+			}
+		}
+		if at < 0 {
+			return "", false
+		}
+		for i := at; i < at+n; i++ {
+			if taken[i] {
+				return "", false
+			}
+			taken[i] = true
+		}
+		names[at] = name
+		catchAll[at] = n > 1
+	}
+
+	var sb strings.Builder
+	for i, segment := range segments {
+		sb.WriteByte('/')
+		switch {
+		case catchAll[i]:
+			sb.WriteString("*" + names[i])
+			return sb.String(), true
+		case names[i] != "":
+			sb.WriteString(":" + names[i])
+		default:
+			sb.WriteString(segment)
+		}
+	}
+	return sb.String(), true
+}
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"testing"
+
+	"github.com/ginjigo/ginji"
+)
+
+func TestRoutePattern(t *testing.T) {
+	app := ginji.New()
+
+	var got string
+	app.Use(func(c *ginji.Context) error {
+		err := c.Next()
+		got = RoutePattern(c)
+		return err
+	})
+	app.Get("/users/:id/posts/:post", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "post")
+	})
+	app.Get("/files/*filepath", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "file")
+	})
+	app.Get("/teams/:team/members/:member", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "member")
+	})
+	app.Get("/about", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "about")
+	})
+	app.Get("/orders/:id", func(c *ginji.Context) error {
+		SetRoutePattern(c, "/orders/{id}")
+		return c.Text(ginji.StatusOK, "order")
+	})
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/users/42/posts/7", "/users/:id/posts/:post"},
+		{"/files/css/site.css", "/files/*filepath"},
+		{"/teams/a/members/a", "/teams/a/members/a"},
+		{"/about", "/about"},
+		{"/orders/9", "/orders/{id}"},
+		{"/missing", RouteNotFound},
+	}
+	for _, tt := range tests {
+		ginji.PerformRequest(app, "GET", tt.path, nil)
+		if got != tt.want {
+			t.Errorf("RoutePattern(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRoutePatternFromParams(t *testing.T) {
+	tests := []struct {
+		path   string
+		params map[string]string
+		want   string
+		ok     bool
+	}{
+		{"/teams/a/members/b", map[string]string{"member": "b", "team": "a"}, "/teams/:team/members/:member", true},
+		{"/static/a/b/c", map[string]string{"rest": "a/b/c"}, "/static/*rest", true},
+		{"/users/7/files/x/y", map[string]string{"id": "7", "path": "x/y"}, "/users/:id/files/*path", true},
+		// A value equal to a static segment or to another value does not
+		// tell where the parameter is
+		{"/files/files", map[string]string{"name": "files"}, "", false},
+		{"/teams/a/members/a", map[string]string{"team": "a", "member": "a"}, "", false},
+		{"/files/a/a", map[string]string{"dir": "a", "rest": "a/a"}, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := routePatternFromParams(tt.path, tt.params)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("routePatternFromParams(%q) = %q, %v, want %q, %v", tt.path, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package middleware
+
+import (
+	"context"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// SamplingConfig defines the configuration for sampling middleware.
+type SamplingConfig struct {
+	// Rate is the fraction (0-1] of requests kept when they arrive.
+	// Default: 1 (keep every request)
+	Rate float64
+
+	// PathRates overrides Rate for paths matching a glob pattern (see
+	// PathGlob). The longest matching pattern wins, and a rate of 0 drops
+	// the requests for that path unless they are kept afterwards.
+	PathRates map[string]float64
+
+	// MaxPerSecond caps the number of requests kept when they arrive, so a
+	// traffic spike cannot flood the telemetry pipelines.
+	// Default: 0 (no limit)
+	MaxPerSecond int
+
+	// Burst is the number of requests that may be kept at once before
+	// MaxPerSecond applies.
+	// Default: MaxPerSecond
+	Burst int
+
+	// KeepStatus keeps requests answered with this status or higher, and
+	// those whose handler returned an error, whatever the decision made
+	// when they arrived. Set it to -1 to keep no request afterwards.
+	// Default: 400
+	KeepStatus int
+
+	// SlowThreshold keeps requests slower than this.
+	// Default: 0 (disabled)
+	SlowThreshold time.Duration
+
+	// TrustTraceparent follows the sampled flag of an incoming W3C
+	// traceparent header instead of deciding, so that a trace is kept or
+	// dropped in every service it crosses. Enable it only behind callers
+	// that are trusted to set it.
+	TrustTraceparent bool
+
+	// SkipFunc allows skipping sampling for certain requests. Skipped
+	// requests carry no decision, and each middleware decides on its own.
+	SkipFunc Skipper
+}
+
+// DefaultSamplingConfig returns default sampling configuration.
+func DefaultSamplingConfig() SamplingConfig {
+	return SamplingConfig{
+		Rate:       1,
+		KeepStatus: 400,
+	}
+}
+
+// samplingState is the sampling decision of a request.
+type samplingState struct {
+	config *SamplingConfig
+	start  time.Time
+	head   bool
+	forced bool
+	rate   float64
+}
+
+// keep reports whether the request is kept, given the error returned by
+// its handler.
+func (s *samplingState) keep(c *ginji.Context, err error) bool {
+	if s.head || s.forced {
+		return true
+	}
+	if s.config.KeepStatus > 0 && (err != nil || c.StatusCode() >= s.config.KeepStatus) {
+		return true
+	}
+	return s.config.SlowThreshold > 0 && time.Since(s.start) > s.config.SlowThreshold
+}
+
+// Sampling returns sampling middleware keeping the given fraction of
+// requests.
+func Sampling(rate float64) ginji.Middleware {
+	config := DefaultSamplingConfig()
+	config.Rate = rate
+	return SamplingWithConfig(config)
+}
+
+// SamplingWithConfig returns middleware that makes one sampling decision
+// per request and stores it in the request context, so that Logger and
+// Recorder keep or drop the same requests instead of each deciding on its
+// own:
+//
+//	app.Use(middleware.SamplingWithConfig(middleware.SamplingConfig{
+//		Rate:          0.1,
+//		SlowThreshold: time.Second,
+//	}))
+//	app.Use(middleware.Logger())
+//	app.Use(recorder.Middleware())
+//
+// A request is kept if it is sampled when it arrives, or afterwards if it
+// fails or is slow. Tracers read the decision made on arrival with
+// SampledFromContext to decide whether to record spans. Register it before
+// the middleware honoring it.
+func SamplingWithConfig(config SamplingConfig) ginji.Middleware {
+	defaults := DefaultSamplingConfig()
+	if config.Rate <= 0 || config.Rate > 1 {
+		config.Rate = defaults.Rate
+	}
+	if config.KeepStatus == 0 {
+		config.KeepStatus = defaults.KeepStatus
+	}
+
+	pathRates := compilePathOverrides(config.PathRates)
+
+	var limiter *logBurstLimiter
+	if config.MaxPerSecond > 0 {
+		burst := config.Burst
+		if burst <= 0 {
+			burst = config.MaxPerSecond
+		}
+		limiter = &logBurstLimiter{
+			rate:   float64(config.MaxPerSecond),
+			burst:  float64(burst),
+			tokens: float64(burst),
+			last:   time.Now(),
+		}
+	}
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		state := &samplingState{config: &config, start: time.Now()}
+		sampled, fromParent := false, false
+		if config.TrustTraceparent {
+			sampled, fromParent = traceparentSampled(c.Req.Header.Get("Traceparent"))
+		}
+		if fromParent {
+			state.head, state.rate = sampled, 1
+		} else {
+			state.rate = lookupPathOverride(c, pathRates, config.Rate)
+			state.head = state.rate >= 1 || rand.Float64() < state.rate
+			if state.head && limiter != nil {
+				state.head, _ = limiter.allow(state.start)
+			}
+		}
+		setContextValue(c, samplingContextKey, state)
+
+		return c.Next()
+	}
+}
+
+// Sampled reports whether the request is kept, given the error returned
+// by its handler. ok is false if no Sampling middleware decided for the
+// request.
+func Sampled(c *ginji.Context, err error) (keep, ok bool) {
+	state := samplingFrom(c)
+	if state == nil {
+		return false, false
+	}
+	return state.keep(c, err), true
+}
+
+// SampledFromContext returns the sampling decision made when the request
+// arrived. ok is false if no Sampling middleware decided for the request.
+func SampledFromContext(ctx context.Context) (sampled, ok bool) {
+	state, ok := ctx.Value(samplingContextKey).(*samplingState)
+	if !ok {
+		return false, false
+	}
+	return state.head || state.forced, true
+}
+
+// KeepSample marks the request as kept, e.g. when a handler detects a case
+// worth inspecting. It does nothing without Sampling middleware.
+func KeepSample(c *ginji.Context) {
+	if state := samplingFrom(c); state != nil {
+		state.forced = true
+	}
+}
+
+// samplingFrom returns the request's sampling decision, or nil.
+func samplingFrom(c *ginji.Context) *samplingState {
+	state, _ := c.Req.Context().Value(samplingContextKey).(*samplingState)
+	return state
+}
+
+// traceparentSampled returns the sampled flag of a W3C traceparent header
+// value, and whether the value is valid.
+func traceparentSampled(v string) (sampled, ok bool) {
+	// version "-" trace-id "-" parent-id "-" trace-flags
+	parts := strings.Split(v, "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" ||
+		len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return false, false
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return false, false
+	}
+	for _, part := range parts[:4] {
+		if !isLowerHex(part) {
+			return false, false
+		}
+	}
+	if strings.Trim(parts[1], "0") == "" || strings.Trim(parts[2], "0") == "" {
+		return false, false
+	}
+	flags, _ := strconv.ParseUint(parts[3], 16, 8)
+	return flags&1 == 1, true
+}
+
+// isLowerHex reports whether s consists of lowercase hex digits.
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if (s[i] < '0' || s[i] > '9') && (s[i] < 'a' || s[i] > 'f') {
+			return false
+		}
+	}
+	return true
+}
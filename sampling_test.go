@@ -0,0 +1,173 @@
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+func TestSamplingSharedDecision(t *testing.T) {
+	var buf bytes.Buffer
+	recorder := NewRecorder(RecorderConfig{Enabled: true, Capacity: 200})
+
+	app := ginji.New()
+	app.Use(Sampling(0.5))
+	app.Use(LoggerWithConfig(LoggerConfig{Logger: slog.New(slog.NewJSONHandler(&buf, nil))}))
+	app.Use(recorder.Middleware())
+	app.Get("/ok", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+	app.Get("/fail", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusInternalServerError, "fail")
+	})
+
+	for i := range 100 {
+		ginji.PerformRequest(app, "GET", fmt.Sprintf("/ok?n=%d", i), nil)
+	}
+	entries := recorder.Entries()
+	if len(entries) == 0 || len(entries) == 100 {
+		t.Fatalf("Expected about half of the requests to be kept, got %d of 100", len(entries))
+	}
+	if logged := strings.Count(buf.String(), "\n"); logged != len(entries) {
+		t.Fatalf("Expected the logger and recorder to keep the same requests, got %d and %d", logged, len(entries))
+	}
+	for _, e := range entries {
+		_, query, _ := strings.Cut(e.Request.URL, "?")
+		if !strings.Contains(buf.String(), `"query":"`+query+`"`) {
+			t.Errorf("Expected request %s to be logged", e.Request.URL)
+		}
+	}
+
+	// Failures are kept whatever the decision on arrival
+	buf.Reset()
+	recorder.Clear()
+	for range 20 {
+		ginji.PerformRequest(app, "GET", "/fail", nil)
+	}
+	if got := strings.Count(buf.String(), "\n"); got != 20 || len(recorder.Entries()) != 20 {
+		t.Errorf("Expected every failure to be kept, got %d logged and %d recorded", got, len(recorder.Entries()))
+	}
+}
+
+func TestSamplingTail(t *testing.T) {
+	var buf bytes.Buffer
+	app := ginji.New()
+	app.Use(SamplingWithConfig(SamplingConfig{
+		PathRates:     map[string]float64{"/**": 0},
+		SlowThreshold: 20 * time.Millisecond,
+		KeepStatus:    -1,
+	}))
+	app.Use(LoggerWithConfig(LoggerConfig{Logger: slog.New(slog.NewJSONHandler(&buf, nil))}))
+	app.Get("/fast", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusBadRequest, "bad")
+	})
+	app.Get("/slow", func(c *ginji.Context) error {
+		time.Sleep(30 * time.Millisecond)
+		return c.Text(ginji.StatusOK, "ok")
+	})
+	app.Get("/kept", func(c *ginji.Context) error {
+		KeepSample(c)
+		if sampled, ok := SampledFromContext(c.Req.Context()); !ok || !sampled {
+			t.Error("Expected the forced decision in the context")
+		}
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	ginji.PerformRequest(app, "GET", "/fast", nil)
+	if buf.Len() > 0 {
+		t.Errorf("Expected a dropped 4xx with KeepStatus -1, got %s", buf.String())
+	}
+	ginji.PerformRequest(app, "GET", "/slow", nil)
+	if !strings.Contains(buf.String(), "/slow") {
+		t.Error("Expected a slow request to be kept")
+	}
+	ginji.PerformRequest(app, "GET", "/kept", nil)
+	if !strings.Contains(buf.String(), "/kept") {
+		t.Error("Expected KeepSample to keep the request")
+	}
+}
+
+func TestSamplingTraceparent(t *testing.T) {
+	app := ginji.New()
+	app.Use(SamplingWithConfig(SamplingConfig{Rate: 0.01, TrustTraceparent: true}))
+	app.Get("/", func(c *ginji.Context) error {
+		sampled, _ := SampledFromContext(c.Req.Context())
+		if sampled {
+			return c.Text(ginji.StatusOK, "sampled")
+		}
+		return c.Text(ginji.StatusOK, "dropped")
+	})
+
+	for range 5 {
+		w := ginji.NewRequest(app, "GET", "/").
+			Header("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01").Do()
+		ginji.AssertBody(t, w, "sampled")
+	}
+	w := ginji.NewRequest(app, "GET", "/").
+		Header("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00").Do()
+	ginji.AssertBody(t, w, "dropped")
+}
+
+func TestSamplingMaxPerSecond(t *testing.T) {
+	kept := 0
+	app := ginji.New()
+	app.Use(SamplingWithConfig(SamplingConfig{MaxPerSecond: 1, Burst: 3}))
+	app.Get("/", func(c *ginji.Context) error {
+		if sampled, _ := SampledFromContext(c.Req.Context()); sampled {
+			kept++
+		}
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	for range 10 {
+		ginji.PerformRequest(app, "GET", "/", nil)
+	}
+	if kept != 3 {
+		t.Errorf("Expected the burst to be kept, got %d", kept)
+	}
+}
+
+func TestSamplingWithoutMiddleware(t *testing.T) {
+	app := ginji.New()
+	app.Get("/", func(c *ginji.Context) error {
+		if _, ok := Sampled(c, nil); ok {
+			t.Error("Expected no decision")
+		}
+		if _, ok := SampledFromContext(c.Req.Context()); ok {
+			t.Error("Expected no decision in the context")
+		}
+		KeepSample(c)
+		return c.Text(ginji.StatusOK, "ok")
+	})
+	ginji.PerformRequest(app, "GET", "/", nil)
+}
+
+func TestTraceparentSampled(t *testing.T) {
+	tests := []struct {
+		value   string
+		sampled bool
+		ok      bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", false, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-03", true, true},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false, false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false, false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false, false},
+		{"00-4bf92f3577b34da6-00f067aa0ba902b7-01", false, false},
+		{"", false, false},
+	}
+	for _, tt := range tests {
+		sampled, ok := traceparentSampled(tt.value)
+		if sampled != tt.sampled || ok != tt.ok {
+			t.Errorf("traceparentSampled(%q) = %v, %v, want %v, %v", tt.value, sampled, ok, tt.sampled, tt.ok)
+		}
+	}
+}
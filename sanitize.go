@@ -0,0 +1,188 @@
+package middleware
+
+import (
+	"html"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ginjigo/ginji"
+)
+
+// SanitizeOptions controls how a single field value is cleaned.
+type SanitizeOptions struct {
+	// TrimSpace removes leading and trailing whitespace.
+	TrimSpace bool
+
+	// NormalizeNewlines converts CRLF and CR line endings to LF.
+	NormalizeNewlines bool
+
+	// StripControl removes control characters other than tab and newline.
+	StripControl bool
+
+	// StripTags removes anything that looks like an HTML tag.
+	StripTags bool
+
+	// EscapeHTML escapes <, >, &, ' and " so the value is safe to render.
+	EscapeHTML bool
+}
+
+// SanitizeField overrides the sanitization options for matching field names.
+type SanitizeField struct {
+	// Pattern is a glob pattern matched against the field name.
+	Pattern string
+
+	// Options are applied to matching fields.
+	Options SanitizeOptions
+}
+
+// SanitizeConfig defines the configuration for input sanitization middleware.
+type SanitizeConfig struct {
+	// Options are applied to every query and form field.
+	Options SanitizeOptions
+
+	// Fields overrides Options for fields whose name matches a glob pattern
+	// (e.g. "password", "html_*"). The first matching entry wins.
+	Fields []SanitizeField
+
+	// RejectInvalidUTF8 rejects requests with invalid UTF-8 in any field with 400.
+	// Default: true
+	RejectInvalidUTF8 bool
+
+	// SkipFunc allows skipping sanitization for certain requests.
+	SkipFunc Skipper
+}
+
+// DefaultSanitizeConfig returns default sanitization configuration.
+func DefaultSanitizeConfig() SanitizeConfig {
+	return SanitizeConfig{
+		Options: SanitizeOptions{
+			TrimSpace:         true,
+			NormalizeNewlines: true,
+			StripControl:      true,
+		},
+		RejectInvalidUTF8: true,
+	}
+}
+
+// Sanitize returns input sanitization middleware with default configuration.
+func Sanitize() ginji.Middleware {
+	return SanitizeWithConfig(DefaultSanitizeConfig())
+}
+
+// SanitizeWithConfig returns input sanitization middleware with custom configuration.
+// It rewrites query and form values in place, so it must run before
+// handlers bind the request.
+func SanitizeWithConfig(config SanitizeConfig) ginji.Middleware {
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		valid := true
+
+		// Query string
+		query := c.Req.URL.Query()
+		if len(query) > 0 {
+			valid = sanitizeValues(query, &config) && valid
+			c.Req.URL.RawQuery = query.Encode()
+		}
+
+		// Form bodies
+		if isFormRequest(c) {
+			if strings.HasPrefix(c.Header("Content-Type"), "multipart/form-data") {
+				_ = c.Req.ParseMultipartForm(32 << 20)
+			} else {
+				_ = c.Req.ParseForm()
+			}
+			valid = sanitizeValues(c.Req.PostForm, &config) && valid
+			if c.Req.MultipartForm != nil {
+				valid = sanitizeValues(c.Req.MultipartForm.Value, &config) && valid
+			}
+
+			// Form holds copies of the body and query values, which are
+			// already sanitized, so it is rebuilt rather than sanitized
+			// again
+			form := make(url.Values, len(c.Req.PostForm)+len(query))
+			for name, vals := range c.Req.PostForm {
+				form[name] = append(form[name], vals...)
+			}
+			for name, vals := range query {
+				form[name] = append(form[name], vals...)
+			}
+			c.Req.Form = form
+		}
+
+		if !valid && config.RejectInvalidUTF8 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, ginji.H{
+				"error": "Request contains invalid UTF-8",
+			})
+			return nil
+		}
+
+		return c.Next()
+	}
+}
+
+// sanitizeValues cleans all values in place and reports whether they were valid UTF-8.
+func sanitizeValues(values url.Values, config *SanitizeConfig) bool {
+	valid := true
+	for name, vals := range values {
+		opts := config.optionsFor(name)
+		for i, v := range vals {
+			if !utf8.ValidString(v) {
+				valid = false
+				v = strings.ToValidUTF8(v, "")
+			}
+			vals[i] = SanitizeString(v, opts)
+		}
+	}
+	return valid
+}
+
+// optionsFor returns the options for a field name.
+func (config *SanitizeConfig) optionsFor(name string) SanitizeOptions {
+	for _, field := range config.Fields {
+		if ok, _ := path.Match(field.Pattern, name); ok {
+			return field.Options
+		}
+	}
+	return config.Options
+}
+
+// tagPattern matches HTML tags and comments.
+var tagPattern = regexp.MustCompile(`<!--[\s\S]*?-->|<[^>]*>`)
+
+// SanitizeString applies the sanitization options to a single value.
+func SanitizeString(s string, opts SanitizeOptions) string {
+	if opts.NormalizeNewlines {
+		s = strings.ReplaceAll(s, "\r\n", "\n")
+		s = strings.ReplaceAll(s, "\r", "\n")
+	}
+	if opts.StripControl {
+		s = strings.Map(func(r rune) rune {
+			if r == '\n' || r == '\t' || r == '\r' {
+				return r
+			}
+			if unicode.IsControl(r) {
+				return -1
+			}
+			return r
+		}, s)
+	}
+	if opts.StripTags {
+		s = tagPattern.ReplaceAllString(s, "")
+	}
+	if opts.TrimSpace {
+		s = strings.TrimSpace(s)
+	}
+	if opts.EscapeHTML {
+		s = html.EscapeString(s)
+	}
+	return s
+}
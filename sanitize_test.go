@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ginjigo/ginji"
+)
+
+func TestSanitizeQuery(t *testing.T) {
+	app := ginji.New()
+	app.Use(Sanitize())
+
+	app.Get("/search", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "["+c.Query("q")+"]")
+	})
+
+	q := url.Values{"q": {"  hello\x00world\x07  "}}
+	w := ginji.PerformRequest(app, "GET", "/search?"+q.Encode(), nil)
+
+	ginji.AssertBody(t, w, "[helloworld]")
+}
+
+func TestSanitizeFormFieldPatterns(t *testing.T) {
+	app := ginji.New()
+	app.Use(SanitizeWithConfig(SanitizeConfig{
+		Options: SanitizeOptions{TrimSpace: true, StripTags: true},
+		Fields: []SanitizeField{
+			{Pattern: "password*", Options: SanitizeOptions{}},
+			{Pattern: "bio", Options: SanitizeOptions{TrimSpace: true, EscapeHTML: true}},
+		},
+	}))
+
+	app.Post("/profile", func(c *ginji.Context) error {
+		return c.JSON(ginji.StatusOK, ginji.H{
+			"name":     c.FormValue("name"),
+			"password": c.FormValue("password"),
+			"bio":      c.FormValue("bio"),
+		})
+	})
+
+	form := url.Values{
+		"name":     {" <b>Ann</b> "},
+		"password": {" <secret> "},
+		"bio":      {"I <3 Go"},
+	}
+	req := httptest.NewRequest("POST", "/profile", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+
+	var got map[string]string
+	if err := ginji.NewResponse(w).JSON(&got); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if got["name"] != "Ann" {
+		t.Errorf("Expected tags stripped and trimmed, got %q", got["name"])
+	}
+	if got["password"] != " <secret> " {
+		t.Errorf("Expected password untouched, got %q", got["password"])
+	}
+	if got["bio"] != "I &lt;3 Go" {
+		t.Errorf("Expected bio escaped, got %q", got["bio"])
+	}
+}
+
+func TestSanitizeEscapesOnce(t *testing.T) {
+	app := ginji.New()
+	app.Use(SanitizeWithConfig(SanitizeConfig{Options: SanitizeOptions{EscapeHTML: true}}))
+
+	app.Post("/search", func(c *ginji.Context) error {
+		return c.JSON(ginji.StatusOK, ginji.H{
+			"q":     c.Req.Form.Get("q"),
+			"query": c.Query("q"),
+			"tag":   c.Req.Form.Get("tag"),
+			"post":  c.Req.PostForm.Get("tag"),
+		})
+	})
+
+	req := httptest.NewRequest("POST", "/search?q=a%26b", strings.NewReader("tag=c%26d"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+
+	var got map[string]string
+	if err := ginji.NewResponse(w).JSON(&got); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	want := map[string]string{"q": "a&amp;b", "query": "a&amp;b", "tag": "c&amp;d", "post": "c&amp;d"}
+	for name, value := range want {
+		if got[name] != value {
+			t.Errorf("Expected %s to be escaped once as %q, got %q", name, value, got[name])
+		}
+	}
+}
+
+func TestSanitizeRejectsInvalidUTF8(t *testing.T) {
+	app := ginji.New()
+	app.Use(Sanitize())
+
+	app.Get("/search", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/search?q=%ff%fe", nil)
+	if w.Code != ginji.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
+
+func TestSanitizeString(t *testing.T) {
+	got := SanitizeString("a\r\nb\u200b\x1b", SanitizeOptions{NormalizeNewlines: true, StripControl: true})
+	if got != "a\nb\u200b" {
+		t.Errorf("Unexpected sanitized value %q", got)
+	}
+}
@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/ginjigo/ginji"
+)
+
+// RequireScope returns middleware that requires the token to grant scope.
+func RequireScope(scope string) ginji.Middleware {
+	return RequireAllScopes(scope)
+}
+
+// RequireAllScopes returns middleware that requires the token to grant
+// every one of scopes.
+func RequireAllScopes(scopes ...string) ginji.Middleware {
+	return requireScopes(scopes, true)
+}
+
+// RequireAnyScope returns middleware that requires the token to grant at
+// least one of scopes.
+func RequireAnyScope(scopes ...string) ginji.Middleware {
+	return requireScopes(scopes, false)
+}
+
+// requireScopes checks the granted scopes against scopes. Requests without
+// token claims get a 401 and requests lacking scopes an RFC 6750 403 with
+// error="insufficient_scope".
+func requireScopes(scopes []string, all bool) ginji.Middleware {
+	required := strings.Join(scopes, " ")
+
+	return func(c *ginji.Context) error {
+		granted, ok := ScopesFrom(c)
+		if !ok {
+			unauthorizedBearer(c, "Authorization Required")
+			return nil
+		}
+
+		if !scopesGranted(granted, scopes, all) {
+			c.SetHeader("WWW-Authenticate", `Bearer realm="Authorization Required", error="insufficient_scope", `+
+				`error_description="The request requires higher privileges than provided by the access token", scope="`+required+`"`)
+			c.AbortWithStatusJSON(ginji.StatusForbidden, ginji.H{
+				"error": "Insufficient scope",
+				"scope": required,
+			})
+			return nil
+		}
+
+		return c.Next()
+	}
+}
+
+// scopesGranted reports whether granted includes all of scopes, or any of
+// them if all is false.
+func scopesGranted(granted, scopes []string, all bool) bool {
+	for _, scope := range scopes {
+		if hasScope(granted, scope) != all {
+			return !all
+		}
+	}
+	return all
+}
+
+// ScopesFrom returns the scopes granted by the verified token claims, read
+// from the space separated "scope" claim or the "scp" list.
+func ScopesFrom(c *ginji.Context) ([]string, bool) {
+	claims, ok := ClaimsFrom(c)
+	if !ok {
+		return nil, false
+	}
+	if scope, ok := claims["scope"]; ok {
+		return claimStrings(scope), true
+	}
+	return claimStrings(claims["scp"]), true
+}
+
+// hasScope reports whether granted includes scope. Scopes are hierarchical:
+// "admin:*" grants "admin:read" and "admin:users:write", and "*" grants
+// everything.
+func hasScope(granted []string, scope string) bool {
+	for _, g := range granted {
+		if g == scope || g == "*" {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(g, "*"); ok && strings.HasSuffix(prefix, ":") && strings.HasPrefix(scope, prefix) {
+			return true
+		}
+	}
+	return false
+}
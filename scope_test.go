@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ginjigo/ginji"
+)
+
+func TestRequireScope(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims map[string]any
+		want   int
+	}{
+		{"granted", map[string]any{"scope": "openid read:users"}, ginji.StatusOK},
+		{"scp list", map[string]any{"scp": []any{"read:users"}}, ginji.StatusOK},
+		{"wildcard", map[string]any{"scope": "read:*"}, ginji.StatusOK},
+		{"global wildcard", map[string]any{"scope": "*"}, ginji.StatusOK},
+		{"missing", map[string]any{"scope": "read:orders"}, ginji.StatusForbidden},
+		{"prefix is not wildcard", map[string]any{"scope": "read"}, ginji.StatusForbidden},
+		{"no token", nil, ginji.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := ginji.New()
+			app.Use(func(c *ginji.Context) error {
+				if tt.claims != nil {
+					SetClaims(c, tt.claims)
+				}
+				return c.Next()
+			})
+			app.Use(RequireScope("read:users"))
+			app.Get("/users", func(c *ginji.Context) error {
+				return c.Text(ginji.StatusOK, "users")
+			})
+			ginji.AssertStatus(t, ginji.PerformRequest(app, "GET", "/users", nil), tt.want)
+		})
+	}
+}
+
+func TestRequireScopeChallenge(t *testing.T) {
+	app := ginji.New()
+	app.Use(func(c *ginji.Context) error {
+		SetClaims(c, map[string]any{"scope": "read:orders"})
+		return c.Next()
+	})
+	app.Use(RequireAllScopes("read:users", "write:users"))
+	app.Get("/users", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "users")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/users", nil)
+	ginji.AssertStatus(t, w, ginji.StatusForbidden)
+	ginji.AssertBody(t, w, "Insufficient scope")
+
+	challenge := w.Header().Get("WWW-Authenticate")
+	for _, want := range []string{"Bearer ", `error="insufficient_scope"`, `scope="read:users write:users"`} {
+		if !strings.Contains(challenge, want) {
+			t.Errorf("Expected challenge to contain %s, got %q", want, challenge)
+		}
+	}
+}
+
+func TestRequireAllScopes(t *testing.T) {
+	var scope string
+	app := ginji.New()
+	app.Use(func(c *ginji.Context) error {
+		SetClaims(c, map[string]any{"scope": scope})
+		return c.Next()
+	})
+	app.Use(RequireAllScopes("read:users", "write:users"))
+	app.Get("/users", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "users")
+	})
+
+	scope = "read:users write:users"
+	ginji.AssertStatus(t, ginji.PerformRequest(app, "GET", "/users", nil), ginji.StatusOK)
+
+	scope = "read:users"
+	ginji.AssertStatus(t, ginji.PerformRequest(app, "GET", "/users", nil), ginji.StatusForbidden)
+}
+
+func TestRequireAnyScope(t *testing.T) {
+	var scope string
+	app := ginji.New()
+	app.Use(func(c *ginji.Context) error {
+		SetClaims(c, map[string]any{"scope": scope})
+		return c.Next()
+	})
+	app.Use(RequireAnyScope("read:users", "admin:users"))
+	app.Get("/users", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "users")
+	})
+
+	scope = "admin:*"
+	ginji.AssertStatus(t, ginji.PerformRequest(app, "GET", "/users", nil), ginji.StatusOK)
+
+	scope = "write:users"
+	ginji.AssertStatus(t, ginji.PerformRequest(app, "GET", "/users", nil), ginji.StatusForbidden)
+}
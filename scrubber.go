@@ -0,0 +1,454 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// ScrubStrategy is how a Scrubber replaces the PII it finds.
+type ScrubStrategy int
+
+const (
+	// ScrubMask replaces a match with its detector name, e.g. "[email]".
+	ScrubMask ScrubStrategy = iota
+
+	// ScrubHash replaces a match with a keyed hash, e.g. "[email:9f86d081]",
+	// so that records of the same value can still be correlated.
+	ScrubHash
+
+	// ScrubDrop removes a match from free text, and the whole field from
+	// query strings, JSON bodies, log attributes and audit events.
+	ScrubDrop
+)
+
+// ScrubDetector finds one kind of PII in text.
+type ScrubDetector struct {
+	// Name labels the replacement, e.g. "email".
+	Name string
+
+	// Pattern matches candidates.
+	Pattern *regexp.Regexp
+
+	// Validate, if set, filters the matches of Pattern, e.g. with a
+	// checksum, to cut false positives.
+	Validate func(match string) bool
+}
+
+// EmailDetector detects email addresses.
+func EmailDetector() ScrubDetector {
+	return ScrubDetector{
+		Name:    "email",
+		Pattern: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}`),
+	}
+}
+
+// CreditCardDetector detects payment card numbers of 13 to 19 digits,
+// optionally grouped with spaces or dashes, that pass the Luhn check.
+func CreditCardDetector() ScrubDetector {
+	return ScrubDetector{
+		Name:     "card",
+		Pattern:  regexp.MustCompile(`\b\d(?:[ \-]?\d){12,18}\b`),
+		Validate: luhnValid,
+	}
+}
+
+// SSNDetector detects US social security numbers written as 123-45-6789.
+func SSNDetector() ScrubDetector {
+	return ScrubDetector{
+		Name:    "ssn",
+		Pattern: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`),
+		Validate: func(match string) bool {
+			// Area 000, 666 and 9xx, group 00 and serial 0000 are never issued
+			area, group, serial := match[:3], match[4:6], match[7:]
+			return area != "000" && area != "666" && area[0] != '9' &&
+				group != "00" && serial != "0000"
+		},
+	}
+}
+
+// BearerTokenDetector detects bearer tokens, as found in Authorization
+// values that end up in logged bodies or error messages.
+func BearerTokenDetector() ScrubDetector {
+	return ScrubDetector{
+		Name:    "token",
+		Pattern: regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9\-._~+/]+=*`),
+	}
+}
+
+// RegexpDetector returns a detector for a custom pattern, e.g.
+// RegexpDetector("account", `\bACC-\d{8}\b`). It panics if pattern is
+// invalid.
+func RegexpDetector(name, pattern string) ScrubDetector {
+	return ScrubDetector{Name: name, Pattern: regexp.MustCompile(pattern)}
+}
+
+// luhnValid reports whether the digits of s pass the Luhn check.
+func luhnValid(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		if s[i] < '0' || s[i] > '9' {
+			continue
+		}
+		d := int(s[i] - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n > 0 && sum%10 == 0
+}
+
+// ScrubberConfig defines the configuration for a Scrubber.
+type ScrubberConfig struct {
+	// Detectors find PII in text.
+	// Default: EmailDetector, CreditCardDetector, SSNDetector and
+	// BearerTokenDetector
+	Detectors []ScrubDetector
+
+	// Strategy replaces what the detectors find.
+	// Default: ScrubMask
+	Strategy ScrubStrategy
+
+	// Fields are scrubbed whole with the given strategy whatever their
+	// value, e.g. {"password": ScrubDrop, "phone": ScrubHash}. Field names
+	// are query parameters, JSON object keys, log attribute keys and audit
+	// event fields, matched case-insensitively.
+	Fields map[string]ScrubStrategy
+
+	// AllowFields are never scrubbed, e.g. "user" to keep the actor of
+	// audit events that identify users by email. Matched
+	// case-insensitively.
+	AllowFields []string
+
+	// HashKey keys the ScrubHash replacements, so that hashes of
+	// low-entropy values such as SSNs cannot be reversed by brute force.
+	// Default: a hash without key
+	HashKey []byte
+}
+
+// DefaultScrubberConfig returns default scrubber configuration.
+func DefaultScrubberConfig() ScrubberConfig {
+	return ScrubberConfig{
+		Detectors: []ScrubDetector{
+			EmailDetector(),
+			CreditCardDetector(),
+			SSNDetector(),
+			BearerTokenDetector(),
+		},
+		Strategy: ScrubMask,
+	}
+}
+
+// Scrubber removes PII from text before it reaches a sink. Set it as the
+// Scrubber of LoggerConfig, RecorderConfig and AuditConfig, or wrap other
+// log handlers with Handler:
+//
+//	scrubber := middleware.NewScrubber(middleware.ScrubberConfig{
+//		Fields:      map[string]middleware.ScrubStrategy{"password": middleware.ScrubDrop},
+//		AllowFields: []string{"user"},
+//	})
+//	logger := slog.New(scrubber.Handler(slog.NewJSONHandler(os.Stdout, nil)))
+//
+// A Scrubber is safe for concurrent use.
+type Scrubber struct {
+	config ScrubberConfig
+	fields map[string]ScrubStrategy
+	allow  map[string]bool
+}
+
+// NewScrubber creates a scrubber with the given configuration.
+func NewScrubber(config ScrubberConfig) *Scrubber {
+	defaults := DefaultScrubberConfig()
+	if config.Detectors == nil {
+		config.Detectors = defaults.Detectors
+	}
+
+	s := &Scrubber{
+		config: config,
+		fields: make(map[string]ScrubStrategy, len(config.Fields)),
+		allow:  make(map[string]bool, len(config.AllowFields)),
+	}
+	for name, strategy := range config.Fields {
+		s.fields[strings.ToLower(name)] = strategy
+	}
+	for _, name := range config.AllowFields {
+		s.allow[strings.ToLower(name)] = true
+	}
+	return s
+}
+
+// Text scrubs free text, such as a request body that is not JSON.
+func (s *Scrubber) Text(text string) string {
+	scrubbed, _ := s.detect(text)
+	return scrubbed
+}
+
+// Field scrubs the value of a named field. It returns false when the field
+// must be dropped.
+func (s *Scrubber) Field(name, value string) (string, bool) {
+	key := strings.ToLower(name)
+	if s.allow[key] {
+		return value, true
+	}
+	if strategy, ok := s.fields[key]; ok {
+		if strategy == ScrubDrop {
+			return "", false
+		}
+		return s.replace(name, value, strategy), true
+	}
+
+	scrubbed, found := s.detect(value)
+	if found && s.config.Strategy == ScrubDrop {
+		return "", false
+	}
+	return scrubbed, true
+}
+
+// Query scrubs a raw query string parameter by parameter, keeping the
+// order and encoding of the untouched ones.
+func (s *Scrubber) Query(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	params := strings.Split(rawQuery, "&")
+	kept := params[:0]
+	for _, param := range params {
+		rawKey, rawValue, _ := strings.Cut(param, "=")
+		key, err := url.QueryUnescape(rawKey)
+		if err != nil {
+			key = rawKey
+		}
+		value, err := url.QueryUnescape(rawValue)
+		if err != nil {
+			value = rawValue
+		}
+
+		scrubbed, ok := s.Field(key, value)
+		switch {
+		case !ok:
+			continue
+		case scrubbed != value:
+			param = rawKey + "=" + scrubQueryEscaper.Replace(url.QueryEscape(scrubbed))
+		}
+		kept = append(kept, param)
+	}
+	return strings.Join(kept, "&")
+}
+
+// scrubQueryEscaper keeps the replacements readable in logged queries.
+var scrubQueryEscaper = strings.NewReplacer("%5B", "[", "%5D", "]", "%3A", ":")
+
+// URL scrubs a URL, its path as text and its query with Query.
+func (s *Scrubber) URL(rawURL string) string {
+	base, rawQuery, ok := strings.Cut(rawURL, "?")
+	if !ok {
+		return s.Text(rawURL)
+	}
+	return s.Text(base) + "?" + s.Query(rawQuery)
+}
+
+// JSON scrubs a JSON document field by field, using object keys as field
+// names. Documents that do not parse, such as truncated bodies, are
+// scrubbed as text.
+func (s *Scrubber) JSON(body []byte) []byte {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil || dec.More() {
+		return []byte(s.Text(string(body)))
+	}
+
+	scrubbed, err := json.Marshal(s.value("", doc))
+	if err != nil {
+		return []byte(s.Text(string(body)))
+	}
+	return scrubbed
+}
+
+// Body scrubs a captured body, as JSON if it looks like JSON and as text
+// otherwise.
+func (s *Scrubber) Body(body string) string {
+	trimmed := strings.TrimSpace(body)
+	if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		return string(s.JSON([]byte(body)))
+	}
+	return s.Text(body)
+}
+
+// value scrubs a decoded JSON value found in field name. Dropped values
+// are returned as nil, removing them from objects.
+func (s *Scrubber) value(name string, v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, item := range v {
+			if scrubbed := s.value(key, item); scrubbed != nil {
+				v[key] = scrubbed
+			} else if item != nil {
+				delete(v, key)
+			}
+		}
+		return v
+	case []any:
+		kept := v[:0]
+		for _, item := range v {
+			if scrubbed := s.value(name, item); scrubbed != nil || item == nil {
+				kept = append(kept, scrubbed)
+			}
+		}
+		return kept
+	case string:
+		if scrubbed, ok := s.Field(name, v); ok {
+			return scrubbed
+		}
+		return nil
+	case json.Number:
+		// Card numbers are often sent as numbers
+		scrubbed, ok := s.Field(name, v.String())
+		switch {
+		case !ok:
+			return nil
+		case scrubbed != v.String():
+			return scrubbed
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// AuditEvent scrubs the free-form fields of an audit event: the user,
+// path and resource ID, named "user", "path" and "resource_id". A dropped
+// field is left empty.
+func (s *Scrubber) AuditEvent(event AuditEvent) AuditEvent {
+	event.User, _ = s.Field("user", event.User)
+	event.Path, _ = s.Field("path", event.Path)
+	event.ResourceID, _ = s.Field("resource_id", event.ResourceID)
+	return event
+}
+
+// AuditSink wraps sink, scrubbing events before they are written. Prefer
+// the Scrubber option of AuditConfig when hash chaining, so that hashes
+// cover the scrubbed events.
+func (s *Scrubber) AuditSink(sink AuditSink) AuditSink {
+	return AuditSinkFunc(func(ctx context.Context, event AuditEvent) error {
+		return sink.WriteAudit(ctx, s.AuditEvent(event))
+	})
+}
+
+// Handler wraps a slog handler, scrubbing string attributes by key before
+// they are handled. Messages are scrubbed as text.
+func (s *Scrubber) Handler(next slog.Handler) slog.Handler {
+	return &scrubHandler{scrubber: s, next: next}
+}
+
+// scrubHandler is the slog.Handler returned by Scrubber.Handler.
+type scrubHandler struct {
+	scrubber *Scrubber
+	next     slog.Handler
+}
+
+func (h *scrubHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *scrubHandler) Handle(ctx context.Context, r slog.Record) error {
+	scrubbed := slog.NewRecord(r.Time, r.Level, h.scrubber.Text(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		if a, ok := h.scrubber.attr(a); ok {
+			scrubbed.AddAttrs(a)
+		}
+		return true
+	})
+	return h.next.Handle(ctx, scrubbed)
+}
+
+func (h *scrubHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	kept := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		if a, ok := h.scrubber.attr(a); ok {
+			kept = append(kept, a)
+		}
+	}
+	return &scrubHandler{scrubber: h.scrubber, next: h.next.WithAttrs(kept)}
+}
+
+func (h *scrubHandler) WithGroup(name string) slog.Handler {
+	return &scrubHandler{scrubber: h.scrubber, next: h.next.WithGroup(name)}
+}
+
+// attr scrubs a log attribute. It returns false when the attribute must be
+// dropped.
+func (s *Scrubber) attr(a slog.Attr) (slog.Attr, bool) {
+	a.Value = a.Value.Resolve()
+	switch a.Value.Kind() {
+	case slog.KindGroup:
+		group := a.Value.Group()
+		kept := make([]slog.Attr, 0, len(group))
+		for _, item := range group {
+			if item, ok := s.attr(item); ok {
+				kept = append(kept, item)
+			}
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(kept...)}, true
+	case slog.KindString:
+		v, ok := s.Field(a.Key, a.Value.String())
+		return slog.String(a.Key, v), ok
+	case slog.KindAny:
+		// Errors and other values are logged as text
+		if _, isField := s.fields[strings.ToLower(a.Key)]; !isField {
+			if _, ok := a.Value.Any().(error); !ok {
+				return a, true
+			}
+		}
+		v, ok := s.Field(a.Key, a.Value.String())
+		return slog.String(a.Key, v), ok
+	default:
+		if _, ok := s.fields[strings.ToLower(a.Key)]; ok && !s.allow[strings.ToLower(a.Key)] {
+			v, ok := s.Field(a.Key, a.Value.String())
+			return slog.String(a.Key, v), ok
+		}
+		return a, true
+	}
+}
+
+// detect replaces what the detectors find in text with the configured
+// strategy, reporting whether anything was found.
+func (s *Scrubber) detect(text string) (string, bool) {
+	found := false
+	for _, d := range s.config.Detectors {
+		text = d.Pattern.ReplaceAllStringFunc(text, func(match string) string {
+			if d.Validate != nil && !d.Validate(match) {
+				return match
+			}
+			found = true
+			return s.replace(d.Name, match, s.config.Strategy)
+		})
+	}
+	return text, found
+}
+
+// replace returns the replacement of value labeled name.
+func (s *Scrubber) replace(name, value string, strategy ScrubStrategy) string {
+	switch strategy {
+	case ScrubHash:
+		mac := hmac.New(sha256.New, s.config.HashKey)
+		mac.Write([]byte(value))
+		return "[" + name + ":" + hex.EncodeToString(mac.Sum(nil)[:4]) + "]"
+	case ScrubDrop:
+		return ""
+	default:
+		return "[" + name + "]"
+	}
+}
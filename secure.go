@@ -1,8 +1,16 @@
 package middleware
 
 import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ginjigo/ginji"
 )
@@ -22,6 +30,14 @@ type SecureConfig struct {
 	// Default: "SAMEORIGIN"
 	XFrameOptions string
 
+	// XFrameOptionsFromCSP derives X-Frame-Options from the frame-ancestors
+	// directive of ContentSecurityPolicy so the two cannot conflict: 'none'
+	// becomes DENY, 'self' becomes SAMEORIGIN, and any other source list
+	// omits the header, since X-Frame-Options cannot express it. Without a
+	// frame-ancestors directive, XFrameOptions is used as is.
+	// Default: false
+	XFrameOptionsFromCSP bool
+
 	// HSTSMaxAge sets the Strict-Transport-Security header max-age value in seconds.
 	// Default: 0 (disabled)
 	HSTSMaxAge int
@@ -38,11 +54,19 @@ type SecureConfig struct {
 	// Default: "" (not set)
 	ContentSecurityPolicy string
 
+	// CSPNonce generates a nonce for every request and adds it as a
+	// 'nonce-...' source to the default-src, script-src and style-src
+	// directives of ContentSecurityPolicy. Read it with CSPNonce; Render
+	// passes it to templates as .Nonce.
+	// Default: false
+	CSPNonce bool
+
 	// ReferrerPolicy sets the Referrer-Policy header.
 	// Default: "" (not set)
 	ReferrerPolicy string
 
-	// PermissionsPolicy sets the Permissions-Policy header.
+	// PermissionsPolicy sets the Permissions-Policy header. Use
+	// NewPermissionsPolicy to build it.
 	// Default: "" (not set)
 	PermissionsPolicy string
 
@@ -60,6 +84,143 @@ type SecureConfig struct {
 	// Possible values: "same-site", "same-origin", "cross-origin"
 	// Default: "" (not set)
 	CrossOriginResourcePolicy string
+
+	// ReportingEndpoints sets the Reporting-Endpoints header, mapping
+	// endpoint names to report URLs for CSP report-to and other reports.
+	// Default: nil (not set)
+	ReportingEndpoints map[string]string
+
+	// ReportTo sets the legacy Report-To header, still needed for NEL.
+	// Default: nil (not set)
+	ReportTo []ReportToGroup
+
+	// NEL sets the Network Error Logging policy header. Its ReportTo must
+	// name a ReportTo group.
+	// Default: nil (not set)
+	NEL *NELPolicy
+
+	// ExpectCT sets the Expect-CT header. Browsers have dropped it since
+	// Certificate Transparency became mandatory; only set it for old
+	// clients.
+	// Default: nil (not set)
+	ExpectCT *ExpectCTPolicy
+
+	// SkipFunc allows skipping security headers for certain requests.
+	SkipFunc Skipper
+}
+
+// ReportToGroup is one endpoint group of the Report-To header.
+type ReportToGroup struct {
+	// Group is the name reports refer to.
+	Group string
+
+	// MaxAge is how long the browser remembers the group.
+	MaxAge time.Duration
+
+	// Endpoints are the report URLs.
+	Endpoints []string
+
+	// IncludeSubdomains applies the group to subdomains.
+	IncludeSubdomains bool
+}
+
+// String returns the group as a Report-To JSON object.
+func (g ReportToGroup) String() string {
+	endpoints := make([]map[string]string, len(g.Endpoints))
+	for i, url := range g.Endpoints {
+		endpoints[i] = map[string]string{"url": url}
+	}
+	return marshalHeaderJSON(struct {
+		Group             string              `json:"group,omitempty"`
+		MaxAge            int64               `json:"max_age"`
+		Endpoints         []map[string]string `json:"endpoints"`
+		IncludeSubdomains bool                `json:"include_subdomains,omitempty"`
+	}{g.Group, int64(g.MaxAge.Seconds()), endpoints, g.IncludeSubdomains})
+}
+
+// NELPolicy is a Network Error Logging policy.
+type NELPolicy struct {
+	// ReportTo is the Report-To group that receives the reports.
+	ReportTo string
+
+	// MaxAge is how long the browser applies the policy.
+	MaxAge time.Duration
+
+	// IncludeSubdomains applies the policy to subdomains.
+	IncludeSubdomains bool
+
+	// SuccessFraction is the fraction of successful requests reported.
+	// Default: 0
+	SuccessFraction float64
+
+	// FailureFraction is the fraction of failed requests reported.
+	// Default: 1 (when zero)
+	FailureFraction float64
+}
+
+// String returns the NEL header value.
+func (p NELPolicy) String() string {
+	failure := p.FailureFraction
+	if failure == 0 {
+		failure = 1
+	}
+	return marshalHeaderJSON(struct {
+		ReportTo          string  `json:"report_to"`
+		MaxAge            int64   `json:"max_age"`
+		IncludeSubdomains bool    `json:"include_subdomains,omitempty"`
+		SuccessFraction   float64 `json:"success_fraction,omitempty"`
+		FailureFraction   float64 `json:"failure_fraction"`
+	}{p.ReportTo, int64(p.MaxAge.Seconds()), p.IncludeSubdomains, p.SuccessFraction, failure})
+}
+
+// ExpectCTPolicy is an Expect-CT policy.
+type ExpectCTPolicy struct {
+	// MaxAge is how long the browser applies the policy.
+	MaxAge time.Duration
+
+	// Enforce refuses connections that violate the policy instead of
+	// only reporting them.
+	Enforce bool
+
+	// ReportURI receives violation reports. Optional.
+	ReportURI string
+}
+
+// String returns the Expect-CT header value.
+func (p ExpectCTPolicy) String() string {
+	parts := []string{"max-age=" + strconv.FormatInt(int64(p.MaxAge.Seconds()), 10)}
+	if p.Enforce {
+		parts = append(parts, "enforce")
+	}
+	if p.ReportURI != "" {
+		parts = append(parts, "report-uri="+strconv.Quote(p.ReportURI))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// FormatReportingEndpoints returns the Reporting-Endpoints header value
+// for endpoints, sorted by name.
+func FormatReportingEndpoints(endpoints map[string]string) string {
+	names := make([]string, 0, len(endpoints))
+	for name := range endpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + "=" + strconv.Quote(endpoints[name])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// marshalHeaderJSON encodes v as compact JSON without HTML escaping.
+func marshalHeaderJSON(v any) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(v)
+	return strings.TrimSpace(buf.String())
 }
 
 // DefaultSecureConfig returns a default secure configuration.
@@ -79,68 +240,130 @@ func Secure() ginji.Middleware {
 
 // SecureWithConfig returns a middleware that sets security headers with custom configuration.
 func SecureWithConfig(config SecureConfig) ginji.Middleware {
-	return func(c *ginji.Context) error {
-		// X-XSS-Protection
-		if config.XSSProtection != "" {
-			c.SetHeader("X-XSS-Protection", config.XSSProtection)
-		}
-
-		// X-Content-Type-Options
-		if config.ContentTypeNosniff != "" {
-			c.SetHeader("X-Content-Type-Options", config.ContentTypeNosniff)
+	if config.XFrameOptionsFromCSP {
+		if sources, ok := cspDirective(config.ContentSecurityPolicy, "frame-ancestors"); ok {
+			config.XFrameOptions = frameOptionsFor(sources)
 		}
+	}
 
-		// X-Frame-Options
-		if config.XFrameOptions != "" {
-			c.SetHeader("X-Frame-Options", config.XFrameOptions)
+	// Headers with computed values are built once
+	var hsts string
+	if config.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.Itoa(config.HSTSMaxAge)
+		if config.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
 		}
-
-		// Strict-Transport-Security
-		if config.HSTSMaxAge > 0 {
-			hsts := fmt.Sprintf("max-age=%d", config.HSTSMaxAge)
-			if config.HSTSIncludeSubdomains {
-				hsts += "; includeSubDomains"
-			}
-			if config.HSTSPreload {
-				hsts += "; preload"
-			}
-			c.SetHeader("Strict-Transport-Security", hsts)
+		if config.HSTSPreload {
+			hsts += "; preload"
 		}
+	}
 
-		// Content-Security-Policy
-		if config.ContentSecurityPolicy != "" {
-			c.SetHeader("Content-Security-Policy", config.ContentSecurityPolicy)
+	var reportingEndpoints, reportTo, nel, expectCT string
+	if len(config.ReportingEndpoints) > 0 {
+		reportingEndpoints = FormatReportingEndpoints(config.ReportingEndpoints)
+	}
+	if len(config.ReportTo) > 0 {
+		groups := make([]string, len(config.ReportTo))
+		for i, group := range config.ReportTo {
+			groups[i] = group.String()
 		}
+		reportTo = strings.Join(groups, ", ")
+	}
+	if config.NEL != nil {
+		nel = config.NEL.String()
+	}
+	if config.ExpectCT != nil {
+		expectCT = config.ExpectCT.String()
+	}
 
-		// Referrer-Policy
-		if config.ReferrerPolicy != "" {
-			c.SetHeader("Referrer-Policy", config.ReferrerPolicy)
-		}
+	// With a nonce the policy differs per request and is set separately
+	csp, nonceCSP := config.ContentSecurityPolicy, ""
+	if config.CSPNonce {
+		csp, nonceCSP = "", withCSPNonce(config.ContentSecurityPolicy, cspNoncePlaceholder)
+	}
 
-		// Permissions-Policy
-		if config.PermissionsPolicy != "" {
-			c.SetHeader("Permissions-Policy", config.PermissionsPolicy)
+	// The header list is rendered once, with canonical names so that
+	// setting them does not allocate
+	var headers [][2]string
+	for _, h := range [][2]string{
+		{"X-XSS-Protection", config.XSSProtection},
+		{"X-Content-Type-Options", config.ContentTypeNosniff},
+		{"X-Frame-Options", config.XFrameOptions},
+		{"Strict-Transport-Security", hsts},
+		{"Content-Security-Policy", csp},
+		{"Referrer-Policy", config.ReferrerPolicy},
+		{"Permissions-Policy", config.PermissionsPolicy},
+		{"Cross-Origin-Embedder-Policy", config.CrossOriginEmbedderPolicy},
+		{"Cross-Origin-Opener-Policy", config.CrossOriginOpenerPolicy},
+		{"Cross-Origin-Resource-Policy", config.CrossOriginResourcePolicy},
+		{"Reporting-Endpoints", reportingEndpoints},
+		{"Report-To", reportTo},
+		{"NEL", nel},
+		{"Expect-CT", expectCT},
+	} {
+		if h[1] != "" {
+			headers = append(headers, [2]string{http.CanonicalHeaderKey(h[0]), h[1]})
 		}
+	}
 
-		// Cross-Origin-Embedder-Policy
-		if config.CrossOriginEmbedderPolicy != "" {
-			c.SetHeader("Cross-Origin-Embedder-Policy", config.CrossOriginEmbedderPolicy)
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
 		}
 
-		// Cross-Origin-Opener-Policy
-		if config.CrossOriginOpenerPolicy != "" {
-			c.SetHeader("Cross-Origin-Opener-Policy", config.CrossOriginOpenerPolicy)
+		h := c.Res.Header()
+		for _, header := range headers {
+			h.Set(header[0], header[1])
 		}
 
-		// Cross-Origin-Resource-Policy
-		if config.CrossOriginResourcePolicy != "" {
-			c.SetHeader("Cross-Origin-Resource-Policy", config.CrossOriginResourcePolicy)
+		if config.CSPNonce {
+			nonce := generateCSPNonce()
+			setContextValue(c, cspNonceContextKey, nonce)
+			if nonceCSP != "" {
+				h.Set("Content-Security-Policy", strings.ReplaceAll(nonceCSP, cspNoncePlaceholder, nonce))
+			}
 		}
 
 		return c.Next()
 	}
 }
 
+// cspNoncePlaceholder marks where the nonce goes in a precomputed policy.
+const cspNoncePlaceholder = "\x00nonce\x00"
+
+// CSPNonce returns the Content-Security-Policy nonce generated by Secure
+// with CSPNonce set, or "" if there is none.
+func CSPNonce(c *ginji.Context) string {
+	nonce, _ := c.Req.Context().Value(cspNonceContextKey).(string)
+	return nonce
+}
+
+// generateCSPNonce returns a random nonce, base64url-encoded so that
+// templates do not escape it.
+func generateCSPNonce() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return base64.RawURLEncoding.EncodeToString(b[:])
+}
+
+// withCSPNonce adds a 'nonce-...' source to the default-src, script-src and
+// style-src directives of policy.
+func withCSPNonce(policy, nonce string) string {
+	parts := strings.Split(policy, ";")
+	for i, part := range parts {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		switch strings.ToLower(fields[0]) {
+		case "default-src", "script-src", "style-src":
+			parts[i] = strings.TrimRight(part, " ") + " 'nonce-" + nonce + "'"
+		}
+	}
+	return strings.Join(parts, ";")
+}
+
 // SecureStrict returns middleware with strict security headers for production.
 func SecureStrict() ginji.Middleware {
 	config := SecureConfig{
@@ -231,16 +454,71 @@ func (csp *CSP) FormAction(sources ...string) *CSP {
 	return csp
 }
 
+// FrameAncestors sets the frame-ancestors directive, which controls who may
+// embed the page and so protects against clickjacking. Use "'none'" to
+// forbid framing entirely.
+func (csp *CSP) FrameAncestors(sources ...string) *CSP {
+	csp.directives["frame-ancestors"] = sources
+	return csp
+}
+
+// Sandbox sets the sandbox directive. Without tokens every restriction
+// applies; tokens such as "allow-scripts" lift individual ones.
+func (csp *CSP) Sandbox(tokens ...string) *CSP {
+	csp.directives["sandbox"] = tokens
+	return csp
+}
+
+// WorkerSrc sets the worker-src directive.
+func (csp *CSP) WorkerSrc(sources ...string) *CSP {
+	csp.directives["worker-src"] = sources
+	return csp
+}
+
+// MediaSrc sets the media-src directive.
+func (csp *CSP) MediaSrc(sources ...string) *CSP {
+	csp.directives["media-src"] = sources
+	return csp
+}
+
+// ManifestSrc sets the manifest-src directive.
+func (csp *CSP) ManifestSrc(sources ...string) *CSP {
+	csp.directives["manifest-src"] = sources
+	return csp
+}
+
+// ReportTo sets the report-to directive to a group defined by the
+// Reporting-Endpoints header.
+func (csp *CSP) ReportTo(group string) *CSP {
+	csp.directives["report-to"] = []string{group}
+	return csp
+}
+
+// ReportURI sets the deprecated report-uri directive, still needed for
+// browsers without report-to support.
+func (csp *CSP) ReportURI(uris ...string) *CSP {
+	csp.directives["report-uri"] = uris
+	return csp
+}
+
 // UpgradeInsecureRequests adds the upgrade-insecure-requests directive.
 func (csp *CSP) UpgradeInsecureRequests() *CSP {
 	csp.directives["upgrade-insecure-requests"] = []string{}
 	return csp
 }
 
-// Build constructs the CSP header value.
+// Build constructs the CSP header value. Directives are sorted by name so
+// the output is deterministic.
 func (csp *CSP) Build() string {
-	var parts []string
-	for directive, sources := range csp.directives {
+	names := make([]string, 0, len(csp.directives))
+	for directive := range csp.directives {
+		names = append(names, directive)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, directive := range names {
+		sources := csp.directives[directive]
 		if len(sources) == 0 {
 			parts = append(parts, directive)
 		} else {
@@ -249,3 +527,184 @@ func (csp *CSP) Build() string {
 	}
 	return strings.Join(parts, "; ")
 }
+
+// cspDirective returns the sources of directive in policy.
+func cspDirective(policy, directive string) ([]string, bool) {
+	for _, part := range strings.Split(policy, ";") {
+		fields := strings.Fields(part)
+		if len(fields) > 0 && strings.EqualFold(fields[0], directive) {
+			return fields[1:], true
+		}
+	}
+	return nil, false
+}
+
+// frameOptionsFor returns the X-Frame-Options value equivalent to the
+// frame-ancestors sources, or "" if there is none.
+func frameOptionsFor(sources []string) string {
+	switch {
+	case len(sources) == 0, len(sources) == 1 && sources[0] == "'none'":
+		return "DENY"
+	case len(sources) == 1 && sources[0] == "'self'":
+		return "SAMEORIGIN"
+	}
+	return ""
+}
+
+// PermissionsPolicy is a helper to build Permissions-Policy headers.
+// Allowlists accept "self", "*", "none" (or no arguments) and origins;
+// CSP-style quoted keywords such as "'self'" are accepted too.
+//
+//	policy := middleware.NewPermissionsPolicy().
+//		Camera().
+//		Geolocation("self", "https://maps.example.com").
+//		Build() // camera=(), geolocation=(self "https://maps.example.com")
+type PermissionsPolicy struct {
+	features map[string][]string
+}
+
+// NewPermissionsPolicy creates a new Permissions-Policy builder.
+func NewPermissionsPolicy() *PermissionsPolicy {
+	return &PermissionsPolicy{
+		features: make(map[string][]string),
+	}
+}
+
+// Feature sets the allowlist of any feature, for features without a
+// dedicated method.
+func (p *PermissionsPolicy) Feature(name string, allowlist ...string) *PermissionsPolicy {
+	p.features[name] = allowlist
+	return p
+}
+
+// Accelerometer sets the accelerometer allowlist.
+func (p *PermissionsPolicy) Accelerometer(allowlist ...string) *PermissionsPolicy {
+	return p.Feature("accelerometer", allowlist...)
+}
+
+// Autoplay sets the autoplay allowlist.
+func (p *PermissionsPolicy) Autoplay(allowlist ...string) *PermissionsPolicy {
+	return p.Feature("autoplay", allowlist...)
+}
+
+// Camera sets the camera allowlist.
+func (p *PermissionsPolicy) Camera(allowlist ...string) *PermissionsPolicy {
+	return p.Feature("camera", allowlist...)
+}
+
+// ClipboardRead sets the clipboard-read allowlist.
+func (p *PermissionsPolicy) ClipboardRead(allowlist ...string) *PermissionsPolicy {
+	return p.Feature("clipboard-read", allowlist...)
+}
+
+// ClipboardWrite sets the clipboard-write allowlist.
+func (p *PermissionsPolicy) ClipboardWrite(allowlist ...string) *PermissionsPolicy {
+	return p.Feature("clipboard-write", allowlist...)
+}
+
+// DisplayCapture sets the display-capture allowlist.
+func (p *PermissionsPolicy) DisplayCapture(allowlist ...string) *PermissionsPolicy {
+	return p.Feature("display-capture", allowlist...)
+}
+
+// FullScreen sets the fullscreen allowlist.
+func (p *PermissionsPolicy) FullScreen(allowlist ...string) *PermissionsPolicy {
+	return p.Feature("fullscreen", allowlist...)
+}
+
+// Geolocation sets the geolocation allowlist.
+func (p *PermissionsPolicy) Geolocation(allowlist ...string) *PermissionsPolicy {
+	return p.Feature("geolocation", allowlist...)
+}
+
+// Gyroscope sets the gyroscope allowlist.
+func (p *PermissionsPolicy) Gyroscope(allowlist ...string) *PermissionsPolicy {
+	return p.Feature("gyroscope", allowlist...)
+}
+
+// Magnetometer sets the magnetometer allowlist.
+func (p *PermissionsPolicy) Magnetometer(allowlist ...string) *PermissionsPolicy {
+	return p.Feature("magnetometer", allowlist...)
+}
+
+// Microphone sets the microphone allowlist.
+func (p *PermissionsPolicy) Microphone(allowlist ...string) *PermissionsPolicy {
+	return p.Feature("microphone", allowlist...)
+}
+
+// Midi sets the midi allowlist.
+func (p *PermissionsPolicy) Midi(allowlist ...string) *PermissionsPolicy {
+	return p.Feature("midi", allowlist...)
+}
+
+// Payment sets the payment allowlist.
+func (p *PermissionsPolicy) Payment(allowlist ...string) *PermissionsPolicy {
+	return p.Feature("payment", allowlist...)
+}
+
+// PictureInPicture sets the picture-in-picture allowlist.
+func (p *PermissionsPolicy) PictureInPicture(allowlist ...string) *PermissionsPolicy {
+	return p.Feature("picture-in-picture", allowlist...)
+}
+
+// PublicKeyCredentialsGet sets the publickey-credentials-get allowlist.
+func (p *PermissionsPolicy) PublicKeyCredentialsGet(allowlist ...string) *PermissionsPolicy {
+	return p.Feature("publickey-credentials-get", allowlist...)
+}
+
+// ScreenWakeLock sets the screen-wake-lock allowlist.
+func (p *PermissionsPolicy) ScreenWakeLock(allowlist ...string) *PermissionsPolicy {
+	return p.Feature("screen-wake-lock", allowlist...)
+}
+
+// USB sets the usb allowlist.
+func (p *PermissionsPolicy) USB(allowlist ...string) *PermissionsPolicy {
+	return p.Feature("usb", allowlist...)
+}
+
+// WebShare sets the web-share allowlist.
+func (p *PermissionsPolicy) WebShare(allowlist ...string) *PermissionsPolicy {
+	return p.Feature("web-share", allowlist...)
+}
+
+// XRSpatialTracking sets the xr-spatial-tracking allowlist.
+func (p *PermissionsPolicy) XRSpatialTracking(allowlist ...string) *PermissionsPolicy {
+	return p.Feature("xr-spatial-tracking", allowlist...)
+}
+
+// Build constructs the Permissions-Policy header value. Features are
+// sorted by name so the output is deterministic.
+func (p *PermissionsPolicy) Build() string {
+	names := make([]string, 0, len(p.features))
+	for feature := range p.features {
+		names = append(names, feature)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, feature := range names {
+		parts = append(parts, feature+"="+permissionsAllowlist(p.features[feature]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// permissionsAllowlist formats an allowlist as a structured header value:
+// "*" stays a bare token, "self" and "src" become tokens inside the inner
+// list and origins are quoted. "none" yields the empty list.
+func permissionsAllowlist(allowlist []string) string {
+	items := make([]string, 0, len(allowlist))
+	for _, entry := range allowlist {
+		entry = strings.Trim(strings.TrimSpace(entry), `'"`)
+		switch strings.ToLower(entry) {
+		case "", "none":
+			continue
+		case "*":
+			return "*"
+		case "self", "src":
+			items = append(items, strings.ToLower(entry))
+		default:
+			items = append(items, strconv.Quote(entry))
+		}
+	}
+	return "(" + strings.Join(items, " ") + ")"
+}
@@ -1,8 +1,10 @@
 package middleware
 
 import (
+	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/ginjigo/ginji"
 )
@@ -202,3 +204,180 @@ func contains(s, substr string) bool {
 		(s[:len(substr)] == substr || s[len(s)-len(substr):] == substr ||
 			strings.Contains(s, substr)))
 }
+
+func TestCSPBuilderDeterministic(t *testing.T) {
+	csp := NewCSP().
+		ReportURI("/csp-report").
+		ReportTo("csp-endpoint").
+		FrameAncestors("'none'").
+		Sandbox("allow-scripts", "allow-forms").
+		WorkerSrc("'self'", "blob:").
+		MediaSrc("https://media.example.com").
+		ManifestSrc("'self'").
+		DefaultSrc("'self'")
+
+	want := "default-src 'self'; frame-ancestors 'none'; manifest-src 'self'; " +
+		"media-src https://media.example.com; report-to csp-endpoint; report-uri /csp-report; " +
+		"sandbox allow-scripts allow-forms; worker-src 'self' blob:"
+	for range 10 {
+		if got := csp.Build(); got != want {
+			t.Fatalf("Expected %q, got %q", want, got)
+		}
+	}
+
+	if got := NewCSP().Sandbox().Build(); got != "sandbox" {
+		t.Errorf("Expected bare sandbox directive, got %q", got)
+	}
+}
+
+func TestSecureXFrameOptionsFromCSP(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		want   string
+	}{
+		{"none", NewCSP().DefaultSrc("'self'").FrameAncestors("'none'").Build(), "DENY"},
+		{"self", NewCSP().FrameAncestors("'self'").Build(), "SAMEORIGIN"},
+		{"origins", NewCSP().FrameAncestors("'self'", "https://partner.example.com").Build(), ""},
+		{"no directive", NewCSP().DefaultSrc("'self'").Build(), "SAMEORIGIN"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultSecureConfig()
+			config.ContentSecurityPolicy = tt.policy
+			config.XFrameOptionsFromCSP = true
+
+			app := ginji.New()
+			app.Use(SecureWithConfig(config))
+			app.Get("/", func(c *ginji.Context) error {
+				return c.Text(ginji.StatusOK, "ok")
+			})
+
+			w := ginji.PerformRequest(app, "GET", "/", nil)
+			if got := w.Header().Get("X-Frame-Options"); got != tt.want {
+				t.Errorf("Expected X-Frame-Options %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSecureReportingHeaders(t *testing.T) {
+	config := DefaultSecureConfig()
+	config.ReportingEndpoints = map[string]string{
+		"default":    "https://reports.example.com/default",
+		"csp-report": "https://reports.example.com/csp",
+	}
+	config.ReportTo = []ReportToGroup{{
+		Group:             "network-errors",
+		MaxAge:            24 * time.Hour,
+		Endpoints:         []string{"https://reports.example.com/nel"},
+		IncludeSubdomains: true,
+	}}
+	config.NEL = &NELPolicy{ReportTo: "network-errors", MaxAge: 24 * time.Hour}
+	config.ExpectCT = &ExpectCTPolicy{
+		MaxAge:    time.Hour,
+		Enforce:   true,
+		ReportURI: "https://reports.example.com/ct",
+	}
+
+	app := ginji.New()
+	app.Use(SecureWithConfig(config))
+	app.Get("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/", nil)
+
+	ginji.AssertHeader(t, w, "Reporting-Endpoints",
+		`csp-report="https://reports.example.com/csp", default="https://reports.example.com/default"`)
+	ginji.AssertHeader(t, w, "Report-To",
+		`{"group":"network-errors","max_age":86400,"endpoints":[{"url":"https://reports.example.com/nel"}],"include_subdomains":true}`)
+	ginji.AssertHeader(t, w, "NEL", `{"report_to":"network-errors","max_age":86400,"failure_fraction":1}`)
+	ginji.AssertHeader(t, w, "Expect-CT", `max-age=3600, enforce, report-uri="https://reports.example.com/ct"`)
+}
+
+func TestSecureReportingHeadersUnset(t *testing.T) {
+	app := ginji.New()
+	app.Use(Secure())
+	app.Get("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/", nil)
+
+	for _, header := range []string{"Reporting-Endpoints", "Report-To", "NEL", "Expect-CT"} {
+		if got := w.Header().Get(header); got != "" {
+			t.Errorf("Expected no %s header, got %q", header, got)
+		}
+	}
+}
+
+func TestPermissionsPolicyBuilder(t *testing.T) {
+	policy := NewPermissionsPolicy().
+		Geolocation("self", "https://maps.example.com").
+		Camera().
+		Microphone("none").
+		FullScreen("'self'").
+		Payment("*").
+		Feature("browsing-topics").
+		Build()
+
+	want := `browsing-topics=(), camera=(), fullscreen=(self), geolocation=(self "https://maps.example.com"), microphone=(), payment=*`
+	if policy != want {
+		t.Errorf("Expected %q, got %q", want, policy)
+	}
+}
+
+func TestPermissionsPolicyBuilderHeader(t *testing.T) {
+	config := DefaultSecureConfig()
+	config.PermissionsPolicy = NewPermissionsPolicy().Camera().USB().Build()
+
+	app := ginji.New()
+	app.Use(SecureWithConfig(config))
+	app.Get("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/", nil)
+	ginji.AssertHeader(t, w, "Permissions-Policy", "camera=(), usb=()")
+}
+
+func TestSecureCSPNonce(t *testing.T) {
+	app := ginji.New()
+	app.Use(SecureWithConfig(SecureConfig{
+		ContentSecurityPolicy: "default-src 'self'; script-src 'self'; img-src *",
+		CSPNonce:              true,
+	}))
+	app.Get("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, CSPNonce(c))
+	})
+
+	w1 := ginji.PerformRequest(app, "GET", "/", nil)
+	w2 := ginji.PerformRequest(app, "GET", "/", nil)
+	nonce := w1.Body.String()
+	if nonce == "" || nonce == w2.Body.String() {
+		t.Fatalf("Expected a fresh nonce per request, got %q and %q", nonce, w2.Body.String())
+	}
+	want := "default-src 'self' 'nonce-" + nonce + "'; script-src 'self' 'nonce-" + nonce + "'; img-src *"
+	ginji.AssertHeader(t, w1, "Content-Security-Policy", want)
+}
+
+func TestSecureSkipAllocs(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	mw := SecureWithConfig(SecureConfig{
+		XSSProtection: "1; mode=block",
+		SkipFunc:      func(c *ginji.Context) bool { return true },
+	})
+	if n := middlewareAllocs(t, req, mw); n > 0 {
+		t.Errorf("Expected no allocations when skipped, got %v", n)
+	}
+}
+
+func BenchmarkSecure(b *testing.B) {
+	benchmarkMiddleware(b, httptest.NewRequest("GET", "/", nil), Secure())
+}
+
+func BenchmarkSecureStrict(b *testing.B) {
+	benchmarkMiddleware(b, httptest.NewRequest("GET", "/", nil), SecureStrict())
+}
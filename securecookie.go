@@ -0,0 +1,301 @@
+package middleware
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+var (
+	// ErrCookieInvalid is returned when a cookie fails signature or decryption checks.
+	ErrCookieInvalid = errors.New("securecookie: invalid cookie")
+
+	// ErrCookieExpired is returned when a cookie is older than MaxAge.
+	ErrCookieExpired = errors.New("securecookie: cookie expired")
+)
+
+// SecureCookieConfig defines the configuration for signed and encrypted cookies.
+type SecureCookieConfig struct {
+	// HashKeys are HMAC-SHA256 keys. The first key signs new cookies and
+	// every key is tried when verifying, which allows key rotation.
+	// At least one key of 32 bytes or more is required.
+	HashKeys [][]byte
+
+	// BlockKeys are AES keys (16, 24 or 32 bytes). When set, cookie values
+	// are encrypted with AES-GCM using the first key; every key is tried
+	// when decrypting.
+	BlockKeys [][]byte
+
+	// HashKeyProvider supplies the hash keys instead of HashKeys, e.g.
+	// from a file that is reloaded on rotation.
+	HashKeyProvider KeyProvider
+
+	// BlockKeyProvider supplies the block keys instead of BlockKeys.
+	BlockKeyProvider KeyProvider
+
+	// MaxAge rejects cookies whose embedded timestamp is older than this.
+	// Default: 0 (no limit beyond the cookie's own expiry)
+	MaxAge time.Duration
+
+	// ContextKey is the key used to store the codec in context.
+	// Default: "securecookie"
+	ContextKey string
+}
+
+// SecureCookie signs, and optionally encrypts, cookie values.
+type SecureCookie struct {
+	hashKeys  KeyProvider
+	blockKeys KeyProvider   // Set when block keys come from a provider
+	blocks    []cipher.AEAD // Ciphers of static block keys
+	maxAge    time.Duration
+}
+
+// NewSecureCookie creates a cookie codec from the given configuration.
+func NewSecureCookie(config SecureCookieConfig) (*SecureCookie, error) {
+	hashKeys := config.HashKeyProvider
+	if hashKeys == nil {
+		if len(config.HashKeys) == 0 {
+			return nil, errors.New("securecookie: at least one hash key is required")
+		}
+		hashKeys = staticKeyValues(config.HashKeys)
+	}
+	if _, err := loadKeys(hashKeys, 32); err != nil {
+		return nil, fmt.Errorf("securecookie: %w", err)
+	}
+
+	sc := &SecureCookie{
+		hashKeys:  hashKeys,
+		blockKeys: config.BlockKeyProvider,
+		maxAge:    config.MaxAge,
+	}
+
+	if sc.blockKeys != nil {
+		if _, err := sc.ciphers(); err != nil {
+			return nil, err
+		}
+		return sc, nil
+	}
+	blocks := make([]Key, len(config.BlockKeys))
+	for i, key := range config.BlockKeys {
+		blocks[i] = Key{Value: key}
+	}
+	var err error
+	if sc.blocks, err = newCookieCiphers(blocks); err != nil {
+		return nil, err
+	}
+	return sc, nil
+}
+
+// ciphers returns the AES-GCM ciphers of the block keys, the encrypting
+// one first, or none if cookies are only signed.
+func (sc *SecureCookie) ciphers() ([]cipher.AEAD, error) {
+	if sc.blockKeys == nil {
+		return sc.blocks, nil
+	}
+	keys, err := loadKeys(sc.blockKeys, 0)
+	if err != nil {
+		return nil, fmt.Errorf("securecookie: %w", err)
+	}
+	return newCookieCiphers(keys)
+}
+
+// newCookieCiphers creates AES-GCM ciphers for keys.
+func newCookieCiphers(keys []Key) ([]cipher.AEAD, error) {
+	blocks := make([]cipher.AEAD, 0, len(keys))
+	for _, key := range keys {
+		block, err := aes.NewCipher(key.Value)
+		if err != nil {
+			return nil, fmt.Errorf("securecookie: invalid block key: %w", err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("securecookie: %w", err)
+		}
+		blocks = append(blocks, aead)
+	}
+	return blocks, nil
+}
+
+// Encode signs (and encrypts, if block keys are configured) a cookie value.
+// The cookie name is bound into the signature so values cannot be swapped
+// between cookies.
+func (sc *SecureCookie) Encode(name, value string) (string, error) {
+	data := []byte(value)
+
+	hashKeys, err := loadKeys(sc.hashKeys, 32)
+	if err != nil {
+		return "", fmt.Errorf("securecookie: %w", err)
+	}
+	blocks, err := sc.ciphers()
+	if err != nil {
+		return "", err
+	}
+
+	if len(blocks) > 0 {
+		aead := blocks[0]
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return "", fmt.Errorf("securecookie: %w", err)
+		}
+		data = aead.Seal(nonce, nonce, data, []byte(name))
+	}
+
+	payload := strconv.FormatInt(time.Now().Unix(), 10) + "|" + base64.RawURLEncoding.EncodeToString(data)
+	mac := cookieMAC(hashKeys[0].Value, name, payload)
+
+	return base64.RawURLEncoding.EncodeToString([]byte(payload + "|" + base64.RawURLEncoding.EncodeToString(mac))), nil
+}
+
+// Decode verifies (and decrypts, if block keys are configured) a cookie value.
+func (sc *SecureCookie) Decode(name, encoded string) (string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", ErrCookieInvalid
+	}
+
+	parts := strings.SplitN(string(raw), "|", 3)
+	if len(parts) != 3 {
+		return "", ErrCookieInvalid
+	}
+	payload := parts[0] + "|" + parts[1]
+
+	mac, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", ErrCookieInvalid
+	}
+
+	hashKeys, err := loadKeys(sc.hashKeys, 32)
+	if err != nil {
+		return "", fmt.Errorf("securecookie: %w", err)
+	}
+	verified := false
+	for _, key := range hashKeys {
+		if hmac.Equal(mac, cookieMAC(key.Value, name, payload)) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return "", ErrCookieInvalid
+	}
+
+	timestamp, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return "", ErrCookieInvalid
+	}
+	if sc.maxAge > 0 && time.Since(time.Unix(timestamp, 0)) > sc.maxAge {
+		return "", ErrCookieExpired
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", ErrCookieInvalid
+	}
+
+	blocks, err := sc.ciphers()
+	if err != nil {
+		return "", err
+	}
+	if len(blocks) == 0 {
+		return string(data), nil
+	}
+
+	for _, aead := range blocks {
+		if len(data) < aead.NonceSize() {
+			continue
+		}
+		nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+		if plain, err := aead.Open(nil, nonce, ciphertext, []byte(name)); err == nil {
+			return string(plain), nil
+		}
+	}
+
+	return "", ErrCookieInvalid
+}
+
+// SetCookie encodes the cookie value and adds the cookie to the response.
+func (sc *SecureCookie) SetCookie(c *ginji.Context, cookie *http.Cookie) error {
+	encoded, err := sc.Encode(cookie.Name, cookie.Value)
+	if err != nil {
+		return err
+	}
+	out := *cookie
+	out.Value = encoded
+	http.SetCookie(c.Res, &out)
+	return nil
+}
+
+// GetCookie reads and decodes a cookie from the request.
+func (sc *SecureCookie) GetCookie(c *ginji.Context, name string) (string, error) {
+	cookie, err := c.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	return sc.Decode(name, cookie.Value)
+}
+
+// cookieMAC computes the HMAC of a cookie payload bound to its name.
+func cookieMAC(key []byte, name, payload string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(name))
+	h.Write([]byte("|"))
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
+
+// SecureCookieWithConfig returns middleware that makes a cookie codec
+// available to handlers through SetSecureCookie and GetSecureCookie.
+// It panics if the keys are invalid.
+func SecureCookieWithConfig(config SecureCookieConfig) ginji.Middleware {
+	if config.ContextKey == "" {
+		config.ContextKey = "securecookie"
+	}
+
+	sc, err := NewSecureCookie(config)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	return func(c *ginji.Context) error {
+		c.Set(config.ContextKey, sc)
+		setContextValue(c, secureCookieContextKey, sc)
+		return c.Next()
+	}
+}
+
+// SetSecureCookie signs and sets a cookie using the codec installed by
+// SecureCookieWithConfig.
+func SetSecureCookie(c *ginji.Context, cookie *http.Cookie) error {
+	sc, ok := secureCookieFromContext(c)
+	if !ok {
+		return errors.New("securecookie: middleware not installed")
+	}
+	return sc.SetCookie(c, cookie)
+}
+
+// GetSecureCookie reads and verifies a cookie using the codec installed by
+// SecureCookieWithConfig.
+func GetSecureCookie(c *ginji.Context, name string) (string, error) {
+	sc, ok := secureCookieFromContext(c)
+	if !ok {
+		return "", errors.New("securecookie: middleware not installed")
+	}
+	return sc.GetCookie(c, name)
+}
+
+// secureCookieFromContext returns the codec stored in context.
+func secureCookieFromContext(c *ginji.Context) (*SecureCookie, bool) {
+	sc, ok := c.Req.Context().Value(secureCookieContextKey).(*SecureCookie)
+	return sc, ok
+}
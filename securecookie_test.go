@@ -0,0 +1,178 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+var (
+	testHashKey  = bytes.Repeat([]byte("h"), 32)
+	testHashKey2 = bytes.Repeat([]byte("k"), 32)
+	testBlockKey = bytes.Repeat([]byte("b"), 32)
+)
+
+func TestSecureCookieRoundTrip(t *testing.T) {
+	sc, err := NewSecureCookie(SecureCookieConfig{
+		HashKeys:  [][]byte{testHashKey},
+		BlockKeys: [][]byte{testBlockKey},
+	})
+	if err != nil {
+		t.Fatalf("NewSecureCookie failed: %v", err)
+	}
+
+	encoded, err := sc.Encode("session", "user-42")
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if bytes.Contains([]byte(encoded), []byte("user-42")) {
+		t.Error("Expected encrypted value not to contain plaintext")
+	}
+
+	value, err := sc.Decode("session", encoded)
+	if err != nil || value != "user-42" {
+		t.Errorf("Expected user-42, got %q (%v)", value, err)
+	}
+
+	// Values are bound to the cookie name
+	if _, err := sc.Decode("other", encoded); err != ErrCookieInvalid {
+		t.Errorf("Expected ErrCookieInvalid for wrong name, got %v", err)
+	}
+}
+
+func TestSecureCookieTampered(t *testing.T) {
+	sc, _ := NewSecureCookie(SecureCookieConfig{HashKeys: [][]byte{testHashKey}})
+
+	encoded, _ := sc.Encode("prefs", "dark")
+	tampered := []byte(encoded)
+	tampered[len(tampered)/2] ^= 0x01
+
+	if _, err := sc.Decode("prefs", string(tampered)); err == nil {
+		t.Error("Expected tampered cookie to be rejected")
+	}
+}
+
+func TestSecureCookieKeyRotation(t *testing.T) {
+	old, _ := NewSecureCookie(SecureCookieConfig{HashKeys: [][]byte{testHashKey}})
+	rotated, _ := NewSecureCookie(SecureCookieConfig{HashKeys: [][]byte{testHashKey2, testHashKey}})
+
+	encoded, _ := old.Encode("id", "abc")
+	if value, err := rotated.Decode("id", encoded); err != nil || value != "abc" {
+		t.Errorf("Expected old cookie to verify after rotation, got %q (%v)", value, err)
+	}
+
+	fresh, _ := rotated.Encode("id", "abc")
+	if _, err := old.Decode("id", fresh); err == nil {
+		t.Error("Expected cookie signed with new key to fail on old codec")
+	}
+}
+
+func TestSecureCookieMaxAge(t *testing.T) {
+	sc, _ := NewSecureCookie(SecureCookieConfig{
+		HashKeys: [][]byte{testHashKey},
+		MaxAge:   time.Nanosecond,
+	})
+
+	encoded, _ := sc.Encode("id", "abc")
+	time.Sleep(time.Millisecond)
+	if _, err := sc.Decode("id", encoded); err != ErrCookieExpired {
+		t.Errorf("Expected ErrCookieExpired, got %v", err)
+	}
+}
+
+func TestSecureCookieInvalidKeys(t *testing.T) {
+	if _, err := NewSecureCookie(SecureCookieConfig{HashKeys: [][]byte{[]byte("short")}}); err == nil {
+		t.Error("Expected short hash key to be rejected")
+	}
+	if _, err := NewSecureCookie(SecureCookieConfig{
+		HashKeys:  [][]byte{testHashKey},
+		BlockKeys: [][]byte{[]byte("bad")},
+	}); err == nil {
+		t.Error("Expected invalid block key to be rejected")
+	}
+}
+
+func TestSecureCookieMiddleware(t *testing.T) {
+	app := ginji.New()
+	app.Use(SecureCookieWithConfig(SecureCookieConfig{HashKeys: [][]byte{testHashKey}, ContextKey: "cookies"}))
+
+	app.Get("/set", func(c *ginji.Context) error {
+		if err := SetSecureCookie(c, &http.Cookie{Name: "theme", Value: "dark", Path: "/"}); err != nil {
+			return err
+		}
+		return c.Text(ginji.StatusOK, "set")
+	})
+	app.Get("/get", func(c *ginji.Context) error {
+		value, err := GetSecureCookie(c, "theme")
+		if err != nil {
+			return c.Text(ginji.StatusBadRequest, err.Error())
+		}
+		return c.Text(ginji.StatusOK, value)
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/set", nil)
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Value == "dark" {
+		t.Fatalf("Expected one signed cookie, got %v", cookies)
+	}
+
+	req := httptest.NewRequest("GET", "/get", nil)
+	req.AddCookie(cookies[0])
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	ginji.AssertBody(t, w, "dark")
+
+	req = httptest.NewRequest("GET", "/get", nil)
+	req.AddCookie(&http.Cookie{Name: "theme", Value: "dark"})
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != ginji.StatusBadRequest {
+		t.Errorf("Expected unsigned cookie to be rejected, got %d", w.Code)
+	}
+}
+
+func TestCSRFWithSecureCookie(t *testing.T) {
+	sc, _ := NewSecureCookie(SecureCookieConfig{HashKeys: [][]byte{testHashKey}})
+
+	config := DefaultCSRFConfig()
+	config.SecureCookie = sc
+
+	app := ginji.New()
+	app.Use(CSRFWithConfig(config))
+	app.Get("/form", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, CSRFToken(c))
+	})
+	app.Post("/submit", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/form", nil)
+	token := w.Body.String()
+	cookie := w.Result().Cookies()[0]
+	if cookie.Value == token {
+		t.Fatal("Expected cookie value to be signed, not the raw token")
+	}
+
+	req := httptest.NewRequest("POST", "/submit", nil)
+	req.AddCookie(cookie)
+	req.Header.Set("X-CSRF-Token", token)
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != ginji.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+
+	// A forged unsigned cookie must not validate
+	req = httptest.NewRequest("POST", "/submit", nil)
+	req.AddCookie(&http.Cookie{Name: "_csrf", Value: "forged"})
+	req.Header.Set("X-CSRF-Token", "forged")
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != ginji.StatusForbidden {
+		t.Errorf("Expected status 403 for forged cookie, got %d", w.Code)
+	}
+}
@@ -0,0 +1,176 @@
+package middleware
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/ginjigo/ginji"
+)
+
+// redacted replaces sensitive values in every rendering.
+const redacted = "[REDACTED]"
+
+// ErrSealedInvalid is returned when a sealed value cannot be opened.
+var ErrSealedInvalid = errors.New("sensitive: invalid sealed value")
+
+// Sensitive holds a value, such as PII or a token, that must not leak into
+// logs, recordings or audit trails. It renders as "[REDACTED]" with fmt,
+// slog (so in Logger, ErrorHandler and audit sinks), encoding/json and
+// encoding.TextMarshaler, so a stray log line or a response cached with
+// the request state only ever shows the placeholder. Value returns the
+// value itself; Seal encrypts it for storage.
+type Sensitive[T any] struct {
+	value T
+}
+
+// NewSensitive wraps value.
+func NewSensitive[T any](value T) Sensitive[T] {
+	return Sensitive[T]{value: value}
+}
+
+// Value returns the wrapped value.
+func (s Sensitive[T]) Value() T {
+	return s.value
+}
+
+// String implements fmt.Stringer.
+func (s Sensitive[T]) String() string {
+	return redacted
+}
+
+// GoString implements fmt.GoStringer.
+func (s Sensitive[T]) GoString() string {
+	return redacted
+}
+
+// Format implements fmt.Formatter, redacting every verb.
+func (s Sensitive[T]) Format(f fmt.State, verb rune) {
+	if verb == 'q' {
+		fmt.Fprintf(f, "%q", redacted)
+		return
+	}
+	_, _ = f.Write([]byte(redacted))
+}
+
+// LogValue implements slog.LogValuer.
+func (s Sensitive[T]) LogValue() slog.Value {
+	return slog.StringValue(redacted)
+}
+
+// MarshalJSON implements json.Marshaler.
+func (s Sensitive[T]) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + redacted + `"`), nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (s Sensitive[T]) MarshalText() ([]byte, error) {
+	return []byte(redacted), nil
+}
+
+// Seal encrypts the JSON encoding of the value with AES-GCM under the
+// first key of keys, which must be 16, 24 or 32 bytes, for stores that
+// persist request state. The result names the key if it has an ID, so
+// OpenSensitive keeps working after rotation.
+func (s Sensitive[T]) Seal(keys KeyProvider) (string, error) {
+	plain, err := json.Marshal(s.value)
+	if err != nil {
+		return "", fmt.Errorf("sensitive: %w", err)
+	}
+	all, err := loadKeys(keys, 16)
+	if err != nil {
+		return "", fmt.Errorf("sensitive: %w", err)
+	}
+	aead, err := newSensitiveAEAD(all[0].Value)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("sensitive: %w", err)
+	}
+	sealed := base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, plain, nil))
+	if all[0].ID != "" {
+		sealed = all[0].ID + "." + sealed
+	}
+	return sealed, nil
+}
+
+// OpenSensitive decrypts a value sealed with Seal under one of keys.
+func OpenSensitive[T any](keys KeyProvider, sealed string) (Sensitive[T], error) {
+	var s Sensitive[T]
+	all, err := loadKeys(keys, 16)
+	if err != nil {
+		return s, fmt.Errorf("sensitive: %w", err)
+	}
+	if kid, data, ok := strings.Cut(sealed, "."); ok {
+		key, found := findKey(all, kid)
+		if !found {
+			return s, ErrSealedInvalid
+		}
+		all, sealed = []Key{key}, data
+	}
+	data, err := base64.RawURLEncoding.DecodeString(sealed)
+	if err != nil {
+		return s, ErrSealedInvalid
+	}
+
+	for _, key := range all {
+		aead, err := newSensitiveAEAD(key.Value)
+		if err != nil {
+			return s, err
+		}
+		if len(data) < aead.NonceSize() {
+			return s, ErrSealedInvalid
+		}
+		plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+		if err != nil {
+			continue
+		}
+		if err := json.Unmarshal(plain, &s.value); err != nil {
+			return s, fmt.Errorf("sensitive: %w", err)
+		}
+		return s, nil
+	}
+	return s, ErrSealedInvalid
+}
+
+// newSensitiveAEAD creates the AES-GCM cipher of key.
+func newSensitiveAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("sensitive: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("sensitive: %w", err)
+	}
+	return aead, nil
+}
+
+// SetSensitive stores value in context wrapped in Sensitive, so that code
+// dumping context values cannot leak it:
+//
+//	middleware.SetSensitive(c, "ssn", form.SSN)
+//	ssn, _ := middleware.GetSensitive[string](c, "ssn")
+func SetSensitive[T any](c *ginji.Context, key string, value T) {
+	c.Set(key, NewSensitive(value))
+}
+
+// GetSensitive returns the value stored with SetSensitive.
+func GetSensitive[T any](c *ginji.Context, key string) (T, bool) {
+	val, exists := c.Get(key)
+	if !exists {
+		var zero T
+		return zero, false
+	}
+	s, ok := val.(Sensitive[T])
+	return s.value, ok
+}
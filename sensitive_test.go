@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/ginjigo/ginji"
+)
+
+func TestSensitiveRedacts(t *testing.T) {
+	token := NewSensitive("tok_live_123")
+	if token.Value() != "tok_live_123" {
+		t.Fatalf("Expected the wrapped value, got %q", token.Value())
+	}
+
+	for _, format := range []string{"%v", "%+v", "%#v", "%s", "%q", "%x", "%d"} {
+		if got := fmt.Sprintf(format, token); strings.Contains(got, "tok_live") {
+			t.Errorf("%s leaked the value: %s", format, got)
+		}
+	}
+
+	payload, err := json.Marshal(struct {
+		Token Sensitive[string]         `json:"token"`
+		Card  map[string]Sensitive[int] `json:"card"`
+	}{token, map[string]Sensitive[int]{"number": NewSensitive(4242)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"token":"[REDACTED]","card":{"number":"[REDACTED]"}}`; string(payload) != want {
+		t.Errorf("Expected %s, got %s", want, payload)
+	}
+
+	var buf bytes.Buffer
+	slog.New(slog.NewJSONHandler(&buf, nil)).Info("Charge", "token", token, slog.Any("ssn", NewSensitive("078-05-1120")))
+	if strings.Contains(buf.String(), "tok_live") || strings.Contains(buf.String(), "078-05") {
+		t.Errorf("Expected log attributes to be redacted, got %s", buf.String())
+	}
+}
+
+func TestSensitiveContext(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	app := ginji.New()
+	app.Use(LoggerWithConfig(LoggerConfig{Logger: logger}))
+	app.Get("/", func(c *ginji.Context) error {
+		SetSensitive(c, "email", "alice@example.com")
+		email, ok := GetSensitive[string](c, "email")
+		if !ok || email != "alice@example.com" {
+			t.Errorf("Expected the value back, got %q", email)
+		}
+		if _, ok := GetSensitive[int](c, "email"); ok {
+			t.Error("Expected a type mismatch to fail")
+		}
+		if _, ok := GetSensitive[string](c, "missing"); ok {
+			t.Error("Expected a missing key to fail")
+		}
+		raw, _ := c.Get("email")
+		logger.Info("Handling", "email", raw)
+		return c.Text(ginji.StatusOK, "ok")
+	})
+	ginji.PerformRequest(app, "GET", "/", nil)
+	if !strings.Contains(buf.String(), `"email":"[REDACTED]"`) || strings.Contains(buf.String(), "alice") {
+		t.Errorf("Expected no PII in the logs, got %s", buf.String())
+	}
+}
+
+func TestSensitiveSeal(t *testing.T) {
+	oldKey := Key{ID: "k1", Value: bytes.Repeat([]byte("1"), 32)}
+	newKey := Key{ID: "k2", Value: bytes.Repeat([]byte("2"), 32)}
+
+	type card struct {
+		Number string
+		CVC    int
+	}
+	sealed, err := NewSensitive(card{"4242424242424242", 123}).Seal(StaticKeys(oldKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(sealed, "k1.") || strings.Contains(sealed, "4242") {
+		t.Errorf("Unexpected sealed value %s", sealed)
+	}
+
+	// Still opens after rotating in a new key
+	opened, err := OpenSensitive[card](StaticKeys(newKey, oldKey), sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened.Value().Number != "4242424242424242" || opened.Value().CVC != 123 {
+		t.Errorf("Unexpected value %+v", opened.Value())
+	}
+
+	if _, err := OpenSensitive[card](StaticKeys(newKey), sealed); !errors.Is(err, ErrSealedInvalid) {
+		t.Errorf("Expected ErrSealedInvalid without the key, got %v", err)
+	}
+	tampered := sealed[:len(sealed)-2] + "AA"
+	if _, err := OpenSensitive[card](StaticKeys(oldKey), tampered); !errors.Is(err, ErrSealedInvalid) {
+		t.Errorf("Expected ErrSealedInvalid when tampered, got %v", err)
+	}
+
+	// Keys without IDs are all tried
+	anonymous := Key{Value: oldKey.Value}
+	sealed, _ = NewSensitive("secret").Seal(StaticKeys(anonymous))
+	if s, err := OpenSensitive[string](StaticKeys(Key{Value: newKey.Value}, anonymous), sealed); err != nil || s.Value() != "secret" {
+		t.Errorf("Expected the second key to open it, got %q, %v", s.Value(), err)
+	}
+}
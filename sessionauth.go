@@ -0,0 +1,516 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// ErrNoSession is returned, e.g. by SessionAuth's Login and Logout, when no
+// session middleware has stored a session in the context.
+var ErrNoSession = errors.New("sessionauth: no session in context")
+
+// SessionAuthConfig defines the configuration for session authentication.
+//
+// SessionAuth does not load sessions itself. It needs session middleware,
+// registered before it, that calls SetSession for every request; requests
+// without a session are treated as unauthenticated.
+type SessionAuthConfig struct {
+	// LoadUser loads the user with the ID stored in the session. It returns
+	// a nil user when the user no longer exists, which logs the session
+	// out; errors are returned from the middleware. Required.
+	LoadUser func(c *ginji.Context, userID string) (any, error)
+
+	// UserIDKey is the session key holding the user ID. The login and last
+	// activity times are stored next to it.
+	// Default: "auth_user_id"
+	UserIDKey string
+
+	// IdleTimeout logs the session out after this long without requests.
+	// Negative disables it.
+	// Default: 30 minutes
+	IdleTimeout time.Duration
+
+	// AbsoluteTimeout logs the session out this long after login,
+	// regardless of activity. Negative disables it.
+	// Default: 24 hours
+	AbsoluteTimeout time.Duration
+
+	// RememberCookie signs the remember-me cookie. Remember-me is disabled
+	// when nil.
+	RememberCookie *SecureCookie
+
+	// RememberStore records issued remember-me tokens so that they can be
+	// revoked. Without it a token stays valid until it expires.
+	RememberStore RememberTokenStore
+
+	// RememberCookieName is the name of the remember-me cookie.
+	// Default: "remember_me"
+	RememberCookieName string
+
+	// RememberMaxAge is how long a remember-me token is valid.
+	// Default: 30 days
+	RememberMaxAge time.Duration
+
+	// CookieSecure sets the Secure flag on the remember-me cookie.
+	// Default: false
+	CookieSecure bool
+
+	// Optional lets unauthenticated requests through without a user.
+	// Default: false
+	Optional bool
+
+	// Unauthorized is called when authentication fails.
+	// If nil, a default 401 response is sent.
+	Unauthorized func(*ginji.Context)
+
+	// ContextKey is the key used to store the authenticated user in context.
+	// Default: "user"
+	ContextKey string
+
+	// SkipFunc allows skipping authentication for certain requests.
+	SkipFunc Skipper
+}
+
+// RememberTokenStore persists remember-me tokens. Implementations must be
+// safe for concurrent use.
+type RememberTokenStore interface {
+	// Save records a token issued to a user.
+	Save(userID, token string, expires time.Time) error
+
+	// Valid reports whether the token was issued to the user and has not
+	// been deleted.
+	Valid(userID, token string) bool
+
+	// Delete revokes a token.
+	Delete(userID, token string) error
+}
+
+// RememberToken is a remember-me token listed by RememberTokenUserStore.
+// The token itself is left out of its JSON encoding.
+type RememberToken struct {
+	Token   string    `json:"-"`
+	Expires time.Time `json:"expires"`
+}
+
+// RememberTokenUserStore is a RememberTokenStore that can list and revoke
+// the tokens of a user, which SessionAuth needs to serve data subject
+// requests.
+type RememberTokenUserStore interface {
+	RememberTokenStore
+
+	// UserTokens returns the unexpired tokens issued to a user.
+	UserTokens(userID string) ([]RememberToken, error)
+
+	// DeleteUser revokes every token issued to a user and returns how
+	// many were revoked.
+	DeleteUser(userID string) (int, error)
+}
+
+// memoryRememberTokenStore is an in-memory RememberTokenUserStore.
+type memoryRememberTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]map[string]time.Time
+}
+
+// NewMemoryRememberTokenStore creates an in-memory remember-me token store,
+// for single-instance deployments and tests. Tokens are lost on restart.
+func NewMemoryRememberTokenStore() RememberTokenUserStore {
+	return &memoryRememberTokenStore{tokens: make(map[string]map[string]time.Time)}
+}
+
+func (s *memoryRememberTokenStore) Save(userID, token string, expires time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user := s.tokens[userID]
+	if user == nil {
+		user = make(map[string]time.Time)
+		s.tokens[userID] = user
+	}
+	// Drop the user's expired tokens so that the map does not grow forever
+	now := time.Now()
+	for t, exp := range user {
+		if now.After(exp) {
+			delete(user, t)
+		}
+	}
+	user[token] = expires
+	return nil
+}
+
+func (s *memoryRememberTokenStore) Valid(userID, token string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expires, ok := s.tokens[userID][token]
+	return ok && time.Now().Before(expires)
+}
+
+func (s *memoryRememberTokenStore) Delete(userID, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tokens[userID], token)
+	if len(s.tokens[userID]) == 0 {
+		delete(s.tokens, userID)
+	}
+	return nil
+}
+
+func (s *memoryRememberTokenStore) UserTokens(userID string) ([]RememberToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	var tokens []RememberToken
+	for token, expires := range s.tokens[userID] {
+		if now.Before(expires) {
+			tokens = append(tokens, RememberToken{Token: token, Expires: expires})
+		}
+	}
+	slices.SortFunc(tokens, func(a, b RememberToken) int { return a.Expires.Compare(b.Expires) })
+	return tokens, nil
+}
+
+func (s *memoryRememberTokenStore) DeleteUser(userID string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.tokens[userID])
+	delete(s.tokens, userID)
+	return n, nil
+}
+
+// sessionRegenerator is implemented by sessions that can change their ID,
+// which Login and Logout use to prevent session fixation.
+type sessionRegenerator interface {
+	Regenerate() error
+}
+
+// DefaultSessionAuthConfig returns default session authentication configuration.
+func DefaultSessionAuthConfig() SessionAuthConfig {
+	return SessionAuthConfig{
+		UserIDKey:          "auth_user_id",
+		IdleTimeout:        30 * time.Minute,
+		AbsoluteTimeout:    24 * time.Hour,
+		RememberCookieName: "remember_me",
+		RememberMaxAge:     30 * 24 * time.Hour,
+		ContextKey:         "user",
+	}
+}
+
+// SessionAuth authenticates requests through the session stored by a
+// session middleware. The user ID is kept in the session by Login and the
+// user is loaded on every request, so RequireRole and UserFrom work as with
+// the other authentication middleware.
+//
+//	auth := middleware.NewSessionAuth(middleware.SessionAuthConfig{LoadUser: loadUser})
+//	app.Use(sessions) // Calls middleware.SetSession
+//	app.Use(auth.Middleware())
+//	app.Post("/login", func(c *ginji.Context) error {
+//		// check credentials, then:
+//		return auth.Login(c, userID, c.FormValue("remember") == "on")
+//	})
+type SessionAuth struct {
+	config SessionAuthConfig
+}
+
+// NewSessionAuth creates session authentication with the given
+// configuration. It panics if LoadUser is nil.
+func NewSessionAuth(config SessionAuthConfig) *SessionAuth {
+	defaults := DefaultSessionAuthConfig()
+	if config.LoadUser == nil {
+		panic("SessionAuth: LoadUser is required")
+	}
+	if config.UserIDKey == "" {
+		config.UserIDKey = defaults.UserIDKey
+	}
+	if config.IdleTimeout == 0 {
+		config.IdleTimeout = defaults.IdleTimeout
+	}
+	if config.AbsoluteTimeout == 0 {
+		config.AbsoluteTimeout = defaults.AbsoluteTimeout
+	}
+	if config.RememberCookieName == "" {
+		config.RememberCookieName = defaults.RememberCookieName
+	}
+	if config.RememberMaxAge == 0 {
+		config.RememberMaxAge = defaults.RememberMaxAge
+	}
+	if config.ContextKey == "" {
+		config.ContextKey = defaults.ContextKey
+	}
+	return &SessionAuth{config: config}
+}
+
+// Middleware returns middleware that loads the logged-in user, falling back
+// to the remember-me cookie when the session has none.
+func (a *SessionAuth) Middleware() ginji.Middleware {
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if a.config.SkipFunc != nil && a.config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		session, ok := SessionFrom(c)
+		if !ok {
+			return a.unauthenticated(c)
+		}
+
+		now := time.Now()
+		userID := a.sessionUser(session, now)
+		if userID == "" {
+			if id, ok := a.remembered(c); ok {
+				if err := a.login(session, id, now); err != nil {
+					return err
+				}
+				userID = id
+			}
+		}
+
+		if userID != "" {
+			user, err := a.config.LoadUser(c, userID)
+			if err != nil {
+				return err
+			}
+			if user != nil {
+				session.Set(a.config.UserIDKey+".seen_at", now.Unix())
+				c.Set(a.config.ContextKey, user)
+				SetUser(c, user)
+				return c.Next()
+			}
+			a.clear(session)
+		}
+
+		return a.unauthenticated(c)
+	}
+}
+
+// unauthenticated lets an optional request through or rejects it.
+func (a *SessionAuth) unauthenticated(c *ginji.Context) error {
+	if a.config.Optional {
+		return c.Next()
+	}
+	if a.config.Unauthorized != nil {
+		a.config.Unauthorized(c)
+		c.Abort()
+		return nil
+	}
+	c.AbortWithStatusJSON(ginji.StatusUnauthorized, ginji.H{
+		"error": "Authentication required",
+	})
+	return nil
+}
+
+// Login stores userID in the session, renewing its ID when the session
+// supports it. With remember set and RememberCookie configured, it also
+// issues a remember-me cookie.
+func (a *SessionAuth) Login(c *ginji.Context, userID string, remember bool) error {
+	session, ok := SessionFrom(c)
+	if !ok {
+		return ErrNoSession
+	}
+	if err := a.login(session, userID, time.Now()); err != nil {
+		return err
+	}
+	if remember && a.config.RememberCookie != nil {
+		return a.remember(c, userID)
+	}
+	return nil
+}
+
+// Logout removes the user from the session, renews its ID when the session
+// supports it, and revokes the remember-me cookie.
+func (a *SessionAuth) Logout(c *ginji.Context) error {
+	session, ok := SessionFrom(c)
+	if !ok {
+		return ErrNoSession
+	}
+	a.clear(session)
+	if regenerator, ok := session.(sessionRegenerator); ok {
+		if err := regenerator.Regenerate(); err != nil {
+			return err
+		}
+	}
+
+	if a.config.RememberCookie == nil {
+		return nil
+	}
+	if value, err := a.config.RememberCookie.GetCookie(c, a.config.RememberCookieName); err == nil && a.config.RememberStore != nil {
+		if userID, token, _, ok := parseRememberValue(value); ok {
+			if err := a.config.RememberStore.Delete(userID, token); err != nil {
+				return err
+			}
+		}
+	}
+	http.SetCookie(c.Res, &http.Cookie{
+		Name:     a.config.RememberCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		Secure:   a.config.CookieSecure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// ExportUser implements UserDataStore, listing the remember-me tokens of
+// userID without the tokens themselves. It returns ErrUserDataUnsupported
+// if RememberStore is not a RememberTokenUserStore.
+func (a *SessionAuth) ExportUser(_ context.Context, userID string) ([]any, error) {
+	if a.config.RememberStore == nil {
+		return nil, nil
+	}
+	store, ok := a.config.RememberStore.(RememberTokenUserStore)
+	if !ok {
+		return nil, ErrUserDataUnsupported
+	}
+	tokens, err := store.UserTokens(userID)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]any, len(tokens))
+	for i, token := range tokens {
+		records[i] = token
+	}
+	return records, nil
+}
+
+// PurgeUser implements UserDataStore, revoking every remember-me token of
+// userID. Sessions live in the session middleware's store; they end on the
+// next request once LoadUser no longer finds the deleted user. It returns
+// ErrUserDataUnsupported if RememberStore is not a RememberTokenUserStore.
+func (a *SessionAuth) PurgeUser(_ context.Context, userID string) (int, error) {
+	if a.config.RememberStore == nil {
+		return 0, nil
+	}
+	store, ok := a.config.RememberStore.(RememberTokenUserStore)
+	if !ok {
+		return 0, ErrUserDataUnsupported
+	}
+	return store.DeleteUser(userID)
+}
+
+// sessionUser returns the user ID in the session, or "" if there is none
+// or the session timed out.
+func (a *SessionAuth) sessionUser(session Session, now time.Time) string {
+	userID, _ := session.Get(a.config.UserIDKey).(string)
+	if userID == "" {
+		return ""
+	}
+
+	loginAt, _ := sessionTime(session.Get(a.config.UserIDKey + ".login_at"))
+	seenAt, _ := sessionTime(session.Get(a.config.UserIDKey + ".seen_at"))
+	if a.config.AbsoluteTimeout > 0 && now.Sub(loginAt) > a.config.AbsoluteTimeout ||
+		a.config.IdleTimeout > 0 && now.Sub(seenAt) > a.config.IdleTimeout {
+		a.clear(session)
+		return ""
+	}
+	return userID
+}
+
+// login stores userID and the login time in the session.
+func (a *SessionAuth) login(session Session, userID string, now time.Time) error {
+	if regenerator, ok := session.(sessionRegenerator); ok {
+		if err := regenerator.Regenerate(); err != nil {
+			return err
+		}
+	}
+	session.Set(a.config.UserIDKey, userID)
+	session.Set(a.config.UserIDKey+".login_at", now.Unix())
+	session.Set(a.config.UserIDKey+".seen_at", now.Unix())
+	return nil
+}
+
+// clear removes the authentication keys from the session.
+func (a *SessionAuth) clear(session Session) {
+	session.Delete(a.config.UserIDKey)
+	session.Delete(a.config.UserIDKey + ".login_at")
+	session.Delete(a.config.UserIDKey + ".seen_at")
+}
+
+// remember issues a remember-me token and cookie for userID.
+func (a *SessionAuth) remember(c *ginji.Context, userID string) error {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	token := hex.EncodeToString(b)
+	expires := time.Now().Add(a.config.RememberMaxAge)
+
+	if a.config.RememberStore != nil {
+		if err := a.config.RememberStore.Save(userID, token, expires); err != nil {
+			return err
+		}
+	}
+	return a.config.RememberCookie.SetCookie(c, &http.Cookie{
+		Name:     a.config.RememberCookieName,
+		Value:    token + "|" + strconv.FormatInt(expires.Unix(), 10) + "|" + userID,
+		Path:     "/",
+		MaxAge:   int(a.config.RememberMaxAge.Seconds()),
+		Secure:   a.config.CookieSecure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// remembered returns the user ID of a valid remember-me cookie.
+func (a *SessionAuth) remembered(c *ginji.Context) (string, bool) {
+	if a.config.RememberCookie == nil {
+		return "", false
+	}
+	cookie, err := c.Cookie(a.config.RememberCookieName)
+	if err != nil {
+		return "", false
+	}
+	value, err := a.config.RememberCookie.Decode(a.config.RememberCookieName, cookie.Value)
+	if err != nil {
+		return "", false
+	}
+
+	// The embedded expiry holds even if the browser keeps the cookie longer
+	userID, token, expires, ok := parseRememberValue(value)
+	if !ok || time.Now().After(expires) {
+		return "", false
+	}
+	if a.config.RememberStore != nil && !a.config.RememberStore.Valid(userID, token) {
+		return "", false
+	}
+	return userID, true
+}
+
+// parseRememberValue splits a decoded remember-me cookie value. The user ID
+// comes last since it may contain the separator.
+func parseRememberValue(value string) (userID, token string, expires time.Time, ok bool) {
+	parts := strings.SplitN(value, "|", 3)
+	if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+		return "", "", time.Time{}, false
+	}
+	unix, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", "", time.Time{}, false
+	}
+	return parts[2], parts[0], time.Unix(unix, 0), true
+}
+
+// sessionTime converts a time stored in a session, which may have been
+// round-tripped through a serializer, to a time.Time.
+func sessionTime(v any) (time.Time, bool) {
+	switch t := v.(type) {
+	case int64:
+		return time.Unix(t, 0), true
+	case int:
+		return time.Unix(int64(t), 0), true
+	case float64:
+		return time.Unix(int64(t), 0), true
+	case time.Time:
+		return t, true
+	}
+	return time.Time{}, false
+}
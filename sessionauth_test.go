@@ -0,0 +1,431 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// memoryRememberStore is a RememberTokenStore for tests.
+type memoryRememberStore struct {
+	mu     sync.Mutex
+	tokens map[string]bool
+}
+
+func (s *memoryRememberStore) Save(userID, token string, expires time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tokens == nil {
+		s.tokens = make(map[string]bool)
+	}
+	s.tokens[userID+"|"+token] = true
+	return nil
+}
+
+func (s *memoryRememberStore) Valid(userID, token string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tokens[userID+"|"+token]
+}
+
+func (s *memoryRememberStore) Delete(userID, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tokens, userID+"|"+token)
+	return nil
+}
+
+// loadTestUser loads every user but "ghost", which was deleted.
+func loadTestUser(c *ginji.Context, userID string) (any, error) {
+	if userID == "ghost" {
+		return nil, nil
+	}
+	return map[string]any{"name": userID}, nil
+}
+
+func TestSessionAuthLogin(t *testing.T) {
+	session := mapSession{}
+	auth := NewSessionAuth(SessionAuthConfig{LoadUser: loadTestUser})
+
+	app := ginji.New()
+	app.Use(func(c *ginji.Context) error {
+		SetSession(c, session)
+		return c.Next()
+	})
+	app.Post("/login", func(c *ginji.Context) error {
+		if err := auth.Login(c, "alice", false); err != nil {
+			return err
+		}
+		return c.Text(ginji.StatusOK, "ok")
+	})
+	ginji.AssertStatus(t, ginji.PerformRequest(app, "POST", "/login", nil), ginji.StatusOK)
+
+	protected := ginji.New()
+	protected.Use(func(c *ginji.Context) error {
+		SetSession(c, session)
+		return c.Next()
+	})
+	protected.Use(auth.Middleware())
+	protected.Get("/me", func(c *ginji.Context) error {
+		user, _ := UserFrom(c)
+		return c.JSON(ginji.StatusOK, user)
+	})
+
+	w := ginji.PerformRequest(protected, "GET", "/me", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	ginji.AssertBody(t, w, "alice")
+}
+
+func TestSessionAuthUnauthenticated(t *testing.T) {
+	auth := NewSessionAuth(SessionAuthConfig{LoadUser: loadTestUser})
+
+	app := ginji.New()
+	app.Use(func(c *ginji.Context) error {
+		SetSession(c, mapSession{})
+		return c.Next()
+	})
+	app.Use(auth.Middleware())
+	app.Get("/me", func(c *ginji.Context) error {
+		user, _ := UserFrom(c)
+		return c.JSON(ginji.StatusOK, user)
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/me", nil)
+	ginji.AssertStatus(t, w, ginji.StatusUnauthorized)
+	ginji.AssertBody(t, w, "Authentication required")
+}
+
+func TestSessionAuthOptional(t *testing.T) {
+	auth := NewSessionAuth(SessionAuthConfig{
+		LoadUser: func(c *ginji.Context, userID string) (any, error) { return userID, nil },
+		Optional: true,
+	})
+
+	app := ginji.New()
+	app.Use(func(c *ginji.Context) error {
+		SetSession(c, mapSession{})
+		return c.Next()
+	})
+	app.Use(auth.Middleware())
+	app.Get("/", func(c *ginji.Context) error {
+		_, ok := UserFrom(c)
+		if ok {
+			return c.Text(ginji.StatusOK, "user")
+		}
+		return c.Text(ginji.StatusOK, "anonymous")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	ginji.AssertBody(t, w, "anonymous")
+}
+
+func TestSessionAuthTimeouts(t *testing.T) {
+	now := time.Now().Unix()
+	tests := []struct {
+		name    string
+		loginAt int64
+		seenAt  int64
+		want    int
+	}{
+		{"active", now - 60, now - 10, ginji.StatusOK},
+		{"idle", now - 3600, now - 3600, ginji.StatusUnauthorized},
+		{"absolute", now - 25*3600, now - 10, ginji.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := mapSession{
+				"auth_user_id":          "alice",
+				"auth_user_id.login_at": tt.loginAt,
+				"auth_user_id.seen_at":  float64(tt.seenAt), // as decoded from JSON
+			}
+			auth := NewSessionAuth(SessionAuthConfig{LoadUser: loadTestUser})
+
+			app := ginji.New()
+			app.Use(func(c *ginji.Context) error {
+				SetSession(c, session)
+				return c.Next()
+			})
+			app.Use(auth.Middleware())
+			app.Get("/me", func(c *ginji.Context) error {
+				return c.Text(ginji.StatusOK, "ok")
+			})
+
+			w := ginji.PerformRequest(app, "GET", "/me", nil)
+			ginji.AssertStatus(t, w, tt.want)
+			if tt.want != ginji.StatusOK && session["auth_user_id"] != nil {
+				t.Error("Expected expired session to be cleared")
+			}
+		})
+	}
+}
+
+func TestSessionAuthDeletedUser(t *testing.T) {
+	now := time.Now().Unix()
+	session := mapSession{
+		"auth_user_id":          "ghost",
+		"auth_user_id.login_at": now,
+		"auth_user_id.seen_at":  now,
+	}
+	auth := NewSessionAuth(SessionAuthConfig{LoadUser: loadTestUser})
+
+	app := ginji.New()
+	app.Use(func(c *ginji.Context) error {
+		SetSession(c, session)
+		return c.Next()
+	})
+	app.Use(auth.Middleware())
+	app.Get("/me", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/me", nil)
+	ginji.AssertStatus(t, w, ginji.StatusUnauthorized)
+	if session["auth_user_id"] != nil {
+		t.Error("Expected session of deleted user to be cleared")
+	}
+}
+
+func TestSessionAuthLoadUserError(t *testing.T) {
+	now := time.Now().Unix()
+	session := mapSession{
+		"auth_user_id":          "alice",
+		"auth_user_id.login_at": now,
+		"auth_user_id.seen_at":  now,
+	}
+	errUnavailable := errors.New("database unavailable")
+	auth := NewSessionAuth(SessionAuthConfig{
+		LoadUser: func(c *ginji.Context, userID string) (any, error) {
+			return nil, errUnavailable
+		},
+	})
+
+	var got error
+	app := ginji.New()
+	app.Use(func(c *ginji.Context) error {
+		SetSession(c, session)
+		got = c.Next()
+		return got
+	})
+	app.Use(auth.Middleware())
+	app.Get("/me", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	ginji.PerformRequest(app, "GET", "/me", nil)
+	if !errors.Is(got, errUnavailable) {
+		t.Errorf("Expected LoadUser error, got %v", got)
+	}
+}
+
+func TestSessionAuthNoSession(t *testing.T) {
+	auth := NewSessionAuth(SessionAuthConfig{
+		LoadUser: func(c *ginji.Context, userID string) (any, error) { return userID, nil },
+	})
+
+	app := ginji.New()
+	app.Use(auth.Middleware())
+	app.Get("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	// Without session middleware nobody is logged in
+	w := ginji.PerformRequest(app, "GET", "/", nil)
+	ginji.AssertStatus(t, w, ginji.StatusUnauthorized)
+
+	optional := NewSessionAuth(SessionAuthConfig{
+		LoadUser: func(c *ginji.Context, userID string) (any, error) { return userID, nil },
+		Optional: true,
+	})
+	app = ginji.New()
+	app.Use(optional.Middleware())
+	app.Get("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+	app.Post("/login", func(c *ginji.Context) error {
+		if err := optional.Login(c, "alice", false); !errors.Is(err, ErrNoSession) {
+			t.Errorf("Expected ErrNoSession, got %v", err)
+		}
+		return c.Text(ginji.StatusOK, "ok")
+	})
+	ginji.AssertStatus(t, ginji.PerformRequest(app, "GET", "/", nil), ginji.StatusOK)
+	ginji.PerformRequest(app, "POST", "/login", nil)
+}
+
+func TestSessionAuthRememberMe(t *testing.T) {
+	sc, err := NewSecureCookie(SecureCookieConfig{HashKeys: [][]byte{[]byte(strings.Repeat("k", 32))}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := NewSessionAuth(SessionAuthConfig{
+		LoadUser:       loadTestUser,
+		RememberCookie: sc,
+		RememberStore:  &memoryRememberStore{},
+	})
+
+	session := mapSession{}
+	app := ginji.New()
+	app.Use(func(c *ginji.Context) error {
+		SetSession(c, session)
+		return c.Next()
+	})
+	app.Use(auth.Middleware())
+	app.Get("/me", func(c *ginji.Context) error {
+		user, _ := UserFrom(c)
+		return c.JSON(ginji.StatusOK, user)
+	})
+
+	login := ginji.New()
+	login.Use(func(c *ginji.Context) error {
+		SetSession(c, mapSession{})
+		return c.Next()
+	})
+	login.Post("/login", func(c *ginji.Context) error {
+		if err := auth.Login(c, "alice", true); err != nil {
+			return err
+		}
+		return c.Text(ginji.StatusOK, "ok")
+	})
+	login.Post("/logout", func(c *ginji.Context) error {
+		if err := auth.Logout(c); err != nil {
+			return err
+		}
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	// Log in with remember-me on one session
+	w := ginji.PerformRequest(login, "POST", "/login", nil)
+	var remember *http.Cookie
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == "remember_me" {
+			remember = cookie
+		}
+	}
+	if remember == nil {
+		t.Fatal("Expected remember-me cookie")
+	}
+	if !remember.HttpOnly || remember.MaxAge != 30*24*3600 {
+		t.Errorf("Unexpected remember-me cookie attributes: %+v", remember)
+	}
+
+	// A fresh session is logged in from the cookie
+	w = ginji.NewRequest(app, "GET", "/me").Cookie(remember).Do()
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	ginji.AssertBody(t, w, "alice")
+	if session["auth_user_id"] != "alice" {
+		t.Error("Expected remember-me login to populate the session")
+	}
+
+	// Logging out revokes the token
+	w = ginji.NewRequest(login, "POST", "/logout").Cookie(remember).Do()
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+
+	session = mapSession{}
+	w = ginji.NewRequest(app, "GET", "/me").Cookie(remember).Do()
+	ginji.AssertStatus(t, w, ginji.StatusUnauthorized)
+}
+
+func TestSessionAuthRememberMeTampered(t *testing.T) {
+	sc, err := NewSecureCookie(SecureCookieConfig{HashKeys: [][]byte{[]byte(strings.Repeat("k", 32))}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := NewSessionAuth(SessionAuthConfig{LoadUser: loadTestUser, RememberCookie: sc})
+
+	app := ginji.New()
+	app.Use(func(c *ginji.Context) error {
+		SetSession(c, mapSession{})
+		return c.Next()
+	})
+	app.Use(auth.Middleware())
+	app.Get("/me", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	w := ginji.NewRequest(app, "GET", "/me").
+		Cookie(&http.Cookie{Name: "remember_me", Value: "token|9999999999|alice"}).
+		Do()
+	ginji.AssertStatus(t, w, ginji.StatusUnauthorized)
+}
+
+func TestSessionAuthRequiresLoadUser(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic without LoadUser")
+		}
+	}()
+	NewSessionAuth(SessionAuthConfig{})
+}
+
+func TestSessionAuthPurgeUser(t *testing.T) {
+	sc, err := NewSecureCookie(SecureCookieConfig{HashKeys: [][]byte{[]byte(strings.Repeat("k", 32))}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewMemoryRememberTokenStore()
+	auth := NewSessionAuth(SessionAuthConfig{LoadUser: loadTestUser, RememberCookie: sc, RememberStore: store})
+
+	app := ginji.New()
+	app.Use(func(c *ginji.Context) error {
+		SetSession(c, mapSession{})
+		return c.Next()
+	})
+	app.Use(auth.Middleware())
+	app.Get("/me", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	login := ginji.New()
+	login.Use(func(c *ginji.Context) error {
+		SetSession(c, mapSession{})
+		return c.Next()
+	})
+	login.Post("/login", func(c *ginji.Context) error {
+		if err := auth.Login(c, "alice", true); err != nil {
+			return err
+		}
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	var remember *http.Cookie
+	for range 2 {
+		w := ginji.PerformRequest(login, "POST", "/login", nil)
+		remember = w.Result().Cookies()[0]
+	}
+	_ = store.Save("bob", "other", time.Now().Add(time.Hour))
+
+	records, err := auth.ExportUser(context.Background(), "alice")
+	if err != nil || len(records) != 2 {
+		t.Fatalf("Expected 2 exported tokens, got %v, %v", records, err)
+	}
+	if data, _ := json.Marshal(records); strings.Contains(string(data), "token") {
+		t.Errorf("Expected tokens to be left out of the export, got %s", data)
+	}
+
+	w := ginji.NewRequest(app, "GET", "/me").Cookie(remember).Do()
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+
+	n, err := auth.PurgeUser(context.Background(), "alice")
+	if err != nil || n != 2 {
+		t.Fatalf("Expected 2 purged tokens, got %d, %v", n, err)
+	}
+	w = ginji.NewRequest(app, "GET", "/me").Cookie(remember).Do()
+	ginji.AssertStatus(t, w, ginji.StatusUnauthorized)
+	if !store.Valid("bob", "other") {
+		t.Error("Expected other users' tokens to be kept")
+	}
+
+	// Stores that cannot enumerate by user are reported
+	auth = NewSessionAuth(SessionAuthConfig{LoadUser: loadTestUser, RememberCookie: sc, RememberStore: &memoryRememberStore{}})
+	if _, err := auth.PurgeUser(context.Background(), "alice"); !errors.Is(err, ErrUserDataUnsupported) {
+		t.Errorf("Expected ErrUserDataUnsupported, got %v", err)
+	}
+}
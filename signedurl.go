@@ -0,0 +1,319 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// Signed URL verification errors.
+var (
+	ErrSignedURLInvalid = errors.New("signedurl: invalid signature")
+	ErrSignedURLExpired = errors.New("signedurl: link expired")
+	ErrSignedURLUsed    = errors.New("signedurl: link already used")
+)
+
+// SignedURLConfig defines the configuration for signed URLs.
+type SignedURLConfig struct {
+	// Keys are HMAC-SHA256 keys. The first key signs new URLs and every key
+	// is tried when verifying, which allows key rotation. At least one key
+	// of 32 bytes or more is required.
+	Keys [][]byte
+
+	// KeyProvider supplies the keys instead of Keys, e.g. from a file that
+	// is reloaded on rotation. URLs signed with a key that has an ID carry
+	// it in a "kid" parameter and are verified with that key only.
+	KeyProvider KeyProvider
+
+	// TTL is how long links stay valid when SignedURLOptions sets no
+	// expiry.
+	// Default: 1 hour
+	TTL time.Duration
+
+	// UsageStore counts the uses of links signed with MaxUses.
+	// Default: in-memory
+	UsageStore SignedURLUsageStore
+
+	// TrustedProxies are used to find the client IP of IP-bound links.
+	TrustedProxies []string
+
+	// StatusCode is the status sent for invalid, expired or used links.
+	// Default: 403
+	StatusCode int
+
+	// SkipFunc allows skipping verification for certain requests.
+	SkipFunc Skipper
+}
+
+// SignedURLOptions restrict a signed URL.
+type SignedURLOptions struct {
+	// Expires is when the link stops working.
+	// Default: now plus the configured TTL
+	Expires time.Time
+
+	// Method binds the link to an HTTP method.
+	// Default: "" (any method)
+	Method string
+
+	// IP binds the link to a client IP. The IP is part of the signature
+	// but not of the URL.
+	// Default: "" (any IP)
+	IP string
+
+	// MaxUses limits how many times the link can be used.
+	// Default: 0 (unlimited)
+	MaxUses int
+}
+
+// SignedURLUsageStore counts link uses. Implementations must be safe for
+// concurrent use.
+type SignedURLUsageStore interface {
+	// Incr increments the counter of key, which may be forgotten after
+	// expires, and returns the new count.
+	Incr(key string, expires time.Time) (int64, error)
+}
+
+// Query parameters of signed URLs.
+const (
+	signedURLExpiresParam   = "expires"
+	signedURLMethodParam    = "method"
+	signedURLIPParam        = "ip"
+	signedURLUsesParam      = "uses"
+	signedURLKeyIDParam     = "kid"
+	signedURLSignatureParam = "signature"
+)
+
+// memoryUsageStore is the default in-memory SignedURLUsageStore.
+type memoryUsageStore struct {
+	mu     sync.Mutex
+	counts map[string]usageCount
+}
+
+// usageCount is the use count of one link.
+type usageCount struct {
+	n       int64
+	expires time.Time
+}
+
+// NewMemoryUsageStore creates an in-memory usage store. Expired counters
+// are removed as new links are used.
+func NewMemoryUsageStore() SignedURLUsageStore {
+	return &memoryUsageStore{counts: make(map[string]usageCount)}
+}
+
+// Incr increments the counter of key.
+func (s *memoryUsageStore) Incr(key string, expires time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	count, ok := s.counts[key]
+	if !ok {
+		for k, v := range s.counts {
+			if now.After(v.expires) {
+				delete(s.counts, k)
+			}
+		}
+	}
+	count.n++
+	count.expires = expires
+	s.counts[key] = count
+	return count.n, nil
+}
+
+// URLSigner creates and verifies expiring HMAC-signed URLs, e.g. for
+// download or share links that work without a session:
+//
+//	signer, _ := middleware.NewURLSigner(middleware.SignedURLConfig{Keys: [][]byte{key}})
+//	link, _ := signer.Sign("/files/report.pdf", middleware.SignedURLOptions{MaxUses: 1})
+//
+//	files := app.Group("/files")
+//	files.Use(signer.Middleware())
+type URLSigner struct {
+	config SignedURLConfig
+	keys   KeyProvider
+}
+
+// NewURLSigner creates a URL signer with the given configuration.
+func NewURLSigner(config SignedURLConfig) (*URLSigner, error) {
+	keys := config.KeyProvider
+	if keys == nil {
+		if len(config.Keys) == 0 {
+			return nil, errors.New("signedurl: at least one key is required")
+		}
+		keys = staticKeyValues(config.Keys)
+	}
+	if _, err := loadKeys(keys, 32); err != nil {
+		return nil, fmt.Errorf("signedurl: %w", err)
+	}
+	if config.TTL <= 0 {
+		config.TTL = time.Hour
+	}
+	if config.UsageStore == nil {
+		config.UsageStore = NewMemoryUsageStore()
+	}
+	if config.StatusCode == 0 {
+		config.StatusCode = ginji.StatusForbidden
+	}
+	return &URLSigner{config: config, keys: keys}, nil
+}
+
+// Sign returns rawURL with the expiry, bindings and signature added as
+// query parameters. Only the path and query are signed, so the link works
+// on any host serving the path.
+func (s *URLSigner) Sign(rawURL string, opts SignedURLOptions) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("signedurl: %w", err)
+	}
+	keys, err := loadKeys(s.keys, 32)
+	if err != nil {
+		return "", fmt.Errorf("signedurl: %w", err)
+	}
+
+	expires := opts.Expires
+	if expires.IsZero() {
+		expires = time.Now().Add(s.config.TTL)
+	}
+
+	query := u.Query()
+	query.Del(signedURLSignatureParam)
+	query.Del(signedURLKeyIDParam)
+	if keys[0].ID != "" {
+		query.Set(signedURLKeyIDParam, keys[0].ID)
+	}
+	query.Set(signedURLExpiresParam, strconv.FormatInt(expires.Unix(), 10))
+	if opts.Method != "" {
+		query.Set(signedURLMethodParam, strings.ToUpper(opts.Method))
+	}
+	if opts.IP != "" {
+		query.Set(signedURLIPParam, "1")
+	}
+	if opts.MaxUses > 0 {
+		query.Set(signedURLUsesParam, strconv.Itoa(opts.MaxUses))
+	}
+
+	query.Set(signedURLSignatureParam, signURL(keys[0].Value, u.EscapedPath(), query, opts.IP))
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// Verify checks the signature, expiry and bindings of a request for a
+// signed URL and records a use of links with a use limit.
+func (s *URLSigner) Verify(r *http.Request) error {
+	query := r.URL.Query()
+	signature := query.Get(signedURLSignatureParam)
+	if signature == "" {
+		return ErrSignedURLInvalid
+	}
+
+	var ip string
+	if query.Get(signedURLIPParam) != "" {
+		ip = clientIP(r, s.config.TrustedProxies)
+	}
+
+	keys, err := loadKeys(s.keys, 32)
+	if err != nil {
+		return fmt.Errorf("signedurl: %w", err)
+	}
+	if kid := query.Get(signedURLKeyIDParam); kid != "" {
+		key, ok := findKey(keys, kid)
+		if !ok {
+			return ErrSignedURLInvalid
+		}
+		keys = []Key{key}
+	}
+
+	valid := false
+	for _, key := range keys {
+		if hmac.Equal([]byte(signature), []byte(signURL(key.Value, r.URL.EscapedPath(), query, ip))) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return ErrSignedURLInvalid
+	}
+
+	expires, err := parseUnixTimestamp(query.Get(signedURLExpiresParam))
+	if err != nil {
+		return ErrSignedURLInvalid
+	}
+	if time.Now().After(expires) {
+		return ErrSignedURLExpired
+	}
+
+	if method := query.Get(signedURLMethodParam); method != "" && method != r.Method {
+		return ErrSignedURLInvalid
+	}
+
+	if uses := query.Get(signedURLUsesParam); uses != "" {
+		limit, err := strconv.ParseInt(uses, 10, 64)
+		if err != nil {
+			return ErrSignedURLInvalid
+		}
+		n, err := s.config.UsageStore.Incr("signedurl|"+signature, expires)
+		if err != nil {
+			return err
+		}
+		if n > limit {
+			return ErrSignedURLUsed
+		}
+	}
+	return nil
+}
+
+// Middleware returns middleware that rejects requests without a valid
+// signed URL.
+func (s *URLSigner) Middleware() ginji.Middleware {
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if s.config.SkipFunc != nil && s.config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		err := s.Verify(c.Req)
+		switch {
+		case err == nil:
+			return c.Next()
+		case errors.Is(err, ErrSignedURLExpired):
+			c.AbortWithStatusJSON(s.config.StatusCode, ginji.H{"error": "Link expired"})
+		case errors.Is(err, ErrSignedURLUsed):
+			c.AbortWithStatusJSON(s.config.StatusCode, ginji.H{"error": "Link already used"})
+		case errors.Is(err, ErrSignedURLInvalid):
+			c.AbortWithStatusJSON(s.config.StatusCode, ginji.H{"error": "Invalid signature"})
+		default:
+			return err
+		}
+		return nil
+	}
+}
+
+// signURL computes the signature of path and query, without the signature
+// parameter, bound to ip if set.
+func signURL(key []byte, path string, query url.Values, ip string) string {
+	unsigned := make(url.Values, len(query))
+	for name, values := range query {
+		if name != signedURLSignatureParam {
+			unsigned[name] = values
+		}
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(path))
+	mac.Write([]byte("?"))
+	mac.Write([]byte(unsigned.Encode()))
+	mac.Write([]byte("|"))
+	mac.Write([]byte(ip))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+func newTestURLSigner(t *testing.T, keys ...string) *URLSigner {
+	t.Helper()
+	if len(keys) == 0 {
+		keys = []string{strings.Repeat("s", 32)}
+	}
+	config := SignedURLConfig{}
+	for _, key := range keys {
+		config.Keys = append(config.Keys, []byte(key))
+	}
+	signer, err := NewURLSigner(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+func TestSignedURL(t *testing.T) {
+	signer := newTestURLSigner(t)
+	app := ginji.New()
+	app.Use(signer.Middleware())
+	app.Get("/files/:name", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "file "+c.Param("name"))
+	})
+
+	link, err := signer.Sign("/files/report.pdf?download=1", SignedURLOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := ginji.PerformRequest(app, "GET", link, nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	ginji.AssertBody(t, w, "file report.pdf")
+
+	// Tampering with the path or query breaks the signature
+	for _, tampered := range []string{
+		strings.Replace(link, "report.pdf", "secrets.pdf", 1),
+		strings.Replace(link, "download=1", "download=2", 1),
+		"/files/report.pdf",
+	} {
+		w := ginji.PerformRequest(app, "GET", tampered, nil)
+		ginji.AssertStatus(t, w, ginji.StatusForbidden)
+		ginji.AssertBody(t, w, "Invalid signature")
+	}
+}
+
+func TestSignedURLExpired(t *testing.T) {
+	signer := newTestURLSigner(t)
+	app := ginji.New()
+	app.Use(signer.Middleware())
+	app.Get("/files/:name", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "file "+c.Param("name"))
+	})
+
+	link, _ := signer.Sign("/files/report.pdf", SignedURLOptions{Expires: time.Now().Add(-time.Second)})
+
+	w := ginji.PerformRequest(app, "GET", link, nil)
+	ginji.AssertStatus(t, w, ginji.StatusForbidden)
+	ginji.AssertBody(t, w, "Link expired")
+
+	// Extending the expiry invalidates the signature
+	u, _ := url.Parse(link)
+	query := u.Query()
+	query.Set("expires", "9999999999")
+	u.RawQuery = query.Encode()
+	ginji.AssertBody(t, ginji.PerformRequest(app, "GET", u.String(), nil), "Invalid signature")
+}
+
+func TestSignedURLMethod(t *testing.T) {
+	signer := newTestURLSigner(t)
+	app := ginji.New()
+	app.Use(signer.Middleware())
+	app.Get("/files/:name", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "file "+c.Param("name"))
+	})
+	app.Delete("/files/:name", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "deleted")
+	})
+
+	link, _ := signer.Sign("/files/report.pdf", SignedURLOptions{Method: "get"})
+
+	ginji.AssertStatus(t, ginji.PerformRequest(app, "GET", link, nil), ginji.StatusOK)
+	ginji.AssertStatus(t, ginji.PerformRequest(app, "DELETE", link, nil), ginji.StatusForbidden)
+}
+
+func TestSignedURLIP(t *testing.T) {
+	signer := newTestURLSigner(t)
+	app := ginji.New()
+	app.Use(signer.Middleware())
+	app.Get("/files/:name", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "file "+c.Param("name"))
+	})
+
+	link, _ := signer.Sign("/files/report.pdf", SignedURLOptions{IP: "192.0.2.1"})
+	if strings.Contains(link, "192.0.2.1") {
+		t.Errorf("Expected IP not to appear in the link, got %s", link)
+	}
+	ginji.AssertStatus(t, ginji.PerformRequest(app, "GET", link, nil), ginji.StatusOK)
+
+	other, _ := signer.Sign("/files/report.pdf", SignedURLOptions{IP: "198.51.100.7"})
+	ginji.AssertStatus(t, ginji.PerformRequest(app, "GET", other, nil), ginji.StatusForbidden)
+}
+
+func TestSignedURLMaxUses(t *testing.T) {
+	signer := newTestURLSigner(t)
+	app := ginji.New()
+	app.Use(signer.Middleware())
+	app.Get("/files/:name", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "file "+c.Param("name"))
+	})
+
+	link, _ := signer.Sign("/files/report.pdf", SignedURLOptions{MaxUses: 2})
+
+	ginji.AssertStatus(t, ginji.PerformRequest(app, "GET", link, nil), ginji.StatusOK)
+	ginji.AssertStatus(t, ginji.PerformRequest(app, "GET", link, nil), ginji.StatusOK)
+
+	w := ginji.PerformRequest(app, "GET", link, nil)
+	ginji.AssertStatus(t, w, ginji.StatusForbidden)
+	ginji.AssertBody(t, w, "Link already used")
+}
+
+func TestSignedURLKeyRotation(t *testing.T) {
+	oldKey, newKey := strings.Repeat("o", 32), strings.Repeat("n", 32)
+	link, _ := newTestURLSigner(t, oldKey).Sign("/files/report.pdf", SignedURLOptions{})
+
+	rotated := ginji.New()
+	rotated.Use(newTestURLSigner(t, newKey, oldKey).Middleware())
+	rotated.Get("/files/:name", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "file "+c.Param("name"))
+	})
+	ginji.AssertStatus(t, ginji.PerformRequest(rotated, "GET", link, nil), ginji.StatusOK)
+
+	retired := ginji.New()
+	retired.Use(newTestURLSigner(t, newKey).Middleware())
+	retired.Get("/files/:name", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "file "+c.Param("name"))
+	})
+	ginji.AssertStatus(t, ginji.PerformRequest(retired, "GET", link, nil), ginji.StatusForbidden)
+}
+
+func TestNewURLSignerKeys(t *testing.T) {
+	if _, err := NewURLSigner(SignedURLConfig{}); err == nil {
+		t.Error("Expected error without keys")
+	}
+	if _, err := NewURLSigner(SignedURLConfig{Keys: [][]byte{[]byte("short")}}); err == nil {
+		t.Error("Expected error for short key")
+	}
+}
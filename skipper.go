@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"github.com/ginjigo/ginji"
+)
+
+// Skipper reports whether a middleware should be bypassed for a request.
+// Every middleware config exposes one as its SkipFunc field.
+type Skipper func(*ginji.Context) bool
+
+// healthEndpoints are the probe paths skipped by SkipHealthEndpoints.
+var healthEndpoints = []string{
+	"/health",
+	"/health/live",
+	"/health/ready",
+	"/healthz",
+	"/livez",
+	"/readyz",
+	"/ping",
+}
+
+// SkipPaths returns a Skipper matching request paths against globs, using
+// the same syntax as PathGlob.
+//
+//	config.SkipFunc = middleware.SkipPaths("/static/**", "/favicon.ico")
+func SkipPaths(globs ...string) Skipper {
+	matchers := make([]ginji.ConditionFunc, len(globs))
+	for i, glob := range globs {
+		matchers[i] = PathGlob(glob)
+	}
+	return func(c *ginji.Context) bool {
+		for _, match := range matchers {
+			if match(c) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// SkipHealthEndpoints returns a Skipper for common liveness and readiness
+// probe paths, including those served by the Health middleware.
+func SkipHealthEndpoints() Skipper {
+	return SkipPaths(healthEndpoints...)
+}
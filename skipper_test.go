@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ginjigo/ginji"
+)
+
+func TestSkipPaths(t *testing.T) {
+	skip := SkipPaths("/static/**", "/favicon.ico")
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/static/js/app.js", true},
+		{"/favicon.ico", true},
+		{"/api/users", false},
+	}
+	for _, tt := range tests {
+		c := &ginji.Context{Req: httptest.NewRequest("GET", tt.path, nil)}
+		if got := skip(c); got != tt.want {
+			t.Errorf("SkipPaths on %q = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestSkipHealthEndpoints(t *testing.T) {
+	app := ginji.New()
+	config := DefaultRequestIDConfig()
+	config.SkipFunc = SkipHealthEndpoints()
+	app.Use(RequestIDWithConfig(config))
+	handler := func(c *ginji.Context) error { return c.Text(ginji.StatusOK, "ok") }
+	app.Get("/healthz", handler)
+	app.Get("/users", handler)
+
+	w := ginji.PerformRequest(app, "GET", "/healthz", nil)
+	if w.Header().Get("X-Request-ID") != "" {
+		t.Error("Expected health endpoint to skip request ID")
+	}
+
+	w = ginji.PerformRequest(app, "GET", "/users", nil)
+	if w.Header().Get("X-Request-ID") == "" {
+		t.Error("Expected request ID on regular endpoint")
+	}
+}
+
+func TestSkipFuncOnSecureAndBodyLimit(t *testing.T) {
+	app := ginji.New()
+	secure := DefaultSecureConfig()
+	secure.SkipFunc = SkipPaths("/raw")
+	app.Use(SecureWithConfig(secure))
+	limit := DefaultBodyLimitConfig()
+	limit.MaxBytes = 4
+	limit.SkipFunc = SkipPaths("/raw")
+	app.Use(BodyLimitWithConfig(limit))
+	handler := func(c *ginji.Context) error { return c.Text(ginji.StatusOK, "ok") }
+	app.Post("/raw", handler)
+	app.Post("/api", handler)
+
+	w := ginji.PerformRequest(app, "POST", "/raw", strings.NewReader("larger than four bytes"))
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	if w.Header().Get("X-Frame-Options") != "" {
+		t.Error("Expected security headers to be skipped")
+	}
+
+	w = ginji.PerformRequest(app, "POST", "/api", strings.NewReader("larger than four bytes"))
+	ginji.AssertStatus(t, w, ginji.StatusRequestEntityTooLarge)
+}
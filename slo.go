@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// SLOTarget is a latency objective such as "99% of requests under 300ms".
+// A request is good when it completes within Latency without a 5xx status.
+type SLOTarget struct {
+	// Objective is the fraction of requests that must be good, e.g. 0.99.
+	Objective float64
+
+	// Latency is the threshold a good request must complete within.
+	Latency time.Duration
+}
+
+// SLOStats is the compliance of one SLO, as reported by Metrics.Stats.
+type SLOStats struct {
+	Objective    float64 `json:"objective"`
+	Latency      string  `json:"latency"`
+	Good         uint64  `json:"good"`
+	Total        uint64  `json:"total"`
+	Compliance   float64 `json:"compliance"`
+	BudgetLeft   float64 `json:"error_budget_remaining"`
+	ObjectiveMet bool    `json:"objective_met"`
+}
+
+// MetricsStats is the snapshot returned by Metrics.Stats.
+type MetricsStats struct {
+	SLOs  map[string]SLOStats `json:"slos,omitempty"`
+	Apdex map[string]float64  `json:"apdex,omitempty"`
+}
+
+// describeSLO registers the SLO and Apdex metric families.
+func (m *Metrics) describeSLO() {
+	if len(m.slos) > 0 {
+		m.describe("slo_requests_total", MetricCounter, "Requests evaluated against an SLO, by result (good or bad).")
+		m.describe("slo_objective_ratio", MetricGauge, "Target fraction of good requests for an SLO.")
+		m.describe("slo_compliance_ratio", MetricGauge, "Observed fraction of good requests for an SLO.")
+		m.describe("slo_error_budget_remaining_ratio", MetricGauge, "Fraction of the SLO error budget left; negative once exhausted.")
+	}
+	if m.config.ApdexTarget > 0 {
+		m.describe("apdex_requests_total", MetricCounter, "Requests by Apdex result (satisfied, tolerating or frustrated).")
+		m.describe("apdex_score", MetricGauge, "Apdex score between 0 and 1.")
+	}
+}
+
+// observeSLO records a request against the matching SLO and Apdex.
+func (m *Metrics) observeSLO(c *ginji.Context, route string, status int, latency time.Duration) {
+	failed := status >= 500
+
+	for _, slo := range m.slos {
+		if !slo.match(c) {
+			continue
+		}
+		result := "good"
+		if failed || latency > slo.value.Latency {
+			result = "bad"
+		}
+		m.Inc("slo_requests_total", "slo", slo.pattern, "result", result)
+		break
+	}
+
+	if target := m.config.ApdexTarget; target > 0 {
+		result := "frustrated"
+		switch {
+		case failed:
+		case latency <= target:
+			result = "satisfied"
+		case latency <= 4*target:
+			result = "tolerating"
+		}
+		m.Inc("apdex_requests_total", "route", route, "result", result)
+	}
+}
+
+// refreshSLOGauges recomputes the derived SLO and Apdex gauges.
+func (m *Metrics) refreshSLOGauges() {
+	stats := m.stats()
+	for pattern, s := range stats.SLOs {
+		m.Set("slo_objective_ratio", s.Objective, "slo", pattern)
+		m.Set("slo_compliance_ratio", s.Compliance, "slo", pattern)
+		m.Set("slo_error_budget_remaining_ratio", s.BudgetLeft, "slo", pattern)
+	}
+	for route, score := range stats.Apdex {
+		m.Set("apdex_score", score, "route", route)
+	}
+}
+
+// Stats returns SLO compliance and Apdex scores, so Metrics can be passed
+// to AdminConfig.Stats.
+func (m *Metrics) Stats() any {
+	return m.stats()
+}
+
+// stats computes the MetricsStats snapshot.
+func (m *Metrics) stats() MetricsStats {
+	stats := MetricsStats{}
+
+	if len(m.slos) > 0 {
+		stats.SLOs = make(map[string]SLOStats, len(m.slos))
+		for _, slo := range m.slos {
+			good := uint64(m.Value("slo_requests_total", "slo", slo.pattern, "result", "good"))
+			bad := uint64(m.Value("slo_requests_total", "slo", slo.pattern, "result", "bad"))
+			stats.SLOs[slo.pattern] = newSLOStats(slo.value, good, good+bad)
+		}
+	}
+
+	if m.config.ApdexTarget > 0 {
+		stats.Apdex = make(map[string]float64)
+		counts := make(map[string][3]float64)
+		m.mu.Lock()
+		if family, ok := m.families[m.fullName("apdex_requests_total")]; ok {
+			for _, s := range family.series {
+				route, result := s.labels[1], s.labels[3]
+				n := counts[route]
+				switch result {
+				case "satisfied":
+					n[0] += s.value
+				case "tolerating":
+					n[1] += s.value
+				default:
+					n[2] += s.value
+				}
+				counts[route] = n
+			}
+		}
+		m.mu.Unlock()
+		for route, n := range counts {
+			if total := n[0] + n[1] + n[2]; total > 0 {
+				stats.Apdex[route] = (n[0] + n[1]/2) / total
+			}
+		}
+	}
+	return stats
+}
+
+// newSLOStats computes compliance and the remaining error budget.
+func newSLOStats(target SLOTarget, good, total uint64) SLOStats {
+	s := SLOStats{
+		Objective:  target.Objective,
+		Latency:    target.Latency.String(),
+		Good:       good,
+		Total:      total,
+		Compliance: 1,
+		BudgetLeft: 1,
+	}
+	if total > 0 {
+		s.Compliance = float64(good) / float64(total)
+		if budget := 1 - target.Objective; budget > 0 {
+			s.BudgetLeft = 1 - (1-s.Compliance)/budget
+		}
+	}
+	s.ObjectiveMet = s.Compliance >= target.Objective
+	return s
+}
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+func TestMetricsSLO(t *testing.T) {
+	m := NewMetrics(MetricsConfig{
+		SLOs: map[string]SLOTarget{
+			"/**":   {Objective: 0.5, Latency: time.Second},
+			"/slow": {Objective: 0.9, Latency: 10 * time.Millisecond},
+		},
+	})
+	app := ginji.New()
+	app.Use(m.Middleware())
+	app.Get("/metrics", m.Handler())
+	app.Get("/users", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "users")
+	})
+	app.Get("/fail", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusInternalServerError, "fail")
+	})
+	app.Get("/slow", func(c *ginji.Context) error {
+		time.Sleep(20 * time.Millisecond)
+		return c.Text(ginji.StatusOK, "slow")
+	})
+
+	ginji.PerformRequest(app, "GET", "/users", nil)
+	ginji.PerformRequest(app, "GET", "/users", nil)
+	ginji.PerformRequest(app, "GET", "/fail", nil)
+	ginji.PerformRequest(app, "GET", "/slow", nil)
+
+	stats := m.Stats().(MetricsStats)
+	all := stats.SLOs["/**"]
+	if all.Good != 2 || all.Total != 3 || !all.ObjectiveMet {
+		t.Errorf("Expected 2 of 3 good requests meeting the objective, got %+v", all)
+	}
+	slow := stats.SLOs["/slow"]
+	if slow.Good != 0 || slow.Total != 1 || slow.ObjectiveMet || slow.BudgetLeft >= 0 {
+		t.Errorf("Expected /slow to miss its objective, got %+v", slow)
+	}
+
+	var buf bytes.Buffer
+	m.WriteTo(&buf)
+	body := buf.String()
+	for _, want := range []string{
+		`http_slo_requests_total{slo="/**",result="bad"} 1`,
+		`http_slo_requests_total{slo="/**",result="good"} 2`,
+		`http_slo_objective_ratio{slo="/slow"} 0.9`,
+		`http_slo_compliance_ratio{slo="/slow"} 0`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected exposition to contain %q, got:\n%s", want, body)
+		}
+	}
+}
+
+func TestMetricsApdex(t *testing.T) {
+	m := NewMetrics(MetricsConfig{ApdexTarget: 10 * time.Millisecond})
+	app := ginji.New()
+	app.Use(m.Middleware())
+	app.Get("/metrics", m.Handler())
+	app.Get("/users", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "users")
+	})
+	app.Get("/fail", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusInternalServerError, "fail")
+	})
+	app.Get("/tolerable", func(c *ginji.Context) error {
+		time.Sleep(15 * time.Millisecond)
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	ginji.PerformRequest(app, "GET", "/users", nil)
+	ginji.PerformRequest(app, "GET", "/fail", nil)
+	ginji.PerformRequest(app, "GET", "/tolerable", nil)
+
+	stats := m.Stats().(MetricsStats)
+	if stats.Apdex["/users"] != 1 {
+		t.Errorf("Expected satisfied Apdex of 1, got %v", stats.Apdex["/users"])
+	}
+	if stats.Apdex["/fail"] != 0 {
+		t.Errorf("Expected errors to be frustrated, got %v", stats.Apdex["/fail"])
+	}
+	if stats.Apdex["/tolerable"] != 0.5 {
+		t.Errorf("Expected tolerating Apdex of 0.5, got %v", stats.Apdex["/tolerable"])
+	}
+
+	var buf bytes.Buffer
+	m.WriteTo(&buf)
+	if !strings.Contains(buf.String(), `http_apdex_score{route="/users"} 1`) {
+		t.Errorf("Expected Apdex gauge, got:\n%s", buf.String())
+	}
+}
+
+func TestNewSLOStatsEmpty(t *testing.T) {
+	s := newSLOStats(SLOTarget{Objective: 0.99, Latency: time.Second}, 0, 0)
+	if s.Compliance != 1 || s.BudgetLeft != 1 || !s.ObjectiveMet {
+		t.Errorf("Expected an unused SLO to be compliant, got %+v", s)
+	}
+}
@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// SlowLogConfig defines the configuration for SlowLog middleware.
+type SlowLogConfig struct {
+	// Threshold is the latency above which a request is logged.
+	// Default: 1 second
+	Threshold time.Duration
+
+	// Routes overrides Threshold for paths matching a glob pattern
+	// (see PathGlob). The longest matching pattern wins.
+	Routes map[string]time.Duration
+
+	// Logger is the slog logger instance to use. If nil, uses engine's logger.
+	Logger *slog.Logger
+
+	// Level is the level slow requests are logged at.
+	// Default: slog.LevelWarn
+	Level slog.Level
+
+	// OnSlow is called for every slow request, e.g. to raise an alert.
+	OnSlow func(c *ginji.Context, latency time.Duration)
+
+	// SkipFunc allows skipping the middleware for certain requests.
+	SkipFunc Skipper
+}
+
+// DefaultSlowLogConfig returns default slow log configuration.
+func DefaultSlowLogConfig() SlowLogConfig {
+	return SlowLogConfig{
+		Threshold: time.Second,
+		Level:     slog.LevelWarn,
+	}
+}
+
+// SlowLog returns middleware that logs only requests slower than threshold.
+// Use it on its own when full request logging is too noisy.
+func SlowLog(threshold time.Duration) ginji.Middleware {
+	config := DefaultSlowLogConfig()
+	config.Threshold = threshold
+	return SlowLogWithConfig(config)
+}
+
+// SlowLogWithConfig returns SlowLog middleware with custom configuration.
+func SlowLogWithConfig(config SlowLogConfig) ginji.Middleware {
+	defaults := DefaultSlowLogConfig()
+	if config.Threshold == 0 {
+		config.Threshold = defaults.Threshold
+	}
+	// The zero level is Info, which is too quiet for slow request reports
+	if config.Level == 0 {
+		config.Level = defaults.Level
+	}
+	routes := compilePathOverrides(config.Routes)
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		start := time.Now()
+		err := c.Next()
+		latency := time.Since(start)
+
+		threshold := lookupPathOverride(c, routes, config.Threshold)
+		if threshold <= 0 || latency <= threshold {
+			return err
+		}
+
+		if config.OnSlow != nil {
+			config.OnSlow(c, latency)
+		}
+
+		requestLogger(c, config.Logger).LogAttrs(c.Req.Context(), config.Level, "Slow request",
+			slog.Int("status", c.StatusCode()),
+			slog.String("method", c.Req.Method),
+			slog.String("path", c.Req.URL.Path),
+			slog.String("route", RoutePattern(c)),
+			slog.Duration("latency", latency),
+			slog.Duration("threshold", threshold),
+			slog.Bool("slow", true),
+		)
+		return err
+	}
+}
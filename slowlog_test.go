@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+func TestSlowLog(t *testing.T) {
+	app := ginji.New()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	var alerts int
+	app.Use(SlowLogWithConfig(SlowLogConfig{
+		Threshold: 20 * time.Millisecond,
+		Routes:    map[string]time.Duration{"/reports/**": time.Hour},
+		Logger:    logger,
+		OnSlow: func(c *ginji.Context, latency time.Duration) {
+			alerts++
+		},
+	}))
+
+	app.Get("/fast", func(c *ginji.Context) error {
+		return c.Text(200, "OK")
+	})
+	app.Get("/slow", func(c *ginji.Context) error {
+		time.Sleep(30 * time.Millisecond)
+		return c.Text(200, "OK")
+	})
+	app.Get("/reports/daily", func(c *ginji.Context) error {
+		time.Sleep(30 * time.Millisecond)
+		return c.Text(200, "OK")
+	})
+
+	ginji.PerformRequest(app, "GET", "/fast", nil)
+	ginji.PerformRequest(app, "GET", "/reports/daily", nil)
+	if buf.Len() > 0 {
+		t.Errorf("Expected no log output for requests under threshold, got %s", buf.String())
+	}
+
+	ginji.PerformRequest(app, "GET", "/slow", nil)
+	out := buf.String()
+	if !strings.Contains(out, "Slow request") || !strings.Contains(out, `"level":"WARN"`) {
+		t.Errorf("Expected slow request warning, got %s", out)
+	}
+	if !strings.Contains(out, `"slow":true`) || !strings.Contains(out, `"threshold"`) {
+		t.Errorf("Expected slow and threshold attributes, got %s", out)
+	}
+	if alerts != 1 {
+		t.Errorf("Expected 1 alert, got %d", alerts)
+	}
+}
+
+func TestSlowLogSkipFunc(t *testing.T) {
+	app := ginji.New()
+
+	var buf bytes.Buffer
+	app.Use(SlowLogWithConfig(SlowLogConfig{
+		Threshold: time.Nanosecond,
+		Logger:    slog.New(slog.NewJSONHandler(&buf, nil)),
+		SkipFunc:  SkipPaths("/stream"),
+	}))
+	app.Get("/stream", func(c *ginji.Context) error {
+		time.Sleep(time.Millisecond)
+		return c.Text(200, "OK")
+	})
+
+	ginji.PerformRequest(app, "GET", "/stream", nil)
+	if buf.Len() > 0 {
+		t.Error("Expected skipped request not to be logged")
+	}
+}
@@ -0,0 +1,174 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// ErrSlowRequest is returned when reading a request body that arrives too
+// slowly.
+var ErrSlowRequest = errors.New("slow request: body read too slowly")
+
+// SlowRequestConfig defines the configuration for slow request middleware.
+type SlowRequestConfig struct {
+	// FirstByteTimeout is the maximum time to wait for the first byte of
+	// the request body.
+	// Default: 10 seconds
+	FirstByteTimeout time.Duration
+
+	// MinRate is the minimum average body transfer rate in bytes per
+	// second, enforced once GracePeriod has passed.
+	// Default: 240
+	MinRate int64
+
+	// GracePeriod is how long a client may send the body before MinRate is
+	// enforced, allowing for TCP slow start.
+	// Default: 5 seconds
+	GracePeriod time.Duration
+
+	// StatusCode is the HTTP status code for slow requests.
+	// Default: 408 Request Timeout
+	StatusCode int
+
+	// ErrorMessage is returned when a request is too slow.
+	// Default: "Request body read timeout"
+	ErrorMessage string
+
+	// SkipFunc allows skipping the guard for certain requests, such as
+	// streaming uploads.
+	SkipFunc Skipper
+}
+
+// DefaultSlowRequestConfig returns default slow request configuration.
+func DefaultSlowRequestConfig() SlowRequestConfig {
+	return SlowRequestConfig{
+		FirstByteTimeout: 10 * time.Second,
+		MinRate:          240,
+		GracePeriod:      5 * time.Second,
+		StatusCode:       http.StatusRequestTimeout,
+		ErrorMessage:     "Request body read timeout",
+	}
+}
+
+// SlowRequest returns middleware that aborts requests whose bodies trickle in,
+// as in slowloris-style attacks. Read deadlines are set on the connection, so
+// a stalled read fails with ErrSlowRequest instead of tying up the handler.
+//
+// Headers are read before any middleware runs; bound them with
+// http.Server.ReadHeaderTimeout.
+func SlowRequest() ginji.Middleware {
+	return SlowRequestWithConfig(DefaultSlowRequestConfig())
+}
+
+// SlowRequestWithConfig returns slow request middleware with custom configuration.
+func SlowRequestWithConfig(config SlowRequestConfig) ginji.Middleware {
+	defaults := DefaultSlowRequestConfig()
+	if config.FirstByteTimeout <= 0 {
+		config.FirstByteTimeout = defaults.FirstByteTimeout
+	}
+	if config.MinRate <= 0 {
+		config.MinRate = defaults.MinRate
+	}
+	if config.GracePeriod <= 0 {
+		config.GracePeriod = defaults.GracePeriod
+	}
+	if config.StatusCode == 0 {
+		config.StatusCode = defaults.StatusCode
+	}
+	if config.ErrorMessage == "" {
+		config.ErrorMessage = defaults.ErrorMessage
+	}
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		if c.Req.Body == nil || c.Req.Body == http.NoBody {
+			return c.Next()
+		}
+
+		rc := http.NewResponseController(baseResponseWriter(c.Res))
+		body := &slowRequestBody{
+			ReadCloser: c.Req.Body,
+			config:     &config,
+			controller: rc,
+			start:      time.Now(),
+		}
+		c.Req.Body = body
+		// Clear the deadline so it does not outlive the request body
+		defer func() { _ = rc.SetReadDeadline(time.Time{}) }()
+
+		// Track whether the handler started a response
+		rw := WrapResponseWriter(c)
+
+		err := c.Next()
+		if !body.slow {
+			return err
+		}
+
+		// The connection is left in an unknown state
+		c.SetHeader("Connection", "close")
+		if !rw.Written() {
+			c.AbortWithStatusJSON(config.StatusCode, ginji.H{
+				"error": config.ErrorMessage,
+			})
+			return nil
+		}
+		return err
+	}
+}
+
+// slowRequestBody enforces the first-byte timeout and minimum rate on reads.
+type slowRequestBody struct {
+	io.ReadCloser
+	config     *SlowRequestConfig
+	controller *http.ResponseController
+	start      time.Time
+	first      time.Time // When the first byte arrived
+	read       int64
+	slow       bool
+}
+
+// Read sets a read deadline by which the next byte must arrive and reads.
+func (b *slowRequestBody) Read(p []byte) (int, error) {
+	if b.slow {
+		return 0, ErrSlowRequest
+	}
+
+	deadline := b.deadline()
+	if time.Now().After(deadline) {
+		b.slow = true
+		return 0, ErrSlowRequest
+	}
+	// Without deadline support, e.g. in tests, only the rate check applies
+	_ = b.controller.SetReadDeadline(deadline)
+
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 && b.read == 0 {
+		b.first = time.Now()
+	}
+	b.read += int64(n)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		b.slow = true
+		return n, ErrSlowRequest
+	}
+	return n, err
+}
+
+// deadline returns the time by which the next byte must arrive: the first
+// byte within FirstByteTimeout, then enough to keep the average since the
+// first byte at MinRate once GracePeriod has passed.
+func (b *slowRequestBody) deadline() time.Time {
+	if b.read == 0 {
+		return b.start.Add(b.config.FirstByteTimeout)
+	}
+	rateDeadline := time.Duration(float64(b.read+1) / float64(b.config.MinRate) * float64(time.Second))
+	return b.first.Add(max(b.config.GracePeriod, rateDeadline))
+}
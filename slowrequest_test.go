@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+func newSlowRequestServer(t *testing.T, config SlowRequestConfig) *httptest.Server {
+	t.Helper()
+	app := ginji.New()
+	app.Use(SlowRequestWithConfig(config))
+	app.Post("/upload", func(c *ginji.Context) error {
+		body, err := io.ReadAll(c.Req.Body)
+		if err != nil {
+			return err
+		}
+		return c.Text(ginji.StatusOK, fmt.Sprintf("read %d bytes", len(body)))
+	})
+	srv := httptest.NewServer(app)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// trickleRequest sends a POST declaring size bytes and writes chunks with
+// delay between them, then returns the response.
+func trickleRequest(t *testing.T, srv *httptest.Server, size int, chunks []string, delay time.Duration) *http.Response {
+	t.Helper()
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	fmt.Fprintf(conn, "POST /upload HTTP/1.1\r\nHost: test\r\nContent-Length: %d\r\n\r\n", size)
+	go func() {
+		for _, chunk := range chunks {
+			time.Sleep(delay)
+			if _, err := io.WriteString(conn, chunk); err != nil {
+				return
+			}
+		}
+	}()
+
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	return resp
+}
+
+func TestSlowRequestNormalBody(t *testing.T) {
+	srv := newSlowRequestServer(t, DefaultSlowRequestConfig())
+
+	resp, err := http.Post(srv.URL+"/upload", "text/plain", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "read 5 bytes" {
+		t.Errorf("Expected 200 'read 5 bytes', got %d %q", resp.StatusCode, body)
+	}
+}
+
+func TestSlowRequestFirstByteTimeout(t *testing.T) {
+	srv := newSlowRequestServer(t, SlowRequestConfig{FirstByteTimeout: 50 * time.Millisecond})
+
+	resp := trickleRequest(t, srv, 10, nil, 0)
+	if resp.StatusCode != http.StatusRequestTimeout {
+		t.Errorf("Expected 408, got %d", resp.StatusCode)
+	}
+	if !resp.Close {
+		t.Error("Expected connection to be closed")
+	}
+}
+
+func TestSlowRequestMinRate(t *testing.T) {
+	srv := newSlowRequestServer(t, SlowRequestConfig{
+		MinRate:     20,
+		GracePeriod: 50 * time.Millisecond,
+	})
+
+	// One byte every 100ms is 10 bytes per second
+	resp := trickleRequest(t, srv, 10, strings.Split("abcdefghij", ""), 100*time.Millisecond)
+	if resp.StatusCode != http.StatusRequestTimeout {
+		t.Errorf("Expected 408, got %d", resp.StatusCode)
+	}
+}
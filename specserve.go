@@ -0,0 +1,224 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ginjigo/ginji"
+)
+
+// SpecServeConfig defines the configuration for SpecServe middleware.
+type SpecServeConfig struct {
+	// Spec is the OpenAPI document, JSON or YAML. Required.
+	Spec []byte
+
+	// SpecPath is the path the document is served at.
+	// Default: "/openapi.json"
+	SpecPath string
+
+	// UIPath is the path of the documentation page.
+	// Default: "/docs"
+	UIPath string
+
+	// UI selects the documentation page: "swagger" for Swagger UI, "redoc"
+	// for Redoc or "none" to only serve the document.
+	// Default: "swagger"
+	UI string
+
+	// UIAssetsURL is the base URL the page loads the UI's script and styles
+	// from. Allow it in the Content-Security-Policy of the page, or host the
+	// files yourself.
+	// Default: "https://unpkg.com/swagger-ui-dist@5" for Swagger UI,
+	// "https://cdn.redoc.ly/redoc/latest/bundles" for Redoc
+	UIAssetsURL string
+
+	// Title is the title of the documentation page.
+	// Default: "API Reference"
+	Title string
+
+	// Auth guards the document and the page, e.g. BasicAuth.
+	// Default: nil (public)
+	Auth ginji.Middleware
+
+	// EnableEnv gates the endpoints like ProfilerConfig.EnableEnv.
+	EnableEnv string
+
+	// SkipFunc allows skipping the middleware for certain requests.
+	SkipFunc Skipper
+}
+
+// DefaultSpecServeConfig returns default SpecServe configuration.
+func DefaultSpecServeConfig() SpecServeConfig {
+	return SpecServeConfig{
+		SpecPath: "/openapi.json",
+		UIPath:   "/docs",
+		UI:       "swagger",
+		Title:    "API Reference",
+	}
+}
+
+// SpecServe returns middleware serving spec and a Swagger UI page.
+//
+//	//go:embed openapi.json
+//	var spec []byte
+//
+//	app.Use(middleware.SpecServe(spec))
+func SpecServe(spec []byte) ginji.Middleware {
+	config := DefaultSpecServeConfig()
+	config.Spec = spec
+	return SpecServeWithConfig(config)
+}
+
+// SpecServeWithConfig returns middleware that serves an OpenAPI document
+// and a documentation page for it. The document is served with an ETag so
+// clients can cache it. It panics if Spec is empty or UI is unknown.
+func SpecServeWithConfig(config SpecServeConfig) ginji.Middleware {
+	defaults := DefaultSpecServeConfig()
+	if len(config.Spec) == 0 {
+		panic("SpecServe: Spec is required")
+	}
+	if config.SpecPath == "" {
+		config.SpecPath = defaults.SpecPath
+	}
+	if config.UIPath == "" {
+		config.UIPath = defaults.UIPath
+	}
+	if config.UI == "" {
+		config.UI = defaults.UI
+	}
+	if config.Title == "" {
+		config.Title = defaults.Title
+	}
+
+	if !envEnabled(config.EnableEnv) {
+		return func(c *ginji.Context) error {
+			return c.Next()
+		}
+	}
+
+	var page []byte
+	switch config.UI {
+	case "swagger", "redoc":
+		page = renderSpecPage(config)
+	case "none":
+	default:
+		panic("SpecServe: unknown UI " + strconv.Quote(config.UI))
+	}
+
+	contentType := "application/yaml"
+	if trimmed := bytes.TrimSpace(config.Spec); len(trimmed) > 0 && trimmed[0] == '{' {
+		contentType = "application/json"
+	}
+	sum := sha256.Sum256(config.Spec)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	serve := func(c *ginji.Context) error {
+		if c.Req.Method != http.MethodGet && c.Req.Method != http.MethodHead {
+			c.SetHeader("Allow", "GET, HEAD")
+			c.AbortWithStatusJSON(ginji.StatusMethodNotAllowed, ginji.H{
+				"error": "Method not allowed",
+			})
+			return nil
+		}
+
+		if c.Req.URL.Path == config.SpecPath {
+			c.SetHeader("ETag", etag)
+			c.SetHeader("Cache-Control", "no-cache")
+			if c.Header("If-None-Match") == etag {
+				c.Res.WriteHeader(ginji.StatusNotModified)
+				c.Abort()
+				return nil
+			}
+			c.SetHeader("Content-Type", contentType)
+			c.Res.WriteHeader(ginji.StatusOK)
+			if c.Req.Method == http.MethodGet {
+				_, _ = c.Res.Write(config.Spec)
+			}
+		} else {
+			c.SetHeader("Content-Type", "text/html; charset=utf-8")
+			c.Res.WriteHeader(ginji.StatusOK)
+			if c.Req.Method == http.MethodGet {
+				_, _ = c.Res.Write(page)
+			}
+		}
+		c.Abort()
+		return nil
+	}
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		path := c.Req.URL.Path
+		if path != config.SpecPath && (page == nil || strings.TrimSuffix(path, "/") != config.UIPath) {
+			return c.Next()
+		}
+		if config.Auth != nil {
+			return ginji.Combine(config.Auth, serve)(c)
+		}
+		return serve(c)
+	}
+}
+
+// specPageTemplates are the documentation pages by UI.
+var specPageTemplates = map[string]*template.Template{
+	"swagger": template.Must(template.New("swagger").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<link rel="stylesheet" href="{{.Assets}}/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="{{.Assets}}/swagger-ui-bundle.js"></script>
+<script>
+window.ui = SwaggerUIBundle({url: {{.SpecURL}}, dom_id: "#swagger-ui"});
+</script>
+</body>
+</html>
+`)),
+	"redoc": template.Must(template.New("redoc").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+</head>
+<body>
+<redoc spec-url="{{.SpecURL}}"></redoc>
+<script src="{{.Assets}}/redoc.standalone.js"></script>
+</body>
+</html>
+`)),
+}
+
+// renderSpecPage renders the documentation page of config.UI.
+func renderSpecPage(config SpecServeConfig) []byte {
+	assets := config.UIAssetsURL
+	if assets == "" {
+		assets = "https://unpkg.com/swagger-ui-dist@5"
+		if config.UI == "redoc" {
+			assets = "https://cdn.redoc.ly/redoc/latest/bundles"
+		}
+	}
+
+	var b bytes.Buffer
+	err := specPageTemplates[config.UI].Execute(&b, struct {
+		Title   string
+		Assets  string
+		SpecURL string
+	}{config.Title, strings.TrimSuffix(assets, "/"), config.SpecPath})
+	if err != nil {
+		panic("SpecServe: " + err.Error())
+	}
+	return b.Bytes()
+}
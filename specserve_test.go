@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ginjigo/ginji"
+)
+
+const testSpec = `{"openapi":"3.1.0","info":{"title":"Pets","version":"1"}}`
+
+func TestSpecServe(t *testing.T) {
+	app := ginji.New()
+	app.Use(SpecServe([]byte(testSpec)))
+
+	w := ginji.PerformRequest(app, "GET", "/openapi.json", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	ginji.AssertHeader(t, w, "Content-Type", "application/json")
+	ginji.AssertBody(t, w, testSpec)
+
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("Expected ETag header")
+	}
+	w = ginji.NewRequest(app, "GET", "/openapi.json").Header("If-None-Match", etag).Do()
+	ginji.AssertStatus(t, w, ginji.StatusNotModified)
+
+	w = ginji.PerformRequest(app, "GET", "/docs/", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	ginji.AssertHeader(t, w, "Content-Type", "text/html; charset=utf-8")
+	ginji.AssertBody(t, w, `<script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js">`)
+	ginji.AssertBody(t, w, `SwaggerUIBundle({url: "/openapi.json"`)
+
+	w = ginji.PerformRequest(app, "POST", "/openapi.json", nil)
+	ginji.AssertStatus(t, w, ginji.StatusMethodNotAllowed)
+}
+
+func TestSpecServeRedoc(t *testing.T) {
+	config := DefaultSpecServeConfig()
+	config.Spec = []byte("openapi: 3.1.0\n")
+	config.SpecPath = "/api/spec.yaml"
+	config.UIPath = "/api/docs"
+	config.UI = "redoc"
+	config.UIAssetsURL = "/static/redoc/"
+	config.Title = "Pets <API>"
+	app := ginji.New()
+	app.Use(SpecServeWithConfig(config))
+	app.Get("/pets", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "pets")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/api/spec.yaml", nil)
+	ginji.AssertHeader(t, w, "Content-Type", "application/yaml")
+
+	w = ginji.PerformRequest(app, "GET", "/api/docs", nil)
+	ginji.AssertBody(t, w, `<redoc spec-url="/api/spec.yaml"></redoc>`)
+	ginji.AssertBody(t, w, `<script src="/static/redoc/redoc.standalone.js">`)
+	ginji.AssertBody(t, w, `<title>Pets &lt;API&gt;</title>`)
+
+	w = ginji.PerformRequest(app, "GET", "/pets", nil)
+	ginji.AssertBody(t, w, "pets")
+}
+
+func TestSpecServeNoUI(t *testing.T) {
+	config := DefaultSpecServeConfig()
+	config.Spec = []byte(testSpec)
+	config.UI = "none"
+	app := ginji.New()
+	app.Use(SpecServeWithConfig(config))
+
+	w := ginji.PerformRequest(app, "GET", "/docs", nil)
+	ginji.AssertStatus(t, w, ginji.StatusNotFound)
+}
+
+func TestSpecServeAuth(t *testing.T) {
+	config := DefaultSpecServeConfig()
+	config.Spec = []byte(testSpec)
+	config.Auth = BasicAuth(map[string]string{"admin": "secret"})
+	app := ginji.New()
+	app.Use(SpecServeWithConfig(config))
+	app.Get("/pets", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "pets")
+	})
+
+	for _, path := range []string{"/openapi.json", "/docs"} {
+		w := ginji.PerformRequest(app, "GET", path, nil)
+		ginji.AssertStatus(t, w, ginji.StatusUnauthorized)
+	}
+
+	w := ginji.NewRequest(app, "GET", "/openapi.json").Header("Authorization", "Basic YWRtaW46c2VjcmV0").Do()
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+
+	w = ginji.PerformRequest(app, "GET", "/pets", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+}
+
+func TestSpecServeEnableEnv(t *testing.T) {
+	config := DefaultSpecServeConfig()
+	config.Spec = []byte(testSpec)
+	config.EnableEnv = "TEST_SPEC_SERVE"
+
+	t.Setenv("TEST_SPEC_SERVE", "")
+	app := ginji.New()
+	app.Use(SpecServeWithConfig(config))
+	w := ginji.PerformRequest(app, "GET", "/openapi.json", nil)
+	ginji.AssertStatus(t, w, ginji.StatusNotFound)
+
+	t.Setenv("TEST_SPEC_SERVE", "true")
+	app = ginji.New()
+	app.Use(SpecServeWithConfig(config))
+	w = ginji.PerformRequest(app, "GET", "/openapi.json", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+}
+
+func TestSpecServeInvalidConfig(t *testing.T) {
+	for _, config := range []SpecServeConfig{{}, {Spec: []byte(testSpec), UI: "graphiql"}} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil || !strings.HasPrefix(r.(string), "SpecServe:") {
+					t.Errorf("Expected SpecServe panic, got %v", r)
+				}
+			}()
+			SpecServeWithConfig(config)
+		}()
+	}
+}
@@ -0,0 +1,296 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// SSEConfig defines the configuration for SSE middleware.
+type SSEConfig struct {
+	// Retry is the reconnection delay advertised to clients.
+	// Default: 0 (browser default)
+	Retry time.Duration
+
+	// SkipFunc allows skipping the middleware for certain requests.
+	SkipFunc Skipper
+}
+
+// SSE returns middleware that prepares a route for Server-Sent Events.
+// It sets the event stream headers, disables proxy buffering and clears
+// the server write deadline so long-lived streams are not cut off.
+func SSE() ginji.Middleware {
+	return SSEWithConfig(SSEConfig{})
+}
+
+// SSEWithConfig returns SSE middleware with custom configuration.
+func SSEWithConfig(config SSEConfig) ginji.Middleware {
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		prepareEventStream(c)
+		if config.Retry > 0 {
+			_, _ = fmt.Fprintf(c.Res, "retry: %d\n\n", config.Retry.Milliseconds())
+			_ = flushResponse(c.Res)
+		}
+
+		return c.Next()
+	}
+}
+
+// prepareEventStream sets the SSE response headers and marks the request
+// as streaming so buffering middleware leave it alone.
+func prepareEventStream(c *ginji.Context) {
+	setContextValue(c, eventStreamContextKey, true)
+
+	h := c.Res.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	h.Set("X-Accel-Buffering", "no") // Disable nginx buffering
+	h.Del("Content-Length")
+
+	_ = http.NewResponseController(baseResponseWriter(c.Res)).SetWriteDeadline(time.Time{})
+
+	// Streams have no meaningful total size
+	if rw, ok := c.Res.(*ResponseWriter); ok {
+		rw.Limit(0)
+	}
+}
+
+// isEventStream reports whether SSE middleware prepared the response as an
+// event stream. The Accept header is not trusted, since any client can send
+// it to escape buffering middleware.
+func isEventStream(c *ginji.Context) bool {
+	streaming, _ := c.Req.Context().Value(eventStreamContextKey).(bool)
+	return streaming
+}
+
+var (
+	// sseLineBreaks removes line breaks from single-line fields, which
+	// would otherwise let a value inject fields or events
+	sseLineBreaks = strings.NewReplacer("\r", "", "\n", "")
+
+	// sseNewlines normalizes the line endings the format accepts to "\n"
+	sseNewlines = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+)
+
+// writeSSEEvent writes a single event in the text/event-stream format.
+// Line breaks are removed from the ID and event type, and every line of
+// the data is sent as its own data field.
+func writeSSEEvent(w http.ResponseWriter, event ginji.SSEEvent) error {
+	var sb strings.Builder
+	if event.ID != "" {
+		sb.WriteString("id: " + sseLineBreaks.Replace(event.ID) + "\n")
+	}
+	if event.Event != "" {
+		sb.WriteString("event: " + sseLineBreaks.Replace(event.Event) + "\n")
+	}
+	if event.Retry > 0 {
+		sb.WriteString("retry: " + strconv.Itoa(event.Retry) + "\n")
+	}
+	for _, line := range strings.Split(sseNewlines.Replace(event.Data), "\n") {
+		sb.WriteString("data: " + line + "\n")
+	}
+	sb.WriteString("\n")
+
+	if _, err := w.Write([]byte(sb.String())); err != nil {
+		return err
+	}
+	return flushResponse(w)
+}
+
+// SSEBrokerConfig defines the configuration for an SSEBroker.
+type SSEBrokerConfig struct {
+	// Heartbeat is the interval between keep-alive comments.
+	// Default: 15 seconds
+	Heartbeat time.Duration
+
+	// BufferSize is the number of events queued per client. Events for
+	// clients whose queue is full are dropped.
+	// Default: 16
+	BufferSize int
+
+	// History is the number of recent events kept for clients that
+	// reconnect with a Last-Event-ID header.
+	// Default: 100
+	History int
+}
+
+// DefaultSSEBrokerConfig returns default SSE broker configuration.
+func DefaultSSEBrokerConfig() SSEBrokerConfig {
+	return SSEBrokerConfig{
+		Heartbeat:  15 * time.Second,
+		BufferSize: 16,
+		History:    100,
+	}
+}
+
+// SSEBroker fans published events out to connected SSE clients.
+type SSEBroker struct {
+	config  SSEBrokerConfig
+	mu      sync.Mutex
+	clients map[chan ginji.SSEEvent]struct{}
+	history []ginji.SSEEvent
+	nextID  uint64
+	done    chan struct{}
+	closed  bool
+}
+
+// NewSSEBroker creates a broker with the given configuration.
+func NewSSEBroker(config SSEBrokerConfig) *SSEBroker {
+	defaults := DefaultSSEBrokerConfig()
+	if config.Heartbeat == 0 {
+		config.Heartbeat = defaults.Heartbeat
+	}
+	if config.BufferSize == 0 {
+		config.BufferSize = defaults.BufferSize
+	}
+	if config.History == 0 {
+		config.History = defaults.History
+	}
+
+	return &SSEBroker{
+		config:  config,
+		clients: make(map[chan ginji.SSEEvent]struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// Publish sends event to all connected clients. Events without an ID are
+// assigned a sequential one so clients can resume after reconnecting.
+func (b *SSEBroker) Publish(event ginji.SSEEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	b.nextID++
+	// Store the ID as clients receive it, so Last-Event-ID matches
+	event.ID = sseLineBreaks.Replace(event.ID)
+	if event.ID == "" {
+		event.ID = strconv.FormatUint(b.nextID, 10)
+	}
+
+	if b.config.History > 0 {
+		b.history = append(b.history, event)
+		if len(b.history) > b.config.History {
+			b.history = b.history[len(b.history)-b.config.History:]
+		}
+	}
+
+	for ch := range b.clients {
+		select {
+		case ch <- event:
+		default:
+			// Client is too slow, drop the event
+		}
+	}
+}
+
+// Clients returns the number of connected clients.
+func (b *SSEBroker) Clients() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.clients)
+}
+
+// HealthCheck implements HealthReporter. A closed broker is unhealthy.
+func (b *SSEBroker) HealthCheck() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return errors.New("sse: broker is closed")
+	}
+	return nil
+}
+
+// Close disconnects all clients and stops accepting events.
+func (b *SSEBroker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.closed {
+		b.closed = true
+		close(b.done)
+	}
+}
+
+// subscribe registers a client and returns the events it missed since lastID.
+func (b *SSEBroker) subscribe(lastID string) (chan ginji.SSEEvent, []ginji.SSEEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan ginji.SSEEvent, b.config.BufferSize)
+	b.clients[ch] = struct{}{}
+
+	var missed []ginji.SSEEvent
+	if lastID != "" {
+		for i, event := range b.history {
+			if event.ID == lastID {
+				missed = append(missed, b.history[i+1:]...)
+				break
+			}
+		}
+	}
+	return ch, missed
+}
+
+// unsubscribe removes a client.
+func (b *SSEBroker) unsubscribe(ch chan ginji.SSEEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.clients, ch)
+}
+
+// Serve streams events to the client until it disconnects or the broker
+// is closed. It can be used directly as a ginji handler.
+func (b *SSEBroker) Serve(c *ginji.Context) error {
+	prepareEventStream(c)
+	c.Res.WriteHeader(http.StatusOK)
+	if err := flushResponse(c.Res); err != nil {
+		return err
+	}
+
+	ch, missed := b.subscribe(c.Header("Last-Event-ID"))
+	defer b.unsubscribe(ch)
+
+	for _, event := range missed {
+		if err := writeSSEEvent(c.Res, event); err != nil {
+			return nil
+		}
+	}
+
+	heartbeat := time.NewTicker(b.config.Heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case event := <-ch:
+			if err := writeSSEEvent(c.Res, event); err != nil {
+				return nil
+			}
+		case <-heartbeat.C:
+			if _, err := c.Res.Write([]byte(": keep-alive\n\n")); err != nil {
+				return nil
+			}
+			if err := flushResponse(c.Res); err != nil {
+				return nil
+			}
+		case <-c.Req.Context().Done():
+			return nil
+		case <-b.done:
+			return nil
+		}
+	}
+}
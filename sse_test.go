@@ -0,0 +1,147 @@
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// readSSEData reads the stream until n data lines have been received.
+func readSSEData(t *testing.T, r *bufio.Reader, n int) []string {
+	t.Helper()
+	var data []string
+	for len(data) < n {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read stream: %v", err)
+		}
+		if strings.HasPrefix(line, "data: ") {
+			data = append(data, strings.TrimSpace(strings.TrimPrefix(line, "data: ")))
+		}
+	}
+	return data
+}
+
+func waitForClients(t *testing.T, b *SSEBroker, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for b.Clients() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d clients, got %d", n, b.Clients())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSSEBrokerStreamsEvents(t *testing.T) {
+	broker := NewSSEBroker(DefaultSSEBrokerConfig())
+	defer broker.Close()
+
+	app := ginji.New()
+	app.Use(Timeout(50 * time.Millisecond))
+	app.Get("/events", broker.Serve)
+
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+"/events", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Expected text/event-stream, got %s", ct)
+	}
+
+	waitForClients(t, broker, 1)
+
+	// Events stream through the timeout buffer as they are published
+	broker.Publish(ginji.SSEEvent{Data: "hello"})
+	broker.Publish(ginji.SSEEvent{Event: "update", Data: "world"})
+
+	data := readSSEData(t, bufio.NewReader(resp.Body), 2)
+	if data[0] != "hello" || data[1] != "world" {
+		t.Errorf("Unexpected events: %v", data)
+	}
+
+	// The stream still ends at the deadline
+	waitForClients(t, broker, 0)
+}
+
+func TestSSEBrokerReplaysAfterLastEventID(t *testing.T) {
+	broker := NewSSEBroker(DefaultSSEBrokerConfig())
+	defer broker.Close()
+
+	for _, d := range []string{"one", "two", "three"} {
+		broker.Publish(ginji.SSEEvent{Data: d})
+	}
+
+	app := ginji.New()
+	app.Get("/events", broker.Serve)
+
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+"/events", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	data := readSSEData(t, bufio.NewReader(resp.Body), 2)
+	if data[0] != "two" || data[1] != "three" {
+		t.Errorf("Expected missed events to be replayed, got %v", data)
+	}
+}
+
+func TestSSEMiddlewareHeaders(t *testing.T) {
+	app := ginji.New()
+	app.Use(SSE())
+	app.Get("/stream", func(c *ginji.Context) error {
+		return writeSSEEvent(c.Res, ginji.SSEEvent{Data: "ping"})
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/stream", nil)
+
+	ginji.AssertHeader(t, w, "Content-Type", "text/event-stream")
+	ginji.AssertHeader(t, w, "Cache-Control", "no-cache")
+	ginji.AssertHeader(t, w, "X-Accel-Buffering", "no")
+	if !w.Flushed {
+		t.Error("Expected response to be flushed")
+	}
+	ginji.AssertBody(t, w, "data: ping\n\n")
+}
+
+func TestSSEEventLineBreaks(t *testing.T) {
+	w := httptest.NewRecorder()
+	err := writeSSEEvent(w, ginji.SSEEvent{
+		ID:    "1\nevent: admin",
+		Event: "update\r\ndata: forged",
+		Data:  "a\r\nb\rc\nd",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "id: 1event: admin\nevent: updatedata: forged\ndata: a\ndata: b\ndata: c\ndata: d\n\n"
+	if got := w.Body.String(); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,243 @@
+package middleware
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StatsDConfig defines the configuration for a StatsD metrics sink.
+type StatsDConfig struct {
+	// Addr is the UDP address of the StatsD or DogStatsD agent.
+	// Default: "127.0.0.1:8125"
+	Addr string
+
+	// Prefix is prepended to every metric name, e.g. "myapp.".
+	Prefix string
+
+	// Tags enables DogStatsD tags. Labels are sent as "|#name:value" tags;
+	// without tags their values are appended to the metric name instead.
+	Tags bool
+
+	// GlobalTags are added to every metric when Tags is enabled, e.g.
+	// "env:prod".
+	GlobalTags []string
+
+	// FlushInterval is how often buffered metrics are sent.
+	// Default: 1 second
+	FlushInterval time.Duration
+
+	// MaxPacketSize is the largest UDP payload sent.
+	// Default: 1432 bytes
+	MaxPacketSize int
+}
+
+// DefaultStatsDConfig returns default StatsD configuration.
+func DefaultStatsDConfig() StatsDConfig {
+	return StatsDConfig{
+		Addr:          "127.0.0.1:8125",
+		FlushInterval: time.Second,
+		MaxPacketSize: 1432,
+	}
+}
+
+// StatsDSink is a MetricsSink that sends metrics to StatsD or DogStatsD
+// over UDP. Lines are batched into packets of up to MaxPacketSize bytes.
+//
+//	sink, err := middleware.NewStatsDSink(middleware.StatsDConfig{Tags: true})
+//	metrics := middleware.NewMetrics(middleware.MetricsConfig{Sinks: []middleware.MetricsSink{sink}})
+type StatsDSink struct {
+	config    StatsDConfig
+	conn      net.Conn
+	mu        sync.Mutex
+	buf       []byte
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewStatsDSink creates a StatsD sink. It returns an error if Addr cannot
+// be resolved.
+func NewStatsDSink(config StatsDConfig) (*StatsDSink, error) {
+	defaults := DefaultStatsDConfig()
+	if config.Addr == "" {
+		config.Addr = defaults.Addr
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaults.FlushInterval
+	}
+	if config.MaxPacketSize <= 0 {
+		config.MaxPacketSize = defaults.MaxPacketSize
+	}
+
+	conn, err := net.Dial("udp", config.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &StatsDSink{
+		config: config,
+		conn:   conn,
+		buf:    make([]byte, 0, config.MaxPacketSize),
+		done:   make(chan struct{}),
+	}
+	go s.flushLoop()
+	return s, nil
+}
+
+// Count implements MetricsSink.
+func (s *StatsDSink) Count(name string, delta float64, labels []string) {
+	s.write(name, formatFloat(delta), "c", labels)
+}
+
+// Gauge implements MetricsSink.
+func (s *StatsDSink) Gauge(name string, value float64, labels []string) {
+	// A leading sign makes StatsD treat the value as a delta
+	if value < 0 {
+		s.write(name, "0", "g", labels)
+	}
+	s.write(name, formatFloat(value), "g", labels)
+}
+
+// Histogram implements MetricsSink. Durations in seconds are sent as
+// millisecond timers.
+func (s *StatsDSink) Histogram(name string, value float64, labels []string) {
+	if strings.HasSuffix(name, "_seconds") {
+		s.write(strings.TrimSuffix(name, "_seconds")+"_ms", formatFloat(value*1000), "ms", labels)
+		return
+	}
+	typ := "ms"
+	if s.config.Tags {
+		typ = "h"
+	}
+	s.write(name, formatFloat(value), typ, labels)
+}
+
+// Flush sends buffered metrics immediately.
+func (s *StatsDSink) Flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flushLocked()
+}
+
+// HealthCheck implements HealthReporter. A closed sink is unhealthy.
+// Delivery is not checked since UDP has no acknowledgement.
+func (s *StatsDSink) HealthCheck() error {
+	select {
+	case <-s.done:
+		return errors.New("statsd: sink is closed")
+	default:
+		return nil
+	}
+}
+
+// Close flushes buffered metrics and closes the connection.
+func (s *StatsDSink) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.Flush()
+		err = s.conn.Close()
+	})
+	return err
+}
+
+// write formats one line and appends it to the packet buffer.
+func (s *StatsDSink) write(name, value, typ string, labels []string) {
+	line := s.format(name, value, typ, labels)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.buf) > 0 && len(s.buf)+1+len(line) > s.config.MaxPacketSize {
+		s.flushLocked()
+	}
+	if len(s.buf) > 0 {
+		s.buf = append(s.buf, '\n')
+	}
+	s.buf = append(s.buf, line...)
+}
+
+// format renders a StatsD line.
+func (s *StatsDSink) format(name, value, typ string, labels []string) string {
+	var sb strings.Builder
+	sb.WriteString(s.config.Prefix)
+	sb.WriteString(sanitizeStatsDName(name))
+	if !s.config.Tags {
+		for i := 1; i < len(labels); i += 2 {
+			sb.WriteString("." + sanitizeStatsDName(labels[i]))
+		}
+	}
+	sb.WriteString(":" + value + "|" + typ)
+
+	if s.config.Tags && (len(labels) > 1 || len(s.config.GlobalTags) > 0) {
+		sb.WriteString("|#")
+		first := true
+		for _, tag := range s.config.GlobalTags {
+			if !first {
+				sb.WriteByte(',')
+			}
+			sb.WriteString(tag)
+			first = false
+		}
+		for i := 0; i+1 < len(labels); i += 2 {
+			if !first {
+				sb.WriteByte(',')
+			}
+			sb.WriteString(labels[i] + ":" + sanitizeStatsDTag(labels[i+1]))
+			first = false
+		}
+	}
+	return sb.String()
+}
+
+// flushLocked sends the buffered packet.
+func (s *StatsDSink) flushLocked() {
+	if len(s.buf) == 0 {
+		return
+	}
+	// UDP is fire-and-forget; a missing agent must not affect requests
+	_, _ = s.conn.Write(s.buf)
+	s.buf = s.buf[:0]
+}
+
+// flushLoop flushes the buffer every FlushInterval until closed.
+func (s *StatsDSink) flushLoop() {
+	ticker := time.NewTicker(s.config.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.Flush()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// sanitizeStatsDName replaces characters that StatsD uses as separators.
+func sanitizeStatsDName(name string) string {
+	name = strings.Trim(name, "/")
+	if name == "" {
+		return "root"
+	}
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ':', '|', '@', '/', ' ', '\n':
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+// sanitizeStatsDTag replaces characters that end a DogStatsD tag.
+func sanitizeStatsDTag(value string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ',', '|', '#', '\n':
+			return '_'
+		}
+		return r
+	}, value)
+}
@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// listenStatsD starts a UDP listener and returns its address and a
+// function reading the next packet.
+func listenStatsD(t *testing.T) (string, func() string) {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return conn.LocalAddr().String(), func() string {
+		buf := make([]byte, 65536)
+		_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("Expected a StatsD packet: %v", err)
+		}
+		return string(buf[:n])
+	}
+}
+
+func TestStatsDSinkDogStatsD(t *testing.T) {
+	addr, read := listenStatsD(t)
+	sink, err := NewStatsDSink(StatsDConfig{Addr: addr, Prefix: "app.", Tags: true, GlobalTags: []string{"env:test"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = sink.Close() }()
+
+	m := NewMetrics(MetricsConfig{Sinks: []MetricsSink{sink}})
+	app := ginji.New()
+	app.Use(m.Middleware())
+	app.Get("/metrics", m.Handler())
+	app.Get("/users", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "users")
+	})
+	ginji.PerformRequest(app, "GET", "/users", nil)
+	sink.Flush()
+
+	packet := read()
+	for _, want := range []string{
+		"app.http_requests_in_flight:1|g|#env:test",
+		"app.http_requests_in_flight:0|g|#env:test",
+		"app.http_requests_total:1|c|#env:test,method:GET,route:/users,status:200",
+		"app.http_request_duration_ms:",
+		"|ms|#env:test,method:GET,route:/users",
+	} {
+		if !strings.Contains(packet, want) {
+			t.Errorf("Expected packet to contain %q, got:\n%s", want, packet)
+		}
+	}
+}
+
+func TestStatsDSinkPlain(t *testing.T) {
+	addr, read := listenStatsD(t)
+	sink, err := NewStatsDSink(StatsDConfig{Addr: addr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = sink.Close() }()
+
+	sink.Count("http_requests_total", 1, []string{"path", "/api/users", "status", "200"})
+	sink.Gauge("queue_depth", -2, nil)
+	sink.Histogram("payload_size", 12, nil)
+	sink.Flush()
+
+	lines := strings.Split(read(), "\n")
+	want := []string{
+		"http_requests_total.api_users.200:1|c",
+		"queue_depth:0|g",
+		"queue_depth:-2|g",
+		"payload_size:12|ms",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected lines %q, got %q", want, lines)
+	}
+}
+
+func TestStatsDSinkPacketSize(t *testing.T) {
+	addr, read := listenStatsD(t)
+	sink, err := NewStatsDSink(StatsDConfig{Addr: addr, MaxPacketSize: 40})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = sink.Close() }()
+
+	sink.Count("first_metric_name", 1, nil)
+	sink.Count("second_metric_name", 1, nil)
+
+	// The second line does not fit and flushes the first
+	if got := read(); got != "first_metric_name:1|c" {
+		t.Errorf("Expected first packet to hold one line, got %q", got)
+	}
+	sink.Flush()
+	if got := read(); got != "second_metric_name:1|c" {
+		t.Errorf("Expected second packet, got %q", got)
+	}
+}
@@ -0,0 +1,280 @@
+package middleware
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// StepUpConfig defines the configuration for step-up authentication.
+type StepUpConfig struct {
+	// Level is the authentication level required on every route not
+	// matched by Routes. Zero lets unmatched routes through.
+	// Default: 0
+	Level int
+
+	// MaxAge requires the authentication at that level to be this recent.
+	// Default: 0 (any age)
+	MaxAge time.Duration
+
+	// Methods are authentication methods that must all be present, e.g.
+	// "otp" or "hwk" (RFC 8176).
+	// Default: nil
+	Methods []string
+
+	// Routes are requirements keyed by a path glob pattern (see PathGlob).
+	// The longest matching pattern wins.
+	Routes map[string]StepUpRequirement
+
+	// LevelFunc returns the authentication state of the request.
+	// Default: DefaultAuthLevel, reading token claims then the session
+	LevelFunc func(*ginji.Context) (AuthLevel, bool)
+
+	// ChallengeURL is included in the challenge so clients know where to
+	// step up, e.g. "/auth/2fa".
+	// Default: "" (not sent)
+	ChallengeURL string
+
+	// Realm for the WWW-Authenticate header.
+	// Default: "Authorization Required"
+	Realm string
+
+	// SkipFunc allows skipping the check for certain requests.
+	SkipFunc Skipper
+}
+
+// StepUpRequirement is the authentication required on a route.
+type StepUpRequirement struct {
+	// Level is the minimum authentication level. Zero requires none.
+	Level int
+
+	// MaxAge requires the authentication to be this recent.
+	MaxAge time.Duration
+
+	// Methods must all be among the authentication methods used.
+	Methods []string
+}
+
+// AuthLevel is how strongly a request was authenticated.
+type AuthLevel struct {
+	// Level is 1 for a single factor and 2 or more for multi-factor.
+	Level int
+
+	// Methods are the authentication methods used (the "amr" claim).
+	Methods []string
+
+	// Time is when the user last authenticated at Level.
+	Time time.Time
+}
+
+// Session keys used by SetAuthLevel and DefaultAuthLevel.
+const (
+	authLevelSessionKey   = "auth_level"
+	authMethodsSessionKey = "auth_amr"
+	authTimeSessionKey    = "auth_time"
+)
+
+// multiFactorMethods are RFC 8176 methods that count as a second factor.
+var multiFactorMethods = []string{"mfa", "otp", "hwk", "sms", "tel", "fpt", "face", "iris", "retina", "vbm", "pop"}
+
+// DefaultStepUpConfig returns default step-up configuration.
+func DefaultStepUpConfig() StepUpConfig {
+	return StepUpConfig{
+		LevelFunc: DefaultAuthLevel,
+		Realm:     "Authorization Required",
+	}
+}
+
+// StepUp returns middleware requiring at least the given authentication
+// level on every request.
+func StepUp(level int) ginji.Middleware {
+	config := DefaultStepUpConfig()
+	config.Level = level
+	return StepUpWithConfig(config)
+}
+
+// StepUpWithConfig returns middleware that challenges requests whose
+// authentication is weaker, older or uses other methods than the route
+// requires. The challenge is a 401 with an RFC 9470 WWW-Authenticate header
+// and a JSON body telling the client what to do:
+//
+//	{"error": "Step-up authentication required", "required_level": 2, "current_level": 1}
+//
+// It panics if neither Level nor Routes is set.
+func StepUpWithConfig(config StepUpConfig) ginji.Middleware {
+	defaults := DefaultStepUpConfig()
+	if config.Level <= 0 && len(config.Routes) == 0 {
+		panic("StepUp: Level or Routes is required")
+	}
+	if config.LevelFunc == nil {
+		config.LevelFunc = defaults.LevelFunc
+	}
+	if config.Realm == "" {
+		config.Realm = defaults.Realm
+	}
+
+	routes := compilePathOverrides(config.Routes)
+	fallback := StepUpRequirement{Level: config.Level, MaxAge: config.MaxAge, Methods: config.Methods}
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		required := lookupPathOverride(c, routes, fallback)
+		if required.Level <= 0 && len(required.Methods) == 0 {
+			return c.Next()
+		}
+
+		current, _ := config.LevelFunc(c)
+		if satisfiesStepUp(current, required, time.Now()) {
+			return c.Next()
+		}
+
+		stepUpChallenge(c, config, required, current)
+		return nil
+	}
+}
+
+// satisfiesStepUp reports whether current meets required at now.
+func satisfiesStepUp(current AuthLevel, required StepUpRequirement, now time.Time) bool {
+	if current.Level < required.Level {
+		return false
+	}
+	if required.MaxAge > 0 && (current.Time.IsZero() || now.Sub(current.Time) > required.MaxAge) {
+		return false
+	}
+	for _, method := range required.Methods {
+		if !slices.Contains(current.Methods, method) {
+			return false
+		}
+	}
+	return true
+}
+
+// stepUpChallenge sends the step-up challenge.
+func stepUpChallenge(c *ginji.Context, config StepUpConfig, required StepUpRequirement, current AuthLevel) {
+	challenge := fmt.Sprintf(`Bearer realm=%q, error="insufficient_user_authentication", error_description="A different authentication level is required", acr_values="%d"`,
+		config.Realm, required.Level)
+	if required.MaxAge > 0 {
+		challenge += ", max_age=" + strconv.FormatInt(int64(required.MaxAge.Seconds()), 10)
+	}
+	c.SetHeader("WWW-Authenticate", challenge)
+
+	body := ginji.H{
+		"error":          "Step-up authentication required",
+		"required_level": required.Level,
+		"current_level":  current.Level,
+	}
+	if required.MaxAge > 0 {
+		body["max_age"] = int64(required.MaxAge.Seconds())
+	}
+	if len(required.Methods) > 0 {
+		body["required_methods"] = required.Methods
+	}
+	if config.ChallengeURL != "" {
+		body["challenge_url"] = config.ChallengeURL
+	}
+	c.AbortWithStatusJSON(ginji.StatusUnauthorized, body)
+}
+
+// SetAuthLevel records in the session that the user authenticated at level
+// with the given methods, e.g. after verifying a one-time password:
+//
+//	middleware.SetAuthLevel(c, 2, "pwd", "otp")
+func SetAuthLevel(c *ginji.Context, level int, methods ...string) error {
+	session, ok := SessionFrom(c)
+	if !ok {
+		return ErrNoSession
+	}
+	session.Set(authLevelSessionKey, level)
+	session.Set(authMethodsSessionKey, methods)
+	session.Set(authTimeSessionKey, time.Now().Unix())
+	return nil
+}
+
+// DefaultAuthLevel reads the authentication level from verified token
+// claims ("auth_level" or a numeric "acr", "amr" and "auth_time"), falling
+// back to the session keys written by SetAuthLevel. Without an explicit
+// level, a user is at level 1, or 2 if the methods include a second factor.
+func DefaultAuthLevel(c *ginji.Context) (AuthLevel, bool) {
+	if claims, ok := ClaimsFrom(c); ok {
+		level, ok := claimInt(claims["auth_level"])
+		if !ok {
+			level, ok = claimInt(claims["acr"])
+		}
+		auth := AuthLevel{Level: level, Methods: claimStrings(claims["amr"])}
+		auth.Time, _ = sessionTime(claims["auth_time"])
+		if !ok {
+			auth.Level = levelFromMethods(auth.Methods)
+		}
+		return auth, true
+	}
+
+	if session, ok := SessionFrom(c); ok {
+		if level, ok := claimInt(session.Get(authLevelSessionKey)); ok {
+			auth := AuthLevel{Level: level, Methods: claimStrings(session.Get(authMethodsSessionKey))}
+			auth.Time, _ = sessionTime(session.Get(authTimeSessionKey))
+			return auth, true
+		}
+	}
+
+	if _, ok := UserFrom(c); ok {
+		return AuthLevel{Level: 1}, true
+	}
+	return AuthLevel{}, false
+}
+
+// levelFromMethods derives an authentication level from "amr" methods.
+func levelFromMethods(methods []string) int {
+	if len(methods) == 0 {
+		return 1
+	}
+	for _, method := range methods {
+		if slices.Contains(multiFactorMethods, method) {
+			return 2
+		}
+	}
+	return 1
+}
+
+// claimInt converts a numeric claim or session value to an int.
+func claimInt(v any) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int64:
+		return int(n), true
+	case float64:
+		return int(n), true
+	case string:
+		i, err := strconv.Atoi(strings.TrimSpace(n))
+		return i, err == nil
+	}
+	return 0, false
+}
+
+// claimStrings converts a string list claim, which may have been decoded
+// from JSON as []any, or a space separated string.
+func claimStrings(v any) []string {
+	switch s := v.(type) {
+	case []string:
+		return s
+	case []any:
+		out := make([]string, 0, len(s))
+		for _, item := range s {
+			if str, ok := item.(string); ok {
+				out = append(out, str)
+			}
+		}
+		return out
+	case string:
+		return strings.Fields(s)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package middleware
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+func TestStepUpLevel(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims map[string]any
+		want   int
+	}{
+		{"password only", map[string]any{"amr": []any{"pwd"}}, ginji.StatusUnauthorized},
+		{"with otp", map[string]any{"amr": []any{"pwd", "otp"}}, ginji.StatusOK},
+		{"explicit level", map[string]any{"auth_level": float64(2)}, ginji.StatusOK},
+		{"numeric acr", map[string]any{"acr": "1"}, ginji.StatusUnauthorized},
+		{"no claims", nil, ginji.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := ginji.New()
+			app.Use(func(c *ginji.Context) error {
+				if tt.claims != nil {
+					SetClaims(c, tt.claims)
+				}
+				return c.Next()
+			})
+			app.Use(StepUp(2))
+			app.Get("/account", func(c *ginji.Context) error {
+				return c.Text(ginji.StatusOK, "account")
+			})
+			ginji.AssertStatus(t, ginji.PerformRequest(app, "GET", "/account", nil), tt.want)
+		})
+	}
+}
+
+func TestStepUpChallenge(t *testing.T) {
+	config := DefaultStepUpConfig()
+	config.Level = 2
+	config.MaxAge = 5 * time.Minute
+	config.ChallengeURL = "/auth/2fa"
+	app := ginji.New()
+	app.Use(func(c *ginji.Context) error {
+		SetClaims(c, map[string]any{"amr": []any{"pwd"}})
+		return c.Next()
+	})
+	app.Use(StepUpWithConfig(config))
+	app.Get("/account", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "account")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/account", nil)
+	ginji.AssertStatus(t, w, ginji.StatusUnauthorized)
+	ginji.AssertBody(t, w, `"required_level":2`)
+	ginji.AssertBody(t, w, `"current_level":1`)
+	ginji.AssertBody(t, w, `"challenge_url":"/auth/2fa"`)
+
+	challenge := w.Header().Get("WWW-Authenticate")
+	for _, want := range []string{`error="insufficient_user_authentication"`, `acr_values="2"`, "max_age=300"} {
+		if !strings.Contains(challenge, want) {
+			t.Errorf("Expected challenge to contain %s, got %q", want, challenge)
+		}
+	}
+}
+
+func TestStepUpMaxAge(t *testing.T) {
+	config := DefaultStepUpConfig()
+	config.Level = 2
+	config.MaxAge = 5 * time.Minute
+	var authTime time.Time
+	app := ginji.New()
+	app.Use(func(c *ginji.Context) error {
+		SetClaims(c, map[string]any{"amr": []any{"otp"}, "auth_time": float64(authTime.Unix())})
+		return c.Next()
+	})
+	app.Use(StepUpWithConfig(config))
+	app.Get("/account", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "account")
+	})
+
+	authTime = time.Now().Add(-time.Minute)
+	ginji.AssertStatus(t, ginji.PerformRequest(app, "GET", "/account", nil), ginji.StatusOK)
+
+	authTime = time.Now().Add(-time.Hour)
+	ginji.AssertStatus(t, ginji.PerformRequest(app, "GET", "/account", nil), ginji.StatusUnauthorized)
+}
+
+func TestStepUpRoutes(t *testing.T) {
+	config := DefaultStepUpConfig()
+	config.Routes = map[string]StepUpRequirement{
+		"/admin/*": {Level: 2, Methods: []string{"hwk"}},
+	}
+	app := ginji.New()
+	app.Use(func(c *ginji.Context) error {
+		SetClaims(c, map[string]any{"amr": []any{"pwd", "otp"}})
+		return c.Next()
+	})
+	app.Use(StepUpWithConfig(config))
+	app.Get("/account", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "account")
+	})
+	app.Get("/admin/keys", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "keys")
+	})
+
+	ginji.AssertStatus(t, ginji.PerformRequest(app, "GET", "/account", nil), ginji.StatusOK)
+
+	w := ginji.PerformRequest(app, "GET", "/admin/keys", nil)
+	ginji.AssertStatus(t, w, ginji.StatusUnauthorized)
+	ginji.AssertBody(t, w, `"required_methods":["hwk"]`)
+}
+
+func TestStepUpSession(t *testing.T) {
+	session := mapSession{}
+	app := ginji.New()
+	app.Use(func(c *ginji.Context) error {
+		SetSession(c, session)
+		return c.Next()
+	})
+	app.Post("/auth/2fa", func(c *ginji.Context) error {
+		if err := SetAuthLevel(c, 2, "pwd", "otp"); err != nil {
+			return err
+		}
+		return c.Text(ginji.StatusOK, "verified")
+	})
+
+	protected := ginji.New()
+	protected.Use(func(c *ginji.Context) error {
+		SetSession(c, session)
+		return c.Next()
+	})
+	protected.Use(StepUp(2))
+	protected.Get("/account", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "account")
+	})
+
+	ginji.AssertStatus(t, ginji.PerformRequest(protected, "GET", "/account", nil), ginji.StatusUnauthorized)
+	ginji.AssertStatus(t, ginji.PerformRequest(app, "POST", "/auth/2fa", nil), ginji.StatusOK)
+	ginji.AssertStatus(t, ginji.PerformRequest(protected, "GET", "/account", nil), ginji.StatusOK)
+}
+
+func TestStepUpSessionUserWithoutLevel(t *testing.T) {
+	app := ginji.New()
+	app.Use(func(c *ginji.Context) error {
+		SetSession(c, mapSession{})
+		SetUser(c, "alice")
+		return c.Next()
+	})
+	app.Use(StepUp(1))
+	app.Get("/account", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "account")
+	})
+
+	ginji.AssertStatus(t, ginji.PerformRequest(app, "GET", "/account", nil), ginji.StatusOK)
+}
+
+func TestStepUpRequiresLevelOrRoutes(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic without Level or Routes")
+		}
+	}()
+	StepUpWithConfig(StepUpConfig{})
+}
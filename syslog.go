@@ -0,0 +1,360 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Syslog facilities, for SyslogConfig.Facility.
+const (
+	SyslogUser   = 1
+	SyslogDaemon = 3
+	SyslogAuth   = 4
+	SyslogLocal0 = 16
+	SyslogLocal1 = 17
+	SyslogLocal2 = 18
+	SyslogLocal3 = 19
+	SyslogLocal4 = 20
+	SyslogLocal5 = 21
+	SyslogLocal6 = 22
+	SyslogLocal7 = 23
+)
+
+// syslogSockets are the local syslog sockets tried when no address is set.
+var syslogSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// SyslogConfig defines the configuration for a syslog log handler.
+type SyslogConfig struct {
+	// Network is "udp", "tcp", "unix" or "unixgram". If empty, the local
+	// syslog socket is used.
+	Network string
+
+	// Address is the address of the syslog server, or the socket path.
+	Address string
+
+	// Facility is the syslog facility of the messages.
+	// Default: SyslogLocal0
+	Facility int
+
+	// AppName identifies the application in every message.
+	// Default: the program name
+	AppName string
+
+	// Hostname is sent in every message.
+	// Default: os.Hostname()
+	Hostname string
+
+	// Level is the minimum level sent.
+	// Default: slog.LevelInfo
+	Level slog.Leveler
+
+	// StructuredDataID is the SD-ID under which attributes are sent as
+	// RFC 5424 structured data.
+	// Default: "attrs@32473"
+	StructuredDataID string
+
+	// DialTimeout bounds connecting to the server.
+	// Default: 5 seconds
+	DialTimeout time.Duration
+}
+
+// DefaultSyslogConfig returns default syslog configuration.
+func DefaultSyslogConfig() SyslogConfig {
+	hostname, _ := os.Hostname()
+	return SyslogConfig{
+		Facility:         SyslogLocal0,
+		AppName:          filepath.Base(os.Args[0]),
+		Hostname:         hostname,
+		Level:            slog.LevelInfo,
+		StructuredDataID: "attrs@32473",
+		DialTimeout:      5 * time.Second,
+	}
+}
+
+// SyslogHandler is a slog.Handler sending records to syslog in the RFC 5424
+// format, over UDP, TCP (with octet-counting framing) or a Unix socket.
+// Attributes are sent as structured data, and levels map to severities:
+// Debug to debug, Info to info, Warn to warning, Error to err, and levels
+// above Error to crit.
+//
+//	h, err := middleware.NewSyslogHandler(middleware.SyslogConfig{
+//		Network: "tcp",
+//		Address: "logs.internal:514",
+//	})
+//	app.Use(middleware.LoggerWithConfig(middleware.LoggerConfig{Handler: h}))
+type SyslogHandler struct {
+	conn   *syslogConn
+	attrs  []flatAttr
+	prefix string
+}
+
+// syslogConn is the connection shared by a SyslogHandler and the handlers
+// derived from it.
+type syslogConn struct {
+	config  SyslogConfig
+	network string
+	address string
+	mu      sync.Mutex
+	conn    net.Conn
+}
+
+// NewSyslogHandler connects to the syslog server of config.
+func NewSyslogHandler(config SyslogConfig) (*SyslogHandler, error) {
+	defaults := DefaultSyslogConfig()
+	if config.Facility <= 0 {
+		config.Facility = defaults.Facility
+	}
+	if config.AppName == "" {
+		config.AppName = defaults.AppName
+	}
+	if config.Hostname == "" {
+		config.Hostname = defaults.Hostname
+	}
+	if config.Level == nil {
+		config.Level = defaults.Level
+	}
+	if config.StructuredDataID == "" {
+		config.StructuredDataID = defaults.StructuredDataID
+	}
+	if config.DialTimeout <= 0 {
+		config.DialTimeout = defaults.DialTimeout
+	}
+
+	sc := &syslogConn{config: config, network: config.Network, address: config.Address}
+	if err := sc.dial(); err != nil {
+		return nil, err
+	}
+	return &SyslogHandler{conn: sc}, nil
+}
+
+// Enabled implements slog.Handler.
+func (h *SyslogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.conn.config.Level.Level()
+}
+
+// Handle implements slog.Handler.
+func (h *SyslogHandler) Handle(_ context.Context, r slog.Record) error {
+	attrs := recordAttrs(h.attrs, h.prefix, r)
+	return h.conn.write(h.conn.format(r, attrs))
+}
+
+// WithAttrs implements slog.Handler.
+func (h *SyslogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.attrs = appendFlatAttrs(slices.Clip(h.attrs), h.prefix, attrs)
+	return &clone
+}
+
+// WithGroup implements slog.Handler.
+func (h *SyslogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.prefix = h.prefix + name + "."
+	return &clone
+}
+
+// Close closes the connection.
+func (h *SyslogHandler) Close() error {
+	h.conn.mu.Lock()
+	defer h.conn.mu.Unlock()
+
+	if h.conn.conn == nil {
+		return nil
+	}
+	err := h.conn.conn.Close()
+	h.conn.conn = nil
+	return err
+}
+
+// dial connects to the server, or to the first local socket found.
+func (s *syslogConn) dial() error {
+	if s.network == "" {
+		for _, path := range syslogSockets {
+			for _, network := range []string{"unixgram", "unix"} {
+				conn, err := net.DialTimeout(network, path, s.config.DialTimeout)
+				if err == nil {
+					s.network, s.address, s.conn = network, path, conn
+					return nil
+				}
+			}
+		}
+		return errors.New("syslog: no local syslog socket found")
+	}
+
+	conn, err := net.DialTimeout(s.network, s.address, s.config.DialTimeout)
+	if err != nil {
+		return fmt.Errorf("syslog: %w", err)
+	}
+	s.conn = conn
+	return nil
+}
+
+// write sends msg, reconnecting once if the connection was lost.
+func (s *syslogConn) write(msg []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.network == "tcp" || s.network == "unix" {
+		// Octet-counting framing (RFC 6587)
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+
+	var err error
+	for range 2 {
+		if s.conn == nil {
+			if err = s.dial(); err != nil {
+				continue
+			}
+		}
+		if _, err = s.conn.Write(msg); err == nil {
+			return nil
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+	return err
+}
+
+// format renders r as an RFC 5424 message.
+func (s *syslogConn) format(r slog.Record, attrs []flatAttr) []byte {
+	t := r.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+
+	var b strings.Builder
+	b.WriteByte('<')
+	b.WriteString(strconv.Itoa(s.config.Facility*8 + syslogSeverity(r.Level)))
+	b.WriteString(">1 ")
+	b.WriteString(t.Format("2006-01-02T15:04:05.000000Z07:00"))
+	b.WriteByte(' ')
+	b.WriteString(syslogHeaderField(s.config.Hostname, 255))
+	b.WriteByte(' ')
+	b.WriteString(syslogHeaderField(s.config.AppName, 48))
+	b.WriteByte(' ')
+	b.WriteString(strconv.Itoa(os.Getpid()))
+	b.WriteString(" - ")
+
+	if len(attrs) == 0 {
+		b.WriteByte('-')
+	} else {
+		b.WriteByte('[')
+		b.WriteString(s.config.StructuredDataID)
+		for _, a := range attrs {
+			b.WriteByte(' ')
+			b.WriteString(syslogParamName(a.key))
+			b.WriteString(`="`)
+			syslogEscape(&b, a.value)
+			b.WriteByte('"')
+		}
+		b.WriteByte(']')
+	}
+
+	if r.Message != "" {
+		b.WriteByte(' ')
+		b.WriteString(r.Message)
+	}
+	return []byte(b.String())
+}
+
+// syslogSeverity maps a slog level to a syslog severity.
+func syslogSeverity(level slog.Level) int {
+	switch {
+	case level > slog.LevelError:
+		return 2 // crit
+	case level >= slog.LevelError:
+		return 3 // err
+	case level >= slog.LevelWarn:
+		return 4 // warning
+	case level >= slog.LevelInfo:
+		return 6 // info
+	default:
+		return 7 // debug
+	}
+}
+
+// syslogHeaderField returns v as a header field of at most n printable
+// ASCII characters, or "-" if it is empty.
+func syslogHeaderField(v string, n int) string {
+	v = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+		return r
+	}, v)
+	if v == "" {
+		return "-"
+	}
+	return v[:min(len(v), n)]
+}
+
+// syslogParamName returns key as a valid SD-PARAM name.
+func syslogParamName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 || r == '=' || r == ']' || r == '"' || r == ' ' {
+			return '_'
+		}
+		return r
+	}, key)
+	return name[:min(len(name), 32)]
+}
+
+// syslogEscape writes v as an SD-PARAM value.
+func syslogEscape(b *strings.Builder, v string) {
+	for _, r := range v {
+		if r == '"' || r == '\\' || r == ']' {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+}
+
+// flatAttr is a log attribute flattened to a dotted key and a string
+// value, for sinks without nested values.
+type flatAttr struct {
+	key   string
+	value string
+}
+
+// recordAttrs returns the attributes of r after those of the handler.
+func recordAttrs(handlerAttrs []flatAttr, prefix string, r slog.Record) []flatAttr {
+	attrs := slices.Clip(handlerAttrs)
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = appendFlatAttrs(attrs, prefix, []slog.Attr{a})
+		return true
+	})
+	return attrs
+}
+
+// appendFlatAttrs appends attrs to dst, flattening groups into dotted
+// keys under prefix.
+func appendFlatAttrs(dst []flatAttr, prefix string, attrs []slog.Attr) []flatAttr {
+	for _, a := range attrs {
+		a.Value = a.Value.Resolve()
+		if a.Equal(slog.Attr{}) {
+			continue
+		}
+		if a.Value.Kind() == slog.KindGroup {
+			group := prefix
+			if a.Key != "" {
+				group += a.Key + "."
+			}
+			dst = appendFlatAttrs(dst, group, a.Value.Group())
+			continue
+		}
+		dst = append(dst, flatAttr{key: prefix + a.Key, value: a.Value.String()})
+	}
+	return dst
+}
@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"bufio"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+func TestSyslogHandlerUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	h, err := NewSyslogHandler(SyslogConfig{
+		Network:  "udp",
+		Address:  pc.LocalAddr().String(),
+		Facility: SyslogLocal3,
+		AppName:  "shop api",
+		Hostname: "web-1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	app := ginji.New()
+	app.Use(LoggerWithConfig(LoggerConfig{Handler: h}))
+	app.Get("/fail", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusInternalServerError, "fail")
+	})
+	ginji.PerformRequest(app, "GET", "/fail", nil)
+
+	buf := make([]byte, 4096)
+	pc.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := string(buf[:n])
+
+	// local3 (19) * 8 + err (3)
+	if !strings.HasPrefix(msg, "<155>1 ") {
+		t.Errorf("Expected local3.err priority, got %q", msg)
+	}
+	for _, want := range []string{" web-1 shopapi ", `[attrs@32473 status="500" method="GET" path="/fail"`, "] Server error"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("Expected %s in %q", want, msg)
+		}
+	}
+}
+
+func TestSyslogHandlerTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	received := make(chan string, 2)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			length, err := r.ReadString(' ')
+			if err != nil {
+				return
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(length))
+			msg := make([]byte, n)
+			if _, err := io.ReadFull(r, msg); err != nil {
+				return
+			}
+			received <- string(msg)
+		}
+	}()
+
+	h, err := NewSyslogHandler(SyslogConfig{Network: "tcp", Address: ln.Addr().String(), Level: slog.LevelDebug})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	logger := slog.New(h).With("request_id", "abc").WithGroup("http")
+	logger.Debug("Starting", "note", `say "hi" [ok]`)
+	logger.Warn("Slow", slog.Group("timing", "ms", 1200))
+
+	msg := <-received
+	if want := `[attrs@32473 request_id="abc" http.note="say \"hi\" [ok\]"] Starting`; !strings.HasPrefix(msg, "<135>1 ") || !strings.Contains(msg, want) {
+		t.Errorf("Expected a framed debug message with %s, got %q", want, msg)
+	}
+	if msg = <-received; !strings.HasPrefix(msg, "<132>1 ") || !strings.Contains(msg, `http.timing.ms="1200"`) {
+		t.Errorf("Expected a framed warning with grouped attributes, got %q", msg)
+	}
+}
+
+func TestSyslogSeverity(t *testing.T) {
+	tests := map[slog.Level]int{
+		slog.LevelDebug - 4: 7,
+		slog.LevelDebug:     7,
+		slog.LevelInfo:      6,
+		slog.LevelInfo + 2:  6,
+		slog.LevelWarn:      4,
+		slog.LevelError:     3,
+		slog.LevelError + 4: 2,
+	}
+	for level, want := range tests {
+		if got := syslogSeverity(level); got != want {
+			t.Errorf("syslogSeverity(%v) = %d, want %d", level, got, want)
+		}
+	}
+}
+
+func TestSyslogHandlerErrors(t *testing.T) {
+	if _, err := NewSyslogHandler(SyslogConfig{Network: "tcp", Address: "127.0.0.1:1", DialTimeout: 100 * time.Millisecond}); err == nil {
+		t.Error("Expected a dial error")
+	}
+}
@@ -0,0 +1,202 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// PlanLimit is the rate limit of a plan.
+type PlanLimit struct {
+	// Max is the number of requests allowed per Window.
+	Max int
+
+	// Window is the time window of Max.
+	Window time.Duration
+
+	// Unlimited exempts the plan from rate limiting.
+	Unlimited bool
+}
+
+// PlanResolver returns the plan of the client making the request, e.g.
+// "pro", or "" if unknown.
+type PlanResolver func(*ginji.Context) string
+
+// TieredRateLimitConfig defines the configuration for plan-aware rate limiting.
+type TieredRateLimitConfig struct {
+	// Plans are the limits by plan name. Required.
+	Plans map[string]PlanLimit
+
+	// DefaultPlan applies when the resolver returns no plan or an unknown
+	// one. It must be in Plans.
+	// Default: "free"
+	DefaultPlan string
+
+	// PlanResolver returns the plan of the request.
+	// Default: DefaultPlanResolver
+	PlanResolver PlanResolver
+
+	// KeyFunc returns the client the limit applies to. Requests for which
+	// it returns an empty string are limited by client IP.
+	// Default: the authenticated user's ID, or the client IP
+	KeyFunc func(*ginji.Context) string
+
+	// Limiter holds the buckets. Share a limiter with other rate limits to
+	// close it in one place.
+	// Default: a private limiter
+	Limiter *Limiter
+
+	// Headers enables rate limit headers reporting the plan's limit.
+	// Default: true
+	Headers bool
+
+	// HeaderStyle selects the rate limit headers.
+	// Default: RateLimitHeadersLegacy
+	HeaderStyle RateLimitHeaderStyle
+
+	// StatusCode is the status sent when the limit is exceeded.
+	// Default: 429
+	StatusCode int
+
+	// ErrorMessage is the error sent when the limit is exceeded.
+	// Default: "Rate limit exceeded for plan <plan>"
+	ErrorMessage string
+
+	// SkipFunc allows skipping rate limiting for certain requests.
+	SkipFunc Skipper
+}
+
+// DefaultTieredRateLimitConfig returns default plan-aware rate limit
+// configuration with free, pro and enterprise plans.
+func DefaultTieredRateLimitConfig() TieredRateLimitConfig {
+	return TieredRateLimitConfig{
+		Plans: map[string]PlanLimit{
+			"free":       {Max: 60, Window: time.Minute},
+			"pro":        {Max: 600, Window: time.Minute},
+			"enterprise": {Unlimited: true},
+		},
+		DefaultPlan:  "free",
+		PlanResolver: DefaultPlanResolver,
+		KeyFunc:      defaultTieredRateLimitKey,
+		Headers:      true,
+		StatusCode:   http.StatusTooManyRequests,
+	}
+}
+
+// TieredRateLimit returns plan-aware rate limiting for the given plans.
+func TieredRateLimit(plans map[string]PlanLimit) ginji.Middleware {
+	config := DefaultTieredRateLimitConfig()
+	config.Plans = plans
+	return TieredRateLimitWithConfig(config)
+}
+
+// TieredRateLimitWithConfig returns middleware that rate limits each client
+// by the limit of its plan. Buckets are kept per plan, so an upgrade takes
+// effect immediately. It panics if DefaultPlan is not in Plans.
+func TieredRateLimitWithConfig(config TieredRateLimitConfig) ginji.Middleware {
+	defaults := DefaultTieredRateLimitConfig()
+	if config.Plans == nil {
+		config.Plans = defaults.Plans
+	}
+	if config.DefaultPlan == "" {
+		config.DefaultPlan = defaults.DefaultPlan
+	}
+	if _, ok := config.Plans[config.DefaultPlan]; !ok {
+		panic(fmt.Sprintf("TieredRateLimit: default plan %q is not in Plans", config.DefaultPlan))
+	}
+	for name, plan := range config.Plans {
+		if !plan.Unlimited && (plan.Max <= 0 || plan.Window <= 0) {
+			panic(fmt.Sprintf("TieredRateLimit: plan %q needs Max and Window, or Unlimited", name))
+		}
+	}
+	if config.PlanResolver == nil {
+		config.PlanResolver = defaults.PlanResolver
+	}
+	if config.KeyFunc == nil {
+		config.KeyFunc = defaults.KeyFunc
+	}
+	if config.Limiter == nil {
+		config.Limiter = NewLimiter(DefaultRateLimiterConfig())
+	}
+	if config.StatusCode == 0 {
+		config.StatusCode = defaults.StatusCode
+	}
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		name := config.PlanResolver(c)
+		plan, ok := config.Plans[name]
+		if !ok {
+			name, plan = config.DefaultPlan, config.Plans[config.DefaultPlan]
+		}
+		if plan.Unlimited {
+			return c.Next()
+		}
+
+		limit := RouteLimit{Max: plan.Max, Window: plan.Window}
+		key := "tier|" + name + "|" + keyOrIP(c, config.KeyFunc(c), nil)
+		allowed, remaining, resetTime := config.Limiter.Take(key, limit, 1)
+
+		check := rateLimitCheck{policy: name, key: key, limit: limit, allowed: allowed, remaining: remaining, resetTime: resetTime}
+		if config.Headers {
+			setRateLimitHeaders(c, config.HeaderStyle, check, []rateLimitCheck{check})
+		}
+
+		if !allowed {
+			message := config.ErrorMessage
+			if message == "" {
+				message = "Rate limit exceeded for plan " + name
+			}
+			c.SetHeader("Retry-After", fmt.Sprintf("%d", secondsUntil(resetTime)))
+			c.AbortWithStatusJSON(config.StatusCode, ginji.H{
+				"error":   message,
+				"plan":    name,
+				"limit":   plan.Max,
+				"window":  plan.Window.String(),
+				"retryAt": resetTime.Format(time.RFC3339),
+			})
+			return nil
+		}
+
+		return c.Next()
+	}
+}
+
+// DefaultPlanResolver reads the plan from the authenticated user, as the
+// "plan" or "tier" field of a map[string]any or from a Plan() method, and
+// otherwise from the "plan" claim of a token.
+func DefaultPlanResolver(c *ginji.Context) string {
+	if user, ok := UserFrom(c); ok {
+		switch u := user.(type) {
+		case interface{ Plan() string }:
+			return u.Plan()
+		case map[string]any:
+			for _, field := range []string{"plan", "tier"} {
+				if plan, ok := u[field].(string); ok {
+					return plan
+				}
+			}
+		}
+	}
+	if claims, ok := ClaimsFrom(c); ok {
+		if plan, ok := claims["plan"].(string); ok {
+			return plan
+		}
+	}
+	return ""
+}
+
+// defaultTieredRateLimitKey returns the authenticated user's ID, or the
+// client IP for anonymous requests.
+func defaultTieredRateLimitKey(c *ginji.Context) string {
+	if subject := defaultPolicySubject(c); subject != "" {
+		return "user:" + subject
+	}
+	return "ip:" + clientIP(c.Req, nil)
+}
@@ -0,0 +1,157 @@
+package middleware
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+func testPlans() map[string]PlanLimit {
+	return map[string]PlanLimit{
+		"free":       {Max: 2, Window: time.Minute},
+		"pro":        {Max: 5, Window: time.Minute},
+		"enterprise": {Unlimited: true},
+	}
+}
+
+func TestTieredRateLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    any
+		allowed int
+	}{
+		{"free", map[string]any{"id": "alice", "plan": "free"}, 2},
+		{"pro", map[string]any{"id": "bob", "plan": "pro"}, 5},
+		{"unknown plan uses default", map[string]any{"id": "carol", "plan": "legacy"}, 2},
+		{"anonymous", nil, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultTieredRateLimitConfig()
+			config.Plans = testPlans()
+			app := ginji.New()
+			app.Use(func(c *ginji.Context) error {
+				if tt.user != nil {
+					SetUser(c, tt.user)
+				}
+				return c.Next()
+			})
+			app.Use(TieredRateLimitWithConfig(config))
+			app.Get("/api", func(c *ginji.Context) error {
+				return c.Text(ginji.StatusOK, "ok")
+			})
+
+			for i := 0; i < tt.allowed; i++ {
+				ginji.AssertStatus(t, ginji.PerformRequest(app, "GET", "/api", nil), ginji.StatusOK)
+			}
+			w := ginji.PerformRequest(app, "GET", "/api", nil)
+			ginji.AssertStatus(t, w, ginji.StatusTooManyRequests)
+			ginji.AssertHeader(t, w, "X-RateLimit-Limit", strconv.Itoa(tt.allowed))
+			if w.Header().Get("Retry-After") == "" {
+				t.Error("Expected Retry-After header")
+			}
+		})
+	}
+}
+
+func TestTieredRateLimitHeaders(t *testing.T) {
+	config := DefaultTieredRateLimitConfig()
+	config.Plans = testPlans()
+	config.HeaderStyle = RateLimitHeadersStandard
+	app := ginji.New()
+	app.Use(func(c *ginji.Context) error {
+		SetUser(c, map[string]any{"id": "bob", "plan": "pro"})
+		return c.Next()
+	})
+	app.Use(TieredRateLimitWithConfig(config))
+	app.Get("/api", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/api", nil)
+	ginji.AssertHeader(t, w, "RateLimit-Limit", "5")
+	ginji.AssertHeader(t, w, "RateLimit-Remaining", "4")
+	ginji.AssertHeader(t, w, "RateLimit-Policy", "5;w=60")
+}
+
+func TestTieredRateLimitUnlimited(t *testing.T) {
+	config := DefaultTieredRateLimitConfig()
+	config.Plans = testPlans()
+	app := ginji.New()
+	app.Use(func(c *ginji.Context) error {
+		SetUser(c, map[string]any{"id": "dave", "plan": "enterprise"})
+		return c.Next()
+	})
+	app.Use(TieredRateLimitWithConfig(config))
+	app.Get("/api", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	for i := 0; i < 10; i++ {
+		w := ginji.PerformRequest(app, "GET", "/api", nil)
+		ginji.AssertStatus(t, w, ginji.StatusOK)
+		if w.Header().Get("X-RateLimit-Limit") != "" {
+			t.Fatal("Expected no rate limit headers for unlimited plan")
+		}
+	}
+}
+
+func TestTieredRateLimitUpgrade(t *testing.T) {
+	user := map[string]any{"id": "erin", "plan": "free"}
+	config := DefaultTieredRateLimitConfig()
+	config.Plans = testPlans()
+	app := ginji.New()
+	app.Use(func(c *ginji.Context) error {
+		SetUser(c, user)
+		return c.Next()
+	})
+	app.Use(TieredRateLimitWithConfig(config))
+	app.Get("/api", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	ginji.PerformRequest(app, "GET", "/api", nil)
+	ginji.PerformRequest(app, "GET", "/api", nil)
+	ginji.AssertStatus(t, ginji.PerformRequest(app, "GET", "/api", nil), ginji.StatusTooManyRequests)
+
+	user["plan"] = "pro"
+	ginji.AssertStatus(t, ginji.PerformRequest(app, "GET", "/api", nil), ginji.StatusOK)
+}
+
+func TestTieredRateLimitClaims(t *testing.T) {
+	app := ginji.New()
+	app.Use(func(c *ginji.Context) error {
+		SetClaims(c, map[string]any{"sub": "key-1", "plan": "pro"})
+		return c.Next()
+	})
+	app.Use(TieredRateLimit(testPlans()))
+	app.Get("/api", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/api", nil)
+	ginji.AssertHeader(t, w, "X-RateLimit-Limit", "5")
+}
+
+func TestTieredRateLimitInvalidPlans(t *testing.T) {
+	tests := []struct {
+		name   string
+		config TieredRateLimitConfig
+	}{
+		{"missing default", TieredRateLimitConfig{Plans: map[string]PlanLimit{"pro": {Max: 1, Window: time.Second}}}},
+		{"zero limit", TieredRateLimitConfig{Plans: map[string]PlanLimit{"free": {}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("Expected panic")
+				}
+			}()
+			TieredRateLimitWithConfig(tt.config)
+		})
+	}
+}
@@ -1,24 +1,36 @@
 package middleware
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
+	"io"
+	"net"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/ginjigo/ginji"
 )
 
-// bufferedResponseWriter buffers the response until we know if timeout occurred
+// bufferedResponseWriter buffers the response until we know if timeout occurred.
+// Flush and Hijack commit the response to the original writer instead, after
+// which writes stream through and a timeout can no longer replace the response.
 type bufferedResponseWriter struct {
-	header http.Header
-	buf    *bytes.Buffer
-	status int
+	mu        sync.Mutex
+	dst       http.ResponseWriter
+	header    http.Header
+	buf       *bytes.Buffer
+	status    int
+	committed bool // Headers were sent to dst, or the connection was hijacked
+	timedOut  bool
 }
 
-func newBufferedResponseWriter() *bufferedResponseWriter {
+func newBufferedResponseWriter(dst http.ResponseWriter) *bufferedResponseWriter {
 	return &bufferedResponseWriter{
+		dst:    dst,
 		header: make(http.Header),
 		buf:    new(bytes.Buffer),
 		status: 200,
@@ -26,29 +38,151 @@ func newBufferedResponseWriter() *bufferedResponseWriter {
 }
 
 func (w *bufferedResponseWriter) Header() http.Header {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.committed {
+		return w.dst.Header()
+	}
 	return w.header
 }
 
 func (w *bufferedResponseWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if w.committed {
+		return w.dst.Write(b)
+	}
 	return w.buf.Write(b)
 }
 
 func (w *bufferedResponseWriter) WriteHeader(statusCode int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return
+	}
+	if statusCode >= 100 && statusCode < 200 && statusCode != http.StatusSwitchingProtocols {
+		w.writeInformationalLocked(statusCode)
+		return
+	}
+	if w.committed {
+		return
+	}
 	w.status = statusCode
 }
 
-// copyTo copies the buffered response to the actual response writer
-func (w *bufferedResponseWriter) copyTo(dst http.ResponseWriter) {
+// writeInformationalLocked sends a 1xx response, such as 103 Early Hints,
+// right away with the headers buffered so far. The original writer's
+// headers are restored afterwards so the final response is unaffected.
+// The caller must hold w.mu.
+func (w *bufferedResponseWriter) writeInformationalLocked(statusCode int) {
+	if w.committed {
+		w.dst.WriteHeader(statusCode)
+		return
+	}
+	h := w.dst.Header()
+	saved := h.Clone()
+	for k, v := range w.header {
+		h[k] = v
+	}
+	w.dst.WriteHeader(statusCode)
+	clear(h)
+	for k, v := range saved {
+		h[k] = v
+	}
+}
+
+// ReadFrom copies r through Write, so large bodies are buffered or streamed
+// like any other write.
+func (w *bufferedResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	return io.Copy(struct{ io.Writer }{w}, r)
+}
+
+// Flush commits the buffered response and flushes it to the client.
+func (w *bufferedResponseWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return
+	}
+	w.commitLocked()
+	_ = flushResponse(w.dst)
+}
+
+// Hijack takes over the connection from the original writer.
+func (w *bufferedResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return nil, nil, http.ErrHandlerTimeout
+	}
+	conn, rw, err := http.NewResponseController(baseResponseWriter(w.dst)).Hijack()
+	if err == nil {
+		w.committed = true
+	}
+	return conn, rw, err
+}
+
+// Push initiates an HTTP/2 server push when the original writer supports it.
+func (w *bufferedResponseWriter) Push(target string, opts *http.PushOptions) error {
+	if pusher, ok := baseResponseWriter(w.dst).(http.Pusher); ok {
+		return pusher.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// commitLocked sends the buffered headers and body to the original writer.
+// The caller must hold w.mu.
+func (w *bufferedResponseWriter) commitLocked() {
+	if w.committed {
+		return
+	}
+	w.committed = true
+	// Declared trailers are set after the body so they are sent as trailers
+	trailers := make(map[string]bool)
+	for _, v := range w.header.Values("Trailer") {
+		for _, name := range strings.Split(v, ",") {
+			trailers[http.CanonicalHeaderKey(strings.TrimSpace(name))] = true
+		}
+	}
 	// Copy headers
 	for k, v := range w.header {
+		if trailers[k] {
+			continue
+		}
 		for _, vv := range v {
-			dst.Header().Add(k, vv)
+			w.dst.Header().Add(k, vv)
 		}
 	}
 	// Write status
-	dst.WriteHeader(w.status)
+	w.dst.WriteHeader(w.status)
 	// Write body
-	_, _ = dst.Write(w.buf.Bytes())
+	_, _ = w.dst.Write(w.buf.Bytes())
+	w.buf.Reset()
+	for k := range trailers {
+		if v, ok := w.header[k]; ok {
+			w.dst.Header()[k] = v
+		}
+	}
+}
+
+// finish writes the buffered response once the handler has returned.
+func (w *bufferedResponseWriter) finish() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.commitLocked()
+}
+
+// timeout stops further writes and reports whether the response was already
+// committed, in which case no timeout response can be sent.
+func (w *bufferedResponseWriter) timeout() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.timedOut = true
+	return w.committed
 }
 
 // TimeoutConfig defines the configuration for timeout middleware.
@@ -64,7 +198,12 @@ type TimeoutConfig struct {
 	StatusCode int
 
 	// SkipFunc allows skipping timeout for certain requests.
-	SkipFunc func(*ginji.Context) bool
+	SkipFunc Skipper
+
+	// BypassBuffering selects requests, such as streaming or hijacking
+	// handlers, that run with the request context deadline only. Their
+	// responses are written directly and no timeout response is sent.
+	BypassBuffering Skipper
 }
 
 // DefaultTimeoutConfig returns default timeout configuration.
@@ -108,9 +247,20 @@ func TimeoutWithConfig(config TimeoutConfig) ginji.Middleware {
 		// Replace request context
 		c.Req = c.Req.WithContext(ctx)
 
+		// Event streams and WebSocket connections set up by middleware
+		// earlier in the chain are written directly, still under the
+		// deadline. Those set up later commit the buffer on their first
+		// flush or hijack.
+		if _, ws := GetWebSocket(c); ws || isEventStream(c) {
+			return c.Next()
+		}
+		if config.BypassBuffering != nil && config.BypassBuffering(c) {
+			return c.Next()
+		}
+
 		// Replace response writer with buffered version
 		originalRes := c.Res
-		buffered := newBufferedResponseWriter()
+		buffered := newBufferedResponseWriter(originalRes)
 		c.Res = buffered
 
 		// Create a deep copy of the context for the goroutine
@@ -146,7 +296,7 @@ func TimeoutWithConfig(config TimeoutConfig) ginji.Middleware {
 			// Handler completed successfully - write buffered response
 			// Restore original writer first? No, we copy to it.
 			c.Res = originalRes
-			buffered.copyTo(originalRes)
+			buffered.finish()
 
 			// We need to sync the context state back if needed?
 			// e.g. if handlers modified c.Keys, cp.Keys is modified (map is ref).
@@ -170,7 +320,10 @@ func TimeoutWithConfig(config TimeoutConfig) ginji.Middleware {
 			// Wait, we just restored it.
 			// The goroutine uses cp.Res which is buffered. So it's fine.
 
-			if ctx.Err() == context.DeadlineExceeded {
+			// A flushed or hijacked response cannot be replaced
+			committed := buffered.timeout()
+
+			if ctx.Err() == context.DeadlineExceeded && !committed {
 				// Write directly to original writer
 				c.Res.Header().Set("Content-Type", "application/json")
 				c.Res.WriteHeader(config.StatusCode)
@@ -1,6 +1,11 @@
 package middleware
 
 import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -212,3 +217,157 @@ func TestTimeoutMultipleRequests(t *testing.T) {
 		t.Errorf("Slow request: Expected status 504, got %d", w2.Code)
 	}
 }
+
+func TestTimeoutFlushCommitsResponse(t *testing.T) {
+	app := ginji.New()
+	app.Use(Timeout(50 * time.Millisecond))
+
+	writeErr := make(chan error, 1)
+	app.Get("/stream", func(c *ginji.Context) error {
+		c.Res.Header().Set("Content-Type", "text/plain")
+		_, _ = c.Res.Write([]byte("first"))
+		c.Res.(http.Flusher).Flush()
+
+		time.Sleep(100 * time.Millisecond)
+		_, err := c.Res.Write([]byte("second"))
+		writeErr <- err
+		return nil
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/stream", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	if !w.Flushed {
+		t.Error("Expected response to be flushed")
+	}
+	if body := w.Body.String(); body != "first" {
+		t.Errorf("Expected body 'first', got %q", body)
+	}
+	if err := <-writeErr; !errors.Is(err, http.ErrHandlerTimeout) {
+		t.Errorf("Expected ErrHandlerTimeout after timeout, got %v", err)
+	}
+}
+
+func TestTimeoutReaderFrom(t *testing.T) {
+	app := ginji.New()
+	app.Use(Timeout(time.Second))
+	app.Get("/copy", func(c *ginji.Context) error {
+		_, err := c.Res.(io.ReaderFrom).ReadFrom(strings.NewReader("copied"))
+		return err
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/copy", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	ginji.AssertBody(t, w, "copied")
+}
+
+func TestTimeoutHijack(t *testing.T) {
+	app := ginji.New()
+	app.Use(Timeout(time.Second))
+	app.Get("/raw", func(c *ginji.Context) error {
+		conn, rw, err := c.Res.(http.Hijacker).Hijack()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		_, _ = rw.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 6\r\nConnection: close\r\n\r\nraw ok")
+		return rw.Flush()
+	})
+
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/raw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "raw ok" {
+		t.Errorf("Expected hijacked response, got %q", body)
+	}
+}
+
+func TestTimeoutBypassBuffering(t *testing.T) {
+	app := ginji.New()
+	config := DefaultTimeoutConfig()
+	config.Timeout = 50 * time.Millisecond
+	config.BypassBuffering = SkipPaths("/direct")
+	app.Use(TimeoutWithConfig(config))
+
+	app.Get("/direct", func(c *ginji.Context) error {
+		if _, ok := c.Res.(*bufferedResponseWriter); ok {
+			t.Error("Expected unbuffered writer")
+		}
+		<-c.Req.Context().Done()
+		return c.Text(ginji.StatusOK, "deadline reached")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/direct", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	ginji.AssertBody(t, w, "deadline reached")
+}
+
+func TestTimeoutEventStream(t *testing.T) {
+	app := ginji.New()
+	app.Use(SSE())
+	app.Use(Timeout(50 * time.Millisecond))
+	app.Get("/events", func(c *ginji.Context) error {
+		if _, ok := c.Res.(*bufferedResponseWriter); ok {
+			t.Error("Expected unbuffered writer for event streams")
+		}
+		if _, ok := c.Req.Context().Deadline(); !ok {
+			t.Error("Expected the deadline to apply to event streams")
+		}
+		return c.Text(ginji.StatusOK, "data: ok\n\n")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/events", nil)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+
+	// The Accept header alone does not lift the deadline
+	plain := ginji.New()
+	plain.Use(Timeout(50 * time.Millisecond))
+	plain.Get("/slow", func(c *ginji.Context) error {
+		time.Sleep(300 * time.Millisecond)
+		return c.Text(ginji.StatusOK, "done")
+	})
+	w = ginji.NewRequest(plain, "GET", "/slow").Header("Accept", "text/event-stream").Do()
+	ginji.AssertStatus(t, w, ginji.StatusGatewayTimeout)
+}
+
+func TestTimeoutWebSocket(t *testing.T) {
+	app := ginji.New()
+	app.Use(WebSocket())
+	app.Use(Timeout(time.Second))
+	app.Get("/ws", func(c *ginji.Context) error {
+		ws, _ := GetWebSocket(c)
+		if _, ok := c.Req.Context().Deadline(); !ok {
+			t.Error("Expected the deadline to apply to WebSocket connections")
+		}
+		return ws.WriteMessage(ginji.TextMessage, []byte("hello"))
+	})
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+
+	conn, br, resp := dialWebSocket(t, srv, "/ws", nil)
+	defer func() { _ = conn.Close() }()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected 101, got %d", resp.StatusCode)
+	}
+	if _, payload := readServerFrame(t, br); string(payload) != "hello" {
+		t.Errorf("Unexpected message %q", payload)
+	}
+
+	// Upgrade headers on a route without WebSocket middleware keep the timeout
+	plain := ginji.New()
+	plain.Use(Timeout(50 * time.Millisecond))
+	plain.Get("/slow", func(c *ginji.Context) error {
+		time.Sleep(300 * time.Millisecond)
+		return c.Text(ginji.StatusOK, "done")
+	})
+	w := ginji.NewRequest(plain, "GET", "/slow").
+		Header("Connection", "Upgrade").
+		Header("Upgrade", "websocket").
+		Do()
+	ginji.AssertStatus(t, w, ginji.StatusGatewayTimeout)
+}
@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// TrailersConfig defines the configuration for trailers middleware.
+type TrailersConfig struct {
+	// Digest adds a Content-Digest trailer (RFC 9530) with the SHA-256
+	// digest of the response body.
+	// Default: true
+	Digest bool
+
+	// Timing adds a Server-Timing trailer with the time spent handling the
+	// request, measured after the body was written.
+	// Default: true
+	Timing bool
+
+	// SkipFunc allows skipping the trailers for certain requests.
+	SkipFunc Skipper
+}
+
+// DefaultTrailersConfig returns default trailers configuration.
+func DefaultTrailersConfig() TrailersConfig {
+	return TrailersConfig{
+		Digest: true,
+		Timing: true,
+	}
+}
+
+// Trailers returns trailers middleware with default configuration.
+func Trailers() ginji.Middleware {
+	return TrailersWithConfig(DefaultTrailersConfig())
+}
+
+// TrailersWithConfig returns middleware that sends a digest of the body
+// and the handling time as HTTP trailers. Both are only known once the body
+// has been written, so they cannot be sent as headers of streamed responses.
+// Clients receive trailers over HTTP/2 and chunked HTTP/1.1 responses.
+func TrailersWithConfig(config TrailersConfig) ginji.Middleware {
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+		if !config.Digest && !config.Timing {
+			return c.Next()
+		}
+
+		start := time.Now()
+		rw := WrapResponseWriter(c)
+
+		var digest hash.Hash
+		if config.Digest {
+			digest = sha256.New()
+			rw.OnWrite(func(b []byte) {
+				digest.Write(b)
+			})
+			DeclareTrailers(c, "Content-Digest")
+		}
+		if config.Timing {
+			DeclareTrailers(c, "Server-Timing")
+		}
+
+		err := c.Next()
+
+		if config.Digest {
+			SetTrailer(c, "Content-Digest", "sha-256=:"+base64.StdEncoding.EncodeToString(digest.Sum(nil))+":")
+		}
+		if config.Timing {
+			SetTrailer(c, "Server-Timing", fmt.Sprintf("app;dur=%.1f", float64(time.Since(start).Microseconds())/1000))
+		}
+		return err
+	}
+}
+
+// DeclareTrailers announces trailers in the Trailer header. It must be
+// called before the response is written; HTTP/1.1 responses with declared
+// trailers are sent chunked.
+func DeclareTrailers(c *ginji.Context, names ...string) {
+	if len(names) > 0 {
+		c.Res.Header().Add("Trailer", strings.Join(names, ", "))
+	}
+}
+
+// SetTrailer sets the trailer name to value. It may be called at any time,
+// including after the body was written. Undeclared trailers are only sent
+// over HTTP/2 and in HTTP/1.1 responses that are chunked anyway, such as
+// flushed ones; use DeclareTrailers to be sure.
+func SetTrailer(c *ginji.Context, name, value string) {
+	c.Res.Header().Set(http.TrailerPrefix+name, value)
+}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// getTrailers requests url and returns the body and trailers.
+func getTrailers(t *testing.T, url string) (string, http.Header) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body), resp.Trailer
+}
+
+func TestTrailers(t *testing.T) {
+	for _, timeout := range []bool{false, true} {
+		name := "direct"
+		if timeout {
+			name = "through timeout"
+		}
+		t.Run(name, func(t *testing.T) {
+			app := ginji.New()
+			app.Use(Trailers())
+			if timeout {
+				app.Use(Timeout(time.Second))
+			}
+			app.Get("/", func(c *ginji.Context) error {
+				DeclareTrailers(c, "X-Rows")
+				if err := c.Text(ginji.StatusOK, "hello world"); err != nil {
+					return err
+				}
+				c.Res.Header().Set("X-Rows", "2")
+				return nil
+			})
+			server := httptest.NewServer(app)
+			defer server.Close()
+
+			body, trailers := getTrailers(t, server.URL)
+			sum := sha256.Sum256([]byte(body))
+			if want := "sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"; trailers.Get("Content-Digest") != want {
+				t.Errorf("Expected Content-Digest %q, got %q", want, trailers.Get("Content-Digest"))
+			}
+			if !strings.HasPrefix(trailers.Get("Server-Timing"), "app;dur=") {
+				t.Errorf("Expected Server-Timing trailer, got %q", trailers.Get("Server-Timing"))
+			}
+			if trailers.Get("X-Rows") != "2" {
+				t.Errorf("Expected declared trailer, got %v", trailers)
+			}
+		})
+	}
+}
+
+func TestSetTrailer(t *testing.T) {
+	app := ginji.New()
+	app.Get("/", func(c *ginji.Context) error {
+		if err := c.Text(ginji.StatusOK, "streamed"); err != nil {
+			return err
+		}
+		_ = flushResponse(c.Res)
+		SetTrailer(c, "X-Checksum", "abc")
+		return nil
+	})
+	server := httptest.NewServer(app)
+	defer server.Close()
+
+	_, trailers := getTrailers(t, server.URL)
+	if trailers.Get("X-Checksum") != "abc" {
+		t.Errorf("Expected undeclared trailer, got %v", trailers)
+	}
+}
@@ -0,0 +1,192 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/ginjigo/ginji"
+)
+
+// ErrNoTxn is returned by CommitTxn and RollbackTxn when no WithTxn
+// middleware has begun a transaction for the request.
+var ErrNoTxn = errors.New("txn: no transaction in context")
+
+// Tx is a database transaction, such as *sql.Tx.
+type Tx interface {
+	Commit() error
+	Rollback() error
+}
+
+// TxBeginner begins transactions bound to a context. Use SQLBeginner for a
+// *sql.DB, or TxBeginnerFunc to adapt other drivers.
+type TxBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (Tx, error)
+}
+
+// TxBeginnerFunc adapts a function to a TxBeginner.
+type TxBeginnerFunc func(ctx context.Context, opts *sql.TxOptions) (Tx, error)
+
+// BeginTx calls f.
+func (f TxBeginnerFunc) BeginTx(ctx context.Context, opts *sql.TxOptions) (Tx, error) {
+	return f(ctx, opts)
+}
+
+// SQLBeginner returns a TxBeginner for a database/sql handle, such as a
+// *sql.DB or *sql.Conn.
+func SQLBeginner(db interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}) TxBeginner {
+	return TxBeginnerFunc(func(ctx context.Context, opts *sql.TxOptions) (Tx, error) {
+		return db.BeginTx(ctx, opts)
+	})
+}
+
+// TxnConfig defines the configuration for transaction middleware.
+type TxnConfig struct {
+	// DB begins the transactions. Required.
+	DB TxBeginner
+
+	// Options are passed to BeginTx, e.g. to set the isolation level.
+	Options *sql.TxOptions
+
+	// IsFailure reports whether the request failed and its transaction must
+	// be rolled back.
+	// Default: an error was returned or the status is 400 or higher
+	IsFailure func(c *ginji.Context, err error) bool
+
+	// SkipFunc allows skipping the transaction for certain requests.
+	SkipFunc Skipper
+}
+
+// DefaultTxnConfig returns default transaction configuration.
+func DefaultTxnConfig() TxnConfig {
+	return TxnConfig{
+		IsFailure: func(c *ginji.Context, err error) bool {
+			return err != nil || c.StatusCode() >= 400
+		},
+	}
+}
+
+// WithTxn returns middleware that runs each request in a transaction of db.
+func WithTxn(db TxBeginner) ginji.Middleware {
+	config := DefaultTxnConfig()
+	config.DB = db
+	return TxnWithConfig(config)
+}
+
+// TxnWithConfig returns middleware that begins a transaction for each
+// request and stores it in the context, where handlers get it with TxFrom
+// or SQLTxFrom. The transaction is committed when the handler succeeds and
+// rolled back when it fails, panics or runs past the request deadline. It
+// is bound to the request context, so a deadline set by Timeout or Budget
+// also cancels its queries.
+//
+// The commit happens after the handler has written its response. Handlers
+// that must not report success before the data is durable call CommitTxn
+// before writing.
+func TxnWithConfig(config TxnConfig) ginji.Middleware {
+	defaults := DefaultTxnConfig()
+	if config.DB == nil {
+		panic("Txn: DB is required")
+	}
+	if config.IsFailure == nil {
+		config.IsFailure = defaults.IsFailure
+	}
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		ctx := c.Req.Context()
+		tx, err := config.DB.BeginTx(ctx, config.Options)
+		if err != nil {
+			return fmt.Errorf("txn: begin: %w", err)
+		}
+		state := &txnState{tx: tx}
+		setContextValue(c, txnContextKey, state)
+
+		completed := false
+		defer func() {
+			if !completed {
+				_ = state.rollback()
+			}
+		}()
+
+		err = c.Next()
+		completed = true
+
+		if config.IsFailure(c, err) || ctx.Err() != nil {
+			_ = state.rollback()
+			return err
+		}
+		if cerr := state.commit(); cerr != nil && err == nil {
+			return fmt.Errorf("txn: commit: %w", cerr)
+		}
+		return err
+	}
+}
+
+// TxFrom returns the request's transaction begun by WithTxn.
+func TxFrom(c *ginji.Context) (Tx, bool) {
+	if state, ok := c.Req.Context().Value(txnContextKey).(*txnState); ok {
+		return state.tx, true
+	}
+	return nil, false
+}
+
+// SQLTxFrom returns the request's transaction begun by WithTxn with a
+// SQLBeginner.
+func SQLTxFrom(c *ginji.Context) (*sql.Tx, bool) {
+	tx, _ := TxFrom(c)
+	sqlTx, ok := tx.(*sql.Tx)
+	return sqlTx, ok
+}
+
+// CommitTxn commits the request's transaction now, instead of after the
+// handler returns, so that a commit failure can still be reported to the
+// client. Committing twice is a no-op.
+func CommitTxn(c *ginji.Context) error {
+	state, ok := c.Req.Context().Value(txnContextKey).(*txnState)
+	if !ok {
+		return ErrNoTxn
+	}
+	return state.commit()
+}
+
+// RollbackTxn rolls the request's transaction back now, e.g. before
+// answering a request that failed in a way IsFailure cannot see.
+func RollbackTxn(c *ginji.Context) error {
+	state, ok := c.Req.Context().Value(txnContextKey).(*txnState)
+	if !ok {
+		return ErrNoTxn
+	}
+	return state.rollback()
+}
+
+// txnState tracks whether a request's transaction has been finished.
+type txnState struct {
+	tx   Tx
+	done bool
+}
+
+// commit commits the transaction unless it is already finished.
+func (s *txnState) commit() error {
+	if s.done {
+		return nil
+	}
+	s.done = true
+	return s.tx.Commit()
+}
+
+// rollback rolls the transaction back unless it is already finished.
+func (s *txnState) rollback() error {
+	if s.done {
+		return nil
+	}
+	s.done = true
+	return s.tx.Rollback()
+}
@@ -0,0 +1,160 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// fakeTx records how a transaction was finished.
+type fakeTx struct {
+	ctx       context.Context
+	commits   int
+	rollbacks int
+	commitErr error
+}
+
+func (tx *fakeTx) Commit() error {
+	tx.commits++
+	return tx.commitErr
+}
+
+func (tx *fakeTx) Rollback() error {
+	tx.rollbacks++
+	return nil
+}
+
+func TestTxn(t *testing.T) {
+	var last *fakeTx
+	db := TxBeginnerFunc(func(ctx context.Context, opts *sql.TxOptions) (Tx, error) {
+		last = &fakeTx{ctx: ctx}
+		return last, nil
+	})
+
+	app := ginji.New()
+	app.Use(ginji.Recovery())
+	app.Use(func(c *ginji.Context) error {
+		ctx, cancel := context.WithTimeout(c.Req.Context(), 20*time.Millisecond)
+		defer cancel()
+		c.Req = c.Req.WithContext(ctx)
+		return c.Next()
+	})
+	app.Use(WithTxn(db))
+	app.Get("/ok", func(c *ginji.Context) error {
+		if tx, ok := TxFrom(c); !ok || tx != last {
+			t.Error("Expected the transaction in the context")
+		}
+		return c.Text(ginji.StatusOK, "ok")
+	})
+	app.Get("/error", func(c *ginji.Context) error {
+		return errors.New("boom")
+	})
+	app.Get("/bad", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusBadRequest, "bad")
+	})
+	app.Get("/panic", func(c *ginji.Context) error {
+		panic("boom")
+	})
+	app.Get("/slow", func(c *ginji.Context) error {
+		<-c.Req.Context().Done()
+		return nil
+	})
+	app.Get("/early", func(c *ginji.Context) error {
+		if err := CommitTxn(c); err != nil {
+			return err
+		}
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	tests := []struct {
+		path      string
+		commits   int
+		rollbacks int
+	}{
+		{"/ok", 1, 0},
+		{"/error", 0, 1},
+		{"/bad", 0, 1},
+		{"/panic", 0, 1},
+		{"/slow", 0, 1},
+		{"/early", 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			ginji.PerformRequest(app, "GET", tt.path, nil)
+			if last.commits != tt.commits || last.rollbacks != tt.rollbacks {
+				t.Errorf("Expected %d commits and %d rollbacks, got %d and %d",
+					tt.commits, tt.rollbacks, last.commits, last.rollbacks)
+			}
+			if _, ok := last.ctx.Deadline(); !ok {
+				t.Error("Expected the transaction to be bound to the request deadline")
+			}
+		})
+	}
+}
+
+func TestTxnCommitError(t *testing.T) {
+	db := TxBeginnerFunc(func(ctx context.Context, opts *sql.TxOptions) (Tx, error) {
+		return &fakeTx{commitErr: errors.New("serialization failure")}, nil
+	})
+
+	var handlerErr error
+	app := ginji.New()
+	app.Use(func(c *ginji.Context) error {
+		handlerErr = c.Next()
+		return handlerErr
+	})
+	app.Use(WithTxn(db))
+	app.Get("/", func(c *ginji.Context) error {
+		return nil
+	})
+
+	ginji.PerformRequest(app, "GET", "/", nil)
+	if handlerErr == nil || handlerErr.Error() != "txn: commit: serialization failure" {
+		t.Errorf("Expected the commit error, got %v", handlerErr)
+	}
+}
+
+func TestTxnBeginError(t *testing.T) {
+	db := TxBeginnerFunc(func(ctx context.Context, opts *sql.TxOptions) (Tx, error) {
+		return nil, errors.New("connection refused")
+	})
+
+	var got error
+	called := false
+	app := ginji.New()
+	app.Use(func(c *ginji.Context) error {
+		got = c.Next()
+		return got
+	})
+	app.Use(WithTxn(db))
+	app.Get("/", func(c *ginji.Context) error {
+		called = true
+		return nil
+	})
+
+	ginji.PerformRequest(app, "GET", "/", nil)
+	if called {
+		t.Error("Expected the handler not to run")
+	}
+	if got == nil || got.Error() != "txn: begin: connection refused" {
+		t.Errorf("Expected the begin error, got %v", got)
+	}
+}
+
+func TestTxnHelpersWithoutMiddleware(t *testing.T) {
+	app := ginji.New()
+	app.Get("/", func(c *ginji.Context) error {
+		if !errors.Is(CommitTxn(c), ErrNoTxn) || !errors.Is(RollbackTxn(c), ErrNoTxn) {
+			t.Error("Expected ErrNoTxn")
+		}
+		if _, ok := SQLTxFrom(c); ok {
+			t.Error("Expected no transaction")
+		}
+		return nil
+	})
+	ginji.PerformRequest(app, "GET", "/", nil)
+}
@@ -0,0 +1,207 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/ginjigo/ginji"
+)
+
+// DeviceClass is the broad category of a client device.
+type DeviceClass string
+
+// Device classes detected by ParseUserAgent.
+const (
+	DeviceUnknown DeviceClass = "unknown"
+	DeviceDesktop DeviceClass = "desktop"
+	DeviceMobile  DeviceClass = "mobile"
+	DeviceTablet  DeviceClass = "tablet"
+	DeviceBot     DeviceClass = "bot"
+)
+
+// UserAgentInfo is the parsed form of a User-Agent header.
+type UserAgentInfo struct {
+	Raw            string      `json:"raw"`
+	Device         DeviceClass `json:"device"`
+	Browser        string      `json:"browser,omitempty"`
+	BrowserVersion string      `json:"browser_version,omitempty"`
+	OS             string      `json:"os,omitempty"`
+	OSVersion      string      `json:"os_version,omitempty"`
+}
+
+// IsMobile reports whether the client is a phone or tablet.
+func (u UserAgentInfo) IsMobile() bool {
+	return u.Device == DeviceMobile || u.Device == DeviceTablet
+}
+
+// IsBot reports whether the client is an automated agent.
+func (u UserAgentInfo) IsBot() bool {
+	return u.Device == DeviceBot
+}
+
+// UserAgentConfig defines the configuration for user agent middleware.
+type UserAgentConfig struct {
+	// CacheSize is the maximum number of cached parse results.
+	// Default: 1000
+	CacheSize int
+
+	// ContextKey is the key used to store the UserAgentInfo in context.
+	// Default: "user_agent"
+	ContextKey string
+
+	// Vary appends "Vary: User-Agent" to responses. Enable it when
+	// responses differ by device and may be cached.
+	Vary bool
+
+	// SkipFunc allows skipping parsing for certain requests.
+	SkipFunc Skipper
+}
+
+// DefaultUserAgentConfig returns default user agent configuration.
+func DefaultUserAgentConfig() UserAgentConfig {
+	return UserAgentConfig{
+		CacheSize:  1000,
+		ContextKey: "user_agent",
+	}
+}
+
+// UserAgent returns user agent parsing middleware with default configuration.
+func UserAgent() ginji.Middleware {
+	return UserAgentWithConfig(DefaultUserAgentConfig())
+}
+
+// UserAgentWithConfig returns user agent parsing middleware with custom configuration.
+func UserAgentWithConfig(config UserAgentConfig) ginji.Middleware {
+	defaults := DefaultUserAgentConfig()
+	if config.CacheSize == 0 {
+		config.CacheSize = defaults.CacheSize
+	}
+	if config.ContextKey == "" {
+		config.ContextKey = defaults.ContextKey
+	}
+
+	cache := newLRUCache[string, UserAgentInfo](config.CacheSize, 0)
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		raw := c.Header("User-Agent")
+		info, ok := cache.Get(raw)
+		if !ok {
+			info = ParseUserAgent(raw)
+			cache.Add(raw, info)
+		}
+		c.Set(config.ContextKey, info)
+		setContextValue(c, userAgentContextKey, info)
+
+		if config.Vary {
+			AddVary(c.Res.Header(), "User-Agent")
+		}
+
+		return c.Next()
+	}
+}
+
+// GetUserAgent returns the parsed user agent stored by the UserAgent middleware.
+func GetUserAgent(c *ginji.Context) (UserAgentInfo, bool) {
+	info, ok := c.Req.Context().Value(userAgentContextKey).(UserAgentInfo)
+	return info, ok
+}
+
+// uaBrowsers maps version tokens to browser names. Order matters: many
+// browsers include the tokens of the engines they are built on.
+var uaBrowsers = []struct {
+	token string
+	name  string
+}{
+	{"Edg/", "Edge"},
+	{"EdgiOS/", "Edge"},
+	{"OPR/", "Opera"},
+	{"SamsungBrowser/", "Samsung Internet"},
+	{"CriOS/", "Chrome"},
+	{"Chrome/", "Chrome"},
+	{"FxiOS/", "Firefox"},
+	{"Firefox/", "Firefox"},
+	{"MSIE ", "Internet Explorer"},
+	{"Trident/", "Internet Explorer"},
+}
+
+// uaOperatingSystems maps version tokens to operating system names.
+var uaOperatingSystems = []struct {
+	token string
+	name  string
+}{
+	{"Windows NT ", "Windows"},
+	{"iPhone OS ", "iOS"},
+	{"CPU OS ", "iOS"},
+	{"Android ", "Android"},
+	{"Mac OS X ", "macOS"},
+	{"CrOS ", "ChromeOS"},
+	{"Linux", "Linux"},
+}
+
+// ParseUserAgent extracts device class, browser and OS from a User-Agent string.
+func ParseUserAgent(ua string) UserAgentInfo {
+	info := UserAgentInfo{Raw: ua, Device: DeviceUnknown}
+	if ua == "" {
+		return info
+	}
+
+	for _, b := range uaBrowsers {
+		if version, ok := uaVersion(ua, b.token); ok {
+			info.Browser = b.name
+			info.BrowserVersion = version
+			break
+		}
+	}
+	if info.Browser == "" && strings.Contains(ua, "Safari/") {
+		info.Browser = "Safari"
+		info.BrowserVersion, _ = uaVersion(ua, "Version/")
+	}
+
+	for _, os := range uaOperatingSystems {
+		if version, ok := uaVersion(ua, os.token); ok {
+			info.OS = os.name
+			info.OSVersion = version
+			break
+		}
+	}
+
+	info.Device = uaDeviceClass(ua)
+	return info
+}
+
+// uaDeviceClass classifies the device from User-Agent markers.
+func uaDeviceClass(ua string) DeviceClass {
+	lower := strings.ToLower(ua)
+	for _, marker := range botUserAgentMarkers {
+		if strings.Contains(lower, marker) {
+			return DeviceBot
+		}
+	}
+	switch {
+	case strings.Contains(ua, "iPad"), strings.Contains(lower, "tablet"),
+		strings.Contains(ua, "Android") && !strings.Contains(ua, "Mobile"):
+		return DeviceTablet
+	case strings.Contains(ua, "Mobi"), strings.Contains(ua, "iPhone"), strings.Contains(ua, "iPod"):
+		return DeviceMobile
+	case strings.Contains(ua, "Mozilla/"):
+		return DeviceDesktop
+	}
+	return DeviceUnknown
+}
+
+// uaVersion returns the version following token, normalizing underscores to dots.
+func uaVersion(ua, token string) (string, bool) {
+	idx := strings.Index(ua, token)
+	if idx == -1 {
+		return "", false
+	}
+	rest := ua[idx+len(token):]
+	if end := strings.IndexAny(rest, " ;)"); end != -1 {
+		rest = rest[:end]
+	}
+	return strings.ReplaceAll(rest, "_", "."), true
+}
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ginjigo/ginji"
+)
+
+func TestParseUserAgent(t *testing.T) {
+	tests := []struct {
+		name    string
+		ua      string
+		device  DeviceClass
+		browser string
+		os      string
+		osVer   string
+	}{
+		{
+			name:    "desktop chrome",
+			ua:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+			device:  DeviceDesktop,
+			browser: "Chrome",
+			os:      "Windows",
+			osVer:   "10.0",
+		},
+		{
+			name:    "iphone safari",
+			ua:      "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1",
+			device:  DeviceMobile,
+			browser: "Safari",
+			os:      "iOS",
+			osVer:   "17.4",
+		},
+		{
+			name:    "android tablet",
+			ua:      "Mozilla/5.0 (Linux; Android 13; SM-X700) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+			device:  DeviceTablet,
+			browser: "Chrome",
+			os:      "Android",
+			osVer:   "13",
+		},
+		{
+			name:    "edge",
+			ua:      "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36 Edg/124.0.2478.51",
+			device:  DeviceDesktop,
+			browser: "Edge",
+			os:      "macOS",
+			osVer:   "10.15.7",
+		},
+		{
+			name:   "crawler",
+			ua:     "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			device: DeviceBot,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := ParseUserAgent(tt.ua)
+			if info.Device != tt.device {
+				t.Errorf("Expected device %s, got %s", tt.device, info.Device)
+			}
+			if tt.browser != "" && info.Browser != tt.browser {
+				t.Errorf("Expected browser %s, got %s", tt.browser, info.Browser)
+			}
+			if tt.os != "" && (info.OS != tt.os || info.OSVersion != tt.osVer) {
+				t.Errorf("Expected OS %s %s, got %s %s", tt.os, tt.osVer, info.OS, info.OSVersion)
+			}
+		})
+	}
+}
+
+func TestUserAgentMiddleware(t *testing.T) {
+	app := ginji.New()
+	app.Use(UserAgentWithConfig(UserAgentConfig{Vary: true}))
+
+	app.Get("/", func(c *ginji.Context) error {
+		info, _ := GetUserAgent(c)
+		if info.IsMobile() {
+			return c.Text(ginji.StatusOK, "mobile")
+		}
+		return c.Text(ginji.StatusOK, "desktop")
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) Mobile/15E148")
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+
+	ginji.AssertBody(t, w, "mobile")
+	ginji.AssertHeader(t, w, "Vary", "User-Agent")
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+)
+
+// AddVary adds fields to the Vary header of h, once each. Middleware that
+// choose a response by a request header, such as CORS by Origin or a
+// compressor by Accept-Encoding, call it so that the header stays a single
+// list without duplicates however they are combined. Nothing is added once
+// Vary is "*".
+func AddVary(h http.Header, fields ...string) {
+	var existing []string
+	for _, value := range h.Values("Vary") {
+		for _, field := range strings.Split(value, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				existing = append(existing, field)
+			}
+		}
+	}
+
+	merged := existing
+	for _, field := range fields {
+		if hasVaryField(merged, "*") {
+			break
+		}
+		if !hasVaryField(merged, field) {
+			merged = append(merged, http.CanonicalHeaderKey(field))
+		}
+	}
+	if len(merged) == 0 {
+		return
+	}
+	h.Set("Vary", strings.Join(merged, ", "))
+}
+
+// hasVaryField reports whether fields contains field, ignoring case.
+func hasVaryField(fields []string, field string) bool {
+	for _, f := range fields {
+		if strings.EqualFold(f, field) {
+			return true
+		}
+	}
+	return false
+}
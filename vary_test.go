@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAddVary(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []string
+		fields   []string
+		want     string
+	}{
+		{"empty", nil, []string{"Origin"}, "Origin"},
+		{"canonical", nil, []string{"accept-encoding"}, "Accept-Encoding"},
+		{"appends", []string{"Origin"}, []string{"Accept-Encoding"}, "Origin, Accept-Encoding"},
+		{"no duplicates", []string{"Origin, accept-encoding"}, []string{"Accept-Encoding", "Origin"}, "Origin, accept-encoding"},
+		{"merges lines", []string{"Origin", "Cookie"}, []string{"Origin"}, "Origin, Cookie"},
+		{"star", []string{"*"}, []string{"Origin"}, "*"},
+		{"star added", []string{"Origin"}, []string{"*", "Cookie"}, "Origin, *"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			for _, v := range tt.existing {
+				h.Add("Vary", v)
+			}
+			AddVary(h, tt.fields...)
+			if got := h.Values("Vary"); len(got) != 1 || got[0] != tt.want {
+				t.Errorf("Expected Vary %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
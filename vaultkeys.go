@@ -0,0 +1,162 @@
+package middleware
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VaultConfig defines the connection to a HashiCorp Vault server.
+type VaultConfig struct {
+	// Address is the URL of the server.
+	// Default: $VAULT_ADDR
+	Address string
+
+	// Token authenticates the requests.
+	// Default: $VAULT_TOKEN
+	Token string
+
+	// Namespace is the Vault Enterprise namespace, if any.
+	// Default: $VAULT_NAMESPACE
+	Namespace string
+
+	// Client sends the requests.
+	// Default: an http.Client with a 10 second timeout
+	Client *http.Client
+}
+
+// VaultKVKeys returns a KeyFetcher reading keys from a KV version 2 secret
+// at path under mount, e.g. ("secret", "app/cookie-keys"). Each field of
+// the secret is a key named after the field. The field named by the
+// optional "current" field signs; the others follow in descending order of
+// name, so that date-based names put newer keys first:
+//
+//	vault kv put secret/app/cookie-keys current=2025-06 2025-06=... 2025-01=...
+//
+// Use it with NewRefreshingKeys.
+func VaultKVKeys(config VaultConfig, mount, path string) KeyFetcher {
+	return func(ctx context.Context) ([]Key, error) {
+		var secret struct {
+			Data struct {
+				Data map[string]string `json:"data"`
+			} `json:"data"`
+		}
+		if err := vaultGet(ctx, config, mount+"/data/"+path, &secret); err != nil {
+			return nil, err
+		}
+
+		fields := secret.Data.Data
+		current := fields["current"]
+		keys := make([]Key, 0, len(fields))
+		for name, value := range fields {
+			if name != "current" && value != "" {
+				keys = append(keys, Key{ID: name, Value: []byte(value)})
+			}
+		}
+		slices.SortFunc(keys, func(a, b Key) int {
+			switch {
+			case a.ID == current:
+				return -1
+			case b.ID == current:
+				return 1
+			}
+			return strings.Compare(b.ID, a.ID)
+		})
+		if current != "" && (len(keys) == 0 || keys[0].ID != current) {
+			return nil, fmt.Errorf("vault: current key %q not found", current)
+		}
+		return keys, nil
+	}
+}
+
+// VaultTransitKeys returns a KeyFetcher exporting the versions of a transit
+// key under mount, e.g. ("transit", "cookies", "hmac-key"). keyType is
+// "hmac-key" or "encryption-key", and the key must be exportable. Versions
+// are named by number, the latest first, so that rotating the key in Vault
+// (vault write -f transit/keys/cookies/rotate) rotates the signing key
+// while older versions still verify.
+func VaultTransitKeys(config VaultConfig, mount, name, keyType string) KeyFetcher {
+	return func(ctx context.Context) ([]Key, error) {
+		var export struct {
+			Data struct {
+				Keys map[string]string `json:"keys"`
+			} `json:"data"`
+		}
+		if err := vaultGet(ctx, config, mount+"/export/"+keyType+"/"+name, &export); err != nil {
+			return nil, err
+		}
+
+		type version struct {
+			n   int
+			key Key
+		}
+		versions := make([]version, 0, len(export.Data.Keys))
+		for v, encoded := range export.Data.Keys {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("vault: invalid key version %q", v)
+			}
+			value, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return nil, fmt.Errorf("vault: key version %s: %w", v, err)
+			}
+			versions = append(versions, version{n: n, key: Key{ID: v, Value: value}})
+		}
+		slices.SortFunc(versions, func(a, b version) int { return b.n - a.n })
+
+		keys := make([]Key, len(versions))
+		for i, v := range versions {
+			keys[i] = v.key
+		}
+		return keys, nil
+	}
+}
+
+// vaultGet reads path from the Vault HTTP API into out.
+func vaultGet(ctx context.Context, config VaultConfig, path string, out any) error {
+	address := cmp.Or(config.Address, os.Getenv("VAULT_ADDR"))
+	if address == "" {
+		return errors.New("vault: Address is required")
+	}
+	client := config.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	u, err := url.JoinPath(address, "v1", path)
+	if err != nil {
+		return fmt.Errorf("vault: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return fmt.Errorf("vault: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", cmp.Or(config.Token, os.Getenv("VAULT_TOKEN")))
+	if namespace := cmp.Or(config.Namespace, os.Getenv("VAULT_NAMESPACE")); namespace != "" {
+		req.Header.Set("X-Vault-Namespace", namespace)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("vault: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("vault: unexpected status %d for %s", resp.StatusCode, path)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("vault: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestVault(t *testing.T, responses map[string]string) VaultConfig {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.test" || r.Header.Get("X-Vault-Namespace") != "team" {
+			http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+			return
+		}
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return VaultConfig{Address: server.URL, Token: "s.test", Namespace: "team"}
+}
+
+func TestVaultKVKeys(t *testing.T) {
+	config := newTestVault(t, map[string]string{
+		"/v1/secret/data/app/keys": `{"data":{"data":{"current":"2025-01","2025-06":"next","2025-01":"live","2024-06":"old"}}}`,
+		"/v1/secret/data/app/bad":  `{"data":{"data":{"current":"2030-01","2025-01":"live"}}}`,
+	})
+
+	keys, err := VaultKVKeys(config, "secret", "app/keys")(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, key := range keys {
+		ids = append(ids, key.ID)
+	}
+	if got := strings.Join(ids, ","); got != "2025-01,2025-06,2024-06" || string(keys[0].Value) != "live" {
+		t.Errorf("Expected the current key first, got %s", got)
+	}
+
+	if _, err := VaultKVKeys(config, "secret", "app/bad")(context.Background()); err == nil {
+		t.Error("Expected an error for a missing current key")
+	}
+	if _, err := VaultKVKeys(config, "secret", "app/missing")(context.Background()); err == nil {
+		t.Error("Expected an error for a missing secret")
+	}
+	config.Token = "wrong"
+	if _, err := VaultKVKeys(config, "secret", "app/keys")(context.Background()); err == nil {
+		t.Error("Expected an error for a denied request")
+	}
+}
+
+func TestVaultTransitKeys(t *testing.T) {
+	v1 := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("1", 32)))
+	v2 := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("2", 32)))
+	v10 := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("a", 32)))
+	config := newTestVault(t, map[string]string{
+		"/v1/transit/export/hmac-key/cookies": `{"data":{"name":"cookies","keys":{"1":"` + v1 + `","2":"` + v2 + `","10":"` + v10 + `"}}}`,
+	})
+
+	keys, err := VaultTransitKeys(config, "transit", "cookies", "hmac-key")(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 3 || keys[0].ID != "10" || keys[1].ID != "2" || keys[2].ID != "1" {
+		t.Fatalf("Expected versions newest first, got %+v", keys)
+	}
+	if string(keys[0].Value) != strings.Repeat("a", 32) {
+		t.Errorf("Expected the decoded key, got %q", keys[0].Value)
+	}
+}
@@ -0,0 +1,369 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// WAFMode controls what the WAF does when a rule matches.
+type WAFMode int
+
+const (
+	// WAFModeBlock rejects requests that match a rule.
+	WAFModeBlock WAFMode = iota
+	// WAFModeDetect only records matches and lets requests through.
+	WAFModeDetect
+)
+
+// WAF request targets inspected by rules.
+const (
+	WAFTargetPath   = "path"
+	WAFTargetQuery  = "query"
+	WAFTargetHeader = "header"
+	WAFTargetCookie = "cookie"
+	WAFTargetBody   = "body"
+)
+
+// WAFRule is a single pattern-based detection rule.
+type WAFRule struct {
+	// ID uniquely identifies the rule, e.g. "sqli-union".
+	ID string
+
+	// Category groups related rules, e.g. "sqli", "xss".
+	Category string
+
+	// Pattern is matched against inspected values.
+	Pattern *regexp.Regexp
+
+	// Targets restricts the rule to certain parts of the request.
+	// If empty, all targets are inspected.
+	Targets []string
+}
+
+// WAFAllow exempts parts of the traffic from a rule.
+type WAFAllow struct {
+	// Paths are request path prefixes the rule does not apply to.
+	Paths []string
+
+	// Fields are query, header, cookie or form field names the rule does not
+	// apply to.
+	Fields []string
+}
+
+// WAFMatch describes a rule match, used for audit logging.
+type WAFMatch struct {
+	RuleID     string    `json:"rule_id"`
+	Category   string    `json:"category"`
+	Target     string    `json:"target"`
+	Field      string    `json:"field,omitempty"`
+	Value      string    `json:"value"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	RemoteAddr string    `json:"remote_addr"`
+	Blocked    bool      `json:"blocked"`
+	Time       time.Time `json:"time"`
+}
+
+// WAFConfig defines the configuration for the WAF middleware.
+type WAFConfig struct {
+	// Mode selects blocking or detect-only operation.
+	// Default: WAFModeBlock
+	Mode WAFMode
+
+	// Rules are the rules to evaluate.
+	// Default: DefaultWAFRules()
+	Rules []WAFRule
+
+	// MaxBodyBytes caps how much of the request body is inspected.
+	// Default: 64KB
+	MaxBodyBytes int64
+
+	// Allowlist maps rule IDs to exemptions.
+	Allowlist map[string]WAFAllow
+
+	// OnMatch is called for every rule match. If nil, matches are logged
+	// to Logger as structured records.
+	OnMatch func(WAFMatch)
+
+	// Logger receives the audit log when OnMatch is nil.
+	// Default: slog.Default()
+	Logger *slog.Logger
+
+	// Scrubber, if set, removes PII from matched values before they are
+	// passed to OnMatch. Cookie values are always redacted.
+	Scrubber *Scrubber
+
+	// StatusCode is returned for blocked requests.
+	// Default: 403 Forbidden
+	StatusCode int
+
+	// SkipFunc allows skipping inspection for certain requests.
+	SkipFunc Skipper
+}
+
+// SQLInjectionRules returns the built-in SQL injection rule set.
+func SQLInjectionRules() []WAFRule {
+	return []WAFRule{
+		{ID: "sqli-union", Category: "sqli", Pattern: regexp.MustCompile(`(?i)\bunion\b[\s\S]*?\bselect\b`)},
+		{ID: "sqli-tautology", Category: "sqli", Pattern: regexp.MustCompile(`(?i)['"]\s*(or|and)\s+['"]?\w+['"]?\s*=\s*['"]?\w+`)},
+		{ID: "sqli-comment", Category: "sqli", Pattern: regexp.MustCompile(`(?i)['"]\s*(--|#|/\*)`)},
+		{ID: "sqli-stacked", Category: "sqli", Pattern: regexp.MustCompile(`(?i);\s*(drop|delete|insert|update|alter|create|truncate)\s`)},
+		{ID: "sqli-timing", Category: "sqli", Pattern: regexp.MustCompile(`(?i)\b(sleep|benchmark|pg_sleep)\s*\(|\bwaitfor\s+delay\b`)},
+	}
+}
+
+// XSSRules returns the built-in cross-site scripting rule set.
+func XSSRules() []WAFRule {
+	return []WAFRule{
+		{ID: "xss-script", Category: "xss", Pattern: regexp.MustCompile(`(?i)<\s*script\b`)},
+		{ID: "xss-handler", Category: "xss", Pattern: regexp.MustCompile(`(?i)<[^>]*\bon[a-z]+\s*=`)},
+		{ID: "xss-js-uri", Category: "xss", Pattern: regexp.MustCompile(`(?i)javascript\s*:`)},
+		{ID: "xss-embed", Category: "xss", Pattern: regexp.MustCompile(`(?i)<\s*(iframe|object|embed)\b`)},
+	}
+}
+
+// PathTraversalRules returns the built-in path traversal rule set.
+func PathTraversalRules() []WAFRule {
+	return []WAFRule{
+		{ID: "traversal-dotdot", Category: "traversal", Pattern: regexp.MustCompile(`(?i)(\.\.[/\\])|([/\\]\.\.$)|(%2e%2e(%2f|%5c|/|\\))`)},
+		{ID: "traversal-sensitive", Category: "traversal", Pattern: regexp.MustCompile(`(?i)(/etc/(passwd|shadow|hosts)|win\.ini|boot\.ini)`)},
+	}
+}
+
+// CommandInjectionRules returns the built-in command injection rule set.
+func CommandInjectionRules() []WAFRule {
+	return []WAFRule{
+		{ID: "cmdi-chain", Category: "cmdi", Pattern: regexp.MustCompile("(?i)(;|\\||&&|\\$\\(|`)\\s*(cat|ls|id|whoami|uname|wget|curl|nc|bash|sh|rm|ping|chmod)\\b")},
+	}
+}
+
+// DefaultWAFRules returns all built-in rule sets.
+func DefaultWAFRules() []WAFRule {
+	var rules []WAFRule
+	rules = append(rules, SQLInjectionRules()...)
+	rules = append(rules, XSSRules()...)
+	rules = append(rules, PathTraversalRules()...)
+	rules = append(rules, CommandInjectionRules()...)
+	return rules
+}
+
+// DefaultWAFConfig returns default WAF configuration.
+func DefaultWAFConfig() WAFConfig {
+	return WAFConfig{
+		Mode:         WAFModeBlock,
+		Rules:        DefaultWAFRules(),
+		MaxBodyBytes: 64 << 10, // 64 KB
+		StatusCode:   http.StatusForbidden,
+	}
+}
+
+// WAF returns web application firewall middleware with default configuration.
+func WAF() ginji.Middleware {
+	return WAFWithConfig(DefaultWAFConfig())
+}
+
+// WAFWithConfig returns web application firewall middleware with custom configuration.
+func WAFWithConfig(config WAFConfig) ginji.Middleware {
+	// Set defaults
+	if config.Rules == nil {
+		config.Rules = DefaultWAFRules()
+	}
+	if config.MaxBodyBytes <= 0 {
+		config.MaxBodyBytes = 64 << 10
+	}
+	if config.StatusCode == 0 {
+		config.StatusCode = http.StatusForbidden
+	}
+	if config.OnMatch == nil {
+		logger := config.Logger
+		config.OnMatch = func(m WAFMatch) {
+			if logger == nil {
+				logger = slog.Default()
+			}
+			logger.Warn("WAF rule matched",
+				slog.String("rule_id", m.RuleID),
+				slog.String("category", m.Category),
+				slog.String("target", m.Target),
+				slog.String("field", m.Field),
+				slog.String("value", m.Value),
+				slog.String("method", m.Method),
+				slog.String("path", m.Path),
+				slog.String("remote_addr", m.RemoteAddr),
+				slog.Bool("blocked", m.Blocked),
+			)
+		}
+	}
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		inspector := &wafInspector{config: &config, c: c}
+		inspector.inspect(WAFTargetPath, "", c.Req.URL.EscapedPath())
+		for name, values := range c.Req.URL.Query() {
+			for _, v := range values {
+				inspector.inspect(WAFTargetQuery, name, v)
+			}
+		}
+		for name, values := range c.Req.Header {
+			// Credentials are opaque and cookies are inspected one by
+			// one below, since "; " separates cookie pairs.
+			if name == "Authorization" || name == "Proxy-Authorization" || name == "Cookie" {
+				continue
+			}
+			for _, v := range values {
+				inspector.inspect(WAFTargetHeader, name, v)
+			}
+		}
+		for _, cookie := range c.Req.Cookies() {
+			inspector.inspect(WAFTargetCookie, cookie.Name, cookie.Value)
+		}
+		if body := peekBody(c, config.MaxBodyBytes); len(body) > 0 {
+			inspector.inspectBody(c, body)
+		}
+
+		if inspector.matched && config.Mode == WAFModeBlock {
+			c.AbortWithStatusJSON(config.StatusCode, ginji.H{
+				"error": "Request blocked",
+			})
+			return nil
+		}
+
+		return c.Next()
+	}
+}
+
+// wafInspector evaluates rules for a single request.
+type wafInspector struct {
+	config  *WAFConfig
+	c       *ginji.Context
+	matched bool
+}
+
+// inspect evaluates all applicable rules against a single value.
+func (w *wafInspector) inspect(target, field, value string) {
+	if value == "" {
+		return
+	}
+	for _, rule := range w.config.Rules {
+		if !ruleTargets(rule, target) || w.allowed(rule.ID, field) {
+			continue
+		}
+		if rule.Pattern.MatchString(value) {
+			w.matched = true
+			w.config.OnMatch(WAFMatch{
+				RuleID:     rule.ID,
+				Category:   rule.Category,
+				Target:     target,
+				Field:      field,
+				Value:      w.loggedValue(target, field, value),
+				Method:     w.c.Req.Method,
+				Path:       w.c.Req.URL.Path,
+				RemoteAddr: w.c.Req.RemoteAddr,
+				Blocked:    w.config.Mode == WAFModeBlock,
+				Time:       time.Now(),
+			})
+		}
+	}
+}
+
+// loggedValue returns value as recorded in a match: truncated, scrubbed,
+// and redacted for cookies, which often carry session tokens.
+func (w *wafInspector) loggedValue(target, field, value string) string {
+	if target == WAFTargetCookie {
+		return "[REDACTED]"
+	}
+	value = truncate(value, 128)
+	if w.config.Scrubber != nil {
+		value, _ = w.config.Scrubber.Field(field, value)
+	}
+	return value
+}
+
+// inspectBody evaluates rules against the body, field by field for forms.
+func (w *wafInspector) inspectBody(c *ginji.Context, body []byte) {
+	if strings.HasPrefix(c.Header("Content-Type"), "application/x-www-form-urlencoded") {
+		if values, err := url.ParseQuery(string(body)); err == nil {
+			for name, vals := range values {
+				for _, v := range vals {
+					w.inspect(WAFTargetBody, name, v)
+				}
+			}
+			return
+		}
+	}
+	w.inspect(WAFTargetBody, "", string(body))
+}
+
+// allowed reports whether the rule is exempted for the current path or field.
+func (w *wafInspector) allowed(ruleID, field string) bool {
+	allow, ok := w.config.Allowlist[ruleID]
+	if !ok {
+		return false
+	}
+	for _, prefix := range allow.Paths {
+		if strings.HasPrefix(w.c.Req.URL.Path, prefix) {
+			return true
+		}
+	}
+	for _, name := range allow.Fields {
+		if field != "" && strings.EqualFold(name, field) {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleTargets reports whether a rule applies to the given target.
+func ruleTargets(rule WAFRule, target string) bool {
+	if len(rule.Targets) == 0 {
+		return true
+	}
+	for _, t := range rule.Targets {
+		if t == target {
+			return true
+		}
+	}
+	return false
+}
+
+// peekBody reads up to limit bytes of the request body and restores it so
+// downstream handlers can still read the full body.
+func peekBody(c *ginji.Context, limit int64) []byte {
+	if c.Req.Body == nil || c.Req.Body == http.NoBody {
+		return nil
+	}
+	buf, err := io.ReadAll(io.LimitReader(c.Req.Body, limit))
+	c.Req.Body = readCloser{
+		Reader: io.MultiReader(bytes.NewReader(buf), c.Req.Body),
+		Closer: c.Req.Body,
+	}
+	if err != nil {
+		return nil
+	}
+	return buf
+}
+
+// readCloser combines a reader with the closer of the original body.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// truncate shortens s to at most n bytes.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
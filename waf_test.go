@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ginjigo/ginji"
+)
+
+func TestWAFBlocksSQLInjection(t *testing.T) {
+	app := ginji.New()
+	app.Use(WAFWithConfig(WAFConfig{OnMatch: func(WAFMatch) {}}))
+
+	app.Get("/search", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	q := url.Values{"q": {"1' OR '1'='1"}}
+	w := ginji.PerformRequest(app, "GET", "/search?"+q.Encode(), nil)
+	if w.Code != ginji.StatusForbidden {
+		t.Errorf("Expected status 403, got %d", w.Code)
+	}
+
+	w = ginji.PerformRequest(app, "GET", "/search?q=golang+middleware", nil)
+	if w.Code != ginji.StatusOK {
+		t.Errorf("Expected status 200 for clean query, got %d", w.Code)
+	}
+}
+
+func TestWAFInspectsBodyAndRestoresIt(t *testing.T) {
+	app := ginji.New()
+	app.Use(WAFWithConfig(WAFConfig{OnMatch: func(WAFMatch) {}}))
+
+	app.Post("/comments", func(c *ginji.Context) error {
+		body, _ := io.ReadAll(c.Req.Body)
+		return c.Text(ginji.StatusOK, string(body))
+	})
+
+	w := ginji.PerformRequest(app, "POST", "/comments", strings.NewReader(`{"text":"<script>alert(1)</script>"}`))
+	if w.Code != ginji.StatusForbidden {
+		t.Errorf("Expected status 403, got %d", w.Code)
+	}
+
+	w = ginji.PerformRequest(app, "POST", "/comments", strings.NewReader(`{"text":"hello"}`))
+	if w.Code != ginji.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	ginji.AssertBody(t, w, `{"text":"hello"}`)
+}
+
+func TestWAFDetectMode(t *testing.T) {
+	var matches []WAFMatch
+	app := ginji.New()
+	app.Use(WAFWithConfig(WAFConfig{
+		Mode:    WAFModeDetect,
+		OnMatch: func(m WAFMatch) { matches = append(matches, m) },
+	}))
+
+	app.Get("/files", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/files?name=../../etc/passwd", nil)
+	if w.Code != ginji.StatusOK {
+		t.Errorf("Expected detect mode to pass request, got %d", w.Code)
+	}
+	if len(matches) == 0 {
+		t.Fatal("Expected traversal match to be recorded")
+	}
+	if matches[0].Category != "traversal" || matches[0].Field != "name" || matches[0].Blocked {
+		t.Errorf("Unexpected match: %+v", matches[0])
+	}
+}
+
+func TestWAFAllowlist(t *testing.T) {
+	app := ginji.New()
+	app.Use(WAFWithConfig(WAFConfig{
+		OnMatch: func(WAFMatch) {},
+		Allowlist: map[string]WAFAllow{
+			"xss-script": {Paths: []string{"/admin/templates"}},
+		},
+	}))
+
+	app.Post("/admin/templates", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "saved")
+	})
+
+	req := httptest.NewRequest("POST", "/admin/templates", strings.NewReader("<script src=app.js>"))
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+
+	if w.Code != ginji.StatusOK {
+		t.Errorf("Expected allowlisted request to pass, got %d", w.Code)
+	}
+}
+
+func TestWAFCommandInjectionHeader(t *testing.T) {
+	app := ginji.New()
+	app.Use(WAFWithConfig(WAFConfig{OnMatch: func(WAFMatch) {}}))
+
+	app.Get("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Forwarded-Host", "example.com; cat /etc/hosts")
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+
+	if w.Code != ginji.StatusForbidden {
+		t.Errorf("Expected status 403, got %d", w.Code)
+	}
+}
+
+func TestWAFCookiesAndCredentials(t *testing.T) {
+	var matches []WAFMatch
+	app := ginji.New()
+	app.Use(WAFWithConfig(WAFConfig{OnMatch: func(m WAFMatch) { matches = append(matches, m) }}))
+
+	app.Get("/", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "ok")
+	})
+
+	// Cookie pairs and credentials are not matched as one string
+	w := ginji.NewRequest(app, "GET", "/").
+		Header("Cookie", "theme=dark; id=5").
+		Header("Authorization", "Bearer abc; id").
+		Do()
+	if w.Code != ginji.StatusOK {
+		t.Errorf("Expected status 200, got %d (matches %+v)", w.Code, matches)
+	}
+
+	// Cookie values are still inspected, but never logged
+	w = ginji.NewRequest(app, "GET", "/").
+		Header("Cookie", "session=s3cret; pref=$(id)").
+		Do()
+	if w.Code != ginji.StatusForbidden {
+		t.Fatalf("Expected status 403, got %d", w.Code)
+	}
+	for _, m := range matches {
+		if m.Target != WAFTargetCookie || m.Field != "pref" || m.Value != "[REDACTED]" {
+			t.Errorf("Expected redacted cookie match, got %+v", m)
+		}
+	}
+}
@@ -0,0 +1,406 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// Webhook verification errors.
+var (
+	ErrWebhookSignature = errors.New("webhook: invalid signature")
+	ErrWebhookMalformed = errors.New("webhook: missing or malformed signature headers")
+)
+
+// WebhookDelivery describes a verified webhook request.
+type WebhookDelivery struct {
+	// ID identifies the delivery for replay detection.
+	ID string
+
+	// Timestamp is the signed delivery time, if the scheme includes one.
+	Timestamp time.Time
+}
+
+// WebhookVerifier checks the signature of a webhook request against secret.
+// It returns ErrWebhookSignature if the signature does not match.
+type WebhookVerifier func(r *http.Request, body, secret []byte) (WebhookDelivery, error)
+
+// GitHubWebhook verifies the X-Hub-Signature-256 header sent by GitHub.
+func GitHubWebhook() WebhookVerifier {
+	return func(r *http.Request, body, secret []byte) (WebhookDelivery, error) {
+		sig, ok := strings.CutPrefix(r.Header.Get("X-Hub-Signature-256"), "sha256=")
+		if !ok {
+			return WebhookDelivery{}, ErrWebhookMalformed
+		}
+		if !hmacHexEqual(sha256.New, secret, body, sig) {
+			return WebhookDelivery{}, ErrWebhookSignature
+		}
+		return WebhookDelivery{ID: r.Header.Get("X-GitHub-Delivery")}, nil
+	}
+}
+
+// StripeWebhook verifies the Stripe-Signature header sent by Stripe.
+func StripeWebhook() WebhookVerifier {
+	return func(r *http.Request, body, secret []byte) (WebhookDelivery, error) {
+		header := r.Header.Get("Stripe-Signature")
+		var ts string
+		var sigs []string
+		for _, part := range strings.Split(header, ",") {
+			k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+			switch k {
+			case "t":
+				ts = v
+			case "v1":
+				sigs = append(sigs, v)
+			}
+		}
+		timestamp, err := parseUnixTimestamp(ts)
+		if err != nil || len(sigs) == 0 {
+			return WebhookDelivery{}, ErrWebhookMalformed
+		}
+
+		payload := append([]byte(ts+"."), body...)
+		for _, sig := range sigs {
+			if hmacHexEqual(sha256.New, secret, payload, sig) {
+				return WebhookDelivery{ID: ts + ":" + sig, Timestamp: timestamp}, nil
+			}
+		}
+		return WebhookDelivery{}, ErrWebhookSignature
+	}
+}
+
+// SlackWebhook verifies the X-Slack-Signature header sent by Slack.
+func SlackWebhook() WebhookVerifier {
+	return func(r *http.Request, body, secret []byte) (WebhookDelivery, error) {
+		ts := r.Header.Get("X-Slack-Request-Timestamp")
+		sig, ok := strings.CutPrefix(r.Header.Get("X-Slack-Signature"), "v0=")
+		timestamp, err := parseUnixTimestamp(ts)
+		if !ok || err != nil {
+			return WebhookDelivery{}, ErrWebhookMalformed
+		}
+
+		payload := append([]byte("v0:"+ts+":"), body...)
+		if !hmacHexEqual(sha256.New, secret, payload, sig) {
+			return WebhookDelivery{}, ErrWebhookSignature
+		}
+		return WebhookDelivery{ID: ts + ":" + sig, Timestamp: timestamp}, nil
+	}
+}
+
+// TwilioWebhook verifies the X-Twilio-Signature header sent by Twilio.
+// Twilio signs the public URL it called; urlFunc reconstructs it when the
+// application runs behind a proxy. If urlFunc is nil, the URL is built
+// from the request Host and scheme.
+func TwilioWebhook(urlFunc func(*http.Request) string) WebhookVerifier {
+	if urlFunc == nil {
+		urlFunc = requestURL
+	}
+	return func(r *http.Request, body, secret []byte) (WebhookDelivery, error) {
+		sig := r.Header.Get("X-Twilio-Signature")
+		if sig == "" {
+			return WebhookDelivery{}, ErrWebhookMalformed
+		}
+
+		payload := urlFunc(r)
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+			form, err := url.ParseQuery(string(body))
+			if err != nil {
+				return WebhookDelivery{}, ErrWebhookMalformed
+			}
+			keys := make([]string, 0, len(form))
+			for k := range form {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				for _, v := range form[k] {
+					payload += k + v
+				}
+			}
+		}
+
+		mac := hmac.New(sha1.New, secret)
+		mac.Write([]byte(payload))
+		expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+		if !hmac.Equal([]byte(expected), []byte(sig)) {
+			return WebhookDelivery{}, ErrWebhookSignature
+		}
+		return WebhookDelivery{ID: r.Header.Get("I-Twilio-Idempotency-Token")}, nil
+	}
+}
+
+// HMACWebhookConfig describes a generic HMAC signature scheme.
+type HMACWebhookConfig struct {
+	// SignatureHeader carries the signature.
+	// Default: "X-Signature"
+	SignatureHeader string
+
+	// SignaturePrefix is stripped from the header value, e.g. "sha256=".
+	SignaturePrefix string
+
+	// TimestampHeader carries the Unix timestamp. If set, the signed payload
+	// is "<timestamp>.<body>"; otherwise just the body.
+	TimestampHeader string
+
+	// IDHeader carries a unique delivery ID used for replay detection.
+	IDHeader string
+
+	// Hash is the HMAC hash function.
+	// Default: sha256.New
+	Hash func() hash.Hash
+
+	// Base64 selects base64 instead of hex signature encoding.
+	Base64 bool
+}
+
+// HMACWebhook verifies a generic HMAC signature scheme.
+func HMACWebhook(config HMACWebhookConfig) WebhookVerifier {
+	if config.SignatureHeader == "" {
+		config.SignatureHeader = "X-Signature"
+	}
+	if config.Hash == nil {
+		config.Hash = sha256.New
+	}
+
+	return func(r *http.Request, body, secret []byte) (WebhookDelivery, error) {
+		sig, ok := strings.CutPrefix(r.Header.Get(config.SignatureHeader), config.SignaturePrefix)
+		if !ok || sig == "" {
+			return WebhookDelivery{}, ErrWebhookMalformed
+		}
+
+		delivery := WebhookDelivery{ID: sig}
+		if config.IDHeader != "" {
+			delivery.ID = r.Header.Get(config.IDHeader)
+		}
+
+		payload := body
+		if config.TimestampHeader != "" {
+			ts := r.Header.Get(config.TimestampHeader)
+			timestamp, err := parseUnixTimestamp(ts)
+			if err != nil {
+				return WebhookDelivery{}, ErrWebhookMalformed
+			}
+			delivery.Timestamp = timestamp
+			payload = append([]byte(ts+"."), body...)
+		}
+
+		mac := hmac.New(config.Hash, secret)
+		mac.Write(payload)
+		sum := mac.Sum(nil)
+
+		var expected []byte
+		if config.Base64 {
+			expected = []byte(base64.StdEncoding.EncodeToString(sum))
+		} else {
+			expected = []byte(hex.EncodeToString(sum))
+			sig = strings.ToLower(sig)
+		}
+		if !hmac.Equal(expected, []byte(sig)) {
+			return WebhookDelivery{}, ErrWebhookSignature
+		}
+		return delivery, nil
+	}
+}
+
+// hmacHexEqual compares a hex-encoded HMAC of payload in constant time.
+func hmacHexEqual(h func() hash.Hash, secret, payload []byte, sig string) bool {
+	mac := hmac.New(h, secret)
+	mac.Write(payload)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(strings.ToLower(sig)))
+}
+
+// parseUnixTimestamp parses a Unix timestamp in seconds.
+func parseUnixTimestamp(s string) (time.Time, error) {
+	sec, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(sec, 0), nil
+}
+
+// WebhookConfig defines the configuration for webhook verification middleware.
+type WebhookConfig struct {
+	// Verifier checks request signatures. Required.
+	Verifier WebhookVerifier
+
+	// Secrets are the signing secrets. Any match is accepted, which allows
+	// rotating secrets without downtime. Required.
+	Secrets []string
+
+	// SecretProvider supplies the secrets instead of Secrets, e.g. from
+	// environment variables or a file that is reloaded on rotation.
+	SecretProvider KeyProvider
+
+	// Tolerance is the maximum age of a signed timestamp.
+	// Default: 5 minutes
+	Tolerance time.Duration
+
+	// ReplayWindow is how long delivery IDs are remembered to reject replays.
+	// Deliveries whose handler fails, with an error or a 5xx status, are
+	// forgotten so that the sender can retry them.
+	// Default: 24 hours
+	ReplayWindow time.Duration
+
+	// ReplayCacheSize is the maximum number of remembered delivery IDs.
+	// Default: 10000
+	ReplayCacheSize int
+
+	// MaxBodySize is the maximum body size read for verification.
+	// Default: 1MB
+	MaxBodySize int64
+
+	// ContextKey is the key used to store the WebhookDelivery in context.
+	// Default: "webhook"
+	ContextKey string
+
+	// SkipFunc allows skipping verification for certain requests.
+	SkipFunc Skipper
+}
+
+// DefaultWebhookConfig returns default webhook configuration.
+func DefaultWebhookConfig() WebhookConfig {
+	return WebhookConfig{
+		Tolerance:       5 * time.Minute,
+		ReplayWindow:    24 * time.Hour,
+		ReplayCacheSize: 10000,
+		MaxBodySize:     1 << 20,
+		ContextKey:      "webhook",
+	}
+}
+
+// WebhookVerify returns middleware that verifies webhook signatures.
+func WebhookVerify(verifier WebhookVerifier, secret string) ginji.Middleware {
+	config := DefaultWebhookConfig()
+	config.Verifier = verifier
+	config.Secrets = []string{secret}
+	return WebhookVerifyWithConfig(config)
+}
+
+// WebhookVerifyWithConfig returns webhook verification middleware with custom configuration.
+// The raw body is buffered as with BufferBody, so handlers can still bind it.
+func WebhookVerifyWithConfig(config WebhookConfig) ginji.Middleware {
+	if config.Verifier == nil {
+		panic("WebhookVerify: Verifier is required")
+	}
+	secrets := config.SecretProvider
+	if secrets == nil {
+		if len(config.Secrets) == 0 {
+			panic("WebhookVerify: at least one secret is required")
+		}
+		values := make([][]byte, len(config.Secrets))
+		for i, secret := range config.Secrets {
+			values[i] = []byte(secret)
+		}
+		secrets = staticKeyValues(values)
+	}
+	defaults := DefaultWebhookConfig()
+	if config.Tolerance == 0 {
+		config.Tolerance = defaults.Tolerance
+	}
+	if config.ReplayWindow == 0 {
+		config.ReplayWindow = defaults.ReplayWindow
+	}
+	if config.ReplayCacheSize == 0 {
+		config.ReplayCacheSize = defaults.ReplayCacheSize
+	}
+	if config.MaxBodySize == 0 {
+		config.MaxBodySize = defaults.MaxBodySize
+	}
+	if config.ContextKey == "" {
+		config.ContextKey = defaults.ContextKey
+	}
+
+	seen := newLRUCache[string, struct{}](config.ReplayCacheSize, config.ReplayWindow)
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		body, err := bufferRequestBody(c, config.MaxBodySize)
+		if err != nil {
+			if errors.Is(err, errBodyTooLarge) {
+				c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, ginji.H{
+					"error": "Request body too large",
+				})
+				return nil
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, ginji.H{
+				"error": "Failed to read request body",
+			})
+			return nil
+		}
+
+		keys, err := loadKeys(secrets, 1)
+		if err != nil {
+			return fmt.Errorf("webhook: %w", err)
+		}
+
+		var delivery WebhookDelivery
+		for _, key := range keys {
+			delivery, err = config.Verifier(c.Req, body, key.Value)
+			if !errors.Is(err, ErrWebhookSignature) {
+				break
+			}
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, ginji.H{
+				"error": "Invalid webhook signature",
+			})
+			return nil
+		}
+
+		if !delivery.Timestamp.IsZero() {
+			if age := time.Since(delivery.Timestamp); age > config.Tolerance || age < -config.Tolerance {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, ginji.H{
+					"error": "Stale webhook delivery",
+				})
+				return nil
+			}
+		}
+
+		// The ID is reserved while the handler runs, so concurrent
+		// duplicates are rejected too
+		failed := true
+		if delivery.ID != "" {
+			if !seen.AddIfAbsent(delivery.ID, struct{}{}) {
+				c.AbortWithStatusJSON(http.StatusConflict, ginji.H{
+					"error": "Duplicate webhook delivery",
+				})
+				return nil
+			}
+			defer func() {
+				if failed {
+					seen.Remove(delivery.ID)
+				}
+			}()
+		}
+
+		c.Set(config.ContextKey, delivery)
+		setContextValue(c, webhookContextKey, delivery)
+		err = c.Next()
+		failed = err != nil || c.StatusCode() >= 500
+		return err
+	}
+}
+
+// GetWebhookDelivery returns the delivery verified by the WebhookVerify middleware.
+func GetWebhookDelivery(c *ginji.Context) (WebhookDelivery, bool) {
+	delivery, ok := c.Req.Context().Value(webhookContextKey).(WebhookDelivery)
+	return delivery, ok
+}
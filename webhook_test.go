@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+const testWebhookSecret = "whsec_test"
+
+func hmacHex(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func sendWebhook(app *ginji.Engine, body string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/hook", strings.NewReader(body))
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	return w
+}
+
+func TestWebhookGitHub(t *testing.T) {
+	app := ginji.New()
+	app.Use(WebhookVerify(GitHubWebhook(), testWebhookSecret))
+	app.Post("/hook", func(c *ginji.Context) error {
+		// The body must still be readable after verification
+		body, _ := io.ReadAll(c.Req.Body)
+		return c.Text(ginji.StatusOK, string(body))
+	})
+	body := `{"action":"opened"}`
+
+	header := http.Header{
+		"X-Hub-Signature-256": {"sha256=" + hmacHex(testWebhookSecret, body)},
+		"X-Github-Delivery":   {"delivery-1"},
+	}
+	w := sendWebhook(app, body, header)
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+	ginji.AssertBody(t, w, body)
+
+	// Replaying the same delivery is rejected
+	w = sendWebhook(app, body, header)
+	ginji.AssertStatus(t, w, ginji.StatusConflict)
+
+	w = sendWebhook(app, body, http.Header{
+		"X-Hub-Signature-256": {"sha256=" + hmacHex("wrong", body)},
+	})
+	ginji.AssertStatus(t, w, ginji.StatusUnauthorized)
+}
+
+func TestWebhookRetryAfterFailure(t *testing.T) {
+	status := ginji.StatusServiceUnavailable
+	app := ginji.New()
+	app.Use(WebhookVerify(GitHubWebhook(), testWebhookSecret))
+	app.Post("/hook", func(c *ginji.Context) error {
+		return c.Text(status, "done")
+	})
+	body := `{"action":"opened"}`
+	header := http.Header{
+		"X-Hub-Signature-256": {"sha256=" + hmacHex(testWebhookSecret, body)},
+		"X-Github-Delivery":   {"delivery-1"},
+	}
+
+	// A failed delivery is not recorded, so its retry is handled
+	ginji.AssertStatus(t, sendWebhook(app, body, header), ginji.StatusServiceUnavailable)
+	status = ginji.StatusOK
+	ginji.AssertStatus(t, sendWebhook(app, body, header), ginji.StatusOK)
+	ginji.AssertStatus(t, sendWebhook(app, body, header), ginji.StatusConflict)
+}
+
+func TestWebhookStripeTolerance(t *testing.T) {
+	app := ginji.New()
+	app.Use(WebhookVerify(StripeWebhook(), testWebhookSecret))
+	app.Post("/hook", func(c *ginji.Context) error {
+		// The body must still be readable after verification
+		body, _ := io.ReadAll(c.Req.Body)
+		return c.Text(ginji.StatusOK, string(body))
+	})
+	body := `{"type":"charge.succeeded"}`
+
+	sign := func(ts time.Time) http.Header {
+		t := strconv.FormatInt(ts.Unix(), 10)
+		return http.Header{"Stripe-Signature": {"t=" + t + ",v1=" + hmacHex(testWebhookSecret, t+"."+body)}}
+	}
+
+	ginji.AssertStatus(t, sendWebhook(app, body, sign(time.Now())), ginji.StatusOK)
+
+	w := sendWebhook(app, body, sign(time.Now().Add(-10*time.Minute)))
+	ginji.AssertStatus(t, w, ginji.StatusUnauthorized)
+	ginji.AssertBody(t, w, "Stale webhook delivery")
+}
+
+func TestWebhookSlackSecretRotation(t *testing.T) {
+	config := DefaultWebhookConfig()
+	config.Verifier = SlackWebhook()
+	config.Secrets = []string{"new-secret", "old-secret"}
+	app := ginji.New()
+	app.Use(WebhookVerifyWithConfig(config))
+	app.Post("/hook", func(c *ginji.Context) error {
+		// The body must still be readable after verification
+		body, _ := io.ReadAll(c.Req.Body)
+		return c.Text(ginji.StatusOK, string(body))
+	})
+
+	body := "token=x&team_id=T1"
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	w := sendWebhook(app, body, http.Header{
+		"X-Slack-Request-Timestamp": {ts},
+		"X-Slack-Signature":         {"v0=" + hmacHex("old-secret", "v0:"+ts+":"+body)},
+	})
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+}
+
+func TestWebhookTwilio(t *testing.T) {
+	app := ginji.New()
+	app.Use(WebhookVerify(TwilioWebhook(nil), testWebhookSecret))
+	app.Post("/hook", func(c *ginji.Context) error {
+		// The body must still be readable after verification
+		body, _ := io.ReadAll(c.Req.Body)
+		return c.Text(ginji.StatusOK, string(body))
+	})
+	body := "To=%2B15551234567&Body=hello&From=%2B15557654321"
+
+	mac := hmac.New(sha1.New, []byte(testWebhookSecret))
+	mac.Write([]byte("http://example.com/hook" + "Bodyhello" + "From+15557654321" + "To+15551234567"))
+	sig := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	w := sendWebhook(app, body, http.Header{
+		"Content-Type":       {"application/x-www-form-urlencoded"},
+		"X-Twilio-Signature": {sig},
+	})
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+}
+
+func TestWebhookGenericHMAC(t *testing.T) {
+	app := ginji.New()
+	app.Use(WebhookVerify(HMACWebhook(HMACWebhookConfig{
+		SignatureHeader: "X-Webhook-Signature",
+		TimestampHeader: "X-Webhook-Timestamp",
+	}), testWebhookSecret))
+	app.Post("/hook", func(c *ginji.Context) error {
+		// The body must still be readable after verification
+		body, _ := io.ReadAll(c.Req.Body)
+		return c.Text(ginji.StatusOK, string(body))
+	})
+	body := `{"event":"ping"}`
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+
+	w := sendWebhook(app, body, http.Header{
+		"X-Webhook-Timestamp": {ts},
+		"X-Webhook-Signature": {hmacHex(testWebhookSecret, ts+"."+body)},
+	})
+	ginji.AssertStatus(t, w, ginji.StatusOK)
+
+	w = sendWebhook(app, body, http.Header{"X-Webhook-Timestamp": {ts}})
+	ginji.AssertStatus(t, w, ginji.StatusUnauthorized)
+}
@@ -0,0 +1,556 @@
+package middleware
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ginjigo/ginji"
+)
+
+// WebSocket close codes defined by RFC 6455.
+const (
+	WebSocketCloseNormal        = 1000
+	WebSocketCloseGoingAway     = 1001
+	WebSocketCloseProtocolError = 1002
+	WebSocketCloseInvalidData   = 1007
+	WebSocketCloseMessageTooBig = 1009
+	WebSocketCloseInternalError = 1011
+)
+
+const (
+	websocketGUID               = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	websocketCloseNoStatus      = 1005
+	websocketOpcodeContinuation = 0
+	websocketMaxControlPayload  = 125
+
+	// websocketPongTolerance is the number of ping intervals a peer may
+	// stay silent before the connection is considered dead.
+	websocketPongTolerance = 2
+)
+
+// ErrWebSocketClosed is returned when using a closed connection.
+var ErrWebSocketClosed = errors.New("websocket: connection closed")
+
+// ErrWebSocketReadLimit is returned when a message exceeds the read limit.
+var ErrWebSocketReadLimit = errors.New("websocket: message exceeds read limit")
+
+// WebSocketCloseError is returned by ReadMessage when the peer closes the connection.
+type WebSocketCloseError struct {
+	Code int
+	Text string
+}
+
+// Error implements the error interface.
+func (e *WebSocketCloseError) Error() string {
+	return fmt.Sprintf("websocket: close %d %s", e.Code, e.Text)
+}
+
+// WebSocketConfig defines the configuration for WebSocket middleware.
+type WebSocketConfig struct {
+	// AllowedOrigins lists the origins allowed to connect. Entries may be
+	// "*" or use a wildcard subdomain such as "https://*.example.com".
+	// Default: same origin as the request Host, or no Origin header
+	AllowedOrigins []string
+
+	// CheckOrigin overrides AllowedOrigins with a custom check.
+	CheckOrigin func(*ginji.Context) bool
+
+	// Subprotocols are the supported subprotocols in order of preference.
+	// If set, clients must offer at least one of them.
+	Subprotocols []string
+
+	// ReadLimit is the maximum size of a message in bytes.
+	// Default: 1MB
+	ReadLimit int64
+
+	// PingInterval is the interval between keepalive pings. The connection
+	// is closed if nothing, including a pong, is received for two intervals.
+	// Default: 30 seconds
+	PingInterval time.Duration
+
+	// WriteTimeout is the deadline for writing a single message.
+	// Default: 10 seconds
+	WriteTimeout time.Duration
+
+	// ContextKey is the key used to store the connection in context.
+	// Default: "websocket"
+	ContextKey string
+
+	// SkipFunc allows skipping the upgrade for certain requests.
+	SkipFunc Skipper
+}
+
+// DefaultWebSocketConfig returns default WebSocket configuration.
+func DefaultWebSocketConfig() WebSocketConfig {
+	return WebSocketConfig{
+		ReadLimit:    1 << 20,
+		PingInterval: 30 * time.Second,
+		WriteTimeout: 10 * time.Second,
+		ContextKey:   "websocket",
+	}
+}
+
+// WebSocket returns middleware that upgrades the request to a WebSocket
+// connection with default configuration. Register it after authentication
+// and rate limiting so they run before the upgrade.
+func WebSocket() ginji.Middleware {
+	return WebSocketWithConfig(DefaultWebSocketConfig())
+}
+
+// WebSocketWithConfig returns WebSocket middleware with custom configuration.
+//
+// The upgraded connection is stored in context and retrieved by handlers
+// with GetWebSocket. It is closed when the handler returns; a handler
+// error closes it with code 1011.
+func WebSocketWithConfig(config WebSocketConfig) ginji.Middleware {
+	defaults := DefaultWebSocketConfig()
+	if config.ReadLimit == 0 {
+		config.ReadLimit = defaults.ReadLimit
+	}
+	if config.PingInterval == 0 {
+		config.PingInterval = defaults.PingInterval
+	}
+	if config.WriteTimeout == 0 {
+		config.WriteTimeout = defaults.WriteTimeout
+	}
+	if config.ContextKey == "" {
+		config.ContextKey = defaults.ContextKey
+	}
+	if config.CheckOrigin == nil {
+		config.CheckOrigin = websocketOriginCheck(config.AllowedOrigins)
+	}
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		if !isWebSocketUpgrade(c) {
+			c.SetHeader("Upgrade", "websocket")
+			c.AbortWithStatusJSON(http.StatusUpgradeRequired, ginji.H{
+				"error": "WebSocket upgrade required",
+			})
+			return nil
+		}
+
+		key := c.Header("Sec-WebSocket-Key")
+		if c.Header("Sec-WebSocket-Version") != "13" || !validWebSocketKey(key) {
+			c.SetHeader("Sec-WebSocket-Version", "13")
+			c.AbortWithStatusJSON(http.StatusBadRequest, ginji.H{
+				"error": "Invalid WebSocket handshake",
+			})
+			return nil
+		}
+
+		if !config.CheckOrigin(c) {
+			c.AbortWithStatusJSON(http.StatusForbidden, ginji.H{
+				"error": "Origin not allowed",
+			})
+			return nil
+		}
+
+		subprotocol := negotiateSubprotocol(c.Header("Sec-WebSocket-Protocol"), config.Subprotocols)
+		if len(config.Subprotocols) > 0 && subprotocol == "" {
+			c.AbortWithStatusJSON(http.StatusBadRequest, ginji.H{
+				"error": "Unsupported WebSocket subprotocol",
+			})
+			return nil
+		}
+
+		netConn, brw, err := http.NewResponseController(baseResponseWriter(c.Res)).Hijack()
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ginji.H{
+				"error": "WebSocket upgrade not supported",
+			})
+			return nil
+		}
+
+		response := "HTTP/1.1 101 Switching Protocols\r\n" +
+			"Upgrade: websocket\r\n" +
+			"Connection: Upgrade\r\n" +
+			"Sec-WebSocket-Accept: " + websocketAcceptKey(key) + "\r\n"
+		if subprotocol != "" {
+			response += "Sec-WebSocket-Protocol: " + subprotocol + "\r\n"
+		}
+		response += "\r\n"
+
+		_ = netConn.SetDeadline(time.Time{})
+		if _, err := brw.WriteString(response); err != nil {
+			_ = netConn.Close()
+			return nil
+		}
+		if err := brw.Flush(); err != nil {
+			_ = netConn.Close()
+			return nil
+		}
+
+		ws := newWebSocketConn(netConn, brw.Reader, subprotocol, config)
+		c.Set(config.ContextKey, ws)
+		setContextValue(c, webSocketContextKey, ws)
+
+		handlerErr := c.Next()
+		if handlerErr != nil {
+			_ = ws.CloseWithCode(WebSocketCloseInternalError, "")
+		} else {
+			_ = ws.Close()
+		}
+
+		// The connection is hijacked; nothing more can be written
+		c.Abort()
+		return nil
+	}
+}
+
+// GetWebSocket returns the connection upgraded by the WebSocket middleware.
+func GetWebSocket(c *ginji.Context) (*WebSocketConn, bool) {
+	ws, ok := c.Req.Context().Value(webSocketContextKey).(*WebSocketConn)
+	return ws, ok
+}
+
+// isWebSocketUpgrade reports whether the request asks for a WebSocket upgrade.
+func isWebSocketUpgrade(c *ginji.Context) bool {
+	return c.Req.Method == http.MethodGet &&
+		headerContainsToken(c.Req.Header, "Connection", "upgrade") &&
+		headerContainsToken(c.Req.Header, "Upgrade", "websocket")
+}
+
+// headerContainsToken reports whether a comma-separated header contains token.
+func headerContainsToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// validWebSocketKey checks that the key is a base64-encoded 16-byte nonce.
+func validWebSocketKey(key string) bool {
+	decoded, err := base64.StdEncoding.DecodeString(key)
+	return err == nil && len(decoded) == 16
+}
+
+// websocketAcceptKey computes the Sec-WebSocket-Accept value for key.
+func websocketAcceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// negotiateSubprotocol picks the first supported subprotocol offered by the client.
+func negotiateSubprotocol(offered string, supported []string) string {
+	if offered == "" {
+		return ""
+	}
+	for _, s := range supported {
+		for _, o := range strings.Split(offered, ",") {
+			if strings.TrimSpace(o) == s {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// websocketOriginCheck returns a check of the Origin header against
+// allowed origins, or against the request Host if none are allowed.
+func websocketOriginCheck(allowed []string) func(*ginji.Context) bool {
+	anyOrigin := slices.Contains(allowed, "*")
+	origins := newOriginMatcher(allowed)
+	return func(c *ginji.Context) bool {
+		origin := c.Header("Origin")
+		if origin == "" {
+			// Non-browser clients do not send an Origin header
+			return true
+		}
+		if origins.empty() {
+			return sameOrigin(c.Req, origin)
+		}
+		return anyOrigin || origins.match(origin)
+	}
+}
+
+// WebSocketConn is an RFC 6455 server-side WebSocket connection.
+//
+// ReadMessage must be called from a single goroutine; WriteMessage is safe
+// for concurrent use.
+type WebSocketConn struct {
+	conn         net.Conn
+	br           *bufio.Reader
+	subprotocol  string
+	readLimit    int64
+	pingInterval time.Duration
+	writeTimeout time.Duration
+
+	writeMu   sync.Mutex
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// newWebSocketConn wraps an upgraded connection and starts the keepalive loop.
+func newWebSocketConn(conn net.Conn, br *bufio.Reader, subprotocol string, config WebSocketConfig) *WebSocketConn {
+	ws := &WebSocketConn{
+		conn:         conn,
+		br:           br,
+		subprotocol:  subprotocol,
+		readLimit:    config.ReadLimit,
+		pingInterval: config.PingInterval,
+		writeTimeout: config.WriteTimeout,
+		done:         make(chan struct{}),
+	}
+	ws.extendReadDeadline()
+	go ws.keepalive()
+	return ws
+}
+
+// Subprotocol returns the negotiated subprotocol.
+func (ws *WebSocketConn) Subprotocol() string {
+	return ws.subprotocol
+}
+
+// RemoteAddr returns the remote network address.
+func (ws *WebSocketConn) RemoteAddr() net.Addr {
+	return ws.conn.RemoteAddr()
+}
+
+// keepalive sends pings until the connection is closed.
+func (ws *WebSocketConn) keepalive() {
+	ticker := time.NewTicker(ws.pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := ws.writeFrame(ginji.PingMessage, nil); err != nil {
+				return
+			}
+		case <-ws.done:
+			return
+		}
+	}
+}
+
+// extendReadDeadline gives the peer time to answer the next ping.
+func (ws *WebSocketConn) extendReadDeadline() {
+	_ = ws.conn.SetReadDeadline(time.Now().Add(websocketPongTolerance * ws.pingInterval))
+}
+
+// ReadMessage reads the next text or binary message. Control frames are
+// handled transparently: pings are answered and a close frame from the peer
+// is acknowledged and returned as a *WebSocketCloseError.
+func (ws *WebSocketConn) ReadMessage() (int, []byte, error) {
+	var (
+		messageType int
+		message     []byte
+	)
+
+	for {
+		fin, opcode, payload, err := ws.readFrame()
+		if err != nil {
+			if errors.Is(err, ErrWebSocketReadLimit) {
+				_ = ws.CloseWithCode(WebSocketCloseMessageTooBig, "")
+			} else if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				_ = ws.CloseWithCode(WebSocketCloseProtocolError, "")
+			}
+			return 0, nil, err
+		}
+		ws.extendReadDeadline()
+
+		switch opcode {
+		case ginji.PingMessage:
+			if err := ws.writeFrame(ginji.PongMessage, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case ginji.PongMessage:
+			continue
+		case ginji.CloseMessage:
+			closeErr := &WebSocketCloseError{Code: websocketCloseNoStatus}
+			if len(payload) >= 2 {
+				closeErr.Code = int(binary.BigEndian.Uint16(payload))
+				closeErr.Text = string(payload[2:])
+			}
+			_ = ws.CloseWithCode(WebSocketCloseNormal, "")
+			return 0, nil, closeErr
+		case ginji.TextMessage, ginji.BinaryMessage:
+			if messageType != 0 {
+				_ = ws.CloseWithCode(WebSocketCloseProtocolError, "")
+				return 0, nil, errors.New("websocket: new message before previous finished")
+			}
+			messageType = opcode
+		case websocketOpcodeContinuation:
+			if messageType == 0 {
+				_ = ws.CloseWithCode(WebSocketCloseProtocolError, "")
+				return 0, nil, errors.New("websocket: unexpected continuation frame")
+			}
+		default:
+			_ = ws.CloseWithCode(WebSocketCloseProtocolError, "")
+			return 0, nil, fmt.Errorf("websocket: unknown opcode %d", opcode)
+		}
+
+		if int64(len(message)+len(payload)) > ws.readLimit {
+			_ = ws.CloseWithCode(WebSocketCloseMessageTooBig, "")
+			return 0, nil, ErrWebSocketReadLimit
+		}
+		message = append(message, payload...)
+
+		if fin {
+			if messageType == ginji.TextMessage && !utf8.Valid(message) {
+				_ = ws.CloseWithCode(WebSocketCloseInvalidData, "")
+				return 0, nil, errors.New("websocket: invalid UTF-8 in text message")
+			}
+			return messageType, message, nil
+		}
+	}
+}
+
+// readFrame reads and unmasks a single frame.
+func (ws *WebSocketConn) readFrame() (bool, int, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(ws.br, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin := header[0]&0x80 != 0
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, errors.New("websocket: reserved bits set")
+	}
+	opcode := int(header[0] & 0x0F)
+	if header[1]&0x80 == 0 {
+		return false, 0, nil, errors.New("websocket: client frame not masked")
+	}
+
+	length := int64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(ws.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(ws.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]))
+	}
+
+	if opcode >= ginji.CloseMessage && (!fin || length > websocketMaxControlPayload) {
+		return false, 0, nil, errors.New("websocket: invalid control frame")
+	}
+	if length < 0 || length > ws.readLimit {
+		return false, 0, nil, ErrWebSocketReadLimit
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(ws.br, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(ws.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return fin, opcode, payload, nil
+}
+
+// WriteMessage writes a text or binary message.
+func (ws *WebSocketConn) WriteMessage(messageType int, data []byte) error {
+	if messageType != ginji.TextMessage && messageType != ginji.BinaryMessage {
+		return fmt.Errorf("websocket: invalid message type %d", messageType)
+	}
+	return ws.writeFrame(messageType, data)
+}
+
+// writeFrame writes a single unmasked frame.
+func (ws *WebSocketConn) writeFrame(opcode int, payload []byte) error {
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
+
+	select {
+	case <-ws.done:
+		return ErrWebSocketClosed
+	default:
+	}
+
+	header := make([]byte, 2, 10)
+	header[0] = 0x80 | byte(opcode)
+	switch n := len(payload); {
+	case n <= websocketMaxControlPayload:
+		header[1] = byte(n)
+	case n <= 0xFFFF:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	_ = ws.conn.SetWriteDeadline(time.Now().Add(ws.writeTimeout))
+	if _, err := ws.conn.Write(append(header, payload...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// WriteJSON writes v as a JSON text message.
+func (ws *WebSocketConn) WriteJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return ws.WriteMessage(ginji.TextMessage, data)
+}
+
+// ReadJSON reads the next message and decodes it into v.
+func (ws *WebSocketConn) ReadJSON(v any) error {
+	_, data, err := ws.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Close sends a normal close frame and closes the connection.
+func (ws *WebSocketConn) Close() error {
+	return ws.CloseWithCode(WebSocketCloseNormal, "")
+}
+
+// CloseWithCode sends a close frame with code and reason and closes the connection.
+func (ws *WebSocketConn) CloseWithCode(code int, reason string) error {
+	var err error
+	ws.closeOnce.Do(func() {
+		payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+		if len(reason) > websocketMaxControlPayload-2 {
+			reason = reason[:websocketMaxControlPayload-2]
+		}
+		payload = append(payload, reason...)
+		_ = ws.writeFrame(ginji.CloseMessage, payload)
+
+		ws.writeMu.Lock()
+		close(ws.done)
+		ws.writeMu.Unlock()
+		err = ws.conn.Close()
+	})
+	return err
+}
@@ -0,0 +1,206 @@
+package middleware
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// dialWebSocket performs a client handshake against srv.
+func dialWebSocket(t *testing.T, srv *httptest.Server, path string, header http.Header) (net.Conn, *bufio.Reader, *http.Response) {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest("GET", srv.URL+path, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn, br, resp
+}
+
+// writeClientFrame writes a masked frame as a browser would.
+func writeClientFrame(t *testing.T, conn net.Conn, opcode int, payload []byte) {
+	t.Helper()
+	frame := []byte{0x80 | byte(opcode)}
+	switch {
+	case len(payload) <= 125:
+		frame = append(frame, 0x80|byte(len(payload)))
+	case len(payload) <= 0xFFFF:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	}
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readServerFrame reads a single unmasked frame.
+func readServerFrame(t *testing.T, br *bufio.Reader) (int, []byte) {
+	t.Helper()
+	var header [2]byte
+	if _, err := io.ReadFull(br, header[:]); err != nil {
+		t.Fatal(err)
+	}
+	length := int(header[1] & 0x7F)
+	if length == 126 {
+		var ext [2]byte
+		_, _ = io.ReadFull(br, ext[:])
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(br, payload); err != nil {
+		t.Fatal(err)
+	}
+	return int(header[0] & 0x0F), payload
+}
+
+func newWebSocketEchoServer(config WebSocketConfig) *httptest.Server {
+	app := ginji.New()
+	app.Use(Timeout(time.Second))
+	app.Use(ginji.MockMiddleware("user", "alice"))
+	app.Use(WebSocketWithConfig(config))
+	app.Get("/ws", func(c *ginji.Context) error {
+		ws, _ := GetWebSocket(c)
+		for {
+			msgType, msg, err := ws.ReadMessage()
+			if err != nil {
+				return nil
+			}
+			reply := c.GetString("user") + ":" + string(msg)
+			if err := ws.WriteMessage(msgType, []byte(reply)); err != nil {
+				return nil
+			}
+		}
+	})
+	return httptest.NewServer(app)
+}
+
+func TestWebSocketEcho(t *testing.T) {
+	srv := newWebSocketEchoServer(WebSocketConfig{Subprotocols: []string{"chat.v2", "chat.v1"}})
+	defer srv.Close()
+
+	conn, br, resp := dialWebSocket(t, srv, "/ws", http.Header{
+		"Sec-Websocket-Protocol": {"chat.v1, chat.v2"},
+	})
+	defer func() { _ = conn.Close() }()
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected 101, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("Unexpected accept key %q", got)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Protocol"); got != "chat.v2" {
+		t.Errorf("Expected server-preferred subprotocol chat.v2, got %q", got)
+	}
+
+	writeClientFrame(t, conn, ginji.TextMessage, []byte("hello"))
+	op, payload := readServerFrame(t, br)
+	if op != ginji.TextMessage || string(payload) != "alice:hello" {
+		t.Errorf("Unexpected echo: %d %q", op, payload)
+	}
+
+	// Larger messages use the extended length encoding
+	big := strings.Repeat("x", 1000)
+	writeClientFrame(t, conn, ginji.TextMessage, []byte(big))
+	if _, payload := readServerFrame(t, br); string(payload) != "alice:"+big {
+		t.Errorf("Unexpected echo length %d", len(payload))
+	}
+
+	writeClientFrame(t, conn, ginji.PingMessage, []byte("p"))
+	if op, payload := readServerFrame(t, br); op != ginji.PongMessage || string(payload) != "p" {
+		t.Errorf("Expected pong, got %d %q", op, payload)
+	}
+}
+
+func TestWebSocketOriginCheck(t *testing.T) {
+	srv := newWebSocketEchoServer(WebSocketConfig{AllowedOrigins: []string{"https://*.example.com", "https://Admin.Example.org"}})
+	defer srv.Close()
+
+	conn, _, resp := dialWebSocket(t, srv, "/ws", http.Header{"Origin": {"https://evil.test"}})
+	_ = conn.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected 403 for disallowed origin, got %d", resp.StatusCode)
+	}
+
+	for _, origin := range []string{"https://app.example.com", "https://admin.example.org"} {
+		conn, _, resp = dialWebSocket(t, srv, "/ws", http.Header{"Origin": {origin}})
+		_ = conn.Close()
+		if resp.StatusCode != http.StatusSwitchingProtocols {
+			t.Errorf("Expected 101 for allowed origin %s, got %d", origin, resp.StatusCode)
+		}
+	}
+}
+
+func TestWebSocketReadLimit(t *testing.T) {
+	srv := newWebSocketEchoServer(WebSocketConfig{ReadLimit: 16})
+	defer srv.Close()
+
+	conn, br, _ := dialWebSocket(t, srv, "/ws", nil)
+	defer func() { _ = conn.Close() }()
+
+	writeClientFrame(t, conn, ginji.TextMessage, []byte(strings.Repeat("x", 32)))
+	op, payload := readServerFrame(t, br)
+	if op != ginji.CloseMessage || binary.BigEndian.Uint16(payload) != WebSocketCloseMessageTooBig {
+		t.Errorf("Expected close 1009, got %d %v", op, payload)
+	}
+}
+
+func TestWebSocketInvalidUTF8(t *testing.T) {
+	srv := newWebSocketEchoServer(WebSocketConfig{})
+	defer srv.Close()
+
+	conn, br, _ := dialWebSocket(t, srv, "/ws", nil)
+	defer func() { _ = conn.Close() }()
+
+	writeClientFrame(t, conn, ginji.TextMessage, []byte{'o', 'k', 0xff})
+	op, payload := readServerFrame(t, br)
+	if op != ginji.CloseMessage || binary.BigEndian.Uint16(payload) != WebSocketCloseInvalidData {
+		t.Errorf("Expected close 1007, got %d %v", op, payload)
+	}
+}
+
+func TestWebSocketRequiresUpgrade(t *testing.T) {
+	app := ginji.New()
+	app.Use(WebSocket())
+	app.Get("/ws", func(c *ginji.Context) error {
+		return nil
+	})
+
+	w := ginji.PerformRequest(app, "GET", "/ws", nil)
+	if w.Code != http.StatusUpgradeRequired {
+		t.Errorf("Expected 426, got %d", w.Code)
+	}
+}
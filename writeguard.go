@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+// ErrSlowConsumer is returned by writes to a client that reads the response
+// too slowly.
+var ErrSlowConsumer = errors.New("slow consumer: response read too slowly")
+
+// smallResponseSize is the size below which net/http buffers a whole
+// response to compute its Content-Length.
+const smallResponseSize = 2048
+
+// connWriteBufferSize is the size of net/http's connection write buffer, the
+// most that can be pending between flushes.
+const connWriteBufferSize = 4 << 10
+
+// WriteGuardConfig defines the configuration for write guard middleware.
+type WriteGuardConfig struct {
+	// Timeout is the longest a single write or flush may wait for the
+	// client to make room before the connection is aborted.
+	// Default: 10 seconds
+	Timeout time.Duration
+
+	// MinRate is the minimum rate in bytes per second at which the client
+	// must read. Each write is allowed Timeout plus the time to send its
+	// bytes at MinRate.
+	// Default: 1024
+	MinRate int64
+
+	// Logger logs aborted connections. If nil, uses engine's logger.
+	Logger *slog.Logger
+
+	// OnSlow is called when a client is cut off, e.g. to count them.
+	OnSlow func(c *ginji.Context, written int64)
+
+	// SkipFunc allows skipping the guard for certain requests.
+	SkipFunc Skipper
+}
+
+// DefaultWriteGuardConfig returns default write guard configuration.
+func DefaultWriteGuardConfig() WriteGuardConfig {
+	return WriteGuardConfig{
+		Timeout: 10 * time.Second,
+		MinRate: 1024,
+	}
+}
+
+// WriteGuard returns middleware that aborts connections to clients that
+// stall reading the response, so slow consumers cannot pin handler
+// goroutines and their buffers. Write deadlines are set on the connection
+// before every write, so a stalled write fails with ErrSlowConsumer instead
+// of blocking.
+//
+// It is the response side of SlowRequest.
+func WriteGuard() ginji.Middleware {
+	return WriteGuardWithConfig(DefaultWriteGuardConfig())
+}
+
+// WriteGuardWithConfig returns write guard middleware with custom configuration.
+func WriteGuardWithConfig(config WriteGuardConfig) ginji.Middleware {
+	defaults := DefaultWriteGuardConfig()
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+	if config.MinRate <= 0 {
+		config.MinRate = defaults.MinRate
+	}
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		// Hijacked connections manage their own deadlines
+		if _, ws := GetWebSocket(c); ws {
+			return c.Next()
+		}
+
+		rw := WrapResponseWriter(c)
+		gw := &writeGuard{
+			config:     &config,
+			controller: http.NewResponseController(baseResponseWriter(rw)),
+		}
+		rw.BeforeWrite(gw.beforeWrite)
+		rw.OnWriteError(gw.writeFailed)
+		err := c.Next()
+
+		// Send what is left under the deadline, unless the response is small
+		// enough for net/http to still set its Content-Length
+		if !gw.slow && gw.pending > 0 && (gw.flushed || gw.written > smallResponseSize) {
+			_ = rw.FlushError()
+		}
+		if !gw.slow {
+			// Clear the deadline so it does not outlive the request
+			_ = gw.controller.SetWriteDeadline(time.Time{})
+			return err
+		}
+
+		requestLogger(c, config.Logger).LogAttrs(c.Req.Context(), slog.LevelWarn, "Slow consumer",
+			slog.String("method", c.Req.Method),
+			slog.String("path", c.Req.URL.Path),
+			slog.String("client_ip", c.Req.RemoteAddr),
+			slog.Int64("written", gw.written),
+		)
+		if config.OnSlow != nil {
+			config.OnSlow(c, gw.written)
+		}
+		c.Abort()
+		return nil
+	}
+}
+
+// writeGuard sets a write deadline before each write and flush.
+type writeGuard struct {
+	config     *WriteGuardConfig
+	controller *http.ResponseController
+	written    int64
+	pending    int64 // Bytes written since the last explicit flush
+	flushed    bool
+	slow       bool
+}
+
+// beforeWrite sets a deadline for n bytes, or a flush if n is 0, and any
+// buffered bytes.
+func (w *writeGuard) beforeWrite(n int) error {
+	if w.slow {
+		return ErrSlowConsumer
+	}
+	// Without deadline support, e.g. in tests, writes are not guarded
+	_ = w.controller.SetWriteDeadline(w.deadline(int64(n)))
+
+	if n == 0 {
+		w.flushed = true
+		w.pending = 0
+		return nil
+	}
+	w.written += int64(n)
+	w.pending += int64(n)
+	return nil
+}
+
+// writeFailed marks the client as slow if a write missed its deadline.
+func (w *writeGuard) writeFailed(err error) error {
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		w.slow = true
+		return ErrSlowConsumer
+	}
+	return err
+}
+
+// deadline returns the time by which n more bytes, together with those
+// still buffered, must be sent.
+func (w *writeGuard) deadline(n int64) time.Time {
+	buffered := min(w.pending, connWriteBufferSize)
+	send := time.Duration(float64(buffered+n) / float64(w.config.MinRate) * float64(time.Second))
+	return time.Now().Add(w.config.Timeout + send)
+}
@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ginjigo/ginji"
+)
+
+func TestWriteGuard(t *testing.T) {
+	app := ginji.New()
+	app.Use(WriteGuard())
+	app.Get("/small", func(c *ginji.Context) error {
+		return c.Text(ginji.StatusOK, "hello")
+	})
+	app.Get("/large", func(c *ginji.Context) error {
+		for range 100 {
+			if _, err := c.Res.Write([]byte(strings.Repeat("x", 1000))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	server := httptest.NewServer(app)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/small")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "hello" || resp.ContentLength != 5 {
+		t.Errorf("Expected a small response with Content-Length, got %q (%d)", body, resp.ContentLength)
+	}
+
+	resp, err = http.Get(server.URL + "/large")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || len(body) != 100000 {
+		t.Errorf("Expected the full body, got %d bytes, %v", len(body), err)
+	}
+}
+
+func TestWriteGuardAbortsStalledClient(t *testing.T) {
+	slow := make(chan int64, 1)
+	writeErr := make(chan error, 1)
+	app := ginji.New()
+	app.Use(WriteGuardWithConfig(WriteGuardConfig{
+		Timeout: 50 * time.Millisecond,
+		MinRate: 1 << 20,
+		Logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+		OnSlow:  func(c *ginji.Context, written int64) { slow <- written },
+	}))
+	app.Get("/", func(c *ginji.Context) error {
+		chunk := []byte(strings.Repeat("x", 32<<10))
+		for range 4096 {
+			if _, err := c.Res.Write(chunk); err != nil {
+				writeErr <- err
+				return err
+			}
+		}
+		writeErr <- nil
+		return nil
+	})
+
+	server := httptest.NewServer(app)
+	defer server.Close()
+
+	// Send a request and never read the response
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-writeErr:
+		if !errors.Is(err, ErrSlowConsumer) {
+			t.Fatalf("Expected ErrSlowConsumer, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the stalled write to be aborted")
+	}
+	select {
+	case written := <-slow:
+		if written <= 0 || written >= 4096*32<<10 {
+			t.Errorf("Expected a partial response, got %d bytes", written)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected OnSlow to be called")
+	}
+
+	// The connection is closed rather than left for the next request
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+	}
+	if err == nil {
+		t.Error("Expected the truncated response to fail")
+	}
+}
@@ -0,0 +1,332 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/ginjigo/ginji"
+)
+
+// SOAP envelope namespaces.
+const (
+	soap11Namespace = "http://schemas.xmlsoap.org/soap/envelope/"
+	soap12Namespace = "http://www.w3.org/2003/05/soap-envelope"
+)
+
+// xmlMaxBytesKey stores the body cap configured by the XML middleware.
+const xmlMaxBytesKey = "xml_max_bytes"
+
+// XML decoding errors.
+var (
+	ErrXMLDoctype = errors.New("xml: document type declarations are not allowed")
+	ErrXMLTooDeep = errors.New("xml: document nested too deeply")
+)
+
+// XMLConfig defines the configuration for XML middleware.
+type XMLConfig struct {
+	// MaxBodyBytes is the maximum size of XML request bodies.
+	// Default: 1MB
+	MaxBodyBytes int64
+
+	// MaxDepth is the maximum element nesting of XML request bodies.
+	// Default: 64
+	MaxDepth int
+
+	// SOAPFaults renders request errors and errors returned by handlers as
+	// SOAP fault envelopes for SOAP clients.
+	// Default: false
+	SOAPFaults bool
+
+	// SOAPVersion selects the fault format, "1.1" or "1.2".
+	// Default: "1.1"
+	SOAPVersion string
+
+	// SkipFunc allows skipping the middleware for certain requests.
+	SkipFunc Skipper
+}
+
+// SOAPFault is a SOAP fault. Handlers return it to send a specific fault
+// when XMLConfig.SOAPFaults is enabled.
+type SOAPFault struct {
+	// Code is the fault code without prefix: "Client" or "Server" for SOAP
+	// 1.1, "Sender" or "Receiver" for SOAP 1.2.
+	// Default: "Server" or "Receiver"
+	Code string
+
+	// String is the human-readable reason.
+	String string
+
+	// Actor identifies the node that caused the fault.
+	Actor string
+
+	// Detail is marshaled as XML into the fault's detail element.
+	Detail any
+
+	// StatusCode is the HTTP status of the fault.
+	// Default: 500, or 400 for SOAP 1.2 sender faults
+	StatusCode int
+}
+
+// Error implements the error interface.
+func (f *SOAPFault) Error() string {
+	return fmt.Sprintf("soap fault %s: %s", f.Code, f.String)
+}
+
+// DefaultXMLConfig returns default XML configuration.
+func DefaultXMLConfig() XMLConfig {
+	return XMLConfig{
+		MaxBodyBytes: 1 << 20, // 1 MB
+		MaxDepth:     64,
+		SOAPVersion:  "1.1",
+	}
+}
+
+// XML returns XML middleware with default configuration.
+func XML() ginji.Middleware {
+	return XMLWithConfig(DefaultXMLConfig())
+}
+
+// XMLWithConfig returns middleware for XML APIs. It buffers XML request
+// bodies up to MaxBodyBytes and rejects documents that are malformed,
+// nested too deeply or contain a DOCTYPE, which rules out external entity
+// and entity expansion attacks. Handlers decode the body with BindXML.
+//
+// With SOAPFaults, rejected requests and handler errors are rendered as
+// SOAP faults:
+//
+//	soap := app.Group("/soap")
+//	soap.Use(middleware.XMLWithConfig(middleware.XMLConfig{SOAPFaults: true}))
+func XMLWithConfig(config XMLConfig) ginji.Middleware {
+	defaults := DefaultXMLConfig()
+	if config.MaxBodyBytes <= 0 {
+		config.MaxBodyBytes = defaults.MaxBodyBytes
+	}
+	if config.MaxDepth <= 0 {
+		config.MaxDepth = defaults.MaxDepth
+	}
+	if config.SOAPVersion == "" {
+		config.SOAPVersion = defaults.SOAPVersion
+	}
+
+	// fail renders err and stops the chain.
+	fail := func(c *ginji.Context, err error) {
+		if config.SOAPFaults {
+			writeSOAPFault(c, config.SOAPVersion, err)
+		} else {
+			status, message := errorStatus(err)
+			_ = WriteXML(c, status, xmlError{Code: status, Message: message})
+		}
+		c.Abort()
+	}
+
+	return func(c *ginji.Context) error {
+		// Skip if skip function returns true
+		if config.SkipFunc != nil && config.SkipFunc(c) {
+			return c.Next()
+		}
+
+		c.Set(xmlMaxBytesKey, config.MaxBodyBytes)
+		if isXMLContentType(c.Header("Content-Type")) {
+			body, err := bufferRequestBody(c, config.MaxBodyBytes)
+			if errors.Is(err, errBodyTooLarge) {
+				fail(c, ginji.NewHTTPError(http.StatusRequestEntityTooLarge,
+					fmt.Sprintf("Request body too large. Maximum allowed size is %d bytes", config.MaxBodyBytes)))
+				return nil
+			}
+			if err != nil {
+				fail(c, ginji.NewHTTPError(http.StatusBadRequest, "Failed to read request body"))
+				return nil
+			}
+			if len(bytes.TrimSpace(body)) > 0 {
+				if err := checkXML(body, config.MaxDepth); err != nil {
+					fail(c, ginji.NewHTTPError(http.StatusBadRequest, "Invalid XML: "+err.Error()))
+					return nil
+				}
+			}
+		}
+
+		err := c.Next()
+		if err != nil && config.SOAPFaults {
+			fail(c, err)
+			return nil
+		}
+		return err
+	}
+}
+
+// BindXML decodes the XML request body into v. The body is limited to the
+// XML middleware's MaxBodyBytes, or 1MB without it, and documents with a
+// DOCTYPE are rejected. Errors are *ginji.HTTPError with status 400 or 413.
+func BindXML(c *ginji.Context, v any) error {
+	maxBytes := DefaultXMLConfig().MaxBodyBytes
+	if val, ok := c.Get(xmlMaxBytesKey); ok {
+		maxBytes, _ = val.(int64)
+	}
+
+	body, err := bufferRequestBody(c, maxBytes)
+	if errors.Is(err, errBodyTooLarge) {
+		return ginji.NewHTTPError(http.StatusRequestEntityTooLarge, "Request body too large")
+	}
+	if err != nil {
+		return ginji.NewHTTPError(http.StatusBadRequest, "Failed to read request body")
+	}
+	if err := checkXML(body, DefaultXMLConfig().MaxDepth); err != nil {
+		return ginji.NewHTTPError(http.StatusBadRequest, "Invalid XML: "+err.Error())
+	}
+	if err := newXMLDecoder(bytes.NewReader(body)).Decode(v); err != nil {
+		return ginji.NewHTTPError(http.StatusBadRequest, "Invalid XML: "+err.Error())
+	}
+	return nil
+}
+
+// WriteXML writes v as an XML document with the given status.
+func WriteXML(c *ginji.Context, code int, v any) error {
+	body, err := xml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	c.SetHeader("Content-Type", "application/xml; charset=utf-8")
+	c.Res.WriteHeader(code)
+	_, err = c.Res.Write(append([]byte(xml.Header), body...))
+	return err
+}
+
+// xmlError is the error body of XML middleware without SOAP faults.
+type xmlError struct {
+	XMLName xml.Name `xml:"error"`
+	Code    int      `xml:"code"`
+	Message string   `xml:"message"`
+}
+
+// newXMLDecoder returns a strict decoder that only knows the predefined
+// entities and rejects non-UTF-8 encodings.
+func newXMLDecoder(r io.Reader) *xml.Decoder {
+	d := xml.NewDecoder(r)
+	d.Strict = true
+	return d
+}
+
+// checkXML reports whether data is a well-formed document without a
+// DOCTYPE and with at most maxDepth nested elements.
+func checkXML(data []byte, maxDepth int) error {
+	d := newXMLDecoder(bytes.NewReader(data))
+	depth := 0
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch tok.(type) {
+		case xml.Directive:
+			return ErrXMLDoctype
+		case xml.StartElement:
+			depth++
+			if depth > maxDepth {
+				return ErrXMLTooDeep
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+}
+
+// isXMLContentType reports whether contentType is an XML media type.
+func isXMLContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml")
+}
+
+// writeSOAPFault renders err as a SOAP fault of the given version.
+func writeSOAPFault(c *ginji.Context, version string, err error) {
+	soap12 := version == "1.2"
+
+	var fault SOAPFault
+	var soapFault *SOAPFault
+	if errors.As(err, &soapFault) {
+		fault = *soapFault
+	} else {
+		status, message := errorStatus(err)
+		fault = SOAPFault{Code: "Server", String: message}
+		if status < 500 {
+			fault.Code = "Client"
+			if soap12 {
+				fault.StatusCode = status
+			}
+		}
+	}
+
+	switch {
+	case soap12 && fault.Code == "Client":
+		fault.Code = "Sender"
+	case soap12 && (fault.Code == "Server" || fault.Code == ""):
+		fault.Code = "Receiver"
+	case fault.Code == "":
+		fault.Code = "Server"
+	}
+
+	var detail []byte
+	if fault.Detail != nil {
+		detail, _ = xml.Marshal(fault.Detail)
+	}
+
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	if soap12 {
+		b.WriteString(`<env:Envelope xmlns:env="` + soap12Namespace + `"><env:Body><env:Fault>`)
+		b.WriteString(`<env:Code><env:Value>env:` + xmlEscape(fault.Code) + `</env:Value></env:Code>`)
+		b.WriteString(`<env:Reason><env:Text xml:lang="en">` + xmlEscape(fault.String) + `</env:Text></env:Reason>`)
+		if fault.Actor != "" {
+			b.WriteString(`<env:Role>` + xmlEscape(fault.Actor) + `</env:Role>`)
+		}
+		if detail != nil {
+			b.WriteString(`<env:Detail>` + string(detail) + `</env:Detail>`)
+		}
+		b.WriteString(`</env:Fault></env:Body></env:Envelope>`)
+	} else {
+		b.WriteString(`<soap:Envelope xmlns:soap="` + soap11Namespace + `"><soap:Body><soap:Fault>`)
+		b.WriteString(`<faultcode>soap:` + xmlEscape(fault.Code) + `</faultcode>`)
+		b.WriteString(`<faultstring>` + xmlEscape(fault.String) + `</faultstring>`)
+		if fault.Actor != "" {
+			b.WriteString(`<faultactor>` + xmlEscape(fault.Actor) + `</faultactor>`)
+		}
+		if detail != nil {
+			b.WriteString(`<detail>` + string(detail) + `</detail>`)
+		}
+		b.WriteString(`</soap:Fault></soap:Body></soap:Envelope>`)
+	}
+
+	// SOAP 1.1 sends faults with 500; SOAP 1.2 uses 400 for sender faults
+	status := fault.StatusCode
+	if status == 0 {
+		status = http.StatusInternalServerError
+		if soap12 && fault.Code == "Sender" {
+			status = http.StatusBadRequest
+		}
+	}
+	if soap12 {
+		c.SetHeader("Content-Type", "application/soap+xml; charset=utf-8")
+	} else {
+		c.SetHeader("Content-Type", "text/xml; charset=utf-8")
+	}
+	c.Res.WriteHeader(status)
+	_, _ = c.Res.Write([]byte(b.String()))
+}
+
+// xmlEscape escapes s for use in XML text.
+func xmlEscape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
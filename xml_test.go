@@ -0,0 +1,180 @@
+package middleware
+
+import (
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ginjigo/ginji"
+)
+
+type xmlOrder struct {
+	XMLName xml.Name `xml:"order"`
+	ID      string   `xml:"id,attr"`
+	Items   []string `xml:"item"`
+}
+
+func postXML(app *ginji.Engine, body string) *ginji.Request {
+	return ginji.NewRequest(app, "POST", "/orders").
+		Header("Content-Type", "application/xml").
+		Body(strings.NewReader(body))
+}
+
+func TestXMLBinding(t *testing.T) {
+	app := ginji.New()
+	app.Use(XMLWithConfig(DefaultXMLConfig()))
+	app.Post("/orders", func(c *ginji.Context) error {
+		var order xmlOrder
+		if err := BindXML(c, &order); err != nil {
+			return err
+		}
+		return WriteXML(c, ginji.StatusCreated, order)
+	})
+
+	w := postXML(app, `<?xml version="1.0"?><order id="7"><item>a</item><item>b</item></order>`).Do()
+	ginji.AssertStatus(t, w, ginji.StatusCreated)
+	ginji.AssertHeader(t, w, "Content-Type", "application/xml; charset=utf-8")
+	ginji.AssertBody(t, w, `<order id="7"><item>a</item><item>b</item></order>`)
+}
+
+func TestXMLRejectsUnsafeDocuments(t *testing.T) {
+	config := DefaultXMLConfig()
+	config.MaxBodyBytes = 512
+	config.MaxDepth = 3
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"external entity", `<?xml version="1.0"?><!DOCTYPE order [<!ENTITY xxe SYSTEM "file:///etc/passwd">]><order>&xxe;</order>`, ginji.StatusBadRequest},
+		{"entity expansion", `<!DOCTYPE lolz [<!ENTITY lol "lol"><!ENTITY lol2 "&lol;&lol;">]><order>&lol2;</order>`, ginji.StatusBadRequest},
+		{"undefined entity", `<order>&nbsp;</order>`, ginji.StatusBadRequest},
+		{"malformed", `<order><item></order>`, ginji.StatusBadRequest},
+		{"too deep", `<order><a><b><c/></b></a></order>`, ginji.StatusBadRequest},
+		{"too large", `<order>` + strings.Repeat("<item>x</item>", 50) + `</order>`, ginji.StatusRequestEntityTooLarge},
+	}
+
+	app := ginji.New()
+	app.Use(XMLWithConfig(config))
+	app.Post("/orders", func(c *ginji.Context) error {
+		var order xmlOrder
+		if err := BindXML(c, &order); err != nil {
+			return err
+		}
+		return WriteXML(c, ginji.StatusCreated, order)
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postXML(app, tt.body).Do()
+			ginji.AssertStatus(t, w, tt.status)
+			ginji.AssertBody(t, w, "<error><code>")
+		})
+	}
+}
+
+func TestBindXMLWithoutMiddleware(t *testing.T) {
+	app := ginji.New()
+	var got error
+	app.Post("/orders", func(c *ginji.Context) error {
+		var order xmlOrder
+		got = BindXML(c, &order)
+		return c.Text(ginji.StatusOK, order.ID)
+	})
+
+	postXML(app, `<!DOCTYPE order SYSTEM "http://evil.example/order.dtd"><order id="1"/>`).Do()
+	var httpErr *ginji.HTTPError
+	if !errors.As(got, &httpErr) || httpErr.Code != ginji.StatusBadRequest {
+		t.Fatalf("Expected 400 error, got %v", got)
+	}
+
+	w := postXML(app, `<order id="2"/>`).Do()
+	ginji.AssertBody(t, w, "2")
+}
+
+func TestXMLSOAPFaults(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		err     error
+		body    string
+		status  int
+		want    []string
+	}{
+		{
+			name: "soap 1.1 client fault", version: "1.1",
+			body:   `<!DOCTYPE x []><order/>`,
+			status: ginji.StatusInternalServerError,
+			want: []string{
+				`<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">`,
+				`<faultcode>soap:Client</faultcode>`,
+				`<faultstring>Invalid XML: xml: document type declarations are not allowed</faultstring>`,
+			},
+		},
+		{
+			name: "soap 1.1 hides internal errors", version: "1.1",
+			err:    errors.New("connection refused to db:5432"),
+			status: ginji.StatusInternalServerError,
+			want:   []string{`<faultcode>soap:Server</faultcode><faultstring>Internal Server Error</faultstring>`},
+		},
+		{
+			name: "soap 1.2 sender fault", version: "1.2",
+			err:    ginji.NewHTTPError(ginji.StatusUnprocessableEntity, "Unknown product & size"),
+			status: ginji.StatusUnprocessableEntity,
+			want: []string{
+				`<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope">`,
+				`<env:Value>env:Sender</env:Value>`,
+				`<env:Text xml:lang="en">Unknown product &amp; size</env:Text>`,
+			},
+		},
+		{
+			name: "custom fault", version: "1.1",
+			err: &SOAPFault{Code: "Client", String: "Out of stock", Actor: "urn:inventory",
+				Detail: struct {
+					XMLName xml.Name `xml:"stock"`
+					Left    int      `xml:"left"`
+				}{Left: 0}},
+			status: ginji.StatusInternalServerError,
+			want: []string{
+				`<faultactor>urn:inventory</faultactor>`,
+				`<detail><stock><left>0</left></stock></detail>`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultXMLConfig()
+			config.SOAPFaults = true
+			config.SOAPVersion = tt.version
+			body := tt.body
+			if body == "" {
+				body = `<order/>`
+			}
+
+			app := ginji.New()
+			app.Use(XMLWithConfig(config))
+			app.Post("/orders", func(c *ginji.Context) error {
+				if tt.err != nil {
+					return tt.err
+				}
+				var order xmlOrder
+				if err := BindXML(c, &order); err != nil {
+					return err
+				}
+				return WriteXML(c, ginji.StatusCreated, order)
+			})
+
+			w := postXML(app, body).Do()
+			ginji.AssertStatus(t, w, tt.status)
+			for _, want := range tt.want {
+				ginji.AssertBody(t, w, want)
+			}
+			if strings.Contains(w.Body.String(), "5432") {
+				t.Error("Internal error leaked")
+			}
+		})
+	}
+}